@@ -10,8 +10,10 @@ import (
 	"io/ioutil"
 	"net"
 	"os/exec"
+	"path"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/shirou/gopsutil/host"
@@ -36,6 +38,7 @@ type StorkAgent struct {
 	server         *grpc.Server
 	logTailer      *logTailer
 	keaInterceptor *keaInterceptor
+	hostInventory  *hostInventory
 }
 
 // API exposed to Stork Server.
@@ -58,6 +61,7 @@ func NewStorkAgent(settings *cli.Context, appMonitor AppMonitor) *StorkAgent {
 		RndcClient:     rndcClient,
 		logTailer:      logTailer,
 		keaInterceptor: newKeaInterceptor(),
+		hostInventory:  newHostInventory(),
 	}
 
 	registerKeaInterceptFns(sa)
@@ -161,13 +165,26 @@ func (sa *StorkAgent) Ping(ctx context.Context, in *agentapi.PingReq) (*agentapi
 
 // Get state of machine.
 func (sa *StorkAgent) GetState(ctx context.Context, in *agentapi.GetStateReq) (*agentapi.GetStateRsp, error) {
+	// The receive and transmit times allow the server to compute the clock
+	// skew excluding the time spent on gathering the state.
+	receiveTime := time.Now()
+
 	vm, _ := mem.VirtualMemory()
 	hostInfo, _ := host.Info()
 	load, _ := load.Avg()
 	loadStr := fmt.Sprintf("%.2f %.2f %.2f", load.Load1, load.Load5, load.Load15)
 
+	storageLocations, databases := sa.hostInventory.getKea()
+
 	var apps []*agentapi.App
 	for _, app := range sa.AppMonitor.GetApps() {
+		for _, dir := range app.DataDirs {
+			storageLocations = append(storageLocations, storageLocation{
+				Path: dir,
+				Kind: StorageKindBind9Zones,
+			})
+		}
+
 		var accessPoints []*agentapi.AccessPoint
 		for _, point := range app.AccessPoints {
 			accessPoints = append(accessPoints, &agentapi.AccessPoint{
@@ -178,9 +195,23 @@ func (sa *StorkAgent) GetState(ctx context.Context, in *agentapi.GetStateReq) (*
 			})
 		}
 
+		var logTargets []*agentapi.LogTarget
+		for _, target := range app.LogTargets {
+			// Make sure that the reported log files can be viewed.
+			if path.IsAbs(target.Output) {
+				sa.logTailer.allow(target.Output)
+			}
+			logTargets = append(logTargets, &agentapi.LogTarget{
+				Name:     target.Name,
+				Severity: target.Severity,
+				Output:   target.Output,
+			})
+		}
+
 		apps = append(apps, &agentapi.App{
 			Type:         app.Type,
 			AccessPoints: accessPoints,
+			LogTargets:   logTargets,
 		})
 	}
 
@@ -202,8 +233,14 @@ func (sa *StorkAgent) GetState(ctx context.Context, in *agentapi.GetStateReq) (*
 		VirtualizationSystem: hostInfo.VirtualizationSystem,
 		VirtualizationRole:   hostInfo.VirtualizationRole,
 		HostID:               hostInfo.HostID,
+		Interfaces:           getNetworkInterfaces(),
+		Filesystems:          getFilesystemsUsage(storageLocations),
+		Databases:            getDatabasesReachability(databases),
+		ReceiveTime:          receiveTime.UnixNano(),
+		ClockSyncStatus:      getClockSyncStatus(),
 		Error:                "",
 	}
+	state.TransmitTime = time.Now().UnixNano()
 
 	return &state, nil
 }
@@ -355,7 +392,7 @@ func (sa *StorkAgent) ForwardToKeaOverHTTP(ctx context.Context, in *agentapi.For
 		// Push Kea response for async processing. One of the use cases is to
 		// extract log files used by Kea and to allow the log viewer to access
 		// them.
-		go sa.keaInterceptor.asyncHandle(sa, req, body)
+		go sa.keaInterceptor.asyncHandle(sa, reqURL, req, body)
 
 		// gzip json response received from Kea
 		var gzippedBuf bytes.Buffer
@@ -403,13 +440,29 @@ func (sa *StorkAgent) TailTextFile(ctx context.Context, in *agentapi.TailTextFil
 		},
 	}
 
-	lines, err := sa.logTailer.tail(in.Path, in.Offset)
+	anchor := logAnchor{
+		FileID: in.AnchorFileId,
+		Offset: in.AnchorOffset,
+	}
+	tail, err := sa.logTailer.tail(in.Path, in.Offset, anchor, in.Filter, in.ScanLength)
 	if err != nil {
 		response.Status.Code = agentapi.Status_ERROR
 		response.Status.Message = fmt.Sprintf("%s", err)
 		return response, nil
 	}
-	response.Lines = lines
+	response.Lines = tail.Lines
+	response.NextAnchorFileId = tail.Next.FileID
+	response.NextAnchorOffset = tail.Next.Offset
+	response.Beginning = tail.Beginning
+	for _, file := range tail.Files {
+		response.Files = append(response.Files, &agentapi.LogFile{
+			Id:         file.ID,
+			Path:       file.Path,
+			Size:       file.Size,
+			ModTime:    file.ModTime.Unix(),
+			Compressed: file.Compressed,
+		})
+	}
 
 	return response, nil
 }
@@ -71,6 +71,7 @@ func setupAgentTest(rndc CommandExecutor) (*StorkAgent, context.Context) {
 		RndcClient:     rndcClient,
 		logTailer:      newLogTailer(),
 		keaInterceptor: newKeaInterceptor(),
+		hostInventory:  newHostInventory(),
 	}
 	sa.Setup()
 	ctx := context.Background()
@@ -144,6 +145,19 @@ func TestGetState(t *testing.T) {
 	apps = append(apps, &App{
 		Type:         AppTypeBind9,
 		AccessPoints: accessPoints,
+		DataDirs:     []string{os.TempDir()},
+		LogTargets: []LogTarget{
+			{
+				Name:     "default_log",
+				Severity: "info",
+				Output:   "/var/log/named.log",
+			},
+			{
+				Name:     "system",
+				Severity: "warning",
+				Output:   "syslog",
+			},
+		},
 	})
 	fam, _ := sa.AppMonitor.(*FakeAppMonitor)
 	fam.Apps = apps
@@ -174,6 +188,29 @@ func TestGetState(t *testing.T) {
 	require.Equal(t, "2.3.4.5", point.Address)
 	require.EqualValues(t, 2346, point.Port)
 	require.Empty(t, point.Key)
+
+	// the log targets should be reported and the log file can be viewed
+	require.Empty(t, keaApp.LogTargets)
+	require.Len(t, bind9App.LogTargets, 2)
+	require.Equal(t, "default_log", bind9App.LogTargets[0].Name)
+	require.Equal(t, "info", bind9App.LogTargets[0].Severity)
+	require.Equal(t, "/var/log/named.log", bind9App.LogTargets[0].Output)
+	require.Equal(t, "syslog", bind9App.LogTargets[1].Output)
+	require.True(t, sa.logTailer.allowed("/var/log/named.log"))
+	require.False(t, sa.logTailer.allowed("syslog"))
+
+	// the usage of the filesystem holding the zone files should be reported
+	require.Len(t, rsp.Filesystems, 1)
+	require.Equal(t, os.TempDir(), rsp.Filesystems[0].Path)
+	require.Equal(t, StorageKindBind9Zones, rsp.Filesystems[0].Kind)
+	require.Empty(t, rsp.Filesystems[0].Error)
+	require.NotZero(t, rsp.Filesystems[0].Total)
+	require.Empty(t, rsp.Databases)
+
+	// the agent's clock should be reported
+	require.NotZero(t, rsp.ReceiveTime)
+	require.GreaterOrEqual(t, rsp.TransmitTime, rsp.ReceiveTime)
+	require.Contains(t, []string{ClockSyncStatusSynchronized, ClockSyncStatusUnsynchronized, ClockSyncStatusUnknown}, rsp.ClockSyncStatus)
 }
 
 // Helper function for unzipping buffers. It does not return
@@ -510,6 +547,46 @@ func TestTailTextFile(t *testing.T) {
 	require.Len(t, rsp.Lines, 2)
 	require.Equal(t, "which is used", rsp.Lines[0])
 	require.Equal(t, "in testing TailTextFile", rsp.Lines[1])
+	require.Equal(t, rsp.Files[0].Id, rsp.NextAnchorFileId)
+	require.EqualValues(t, 15, rsp.NextAnchorOffset)
+	require.False(t, rsp.Beginning)
+	require.Len(t, rsp.Files, 1)
+	require.NotEmpty(t, rsp.Files[0].Id)
+	require.Equal(t, filename, rsp.Files[0].Path)
+	require.EqualValues(t, 53, rsp.Files[0].Size)
+	require.NotZero(t, rsp.Files[0].ModTime)
+
+	// Lines appended in the meantime don't affect paging backward.
+	fmt.Fprintln(f, "appended line")
+
+	// Fetch the preceding part of the file.
+	req = &agentapi.TailTextFileReq{
+		Offset:       38,
+		AnchorFileId: rsp.NextAnchorFileId,
+		AnchorOffset: rsp.NextAnchorOffset,
+		Path:         filename,
+	}
+
+	rsp, err = sa.TailTextFile(ctx, req)
+	require.NoError(t, err)
+	require.Len(t, rsp.Lines, 1)
+	require.Equal(t, "This is a file", rsp.Lines[0])
+	require.True(t, rsp.Beginning)
+
+	// Remove the appended line.
+	require.NoError(t, f.Truncate(53))
+
+	// Fetch the filtered lines.
+	req = &agentapi.TailTextFileReq{
+		Offset: 200,
+		Filter: "^[Tt]his",
+		Path:   filename,
+	}
+
+	rsp, err = sa.TailTextFile(ctx, req)
+	require.NoError(t, err)
+	require.Len(t, rsp.Lines, 1)
+	require.Equal(t, "This is a file", rsp.Lines[0])
 
 	// Test the case when the offset is beyond the file size.
 	req = &agentapi.TailTextFileReq{
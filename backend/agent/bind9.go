@@ -227,6 +227,127 @@ func getStatisticsChannelFromBind9Config(text string) (statsAddress string, stat
 	return statsAddress, statsPort, statsKey
 }
 
+// getBind9Directory returns the working directory of named specified in
+// the options clause of the configuration `text`. If it is not specified
+// or relative, it is resolved against the working directory of the named
+// process (`cwd`).
+func getBind9Directory(text, cwd string) string {
+	dirPtrn := regexp.MustCompile(`(?m)^\s*directory\s+"([^"]+)"\s*;`)
+	m := dirPtrn.FindStringSubmatch(text)
+	if m == nil {
+		return cwd
+	}
+	if path.IsAbs(m[1]) {
+		return m[1]
+	}
+	return path.Join(cwd, m[1])
+}
+
+// findBind9ClauseEnd returns the position of the closing brace of the
+// clause which body begins at `start` in the configuration `text`, i.e.
+// right after the opening brace.
+func findBind9ClauseEnd(text string, start int) int {
+	depth := 1
+	end := start
+	for ; end < len(text) && depth > 0; end++ {
+		switch text[end] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		}
+	}
+	return end
+}
+
+// getZoneDirsFromBind9Config returns the unique directories holding the zone
+// files specified in the configuration `text`. The relative zone file paths
+// are resolved against the directory specified in the options clause or,
+// if not specified, against the working directory of named (`cwd`). The
+// zone clause may look like this:
+//
+//    zone "example.org" {
+//        type master;
+//        file "db.example.org";
+//    };
+func getZoneDirsFromBind9Config(text, cwd string) (zoneDirs []string) {
+	baseDir := getBind9Directory(text, cwd)
+
+	zonePtrn := regexp.MustCompile(`(?m)^\s*zone\s+"[^"]*"[^{;]*\{`)
+	filePtrn := regexp.MustCompile(`(?m)^\s*file\s+"([^"]+)"`)
+	unique := make(map[string]bool)
+	for _, loc := range zonePtrn.FindAllStringIndex(text, -1) {
+		end := findBind9ClauseEnd(text, loc[1])
+		m := filePtrn.FindStringSubmatch(text[loc[1]:end])
+		if m == nil {
+			continue
+		}
+		zoneDir := path.Dir(m[1])
+		if !path.IsAbs(zoneDir) {
+			zoneDir = path.Join(baseDir, zoneDir)
+		}
+		if !unique[zoneDir] {
+			unique[zoneDir] = true
+			zoneDirs = append(zoneDirs, zoneDir)
+		}
+	}
+	return zoneDirs
+}
+
+// getLogTargetsFromBind9Config returns the log targets for the channels
+// specified in the logging clause of the configuration `text`. The relative
+// log file paths are resolved in the same way as the zone file paths. The
+// channels discarding the messages are skipped. The logging clause may look
+// like this:
+//
+//    logging {
+//        channel "default_log" {
+//            file "/var/log/named/default.log" versions 3 size 20m;
+//            print-time yes;
+//            severity info;
+//        };
+//        category "default" { "default_log"; };
+//    };
+func getLogTargetsFromBind9Config(text, cwd string) (targets []LogTarget) {
+	loggingPtrn := regexp.MustCompile(`(?m)^\s*logging\s*\{`)
+	loc := loggingPtrn.FindStringIndex(text)
+	if loc == nil {
+		return targets
+	}
+	logging := text[loc[1]:findBind9ClauseEnd(text, loc[1])]
+	baseDir := getBind9Directory(text, cwd)
+
+	channelPtrn := regexp.MustCompile(`(?m)^\s*channel\s+"?([^"\s{]+)"?\s*\{`)
+	filePtrn := regexp.MustCompile(`(?m)^\s*file\s+"([^"]+)"`)
+	syslogPtrn := regexp.MustCompile(`(?m)^\s*syslog\b`)
+	stderrPtrn := regexp.MustCompile(`(?m)^\s*stderr\s*;`)
+	severityPtrn := regexp.MustCompile(`(?m)^\s*severity\s+([^;]+);`)
+	for _, m := range channelPtrn.FindAllStringSubmatchIndex(logging, -1) {
+		channel := logging[m[1]:findBind9ClauseEnd(logging, m[1])]
+		target := LogTarget{
+			Name:     logging[m[2]:m[3]],
+			Severity: "info",
+		}
+		if file := filePtrn.FindStringSubmatch(channel); file != nil {
+			target.Output = file[1]
+			if !path.IsAbs(target.Output) {
+				target.Output = path.Join(baseDir, target.Output)
+			}
+		} else if syslogPtrn.MatchString(channel) {
+			target.Output = "syslog"
+		} else if stderrPtrn.MatchString(channel) {
+			target.Output = "stderr"
+		} else {
+			continue
+		}
+		if severity := severityPtrn.FindStringSubmatch(channel); severity != nil {
+			target.Severity = strings.TrimSpace(severity[1])
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
 func detectBind9App(match []string, cwd string, cmdr storkutil.Commander) (bind9App *App) {
 	if len(match) < 3 {
 		log.Warnf("problem with parsing BIND 9 cmdline: %s", match[0])
@@ -300,5 +421,7 @@ func detectBind9App(match []string, cwd string, cmdr storkutil.Commander) (bind9
 	return &App{
 		Type:         AppTypeBind9,
 		AccessPoints: accessPoints,
+		DataDirs:     getZoneDirsFromBind9Config(cfgText, cwd),
+		LogTargets:   getLogTargetsFromBind9Config(cfgText, cwd),
 	}
 }
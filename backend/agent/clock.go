@@ -0,0 +1,8 @@
+package agent
+
+// Statuses of the system clock synchronization reported to the server.
+const (
+	ClockSyncStatusSynchronized   = "synchronized"
+	ClockSyncStatusUnsynchronized = "unsynchronized"
+	ClockSyncStatusUnknown        = "unknown"
+)
@@ -0,0 +1,26 @@
+// +build linux
+
+package agent
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// Kernel flag indicating that the clock is not synchronized. It is not
+// defined in the unix package.
+const staUnsync = 0x0040
+
+// Returns the synchronization status of the system clock. It is read
+// from the kernel, so it reflects the state set by any NTP daemon,
+// e.g. ntpd, chronyd or systemd-timesyncd.
+func getClockSyncStatus() string {
+	var timex unix.Timex
+	state, err := unix.Adjtimex(&timex)
+	if err != nil {
+		return ClockSyncStatusUnknown
+	}
+	if state == unix.TIME_ERROR || timex.Status&staUnsync != 0 {
+		return ClockSyncStatusUnsynchronized
+	}
+	return ClockSyncStatusSynchronized
+}
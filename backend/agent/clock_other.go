@@ -0,0 +1,9 @@
+// +build !linux
+
+package agent
+
+// Returns the synchronization status of the system clock. It is not
+// supported on this system.
+func getClockSyncStatus() string {
+	return ClockSyncStatusUnknown
+}
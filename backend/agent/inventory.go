@@ -0,0 +1,268 @@
+package agent
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/disk"
+	psnet "github.com/shirou/gopsutil/net"
+	log "github.com/sirupsen/logrus"
+
+	agentapi "isc.org/stork/api"
+	keaconfig "isc.org/stork/appcfg/kea"
+	keactrl "isc.org/stork/appctrl/kea"
+)
+
+// Kinds of the directories holding the apps' data which usage is reported
+// to the server.
+const (
+	StorageKindKeaLeases  = "kea-leases"
+	StorageKindBind9Zones = "bind9-zones"
+)
+
+// Kinds of the database backends configured in Kea.
+const (
+	DatabaseKindLeases = "lease-database"
+	DatabaseKindHosts  = "hosts-database"
+)
+
+// Directory with the lease files used by the Kea memfile backend when
+// the lease file name is not specified in the configuration.
+const defaultKeaLeasesDir = "/var/lib/kea"
+
+// Timeout for checking whether a database backend is reachable.
+const databaseReachabilityTimeout = 2 * time.Second
+
+// Directory holding the data of an app.
+type storageLocation struct {
+	Path string
+	Kind string
+}
+
+// Database backend configured in Kea.
+type databaseBackend struct {
+	Kind string
+	Type string
+	Host string
+	Port int64
+	Name string
+}
+
+// Host inventory keeps track of the directories holding the Kea lease
+// files and the database backends configured for the Kea daemons. They
+// are extracted from the daemons' configurations and stored per daemon
+// of each Kea app, so an updated configuration replaces the stale entries.
+// The entries are keyed by the URL of the app's Control Agent and the
+// daemon name because multiple Kea apps may run on the same machine.
+type hostInventory struct {
+	keaStorage   map[string][]storageLocation
+	keaDatabases map[string][]databaseBackend
+	mutex        *sync.Mutex
+}
+
+// Creates new instance of the host inventory.
+func newHostInventory() *hostInventory {
+	return &hostInventory{
+		keaStorage:   make(map[string][]storageLocation),
+		keaDatabases: make(map[string][]databaseBackend),
+		mutex:        new(sync.Mutex),
+	}
+}
+
+// Returns the key identifying the Kea daemon in the host inventory.
+func getKeaInventoryKey(caURL, daemonName string) string {
+	return fmt.Sprintf("%s %s", caURL, strings.ToLower(daemonName))
+}
+
+// Replaces the storage locations and the database backends for the
+// given Kea daemon of the app controlled via the given Control Agent URL.
+func (hi *hostInventory) updateKea(caURL, daemonName string, locations []storageLocation, databases []databaseBackend) {
+	hi.mutex.Lock()
+	defer hi.mutex.Unlock()
+	key := getKeaInventoryKey(caURL, daemonName)
+	hi.keaStorage[key] = locations
+	hi.keaDatabases[key] = databases
+}
+
+// Returns the storage locations and the database backends of all Kea
+// daemons. The returned lists are ordered by the Control Agent URL and
+// the daemon name.
+func (hi *hostInventory) getKea() (locations []storageLocation, databases []databaseBackend) {
+	hi.mutex.Lock()
+	defer hi.mutex.Unlock()
+	var keys []string
+	for key := range hi.keaStorage {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		locations = append(locations, hi.keaStorage[key]...)
+		databases = append(databases, hi.keaDatabases[key]...)
+	}
+	return locations, databases
+}
+
+// Converts the database configuration to the database backend. If the
+// host is not specified, localhost is assumed. The Cassandra contact
+// points are used instead of the host when specified.
+func newDatabaseBackend(kind string, database *keaconfig.Database) databaseBackend {
+	backend := databaseBackend{
+		Kind: kind,
+		Type: database.Type,
+		Host: database.Host,
+		Port: database.Port,
+		Name: database.Name,
+	}
+	if len(database.ContactPoints) > 0 {
+		backend.Host = strings.TrimSpace(strings.Split(database.ContactPoints, ",")[0])
+	}
+	if len(backend.Host) == 0 {
+		backend.Host = "localhost"
+	}
+	if backend.Port == 0 {
+		switch backend.Type {
+		case "mysql":
+			backend.Port = 3306
+		case "postgresql":
+			backend.Port = 5432
+		case "cql":
+			backend.Port = 9042
+		}
+	}
+	return backend
+}
+
+// Updates the host inventory with the lease file directory and the
+// database backends found in the configuration returned in response
+// to the config-get command by one of the Kea DHCP servers behind the
+// Control Agent with the given URL. This function is intended to be called by the functions which intercept
+// config-get commands sent periodically by the server to the agents
+// and by the detectKeaAllowedLogs when the agent is started.
+func updateKeaInventory(agent *StorkAgent, caURL string, response *keactrl.Response) {
+	if response.Result > 0 || response.Arguments == nil {
+		return
+	}
+	cfg := keaconfig.New(response.Arguments)
+	if cfg == nil {
+		return
+	}
+	daemonName, ok := cfg.GetRootName()
+	if !ok || (daemonName != "Dhcp4" && daemonName != "Dhcp6") {
+		return
+	}
+
+	var (
+		locations []storageLocation
+		databases []databaseBackend
+	)
+	leaseDatabase := cfg.GetLeaseDatabase()
+	if leaseDatabase == nil || leaseDatabase.Type == "memfile" {
+		leasesDir := defaultKeaLeasesDir
+		if leaseDatabase != nil && len(leaseDatabase.Name) > 0 {
+			leasesDir = path.Dir(leaseDatabase.Name)
+		}
+		locations = append(locations, storageLocation{
+			Path: leasesDir,
+			Kind: StorageKindKeaLeases,
+		})
+	} else {
+		databases = append(databases, newDatabaseBackend(DatabaseKindLeases, leaseDatabase))
+	}
+	hostsDatabases := cfg.GetHostsDatabases()
+	for i := range hostsDatabases {
+		databases = append(databases, newDatabaseBackend(DatabaseKindHosts, &hostsDatabases[i]))
+	}
+	agent.hostInventory.updateKea(caURL, daemonName, locations, databases)
+}
+
+// Returns the network interfaces of the machine with their addresses.
+func getNetworkInterfaces() (interfaces []*agentapi.NetworkInterface) {
+	stats, err := psnet.Interfaces()
+	if err != nil {
+		log.Warnf("cannot get network interfaces: %+v", err)
+		return interfaces
+	}
+	for _, stat := range stats {
+		iface := &agentapi.NetworkInterface{
+			Name:            stat.Name,
+			HardwareAddress: stat.HardwareAddr,
+			Mtu:             int64(stat.MTU),
+			Flags:           stat.Flags,
+		}
+		for _, addr := range stat.Addrs {
+			iface.Addresses = append(iface.Addresses, addr.Addr)
+		}
+		interfaces = append(interfaces, iface)
+	}
+	return interfaces
+}
+
+// Returns the usage of the filesystems holding the given directories.
+// The directories are reported only once, even if they are used by
+// multiple apps.
+func getFilesystemsUsage(locations []storageLocation) (filesystems []*agentapi.FilesystemUsage) {
+	reported := make(map[string]bool)
+	for _, location := range locations {
+		if reported[location.Path] {
+			continue
+		}
+		reported[location.Path] = true
+
+		filesystem := &agentapi.FilesystemUsage{
+			Path: location.Path,
+			Kind: location.Kind,
+		}
+		usage, err := disk.Usage(location.Path)
+		if err != nil {
+			filesystem.Error = err.Error()
+		} else {
+			filesystem.Fstype = usage.Fstype
+			filesystem.Total = int64(usage.Total)
+			filesystem.Used = int64(usage.Used)
+			filesystem.UsedPercent = usage.UsedPercent
+		}
+		filesystems = append(filesystems, filesystem)
+	}
+	return filesystems
+}
+
+// Checks whether the database backends are reachable by opening a TCP
+// connection to them. The checks are run in parallel so as the total
+// time does not exceed the timeout for a single check.
+func getDatabasesReachability(backends []databaseBackend) (databases []*agentapi.DatabaseBackend) {
+	wg := &sync.WaitGroup{}
+	for _, backend := range backends {
+		database := &agentapi.DatabaseBackend{
+			Kind: backend.Kind,
+			Type: backend.Type,
+			Host: backend.Host,
+			Port: backend.Port,
+			Name: backend.Name,
+		}
+		databases = append(databases, database)
+		if backend.Port == 0 {
+			database.Error = "unknown port of the database"
+			continue
+		}
+		wg.Add(1)
+		go func(database *agentapi.DatabaseBackend) {
+			defer wg.Done()
+			address := net.JoinHostPort(database.Host, strconv.FormatInt(database.Port, 10))
+			conn, err := net.DialTimeout("tcp", address, databaseReachabilityTimeout)
+			if err != nil {
+				database.Error = err.Error()
+				return
+			}
+			conn.Close()
+			database.Reachable = true
+		}(database)
+	}
+	wg.Wait()
+	return databases
+}
@@ -0,0 +1,177 @@
+package agent
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	keaconfig "isc.org/stork/appcfg/kea"
+	keactrl "isc.org/stork/appctrl/kea"
+)
+
+// Creates config-get response with the specified arguments.
+func newConfigGetResponse(t *testing.T, argsJSON string) *keactrl.Response {
+	args := make(map[string]interface{})
+	err := json.Unmarshal([]byte(argsJSON), &args)
+	require.NoError(t, err)
+	return &keactrl.Response{
+		ResponseHeader: keactrl.ResponseHeader{
+			Result: 0,
+		},
+		Arguments: &args,
+	}
+}
+
+// Tests that the lease file directory and the database backends are
+// extracted from the DHCP server configuration and that the updated
+// configuration replaces the previous entries.
+func TestUpdateKeaInventory(t *testing.T) {
+	sa, _ := setupAgentTest(nil)
+
+	updateKeaInventory(sa, "http://localhost:8000/", newConfigGetResponse(t, `{
+        "Dhcp4": {
+            "lease-database": {
+                "type": "memfile",
+                "name": "/var/lib/kea/dhcp4.leases"
+            },
+            "hosts-database": {
+                "type": "mysql",
+                "name": "kea",
+                "host": "db.example.org"
+            }
+        }
+    }`))
+	updateKeaInventory(sa, "http://localhost:8000/", newConfigGetResponse(t, `{
+        "Dhcp6": {
+            "lease-database": {
+                "type": "postgresql",
+                "name": "kea",
+                "port": 5433
+            }
+        }
+    }`))
+
+	locations, databases := sa.hostInventory.getKea()
+	require.Len(t, locations, 1)
+	require.Equal(t, "/var/lib/kea", locations[0].Path)
+	require.Equal(t, StorageKindKeaLeases, locations[0].Kind)
+
+	require.Len(t, databases, 2)
+	require.Equal(t, DatabaseKindHosts, databases[0].Kind)
+	require.Equal(t, "mysql", databases[0].Type)
+	require.Equal(t, "db.example.org", databases[0].Host)
+	require.EqualValues(t, 3306, databases[0].Port)
+	require.Equal(t, DatabaseKindLeases, databases[1].Kind)
+	require.Equal(t, "postgresql", databases[1].Type)
+	require.Equal(t, "localhost", databases[1].Host)
+	require.EqualValues(t, 5433, databases[1].Port)
+
+	// The new configuration of the DHCPv4 server uses default lease file
+	// and no host database.
+	updateKeaInventory(sa, "http://localhost:8000/", newConfigGetResponse(t, `{
+        "Dhcp4": { }
+    }`))
+	locations, databases = sa.hostInventory.getKea()
+	require.Len(t, locations, 1)
+	require.Equal(t, defaultKeaLeasesDir, locations[0].Path)
+	require.Len(t, databases, 1)
+	require.Equal(t, "postgresql", databases[0].Type)
+}
+
+// Tests that the entries of the daemons with the same name belonging to
+// different Kea apps don't overwrite each other.
+func TestUpdateKeaInventoryMultipleApps(t *testing.T) {
+	sa, _ := setupAgentTest(nil)
+
+	updateKeaInventory(sa, "http://localhost:8000/", newConfigGetResponse(t, `{
+        "Dhcp4": {
+            "lease-database": {
+                "type": "memfile",
+                "name": "/var/lib/kea1/dhcp4.leases"
+            }
+        }
+    }`))
+	updateKeaInventory(sa, "http://localhost:8001/", newConfigGetResponse(t, `{
+        "Dhcp4": {
+            "lease-database": {
+                "type": "memfile",
+                "name": "/var/lib/kea2/dhcp4.leases"
+            }
+        }
+    }`))
+
+	locations, _ := sa.hostInventory.getKea()
+	require.Len(t, locations, 2)
+	require.Equal(t, "/var/lib/kea1", locations[0].Path)
+	require.Equal(t, "/var/lib/kea2", locations[1].Path)
+}
+
+// Tests that the configurations of the daemons other than DHCP servers
+// are ignored.
+func TestUpdateKeaInventoryNonDHCP(t *testing.T) {
+	sa, _ := setupAgentTest(nil)
+
+	updateKeaInventory(sa, "http://localhost:8000/", newConfigGetResponse(t, `{
+        "Control-agent": { }
+    }`))
+	locations, databases := sa.hostInventory.getKea()
+	require.Empty(t, locations)
+	require.Empty(t, databases)
+}
+
+// Tests that the Cassandra contact points are used as the database host.
+func TestNewDatabaseBackendContactPoints(t *testing.T) {
+	backend := newDatabaseBackend(DatabaseKindLeases, &keaconfig.Database{
+		Type:          "cql",
+		ContactPoints: "192.0.2.1, 192.0.2.2",
+	})
+	require.Equal(t, "192.0.2.1", backend.Host)
+	require.EqualValues(t, 9042, backend.Port)
+}
+
+// Tests that the usage of the filesystems is returned for the given
+// directories and that an error is reported for a non-existing one.
+func TestGetFilesystemsUsage(t *testing.T) {
+	filesystems := getFilesystemsUsage([]storageLocation{
+		{Path: os.TempDir(), Kind: StorageKindKeaLeases},
+		{Path: os.TempDir(), Kind: StorageKindBind9Zones},
+		{Path: "/non/existing/dir", Kind: StorageKindBind9Zones},
+	})
+	require.Len(t, filesystems, 2)
+	require.Equal(t, os.TempDir(), filesystems[0].Path)
+	require.Equal(t, StorageKindKeaLeases, filesystems[0].Kind)
+	require.NotZero(t, filesystems[0].Total)
+	require.Empty(t, filesystems[0].Error)
+	require.Equal(t, "/non/existing/dir", filesystems[1].Path)
+	require.NotEmpty(t, filesystems[1].Error)
+}
+
+// Tests that the reachability of the database backends is checked.
+func TestGetDatabasesReachability(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	port := int64(listener.Addr().(*net.TCPAddr).Port)
+
+	// Get a port number on which nothing listens.
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	databases := getDatabasesReachability([]databaseBackend{
+		{Kind: DatabaseKindLeases, Type: "mysql", Host: "127.0.0.1", Port: port},
+		{Kind: DatabaseKindHosts, Type: "postgresql", Host: "127.0.0.1", Port: int64(closedPort)},
+		{Kind: DatabaseKindHosts, Type: "unknown", Host: "127.0.0.1"},
+	})
+	require.Len(t, databases, 3)
+	require.True(t, databases[0].Reachable)
+	require.Empty(t, databases[0].Error)
+	require.False(t, databases[1].Reachable)
+	require.NotEmpty(t, databases[1].Error)
+	require.False(t, databases[2].Reachable)
+	require.NotEmpty(t, databases[2].Error)
+}
@@ -88,7 +88,8 @@ func updateKeaAllowedLogs(agent *StorkAgent, response *keactrl.Response) {
 // to fetch its logging configuration and to find the daemons running behind it. Next, the
 // config-get command is sent to the daemons behind CA and their logging configuration
 // is fetched. The log files locations are stored in the logTailer instance of the
-// agent as allowed for viewing. This function should be called when the agent has
+// agent as allowed for viewing. The lease file directories and the database backends
+// are stored in the host inventory of the agent. This function should be called when the agent has
 // been started and the running Kea apps have been detected.
 func detectKeaAllowedLogs(storkAgent *StorkAgent, caAddress string, caPort int64) error {
 	// Prepare config-get command to be sent to Kea Control Agent.
@@ -164,9 +165,11 @@ func detectKeaAllowedLogs(storkAgent *StorkAgent, caAddress string, caPort int64
 	}
 
 	// For each daemon try to extract its logging configuration and allow view
-	// the log files it contains.
+	// the log files it contains. Also, record its lease file directory and
+	// database backends.
 	for i := range responses {
 		updateKeaAllowedLogs(storkAgent, &responses[i])
+		updateKeaInventory(storkAgent, storkutil.HostWithPortURL(caAddress, caPort), &responses[i])
 	}
 
 	return nil
@@ -12,9 +12,10 @@ import (
 // Structure containing a pointer to the callback function registered in
 // in the Kea interceptor and associated with one of the Kea commands.
 // The callback is invoked when the given command is received by the
-// agent and after it is forwarded to Kea.
+// agent and after it is forwarded to Kea. The callback receives the URL
+// of the Kea Control Agent the command was forwarded to.
 type keaInterceptorHandler struct {
-	callback func(*StorkAgent, *keactrl.Response) error
+	callback func(*StorkAgent, string, *keactrl.Response) error
 }
 
 // Structure holding a collection of handlers/callabacks to be invoked
@@ -44,7 +45,7 @@ func newKeaInterceptor() *keaInterceptor {
 
 // Registers a callback function and associates it with a given command.
 // It is possible to register multiple callbacks for the same command.
-func (i *keaInterceptor) register(callback func(*StorkAgent, *keactrl.Response) error, commandName string) {
+func (i *keaInterceptor) register(callback func(*StorkAgent, string, *keactrl.Response) error, commandName string) {
 	var (
 		target *keaInterceptorTarget
 		ok     bool
@@ -76,7 +77,7 @@ func (i *keaInterceptor) register(callback func(*StorkAgent, *keactrl.Response)
 // which can be run independently from the agent. The agent may send back the
 // response to the server while these callbacks are invoked. The result of the
 // callbacks do not affect the response forwarded to the Stork server.
-func (i *keaInterceptor) asyncHandle(agent *StorkAgent, request *agentapi.KeaRequest, response []byte) {
+func (i *keaInterceptor) asyncHandle(agent *StorkAgent, caURL string, request *agentapi.KeaRequest, response []byte) {
 	// Parse the request to get the command name and service.
 	command, err := keactrl.NewCommandFromJSON(request.Request)
 	if err != nil {
@@ -127,7 +128,7 @@ func (i *keaInterceptor) asyncHandle(agent *StorkAgent, request *agentapi.KeaReq
 			if j < len(parsedResponse) {
 				callback := target.handlers[i].callback
 				if callback != nil {
-					err = callback(agent, &parsedResponse[j])
+					err = callback(agent, caURL, &parsedResponse[j])
 					if err != nil {
 						log.Warnf("asynchronous callback returned an error for command %s: %+v",
 							command.Command, err)
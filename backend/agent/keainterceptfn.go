@@ -7,13 +7,22 @@ import (
 // Intercept callback function for config-get. It records log files
 // found in the daemon's configuration  making them accessible by the
 // log viewer.
-func icptConfigGetLoggers(agent *StorkAgent, response *keactrl.Response) error {
+func icptConfigGetLoggers(agent *StorkAgent, caURL string, response *keactrl.Response) error {
 	updateKeaAllowedLogs(agent, response)
 	return nil
 }
 
+// Intercept callback function for config-get. It records the lease file
+// directory and the database backends found in the DHCP server's
+// configuration making them reported in the machine state.
+func icptConfigGetInventory(agent *StorkAgent, caURL string, response *keactrl.Response) error {
+	updateKeaInventory(agent, caURL, response)
+	return nil
+}
+
 // Registers all intercept functions defined in this file. It should
 // be extended every time a new intercept function is defined.
 func registerKeaInterceptFns(agent *StorkAgent) {
 	agent.keaInterceptor.register(icptConfigGetLoggers, "config-get")
+	agent.keaInterceptor.register(icptConfigGetInventory, "config-get")
 }
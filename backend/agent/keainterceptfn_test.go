@@ -66,7 +66,7 @@ func TestIcptConfigGetLoggers(t *testing.T) {
 		},
 		Arguments: &responseArgs,
 	}
-	err = icptConfigGetLoggers(sa, response)
+	err = icptConfigGetLoggers(sa, "http://localhost:8000/", response)
 	require.NoError(t, err)
 	require.NotNil(t, sa.logTailer)
 	require.True(t, sa.logTailer.allowed("/tmp/kea-dhcp4.log"))
@@ -75,3 +75,32 @@ func TestIcptConfigGetLoggers(t *testing.T) {
 	require.False(t, sa.logTailer.allowed("stderr"))
 	require.False(t, sa.logTailer.allowed("syslog:1"))
 }
+
+// Tests that config-get is intercepted and the lease file directory and
+// the database backends found in the returned configuration are recorded
+// in the host inventory.
+func TestIcptConfigGetInventory(t *testing.T) {
+	sa, _ := setupAgentTest(nil)
+
+	responseArgs := map[string]interface{}{
+		"Dhcp4": map[string]interface{}{
+			"lease-database": map[string]interface{}{
+				"type": "memfile",
+				"name": "/tmp/kea-leases4.csv",
+			},
+		},
+	}
+	response := &keactrl.Response{
+		ResponseHeader: keactrl.ResponseHeader{
+			Result: 0,
+			Daemon: "dhcp4",
+		},
+		Arguments: &responseArgs,
+	}
+	err := icptConfigGetInventory(sa, "http://localhost:8000/", response)
+	require.NoError(t, err)
+	locations, databases := sa.hostInventory.getKea()
+	require.Len(t, locations, 1)
+	require.Equal(t, "/tmp", locations[0].Path)
+	require.Empty(t, databases)
+}
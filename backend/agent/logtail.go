@@ -1,17 +1,71 @@
 package agent
 
 import (
-	"bufio"
+	"compress/gzip"
+	"encoding/binary"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
+// Maximum number of bytes scanned backward in a single request when the
+// lines are filtered. It limits the time spent on a single request when
+// the filter rarely matches. The remaining part of the log can be fetched
+// in the subsequent requests. The client may request a lower limit.
+const logFilterScanLength = 4 * 1024 * 1024
+
+// Matches the suffixes of the rotated log files, e.g. .1, .1.gz,
+// -20201012 or -20201012.gz. It doesn't match the lock files created
+// by Kea next to the log files.
+var rotatedLogSuffixPattern = regexp.MustCompile(`^[.-][0-9][0-9-]*(\.gz)?$`)
+
+// Log file or one of its rotated siblings.
+type logFile struct {
+	ID         string // identity of the file preserved across renames
+	Path       string
+	Size       int64 // size of the uncompressed contents
+	ModTime    time.Time
+	Compressed bool
+}
+
+// Position in the log history anchored to one of the log files. Unlike
+// a position counted from the end of the log, it remains valid when new
+// lines are appended to the current log file or when the files are
+// renamed during the log rotation. The zero value denotes the end of the
+// current log file.
+type logAnchor struct {
+	// Identity of the log file.
+	FileID string
+	// Offset in the uncompressed contents of the log file.
+	Offset int64
+}
+
+// Tail of the log returned by the log tailer.
+type logTail struct {
+	// Lines of the tail.
+	Lines []string
+	// Position at which the next request should end to fetch the
+	// preceding part of the log.
+	Next logAnchor
+	// Indicates that the beginning of the oldest rotated file has
+	// been reached.
+	Beginning bool
+	// Log file and its rotated siblings from the newest to the oldest.
+	Files []logFile
+}
+
 // Log tailer provides means for viewing log files. It maintains the list of
 // unique files which can be viewed. If the file is not on the list of the allowed
-// files, an error is returned upon an attempt to view it.
+// files, an error is returned upon an attempt to view it. The rotated siblings
+// of the allowed files can be viewed too.
 type logTailer struct {
 	allowedPaths map[string]bool
 	mutex        *sync.Mutex
@@ -41,49 +95,302 @@ func (lt *logTailer) allowed(path string) bool {
 	return ok
 }
 
+// Returns the size of the uncompressed contents of the gzip file. It is
+// stored in the last 4 bytes of the file modulo 2^32 which is sufficient
+// for the log files.
+func getGzipContentsSize(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	_, err = f.Seek(-4, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	var size uint32
+	err = binary.Read(f, binary.LittleEndian, &size)
+	if err != nil {
+		return 0, err
+	}
+	return int64(size), nil
+}
+
+// Returns the log file and its rotated siblings found in the same directory.
+// The files are ordered from the newest to the oldest. The current log file
+// is always first.
+func getRotatedLogFiles(path string) (files []logFile, err error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "Failed to stat the file opened for tailing: %s", path)
+	}
+	files = append(files, logFile{
+		ID:      getLogFileID(path, stat),
+		Path:    path,
+		Size:    stat.Size(),
+		ModTime: stat.ModTime(),
+	})
+
+	// Errors in reading the rotated files are not fatal. The current
+	// log file can still be viewed.
+	base := filepath.Base(path)
+	entries, err := ioutil.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return files, nil
+	}
+	var rotated []logFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, base) ||
+			!rotatedLogSuffixPattern.MatchString(strings.TrimPrefix(name, base)) {
+			continue
+		}
+		file := logFile{
+			ID:         getLogFileID(filepath.Join(filepath.Dir(path), name), entry),
+			Path:       filepath.Join(filepath.Dir(path), name),
+			Size:       entry.Size(),
+			ModTime:    entry.ModTime(),
+			Compressed: strings.HasSuffix(name, ".gz"),
+		}
+		if file.Compressed {
+			file.Size, err = getGzipContentsSize(file.Path)
+			if err != nil {
+				continue
+			}
+		}
+		rotated = append(rotated, file)
+	}
+	sort.Slice(rotated, func(i, j int) bool {
+		if rotated[i].ModTime.Equal(rotated[j].ModTime) {
+			return rotated[i].Path < rotated[j].Path
+		}
+		return rotated[i].ModTime.After(rotated[j].ModTime)
+	})
+	files = append(files, rotated...)
+	return files, nil
+}
+
+// Reads the part of the log file between the start and the end offset.
+// The compressed file is decompressed on the fly.
+func readLogFileRange(file logFile, start, end int64) ([]byte, error) {
+	f, err := os.Open(file.Path)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "Failed to open file for tailing: %s", file.Path)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var r io.Reader = f
+	if file.Compressed {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "Failed to decompress the file opened for tailing: %s", file.Path)
+		}
+		defer gz.Close()
+		_, err = io.CopyN(ioutil.Discard, gz, start)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "Failed to seek in the file opened for tailing: %s", file.Path)
+		}
+		r = gz
+	} else {
+		_, err = f.Seek(start, io.SeekStart)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "Failed to seek in the file opened for tailing: %s", file.Path)
+		}
+	}
+
+	data := make([]byte, end-start)
+	n, err := io.ReadFull(r, data)
+	// The file may be truncated in the meantime.
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, errors.WithMessagef(err, "Failed to read the tailed file: %s", file.Path)
+	}
+	return data[:n], nil
+}
+
+// Reads the part of the log history, i.e. the log file and its rotated
+// siblings, as if they were concatenated. The skip specifies the number
+// of bytes from the end of the history to skip and the length specifies
+// the maximum number of bytes to return. It returns true if the beginning
+// of the history has been reached.
+func readLogHistory(files []logFile, skip, length int64) (data []byte, beginning bool, err error) {
+	// Position of the end of the current file counted from the end of
+	// the history.
+	pos := int64(0)
+	for _, file := range files {
+		lo := skip
+		if lo < pos {
+			lo = pos
+		}
+		hi := skip + length
+		if hi > pos+file.Size {
+			hi = pos + file.Size
+		}
+		if lo < hi {
+			chunk, err := readLogFileRange(file, file.Size-(hi-pos), file.Size-(lo-pos))
+			if err != nil {
+				return nil, false, err
+			}
+			data = append(chunk, data...)
+		}
+		pos += file.Size
+	}
+	return data, skip+length >= pos, nil
+}
+
+// Converts the anchor to the number of bytes counted from the end of the
+// log history. It returns an error if the anchored file no longer exists,
+// e.g. it has been compressed, or it has been truncated.
+func getLogAnchorSkip(files []logFile, anchor logAnchor) (int64, error) {
+	if len(anchor.FileID) == 0 {
+		return 0, nil
+	}
+	pos := int64(0)
+	for _, file := range files {
+		if file.ID == anchor.FileID {
+			if anchor.Offset < 0 || anchor.Offset > file.Size {
+				return 0, errors.Errorf("Log file %s has been truncated since the previous request", file.Path)
+			}
+			return pos + file.Size - anchor.Offset, nil
+		}
+		pos += file.Size
+	}
+	return 0, errors.New("Log file has been removed or compressed since the previous request")
+}
+
+// Converts the number of bytes counted from the end of the log history to
+// the anchor. The position at the boundary between two files is anchored
+// to the end of the older file. The beginning of the history is anchored
+// to the beginning of the oldest file.
+func getLogSkipAnchor(files []logFile, skip int64) logAnchor {
+	pos := int64(0)
+	for _, file := range files {
+		if skip < pos+file.Size {
+			return logAnchor{
+				FileID: file.ID,
+				Offset: file.Size - (skip - pos),
+			}
+		}
+		pos += file.Size
+	}
+	return logAnchor{
+		FileID: files[len(files)-1].ID,
+	}
+}
+
 // Returns the tail of the specified log file. The path specifies the absolute
-// location of the log file. The offset specifies the location relative to an
-// end of the file from which the tail should be returned. The offset must be
-// a positive value. If the file is not allowed, it does not exist an error
-// is returned. An error is also returned if an attempt to read the file fails.
-func (lt *logTailer) tail(path string, offset int64) (lines []string, err error) {
+// location of the log file. The offset specifies the maximum length of the
+// returned tail and must be a positive value. The anchor specifies where the
+// returned tail ends. The rotated siblings of the log file are read
+// transparently, so the client may page backward by setting the anchor to
+// the Next value returned in the previous tail. If the filter is specified,
+// only the lines matching this regular expression are returned. The scan
+// length limits the number of bytes scanned for the matching lines. If it
+// is 0 or greater than the default limit, the default limit is used. If the
+// file is not allowed, it does not exist or the filter is invalid an error
+// is returned. An error is also returned if an attempt to read the file
+// fails or the anchored file is gone.
+func (lt *logTailer) tail(path string, offset int64, anchor logAnchor, filter string, scanLength int64) (tail *logTail, err error) {
 	// Check if it is allowed to tail this file.
 	if !lt.allowed(path) {
 		err = errors.Errorf("Access forbidden to the %s", path)
-		return lines, err
+		return nil, err
 	}
 
-	f, err := os.Open(path)
+	var filterRegexp *regexp.Regexp
+	if len(filter) > 0 {
+		filterRegexp, err = regexp.Compile(filter)
+		if err != nil {
+			err = errors.WithMessagef(err, "Invalid filter %s", filter)
+			return nil, err
+		}
+	}
+
+	files, err := getRotatedLogFiles(path)
 	if err != nil {
-		err = errors.WithMessagef(err, "Failed to open file for tailing: %s", path)
-		return lines, err
+		return nil, err
+	}
+	skip, err := getLogAnchorSkip(files, anchor)
+	if err != nil {
+		return nil, err
 	}
-	defer func() {
-		_ = f.Close()
-	}()
 
-	stat, err := f.Stat()
+	// Scan the larger part of the log when the lines are filtered.
+	length := offset
+	if filterRegexp != nil {
+		if scanLength <= 0 || scanLength > logFilterScanLength {
+			scanLength = logFilterScanLength
+		}
+		if length < scanLength {
+			length = scanLength
+		}
+	}
+	// One byte preceding the requested part is read to check whether
+	// the part begins with a complete line.
+	data, beginning, err := readLogHistory(files, skip, length+1)
 	if err != nil {
-		err = errors.WithMessagef(err, "Failed to stat the file opened for tailing: %s", path)
-		return lines, err
+		return nil, err
 	}
 
-	// Can't go beyond the file size.
-	if offset > stat.Size() {
-		offset = stat.Size()
+	// The partial first line is dropped. It is returned in the next chunk.
+	if int64(len(data)) > length {
+		if i := strings.IndexByte(string(data), '\n'); i >= 0 {
+			data = data[i+1:]
+		} else {
+			data = data[1:]
+		}
+		beginning = false
 	}
 
-	_, err = f.Seek(-offset, io.SeekEnd)
-	if err != nil {
-		err = errors.WithMessagef(err, "Failed to seek in the file opened for tailing: %s", path)
-		return lines, err
+	// Split the data into lines and remember where they start.
+	var (
+		lines  []string
+		starts []int
+	)
+	text := string(data)
+	for start := 0; start < len(text); {
+		starts = append(starts, start)
+		i := strings.IndexByte(text[start:], '\n')
+		if i < 0 {
+			lines = append(lines, text[start:])
+			break
+		}
+		lines = append(lines, text[start:start+i])
+		start += i + 1
 	}
-	s := bufio.NewScanner(f)
-	for s.Scan() {
-		lines = append(lines, s.Text())
+
+	tail = &logTail{
+		Lines:     lines,
+		Next:      getLogSkipAnchor(files, skip+int64(len(data))),
+		Beginning: beginning,
+		Files:     files,
+	}
+	if filterRegexp == nil {
+		return tail, nil
+	}
+
+	// Walk backward and collect the matching lines until the requested
+	// length is reached.
+	var (
+		matched []string
+		size    int64
+	)
+	examinedFrom := len(data)
+	for i := len(lines) - 1; i >= 0 && size < offset; i-- {
+		examinedFrom = starts[i]
+		if filterRegexp.MatchString(lines[i]) {
+			matched = append(matched, lines[i])
+			size += int64(len(lines[i]) + 1)
+		}
 	}
-	if err = s.Err(); err != nil {
-		err = errors.WithMessagef(err, "Failed to read the tailed file: %s", path)
+	for i, j := 0, len(matched)-1; i < j; i, j = i+1, j-1 {
+		matched[i], matched[j] = matched[j], matched[i]
 	}
-	return lines, err
+	tail.Lines = matched
+	tail.Next = getLogSkipAnchor(files, skip+int64(len(data)-examinedFrom))
+	tail.Beginning = beginning && examinedFrom == 0
+	return tail, nil
 }
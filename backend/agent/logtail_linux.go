@@ -0,0 +1,20 @@
+// +build linux
+
+package agent
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// Returns the identity of the log file. It is built from the device and
+// the inode number, so it doesn't change when the file is renamed during
+// the log rotation.
+func getLogFileID(path string, info os.FileInfo) string {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return path
+	}
+	return fmt.Sprintf("%d:%d", stat.Dev, stat.Ino)
+}
@@ -0,0 +1,13 @@
+// +build !linux
+
+package agent
+
+import (
+	"os"
+)
+
+// Returns the identity of the log file. The inode numbers are not used
+// on this system, so the path identifies the file.
+func getLogFileID(path string, info os.FileInfo) string {
+	return path
+}
@@ -1,10 +1,15 @@
 package agent
 
 import (
+	"compress/gzip"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -46,12 +51,12 @@ func TestTailForbidden(t *testing.T) {
 	// Tailing this file is initially not allowed, so an error should be returned.
 	lt := newLogTailer()
 	require.NotNil(t, lt)
-	_, err = lt.tail(filename, 100)
+	_, err = lt.tail(filename, 100, logAnchor{}, "", 0)
 	require.Error(t, err)
 
 	// Allow tailing the file. This time there should be no error
 	lt.allow(filename)
-	_, err = lt.tail(filename, 100)
+	_, err = lt.tail(filename, 100, logAnchor{}, "", 0)
 	require.NoError(t, err)
 }
 
@@ -59,6 +64,146 @@ func TestTailForbidden(t *testing.T) {
 func TestTailNotExistingFile(t *testing.T) {
 	lt := newLogTailer()
 	require.NotNil(t, lt)
-	_, err := lt.tail("non-existing-file", 100)
+	_, err := lt.tail("non-existing-file", 100, logAnchor{}, "", 0)
+	require.Error(t, err)
+}
+
+// Creates the log file with its rotated siblings in the temporary directory.
+// The oldest log is compressed. Each file holds three lines.
+func createRotatedLogFiles(t *testing.T) (dir string, path string) {
+	dir, err := ioutil.TempDir("", "logtail")
+	require.NoError(t, err)
+	path = filepath.Join(dir, "kea-dhcp4.log")
+
+	now := time.Now()
+	for i, suffix := range []string{"", ".1", ".2.gz"} {
+		var contents string
+		for j := 1; j <= 3; j++ {
+			contents += fmt.Sprintf("file %d line %d\n", i, j)
+		}
+		name := path + suffix
+		if strings.HasSuffix(suffix, ".gz") {
+			f, err := os.Create(name)
+			require.NoError(t, err)
+			w := gzip.NewWriter(f)
+			_, err = w.Write([]byte(contents))
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+			require.NoError(t, f.Close())
+		} else {
+			require.NoError(t, ioutil.WriteFile(name, []byte(contents), 0600))
+		}
+		modTime := now.Add(-time.Duration(i) * time.Hour)
+		require.NoError(t, os.Chtimes(name, modTime, modTime))
+	}
+	// The lock file should be ignored.
+	require.NoError(t, ioutil.WriteFile(path+".lock", []byte("lock"), 0600))
+	return dir, path
+}
+
+// Test that the rotated siblings of the log file are found and ordered
+// from the newest to the oldest.
+func TestGetRotatedLogFiles(t *testing.T) {
+	dir, path := createRotatedLogFiles(t)
+	defer os.RemoveAll(dir)
+
+	files, err := getRotatedLogFiles(path)
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+	require.Equal(t, path, files[0].Path)
+	require.False(t, files[0].Compressed)
+	require.Equal(t, path+".1", files[1].Path)
+	require.Equal(t, path+".2.gz", files[2].Path)
+	require.True(t, files[2].Compressed)
+	// The size of the compressed file is the size of its contents.
+	for _, file := range files {
+		require.EqualValues(t, 42, file.Size)
+	}
+}
+
+// Test that it is possible to page backward through the log and its
+// rotated siblings, including the compressed ones.
+func TestTailRotated(t *testing.T) {
+	dir, path := createRotatedLogFiles(t)
+	defer os.RemoveAll(dir)
+
+	lt := newLogTailer()
+	lt.allow(path)
+
+	// The first chunk begins in the middle of the line which is
+	// skipped.
+	tail, err := lt.tail(path, 40, logAnchor{}, "", 0)
+	require.NoError(t, err)
+	require.Equal(t, []string{"file 0 line 2", "file 0 line 3"}, tail.Lines)
+	require.Equal(t, logAnchor{FileID: tail.Files[0].ID, Offset: 14}, tail.Next)
+	require.False(t, tail.Beginning)
+	require.Len(t, tail.Files, 3)
+
+	// The lines appended to the current log file and the rotation of
+	// the files don't affect paging backward.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	require.NoError(t, err)
+	_, err = f.WriteString("file 0 line 4\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.NoError(t, os.Rename(path+".1", path+".3"))
+
+	// The next chunk spans two files.
+	tail, err = lt.tail(path, 40, tail.Next, "", 0)
+	require.NoError(t, err)
+	require.Equal(t, []string{"file 1 line 3", "file 0 line 1"}, tail.Lines)
+	require.Equal(t, logAnchor{FileID: tail.Files[1].ID, Offset: 28}, tail.Next)
+	require.False(t, tail.Beginning)
+
+	// Read the rest of the log including the compressed file.
+	tail, err = lt.tail(path, 200, tail.Next, "", 0)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"file 2 line 1", "file 2 line 2", "file 2 line 3",
+		"file 1 line 1", "file 1 line 2",
+	}, tail.Lines)
+	require.Equal(t, logAnchor{FileID: tail.Files[2].ID}, tail.Next)
+	require.True(t, tail.Beginning)
+
+	// The anchored file is gone.
+	_, err = lt.tail(path, 40, logAnchor{FileID: "foo", Offset: 10}, "", 0)
+	require.Error(t, err)
+
+	// The anchored file has been truncated.
+	_, err = lt.tail(path, 40, logAnchor{FileID: tail.Files[0].ID, Offset: 100}, "", 0)
+	require.Error(t, err)
+}
+
+// Test that the lines are filtered using the regular expression.
+func TestTailFiltered(t *testing.T) {
+	dir, path := createRotatedLogFiles(t)
+	defer os.RemoveAll(dir)
+
+	lt := newLogTailer()
+	lt.allow(path)
+
+	// The matching lines are collected until the requested length is
+	// reached.
+	tail, err := lt.tail(path, 20, logAnchor{}, "line [13]", 0)
+	require.NoError(t, err)
+	require.Equal(t, []string{"file 0 line 1", "file 0 line 3"}, tail.Lines)
+	require.Equal(t, logAnchor{FileID: tail.Files[1].ID, Offset: 42}, tail.Next)
+	require.False(t, tail.Beginning)
+
+	tail, err = lt.tail(path, 1000, tail.Next, "line [13]", 0)
+	require.NoError(t, err)
+	require.Equal(t, []string{"file 2 line 1", "file 2 line 3", "file 1 line 1", "file 1 line 3"}, tail.Lines)
+	require.Equal(t, logAnchor{FileID: tail.Files[2].ID}, tail.Next)
+	require.True(t, tail.Beginning)
+
+	// The scan is limited to the specified length.
+	tail, err = lt.tail(path, 20, logAnchor{}, "line [13]", 28)
+	require.NoError(t, err)
+	require.Equal(t, []string{"file 0 line 3"}, tail.Lines)
+	require.Equal(t, logAnchor{FileID: tail.Files[0].ID, Offset: 14}, tail.Next)
+	require.False(t, tail.Beginning)
+
+	// Invalid filter.
+	_, err = lt.tail(path, 1000, logAnchor{}, "line [", 0)
 	require.Error(t, err)
 }
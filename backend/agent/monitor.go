@@ -2,6 +2,7 @@ package agent
 
 import (
 	"fmt"
+	"path"
 	"regexp"
 	"strings"
 	"sync"
@@ -29,10 +30,20 @@ const (
 	AccessPointStatistics = "statistics"
 )
 
+// Log target configured for an app. The output is a path to the log
+// file, "syslog" or "stderr".
+type LogTarget struct {
+	Name     string
+	Severity string
+	Output   string
+}
+
 type App struct {
 	Pid          int32
 	Type         string
 	AccessPoints []AccessPoint
+	DataDirs     []string    // directories holding the app's data, e.g. zone files
+	LogTargets   []LogTarget // log targets found in the app's configuration, currently BIND 9 only
 }
 
 // Currently supported types are: "kea" and "bind9".
@@ -236,7 +247,16 @@ func (sm *appMonitor) detectAllowedLogs(storkAgent *StorkAgent) {
 		return
 	}
 	for _, app := range sm.apps {
-		// Only Kea apps are currently supported.
+		// The BIND 9 log files are found in its configuration when the
+		// app is detected.
+		if app.Type == AppTypeBind9 {
+			for _, target := range app.LogTargets {
+				if path.IsAbs(target.Output) {
+					storkAgent.logTailer.allow(target.Output)
+				}
+			}
+			continue
+		}
 		if app.Type == AppTypeKea {
 			for _, ac := range app.AccessPoints {
 				if ac.Type == AccessPointControl {
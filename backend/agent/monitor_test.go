@@ -180,6 +180,102 @@ func TestDetectBind9App(t *testing.T) {
 	require.Equal(t, app.Type, AppTypeBind9)
 }
 
+// Tests that the directories holding zone files are found in the BIND 9
+// configuration and the relative paths are resolved.
+func TestGetZoneDirsFromBind9Config(t *testing.T) {
+	config := `options {
+	directory "/var/cache/bind";
+	key-directory "/etc/bind/keys";
+};
+logging {
+	channel default_log {
+		file "/var/log/named.log";
+	};
+};
+zone "example.org" {
+	type master;
+	file "/etc/bind/db.example.org";
+};
+zone "example.com" IN {
+	type master;
+	file "db.example.com";
+	allow-update { key "ddns" { }; };
+};
+view "internal" {
+	zone "example.net" {
+		type slave;
+		file "slaves/db.example.net";
+	};
+};
+zone "." {
+	type hint;
+	file "/etc/bind/db.root";
+};
+`
+	dirs := getZoneDirsFromBind9Config(config, "/")
+	require.Equal(t, []string{"/etc/bind", "/var/cache/bind", "/var/cache/bind/slaves"}, dirs)
+
+	// Without the directory option the paths are relative to the
+	// working directory of named.
+	config = `zone "example.com" {
+	type master;
+	file "db.example.com";
+};
+`
+	dirs = getZoneDirsFromBind9Config(config, "/fake")
+	require.Equal(t, []string{"/fake"}, dirs)
+}
+
+// Tests that the log targets are found in the logging clause of the BIND 9
+// configuration and the relative paths are resolved.
+func TestGetLogTargetsFromBind9Config(t *testing.T) {
+	config := `options {
+	directory "/var/cache/bind";
+};
+logging {
+	channel "default_log" {
+		file "/var/log/named/default.log" versions 3 size 20971520;
+		print-time yes;
+		severity info;
+	};
+	channel "queries" {
+		file "queries.log";
+		severity debug 3;
+	};
+	channel "system" {
+		syslog daemon;
+		severity warning;
+	};
+	channel "console" {
+		stderr;
+	};
+	channel "discard" {
+		null;
+	};
+	category "default" {
+		"default_log";
+		"system";
+	};
+	category "queries" {
+		"queries";
+	};
+};
+zone "example.org" {
+	type master;
+	file "db.example.org";
+};
+`
+	targets := getLogTargetsFromBind9Config(config, "/")
+	require.Len(t, targets, 4)
+	require.Equal(t, LogTarget{Name: "default_log", Severity: "info", Output: "/var/log/named/default.log"}, targets[0])
+	require.Equal(t, LogTarget{Name: "queries", Severity: "debug 3", Output: "/var/cache/bind/queries.log"}, targets[1])
+	require.Equal(t, LogTarget{Name: "system", Severity: "warning", Output: "syslog"}, targets[2])
+	require.Equal(t, LogTarget{Name: "console", Severity: "info", Output: "stderr"}, targets[3])
+
+	// No logging clause.
+	require.Empty(t, getLogTargetsFromBind9Config(`zone "example.org" { file "db.example.org"; };`, "/"))
+}
+
 func makeKeaConfFile() (file *os.File, removeFunc func(string) error) {
 	// prepare kea conf file
 	file, err := ioutil.TempFile(os.TempDir(), "prefix-")
@@ -0,0 +1,448 @@
+package agent
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Formats in which the metrics can be pushed to a remote sink.
+const (
+	PushFormatRemoteWrite = "remote-write"
+	PushFormatInfluxDB    = "influxdb"
+	PushFormatStatsD      = "statsd"
+)
+
+// A label of the pushed sample.
+type promLabel struct {
+	Name  string
+	Value string
+}
+
+// A single sample (value of the metric with a given set of labels)
+// gathered from the Prometheus registries. Summaries and histograms
+// are flattened into multiple samples using the same naming convention
+// as the Prometheus text exposition format, i.e. _sum, _count and
+// _bucket suffixes.
+type promSample struct {
+	Name   string
+	Labels []promLabel
+	Value  float64
+}
+
+// Main structure for the Prometheus pusher. It periodically gathers
+// the metrics from the registries used by the exporters and pushes
+// them to the remote sink configured by the user. It is useful when
+// the agent cannot be scraped by Prometheus, e.g. because of the
+// firewall settings. If the sink is not available the encoded metrics
+// are buffered on disk and sent later. The buffered metrics older than
+// the configured age are discarded.
+type PromPusher struct {
+	Settings *cli.Context
+	Gatherer prometheus.Gatherer
+
+	URL          string
+	Format       string
+	BufferDir    string
+	BufferMaxAge time.Duration
+
+	HTTPClient *http.Client
+
+	Ticker        *time.Ticker
+	DoneCollector chan bool
+	Wg            *sync.WaitGroup
+}
+
+// Create new Prometheus pusher. The gatherers are typically the registries
+// of the Kea and BIND 9 exporters.
+func NewPromPusher(settings *cli.Context, gatherers ...prometheus.Gatherer) *PromPusher {
+	pp := &PromPusher{
+		Settings:      settings,
+		Gatherer:      prometheus.Gatherers(gatherers),
+		URL:           settings.String("prometheus-push-url"),
+		Format:        settings.String("prometheus-push-format"),
+		BufferDir:     settings.String("prometheus-push-buffer-dir"),
+		BufferMaxAge:  time.Duration(settings.Int("prometheus-push-buffer-max-age")) * time.Second,
+		HTTPClient:    &http.Client{Timeout: 10 * time.Second},
+		DoneCollector: make(chan bool),
+		Wg:            &sync.WaitGroup{},
+	}
+	if pp.Format == "" {
+		pp.Format = PushFormatRemoteWrite
+	}
+	return pp
+}
+
+// Start goroutine with main loop pushing the metrics to the sink.
+func (pp *PromPusher) Start() error {
+	switch pp.Format {
+	case PushFormatRemoteWrite, PushFormatInfluxDB, PushFormatStatsD:
+	default:
+		return errors.Errorf("unsupported format of the pushed metrics: %s", pp.Format)
+	}
+
+	if _, err := url.Parse(pp.URL); err != nil {
+		return errors.Wrapf(err, "invalid URL of the metrics sink: %s", pp.URL)
+	}
+
+	if pp.BufferDir != "" {
+		if err := os.MkdirAll(pp.BufferDir, 0o700); err != nil {
+			return errors.Wrapf(err, "cannot create directory for buffering pushed metrics: %s", pp.BufferDir)
+		}
+	}
+
+	interval := pp.Settings.Int("prometheus-push-interval")
+	log.Printf("Prometheus Pusher sending %s metrics to %s, interval: %d seconds", pp.Format, pp.URL, interval)
+
+	pp.Ticker = time.NewTicker(time.Duration(interval) * time.Second)
+
+	pp.Wg.Add(1)
+	go pp.pushLoop()
+	return nil
+}
+
+// Shutdown pusher goroutine.
+func (pp *PromPusher) Shutdown() {
+	log.Printf("Stopping Prometheus Pusher")
+	if pp.Ticker != nil {
+		pp.Ticker.Stop()
+		pp.DoneCollector <- true
+		pp.Wg.Wait()
+	}
+	log.Printf("Stopped Prometheus Pusher")
+}
+
+// Main loop for pushing the metrics periodically.
+func (pp *PromPusher) pushLoop() {
+	defer pp.Wg.Done()
+	for {
+		select {
+		case <-pp.Ticker.C:
+			if err := pp.push(time.Now()); err != nil {
+				log.Errorf("problem with pushing metrics to %s: %+v", pp.URL, err)
+			}
+		// wait for done signal from shutdown function
+		case <-pp.DoneCollector:
+			return
+		}
+	}
+}
+
+// Gathers the metrics, encodes them in the configured format and sends them
+// to the sink. Earlier buffered payloads are sent first. If any of them
+// can't be sent the current payload is buffered too.
+func (pp *PromPusher) push(now time.Time) error {
+	families, err := pp.Gatherer.Gather()
+	if err != nil {
+		// Gather may return partial results along with the error.
+		log.Warnf("problem with gathering some of the metrics: %+v", err)
+	}
+	samples := flattenMetricFamilies(families)
+	if len(samples) == 0 {
+		return nil
+	}
+
+	payload, err := pp.encode(samples, now)
+	if err != nil {
+		return err
+	}
+
+	if err = pp.flushBuffer(now); err == nil {
+		err = pp.send(payload)
+	}
+	if err != nil {
+		if bufErr := pp.storeInBuffer(payload, now); bufErr != nil {
+			log.Errorf("%+v", bufErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// Encodes the samples in the configured format.
+func (pp *PromPusher) encode(samples []promSample, now time.Time) ([]byte, error) {
+	switch pp.Format {
+	case PushFormatRemoteWrite:
+		return encodeRemoteWrite(samples, now), nil
+	case PushFormatInfluxDB:
+		return encodeInfluxDB(samples, now), nil
+	case PushFormatStatsD:
+		return encodeStatsD(samples), nil
+	}
+	return nil, errors.Errorf("unsupported format of the pushed metrics: %s", pp.Format)
+}
+
+// Sends the encoded metrics to the sink.
+func (pp *PromPusher) send(payload []byte) error {
+	if pp.Format == PushFormatStatsD {
+		sinkURL, err := url.Parse(pp.URL)
+		if err != nil {
+			return errors.Wrapf(err, "invalid URL of the metrics sink: %s", pp.URL)
+		}
+		network := sinkURL.Scheme
+		if network == "" || network == "statsd" {
+			network = "udp"
+		}
+		conn, err := net.DialTimeout(network, sinkURL.Host, 10*time.Second)
+		if err != nil {
+			return errors.Wrapf(err, "problem with connecting to StatsD sink %s", pp.URL)
+		}
+		defer conn.Close()
+		// Send one metric per datagram to avoid exceeding the MTU. Each
+		// metric is terminated with a newline, so the metrics sent over
+		// the TCP stream are separated.
+		for _, line := range bytes.Split(payload, []byte("\n")) {
+			if len(line) == 0 {
+				continue
+			}
+			if _, err = conn.Write(append(line, '\n')); err != nil {
+				return errors.Wrapf(err, "problem with sending metrics to StatsD sink %s", pp.URL)
+			}
+		}
+		return nil
+	}
+
+	req, err := http.NewRequest(http.MethodPost, pp.URL, bytes.NewReader(payload))
+	if err != nil {
+		return errors.Wrapf(err, "problem with creating POST request to %s", pp.URL)
+	}
+	if pp.Format == PushFormatRemoteWrite {
+		req.Header.Set("Content-Type", "application/x-protobuf")
+		req.Header.Set("Content-Encoding", "snappy")
+		req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	} else {
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	rsp, err := pp.HTTPClient.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "problem with sending POST to %s", pp.URL)
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(rsp.Body)
+		return errors.Errorf("metrics sink %s returned %s: %s", pp.URL, rsp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// Stores the payload which couldn't be sent in the buffer directory. The
+// file name holds the time when the payload was created.
+func (pp *PromPusher) storeInBuffer(payload []byte, now time.Time) error {
+	if pp.BufferDir == "" {
+		return nil
+	}
+	path := filepath.Join(pp.BufferDir, fmt.Sprintf("%020d.%s", now.UnixNano(), pp.Format))
+	if err := ioutil.WriteFile(path, payload, 0o600); err != nil {
+		return errors.Wrapf(err, "problem with buffering pushed metrics in %s", path)
+	}
+	return nil
+}
+
+// Sends the buffered payloads to the sink, starting from the oldest one.
+// The payloads older than the maximum age are removed without sending.
+// Payloads buffered in a different format than currently configured are
+// removed too. It stops at the first payload that can't be sent and
+// returns an error.
+func (pp *PromPusher) flushBuffer(now time.Time) error {
+	if pp.BufferDir == "" {
+		return nil
+	}
+	files, err := ioutil.ReadDir(pp.BufferDir)
+	if err != nil {
+		return errors.Wrapf(err, "problem with reading buffered metrics from %s", pp.BufferDir)
+	}
+	// The names begin with zero padded timestamps so the alphabetical
+	// order is also chronological.
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name() < files[j].Name()
+	})
+	for _, f := range files {
+		path := filepath.Join(pp.BufferDir, f.Name())
+		parts := strings.SplitN(f.Name(), ".", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		ts, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		if parts[1] != pp.Format || now.Sub(time.Unix(0, ts)) > pp.BufferMaxAge {
+			log.Warnf("discarding buffered metrics %s", path)
+			_ = os.Remove(path)
+			continue
+		}
+		payload, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "problem with reading buffered metrics from %s", path)
+		}
+		if err = pp.send(payload); err != nil {
+			return err
+		}
+		_ = os.Remove(path)
+	}
+	return nil
+}
+
+// Converts the metric families gathered from the Prometheus registries
+// to a flat list of samples. The labels of each sample are sorted by name.
+func flattenMetricFamilies(families []*dto.MetricFamily) []promSample {
+	var samples []promSample
+	for _, family := range families {
+		name := family.GetName()
+		for _, m := range family.GetMetric() {
+			labels := []promLabel{}
+			for _, lp := range m.GetLabel() {
+				labels = append(labels, promLabel{Name: lp.GetName(), Value: lp.GetValue()})
+			}
+			add := func(name string, value float64, extra ...promLabel) {
+				sampleLabels := append(append([]promLabel{}, labels...), extra...)
+				sort.Slice(sampleLabels, func(i, j int) bool {
+					return sampleLabels[i].Name < sampleLabels[j].Name
+				})
+				samples = append(samples, promSample{Name: name, Labels: sampleLabels, Value: value})
+			}
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				add(name, m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add(name, m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				add(name, m.GetUntyped().GetValue())
+			case dto.MetricType_SUMMARY:
+				summary := m.GetSummary()
+				for _, q := range summary.GetQuantile() {
+					add(name, q.GetValue(), promLabel{"quantile", formatFloat(q.GetQuantile())})
+				}
+				add(name+"_sum", summary.GetSampleSum())
+				add(name+"_count", float64(summary.GetSampleCount()))
+			case dto.MetricType_HISTOGRAM:
+				histogram := m.GetHistogram()
+				for _, b := range histogram.GetBucket() {
+					add(name+"_bucket", float64(b.GetCumulativeCount()), promLabel{"le", formatFloat(b.GetUpperBound())})
+				}
+				add(name+"_bucket", float64(histogram.GetSampleCount()), promLabel{"le", "+Inf"})
+				add(name+"_sum", histogram.GetSampleSum())
+				add(name+"_count", float64(histogram.GetSampleCount()))
+			}
+		}
+	}
+	return samples
+}
+
+// Formats the float the same way as Prometheus does in the labels.
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// Encodes the samples as Prometheus remote_write WriteRequest, i.e.
+// snappy compressed protobuf message. The message is encoded by hand
+// to avoid pulling in the whole Prometheus server as a dependency.
+//
+//   message WriteRequest { repeated TimeSeries timeseries = 1; }
+//   message TimeSeries { repeated Label labels = 1; repeated Sample samples = 2; }
+//   message Label { string name = 1; string value = 2; }
+//   message Sample { double value = 1; int64 timestamp = 2; }
+func encodeRemoteWrite(samples []promSample, now time.Time) []byte {
+	timestamp := now.UnixNano() / int64(time.Millisecond)
+	var request []byte
+	for _, sample := range samples {
+		var series []byte
+		// The metric name is held in the special __name__ label. The
+		// remote_write protocol requires the labels sorted by name, so
+		// it is sorted along with the other labels.
+		labels := append([]promLabel{{Name: "__name__", Value: sample.Name}}, sample.Labels...)
+		sort.Slice(labels, func(i, j int) bool {
+			return labels[i].Name < labels[j].Name
+		})
+		for _, label := range labels {
+			var l []byte
+			l = protowire.AppendTag(l, 1, protowire.BytesType)
+			l = protowire.AppendString(l, label.Name)
+			l = protowire.AppendTag(l, 2, protowire.BytesType)
+			l = protowire.AppendString(l, label.Value)
+			series = protowire.AppendTag(series, 1, protowire.BytesType)
+			series = protowire.AppendBytes(series, l)
+		}
+		var s []byte
+		s = protowire.AppendTag(s, 1, protowire.Fixed64Type)
+		s = protowire.AppendFixed64(s, math.Float64bits(sample.Value))
+		s = protowire.AppendTag(s, 2, protowire.VarintType)
+		s = protowire.AppendVarint(s, uint64(timestamp))
+		series = protowire.AppendTag(series, 2, protowire.BytesType)
+		series = protowire.AppendBytes(series, s)
+
+		request = protowire.AppendTag(request, 1, protowire.BytesType)
+		request = protowire.AppendBytes(request, series)
+	}
+	return snappy.Encode(nil, request)
+}
+
+// Encodes the samples using InfluxDB line protocol. The metric name is
+// used as a measurement, labels as tags and the sample value is stored
+// in the "value" field.
+func encodeInfluxDB(samples []promSample, now time.Time) []byte {
+	measurementEscaper := strings.NewReplacer(",", `\,`, " ", `\ `)
+	tagEscaper := strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+	var buf bytes.Buffer
+	for _, sample := range samples {
+		if math.IsNaN(sample.Value) || math.IsInf(sample.Value, 0) {
+			// InfluxDB does not accept such values.
+			continue
+		}
+		buf.WriteString(measurementEscaper.Replace(sample.Name))
+		for _, label := range sample.Labels {
+			if label.Value == "" {
+				continue
+			}
+			fmt.Fprintf(&buf, ",%s=%s", tagEscaper.Replace(label.Name), tagEscaper.Replace(label.Value))
+		}
+		fmt.Fprintf(&buf, " value=%s %d\n", strconv.FormatFloat(sample.Value, 'g', -1, 64), now.UnixNano())
+	}
+	return buf.Bytes()
+}
+
+// Encodes the samples as StatsD gauges. The labels are appended using
+// the DogStatsD tags extension which is understood by most of the
+// StatsD implementations, e.g. statsd_exporter or Telegraf.
+func encodeStatsD(samples []promSample) []byte {
+	escaper := strings.NewReplacer(":", "_", "|", "_", ",", "_", "#", "_", "\n", " ")
+	var buf bytes.Buffer
+	for _, sample := range samples {
+		if math.IsNaN(sample.Value) || math.IsInf(sample.Value, 0) {
+			continue
+		}
+		fmt.Fprintf(&buf, "%s:%s|g", escaper.Replace(sample.Name), strconv.FormatFloat(sample.Value, 'g', -1, 64))
+		for i, label := range sample.Labels {
+			if i == 0 {
+				buf.WriteString("|#")
+			} else {
+				buf.WriteString(",")
+			}
+			fmt.Fprintf(&buf, "%s:%s", escaper.Replace(label.Name), escaper.Replace(label.Value))
+		}
+		buf.WriteString("\n")
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,324 @@
+package agent
+
+import (
+	"flag"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Creates pusher settings with the given URL, format and buffer directory.
+func newPromPusherTestSettings(url, format, bufferDir string) *cli.Context {
+	flags := flag.NewFlagSet("test", 0)
+	flags.String("prometheus-push-url", "", "usage")
+	flags.String("prometheus-push-format", "", "usage")
+	flags.String("prometheus-push-buffer-dir", "", "usage")
+	flags.Int("prometheus-push-buffer-max-age", 3600, "usage")
+	flags.Int("prometheus-push-interval", 30, "usage")
+	settings := cli.NewContext(nil, flags, nil)
+	_ = settings.Set("prometheus-push-url", url)
+	_ = settings.Set("prometheus-push-format", format)
+	_ = settings.Set("prometheus-push-buffer-dir", bufferDir)
+	return settings
+}
+
+// Creates a registry with a gauge and a histogram.
+func newPromPusherTestRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: AppTypeKea,
+		Subsystem: "dhcp4",
+		Name:      "addresses_assigned_total",
+		Help:      "Assigned addresses",
+	}, []string{"subnet"})
+	gauge.With(prometheus.Labels{"subnet": "7"}).Set(13)
+	registry.MustRegister(gauge)
+
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "rtt",
+		Help:    "Round trip time",
+		Buckets: []float64{0.5},
+	})
+	histogram.Observe(0.25)
+	histogram.Observe(1)
+	registry.MustRegister(histogram)
+	return registry
+}
+
+// Check that the metric families are converted to the flat list of samples.
+func TestFlattenMetricFamilies(t *testing.T) {
+	families, err := newPromPusherTestRegistry().Gather()
+	require.NoError(t, err)
+
+	samples := flattenMetricFamilies(families)
+	require.Len(t, samples, 5)
+
+	require.Equal(t, "kea_dhcp4_addresses_assigned_total", samples[0].Name)
+	require.Equal(t, []promLabel{{Name: "subnet", Value: "7"}}, samples[0].Labels)
+	require.EqualValues(t, 13, samples[0].Value)
+
+	require.Equal(t, "rtt_bucket", samples[1].Name)
+	require.Equal(t, []promLabel{{Name: "le", Value: "0.5"}}, samples[1].Labels)
+	require.EqualValues(t, 1, samples[1].Value)
+
+	require.Equal(t, "rtt_bucket", samples[2].Name)
+	require.Equal(t, []promLabel{{Name: "le", Value: "+Inf"}}, samples[2].Labels)
+	require.EqualValues(t, 2, samples[2].Value)
+
+	require.Equal(t, "rtt_sum", samples[3].Name)
+	require.EqualValues(t, 1.25, samples[3].Value)
+
+	require.Equal(t, "rtt_count", samples[4].Name)
+	require.EqualValues(t, 2, samples[4].Value)
+}
+
+// Check that the samples are encoded using the InfluxDB line protocol.
+func TestEncodeInfluxDB(t *testing.T) {
+	samples := []promSample{
+		{
+			Name:   "kea_dhcp4_addresses_assigned_total",
+			Labels: []promLabel{{Name: "subnet", Value: "7"}, {Name: "desc", Value: "a b,c=d"}},
+			Value:  13,
+		},
+	}
+	payload := encodeInfluxDB(samples, time.Unix(10, 5))
+	require.Equal(t, "kea_dhcp4_addresses_assigned_total,subnet=7,desc=a\\ b\\,c\\=d value=13 10000000005\n", string(payload))
+}
+
+// Check that the samples are encoded as StatsD gauges with tags.
+func TestEncodeStatsD(t *testing.T) {
+	samples := []promSample{
+		{
+			Name:   "kea_dhcp4_addresses_assigned_total",
+			Labels: []promLabel{{Name: "subnet", Value: "7"}, {Name: "pool", Value: "a:b"}},
+			Value:  13,
+		},
+		{
+			Name:  "rtt_sum",
+			Value: 1.25,
+		},
+	}
+	payload := encodeStatsD(samples)
+	require.Equal(t, "kea_dhcp4_addresses_assigned_total:13|g|#subnet:7,pool:a_b\nrtt_sum:1.25|g\n", string(payload))
+}
+
+// Decoded remote_write time series used in the tests.
+type remoteWriteTestSeries struct {
+	Labels    []promLabel
+	Value     float64
+	Timestamp int64
+}
+
+// Decodes the fields of the protobuf message and calls the function for
+// each field holding bytes or a number.
+func decodeRemoteWriteTestFields(t *testing.T, msg []byte, fn func(num protowire.Number, value []byte, number uint64)) {
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		require.GreaterOrEqual(t, n, 0)
+		msg = msg[n:]
+		switch typ {
+		case protowire.BytesType:
+			value, n := protowire.ConsumeBytes(msg)
+			require.GreaterOrEqual(t, n, 0)
+			fn(num, value, 0)
+			msg = msg[n:]
+		case protowire.Fixed64Type:
+			value, n := protowire.ConsumeFixed64(msg)
+			require.GreaterOrEqual(t, n, 0)
+			fn(num, nil, value)
+			msg = msg[n:]
+		case protowire.VarintType:
+			value, n := protowire.ConsumeVarint(msg)
+			require.GreaterOrEqual(t, n, 0)
+			fn(num, nil, value)
+			msg = msg[n:]
+		default:
+			require.FailNow(t, "unexpected wire type", "%d", typ)
+		}
+	}
+}
+
+// Decodes the snappy compressed remote_write WriteRequest.
+func decodeRemoteWriteTestRequest(t *testing.T, payload []byte) (series []remoteWriteTestSeries) {
+	request, err := snappy.Decode(nil, payload)
+	require.NoError(t, err)
+	decodeRemoteWriteTestFields(t, request, func(num protowire.Number, value []byte, _ uint64) {
+		require.EqualValues(t, 1, num)
+		var ts remoteWriteTestSeries
+		decodeRemoteWriteTestFields(t, value, func(num protowire.Number, value []byte, _ uint64) {
+			switch num {
+			case 1:
+				var label promLabel
+				decodeRemoteWriteTestFields(t, value, func(num protowire.Number, value []byte, _ uint64) {
+					if num == 1 {
+						label.Name = string(value)
+					} else {
+						label.Value = string(value)
+					}
+				})
+				ts.Labels = append(ts.Labels, label)
+			case 2:
+				decodeRemoteWriteTestFields(t, value, func(num protowire.Number, _ []byte, number uint64) {
+					if num == 1 {
+						ts.Value = math.Float64frombits(number)
+					} else {
+						ts.Timestamp = int64(number)
+					}
+				})
+			}
+		})
+		series = append(series, ts)
+	})
+	return series
+}
+
+// Check that the remote write request is snappy compressed protobuf
+// holding the samples with the labels sorted by name, including the
+// __name__ label.
+func TestEncodeRemoteWrite(t *testing.T) {
+	samples := []promSample{
+		{
+			Name:   "kea_dhcp4_addresses_assigned_total",
+			Labels: []promLabel{{Name: "Zone", Value: "a"}, {Name: "subnet", Value: "7"}},
+			Value:  13,
+		},
+		{
+			Name:  "rtt_sum",
+			Value: 1.25,
+		},
+	}
+	payload := encodeRemoteWrite(samples, time.Unix(10, 0))
+	series := decodeRemoteWriteTestRequest(t, payload)
+	require.Equal(t, []remoteWriteTestSeries{
+		{
+			Labels: []promLabel{
+				{Name: "Zone", Value: "a"},
+				{Name: "__name__", Value: "kea_dhcp4_addresses_assigned_total"},
+				{Name: "subnet", Value: "7"},
+			},
+			Value:     13,
+			Timestamp: 10000,
+		},
+		{
+			Labels:    []promLabel{{Name: "__name__", Value: "rtt_sum"}},
+			Value:     1.25,
+			Timestamp: 10000,
+		},
+	}, series)
+}
+
+// Check that the StatsD metrics sent over TCP are separated with
+// newlines.
+func TestPromPusherStatsDTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			received <- ""
+			return
+		}
+		defer conn.Close()
+		data, _ := ioutil.ReadAll(conn)
+		received <- string(data)
+	}()
+
+	settings := newPromPusherTestSettings("tcp://"+listener.Addr().String(), PushFormatStatsD, "")
+	pp := NewPromPusher(settings)
+	require.NoError(t, pp.send([]byte("foo:1|g\nbar:2|g|#subnet:7\n")))
+	require.Equal(t, "foo:1|g\nbar:2|g|#subnet:7\n", <-received)
+}
+
+// Check that the metrics are buffered on disk when the sink is down
+// and that they are sent when the sink is back.
+func TestPromPusherBuffering(t *testing.T) {
+	sinkUp := false
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !sinkUp {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		require.Equal(t, "snappy", r.Header.Get("Content-Encoding"))
+		require.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		body, _ := ioutil.ReadAll(r.Body)
+		received = append(received, string(body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	bufferDir, err := ioutil.TempDir("", "push")
+	require.NoError(t, err)
+	defer os.RemoveAll(bufferDir)
+
+	settings := newPromPusherTestSettings(server.URL, PushFormatRemoteWrite, bufferDir)
+	pp := NewPromPusher(settings, newPromPusherTestRegistry())
+	require.Equal(t, server.URL, pp.URL)
+	require.Equal(t, time.Hour, pp.BufferMaxAge)
+
+	// The sink is down so the metrics should be buffered.
+	now := time.Now()
+	require.Error(t, pp.push(now))
+	require.Error(t, pp.push(now.Add(time.Second)))
+	files, err := ioutil.ReadDir(bufferDir)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	// The sink is back so the buffered metrics should be sent
+	// along with the current ones.
+	sinkUp = true
+	require.NoError(t, pp.push(now.Add(2*time.Second)))
+	require.Len(t, received, 3)
+	files, err = ioutil.ReadDir(bufferDir)
+	require.NoError(t, err)
+	require.Empty(t, files)
+}
+
+// Check that the buffered metrics older than the maximum age are
+// discarded.
+func TestPromPusherDiscardOldBuffered(t *testing.T) {
+	received := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	bufferDir, err := ioutil.TempDir("", "push")
+	require.NoError(t, err)
+	defer os.RemoveAll(bufferDir)
+
+	settings := newPromPusherTestSettings(server.URL, PushFormatInfluxDB, bufferDir)
+	pp := NewPromPusher(settings, newPromPusherTestRegistry())
+
+	now := time.Now()
+	require.NoError(t, pp.storeInBuffer([]byte("old 1"), now.Add(-2*time.Hour)))
+	require.NoError(t, pp.storeInBuffer([]byte("new 1"), now.Add(-time.Minute)))
+
+	require.NoError(t, pp.flushBuffer(now))
+	require.Equal(t, 1, received)
+	files, err := ioutil.ReadDir(bufferDir)
+	require.NoError(t, err)
+	require.Empty(t, files)
+}
+
+// Check that starting the pusher with unsupported format fails.
+func TestPromPusherStartBadFormat(t *testing.T) {
+	settings := newPromPusherTestSettings("http://localhost:1234", "graphite", "")
+	pp := NewPromPusher(settings)
+	require.Error(t, pp.Start())
+}
@@ -0,0 +1,265 @@
+package keaconfig
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Kinds of the configuration elements which may change.
+const (
+	ConfigElementParameter     = "parameter"
+	ConfigElementSharedNetwork = "shared-network"
+	ConfigElementSubnet        = "subnet"
+	ConfigElementPool          = "pool"
+	ConfigElementReservation   = "reservation"
+	ConfigElementClientClass   = "client-class"
+)
+
+// Operations performed on the configuration elements.
+const (
+	ConfigChangeAdded    = "added"
+	ConfigChangeRemoved  = "removed"
+	ConfigChangeModified = "modified"
+)
+
+// Single change between two configurations. The path identifies the
+// changed element, e.g. /Dhcp4/subnet4[192.0.2.0/24]/pools[192.0.2.10-192.0.2.20].
+// The elements of the lists of subnets, shared networks, pools,
+// reservations and client classes are identified by their prefixes, names,
+// pool ranges, host identifiers and class names respectively, so the
+// changes are reported correctly even when the elements are reordered.
+// The old value is not set for the added elements and the new value is
+// not set for the removed ones. The values are not set for the modified
+// shared networks and subnets; their modified contents are reported as
+// separate changes.
+type ConfigChange struct {
+	Element   string      `json:"element"`
+	Operation string      `json:"operation"`
+	Path      string      `json:"path"`
+	Old       interface{} `json:"old,omitempty"`
+	New       interface{} `json:"new,omitempty"`
+}
+
+// Describes how to identify the elements of the list in the configuration.
+type keyedList struct {
+	element string
+	key     func(item map[string]interface{}) string
+}
+
+// Lists of the configuration elements compared by their keys rather than
+// by their positions.
+var keyedLists = map[string]keyedList{
+	"shared-networks": {ConfigElementSharedNetwork, getSharedNetworkKey},
+	"subnet4":         {ConfigElementSubnet, getSubnetKey},
+	"subnet6":         {ConfigElementSubnet, getSubnetKey},
+	"pools":           {ConfigElementPool, getPoolKey},
+	"pd-pools":        {ConfigElementPool, getPdPoolKey},
+	"reservations":    {ConfigElementReservation, getReservationKey},
+	"client-classes":  {ConfigElementClientClass, getClientClassKey},
+}
+
+// Returns the key identifying the shared network.
+func getSharedNetworkKey(item map[string]interface{}) string {
+	name, _ := item["name"].(string)
+	return name
+}
+
+// Returns the key identifying the subnet.
+func getSubnetKey(item map[string]interface{}) string {
+	prefix, _ := item["subnet"].(string)
+	return prefix
+}
+
+// Returns the key identifying the address pool. The whitespace around
+// the dash in the pool range is insignificant.
+func getPoolKey(item map[string]interface{}) string {
+	pool, _ := item["pool"].(string)
+	return strings.ReplaceAll(pool, " ", "")
+}
+
+// Returns the key identifying the delegated prefix pool.
+func getPdPoolKey(item map[string]interface{}) string {
+	prefix, _ := item["prefix"].(string)
+	if len(prefix) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s/%v", prefix, item["prefix-len"])
+}
+
+// Returns the key identifying the client class.
+func getClientClassKey(item map[string]interface{}) string {
+	name, _ := item["name"].(string)
+	return name
+}
+
+// Returns the key identifying the host reservation, i.e. the identifier
+// type and the identifier value.
+func getReservationKey(item map[string]interface{}) string {
+	for _, identifier := range []string{"hw-address", "duid", "client-id", "circuit-id", "flex-id"} {
+		if value, ok := item[identifier].(string); ok && len(value) > 0 {
+			return fmt.Sprintf("%s=%s", identifier, strings.ToLower(value))
+		}
+	}
+	return ""
+}
+
+// Returns the structural differences between two configurations. The
+// changes are returned in the order of the paths of the compared
+// elements, with the keys of the maps sorted alphabetically.
+func DiffConfigs(oldConfig, newConfig *Map) []ConfigChange {
+	var oldRaw, newRaw map[string]interface{}
+	if oldConfig != nil {
+		oldRaw = *oldConfig
+	}
+	if newConfig != nil {
+		newRaw = *newConfig
+	}
+	changes := []ConfigChange{}
+	diffMaps("", oldRaw, newRaw, &changes)
+	return changes
+}
+
+// Compares two configuration maps and appends the changes of their
+// parameters to the list.
+func diffMaps(path string, oldMap, newMap map[string]interface{}, changes *[]ConfigChange) {
+	keys := make(map[string]bool)
+	for key := range oldMap {
+		keys[key] = true
+	}
+	for key := range newMap {
+		keys[key] = true
+	}
+	sortedKeys := make([]string, 0, len(keys))
+	for key := range keys {
+		sortedKeys = append(sortedKeys, key)
+	}
+	sort.Strings(sortedKeys)
+
+	for _, key := range sortedKeys {
+		childPath := path + "/" + key
+		oldValue, oldOk := oldMap[key]
+		newValue, newOk := newMap[key]
+		if list, ok := keyedLists[key]; ok && diffKeyedLists(childPath, list, oldValue, newValue, changes) {
+			continue
+		}
+		switch {
+		case !oldOk:
+			*changes = append(*changes, ConfigChange{ConfigElementParameter, ConfigChangeAdded, childPath, nil, newValue})
+		case !newOk:
+			*changes = append(*changes, ConfigChange{ConfigElementParameter, ConfigChangeRemoved, childPath, oldValue, nil})
+		default:
+			diffValues(childPath, oldValue, newValue, changes)
+		}
+	}
+}
+
+// Compares two configuration values. The maps are compared parameter by
+// parameter. Other values, including the lists, are reported as modified
+// parameters if they differ.
+func diffValues(path string, oldValue, newValue interface{}, changes *[]ConfigChange) {
+	oldMap, oldOk := toMap(oldValue)
+	newMap, newOk := toMap(newValue)
+	if oldOk && newOk {
+		diffMaps(path, oldMap, newMap, changes)
+		return
+	}
+	if !reflect.DeepEqual(oldValue, newValue) {
+		*changes = append(*changes, ConfigChange{ConfigElementParameter, ConfigChangeModified, path, oldValue, newValue})
+	}
+}
+
+// Compares the lists of the configuration elements identified by keys,
+// e.g. the subnets identified by prefixes. The missing list is treated as
+// an empty list. It returns false if any of the values is not a list of
+// maps or if any of the elements has no unique key, in which case the
+// lists should be compared as regular values.
+func diffKeyedLists(path string, list keyedList, oldValue, newValue interface{}, changes *[]ConfigChange) bool {
+	oldKeys, oldItems, ok := indexList(list, oldValue)
+	if !ok {
+		return false
+	}
+	newKeys, newItems, ok := indexList(list, newValue)
+	if !ok {
+		return false
+	}
+	for _, key := range oldKeys {
+		itemPath := fmt.Sprintf("%s[%s]", path, key)
+		newItem, ok := newItems[key]
+		if !ok {
+			*changes = append(*changes, ConfigChange{list.element, ConfigChangeRemoved, itemPath, oldItems[key], nil})
+			continue
+		}
+		var itemChanges []ConfigChange
+		diffMaps(itemPath, oldItems[key], newItem, &itemChanges)
+		if len(itemChanges) == 0 {
+			continue
+		}
+		// The pools, reservations and client classes are reported as a
+		// whole while the shared networks and subnets are reported along
+		// with their modified contents.
+		if list.element == ConfigElementPool || list.element == ConfigElementReservation ||
+			list.element == ConfigElementClientClass {
+			*changes = append(*changes, ConfigChange{list.element, ConfigChangeModified, itemPath, oldItems[key], newItem})
+			continue
+		}
+		*changes = append(*changes, ConfigChange{list.element, ConfigChangeModified, itemPath, nil, nil})
+		*changes = append(*changes, itemChanges...)
+	}
+	for _, key := range newKeys {
+		if _, ok := oldItems[key]; !ok {
+			itemPath := fmt.Sprintf("%s[%s]", path, key)
+			*changes = append(*changes, ConfigChange{list.element, ConfigChangeAdded, itemPath, nil, newItems[key]})
+		}
+	}
+	return true
+}
+
+// Indexes the list of the configuration elements by their keys. It
+// returns the keys in the order of the elements and the elements by keys.
+// The nil value is treated as an empty list.
+func indexList(list keyedList, value interface{}) ([]string, map[string]map[string]interface{}, bool) {
+	items := make(map[string]map[string]interface{})
+	if value == nil {
+		return nil, items, true
+	}
+	rawList, ok := value.([]interface{})
+	if !ok {
+		return nil, nil, false
+	}
+	var keys []string
+	for _, rawItem := range rawList {
+		item, ok := toMap(rawItem)
+		if !ok {
+			return nil, nil, false
+		}
+		key := list.key(item)
+		if len(key) == 0 {
+			return nil, nil, false
+		}
+		if _, exists := items[key]; exists {
+			return nil, nil, false
+		}
+		keys = append(keys, key)
+		items[key] = item
+	}
+	return keys, items, true
+}
+
+// Converts the configuration value to the map if it is a map.
+func toMap(value interface{}) (map[string]interface{}, bool) {
+	switch m := value.(type) {
+	case map[string]interface{}:
+		return m, true
+	case Map:
+		return m, true
+	case *Map:
+		if m == nil {
+			return nil, false
+		}
+		return *m, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,230 @@
+package keaconfig
+
+import (
+	"testing"
+
+	require "github.com/stretchr/testify/require"
+)
+
+// Returns the test configuration parsed from JSON.
+func getDiffTestConfig(t *testing.T, configStr string) *Map {
+	cfg, err := NewFromJSON(configStr)
+	require.NoError(t, err)
+	return cfg
+}
+
+// Test that the subnets, pools, reservations and parameters which changed
+// between the configurations are reported.
+func TestDiffConfigs(t *testing.T) {
+	oldConfig := getDiffTestConfig(t, `{
+        "Dhcp4": {
+            "valid-lifetime": 1000,
+            "interfaces-config": {
+                "interfaces": [ "eth0" ]
+            },
+            "subnet4": [
+                {
+                    "id": 1,
+                    "subnet": "192.0.2.0/24",
+                    "pools": [
+                        { "pool": "192.0.2.10 - 192.0.2.20" },
+                        { "pool": "192.0.2.30-192.0.2.40" }
+                    ],
+                    "reservations": [
+                        { "hw-address": "01:02:03:04:05:06", "ip-address": "192.0.2.5" },
+                        { "hw-address": "01:02:03:04:05:07", "ip-address": "192.0.2.6" }
+                    ]
+                },
+                {
+                    "id": 2,
+                    "subnet": "198.51.100.0/24"
+                }
+            ]
+        }
+    }`)
+	newConfig := getDiffTestConfig(t, `{
+        "Dhcp4": {
+            "valid-lifetime": 2000,
+            "interfaces-config": {
+                "interfaces": [ "eth0", "eth1" ]
+            },
+            "renew-timer": 500,
+            "subnet4": [
+                {
+                    "id": 3,
+                    "subnet": "203.0.113.0/24"
+                },
+                {
+                    "id": 1,
+                    "subnet": "192.0.2.0/24",
+                    "pools": [
+                        { "pool": "192.0.2.30-192.0.2.40" },
+                        { "pool": "192.0.2.50-192.0.2.60" }
+                    ],
+                    "reservations": [
+                        { "hw-address": "01:02:03:04:05:07", "ip-address": "192.0.2.6" },
+                        { "hw-address": "01:02:03:04:05:06", "ip-address": "192.0.2.7" }
+                    ]
+                }
+            ]
+        }
+    }`)
+
+	changes := DiffConfigs(oldConfig, newConfig)
+	require.Len(t, changes, 9)
+
+	require.Equal(t, ConfigElementParameter, changes[0].Element)
+	require.Equal(t, ConfigChangeModified, changes[0].Operation)
+	require.Equal(t, "/Dhcp4/interfaces-config/interfaces", changes[0].Path)
+
+	require.Equal(t, ConfigElementParameter, changes[1].Element)
+	require.Equal(t, ConfigChangeAdded, changes[1].Operation)
+	require.Equal(t, "/Dhcp4/renew-timer", changes[1].Path)
+	require.Nil(t, changes[1].Old)
+	require.EqualValues(t, 500, changes[1].New)
+
+	require.Equal(t, ConfigElementSubnet, changes[2].Element)
+	require.Equal(t, ConfigChangeModified, changes[2].Operation)
+	require.Equal(t, "/Dhcp4/subnet4[192.0.2.0/24]", changes[2].Path)
+
+	require.Equal(t, ConfigElementPool, changes[3].Element)
+	require.Equal(t, ConfigChangeRemoved, changes[3].Operation)
+	require.Equal(t, "/Dhcp4/subnet4[192.0.2.0/24]/pools[192.0.2.10-192.0.2.20]", changes[3].Path)
+	require.NotNil(t, changes[3].Old)
+	require.Nil(t, changes[3].New)
+
+	require.Equal(t, ConfigElementPool, changes[4].Element)
+	require.Equal(t, ConfigChangeAdded, changes[4].Operation)
+	require.Equal(t, "/Dhcp4/subnet4[192.0.2.0/24]/pools[192.0.2.50-192.0.2.60]", changes[4].Path)
+
+	require.Equal(t, ConfigElementReservation, changes[5].Element)
+	require.Equal(t, ConfigChangeModified, changes[5].Operation)
+	require.Equal(t, "/Dhcp4/subnet4[192.0.2.0/24]/reservations[hw-address=01:02:03:04:05:06]", changes[5].Path)
+	require.Equal(t, "192.0.2.5", changes[5].Old.(map[string]interface{})["ip-address"])
+	require.Equal(t, "192.0.2.7", changes[5].New.(map[string]interface{})["ip-address"])
+
+	require.Equal(t, ConfigElementSubnet, changes[6].Element)
+	require.Equal(t, ConfigChangeRemoved, changes[6].Operation)
+	require.Equal(t, "/Dhcp4/subnet4[198.51.100.0/24]", changes[6].Path)
+
+	require.Equal(t, ConfigElementSubnet, changes[7].Element)
+	require.Equal(t, ConfigChangeAdded, changes[7].Operation)
+	require.Equal(t, "/Dhcp4/subnet4[203.0.113.0/24]", changes[7].Path)
+
+	require.Equal(t, ConfigElementParameter, changes[8].Element)
+	require.Equal(t, ConfigChangeModified, changes[8].Operation)
+	require.Equal(t, "/Dhcp4/valid-lifetime", changes[8].Path)
+	require.EqualValues(t, 1000, changes[8].Old)
+	require.EqualValues(t, 2000, changes[8].New)
+}
+
+// Test that the subnets in the shared networks are compared and that
+// the subnets are reported when the list appears in the configuration.
+func TestDiffConfigsSharedNetworks(t *testing.T) {
+	oldConfig := getDiffTestConfig(t, `{
+        "Dhcp6": {
+            "shared-networks": [
+                {
+                    "name": "foo",
+                    "subnet6": [
+                        { "subnet": "2001:db8:1::/64" }
+                    ]
+                }
+            ]
+        }
+    }`)
+	newConfig := getDiffTestConfig(t, `{
+        "Dhcp6": {
+            "shared-networks": [
+                {
+                    "name": "foo",
+                    "subnet6": [
+                        {
+                            "subnet": "2001:db8:1::/64",
+                            "pd-pools": [
+                                { "prefix": "3000::", "prefix-len": 48, "delegated-len": 64 }
+                            ]
+                        }
+                    ]
+                }
+            ],
+            "subnet6": [
+                { "subnet": "2001:db8:2::/64" }
+            ]
+        }
+    }`)
+
+	changes := DiffConfigs(oldConfig, newConfig)
+	require.Len(t, changes, 4)
+	require.Equal(t, ConfigElementSharedNetwork, changes[0].Element)
+	require.Equal(t, "/Dhcp6/shared-networks[foo]", changes[0].Path)
+	require.Equal(t, ConfigElementSubnet, changes[1].Element)
+	require.Equal(t, "/Dhcp6/shared-networks[foo]/subnet6[2001:db8:1::/64]", changes[1].Path)
+	require.Equal(t, ConfigElementPool, changes[2].Element)
+	require.Equal(t, ConfigChangeAdded, changes[2].Operation)
+	require.Equal(t, "/Dhcp6/shared-networks[foo]/subnet6[2001:db8:1::/64]/pd-pools[3000::/48]", changes[2].Path)
+	require.Equal(t, ConfigElementSubnet, changes[3].Element)
+	require.Equal(t, ConfigChangeAdded, changes[3].Operation)
+	require.Equal(t, "/Dhcp6/subnet6[2001:db8:2::/64]", changes[3].Path)
+}
+
+// Test that the lists without unique keys are compared as parameters and
+// that identical configurations have no changes.
+func TestDiffConfigsNoKeys(t *testing.T) {
+	oldConfig := getDiffTestConfig(t, `{
+        "Dhcp4": {
+            "subnet4": [
+                { "id": 1 },
+                { "id": 2 }
+            ]
+        }
+    }`)
+	newConfig := getDiffTestConfig(t, `{
+        "Dhcp4": {
+            "subnet4": [
+                { "id": 1 }
+            ]
+        }
+    }`)
+
+	changes := DiffConfigs(oldConfig, newConfig)
+	require.Len(t, changes, 1)
+	require.Equal(t, ConfigElementParameter, changes[0].Element)
+	require.Equal(t, ConfigChangeModified, changes[0].Operation)
+	require.Equal(t, "/Dhcp4/subnet4", changes[0].Path)
+
+	require.Empty(t, DiffConfigs(oldConfig, oldConfig))
+	require.NotNil(t, DiffConfigs(nil, nil))
+}
+
+// Test that the client classes are compared by their names.
+func TestDiffConfigsClientClasses(t *testing.T) {
+	oldConfig := getDiffTestConfig(t, `{
+        "Dhcp4": {
+            "client-classes": [
+                { "name": "foo", "test": "member('ALL')" },
+                { "name": "bar", "test": "member('KNOWN')" }
+            ]
+        }
+    }`)
+	newConfig := getDiffTestConfig(t, `{
+        "Dhcp4": {
+            "client-classes": [
+                { "name": "bar", "test": "member('UNKNOWN')" },
+                { "name": "foo", "test": "member('ALL')" },
+                { "name": "baz" }
+            ]
+        }
+    }`)
+
+	changes := DiffConfigs(oldConfig, newConfig)
+	require.Len(t, changes, 2)
+	require.Equal(t, ConfigElementClientClass, changes[0].Element)
+	require.Equal(t, ConfigChangeModified, changes[0].Operation)
+	require.Equal(t, "/Dhcp4/client-classes[bar]", changes[0].Path)
+	require.NotNil(t, changes[0].Old)
+	require.NotNil(t, changes[0].New)
+	require.Equal(t, ConfigElementClientClass, changes[1].Element)
+	require.Equal(t, ConfigChangeAdded, changes[1].Operation)
+	require.Equal(t, "/Dhcp4/client-classes[baz]", changes[1].Path)
+}
@@ -69,6 +69,16 @@ type ControlSockets struct {
 	NetConf *ControlSocket
 }
 
+// Structure representing a configuration of the lease or host database
+// backend.
+type Database struct {
+	Type          string
+	Name          string
+	Host          string
+	Port          int64
+	ContactPoints string `mapstructure:"contact-points"`
+}
+
 // Creates new instance from the pointer to the map of interfaces.
 func New(rawCfg *map[string]interface{}) *Map {
 	newCfg := Map(*rawCfg)
@@ -268,6 +278,42 @@ func (c *Map) GetLocalSubnetID(prefix string) int64 {
 	return 0
 }
 
+// Returns the highest ID of the subnets within the Kea configuration,
+// including the subnets belonging to the shared networks. It returns 0
+// if there are no subnets.
+func (c *Map) GetMaxLocalSubnetID() (maxID int64) {
+	rootName, ok := c.GetRootName()
+	if !ok {
+		return 0
+	}
+	subnetParamName := "subnet4"
+	if rootName == "Dhcp6" {
+		subnetParamName = "subnet6"
+	}
+	checkSubnets := func(subnetList []interface{}) {
+		for _, s := range subnetList {
+			if sn, ok := s.(map[string]interface{}); ok {
+				if id, ok := sn["id"].(float64); ok && int64(id) > maxID {
+					maxID = int64(id)
+				}
+			}
+		}
+	}
+	if subnetList, ok := c.GetTopLevelList(subnetParamName); ok {
+		checkSubnets(subnetList)
+	}
+	if networkList, ok := c.GetTopLevelList("shared-networks"); ok {
+		for _, n := range networkList {
+			if network, ok := n.(map[string]interface{}); ok {
+				if subnetList, ok := network[subnetParamName].([]interface{}); ok {
+					checkSubnets(subnetList)
+				}
+			}
+		}
+	}
+	return maxID
+}
+
 // Checks if the mandatory peer parameters are set. It doesn't check if the
 // values are correct.
 func (p Peer) IsSet() bool {
@@ -314,3 +360,31 @@ func (sockets ControlSockets) ConfiguredDaemonNames() (names []string) {
 	}
 	return names
 }
+
+// Parses the lease database configuration of the DHCP server. It returns
+// nil if the lease database is not specified.
+func (c *Map) GetLeaseDatabase() *Database {
+	dbMap, ok := c.GetTopLevelMap("lease-database")
+	if !ok {
+		return nil
+	}
+	parsedDatabase := &Database{}
+	_ = mapstructure.Decode(dbMap, parsedDatabase)
+	return parsedDatabase
+}
+
+// Parses the host database configurations of the DHCP server. Both the
+// hosts-database and hosts-databases parameters are taken into account.
+func (c *Map) GetHostsDatabases() (parsedDatabases []Database) {
+	if dbMap, ok := c.GetTopLevelMap("hosts-database"); ok {
+		parsedDatabase := Database{}
+		_ = mapstructure.Decode(dbMap, &parsedDatabase)
+		parsedDatabases = append(parsedDatabases, parsedDatabase)
+	}
+	if dbList, ok := c.GetTopLevelList("hosts-databases"); ok {
+		var parsedList []Database
+		_ = mapstructure.Decode(dbList, &parsedList)
+		parsedDatabases = append(parsedDatabases, parsedList...)
+	}
+	return parsedDatabases
+}
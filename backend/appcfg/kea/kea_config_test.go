@@ -597,3 +597,75 @@ func TestGetLocalIPv6SubnetID(t *testing.T) {
 	require.EqualValues(t, 345, cfg.GetLocalSubnetID("2001:db8:3::/64"))
 	require.EqualValues(t, 0, cfg.GetLocalSubnetID("2001:db8:4::/64"))
 }
+
+// Test that the highest subnet ID is found among the top level subnets
+// and the subnets in the shared networks.
+func TestGetMaxLocalSubnetID(t *testing.T) {
+	require.EqualValues(t, 678, getTestConfigWithIPv4Subnets(t).GetMaxLocalSubnetID())
+	require.EqualValues(t, 678, getTestConfigWithIPv6Subnets(t).GetMaxLocalSubnetID())
+	require.Zero(t, getTestConfigWithoutHooks(t).GetMaxLocalSubnetID())
+}
+
+// Verifies that the lease database configuration is parsed correctly.
+func TestGetLeaseDatabase(t *testing.T) {
+	configStr := `{
+        "Dhcp4": {
+            "lease-database": {
+                "type": "mysql",
+                "name": "kea",
+                "host": "db.example.org",
+                "port": 3307
+            }
+        }
+    }`
+
+	cfg, err := NewFromJSON(configStr)
+	require.NoError(t, err)
+	require.NotNil(t, cfg)
+
+	database := cfg.GetLeaseDatabase()
+	require.NotNil(t, database)
+	require.Equal(t, "mysql", database.Type)
+	require.Equal(t, "kea", database.Name)
+	require.Equal(t, "db.example.org", database.Host)
+	require.EqualValues(t, 3307, database.Port)
+}
+
+// Verifies that nil is returned when the lease database is not specified.
+func TestGetLeaseDatabaseNone(t *testing.T) {
+	cfg, err := NewFromJSON(`{"Dhcp4": { }}`)
+	require.NoError(t, err)
+	require.NotNil(t, cfg)
+	require.Nil(t, cfg.GetLeaseDatabase())
+}
+
+// Verifies that the host databases configured with hosts-database and
+// hosts-databases are parsed correctly.
+func TestGetHostsDatabases(t *testing.T) {
+	configStr := `{
+        "Dhcp6": {
+            "hosts-database": {
+                "type": "postgresql",
+                "name": "kea-hosts"
+            },
+            "hosts-databases": [
+                {
+                    "type": "cql",
+                    "keyspace": "kea",
+                    "contact-points": "192.0.2.1,192.0.2.2"
+                }
+            ]
+        }
+    }`
+
+	cfg, err := NewFromJSON(configStr)
+	require.NoError(t, err)
+	require.NotNil(t, cfg)
+
+	databases := cfg.GetHostsDatabases()
+	require.Len(t, databases, 2)
+	require.Equal(t, "postgresql", databases[0].Type)
+	require.Equal(t, "kea-hosts", databases[0].Name)
+	require.Equal(t, "cql", databases[1].Type)
+	require.Equal(t, "192.0.2.1,192.0.2.2", databases[1].ContactPoints)
+}
@@ -55,6 +55,15 @@ func runAgent(settings *cli.Context) {
 
 		promBind9Exporter.Start()
 		defer promBind9Exporter.Shutdown()
+
+		// Push the metrics to the remote sink if it is configured.
+		if settings.String("prometheus-push-url") != "" {
+			promPusher := agent.NewPromPusher(settings, promKeaExporter.Registry, promBind9Exporter.Registry)
+			if err := promPusher.Start(); err != nil {
+				log.Fatalf("FATAL error: %+v", err)
+			}
+			defer promPusher.Shutdown()
+		}
 	}
 
 	// Only start the agent service if it's enabled.
@@ -168,6 +177,36 @@ func setupApp() *cli.App {
 				Usage:   "specifies how often the agent collects stats from BIND 9, in seconds",
 				EnvVars: []string{"STORK_AGENT_PROMETHEUS_BIND9_EXPORTER_INTERVAL"},
 			},
+			// Prometheus pusher settings
+			&cli.StringFlag{
+				Name:    "prometheus-push-url",
+				Usage:   "URL of the remote sink to which the collected stats are periodically pushed, e.g. Prometheus remote_write endpoint; pushing is disabled when empty",
+				EnvVars: []string{"STORK_AGENT_PROMETHEUS_PUSH_URL"},
+			},
+			&cli.StringFlag{
+				Name:    "prometheus-push-format",
+				Value:   agent.PushFormatRemoteWrite,
+				Usage:   "the format of the pushed stats: remote-write, influxdb or statsd",
+				EnvVars: []string{"STORK_AGENT_PROMETHEUS_PUSH_FORMAT"},
+			},
+			&cli.IntFlag{
+				Name:    "prometheus-push-interval",
+				Value:   30,
+				Usage:   "specifies how often the agent pushes stats to the remote sink, in seconds",
+				EnvVars: []string{"STORK_AGENT_PROMETHEUS_PUSH_INTERVAL"},
+			},
+			&cli.StringFlag{
+				Name:    "prometheus-push-buffer-dir",
+				Value:   "/var/lib/stork-agent/push-buffer",
+				Usage:   "the directory where the stats are buffered while the remote sink is unavailable; buffering is disabled when empty",
+				EnvVars: []string{"STORK_AGENT_PROMETHEUS_PUSH_BUFFER_DIR"},
+			},
+			&cli.IntFlag{
+				Name:    "prometheus-push-buffer-max-age",
+				Value:   3600,
+				Usage:   "specifies how long the buffered stats are kept before they are discarded, in seconds",
+				EnvVars: []string{"STORK_AGENT_PROMETHEUS_PUSH_BUFFER_MAX_AGE"},
+			},
 			// Registration related settings
 			&cli.StringFlag{
 				Name:    "server-url",
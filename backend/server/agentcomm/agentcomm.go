@@ -139,7 +139,7 @@ type ConnectedAgents interface {
 	ForwardRndcCommand(ctx context.Context, dbApp *dbmodel.App, command string) (*RndcOutput, error)
 	ForwardToNamedStats(ctx context.Context, agentAddress string, agentPort int64, statsAddress string, statsPort int64, path string, statsOutput interface{}) error
 	ForwardToKeaOverHTTP(ctx context.Context, dbApp *dbmodel.App, commands []*keactrl.Command, cmdResponses ...interface{}) (*KeaCmdsResult, error)
-	TailTextFile(ctx context.Context, agentAddress string, agentPort int64, path string, offset int64) ([]string, error)
+	TailTextFile(ctx context.Context, agentAddress string, agentPort int64, path string, offset int64, anchor LogAnchor, filter string, scanLength int64) (*TextFileTail, error)
 }
 
 // Agents management map. It tracks Agents currently connected to the Server.
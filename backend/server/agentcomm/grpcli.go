@@ -36,9 +36,18 @@ const (
 	AccessPointStatistics = "statistics"
 )
 
+// Log target configured for an app. The output is a path to the log
+// file, "syslog" or "stderr".
+type LogTarget struct {
+	Name     string
+	Severity string
+	Output   string
+}
+
 type App struct {
 	Type         string
 	AccessPoints []AccessPoint
+	LogTargets   []LogTarget
 }
 
 // Currently supported types are: "kea" and "bind9".
@@ -47,6 +56,39 @@ const (
 	AppTypeBind9 = "bind9"
 )
 
+// Network interface of the machine with its addresses.
+type NetworkInterface struct {
+	Name            string
+	HardwareAddress string
+	Mtu             int64
+	Flags           []string
+	Addresses       []string
+}
+
+// Usage of the filesystem holding a directory with the apps' data,
+// e.g. Kea lease files or BIND 9 zone files.
+type FilesystemUsage struct {
+	Path        string
+	Kind        string
+	Fstype      string
+	Total       int64
+	Used        int64
+	UsedPercent float64
+	Error       string
+}
+
+// Database backend configured in Kea and its reachability from the
+// machine.
+type DatabaseBackend struct {
+	Kind      string
+	Type      string
+	Host      string
+	Port      int64
+	Name      string
+	Reachable bool
+	Error     string
+}
+
 // State of the machine. It describes multiple properties of the machine like number of CPUs
 // or operating system name and version.
 type State struct {
@@ -67,6 +109,13 @@ type State struct {
 	VirtualizationSystem string
 	VirtualizationRole   string
 	HostID               string
+	Interfaces           []NetworkInterface
+	Filesystems          []FilesystemUsage
+	Databases            []DatabaseBackend
+	AgentTime            time.Time     // agent's wall-clock time, zero if not reported
+	ClockSkew            time.Duration // agent's clock minus server's clock
+	ClockRoundTrip       time.Duration
+	ClockSyncStatus      string
 	LastVisitedAt        time.Time
 	Error                string
 	Apps                 []*App
@@ -102,11 +151,11 @@ func (agents *connectedAgentsData) GetState(ctx context.Context, address string,
 	addrPort := net.JoinHostPort(address, strconv.FormatInt(agentPort, 10))
 
 	// Call agent for version.
-	resp, err := agents.sendAndRecvViaQueue(addrPort, &agentapi.GetStateReq{})
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to get state from agent %s", addrPort)
+	resp := agents.sendAndRecvViaQueueTimed(addrPort, &agentapi.GetStateReq{})
+	if resp.Err != nil {
+		return nil, errors.Wrapf(resp.Err, "failed to get state from agent %s", addrPort)
 	}
-	grpcState := resp.(*agentapi.GetStateRsp)
+	grpcState := resp.Response.(*agentapi.GetStateRsp)
 
 	var apps []*App
 	for _, app := range grpcState.Apps {
@@ -121,9 +170,56 @@ func (agents *connectedAgentsData) GetState(ctx context.Context, address string,
 			})
 		}
 
+		var logTargets []LogTarget
+		for _, target := range app.LogTargets {
+			logTargets = append(logTargets, LogTarget{
+				Name:     target.Name,
+				Severity: target.Severity,
+				Output:   target.Output,
+			})
+		}
+
 		apps = append(apps, &App{
 			Type:         app.Type,
 			AccessPoints: accessPoints,
+			LogTargets:   logTargets,
+		})
+	}
+
+	var interfaces []NetworkInterface
+	for _, iface := range grpcState.Interfaces {
+		interfaces = append(interfaces, NetworkInterface{
+			Name:            iface.Name,
+			HardwareAddress: iface.HardwareAddress,
+			Mtu:             iface.Mtu,
+			Flags:           iface.Flags,
+			Addresses:       iface.Addresses,
+		})
+	}
+
+	var filesystems []FilesystemUsage
+	for _, filesystem := range grpcState.Filesystems {
+		filesystems = append(filesystems, FilesystemUsage{
+			Path:        filesystem.Path,
+			Kind:        filesystem.Kind,
+			Fstype:      filesystem.Fstype,
+			Total:       filesystem.Total,
+			Used:        filesystem.Used,
+			UsedPercent: filesystem.UsedPercent,
+			Error:       filesystem.Error,
+		})
+	}
+
+	var databases []DatabaseBackend
+	for _, database := range grpcState.Databases {
+		databases = append(databases, DatabaseBackend{
+			Kind:      database.Kind,
+			Type:      database.Type,
+			Host:      database.Host,
+			Port:      database.Port,
+			Name:      database.Name,
+			Reachable: database.Reachable,
+			Error:     database.Error,
 		})
 	}
 
@@ -145,14 +241,37 @@ func (agents *connectedAgentsData) GetState(ctx context.Context, address string,
 		VirtualizationSystem: grpcState.VirtualizationSystem,
 		VirtualizationRole:   grpcState.VirtualizationRole,
 		HostID:               grpcState.HostID,
+		Interfaces:           interfaces,
+		Filesystems:          filesystems,
+		Databases:            databases,
+		ClockSyncStatus:      grpcState.ClockSyncStatus,
 		LastVisitedAt:        storkutil.UTCNow(),
 		Error:                grpcState.Error,
 		Apps:                 apps,
 	}
 
+	// Older agents do not report their time.
+	if grpcState.ReceiveTime != 0 && grpcState.TransmitTime != 0 {
+		state.AgentTime = time.Unix(0, grpcState.TransmitTime).UTC()
+		state.ClockSkew, state.ClockRoundTrip = computeClockSkew(resp.SentAt,
+			time.Unix(0, grpcState.ReceiveTime), state.AgentTime, resp.ReceivedAt)
+	}
+
 	return &state, nil
 }
 
+// Computes the skew of the agent's clock relative to the server's clock
+// and the round trip time using the same method as NTP. The sent and
+// received times are taken by the server when the request is sent and
+// when the response is received. The agent received and transmitted
+// times are taken by the agent. The time spent by the agent on
+// processing the request does not affect the result.
+func computeClockSkew(sent, agentReceived, agentTransmitted, received time.Time) (skew, roundTrip time.Duration) {
+	skew = (agentReceived.Sub(sent) + agentTransmitted.Sub(received)) / 2
+	roundTrip = received.Sub(sent) - agentTransmitted.Sub(agentReceived)
+	return skew, roundTrip
+}
+
 type RndcOutput struct {
 	Output string
 	Error  error
@@ -203,7 +322,7 @@ func (agents *connectedAgentsData) ForwardRndcCommand(ctx context.Context, dbApp
 				"rndc":  net.JoinHostPort(ctrlPoint.Address, strconv.FormatInt(ctrlPoint.Port, 10)),
 			}).Warnf("failed to send the following rndc command: %s", command)
 		}
-		agents.EventCenter.AddErrorEvent("cannot connect to agent on {machine}", dbApp.Machine)
+		agents.EventCenter.AddErrorEvent("cannot connect to agent on {machine}", dbApp.Machine, dbmodel.EventCodeAgentUnreachable)
 		return nil, err
 	}
 	response := resp.(*agentapi.ForwardRndcCommandRsp)
@@ -232,7 +351,7 @@ func (agents *connectedAgentsData) ForwardRndcCommand(ctx context.Context, dbApp
 		} else {
 			errStr = fmt.Sprintf("%s", result.Error)
 		}
-		agents.EventCenter.AddErrorEvent("communication with {app} failed", errStr, dbApp)
+		agents.EventCenter.AddErrorEvent("communication with {app} failed", errStr, dbApp, dbmodel.EventCodeAppCommunicationFailed)
 	}
 
 	// Start updating error statistics for this agent and the BIND9 app we've
@@ -428,7 +547,7 @@ func (agents *connectedAgentsData) ForwardToKeaOverHTTP(ctx context.Context, dbA
 			// If this is the first time we failed to communicate with the
 			// agent, let's print the stack trace for debugging purposes.
 			err = errors.WithStack(err)
-			agents.EventCenter.AddErrorEvent("cannot connect to agent on {machine}", err.Error(), dbApp.Machine)
+			agents.EventCenter.AddErrorEvent("cannot connect to agent on {machine}", err.Error(), dbApp.Machine, dbmodel.EventCodeAgentUnreachable)
 		} else {
 			// This is not the first time we can't communicate with the
 			// agent. Let's be brief and say that the communication is
@@ -448,7 +567,7 @@ func (agents *connectedAgentsData) ForwardToKeaOverHTTP(ctx context.Context, dbA
 
 	agent.Stats.CurrentErrors = 0
 	if prevAgentErrorsCnt > 0 {
-		agents.EventCenter.AddWarningEvent("communication with stork agent on {machine} resumed", dbApp.Machine)
+		agents.EventCenter.AddWarningEvent("communication with stork agent on {machine} resumed", dbApp.Machine, dbmodel.EventCodeAgentReachable)
 	}
 
 	fdRsp := resp.(*agentapi.ForwardToKeaOverHTTPRsp)
@@ -608,9 +727,11 @@ func (agents *connectedAgentsData) updateErrorStatsAndRaiseEvents(agent *Agent,
 			}).Warnf("communication failed: %+v", fdReq.KeaRequests)
 			dmn, ok := daemonsMap["ca"]
 			if ok {
-				agents.EventCenter.AddErrorEvent("communication with {daemon} of {app} failed", strings.TrimSpace(caErrorStr), &dmn, dbApp)
+				agents.EventCenter.AddErrorEvent("communication with {daemon} of {app} failed", strings.TrimSpace(caErrorStr), &dmn, dbApp,
+					dbmodel.EventCodeDaemonCommunicationFailed)
 			} else {
-				agents.EventCenter.AddErrorEvent("communication with CA daemon of {app} failed", strings.TrimSpace(caErrorStr), dbApp)
+				agents.EventCenter.AddErrorEvent("communication with CA daemon of {app} failed", strings.TrimSpace(caErrorStr), dbApp,
+					dbmodel.EventCodeDaemonCommunicationFailed, dbmodel.EventPayload{"daemonName": "ca"})
 			}
 		}
 	} else {
@@ -620,9 +741,10 @@ func (agents *connectedAgentsData) updateErrorStatsAndRaiseEvents(agent *Agent,
 		if prevErrorsCA > 0 {
 			dmn, ok := daemonsMap["ca"]
 			if ok {
-				agents.EventCenter.AddWarningEvent("communication with {daemon} of {app} resumed", &dmn, dbApp)
+				agents.EventCenter.AddWarningEvent("communication with {daemon} of {app} resumed", &dmn, dbApp, dbmodel.EventCodeDaemonCommunicationResumed)
 			} else {
-				agents.EventCenter.AddWarningEvent("communication with CA daemon of {app} resumed", dbApp)
+				agents.EventCenter.AddWarningEvent("communication with CA daemon of {app} resumed", dbApp,
+					dbmodel.EventCodeDaemonCommunicationResumed, dbmodel.EventPayload{"daemonName": "ca"})
 			}
 		}
 	}
@@ -645,9 +767,9 @@ func (agents *connectedAgentsData) updateErrorStatsAndRaiseEvents(agent *Agent,
 				if dmn.Name == dmnName {
 					dmn.App = dbApp
 					if currentErrors == 0 {
-						agents.EventCenter.AddWarningEvent("communication with {daemon} of {app} resumed", dmn, dbApp)
+						agents.EventCenter.AddWarningEvent("communication with {daemon} of {app} resumed", dmn, dbApp, dbmodel.EventCodeDaemonCommunicationResumed)
 					} else {
-						agents.EventCenter.AddErrorEvent("communication with {daemon} of {app} failed", dmn, dbApp)
+						agents.EventCenter.AddErrorEvent("communication with {daemon} of {app} failed", dmn, dbApp, dbmodel.EventCodeDaemonCommunicationFailed)
 					}
 					break
 				}
@@ -656,15 +778,53 @@ func (agents *connectedAgentsData) updateErrorStatsAndRaiseEvents(agent *Agent,
 	}
 }
 
-// Get the tail of the remote text file.
-func (agents *connectedAgentsData) TailTextFile(ctx context.Context, agentAddress string, agentPort int64, path string, offset int64) ([]string, error) {
+// Log file or one of its rotated siblings. The ID identifies the file
+// regardless of its renames during the log rotation.
+type LogFile struct {
+	ID         string
+	Path       string
+	Size       int64
+	ModTime    time.Time
+	Compressed bool
+}
+
+// Position in the remote log anchored to the file with the given ID and
+// the offset in its uncompressed contents. It remains valid when new
+// lines are appended to the log or the files are rotated. The zero value
+// denotes the end of the current log file.
+type LogAnchor struct {
+	FileID string
+	Offset int64
+}
+
+// Tail of the remote text file. The Next anchor should be used to fetch
+// the preceding part of the file and its rotated siblings. The Beginning
+// is set when the beginning of the oldest rotated file has been reached.
+type TextFileTail struct {
+	Lines     []string
+	Next      LogAnchor
+	Beginning bool
+	Files     []LogFile
+}
+
+// Get the tail of the remote text file. The offset specifies the maximum
+// length of the tail. The anchor specifies where the tail ends within the
+// file or its rotated siblings. If the filter is specified, only the lines
+// matching this regular expression are returned. The scan length limits
+// the number of bytes scanned by the agent for the matching lines. If it is
+// 0, the agent's default limit is used.
+func (agents *connectedAgentsData) TailTextFile(ctx context.Context, agentAddress string, agentPort int64, path string, offset int64, anchor LogAnchor, filter string, scanLength int64) (*TextFileTail, error) {
 	addrPort := net.JoinHostPort(agentAddress, strconv.FormatInt(agentPort, 10))
 
 	// Get the path to the file and the (seek) info indicating the location
 	// from which the tail should be fetched.
 	req := &agentapi.TailTextFileReq{
-		Path:   path,
-		Offset: offset,
+		Path:         path,
+		Offset:       offset,
+		AnchorFileId: anchor.FileID,
+		AnchorOffset: anchor.Offset,
+		Filter:       filter,
+		ScanLength:   scanLength,
 	}
 
 	// Send the request via queue.
@@ -684,5 +844,22 @@ func (agents *connectedAgentsData) TailTextFile(ctx context.Context, agentAddres
 		return nil, errors.New(response.Status.Message)
 	}
 
-	return response.Lines, nil
+	tail := &TextFileTail{
+		Lines: response.Lines,
+		Next: LogAnchor{
+			FileID: response.NextAnchorFileId,
+			Offset: response.NextAnchorOffset,
+		},
+		Beginning: response.Beginning,
+	}
+	for _, file := range response.Files {
+		tail.Files = append(tail.Files, LogFile{
+			ID:         file.Id,
+			Path:       file.Path,
+			Size:       file.Size,
+			ModTime:    time.Unix(file.ModTime, 0).UTC(),
+			Compressed: file.Compressed,
+		})
+	}
+	return tail, nil
 }
@@ -5,6 +5,7 @@ import (
 	"compress/gzip"
 	"context"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
@@ -79,6 +80,39 @@ func TestGetState(t *testing.T) {
 				Type:         AppTypeKea,
 				AccessPoints: makeAccessPoint(AccessPointControl, "1.2.3.4", "", 1234),
 			},
+			{
+				Type:         AppTypeBind9,
+				AccessPoints: makeAccessPoint(AccessPointControl, "1.2.3.4", "abcd", 953),
+				LogTargets: []*agentapi.LogTarget{
+					{
+						Name:     "default_log",
+						Severity: "info",
+						Output:   "/var/log/named.log",
+					},
+				},
+			},
+		},
+		Interfaces: []*agentapi.NetworkInterface{
+			{
+				Name:      "eth0",
+				Addresses: []string{"192.0.2.1/24"},
+			},
+		},
+		Filesystems: []*agentapi.FilesystemUsage{
+			{
+				Path:        "/var/lib/kea",
+				Kind:        "kea-leases",
+				UsedPercent: 42.5,
+			},
+		},
+		Databases: []*agentapi.DatabaseBackend{
+			{
+				Kind:  "lease-database",
+				Type:  "mysql",
+				Host:  "localhost",
+				Port:  3306,
+				Error: "connection refused",
+			},
 		},
 	}
 	mockAgentClient.EXPECT().GetState(gomock.Any(), gomock.Any()).
@@ -90,6 +124,69 @@ func TestGetState(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, expVer, state.AgentVersion)
 	require.Equal(t, AppTypeKea, state.Apps[0].Type)
+	require.Empty(t, state.Apps[0].LogTargets)
+	require.Equal(t, AppTypeBind9, state.Apps[1].Type)
+	require.Equal(t, []LogTarget{{Name: "default_log", Severity: "info", Output: "/var/log/named.log"}}, state.Apps[1].LogTargets)
+
+	require.Len(t, state.Interfaces, 1)
+	require.Equal(t, "eth0", state.Interfaces[0].Name)
+	require.Equal(t, []string{"192.0.2.1/24"}, state.Interfaces[0].Addresses)
+	require.Len(t, state.Filesystems, 1)
+	require.Equal(t, "/var/lib/kea", state.Filesystems[0].Path)
+	require.Equal(t, 42.5, state.Filesystems[0].UsedPercent)
+	require.Len(t, state.Databases, 1)
+	require.Equal(t, "mysql", state.Databases[0].Type)
+	require.False(t, state.Databases[0].Reachable)
+	require.Equal(t, "connection refused", state.Databases[0].Error)
+
+	// the agent did not report its time
+	require.True(t, state.AgentTime.IsZero())
+	require.Zero(t, state.ClockSkew)
+}
+
+// Test that the clock skew of the agent is computed from the times
+// reported by the agent.
+func TestGetStateClockSkew(t *testing.T) {
+	mockAgentClient, agents, teardown := setupGrpcliTestCase(t)
+	defer teardown()
+
+	// The agent's clock is one hour ahead.
+	agentNow := time.Now().Add(time.Hour)
+	rsp := agentapi.GetStateRsp{
+		ReceiveTime:     agentNow.UnixNano(),
+		TransmitTime:    agentNow.UnixNano(),
+		ClockSyncStatus: "unsynchronized",
+	}
+	mockAgentClient.EXPECT().GetState(gomock.Any(), gomock.Any()).
+		Return(&rsp, nil)
+
+	ctx := context.Background()
+	state, err := agents.GetState(ctx, "127.0.0.1", 8080)
+	require.NoError(t, err)
+	require.Equal(t, "unsynchronized", state.ClockSyncStatus)
+	require.False(t, state.AgentTime.IsZero())
+	require.InDelta(t, time.Hour.Seconds(), state.ClockSkew.Seconds(), 1)
+	require.Less(t, int64(state.ClockRoundTrip), int64(time.Second))
+}
+
+// Test the clock skew and round trip time computation.
+func TestComputeClockSkew(t *testing.T) {
+	sent := time.Unix(1000, 0)
+	// The request takes 1s to reach the agent, the agent's clock is 10s
+	// ahead, the agent processes the request for 3s and the response takes
+	// 1s to reach the server.
+	agentReceived := time.Unix(1011, 0)
+	agentTransmitted := time.Unix(1014, 0)
+	received := time.Unix(1005, 0)
+
+	skew, roundTrip := computeClockSkew(sent, agentReceived, agentTransmitted, received)
+	require.Equal(t, 10*time.Second, skew)
+	require.Equal(t, 2*time.Second, roundTrip)
+
+	// The agent's clock is behind.
+	skew, roundTrip = computeClockSkew(sent, time.Unix(991, 0), time.Unix(991, 0), time.Unix(1002, 0))
+	require.Equal(t, -10*time.Second, skew)
+	require.Equal(t, 2*time.Second, roundTrip)
 }
 
 // Helper function for gzipping json text to bytes array.
@@ -510,18 +607,50 @@ func TestTailTextFile(t *testing.T) {
 			"Text returned by",
 			"mock agent client",
 		},
+		NextAnchorFileId: "2049:12",
+		NextAnchorOffset: 7,
+		Beginning:        false,
+		Files: []*agentapi.LogFile{
+			{
+				Id:      "2049:12",
+				Path:    "/tmp/log.txt",
+				Size:    35,
+				ModTime: 1602500000,
+			},
+			{
+				Path:       "/tmp/log.txt.1.gz",
+				Size:       1000,
+				ModTime:    1602400000,
+				Compressed: true,
+			},
+		},
 	}
 
-	mockAgentClient.EXPECT().TailTextFile(gomock.Any(), gomock.Any()).
-		Return(&rsp, nil)
+	mockAgentClient.EXPECT().TailTextFile(gomock.Any(), &agentapi.TailTextFileReq{
+		Path:         "/tmp/log.txt",
+		Offset:       2,
+		AnchorFileId: "2049:12",
+		AnchorOffset: 10,
+		Filter:       "mock",
+		ScanLength:   100,
+	}).Return(&rsp, nil)
 
 	ctx := context.Background()
-	tail, err := agents.TailTextFile(ctx, "127.0.0.1", 8080, "/tmp/log.txt", 2)
+	tail, err := agents.TailTextFile(ctx, "127.0.0.1", 8080, "/tmp/log.txt", 2, LogAnchor{FileID: "2049:12", Offset: 10}, "mock", 100)
 	require.NoError(t, err)
-	require.Len(t, tail, 2)
-
-	require.Equal(t, "Text returned by", tail[0])
-	require.Equal(t, "mock agent client", tail[1])
+	require.Len(t, tail.Lines, 2)
+
+	require.Equal(t, "Text returned by", tail.Lines[0])
+	require.Equal(t, "mock agent client", tail.Lines[1])
+	require.Equal(t, LogAnchor{FileID: "2049:12", Offset: 7}, tail.Next)
+	require.False(t, tail.Beginning)
+	require.Len(t, tail.Files, 2)
+	require.Equal(t, "2049:12", tail.Files[0].ID)
+	require.Equal(t, "/tmp/log.txt", tail.Files[0].Path)
+	require.EqualValues(t, 35, tail.Files[0].Size)
+	require.EqualValues(t, 1602500000, tail.Files[0].ModTime.Unix())
+	require.False(t, tail.Files[0].Compressed)
+	require.True(t, tail.Files[1].Compressed)
 }
 
 // Check MakeAccessPoint.
@@ -2,6 +2,7 @@ package agentcomm
 
 import (
 	"context"
+	"time"
 
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
@@ -30,8 +31,10 @@ func (agents *connectedAgentsData) communicationLoop() {
 }
 
 type channelResp struct {
-	Response interface{}
-	Err      error
+	Response   interface{}
+	Err        error
+	SentAt     time.Time // time when the request was sent to the agent
+	ReceivedAt time.Time // time when the response was received from the agent
 }
 
 type commLoopReq struct {
@@ -42,11 +45,19 @@ type commLoopReq struct {
 
 // Send a request to agent and receive response using channel to communication loop.
 func (agents *connectedAgentsData) sendAndRecvViaQueue(agentAddr string, in interface{}) (interface{}, error) {
+	respErr := agents.sendAndRecvViaQueueTimed(agentAddr, in)
+	return respErr.Response, respErr.Err
+}
+
+// Send a request to agent and receive response using channel to communication
+// loop. Besides the response, the returned structure holds the times when the
+// request was sent to the agent and when the response was received. The time
+// spent by the request in the queue is not included.
+func (agents *connectedAgentsData) sendAndRecvViaQueueTimed(agentAddr string, in interface{}) *channelResp {
 	respChan := make(chan *channelResp)
 	req := &commLoopReq{AgentAddr: agentAddr, ReqData: in, RespChan: respChan}
 	agents.CommLoopReqs <- req
-	respErr := <-respChan
-	return respErr.Response, respErr.Err
+	return <-respChan
 }
 
 // Pass given request directly to an agent.
@@ -85,6 +96,7 @@ func (agents *connectedAgentsData) handleRequest(req *commLoopReq) {
 
 	// do call
 	ctx := context.Background()
+	sentAt := time.Now()
 	response, err := doCall(ctx, agent, req.ReqData)
 	if err != nil {
 		// GetConnectedAgent remembers the grpc connection so it might
@@ -103,6 +115,7 @@ func (agents *connectedAgentsData) handleRequest(req *commLoopReq) {
 		}
 
 		// do call once again
+		sentAt = time.Now()
 		response, err2 = doCall(ctx, agent, req.ReqData)
 		if err2 != nil {
 			log.WithFields(log.Fields{
@@ -116,5 +129,5 @@ func (agents *connectedAgentsData) handleRequest(req *commLoopReq) {
 		}
 	}
 
-	req.RespChan <- &channelResp{Response: response, Err: nil}
+	req.RespChan <- &channelResp{Response: response, Err: nil, SentAt: sentAt, ReceivedAt: time.Now()}
 }
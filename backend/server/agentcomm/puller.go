@@ -1,9 +1,15 @@
 package agentcomm
 
 import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 
 	dbops "isc.org/stork/server/database"
@@ -27,10 +33,65 @@ type PeriodicPuller struct {
 	Done                chan bool
 	Wg                  *sync.WaitGroup
 	mutex               *sync.Mutex
+	maxWorkers          int
+	pullTimeout         time.Duration
+	maxJitter           time.Duration
+	triggers            chan int64
+	triggeredAppID      int64
+	status              PullerStatus
+	targets             map[string]*PullTargetStatus
+	afterPullHooks      []func()
+}
+
+// Result of the last pull from an app or machine.
+type PullTargetStatus struct {
+	// Identifies the app or machine, e.g. "app 1".
+	Key          string
+	LastPulledAt time.Time
+	// Empty if the last pull succeeded.
+	LastError string
+}
+
+// Status of the periodic puller.
+type PullerStatus struct {
+	// Puller identifier derived from the interval setting name, e.g.
+	// kea_stats.
+	ID       string
+	Name     string
+	Interval int64
+	Active   bool
+	// Indicates that the puller action is currently in progress.
+	Running       bool
+	LastStartedAt time.Time
+	LastDuration  time.Duration
+	LastSucceeded int
+	LastFailed    int
+	// Error returned by the last puller action.
+	LastError string
+	// Zero if the puller is paused or running.
+	NextRunAt time.Time
+	// Results of the last pulls from the apps or machines sorted by key.
+	Targets []PullTargetStatus
 }
 
 const InactiveInterval int64 = 60
 
+// Default maximum number of apps (or machines) pulled concurrently by
+// a single puller.
+const DefaultMaxWorkers = 4
+
+// Default maximum time of pulling the data from a single app (or machine).
+const DefaultPullTimeout = 60 * time.Second
+
+// Default maximum random delay of the puller action. The delay spreads
+// the actions of the pullers with the same interval over time, so they
+// don't contact the same agents at once. The delay never exceeds a quarter
+// of the puller interval.
+const DefaultMaxJitter = 5 * time.Second
+
+// Maximum number of pending on-demand runs of the puller action.
+const maxPendingTriggers = 16
+
 // Creates an instance of a new periodic puller. The periodic puller offers a mechanism
 // to periodically trigger an action. This action is supplied as a function instance.
 // This function is executed within a goroutine periodically according to the timer
@@ -67,7 +128,13 @@ func NewPeriodicPuller(db *dbops.PgDB, agents ConnectedAgents, pullerName, inter
 		Done:                make(chan bool),
 		Wg:                  &sync.WaitGroup{},
 		mutex:               &sync.Mutex{},
+		maxWorkers:          DefaultMaxWorkers,
+		pullTimeout:         DefaultPullTimeout,
+		maxJitter:           DefaultMaxJitter,
+		triggers:            make(chan int64, maxPendingTriggers),
+		targets:             make(map[string]*PullTargetStatus),
 	}
+	periodicPuller.status.NextRunAt = time.Now().Add(time.Duration(interval) * time.Second)
 
 	periodicPuller.Wg.Add(1)
 	go periodicPuller.pullerLoop()
@@ -99,6 +166,7 @@ func (puller *PeriodicPuller) Pause() {
 	defer puller.mutex.Unlock()
 	puller.Ticker.Stop()
 	puller.pauseCount++
+	puller.status.NextRunAt = time.Time{}
 }
 
 // Checks if the puller is currently paused.
@@ -134,6 +202,7 @@ func (puller *PeriodicPuller) unpause(lock bool, interval ...int64) {
 		}
 		// Reschedule the timer.
 		puller.Ticker.Reset(time.Duration(puller.Interval) * time.Second)
+		puller.status.NextRunAt = time.Now().Add(time.Duration(puller.Interval) * time.Second)
 	}
 }
 
@@ -154,6 +223,255 @@ func (puller *PeriodicPuller) Reset(interval int64) {
 	puller.unpause(false, interval)
 }
 
+// Sets the maximum number of apps (or machines) pulled concurrently. The
+// pullers which update the data shared between the apps, e.g. the HA
+// services, should pull one app at a time.
+func (puller *PeriodicPuller) SetMaxWorkers(maxWorkers int) {
+	puller.mutex.Lock()
+	defer puller.mutex.Unlock()
+	if maxWorkers < 1 {
+		maxWorkers = 1
+	}
+	puller.maxWorkers = maxWorkers
+}
+
+// Sets the maximum time of pulling the data from a single app (or machine).
+// When the time elapses the pull is reported as failed. The pull is not
+// interrupted unless it respects the context's deadline.
+func (puller *PeriodicPuller) SetPullTimeout(timeout time.Duration) {
+	puller.mutex.Lock()
+	defer puller.mutex.Unlock()
+	puller.pullTimeout = timeout
+}
+
+// Adds the function called after each run of the puller action, e.g. to
+// evaluate the alert rules against the pulled data.
+func (puller *PeriodicPuller) AddAfterPullHook(hook func()) {
+	puller.mutex.Lock()
+	defer puller.mutex.Unlock()
+	puller.afterPullHooks = append(puller.afterPullHooks, hook)
+}
+
+// Sets the maximum random delay of the puller action. Zero disables the delay.
+func (puller *PeriodicPuller) SetMaxJitter(maxJitter time.Duration) {
+	puller.mutex.Lock()
+	defer puller.mutex.Unlock()
+	puller.maxJitter = maxJitter
+}
+
+// Returns the random delay of the next puller action.
+func (puller *PeriodicPuller) getJitter() time.Duration {
+	puller.mutex.Lock()
+	defer puller.mutex.Unlock()
+	maxJitter := puller.maxJitter
+	if limit := time.Duration(puller.Interval) * time.Second / 4; maxJitter > limit {
+		maxJitter = limit
+	}
+	if maxJitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(maxJitter)))
+}
+
+// Pulls the data from multiple apps or machines using a bounded pool of
+// workers. The keys uniquely identify the pulled apps or machines and
+// the pull function is called with the index of the key. The pull
+// function is given a context with the deadline set to the pull timeout.
+// The pull exceeding the timeout is reported as failed immediately, but
+// its worker is released when the pull returns, so the number of
+// concurrent pulls never exceeds the maximum number of workers and the
+// function returns when all pulls have completed. It returns the number
+// of successful pulls and the last encountered error.
+func (puller *PeriodicPuller) PullEach(keys []string, pull func(ctx context.Context, i int) error) (int, error) {
+	puller.mutex.Lock()
+	maxWorkers := puller.maxWorkers
+	timeout := puller.pullTimeout
+	// Forget the apps and machines which no longer exist.
+	if puller.triggeredAppID == 0 {
+		current := make(map[string]bool)
+		for _, key := range keys {
+			current[key] = true
+		}
+		for key := range puller.targets {
+			if !current[key] {
+				delete(puller.targets, key)
+			}
+		}
+	}
+	puller.mutex.Unlock()
+
+	var (
+		okCnt       int
+		lastErr     error
+		resultMutex sync.Mutex
+		wg          sync.WaitGroup
+	)
+	workers := make(chan struct{}, maxWorkers)
+	for i := range keys {
+		key := keys[i]
+		workers <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-workers }()
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			// The pull runs in a separate goroutine, so the timeout can
+			// be reported before the pull returns.
+			result := make(chan error, 1)
+			go func() {
+				result <- pull(ctx, i)
+			}()
+
+			var err error
+			select {
+			case err = <-result:
+				puller.recordTargetStatus(key, err)
+			case <-ctx.Done():
+				err = errors.Errorf("pulling data from %s timed out after %s", key, timeout)
+				puller.recordTargetStatus(key, err)
+				// Hold the worker until the pull returns, so it doesn't
+				// run concurrently with the next pulls and completes
+				// before the after pull hooks are run.
+				<-result
+			}
+
+			resultMutex.Lock()
+			defer resultMutex.Unlock()
+			if err != nil {
+				lastErr = err
+				log.Errorf("error occurred while pulling data from %s: %+v", key, err)
+			} else {
+				okCnt++
+			}
+		}(i)
+	}
+	wg.Wait()
+	return okCnt, lastErr
+}
+
+// Records the result of the pull from an app or machine in the puller status.
+func (puller *PeriodicPuller) recordTargetStatus(key string, err error) {
+	puller.mutex.Lock()
+	defer puller.mutex.Unlock()
+	target := &PullTargetStatus{
+		Key:          key,
+		LastPulledAt: time.Now(),
+	}
+	if err != nil {
+		target.LastError = err.Error()
+		puller.status.LastFailed++
+	} else {
+		puller.status.LastSucceeded++
+	}
+	puller.targets[key] = target
+}
+
+// Pulls the data from the apps using a bounded pool of workers. See
+// PullEach for details. If the puller action has been triggered for
+// a selected app, the other apps are skipped.
+func (puller *PeriodicPuller) PullApps(apps []dbmodel.App, pull func(ctx context.Context, app *dbmodel.App) error) (int, error) {
+	appID := puller.TriggeredAppID()
+	var (
+		keys    []string
+		indexes []int
+	)
+	for i := range apps {
+		if appID != 0 && apps[i].ID != appID {
+			continue
+		}
+		keys = append(keys, fmt.Sprintf("app %d", apps[i].ID))
+		indexes = append(indexes, i)
+	}
+	return puller.PullEach(keys, func(ctx context.Context, i int) error {
+		return pull(ctx, &apps[indexes[i]])
+	})
+}
+
+// Returns the puller identifier derived from the interval setting name,
+// e.g. kea_stats.
+func (puller *PeriodicPuller) GetID() string {
+	return strings.TrimSuffix(puller.intervalSettingName, "_puller_interval")
+}
+
+// Returns the ID of the app for which the current puller action has been
+// triggered or 0 if the action pulls the data from all apps.
+func (puller *PeriodicPuller) TriggeredAppID() int64 {
+	puller.mutex.Lock()
+	defer puller.mutex.Unlock()
+	return puller.triggeredAppID
+}
+
+// Schedules the immediate run of the puller action. If the appID is
+// non-zero only the data of this app are pulled. The action is run even
+// if the puller is disabled. It returns an error if there are too many
+// pending runs.
+func (puller *PeriodicPuller) Trigger(appID int64) error {
+	select {
+	case puller.triggers <- appID:
+		return nil
+	default:
+		return errors.Errorf("too many pending runs of the %s Puller", puller.pullerName)
+	}
+}
+
+// Returns the current status of the puller.
+func (puller *PeriodicPuller) GetStatus() *PullerStatus {
+	puller.mutex.Lock()
+	defer puller.mutex.Unlock()
+	status := puller.status
+	status.ID = puller.GetID()
+	status.Name = puller.pullerName
+	status.Interval = puller.Interval
+	status.Active = puller.Active
+	if !status.Active {
+		status.NextRunAt = time.Time{}
+	}
+	status.Targets = []PullTargetStatus{}
+	for _, target := range puller.targets {
+		status.Targets = append(status.Targets, *target)
+	}
+	sort.Slice(status.Targets, func(i, j int) bool {
+		return status.Targets[i].Key < status.Targets[j].Key
+	})
+	return &status
+}
+
+// Runs the puller action and records its status. The non-zero appID
+// restricts the action to the given app.
+func (puller *PeriodicPuller) runPullFunc(appID int64) {
+	puller.mutex.Lock()
+	puller.triggeredAppID = appID
+	puller.status.Running = true
+	puller.status.LastStartedAt = time.Now()
+	puller.status.LastSucceeded = 0
+	puller.status.LastFailed = 0
+	puller.mutex.Unlock()
+
+	_, err := puller.pullFunc()
+
+	puller.mutex.Lock()
+	puller.triggeredAppID = 0
+	puller.status.Running = false
+	puller.status.LastDuration = time.Since(puller.status.LastStartedAt)
+	puller.status.LastError = ""
+	if err != nil {
+		puller.status.LastError = err.Error()
+	}
+	hooks := puller.afterPullHooks
+	puller.mutex.Unlock()
+
+	for _, hook := range hooks {
+		hook()
+	}
+
+	if err != nil {
+		log.Errorf("errors were encountered while pulling data from apps: %+v", err)
+	}
+}
+
 // This function controls the timing of the function execution and captures the
 // termination signal.
 func (puller *PeriodicPuller) pullerLoop() {
@@ -166,12 +484,21 @@ func (puller *PeriodicPuller) pullerLoop() {
 				// Temporarily stop the puller while running the external action.
 				// It will be resumed when the action ends.
 				puller.Pause()
-				_, err := puller.pullFunc()
-				puller.Unpause()
-				if err != nil {
-					log.Errorf("errors were encountered while pulling data from apps: %+v", err)
+				// Delay the action randomly to avoid contacting the agents
+				// by all pullers at once.
+				select {
+				case <-time.After(puller.getJitter()):
+				case <-puller.Done:
+					return
 				}
+				puller.runPullFunc(0)
+				puller.Unpause()
 			}
+		// run the action on demand
+		case appID := <-puller.triggers:
+			puller.Pause()
+			puller.runPullFunc(appID)
+			puller.Unpause()
 		// wait for done signal from shutdown function
 		case <-puller.Done:
 			// Make sure this function is never called again.
@@ -193,11 +520,15 @@ func (puller *PeriodicPuller) pullerLoop() {
 				if pullerInterval != InactiveInterval {
 					puller.Reset(InactiveInterval)
 				}
+				puller.mutex.Lock()
 				puller.Active = false
+				puller.mutex.Unlock()
 			} else if interval > 0 && interval != pullerInterval {
 				// if puller interval is changed and is not 0 (disabled)
 				puller.Reset(interval)
+				puller.mutex.Lock()
 				puller.Active = true
+				puller.mutex.Unlock()
 			}
 		}
 	}
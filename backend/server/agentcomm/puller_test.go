@@ -1,7 +1,11 @@
 package agentcomm
 
 import (
+	"context"
+	"fmt"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -161,3 +165,304 @@ func TestPauseAndUnapuseOrReset(t *testing.T) {
 		})
 	}
 }
+
+// Creates the puller which doesn't run the periodic action. It is
+// sufficient to test pulling the data from multiple apps.
+func newTestPeriodicPuller(maxWorkers int, timeout time.Duration) *PeriodicPuller {
+	return &PeriodicPuller{
+		pullerName:  "Test",
+		Interval:    1,
+		mutex:       &sync.Mutex{},
+		maxWorkers:  maxWorkers,
+		pullTimeout: timeout,
+		maxJitter:   DefaultMaxJitter,
+		triggers:    make(chan int64, maxPendingTriggers),
+		targets:     make(map[string]*PullTargetStatus),
+	}
+}
+
+// Test that the number of concurrent pulls is limited by the number
+// of workers.
+func TestPullEachMaxWorkers(t *testing.T) {
+	puller := newTestPeriodicPuller(3, time.Minute)
+
+	var keys []string
+	for i := 0; i < 10; i++ {
+		keys = append(keys, fmt.Sprintf("app %d", i))
+	}
+	var current, highest int32
+	pulled := make([]bool, len(keys))
+	okCnt, err := puller.PullEach(keys, func(ctx context.Context, i int) error {
+		n := atomic.AddInt32(&current, 1)
+		for {
+			h := atomic.LoadInt32(&highest)
+			if n <= h || atomic.CompareAndSwapInt32(&highest, h, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&current, -1)
+		pulled[i] = true
+		if i == 5 {
+			return fmt.Errorf("failed to pull")
+		}
+		return nil
+	})
+	require.EqualError(t, err, "failed to pull")
+	require.Equal(t, 9, okCnt)
+	require.LessOrEqual(t, highest, int32(3))
+	require.Greater(t, highest, int32(1))
+	for i := range pulled {
+		require.True(t, pulled[i])
+	}
+
+	// With one worker the pulls are sequential.
+	puller.SetMaxWorkers(0)
+	highest = 0
+	okCnt, err = puller.PullEach(keys, func(ctx context.Context, i int) error {
+		n := atomic.AddInt32(&current, 1)
+		if n > atomic.LoadInt32(&highest) {
+			atomic.StoreInt32(&highest, n)
+		}
+		atomic.AddInt32(&current, -1)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 10, okCnt)
+	require.EqualValues(t, 1, highest)
+}
+
+// Test that the pull exceeding the timeout is reported as failed before
+// it returns and that the puller waits for it.
+func TestPullEachTimeout(t *testing.T) {
+	puller := newTestPeriodicPuller(2, 50*time.Millisecond)
+	keys := []string{"app 1", "app 2"}
+
+	unblock := make(chan bool)
+	var returned int32
+	done := make(chan bool)
+	var (
+		okCnt int
+		err   error
+	)
+	go func() {
+		okCnt, err = puller.PullEach(keys, func(ctx context.Context, i int) error {
+			if i == 0 {
+				<-unblock
+				atomic.StoreInt32(&returned, 1)
+			}
+			return nil
+		})
+		close(done)
+	}()
+
+	// The timeout is recorded while the pull is still running.
+	require.Eventually(t, func() bool {
+		puller.mutex.Lock()
+		defer puller.mutex.Unlock()
+		target, ok := puller.targets["app 1"]
+		return ok && strings.Contains(target.LastError, "pulling data from app 1 timed out")
+	}, time.Second, 10*time.Millisecond)
+	select {
+	case <-done:
+		require.Fail(t, "puller didn't wait for the pull exceeding the timeout")
+	default:
+	}
+
+	unblock <- true
+	<-done
+	require.EqualValues(t, 1, atomic.LoadInt32(&returned))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "pulling data from app 1 timed out")
+	require.Equal(t, 1, okCnt)
+}
+
+// Test that the pull exceeding the timeout doesn't run concurrently with
+// the next pull when the puller has a single worker.
+func TestPullEachTimeoutSingleWorker(t *testing.T) {
+	puller := newTestPeriodicPuller(1, 50*time.Millisecond)
+	keys := []string{"app 1", "app 2"}
+
+	var current, highest int32
+	okCnt, err := puller.PullEach(keys, func(ctx context.Context, i int) error {
+		n := atomic.AddInt32(&current, 1)
+		if n > atomic.LoadInt32(&highest) {
+			atomic.StoreInt32(&highest, n)
+		}
+		if i == 0 {
+			// Outlive the timeout ignoring the context.
+			time.Sleep(150 * time.Millisecond)
+		}
+		atomic.AddInt32(&current, -1)
+		return nil
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "pulling data from app 1 timed out")
+	require.Equal(t, 1, okCnt)
+	require.EqualValues(t, 1, highest)
+}
+
+// Test that the pull function is given the context with a deadline.
+func TestPullAppsContextDeadline(t *testing.T) {
+	puller := newTestPeriodicPuller(DefaultMaxWorkers, time.Minute)
+	apps := []dbmodel.App{{ID: 1}, {ID: 2}}
+	var mutex sync.Mutex
+	var ids []int64
+	okCnt, err := puller.PullApps(apps, func(ctx context.Context, app *dbmodel.App) error {
+		if _, ok := ctx.Deadline(); !ok {
+			return fmt.Errorf("no deadline")
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		ids = append(ids, app.ID)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, okCnt)
+	require.ElementsMatch(t, []int64{1, 2}, ids)
+}
+
+// Test that the random delay of the puller action doesn't exceed the
+// configured maximum nor a quarter of the interval.
+func TestGetJitter(t *testing.T) {
+	puller := newTestPeriodicPuller(1, time.Minute)
+	for i := 0; i < 100; i++ {
+		require.Less(t, int64(puller.getJitter()), int64(250*time.Millisecond))
+	}
+
+	puller.Interval = 3600
+	puller.SetMaxJitter(10 * time.Millisecond)
+	for i := 0; i < 100; i++ {
+		require.Less(t, int64(puller.getJitter()), int64(10*time.Millisecond))
+	}
+
+	puller.SetMaxJitter(0)
+	require.Zero(t, puller.getJitter())
+}
+
+// Test that the puller records the status of its actions.
+func TestPullerStatus(t *testing.T) {
+	puller := newTestPeriodicPuller(2, time.Minute)
+	puller.intervalSettingName = "kea_stats_puller_interval"
+	puller.Active = true
+
+	apps := []dbmodel.App{{ID: 1}, {ID: 2}, {ID: 3}}
+	var pulledIDs []int64
+	var mutex sync.Mutex
+	puller.pullFunc = func() (int, error) {
+		return puller.PullApps(apps, func(ctx context.Context, app *dbmodel.App) error {
+			mutex.Lock()
+			pulledIDs = append(pulledIDs, app.ID)
+			mutex.Unlock()
+			if app.ID == 2 {
+				return fmt.Errorf("app 2 unreachable")
+			}
+			return nil
+		})
+	}
+
+	status := puller.GetStatus()
+	require.Equal(t, "kea_stats", status.ID)
+	require.Equal(t, "Test", status.Name)
+	require.True(t, status.LastStartedAt.IsZero())
+	require.Empty(t, status.Targets)
+
+	puller.runPullFunc(0)
+	status = puller.GetStatus()
+	require.False(t, status.Running)
+	require.False(t, status.LastStartedAt.IsZero())
+	require.Equal(t, 2, status.LastSucceeded)
+	require.Equal(t, 1, status.LastFailed)
+	require.Equal(t, "app 2 unreachable", status.LastError)
+	require.Len(t, status.Targets, 3)
+	require.Equal(t, "app 1", status.Targets[0].Key)
+	require.Empty(t, status.Targets[0].LastError)
+	require.Equal(t, "app 2", status.Targets[1].Key)
+	require.Equal(t, "app 2 unreachable", status.Targets[1].LastError)
+	require.ElementsMatch(t, []int64{1, 2, 3}, pulledIDs)
+
+	// Pull the selected app only.
+	pulledIDs = nil
+	puller.runPullFunc(3)
+	status = puller.GetStatus()
+	require.Equal(t, []int64{3}, pulledIDs)
+	require.Equal(t, 1, status.LastSucceeded)
+	require.Zero(t, status.LastFailed)
+	require.Empty(t, status.LastError)
+	require.Len(t, status.Targets, 3)
+	require.Zero(t, puller.TriggeredAppID())
+
+	// The targets which no longer exist are removed.
+	apps = apps[:1]
+	puller.runPullFunc(0)
+	status = puller.GetStatus()
+	require.Len(t, status.Targets, 1)
+	require.Equal(t, "app 1", status.Targets[0].Key)
+
+	// The next run time is not reported for the disabled puller.
+	puller.status.NextRunAt = time.Now()
+	require.False(t, puller.GetStatus().NextRunAt.IsZero())
+	puller.Active = false
+	require.True(t, puller.GetStatus().NextRunAt.IsZero())
+}
+
+// Test that the number of pending on-demand runs is limited.
+func TestPullerTriggerLimit(t *testing.T) {
+	puller := newTestPeriodicPuller(1, time.Minute)
+	for i := 0; i < maxPendingTriggers; i++ {
+		require.NoError(t, puller.Trigger(int64(i)))
+	}
+	require.Error(t, puller.Trigger(0))
+	require.EqualValues(t, 0, <-puller.triggers)
+	require.NoError(t, puller.Trigger(0))
+}
+
+// Test that the puller action is run on demand even if the puller is
+// disabled.
+func TestPullerTrigger(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	err := dbmodel.InitializeSettings(db)
+	require.NoError(t, err)
+	err = dbmodel.SetSettingInt(db, "kea_hosts_puller_interval", 0)
+	require.NoError(t, err)
+
+	triggered := make(chan int64, 1)
+	var puller *PeriodicPuller
+	puller, err = NewPeriodicPuller(db, nil, "Test", "kea_hosts_puller_interval", func() (int, error) {
+		triggered <- puller.TriggeredAppID()
+		return 0, nil
+	})
+	require.NoError(t, err)
+	defer puller.Shutdown()
+
+	require.NoError(t, puller.Trigger(5))
+	select {
+	case appID := <-triggered:
+		require.EqualValues(t, 5, appID)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "puller action has not been triggered")
+	}
+}
+
+// Test that the hooks are called after each run of the puller action,
+// including the failed runs.
+func TestPullerAfterPullHooks(t *testing.T) {
+	puller := newTestPeriodicPuller(1, time.Minute)
+	puller.pullFunc = func() (int, error) {
+		return 0, fmt.Errorf("pull failed")
+	}
+	calls := 0
+	puller.AddAfterPullHook(func() {
+		calls++
+	})
+	puller.AddAfterPullHook(func() {
+		calls += 10
+	})
+
+	puller.runPullFunc(0)
+	require.Equal(t, 11, calls)
+	puller.runPullFunc(0)
+	require.Equal(t, 22, calls)
+}
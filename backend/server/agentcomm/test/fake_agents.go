@@ -27,6 +27,10 @@ type FakeAgents struct {
 
 	MachineState   *agentcomm.State
 	GetStateCalled bool
+
+	TextFileLines     []string
+	RecordedFilter    string
+	RecordedLogAnchor agentcomm.LogAnchor
 }
 
 // mockRndcOutput returns some mocked named response.
@@ -180,6 +184,24 @@ func (fa *FakeAgents) ForwardRndcCommand(ctx context.Context, dbApp *dbmodel.App
 }
 
 // Mimics tailing text file.
-func (fa *FakeAgents) TailTextFile(ctx context.Context, agentAddress string, agentPort int64, path string, offset int64) ([]string, error) {
-	return []string{"lorem ipsum"}, nil
+func (fa *FakeAgents) TailTextFile(ctx context.Context, agentAddress string, agentPort int64, path string, offset int64, anchor agentcomm.LogAnchor, filter string, scanLength int64) (*agentcomm.TextFileTail, error) {
+	fa.RecordedFilter = filter
+	fa.RecordedLogAnchor = anchor
+	lines := fa.TextFileLines
+	if lines == nil {
+		lines = []string{"lorem ipsum"}
+	}
+	tail := &agentcomm.TextFileTail{
+		Lines:     lines,
+		Next:      agentcomm.LogAnchor{FileID: "1"},
+		Beginning: true,
+		Files: []agentcomm.LogFile{
+			{
+				ID:   "1",
+				Path: path,
+				Size: 12,
+			},
+		},
+	}
+	return tail, nil
 }
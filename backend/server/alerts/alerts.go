@@ -0,0 +1,347 @@
+package alerts
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	dbops "isc.org/stork/server/database"
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/eventcenter"
+	storkutil "isc.org/stork/util"
+)
+
+// Value of the metric for a particular object.
+type metricValue struct {
+	objectID int64
+	value    float64
+	// Describes the object in the event text. It may contain the tags
+	// replaced with the objects by the event center, e.g. {subnet}.
+	description string
+	objects     []interface{}
+	// Indicates that the object has no meaningful value of the metric,
+	// e.g. the daemon has served no traffic recently. The rules are not
+	// evaluated for such values, but the firing ones are resolved.
+	unavailable bool
+}
+
+// Evaluates the alert rules stored in the database against the current
+// state of the subnets, shared networks and daemons. The rules are
+// typically evaluated after each run of the pullers updating this state.
+type Engine struct {
+	db          *dbops.PgDB
+	eventCenter eventcenter.EventCenter
+	mutex       *sync.Mutex
+}
+
+// Creates the alert rules engine.
+func NewEngine(db *dbops.PgDB, eventCenter eventcenter.EventCenter) *Engine {
+	return &Engine{
+		db:          db,
+		eventCenter: eventCenter,
+		mutex:       &sync.Mutex{},
+	}
+}
+
+// Evaluates all alert rules. The errors are logged. It is safe to call it
+// from multiple pullers concurrently.
+func (engine *Engine) Evaluate() {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+	err := engine.evaluate(storkutil.UTCNow())
+	if err != nil {
+		log.Errorf("problem with evaluating alert rules: %+v", err)
+	}
+}
+
+// Resolves the firing alerts of the rule and removes its states before
+// calling the function which modifies or deletes the rule. The rules are
+// not evaluated in the meantime, so the alerts of the rule are not left
+// unresolved. The reason is included in the events resolving the alerts.
+func (engine *Engine) ResolveRule(ruleID int64, reason string, modify func() error) error {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+	rule, err := dbmodel.GetAlertRule(engine.db, ruleID)
+	if err != nil {
+		return err
+	}
+	if rule != nil && len(rule.States) > 0 {
+		// The metric values describe the objects in the events.
+		values, err := engine.collectMetrics(map[string]bool{rule.Metric: true})
+		if err != nil {
+			return err
+		}
+		for _, state := range rule.States {
+			if err := engine.dropState(rule, state, findMetricValue(values[rule.Metric], state.ObjectID), reason); err != nil {
+				return err
+			}
+		}
+	}
+	return modify()
+}
+
+// Returns the value of the metric for the object or nil if the object
+// has no value.
+func findMetricValue(values []metricValue, objectID int64) *metricValue {
+	for i := range values {
+		if values[i].objectID == objectID {
+			return &values[i]
+		}
+	}
+	return nil
+}
+
+// Returns the largest number of declined addresses among the local
+// subnets.
+func getDeclinedAddresses(sn *dbmodel.Subnet) float64 {
+	declined := float64(0)
+	for _, lsn := range sn.LocalSubnets {
+		for _, key := range []string{"declined-addresses", "declined-addreses", "declined-nas"} {
+			if value, ok := lsn.Stats[key].(float64); ok && value > declined {
+				declined = value
+			}
+		}
+	}
+	return declined
+}
+
+// Collects the values of the given metrics for all objects.
+func (engine *Engine) collectMetrics(metrics map[string]bool) (map[string][]metricValue, error) {
+	values := make(map[string][]metricValue)
+
+	if metrics[dbmodel.AlertMetricSubnetUtilization] || metrics[dbmodel.AlertMetricSubnetDeclinedAddresses] {
+		subnets, err := dbmodel.GetAllSubnets(engine.db, 0)
+		if err != nil {
+			return nil, err
+		}
+		for i := range subnets {
+			sn := &subnets[i]
+			values[dbmodel.AlertMetricSubnetUtilization] = append(values[dbmodel.AlertMetricSubnetUtilization], metricValue{
+				objectID:    sn.ID,
+				value:       float64(sn.AddrUtilization) / 10,
+				description: "{subnet}",
+				objects:     []interface{}{sn},
+			})
+			values[dbmodel.AlertMetricSubnetDeclinedAddresses] = append(values[dbmodel.AlertMetricSubnetDeclinedAddresses], metricValue{
+				objectID:    sn.ID,
+				value:       getDeclinedAddresses(sn),
+				description: "{subnet}",
+				objects:     []interface{}{sn},
+			})
+		}
+	}
+
+	if metrics[dbmodel.AlertMetricSharedNetworkUtilization] {
+		networks, err := dbmodel.GetAllSharedNetworks(engine.db, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, net := range networks {
+			values[dbmodel.AlertMetricSharedNetworkUtilization] = append(values[dbmodel.AlertMetricSharedNetworkUtilization], metricValue{
+				objectID:    net.ID,
+				value:       float64(net.AddrUtilization) / 10,
+				description: fmt.Sprintf("shared network %s", net.Name),
+			})
+		}
+	}
+
+	if metrics[dbmodel.AlertMetricDaemonRPS] || metrics[dbmodel.AlertMetricHAPartnerDown] {
+		apps, err := dbmodel.GetAppsByType(engine.db, dbmodel.AppTypeKea)
+		if err != nil {
+			return nil, err
+		}
+		for i := range apps {
+			for _, daemon := range apps[i].Daemons {
+				if !daemon.Monitored || daemon.KeaDaemon == nil || daemon.KeaDaemon.KeaDHCPDaemon == nil {
+					continue
+				}
+				daemon.App = &apps[i]
+				// The daemons which sent no responses within the last 24 hours
+				// do not normally serve traffic and their RPS is not evaluated.
+				values[dbmodel.AlertMetricDaemonRPS] = append(values[dbmodel.AlertMetricDaemonRPS], metricValue{
+					objectID:    daemon.ID,
+					value:       float64(daemon.KeaDaemon.KeaDHCPDaemon.Stats.RPS1),
+					description: "{daemon}",
+					objects:     []interface{}{daemon},
+					unavailable: daemon.KeaDaemon.KeaDHCPDaemon.Stats.RPS2 == 0,
+				})
+				for _, service := range daemon.Services {
+					if service.HAService == nil {
+						continue
+					}
+					partnerDown := float64(0)
+					if service.GetDaemonHAState(daemon.ID) == "partner-down" {
+						partnerDown = 1
+					}
+					values[dbmodel.AlertMetricHAPartnerDown] = append(values[dbmodel.AlertMetricHAPartnerDown], metricValue{
+						objectID:    daemon.ID,
+						value:       partnerDown,
+						description: "{daemon}",
+						objects:     []interface{}{daemon},
+					})
+					break
+				}
+			}
+		}
+	}
+
+	return values, nil
+}
+
+// Raises the event about the rule firing or being resolved for the object.
+func (engine *Engine) raiseEvent(level int, action string, rule *dbmodel.AlertRule, mv *metricValue) {
+	text := fmt.Sprintf("alert rule {rule} %s for %s: %s is %s", action, mv.description, rule.Metric,
+		strconv.FormatFloat(mv.value, 'f', -1, 64))
+	code := dbmodel.EventCodeAlertFired
+	if action == "resolved" {
+		code = dbmodel.EventCodeAlertResolved
+	}
+	payload := dbmodel.EventPayload{
+		"metric":    rule.Metric,
+		"value":     mv.value,
+		"operator":  rule.Operator,
+		"threshold": rule.Threshold,
+		"objectId":  mv.objectID,
+	}
+	objects := append([]interface{}{rule, code, payload}, mv.objects...)
+	engine.eventCenter.AddEvent(eventcenter.CreateEvent(level, text, objects...))
+}
+
+// Removes the state of the rule for the object without evaluating the
+// rule's condition, e.g. when the rule has been disabled. If the rule
+// fires for the object, the event about the rule being resolved is raised
+// before the state is removed. The metric value describes the object if
+// it is known.
+func (engine *Engine) dropState(rule *dbmodel.AlertRule, state *dbmodel.AlertState, mv *metricValue, reason string) error {
+	if state.Firing {
+		description := fmt.Sprintf("%s %d", strings.ReplaceAll(state.ObjectType, "_", " "), state.ObjectID)
+		var objects []interface{}
+		if mv != nil {
+			description = mv.description
+			objects = mv.objects
+		}
+		text := fmt.Sprintf("alert rule {rule} resolved for %s: %s", description, reason)
+		payload := dbmodel.EventPayload{
+			"metric":   rule.Metric,
+			"reason":   reason,
+			"objectId": state.ObjectID,
+		}
+		objects = append([]interface{}{rule, dbmodel.EventCodeAlertResolved, payload}, objects...)
+		engine.eventCenter.AddEvent(eventcenter.CreateEvent(dbmodel.EvInfo, text, objects...))
+	}
+	return dbmodel.DeleteAlertState(engine.db, state)
+}
+
+// Evaluates the rule for all values of its metric. The rule fires for the
+// object when its condition holds for at least the rule's duration and it
+// is resolved when the condition no longer holds with the hysteresis.
+// The rule is also resolved when the object no longer has the metric.
+func (engine *Engine) evaluateRule(rule *dbmodel.AlertRule, values []metricValue, now time.Time) error {
+	states := make(map[int64]*dbmodel.AlertState)
+	for _, state := range rule.States {
+		states[state.ObjectID] = state
+	}
+
+	var lastErr error
+	for i := range values {
+		mv := &values[i]
+		state, ok := states[mv.objectID]
+		delete(states, mv.objectID)
+
+		if mv.unavailable {
+			if ok {
+				if err := engine.dropState(rule, state, mv, "metric is no longer available"); err != nil {
+					lastErr = err
+				}
+			}
+			continue
+		}
+
+		switch {
+		case !ok || !state.Firing:
+			if !rule.Holds(mv.value, 0) {
+				if ok {
+					if err := dbmodel.DeleteAlertState(engine.db, state); err != nil {
+						lastErr = err
+					}
+				}
+				continue
+			}
+			if !ok {
+				state = &dbmodel.AlertState{
+					RuleID:       rule.ID,
+					ObjectType:   dbmodel.GetAlertMetricObjectType(rule.Metric),
+					ObjectID:     mv.objectID,
+					PendingSince: now,
+				}
+			}
+			state.Value = mv.value
+			if now.Sub(state.PendingSince) >= time.Duration(rule.Duration)*time.Second {
+				state.Firing = true
+				state.FiredAt = now
+				engine.raiseEvent(rule.Level, "fired", rule, mv)
+			}
+		default:
+			if !rule.Holds(mv.value, rule.Hysteresis) {
+				engine.raiseEvent(dbmodel.EvInfo, "resolved", rule, mv)
+				if err := dbmodel.DeleteAlertState(engine.db, state); err != nil {
+					lastErr = err
+				}
+				continue
+			}
+			state.Value = mv.value
+		}
+		if err := dbmodel.SetAlertState(engine.db, state); err != nil {
+			lastErr = err
+		}
+	}
+
+	// The objects which no longer exist or no longer have the metric.
+	for _, state := range states {
+		if err := engine.dropState(rule, state, nil, "object no longer exists"); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// Evaluates all enabled rules at the given time. The states of the disabled
+// rules are removed and their firing alerts are resolved.
+func (engine *Engine) evaluate(now time.Time) error {
+	rules, err := dbmodel.GetAlertRules(engine.db)
+	if err != nil {
+		return err
+	}
+	// The metrics of the disabled rules are collected to describe the
+	// objects in the events resolving their firing alerts.
+	metrics := make(map[string]bool)
+	for _, rule := range rules {
+		if rule.Enabled || len(rule.States) > 0 {
+			metrics[rule.Metric] = true
+		}
+	}
+	values, err := engine.collectMetrics(metrics)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	for _, rule := range rules {
+		if !rule.Enabled {
+			for _, state := range rule.States {
+				if err := engine.dropState(rule, state, findMetricValue(values[rule.Metric], state.ObjectID), "rule has been disabled"); err != nil {
+					lastErr = err
+				}
+			}
+			continue
+		}
+		if err := engine.evaluateRule(rule, values[rule.Metric], now); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
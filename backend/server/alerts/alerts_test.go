@@ -0,0 +1,327 @@
+package alerts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	storktest "isc.org/stork/server/test"
+)
+
+// Test that the rule becomes pending, fires after its duration and is
+// resolved when the metric drops below the threshold with hysteresis.
+func TestEvaluateSubnetUtilization(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	subnet := &dbmodel.Subnet{
+		Prefix: "192.0.2.0/24",
+	}
+	err := dbmodel.AddSubnet(db, subnet)
+	require.NoError(t, err)
+	err = subnet.UpdateUtilization(db, 950, 0)
+	require.NoError(t, err)
+
+	rule := &dbmodel.AlertRule{
+		Name:       "subnet full",
+		Metric:     dbmodel.AlertMetricSubnetUtilization,
+		Operator:   ">",
+		Threshold:  90,
+		Hysteresis: 5,
+		Duration:   600,
+		Level:      dbmodel.EvWarning,
+		Enabled:    true,
+	}
+	err = dbmodel.AddAlertRule(db, rule)
+	require.NoError(t, err)
+
+	fec := &storktest.FakeEventCenter{}
+	engine := NewEngine(db, fec)
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	// The condition holds but the rule is only pending.
+	err = engine.evaluate(now)
+	require.NoError(t, err)
+	require.Empty(t, fec.Events)
+	returned, err := dbmodel.GetAlertRule(db, rule.ID)
+	require.NoError(t, err)
+	require.Len(t, returned.States, 1)
+	require.Equal(t, dbmodel.AlertObjectSubnet, returned.States[0].ObjectType)
+	require.Equal(t, subnet.ID, returned.States[0].ObjectID)
+	require.False(t, returned.States[0].Firing)
+	require.EqualValues(t, 95, returned.States[0].Value)
+
+	// The duration has not elapsed yet.
+	err = engine.evaluate(now.Add(5 * time.Minute))
+	require.NoError(t, err)
+	require.Empty(t, fec.Events)
+
+	// The rule fires.
+	err = engine.evaluate(now.Add(10 * time.Minute))
+	require.NoError(t, err)
+	require.Len(t, fec.Events, 1)
+	require.EqualValues(t, dbmodel.EvWarning, fec.Events[0].Level)
+	require.Contains(t, fec.Events[0].Text, "fired for <subnet")
+	require.Contains(t, fec.Events[0].Text, "subnet_utilization is 95")
+	require.EqualValues(t, rule.ID, fec.Events[0].Relations.RuleID)
+	require.EqualValues(t, subnet.ID, fec.Events[0].Relations.SubnetID)
+
+	// The event is not repeated while the rule is firing.
+	err = engine.evaluate(now.Add(15 * time.Minute))
+	require.NoError(t, err)
+	require.Len(t, fec.Events, 1)
+
+	// The utilization drops below the threshold but not below the
+	// hysteresis, so the rule is still firing.
+	err = subnet.UpdateUtilization(db, 870, 0)
+	require.NoError(t, err)
+	err = engine.evaluate(now.Add(20 * time.Minute))
+	require.NoError(t, err)
+	require.Len(t, fec.Events, 1)
+	returned, err = dbmodel.GetAlertRule(db, rule.ID)
+	require.NoError(t, err)
+	require.Len(t, returned.States, 1)
+	require.True(t, returned.States[0].Firing)
+	require.EqualValues(t, 87, returned.States[0].Value)
+
+	// The rule is resolved.
+	err = subnet.UpdateUtilization(db, 850, 0)
+	require.NoError(t, err)
+	err = engine.evaluate(now.Add(25 * time.Minute))
+	require.NoError(t, err)
+	require.Len(t, fec.Events, 2)
+	require.EqualValues(t, dbmodel.EvInfo, fec.Events[1].Level)
+	require.Contains(t, fec.Events[1].Text, "resolved for <subnet")
+	returned, err = dbmodel.GetAlertRule(db, rule.ID)
+	require.NoError(t, err)
+	require.Empty(t, returned.States)
+}
+
+// Test that the pending state is removed when the condition stops holding
+// before the rule fires and that the rule with zero duration fires
+// immediately.
+func TestEvaluatePendingCleared(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	subnet := &dbmodel.Subnet{
+		Prefix: "192.0.2.0/24",
+	}
+	err := dbmodel.AddSubnet(db, subnet)
+	require.NoError(t, err)
+	err = subnet.UpdateUtilization(db, 950, 0)
+	require.NoError(t, err)
+
+	pendingRule := &dbmodel.AlertRule{
+		Name:      "pending",
+		Metric:    dbmodel.AlertMetricSubnetUtilization,
+		Operator:  ">=",
+		Threshold: 90,
+		Duration:  600,
+		Level:     dbmodel.EvWarning,
+		Enabled:   true,
+	}
+	err = dbmodel.AddAlertRule(db, pendingRule)
+	require.NoError(t, err)
+	immediateRule := &dbmodel.AlertRule{
+		Name:      "immediate",
+		Metric:    dbmodel.AlertMetricSubnetUtilization,
+		Operator:  ">=",
+		Threshold: 90,
+		Level:     dbmodel.EvError,
+		Enabled:   true,
+	}
+	err = dbmodel.AddAlertRule(db, immediateRule)
+	require.NoError(t, err)
+
+	fec := &storktest.FakeEventCenter{}
+	engine := NewEngine(db, fec)
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	err = engine.evaluate(now)
+	require.NoError(t, err)
+	require.Len(t, fec.Events, 1)
+	require.EqualValues(t, dbmodel.EvError, fec.Events[0].Level)
+	require.EqualValues(t, immediateRule.ID, fec.Events[0].Relations.RuleID)
+
+	err = subnet.UpdateUtilization(db, 500, 0)
+	require.NoError(t, err)
+	err = engine.evaluate(now.Add(time.Minute))
+	require.NoError(t, err)
+
+	rules, err := dbmodel.GetAlertRules(db)
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	require.Empty(t, rules[0].States)
+	require.Empty(t, rules[1].States)
+}
+
+// Test that the states of the disabled rules are removed and the disabled
+// rules do not fire.
+func TestEvaluateDisabledRule(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	subnet := &dbmodel.Subnet{
+		Prefix: "192.0.2.0/24",
+	}
+	err := dbmodel.AddSubnet(db, subnet)
+	require.NoError(t, err)
+	err = subnet.UpdateUtilization(db, 950, 0)
+	require.NoError(t, err)
+
+	rule := &dbmodel.AlertRule{
+		Name:      "disabled",
+		Metric:    dbmodel.AlertMetricSubnetUtilization,
+		Operator:  ">",
+		Threshold: 90,
+		Level:     dbmodel.EvWarning,
+	}
+	err = dbmodel.AddAlertRule(db, rule)
+	require.NoError(t, err)
+	err = dbmodel.SetAlertState(db, &dbmodel.AlertState{
+		RuleID:     rule.ID,
+		ObjectType: dbmodel.AlertObjectSubnet,
+		ObjectID:   subnet.ID,
+		Firing:     true,
+	})
+	require.NoError(t, err)
+
+	fec := &storktest.FakeEventCenter{}
+	engine := NewEngine(db, fec)
+	err = engine.evaluate(time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+
+	// The firing alert is resolved before its state is removed.
+	require.Len(t, fec.Events, 1)
+	require.EqualValues(t, dbmodel.EvInfo, fec.Events[0].Level)
+	require.Contains(t, fec.Events[0].Text, "resolved for <subnet")
+	require.Contains(t, fec.Events[0].Text, "rule has been disabled")
+	require.EqualValues(t, rule.ID, fec.Events[0].Relations.RuleID)
+	require.EqualValues(t, subnet.ID, fec.Events[0].Relations.SubnetID)
+
+	returned, err := dbmodel.GetAlertRule(db, rule.ID)
+	require.NoError(t, err)
+	require.Empty(t, returned.States)
+}
+
+// Test that the firing alert is resolved when its object no longer exists.
+func TestEvaluateVanishedObject(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rule := &dbmodel.AlertRule{
+		Name:      "vanished",
+		Metric:    dbmodel.AlertMetricSubnetUtilization,
+		Operator:  ">",
+		Threshold: 90,
+		Level:     dbmodel.EvWarning,
+		Enabled:   true,
+	}
+	err := dbmodel.AddAlertRule(db, rule)
+	require.NoError(t, err)
+	err = dbmodel.SetAlertState(db, &dbmodel.AlertState{
+		RuleID:     rule.ID,
+		ObjectType: dbmodel.AlertObjectSubnet,
+		ObjectID:   12345,
+		Firing:     true,
+	})
+	require.NoError(t, err)
+
+	fec := &storktest.FakeEventCenter{}
+	engine := NewEngine(db, fec)
+	err = engine.evaluate(time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+
+	require.Len(t, fec.Events, 1)
+	require.EqualValues(t, dbmodel.EvInfo, fec.Events[0].Level)
+	require.Contains(t, fec.Events[0].Text, "resolved for subnet 12345")
+	require.Contains(t, fec.Events[0].Text, "object no longer exists")
+	require.EqualValues(t, rule.ID, fec.Events[0].Relations.RuleID)
+
+	returned, err := dbmodel.GetAlertRule(db, rule.ID)
+	require.NoError(t, err)
+	require.Empty(t, returned.States)
+}
+
+// Test that the firing alerts are resolved before the rule is modified
+// or deleted.
+func TestResolveRule(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	subnet := &dbmodel.Subnet{
+		Prefix: "192.0.2.0/24",
+	}
+	err := dbmodel.AddSubnet(db, subnet)
+	require.NoError(t, err)
+
+	rule := &dbmodel.AlertRule{
+		Name:      "full",
+		Metric:    dbmodel.AlertMetricSubnetUtilization,
+		Operator:  ">",
+		Threshold: 90,
+		Level:     dbmodel.EvWarning,
+		Enabled:   true,
+	}
+	err = dbmodel.AddAlertRule(db, rule)
+	require.NoError(t, err)
+	setFiring := func() {
+		err := dbmodel.SetAlertState(db, &dbmodel.AlertState{
+			RuleID:     rule.ID,
+			ObjectType: dbmodel.AlertObjectSubnet,
+			ObjectID:   subnet.ID,
+			Firing:     true,
+		})
+		require.NoError(t, err)
+	}
+
+	fec := &storktest.FakeEventCenter{}
+	engine := NewEngine(db, fec)
+
+	// Modify the rule.
+	setFiring()
+	rule.Threshold = 95
+	err = engine.ResolveRule(rule.ID, "rule has been modified", func() error {
+		// The state is removed before the rule is modified.
+		returned, err := dbmodel.GetAlertRule(db, rule.ID)
+		require.NoError(t, err)
+		require.Empty(t, returned.States)
+		return dbmodel.UpdateAlertRule(db, rule)
+	})
+	require.NoError(t, err)
+	require.Len(t, fec.Events, 1)
+	require.EqualValues(t, dbmodel.EvInfo, fec.Events[0].Level)
+	require.Contains(t, fec.Events[0].Text, "resolved for <subnet")
+	require.Contains(t, fec.Events[0].Text, "rule has been modified")
+	require.EqualValues(t, rule.ID, fec.Events[0].Relations.RuleID)
+	require.EqualValues(t, subnet.ID, fec.Events[0].Relations.SubnetID)
+	returned, err := dbmodel.GetAlertRule(db, rule.ID)
+	require.NoError(t, err)
+	require.EqualValues(t, 95, returned.Threshold)
+
+	// Delete the rule.
+	setFiring()
+	err = engine.ResolveRule(rule.ID, "rule has been deleted", func() error {
+		return dbmodel.DeleteAlertRule(db, rule.ID)
+	})
+	require.NoError(t, err)
+	require.Len(t, fec.Events, 2)
+	require.Contains(t, fec.Events[1].Text, "rule has been deleted")
+	require.EqualValues(t, rule.ID, fec.Events[1].Relations.RuleID)
+	returned, err = dbmodel.GetAlertRule(db, rule.ID)
+	require.NoError(t, err)
+	require.Nil(t, returned)
+
+	// The rule no longer exists, so only the function is called.
+	called := false
+	err = engine.ResolveRule(rule.ID, "rule has been deleted", func() error {
+		called = true
+		return nil
+	})
+	require.NoError(t, err)
+	require.True(t, called)
+	require.Len(t, fec.Events, 2)
+}
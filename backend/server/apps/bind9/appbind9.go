@@ -163,6 +163,19 @@ func GetAppState(ctx context.Context, agents agentcomm.ConnectedAgents, dbApp *d
 		log.Warnf("cannot get BIND 9 number of zones: unable to find number of zones in output")
 	}
 
+	// Preserve the existing daemon, so its log targets and other
+	// references to it remain valid.
+	if len(dbApp.Daemons) > 0 {
+		existingDaemon := dbApp.Daemons[0]
+		bind9Daemon.ID = existingDaemon.ID
+		bind9Daemon.CreatedAt = existingDaemon.CreatedAt
+		bind9Daemon.Monitored = existingDaemon.Monitored
+		bind9Daemon.LogTargets = existingDaemon.LogTargets
+		if existingDaemon.Bind9Daemon != nil {
+			bind9Daemon.Bind9Daemon.ID = existingDaemon.Bind9Daemon.ID
+		}
+	}
+
 	// Save status
 	dbApp.Active = bind9Daemon.Active
 	dbApp.Meta.Version = bind9Daemon.Version
@@ -174,11 +187,31 @@ func GetAppState(ctx context.Context, agents agentcomm.ConnectedAgents, dbApp *d
 	GetAppStatistics(ctx, agents, dbApp)
 }
 
+// Sets the log targets reported by the agent for the named daemon of the
+// BIND 9 app. The daemon is created if the app doesn't have it yet. The
+// existing log targets matching the reported ones are preserved.
+func SetAppLogTargets(dbApp *dbmodel.App, logTargets []agentcomm.LogTarget) {
+	if len(dbApp.Daemons) == 0 {
+		dbApp.Daemons = []*dbmodel.Daemon{
+			dbmodel.NewBind9Daemon(false),
+		}
+	}
+	var targets []*dbmodel.LogTarget
+	for _, target := range logTargets {
+		targets = append(targets, &dbmodel.LogTarget{
+			Name:     target.Name,
+			Severity: target.Severity,
+			Output:   target.Output,
+		})
+	}
+	dbApp.Daemons[0].SetLogTargets(targets)
+}
+
 // Inserts or updates information about BIND 9 app in the database.
 func CommitAppIntoDB(db *dbops.PgDB, app *dbmodel.App, eventCenter eventcenter.EventCenter) (err error) {
 	if app.ID == 0 {
 		_, err = dbmodel.AddApp(db, app)
-		eventCenter.AddInfoEvent("added {app}", app.Machine, app)
+		eventCenter.AddInfoEvent("added {app}", app.Machine, app, dbmodel.EventCodeAppAdded)
 	} else {
 		_, _, err = dbmodel.UpdateApp(db, app)
 	}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"isc.org/stork/server/agentcomm"
 	agentcommtest "isc.org/stork/server/agentcomm/test"
 	dbmodel "isc.org/stork/server/database/model"
 	dbtest "isc.org/stork/server/database/test"
@@ -127,3 +128,70 @@ func TestCommitAppIntoDB(t *testing.T) {
 	require.Len(t, returned.AccessPoints, 1)
 	require.EqualValues(t, 2345, returned.AccessPoints[0].Port)
 }
+
+// Tests that the log targets reported by the agent are set for the
+// named daemon and that they are preserved when the state is fetched.
+func TestSetAppLogTargets(t *testing.T) {
+	ctx := context.Background()
+
+	fa := agentcommtest.NewFakeAgents(nil, mockNamed)
+	fec := &storktest.FakeEventCenter{}
+
+	var accessPoints []*dbmodel.AccessPoint
+	accessPoints = dbmodel.AppendAccessPoint(accessPoints, dbmodel.AccessPointControl, "127.0.0.1", "abcd", 953)
+	dbApp := dbmodel.App{
+		AccessPoints: accessPoints,
+		Machine: &dbmodel.Machine{
+			Address:   "192.0.2.0",
+			AgentPort: 1111,
+		},
+	}
+
+	// The daemon should be created for the new app.
+	SetAppLogTargets(&dbApp, []agentcomm.LogTarget{
+		{
+			Name:     "default_log",
+			Severity: "info",
+			Output:   "/var/log/named.log",
+		},
+	})
+	require.Len(t, dbApp.Daemons, 1)
+	require.Equal(t, dbmodel.DaemonNameBind9, dbApp.Daemons[0].Name)
+	require.Len(t, dbApp.Daemons[0].LogTargets, 1)
+
+	// Simulate adding the daemon to the database.
+	dbApp.Daemons[0].ID = 5
+	dbApp.Daemons[0].Monitored = false
+	dbApp.Daemons[0].Bind9Daemon.ID = 6
+	dbApp.Daemons[0].LogTargets[0].ID = 7
+	dbApp.Daemons[0].LogTargets[0].DaemonID = 5
+
+	// The daemon and its log targets should be preserved.
+	GetAppState(ctx, fa, &dbApp, fec)
+	require.Len(t, dbApp.Daemons, 1)
+	daemon := dbApp.Daemons[0]
+	require.EqualValues(t, 5, daemon.ID)
+	require.False(t, daemon.Monitored)
+	require.EqualValues(t, 6, daemon.Bind9Daemon.ID)
+	require.Equal(t, "9.9.9", daemon.Version)
+	require.Len(t, daemon.LogTargets, 1)
+	require.EqualValues(t, 7, daemon.LogTargets[0].ID)
+
+	// Another channel configured.
+	SetAppLogTargets(&dbApp, []agentcomm.LogTarget{
+		{
+			Name:     "default_log",
+			Severity: "info",
+			Output:   "/var/log/named.log",
+		},
+		{
+			Name:     "system",
+			Severity: "warning",
+			Output:   "syslog",
+		},
+	})
+	require.Len(t, dbApp.Daemons[0].LogTargets, 2)
+	require.EqualValues(t, 7, dbApp.Daemons[0].LogTargets[0].ID)
+	require.Zero(t, dbApp.Daemons[0].LogTargets[1].ID)
+	require.Equal(t, "syslog", dbApp.Daemons[0].LogTargets[1].Output)
+}
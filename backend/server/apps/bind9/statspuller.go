@@ -47,24 +47,13 @@ func (statsPuller *StatsPuller) pullStats() (int, error) {
 	}
 
 	// get stats from each bind9 app
-	var lastErr error
-	appsOkCnt := 0
-	for _, dbApp := range dbApps {
-		dbApp2 := dbApp
-		err := statsPuller.getStatsFromApp(&dbApp2)
-		if err != nil {
-			lastErr = err
-			log.Errorf("error occurred while getting stats from app %+v: %+v", dbApp, err)
-		} else {
-			appsOkCnt++
-		}
-	}
+	appsOkCnt, lastErr := statsPuller.PullApps(dbApps, statsPuller.getStatsFromApp)
 	log.Printf("completed pulling stats from BIND 9 apps: %d/%d succeeded", appsOkCnt, len(dbApps))
 	return appsOkCnt, lastErr
 }
 
 // Get stats from given bind9 app.
-func (statsPuller *StatsPuller) getStatsFromApp(dbApp *dbmodel.App) error {
+func (statsPuller *StatsPuller) getStatsFromApp(ctx context.Context, dbApp *dbmodel.App) error {
 	// if app or daemon not active then do nothing
 	if len(dbApp.Daemons) > 0 && !dbApp.Daemons[0].Active {
 		return nil
@@ -76,7 +65,6 @@ func (statsPuller *StatsPuller) getStatsFromApp(dbApp *dbmodel.App) error {
 	}
 
 	statsOutput := NamedStatsGetResponse{}
-	ctx := context.Background()
 	err = statsPuller.Agents.ForwardToNamedStats(ctx, dbApp.Machine.Address, dbApp.Machine.AgentPort, statsChannel.Address, statsChannel.Port, "json/v1", &statsOutput)
 	if err != nil {
 		return err
@@ -0,0 +1,224 @@
+package apps
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"isc.org/stork/server/agentcomm"
+	dbops "isc.org/stork/server/database"
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/eventcenter"
+)
+
+// Change of the clock difference between the HA peers, in milliseconds,
+// below which the stored difference is not updated unless its level with
+// respect to the thresholds changes. The measured clock skews jitter with
+// the round trip times, so updating the HA services on any change would
+// write them to the database in every pull.
+const haClockSkewTolerance = 1000
+
+// Returns the level of the clock skew given in milliseconds with respect
+// to the thresholds given in seconds. A threshold equal to 0 is disabled.
+func clockSkewLevel(skew int64, warningThreshold, errorThreshold int64) int {
+	if skew < 0 {
+		skew = -skew
+	}
+	switch {
+	case errorThreshold > 0 && skew >= errorThreshold*1000:
+		return dbmodel.EvError
+	case warningThreshold > 0 && skew >= warningThreshold*1000:
+		return dbmodel.EvWarning
+	default:
+		return dbmodel.EvInfo
+	}
+}
+
+// Formats the clock skew given in milliseconds for events.
+func formatClockSkew(skew int64) string {
+	return (time.Duration(skew) * time.Millisecond).String()
+}
+
+// Gets the clock skew thresholds from the settings.
+func getClockSkewThresholds(db *dbops.PgDB) (warningThreshold, errorThreshold int64, err error) {
+	warningThreshold, err = dbmodel.GetSettingInt(db, "clock_skew_warning_threshold")
+	if err != nil {
+		return 0, 0, err
+	}
+	errorThreshold, err = dbmodel.GetSettingInt(db, "clock_skew_error_threshold")
+	if err != nil {
+		return 0, 0, err
+	}
+	return warningThreshold, errorThreshold, nil
+}
+
+// Compares the clock skew of the machine received from the agent with the
+// clock skew stored in the database and raises an event when the skew
+// crosses the thresholds. The event is also raised when the skew drops
+// below the thresholds. The clock skew must be checked before the machine
+// is updated in the database.
+func checkMachineClockSkew(dbMachine *dbmodel.Machine, state *agentcomm.State, eventCenter eventcenter.EventCenter, warningThreshold, errorThreshold int64) {
+	// The agent does not report its time.
+	if state.AgentTime.IsZero() {
+		return
+	}
+	oldLevel := dbmodel.EvInfo
+	if !dbMachine.State.ClockCheckedAt.IsZero() {
+		oldLevel = clockSkewLevel(dbMachine.State.ClockSkew, warningThreshold, errorThreshold)
+	}
+	skew := int64(state.ClockSkew / time.Millisecond)
+	newLevel := clockSkewLevel(skew, warningThreshold, errorThreshold)
+	details := fmt.Sprintf("clock skew: %s\nround trip time: %s\nclock synchronization: %s",
+		formatClockSkew(skew), state.ClockRoundTrip, state.ClockSyncStatus)
+	payload := dbmodel.EventPayload{"clockSkewMs": skew}
+	switch {
+	case newLevel > oldLevel:
+		text := fmt.Sprintf("clock of {machine} is off by %s", formatClockSkew(skew))
+		if newLevel == dbmodel.EvError {
+			eventCenter.AddErrorEvent(text, details, dbMachine, dbmodel.EventCodeMachineClockSkew, payload)
+		} else {
+			eventCenter.AddWarningEvent(text, details, dbMachine, dbmodel.EventCodeMachineClockSkew, payload)
+		}
+	case newLevel < oldLevel:
+		text := fmt.Sprintf("clock skew of {machine} dropped to %s", formatClockSkew(skew))
+		eventCenter.AddInfoEvent(text, details, dbMachine, dbmodel.EventCodeMachineClockSkewDropped, payload)
+	}
+}
+
+// Gets the clock skew thresholds from the settings and checks the clock
+// skew of the machine against them.
+func checkMachineClockSkewWithSettings(db *dbops.PgDB, dbMachine *dbmodel.Machine, state *agentcomm.State, eventCenter eventcenter.EventCenter) {
+	warningThreshold, errorThreshold, err := getClockSkewThresholds(db)
+	if err != nil {
+		log.Errorf("problem with getting clock skew thresholds: %+v", err)
+		return
+	}
+	checkMachineClockSkew(dbMachine, state, eventCenter, warningThreshold, errorThreshold)
+}
+
+// Compares the clocks of the machines running the primary and the secondary
+// server of the HA service. The difference between the clocks is stored
+// in the HA service. An event is raised when the difference crosses the
+// thresholds or drops below them. It returns true if the HA service has
+// been updated and should be stored in the database, i.e. when the level
+// of the difference has changed or the difference has changed by at least
+// the tolerance.
+func checkHAServiceClockSkew(service *dbmodel.Service, primary, secondary *dbmodel.Machine, eventCenter eventcenter.EventCenter, warningThreshold, errorThreshold int64) bool {
+	ha := service.HAService
+	if ha == nil || primary == nil || secondary == nil ||
+		primary.State.ClockCheckedAt.IsZero() || secondary.State.ClockCheckedAt.IsZero() {
+		return false
+	}
+
+	skew := primary.State.ClockSkew - secondary.State.ClockSkew
+	if skew < 0 {
+		skew = -skew
+	}
+	oldLevel := dbmodel.EvInfo
+	newLevel := clockSkewLevel(skew, warningThreshold, errorThreshold)
+	if ha.PeersClockSkew != nil {
+		oldLevel = clockSkewLevel(*ha.PeersClockSkew, warningThreshold, errorThreshold)
+		change := skew - *ha.PeersClockSkew
+		if change < 0 {
+			change = -change
+		}
+		if newLevel == oldLevel && change < haClockSkewTolerance {
+			return false
+		}
+	}
+	ha.PeersClockSkew = &skew
+
+	// Find the primary server to be associated with the event.
+	var primaryDaemon *dbmodel.Daemon
+	for _, d := range service.Daemons {
+		if d.ID == ha.PrimaryID {
+			primaryDaemon = d
+			break
+		}
+	}
+	if primaryDaemon == nil || primaryDaemon.App == nil {
+		return true
+	}
+
+	details := fmt.Sprintf("primary: %s, clock skew: %s\nsecondary: %s, clock skew: %s",
+		primary.Address, formatClockSkew(primary.State.ClockSkew),
+		secondary.Address, formatClockSkew(secondary.State.ClockSkew))
+	payload := dbmodel.EventPayload{
+		"clockSkewMs":    skew,
+		"partnerAddress": secondary.Address,
+	}
+	switch {
+	case newLevel > oldLevel:
+		text := fmt.Sprintf("clocks of {daemon} and its HA partner on %s drift apart by %s", secondary.Address, formatClockSkew(skew))
+		if newLevel == dbmodel.EvError {
+			eventCenter.AddErrorEvent(text, details, primaryDaemon, primaryDaemon.App, primary,
+				dbmodel.EventCodeHAClockDrift, payload)
+		} else {
+			eventCenter.AddWarningEvent(text, details, primaryDaemon, primaryDaemon.App, primary,
+				dbmodel.EventCodeHAClockDrift, payload)
+		}
+	case newLevel < oldLevel:
+		text := fmt.Sprintf("clocks of {daemon} and its HA partner on %s drift apart by %s only", secondary.Address, formatClockSkew(skew))
+		eventCenter.AddInfoEvent(text, details, primaryDaemon, primaryDaemon.App, primary,
+			dbmodel.EventCodeHAClockDriftDropped, payload)
+	}
+	return true
+}
+
+// Compares the clocks of the HA peers for all HA services and stores the
+// results in the database. It should be called after the clock skews of
+// all machines have been refreshed.
+func checkHAPeersClockSkew(db *dbops.PgDB, eventCenter eventcenter.EventCenter) error {
+	warningThreshold, errorThreshold, err := getClockSkewThresholds(db)
+	if err != nil {
+		return err
+	}
+	services, err := dbmodel.GetDetailedAllServices(db)
+	if err != nil {
+		return err
+	}
+
+	// Machines are cached because they typically run multiple daemons.
+	machines := make(map[int64]*dbmodel.Machine)
+	getMachine := func(daemonID int64, daemons []*dbmodel.Daemon) (*dbmodel.Machine, error) {
+		for _, d := range daemons {
+			if d.ID != daemonID || d.App == nil {
+				continue
+			}
+			if m, ok := machines[d.App.MachineID]; ok {
+				return m, nil
+			}
+			m, err := dbmodel.GetMachineByID(db, d.App.MachineID)
+			if err != nil {
+				return nil, err
+			}
+			machines[d.App.MachineID] = m
+			return m, nil
+		}
+		return nil, nil
+	}
+
+	for i := range services {
+		service := &services[i]
+		if service.HAService == nil || service.HAService.SecondaryID == 0 {
+			continue
+		}
+		primary, err := getMachine(service.HAService.PrimaryID, service.Daemons)
+		if err != nil {
+			return err
+		}
+		secondary, err := getMachine(service.HAService.SecondaryID, service.Daemons)
+		if err != nil {
+			return err
+		}
+		if checkHAServiceClockSkew(service, primary, secondary, eventCenter, warningThreshold, errorThreshold) {
+			err = dbmodel.UpdateHAServicePeersClockSkew(db, service.HAService)
+			if err != nil {
+				return errors.WithMessagef(err, "problem with storing clock skew of HA service %d", service.ID)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package apps
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"isc.org/stork/server/agentcomm"
+	dbmodel "isc.org/stork/server/database/model"
+	storktest "isc.org/stork/server/test"
+	storkutil "isc.org/stork/util"
+)
+
+// Check that the clock skew level is calculated correctly with respect
+// to the thresholds.
+func TestClockSkewLevel(t *testing.T) {
+	require.Equal(t, dbmodel.EvInfo, clockSkewLevel(9999, 10, 30))
+	require.Equal(t, dbmodel.EvWarning, clockSkewLevel(-10000, 10, 30))
+	require.Equal(t, dbmodel.EvError, clockSkewLevel(30000, 10, 30))
+	// Disabled thresholds.
+	require.Equal(t, dbmodel.EvInfo, clockSkewLevel(30000, 0, 0))
+}
+
+// Check that the events are raised when the clock skew of the machine
+// crosses the thresholds.
+func TestCheckMachineClockSkew(t *testing.T) {
+	fec := &storktest.FakeEventCenter{}
+	dbMachine := &dbmodel.Machine{
+		ID: 1,
+	}
+	state := &agentcomm.State{
+		AgentTime:       storkutil.UTCNow(),
+		ClockSkew:       -15 * time.Second,
+		ClockRoundTrip:  2 * time.Millisecond,
+		ClockSyncStatus: "unsynchronized",
+	}
+	checkMachineClockSkew(dbMachine, state, fec, 10, 30)
+	require.Len(t, fec.Events, 1)
+	require.Equal(t, dbmodel.EvWarning, fec.Events[0].Level)
+	require.Contains(t, fec.Events[0].Text, "is off by -15s")
+	require.Contains(t, fec.Events[0].Details, "clock synchronization: unsynchronized")
+	require.EqualValues(t, 1, fec.Events[0].Relations.MachineID)
+
+	// The skew grows above the error threshold.
+	fec = &storktest.FakeEventCenter{}
+	dbMachine.State.ClockSkew = -15000
+	dbMachine.State.ClockCheckedAt = storkutil.UTCNow()
+	state.ClockSkew = 45 * time.Second
+	checkMachineClockSkew(dbMachine, state, fec, 10, 30)
+	require.Len(t, fec.Events, 1)
+	require.Equal(t, dbmodel.EvError, fec.Events[0].Level)
+
+	// The skew drops below the thresholds.
+	fec = &storktest.FakeEventCenter{}
+	dbMachine.State.ClockSkew = 45000
+	state.ClockSkew = 5 * time.Millisecond
+	checkMachineClockSkew(dbMachine, state, fec, 10, 30)
+	require.Len(t, fec.Events, 1)
+	require.Equal(t, dbmodel.EvInfo, fec.Events[0].Level)
+
+	// No events when the agent does not report its time.
+	fec = &storktest.FakeEventCenter{}
+	dbMachine.State.ClockSkew = 5
+	checkMachineClockSkew(dbMachine, &agentcomm.State{ClockSkew: time.Hour}, fec, 10, 30)
+	require.Empty(t, fec.Events)
+}
+
+// Check that the difference between the clocks of the HA peers is
+// stored in the HA service and the events are raised.
+func TestCheckHAServiceClockSkew(t *testing.T) {
+	fec := &storktest.FakeEventCenter{}
+	primaryDaemon := &dbmodel.Daemon{
+		ID:   1,
+		Name: "dhcp4",
+		App: &dbmodel.App{
+			ID:   2,
+			Type: dbmodel.AppTypeKea,
+		},
+	}
+	service := &dbmodel.Service{
+		BaseService: dbmodel.BaseService{
+			Daemons: []*dbmodel.Daemon{primaryDaemon},
+		},
+		HAService: &dbmodel.BaseHAService{
+			PrimaryID:   1,
+			SecondaryID: 3,
+		},
+	}
+	now := storkutil.UTCNow()
+	primary := &dbmodel.Machine{
+		ID:      4,
+		Address: "192.0.2.1",
+		State: dbmodel.MachineState{
+			ClockSkew:      4000,
+			ClockCheckedAt: now,
+		},
+	}
+	secondary := &dbmodel.Machine{
+		ID:      5,
+		Address: "192.0.2.2",
+		State: dbmodel.MachineState{
+			ClockSkew:      -8000,
+			ClockCheckedAt: now,
+		},
+	}
+	require.True(t, checkHAServiceClockSkew(service, primary, secondary, fec, 10, 30))
+	require.NotNil(t, service.HAService.PeersClockSkew)
+	require.EqualValues(t, 12000, *service.HAService.PeersClockSkew)
+	require.Len(t, fec.Events, 1)
+	require.Equal(t, dbmodel.EvWarning, fec.Events[0].Level)
+	require.Contains(t, fec.Events[0].Text, "HA partner on 192.0.2.2 drift apart by 12s")
+	require.EqualValues(t, 1, fec.Events[0].Relations.DaemonID)
+	require.EqualValues(t, 2, fec.Events[0].Relations.AppID)
+	require.EqualValues(t, 4, fec.Events[0].Relations.MachineID)
+
+	// The same skew should not update the service.
+	fec = &storktest.FakeEventCenter{}
+	require.False(t, checkHAServiceClockSkew(service, primary, secondary, fec, 10, 30))
+	require.Empty(t, fec.Events)
+
+	// A change below the tolerance should not update the service either.
+	secondary.State.ClockSkew = -8900
+	require.False(t, checkHAServiceClockSkew(service, primary, secondary, fec, 10, 30))
+	require.EqualValues(t, 12000, *service.HAService.PeersClockSkew)
+	require.Empty(t, fec.Events)
+
+	// A larger change updates the service without raising an event
+	// because the level remains the same.
+	secondary.State.ClockSkew = -6300
+	require.True(t, checkHAServiceClockSkew(service, primary, secondary, fec, 10, 30))
+	require.EqualValues(t, 10300, *service.HAService.PeersClockSkew)
+	require.Empty(t, fec.Events)
+
+	// The clocks are close again. The change below the tolerance updates
+	// the service because the difference drops below the threshold.
+	secondary.State.ClockSkew = -5800
+	require.True(t, checkHAServiceClockSkew(service, primary, secondary, fec, 10, 30))
+	require.EqualValues(t, 9800, *service.HAService.PeersClockSkew)
+	require.Len(t, fec.Events, 1)
+	require.Equal(t, dbmodel.EvInfo, fec.Events[0].Level)
+
+	// Nothing to compare if the clock of one of the machines is unknown.
+	secondary.State.ClockCheckedAt = time.Time{}
+	require.False(t, checkHAServiceClockSkew(service, primary, secondary, fec, 10, 30))
+}
@@ -0,0 +1,197 @@
+package apps
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"isc.org/stork/server/agentcomm"
+	dbops "isc.org/stork/server/database"
+	dbmodel "isc.org/stork/server/database/model"
+	storkutil "isc.org/stork/util"
+)
+
+// Maximum number of events archived and deleted at once.
+const eventRetentionBatchSize = 1000
+
+// Names of the settings holding the retention periods of the events with
+// the particular levels.
+var eventRetentionSettings = map[int]string{
+	dbmodel.EvInfo:    "event_retention_info",
+	dbmodel.EvWarning: "event_retention_warning",
+	dbmodel.EvError:   "event_retention_error",
+}
+
+// Settings of the events retention.
+type EventRetentionSettings struct {
+	ArchiveDir string `long:"event-archive-dir" description:"Directory where the events are archived before they are deleted according to the retention settings; the events are not archived when empty" default:"" env:"STORK_SERVER_EVENT_ARCHIVE_DIR"`
+}
+
+// Instance of the puller which periodically deletes the events older than
+// the retention periods configured for their levels. The deleted events
+// are optionally archived in the gzipped files holding one event in JSON
+// format per line.
+type EventRetentionPuller struct {
+	*agentcomm.PeriodicPuller
+	settings *EventRetentionSettings
+}
+
+// Create an instance of the puller which periodically deletes the old
+// events.
+func NewEventRetentionPuller(db *dbops.PgDB, agents agentcomm.ConnectedAgents, settings *EventRetentionSettings) (*EventRetentionPuller, error) {
+	puller := &EventRetentionPuller{
+		settings: settings,
+	}
+	periodicPuller, err := agentcomm.NewPeriodicPuller(db, agents, "Event Retention",
+		"event_retention_puller_interval", puller.purgeEvents)
+	if err != nil {
+		return nil, err
+	}
+	puller.PeriodicPuller = periodicPuller
+	return puller, nil
+}
+
+// Stops the timer triggering the deletion of the events.
+func (puller *EventRetentionPuller) Shutdown() {
+	puller.PeriodicPuller.Shutdown()
+}
+
+// Deletes the events older than their retention periods.
+func (puller *EventRetentionPuller) purgeEvents() (int, error) {
+	var archiveDir string
+	if puller.settings != nil {
+		archiveDir = puller.settings.ArchiveDir
+	}
+	count, err := PurgeEvents(puller.DB, archiveDir, storkutil.UTCNow())
+	if err != nil {
+		return 0, err
+	}
+	if count > 0 {
+		log.Printf("deleted %d events older than their retention periods", count)
+	}
+	return 1, nil
+}
+
+// Archive of the events. It is created when the first event is written.
+type eventArchive struct {
+	path   string
+	file   *os.File
+	gz     *gzip.Writer
+	writer *bufio.Writer
+}
+
+// Writes the events to the archive file, one event in JSON format per
+// line. The written events are flushed to the file.
+func (archive *eventArchive) write(events []dbmodel.Event) error {
+	if archive.file == nil {
+		file, err := os.OpenFile(archive.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return errors.Wrapf(err, "problem with creating events archive %s", archive.path)
+		}
+		archive.file = file
+		archive.gz = gzip.NewWriter(file)
+		archive.writer = bufio.NewWriter(archive.gz)
+	}
+	encoder := json.NewEncoder(archive.writer)
+	for i := range events {
+		if err := encoder.Encode(&events[i]); err != nil {
+			return errors.Wrapf(err, "problem with writing event %d to archive %s", events[i].ID, archive.path)
+		}
+	}
+	if err := archive.writer.Flush(); err != nil {
+		return errors.Wrapf(err, "problem with writing events to archive %s", archive.path)
+	}
+	if err := archive.gz.Flush(); err != nil {
+		return errors.Wrapf(err, "problem with writing events to archive %s", archive.path)
+	}
+	return nil
+}
+
+// Closes the archive file if it has been created.
+func (archive *eventArchive) close() error {
+	if archive.file == nil {
+		return nil
+	}
+	err := archive.gz.Close()
+	if err == nil {
+		err = archive.file.Close()
+	} else {
+		archive.file.Close()
+	}
+	if err != nil {
+		return errors.Wrapf(err, "problem with closing events archive %s", archive.path)
+	}
+	return nil
+}
+
+// Deletes the events last seen earlier than the retention periods
+// configured for their levels. The retention period of 0 days keeps the events forever.
+// If the archive directory is specified, the events are written to the
+// archive file in this directory before they are deleted. It returns the
+// number of deleted events.
+func PurgeEvents(db *dbops.PgDB, archiveDir string, now time.Time) (int, error) {
+	var archive *eventArchive
+	if len(archiveDir) > 0 {
+		archive = &eventArchive{
+			path: filepath.Join(archiveDir, fmt.Sprintf("events-%s.jsonl.gz", now.Format("20060102T150405Z"))),
+		}
+	}
+
+	total := 0
+	err := purgeEvents(db, archive, now, &total)
+	if archive != nil {
+		if closeErr := archive.close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return total, err
+}
+
+// Deletes the old events of all levels in batches, archiving them first
+// if the archive is specified.
+func purgeEvents(db *dbops.PgDB, archive *eventArchive, now time.Time, total *int) error {
+	for _, level := range []int{dbmodel.EvInfo, dbmodel.EvWarning, dbmodel.EvError} {
+		days, err := dbmodel.GetSettingInt(db, eventRetentionSettings[level])
+		if err != nil {
+			return err
+		}
+		if days <= 0 {
+			continue
+		}
+		before := now.Add(-time.Duration(days) * 24 * time.Hour)
+		for {
+			events, err := dbmodel.GetEventsLastSeenBefore(db, level, before, eventRetentionBatchSize)
+			if err != nil {
+				return err
+			}
+			if len(events) == 0 {
+				break
+			}
+			if archive != nil {
+				if err = archive.write(events); err != nil {
+					return err
+				}
+			}
+			var ids []int64
+			for _, event := range events {
+				ids = append(ids, event.ID)
+			}
+			count, err := dbmodel.DeleteEvents(db, ids)
+			if err != nil {
+				return err
+			}
+			*total += count
+			if len(events) < eventRetentionBatchSize {
+				break
+			}
+		}
+	}
+	return nil
+}
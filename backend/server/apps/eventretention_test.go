@@ -0,0 +1,121 @@
+package apps
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	agentcommtest "isc.org/stork/server/agentcomm/test"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Check creating and shutting down EventRetentionPuller.
+func TestEventRetentionPullerBasic(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	err := dbmodel.InitializeSettings(db)
+	require.NoError(t, err)
+
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	puller, err := NewEventRetentionPuller(db, fa, &EventRetentionSettings{})
+	require.NoError(t, err)
+	require.NotNil(t, puller.PeriodicPuller)
+	require.Equal(t, "event_retention", puller.GetID())
+
+	puller.Shutdown()
+}
+
+// Check that the events older than their retention periods are archived
+// and deleted.
+func TestPurgeEvents(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	err := dbmodel.InitializeSettings(db)
+	require.NoError(t, err)
+	err = dbmodel.SetSettingInt(db, "event_retention_info", 1)
+	require.NoError(t, err)
+	err = dbmodel.SetSettingInt(db, "event_retention_warning", 0)
+	require.NoError(t, err)
+	err = dbmodel.SetSettingInt(db, "event_retention_error", 10)
+	require.NoError(t, err)
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, days := range []int{0, 2, 20} {
+		for _, level := range []int{dbmodel.EvInfo, dbmodel.EvWarning, dbmodel.EvError} {
+			event := &dbmodel.Event{
+				CreatedAt: now.Add(-time.Duration(days) * 24 * time.Hour),
+				Text:      "some event",
+				Level:     level,
+			}
+			err = dbmodel.AddEvent(db, event)
+			require.NoError(t, err)
+		}
+	}
+
+	// The old info event which recurred recently is kept.
+	recurring := &dbmodel.Event{
+		CreatedAt:  now.Add(-20 * 24 * time.Hour),
+		Text:       "recurring event",
+		Level:      dbmodel.EvInfo,
+		LastSeenAt: now,
+	}
+	err = dbmodel.AddEvent(db, recurring)
+	require.NoError(t, err)
+
+	archiveDir, err := ioutil.TempDir("", "stork_events_archive")
+	require.NoError(t, err)
+	defer os.RemoveAll(archiveDir)
+
+	// The info events older than a day and the errors older than ten days
+	// are deleted. The warnings are kept forever.
+	count, err := PurgeEvents(db, archiveDir, now)
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+
+	events, _, err := dbmodel.GetEventsByTimeRange(db, now.Add(-100*24*time.Hour), now.Add(time.Hour), 0, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, events, 7)
+	returned, err := dbmodel.GetEvent(db, recurring.ID)
+	require.NoError(t, err)
+	require.NotNil(t, returned)
+
+	// The deleted events are archived.
+	file, err := os.Open(filepath.Join(archiveDir, "events-20200601T120000Z.jsonl.gz"))
+	require.NoError(t, err)
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	require.NoError(t, err)
+	scanner := bufio.NewScanner(gz)
+	var archived []dbmodel.Event
+	for scanner.Scan() {
+		var event dbmodel.Event
+		err = json.Unmarshal(scanner.Bytes(), &event)
+		require.NoError(t, err)
+		archived = append(archived, event)
+	}
+	require.NoError(t, scanner.Err())
+	require.Len(t, archived, 3)
+	require.Equal(t, dbmodel.EvInfo, archived[0].Level)
+	require.Equal(t, "some event", archived[0].Text)
+
+	// Nothing more to delete and no archive created.
+	count, err = PurgeEvents(db, archiveDir, now.Add(time.Hour))
+	require.NoError(t, err)
+	require.Zero(t, count)
+	_, err = os.Stat(filepath.Join(archiveDir, "events-20200601T130000Z.jsonl.gz"))
+	require.True(t, os.IsNotExist(err))
+
+	// Without the archive directory the events are just deleted.
+	count, err = PurgeEvents(db, "", now.Add(25*time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+}
@@ -0,0 +1,149 @@
+package apps
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+
+	"isc.org/stork/server/agentcomm"
+	dbops "isc.org/stork/server/database"
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/eventcenter"
+)
+
+// Returns the level of the disk usage with respect to the thresholds.
+// A threshold equal to 0 is disabled.
+func diskUsageLevel(usedPercent float64, warningThreshold, errorThreshold int64) int {
+	switch {
+	case errorThreshold > 0 && usedPercent >= float64(errorThreshold):
+		return dbmodel.EvError
+	case warningThreshold > 0 && usedPercent >= float64(warningThreshold):
+		return dbmodel.EvWarning
+	default:
+		return dbmodel.EvInfo
+	}
+}
+
+// Returns a textual description of the database backend used in events.
+func databaseBackendTag(database *agentcomm.DatabaseBackend) string {
+	address := net.JoinHostPort(database.Host, strconv.FormatInt(database.Port, 10))
+	return fmt.Sprintf("%s %s at %s", database.Type, database.Kind, address)
+}
+
+// Returns a key identifying the database backend.
+func databaseBackendKey(kind, tp, host string, port int64, name string) string {
+	return fmt.Sprintf("%s:%s:%s:%d:%s", kind, tp, host, port, name)
+}
+
+// Compares the inventory of the machine received from the agent with the
+// inventory stored in the database and raises events when the disk usage
+// crosses the thresholds, when a network interface loses its addresses or
+// disappears and when a database backend becomes unreachable. The events
+// are also raised when the situation comes back to normal. The inventory
+// of the machine must be checked before it is updated in the database.
+func checkMachineInventory(dbMachine *dbmodel.Machine, state *agentcomm.State, eventCenter eventcenter.EventCenter, warningThreshold, errorThreshold int64) {
+	oldState := &dbMachine.State
+
+	// Check the disk usage.
+	oldFilesystems := make(map[string]*dbmodel.FilesystemUsage)
+	for i := range oldState.Filesystems {
+		oldFilesystems[oldState.Filesystems[i].Path] = &oldState.Filesystems[i]
+	}
+	for _, filesystem := range state.Filesystems {
+		if len(filesystem.Error) > 0 {
+			continue
+		}
+		oldLevel := dbmodel.EvInfo
+		if oldFilesystem, ok := oldFilesystems[filesystem.Path]; ok && len(oldFilesystem.Error) == 0 {
+			oldLevel = diskUsageLevel(oldFilesystem.UsedPercent, warningThreshold, errorThreshold)
+		}
+		newLevel := diskUsageLevel(filesystem.UsedPercent, warningThreshold, errorThreshold)
+		details := fmt.Sprintf("kind: %s\nfilesystem: %s\nused: %d of %d bytes",
+			filesystem.Kind, filesystem.Fstype, filesystem.Used, filesystem.Total)
+		payload := dbmodel.EventPayload{
+			"path":        filesystem.Path,
+			"usedPercent": filesystem.UsedPercent,
+		}
+		switch {
+		case newLevel > oldLevel:
+			text := fmt.Sprintf("disk usage of %s on {machine} reached %.0f%%", filesystem.Path, filesystem.UsedPercent)
+			if newLevel == dbmodel.EvError {
+				eventCenter.AddErrorEvent(text, details, dbMachine, dbmodel.EventCodeMachineDiskUsageHigh, payload)
+			} else {
+				eventCenter.AddWarningEvent(text, details, dbMachine, dbmodel.EventCodeMachineDiskUsageHigh, payload)
+			}
+		case newLevel < oldLevel:
+			text := fmt.Sprintf("disk usage of %s on {machine} dropped to %.0f%%", filesystem.Path, filesystem.UsedPercent)
+			eventCenter.AddInfoEvent(text, details, dbMachine, dbmodel.EventCodeMachineDiskUsageDropped, payload)
+		}
+	}
+
+	// Check the network interfaces. Nothing to compare against if the
+	// interfaces have not been reported before.
+	if len(oldState.Interfaces) > 0 {
+		newInterfaces := make(map[string]*agentcomm.NetworkInterface)
+		for i := range state.Interfaces {
+			newInterfaces[state.Interfaces[i].Name] = &state.Interfaces[i]
+		}
+		for _, oldInterface := range oldState.Interfaces {
+			newInterface, ok := newInterfaces[oldInterface.Name]
+			payload := dbmodel.EventPayload{"interface": oldInterface.Name}
+			switch {
+			case !ok:
+				eventCenter.AddWarningEvent(fmt.Sprintf("network interface %s disappeared from {machine}", oldInterface.Name), dbMachine,
+					dbmodel.EventCodeMachineInterfaceDisappeared, payload)
+			case len(oldInterface.Addresses) > 0 && len(newInterface.Addresses) == 0:
+				eventCenter.AddWarningEvent(fmt.Sprintf("network interface %s on {machine} lost its addresses", oldInterface.Name), dbMachine,
+					dbmodel.EventCodeMachineInterfaceAddressesLost, payload)
+			case len(oldInterface.Addresses) == 0 && len(newInterface.Addresses) > 0:
+				eventCenter.AddInfoEvent(fmt.Sprintf("network interface %s on {machine} got its addresses back", oldInterface.Name), dbMachine,
+					dbmodel.EventCodeMachineInterfaceAddressesRestored, payload)
+			}
+		}
+	}
+
+	// Check the reachability of the database backends.
+	oldDatabases := make(map[string]*dbmodel.DatabaseBackend)
+	for i := range oldState.Databases {
+		db := &oldState.Databases[i]
+		oldDatabases[databaseBackendKey(db.Kind, db.Type, db.Host, db.Port, db.Name)] = db
+	}
+	for i := range state.Databases {
+		database := &state.Databases[i]
+		oldDatabase, ok := oldDatabases[databaseBackendKey(database.Kind, database.Type, database.Host, database.Port, database.Name)]
+		tag := databaseBackendTag(database)
+		payload := dbmodel.EventPayload{
+			"kind":     database.Kind,
+			"type":     database.Type,
+			"host":     database.Host,
+			"port":     database.Port,
+			"database": database.Name,
+		}
+		switch {
+		case !database.Reachable && (!ok || oldDatabase.Reachable):
+			eventCenter.AddErrorEvent(fmt.Sprintf("%s is unreachable from {machine}", tag), database.Error, dbMachine,
+				dbmodel.EventCodeDatabaseUnreachable, payload)
+		case database.Reachable && ok && !oldDatabase.Reachable:
+			eventCenter.AddInfoEvent(fmt.Sprintf("%s is reachable again from {machine}", tag), dbMachine,
+				dbmodel.EventCodeDatabaseReachable, payload)
+		}
+	}
+}
+
+// Gets the disk usage thresholds from the settings and checks the
+// inventory of the machine against them.
+func checkMachineInventoryWithSettings(db *dbops.PgDB, dbMachine *dbmodel.Machine, state *agentcomm.State, eventCenter eventcenter.EventCenter) {
+	warningThreshold, err := dbmodel.GetSettingInt(db, "disk_usage_warning_threshold")
+	if err != nil {
+		log.Errorf("problem with getting disk usage warning threshold: %+v", err)
+		return
+	}
+	errorThreshold, err := dbmodel.GetSettingInt(db, "disk_usage_error_threshold")
+	if err != nil {
+		log.Errorf("problem with getting disk usage error threshold: %+v", err)
+		return
+	}
+	checkMachineInventory(dbMachine, state, eventCenter, warningThreshold, errorThreshold)
+}
@@ -0,0 +1,136 @@
+package apps
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"isc.org/stork/server/agentcomm"
+	dbmodel "isc.org/stork/server/database/model"
+	storktest "isc.org/stork/server/test"
+)
+
+// Check that the disk usage level is calculated correctly with respect
+// to the thresholds.
+func TestDiskUsageLevel(t *testing.T) {
+	require.Equal(t, dbmodel.EvInfo, diskUsageLevel(50, 80, 90))
+	require.Equal(t, dbmodel.EvWarning, diskUsageLevel(80, 80, 90))
+	require.Equal(t, dbmodel.EvError, diskUsageLevel(95.5, 80, 90))
+	// Disabled thresholds.
+	require.Equal(t, dbmodel.EvInfo, diskUsageLevel(95.5, 0, 0))
+	require.Equal(t, dbmodel.EvWarning, diskUsageLevel(95.5, 80, 0))
+}
+
+// Check that the events are raised when the disk usage crosses the
+// thresholds in both directions.
+func TestCheckMachineInventoryDiskUsage(t *testing.T) {
+	fec := &storktest.FakeEventCenter{}
+	dbMachine := &dbmodel.Machine{
+		ID:      1,
+		Address: "localhost",
+		State: dbmodel.MachineState{
+			Filesystems: []dbmodel.FilesystemUsage{
+				{Path: "/var/lib/kea", UsedPercent: 50},
+				{Path: "/var/cache/bind", UsedPercent: 95},
+			},
+		},
+	}
+	state := &agentcomm.State{
+		Filesystems: []agentcomm.FilesystemUsage{
+			{Path: "/var/lib/kea", UsedPercent: 85},
+			{Path: "/var/cache/bind", UsedPercent: 60},
+			{Path: "/var/lib/other", UsedPercent: 91},
+			{Path: "/non/existing", Error: "no such file or directory"},
+		},
+	}
+	checkMachineInventory(dbMachine, state, fec, 80, 90)
+	require.Len(t, fec.Events, 3)
+	require.Equal(t, dbmodel.EvWarning, fec.Events[0].Level)
+	require.Contains(t, fec.Events[0].Text, "disk usage of /var/lib/kea")
+	require.Contains(t, fec.Events[0].Text, "reached 85%")
+	require.EqualValues(t, 1, fec.Events[0].Relations.MachineID)
+	require.Equal(t, dbmodel.EvInfo, fec.Events[1].Level)
+	require.Contains(t, fec.Events[1].Text, "dropped to 60%")
+	require.Equal(t, dbmodel.EvError, fec.Events[2].Level)
+	require.Contains(t, fec.Events[2].Text, "disk usage of /var/lib/other")
+
+	// The same state again should not raise any events.
+	fec = &storktest.FakeEventCenter{}
+	updateState := &dbmodel.Machine{
+		State: dbmodel.MachineState{
+			Filesystems: []dbmodel.FilesystemUsage{
+				{Path: "/var/lib/kea", UsedPercent: 86},
+				{Path: "/var/cache/bind", UsedPercent: 60},
+				{Path: "/var/lib/other", UsedPercent: 92},
+			},
+		},
+	}
+	checkMachineInventory(updateState, state, fec, 80, 90)
+	require.Empty(t, fec.Events)
+}
+
+// Check that the events are raised when the network interface disappears
+// or loses its addresses.
+func TestCheckMachineInventoryInterfaces(t *testing.T) {
+	fec := &storktest.FakeEventCenter{}
+	dbMachine := &dbmodel.Machine{
+		State: dbmodel.MachineState{
+			Interfaces: []dbmodel.NetworkInterface{
+				{Name: "eth0", Addresses: []string{"192.0.2.1/24"}},
+				{Name: "eth1", Addresses: []string{"192.0.3.1/24"}},
+				{Name: "eth2"},
+				{Name: "eth3", Addresses: []string{"192.0.4.1/24"}},
+			},
+		},
+	}
+	state := &agentcomm.State{
+		Interfaces: []agentcomm.NetworkInterface{
+			{Name: "eth0", Addresses: []string{"192.0.2.1/24"}},
+			{Name: "eth1"},
+			{Name: "eth2", Addresses: []string{"192.0.5.1/24"}},
+		},
+	}
+	checkMachineInventory(dbMachine, state, fec, 80, 90)
+	require.Len(t, fec.Events, 3)
+	require.Equal(t, dbmodel.EvWarning, fec.Events[0].Level)
+	require.Contains(t, fec.Events[0].Text, "network interface eth1")
+	require.Contains(t, fec.Events[0].Text, "lost its addresses")
+	require.Equal(t, dbmodel.EvInfo, fec.Events[1].Level)
+	require.Contains(t, fec.Events[1].Text, "network interface eth2")
+	require.Equal(t, dbmodel.EvWarning, fec.Events[2].Level)
+	require.Contains(t, fec.Events[2].Text, "network interface eth3 disappeared")
+
+	// No events are raised when the interfaces are reported for the
+	// first time.
+	fec = &storktest.FakeEventCenter{}
+	checkMachineInventory(&dbmodel.Machine{}, state, fec, 80, 90)
+	require.Empty(t, fec.Events)
+}
+
+// Check that the events are raised when the database backend becomes
+// unreachable and reachable again.
+func TestCheckMachineInventoryDatabases(t *testing.T) {
+	fec := &storktest.FakeEventCenter{}
+	dbMachine := &dbmodel.Machine{
+		State: dbmodel.MachineState{
+			Databases: []dbmodel.DatabaseBackend{
+				{Kind: "lease-database", Type: "mysql", Host: "localhost", Port: 3306, Reachable: true},
+				{Kind: "hosts-database", Type: "postgresql", Host: "db", Port: 5432},
+			},
+		},
+	}
+	state := &agentcomm.State{
+		Databases: []agentcomm.DatabaseBackend{
+			{Kind: "lease-database", Type: "mysql", Host: "localhost", Port: 3306, Error: "connection refused"},
+			{Kind: "hosts-database", Type: "postgresql", Host: "db", Port: 5432, Reachable: true},
+			{Kind: "hosts-database", Type: "mysql", Host: "db2", Port: 3306, Reachable: true},
+		},
+	}
+	checkMachineInventory(dbMachine, state, fec, 80, 90)
+	require.Len(t, fec.Events, 2)
+	require.Equal(t, dbmodel.EvError, fec.Events[0].Level)
+	require.Contains(t, fec.Events[0].Text, "mysql lease-database at localhost:3306 is unreachable")
+	require.Equal(t, "connection refused", fec.Events[0].Details)
+	require.Equal(t, dbmodel.EvInfo, fec.Events[1].Level)
+	require.Contains(t, fec.Events[1].Text, "postgresql hosts-database at db:5432 is reachable again")
+}
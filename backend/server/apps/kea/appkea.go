@@ -395,13 +395,14 @@ func findChangesAndRaiseEvents(dbApp *dbmodel.App, daemonsMap map[string]*dbmode
 				// when creating the event below.
 				oldDaemon.App = dbApp
 				errStr := daemonsErrors[oldDaemon.Name]
-				ev := eventcenter.CreateEvent(dbmodel.EvError, "{daemon} is unreachable", errStr, dbApp.Machine, dbApp, oldDaemon)
+				ev := eventcenter.CreateEvent(dbmodel.EvError, "{daemon} is unreachable", errStr, dbApp.Machine, dbApp, oldDaemon,
+					dbmodel.EventCodeDaemonUnreachable)
 				events = append(events, ev)
 			}
 		}
 		// In addition, raise an event indicating that the whole app is unreachable.
 		if dbApp.Active {
-			ev := eventcenter.CreateEvent(dbmodel.EvError, "{app} is unreachable", dbApp.Machine, dbApp)
+			ev := eventcenter.CreateEvent(dbmodel.EvError, "{app} is unreachable", dbApp.Machine, dbApp, dbmodel.EventCodeAppUnreachable)
 			events = append(events, ev)
 		}
 		// First three values indicate that there is nothing to do in the database.
@@ -446,6 +447,7 @@ func findChangesAndRaiseEvents(dbApp *dbmodel.App, daemonsMap map[string]*dbmode
 		if daemon.Active != oldDaemon.Active {
 			lvl := dbmodel.EvWarning
 			text := "{daemon} is "
+			code := dbmodel.EventCodeDaemonReachable
 			if daemon.Active && !oldDaemon.Active {
 				// Daemon was inactive and now it is active again.
 				text += "reachable now"
@@ -454,15 +456,17 @@ func findChangesAndRaiseEvents(dbApp *dbmodel.App, daemonsMap map[string]*dbmode
 				// severity.
 				text += "unreachable"
 				lvl = dbmodel.EvError
+				code = dbmodel.EventCodeDaemonUnreachable
 			}
 			errStr := daemonsErrors[oldDaemon.Name]
-			ev := eventcenter.CreateEvent(lvl, text, errStr, dbApp.Machine, dbApp, oldDaemon)
+			ev := eventcenter.CreateEvent(lvl, text, errStr, dbApp.Machine, dbApp, oldDaemon, code)
 			events = append(events, ev)
 
 			// Check if daemon has been restarted.
 		} else if daemon.Uptime < oldDaemon.Uptime {
 			text := "{daemon} has been restarted"
-			ev := eventcenter.CreateEvent(dbmodel.EvWarning, text, dbApp.Machine, dbApp, oldDaemon)
+			ev := eventcenter.CreateEvent(dbmodel.EvWarning, text, dbApp.Machine, dbApp, oldDaemon,
+				dbmodel.EventCodeDaemonRestarted)
 			events = append(events, ev)
 		}
 
@@ -470,7 +474,9 @@ func findChangesAndRaiseEvents(dbApp *dbmodel.App, daemonsMap map[string]*dbmode
 		if daemon.Version != oldDaemon.Version {
 			text := fmt.Sprintf("{daemon} version changed from %s to %s",
 				oldDaemon.Version, daemon.Version)
-			ev := eventcenter.CreateEvent(dbmodel.EvWarning, text, dbApp.Machine, dbApp, oldDaemon)
+			ev := eventcenter.CreateEvent(dbmodel.EvWarning, text, dbApp.Machine, dbApp, oldDaemon,
+				dbmodel.EventCodeDaemonVersionChanged,
+				dbmodel.EventPayload{"oldVersion": oldDaemon.Version, "newVersion": daemon.Version})
 			events = append(events, ev)
 		}
 
@@ -496,12 +502,38 @@ func handleConfigEvent(daemon, oldDaemon *dbmodel.Daemon, events *[]*dbmodel.Eve
 		// Raise this event only if we're certain that the configuration has
 		// changed based on the comparison of the hash values.
 		text := "configuration change detected for {daemon}"
-		ev := eventcenter.CreateEvent(dbmodel.EvInfo, text, daemon)
+		ev := eventcenter.CreateEvent(dbmodel.EvInfo, text, daemon, dbmodel.EventCodeConfigChanged)
 		*events = append(*events, ev)
 	}
 	return false
 }
 
+// Stores the new revisions of the configurations of the app's Kea daemons
+// whose configurations have changed. It returns the event payloads with
+// the IDs of the added revisions and the revisions preceding them, by the
+// IDs of the daemons. The payloads are not returned for the first
+// revisions of the daemons' configurations.
+func commitConfigRevisions(dbIface interface{}, app *dbmodel.App, state *AppStateMeta) (map[int64]dbmodel.EventPayload, error) {
+	payloads := make(map[int64]dbmodel.EventPayload)
+	for _, daemon := range app.Daemons {
+		if state != nil && state.SameConfigDaemons[daemon.Name] {
+			continue
+		}
+		added, previous, err := dbmodel.AddKeaConfigRevisionIfChanged(dbIface, daemon)
+		if err != nil {
+			return nil, err
+		}
+		if added == nil || previous == nil {
+			continue
+		}
+		payloads[daemon.ID] = dbmodel.EventPayload{
+			"revisionId":         added.ID,
+			"previousRevisionId": previous.ID,
+		}
+	}
+	return payloads, nil
+}
+
 // Inserts or updates information about Kea app in the database. Next, it extracts
 // Kea's configurations and uses to either update or create new shared networks,
 // subnets and pools. Finally, the relations between the subnets and the Kea app
@@ -564,6 +596,12 @@ func CommitAppIntoDB(db *dbops.PgDB, app *dbmodel.App, eventCenter eventcenter.E
 		}
 	}
 
+	// The changed configurations are checked with the enabled linter rules.
+	disabledLintRules, err := dbmodel.GetDisabledConfigLintRules(db)
+	if err != nil {
+		return err
+	}
+
 	// Begin transaction.
 	tx, rollback, commit, err := dbops.Transaction(db)
 	if err != nil {
@@ -587,23 +625,48 @@ func CommitAppIntoDB(db *dbops.PgDB, app *dbmodel.App, eventCenter eventcenter.E
 	}
 
 	if newApp {
-		eventCenter.AddInfoEvent("added {app} on {machine}", app.Machine, app)
+		eventCenter.AddInfoEvent("added {app} on {machine}", app.Machine, app, dbmodel.EventCodeAppAdded)
 	}
 
 	for _, daemon := range deletedDaemons {
 		daemon.App = app
-		eventCenter.AddInfoEvent("removed {daemon} from {app}", app.Machine, app, daemon)
+		eventCenter.AddInfoEvent("removed {daemon} from {app}", app.Machine, app, daemon, dbmodel.EventCodeDaemonRemoved)
 	}
 	for _, daemon := range addedDaemons {
 		daemon.App = app
-		eventCenter.AddInfoEvent("added {daemon} to {app}", app.Machine, app, daemon)
+		eventCenter.AddInfoEvent("added {daemon} to {app}", app.Machine, app, daemon, dbmodel.EventCodeDaemonAdded)
+	}
+
+	// Store the new versions of the daemons' configurations. The events
+	// about the configuration changes refer to the stored revisions, so
+	// the changes can be looked up.
+	revisionPayloads, err := commitConfigRevisions(tx, app, state)
+	if err != nil {
+		return err
 	}
 	if state != nil {
 		for _, ev := range state.Events {
+			if ev.Code == dbmodel.EventCodeConfigChanged && ev.Relations != nil {
+				if payload, ok := revisionPayloads[ev.Relations.DaemonID]; ok {
+					if ev.Payload == nil {
+						ev.Payload = dbmodel.EventPayload{}
+					}
+					for key, value := range payload {
+						ev.Payload[key] = value
+					}
+				}
+			}
 			eventCenter.AddEvent(ev)
 		}
 	}
 
+	// Store the issues found in the changed configurations and raise the
+	// events about the new ones.
+	err = commitConfigLintFindings(tx, app, disabledLintRules, eventCenter, state)
+	if err != nil {
+		return err
+	}
+
 	// Associating an app with subnets is expensive operation. It involves finding
 	// local subnet id for each subnet. In order for this operation to be efficient
 	// we have to index subnets in the Kea configurations so the local subnet id
@@ -622,11 +685,12 @@ func CommitAppIntoDB(db *dbops.PgDB, app *dbmodel.App, eventCenter eventcenter.E
 		// add event per subnet only if there is not more than 10 subnets
 		if len(addedSubnets) < 10 {
 			for _, sn := range addedSubnets {
-				eventCenter.AddInfoEvent("added {subnet} to {app}", app, sn)
+				eventCenter.AddInfoEvent("added {subnet} to {app}", app, sn, dbmodel.EventCodeSubnetAdded)
 			}
 		}
 		t := fmt.Sprintf("added %d subnets to {app}", len(addedSubnets))
-		eventCenter.AddInfoEvent(t, app)
+		eventCenter.AddInfoEvent(t, app, dbmodel.EventCodeSubnetsAdded,
+			dbmodel.EventPayload{"count": len(addedSubnets)})
 	}
 
 	// For the given app, iterate over the global hosts and update their instances
@@ -646,6 +710,12 @@ func CommitAppIntoDB(db *dbops.PgDB, app *dbmodel.App, eventCenter eventcenter.E
 		if err != nil {
 			return err
 		}
+
+		// Compare the configurations of the HA peers.
+		err = commitHAConfigDiffs(tx, services, daemon, eventCenter, state)
+		if err != nil {
+			return err
+		}
 	}
 
 	// Commit the changes if everything went fine.
@@ -9,6 +9,7 @@ import (
 	agentcommtest "isc.org/stork/server/agentcomm/test"
 	dbmodel "isc.org/stork/server/database/model"
 	dbtest "isc.org/stork/server/database/test"
+	"isc.org/stork/server/eventcenter"
 	storktest "isc.org/stork/server/test"
 )
 
@@ -499,3 +500,73 @@ func TestCommitAppIntoDB(t *testing.T) {
 	require.Len(t, returned.AccessPoints, 1)
 	require.EqualValues(t, 2345, returned.AccessPoints[0].Port)
 }
+
+// Tests that the revisions of the daemons' configurations are stored when
+// the app is committed and that the events about the configuration changes
+// refer to the revisions.
+func TestCommitAppIntoDBConfigRevisions(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	fec := &storktest.FakeEventCenter{}
+
+	machine := &dbmodel.Machine{
+		Address:   "localhost",
+		AgentPort: 8080,
+	}
+	err := dbmodel.AddMachine(db, machine)
+	require.NoError(t, err)
+
+	daemon := dbmodel.NewKeaDaemon(dbmodel.DaemonNameDHCPv4, true)
+	err = daemon.SetConfigFromJSON(`{"Dhcp4": {"valid-lifetime": 1000}}`)
+	require.NoError(t, err)
+	app := &dbmodel.App{
+		MachineID: machine.ID,
+		Machine:   machine,
+		Type:      dbmodel.AppTypeKea,
+		Active:    true,
+		Daemons:   []*dbmodel.Daemon{daemon},
+	}
+	err = CommitAppIntoDB(db, app, fec, nil)
+	require.NoError(t, err)
+
+	revisions, total, err := dbmodel.GetKeaConfigRevisionsByPage(db, daemon.ID, 0, 10)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, total)
+	firstID := revisions[0].ID
+
+	// Change the configuration and raise the event about it.
+	err = daemon.SetConfigFromJSON(`{"Dhcp4": {"valid-lifetime": 2000}}`)
+	require.NoError(t, err)
+	daemon.App = app
+	state := &AppStateMeta{
+		Events: []*dbmodel.Event{
+			eventcenter.CreateEvent(dbmodel.EvInfo, "configuration change detected for {daemon}", daemon,
+				dbmodel.EventCodeConfigChanged),
+		},
+	}
+	fec.Events = nil
+	err = CommitAppIntoDB(db, app, fec, state)
+	require.NoError(t, err)
+
+	revisions, total, err = dbmodel.GetKeaConfigRevisionsByPage(db, daemon.ID, 0, 10)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, total)
+
+	var configEvent *dbmodel.Event
+	for _, ev := range fec.Events {
+		if ev.Code == dbmodel.EventCodeConfigChanged {
+			configEvent = ev
+		}
+	}
+	require.NotNil(t, configEvent)
+	require.Equal(t, revisions[0].ID, configEvent.Payload["revisionId"])
+	require.Equal(t, firstID, configEvent.Payload["previousRevisionId"])
+
+	// No new revision when the configuration remains the same.
+	err = CommitAppIntoDB(db, app, fec, nil)
+	require.NoError(t, err)
+	_, total, err = dbmodel.GetKeaConfigRevisionsByPage(db, daemon.ID, 0, 10)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, total)
+}
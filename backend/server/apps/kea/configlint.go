@@ -0,0 +1,562 @@
+package kea
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	dbops "isc.org/stork/server/database"
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/eventcenter"
+)
+
+// Rule checking the configuration of the Kea DHCP daemon against the best
+// practices. Each rule reports the issues found as the texts of the
+// findings. The rules can be disabled individually by their names.
+type ConfigLintRule struct {
+	Name        string
+	Description string
+	check       func(config *lintConfig) []string
+}
+
+// Scope of the configuration parameters, i.e. the global scope, the shared
+// network or the subnet. The parameters of the scope include the parameters
+// inherited from the enclosing scopes. The own parameters are the ones
+// specified in the scope.
+type lintScope struct {
+	own    map[string]interface{}
+	params map[string]interface{}
+}
+
+// Subnet of the checked configuration.
+type lintSubnet struct {
+	lintScope
+	subnet dbmodel.KeaConfigSubnet
+}
+
+// Configuration of the Kea DHCP daemon parsed for the linter rules.
+type lintConfig struct {
+	config             *dbmodel.KeaConfig
+	family             int
+	global             lintScope
+	networks           map[string]lintScope
+	networkNames       []string
+	subnets            []lintSubnet
+	globalReservations []dbmodel.KeaConfigReservation
+}
+
+// Rules run by the linter in the order of their findings.
+var configLintRules = []ConfigLintRule{
+	{
+		Name:        "pool_outside_subnet",
+		Description: "Address pool does not belong to the subnet prefix.",
+		check:       checkPoolsOutsideSubnet,
+	},
+	{
+		Name:        "overlapping_pools",
+		Description: "Address or prefix pools of the subnet overlap.",
+		check:       checkOverlappingPools,
+	},
+	{
+		Name:        "in_pool_reservation",
+		Description: "Reserved address is within the pool while the reservations are configured to be out of the pools.",
+		check:       checkInPoolReservations,
+	},
+	{
+		Name:        "duplicate_reservation_identifier",
+		Description: "More than one host reservation in the subnet or global scope has the same identifier.",
+		check:       checkDuplicateReservationIdentifiers,
+	},
+	{
+		Name:        "lease_cmds_missing",
+		Description: "The lease_cmds hooks library is not loaded, so the leases cannot be searched and managed.",
+		check:       checkLeaseCmdsHook,
+	},
+	{
+		Name:        "stat_cmds_missing",
+		Description: "The stat_cmds hooks library is not loaded, so the subnet statistics cannot be fetched.",
+		check:       checkStatCmdsHook,
+	},
+	{
+		Name:        "ha_heartbeat_disabled",
+		Description: "High Availability is configured with the heartbeat disabled, so the partner failures are not detected.",
+		check:       checkHAHeartbeat,
+	},
+	{
+		Name:        "conflicting_lifetimes",
+		Description: "Renew and rebind timers, valid and preferred lifetimes or their bounds conflict with each other.",
+		check:       checkConflictingLifetimes,
+	},
+}
+
+// Returns all linter rules.
+func GetConfigLintRules() []ConfigLintRule {
+	return configLintRules
+}
+
+// Returns the linter rule with the given name or nil if it doesn't
+// exist.
+func GetConfigLintRule(name string) *ConfigLintRule {
+	for i := range configLintRules {
+		if configLintRules[i].Name == name {
+			return &configLintRules[i]
+		}
+	}
+	return nil
+}
+
+// Returns a copy of the parent parameters overridden by the own
+// parameters.
+func mergeLintParams(parent, own map[string]interface{}) map[string]interface{} {
+	params := make(map[string]interface{})
+	for key, value := range parent {
+		params[key] = value
+	}
+	for key, value := range own {
+		params[key] = value
+	}
+	return params
+}
+
+// Parses the configuration of the Kea DHCP daemon. It returns nil if the
+// daemon is not a DHCP daemon or has no configuration.
+func newLintConfig(daemon *dbmodel.Daemon) *lintConfig {
+	if daemon.KeaDaemon == nil || daemon.KeaDaemon.Config == nil {
+		return nil
+	}
+	var family int
+	switch daemon.Name {
+	case dbmodel.DaemonNameDHCPv4:
+		family = 4
+	case dbmodel.DaemonNameDHCPv6:
+		family = 6
+	default:
+		return nil
+	}
+	config := daemon.KeaDaemon.Config
+	rootName, ok := config.GetRootName()
+	if !ok {
+		return nil
+	}
+	root, ok := (*config)[rootName].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	lc := &lintConfig{
+		config: config,
+		family: family,
+		global: lintScope{
+			own:    root,
+			params: root,
+		},
+		networks: make(map[string]lintScope),
+	}
+	subnetsName := fmt.Sprintf("subnet%d", family)
+
+	addSubnets := func(list interface{}, parent map[string]interface{}) {
+		subnets, ok := list.([]interface{})
+		if !ok {
+			return
+		}
+		for _, s := range subnets {
+			own, ok := s.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			subnet := lintSubnet{
+				lintScope: lintScope{
+					own:    own,
+					params: mergeLintParams(parent, own),
+				},
+			}
+			_ = mapstructure.Decode(own, &subnet.subnet)
+			lc.subnets = append(lc.subnets, subnet)
+		}
+	}
+
+	addSubnets(root[subnetsName], root)
+	if networks, ok := root["shared-networks"].([]interface{}); ok {
+		for _, n := range networks {
+			own, ok := n.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := own["name"].(string)
+			scope := lintScope{
+				own:    own,
+				params: mergeLintParams(root, own),
+			}
+			lc.networks[name] = scope
+			lc.networkNames = append(lc.networkNames, name)
+			addSubnets(own[subnetsName], scope.params)
+		}
+	}
+	if reservations, ok := root["reservations"].([]interface{}); ok {
+		_ = mapstructure.Decode(reservations, &lc.globalReservations)
+	}
+	return lc
+}
+
+// Address range of the pool.
+type lintPoolRange struct {
+	lower, upper net.IP
+	text         string
+}
+
+// Returns the address ranges of the subnet's address pools. The invalid
+// pools are skipped.
+func getLintPoolRanges(subnet *dbmodel.KeaConfigSubnet) []lintPoolRange {
+	var ranges []lintPoolRange
+	for _, p := range subnet.Pools {
+		pool, err := dbmodel.NewAddressPoolFromRange(p.Pool)
+		if err != nil {
+			continue
+		}
+		lower := net.ParseIP(pool.LowerBound)
+		upper := net.ParseIP(pool.UpperBound)
+		if lower == nil || upper == nil {
+			continue
+		}
+		ranges = append(ranges, lintPoolRange{
+			lower: lower,
+			upper: upper,
+			text:  strings.TrimSpace(p.Pool),
+		})
+	}
+	return ranges
+}
+
+// Checks if the address belongs to the pool.
+func (r lintPoolRange) contains(ip net.IP) bool {
+	return bytes.Compare(r.lower.To16(), ip.To16()) <= 0 && bytes.Compare(ip.To16(), r.upper.To16()) <= 0
+}
+
+// Reports the address pools which don't belong to the subnet prefix.
+func checkPoolsOutsideSubnet(config *lintConfig) (findings []string) {
+	for i := range config.subnets {
+		subnet := &config.subnets[i].subnet
+		subnetNet, err := parseSubnetPrefix(subnet.Subnet)
+		if err != nil {
+			continue
+		}
+		for _, r := range getLintPoolRanges(subnet) {
+			if !subnetNet.Contains(r.lower) || !subnetNet.Contains(r.upper) {
+				findings = append(findings, fmt.Sprintf("pool %s does not belong to the subnet %s", r.text, subnet.Subnet))
+			}
+		}
+	}
+	return findings
+}
+
+// Reports the overlapping address pools and prefix pools of the subnets.
+func checkOverlappingPools(config *lintConfig) (findings []string) {
+	for i := range config.subnets {
+		subnet := &config.subnets[i].subnet
+		ranges := getLintPoolRanges(subnet)
+		for j := range ranges {
+			for k := 0; k < j; k++ {
+				if bytes.Compare(ranges[j].lower.To16(), ranges[k].upper.To16()) <= 0 &&
+					bytes.Compare(ranges[k].lower.To16(), ranges[j].upper.To16()) <= 0 {
+					findings = append(findings, fmt.Sprintf("pool %s overlaps with the pool %s in the subnet %s", ranges[j].text, ranges[k].text, subnet.Subnet))
+				}
+			}
+		}
+		var prefixes []*net.IPNet
+		for _, p := range subnet.PdPools {
+			_, poolNet, err := net.ParseCIDR(fmt.Sprintf("%s/%d", p.Prefix, p.PrefixLen))
+			if err != nil {
+				continue
+			}
+			for _, other := range prefixes {
+				if prefixesOverlap(poolNet, other) {
+					findings = append(findings, fmt.Sprintf("prefix pool %s overlaps with the prefix pool %s in the subnet %s", poolNet, other, subnet.Subnet))
+				}
+			}
+			prefixes = append(prefixes, poolNet)
+		}
+	}
+	return findings
+}
+
+// Checks if the reservations in the scope are expected to be out of the
+// pools. The reservations-out-of-pool parameter takes precedence over the
+// reservation-mode parameter used by the older Kea versions.
+func (scope *lintScope) reservationsOutOfPool() bool {
+	if outOfPool, ok := scope.params["reservations-out-of-pool"].(bool); ok {
+		return outOfPool
+	}
+	mode, _ := scope.params["reservation-mode"].(string)
+	return mode == "out-of-pool"
+}
+
+// Reports the reserved addresses within the pools of the subnets where
+// the reservations are expected to be out of the pools. Such addresses
+// may be assigned to other clients.
+func checkInPoolReservations(config *lintConfig) (findings []string) {
+	for i := range config.subnets {
+		subnet := &config.subnets[i]
+		if !subnet.reservationsOutOfPool() {
+			continue
+		}
+		ranges := getLintPoolRanges(&subnet.subnet)
+		for _, r := range subnet.subnet.Reservations {
+			addresses := r.IPAddresses
+			if len(r.IPAddress) > 0 {
+				addresses = append([]string{r.IPAddress}, addresses...)
+			}
+			for _, address := range addresses {
+				ip := net.ParseIP(strings.TrimSpace(address))
+				if ip == nil {
+					continue
+				}
+				for _, pr := range ranges {
+					if pr.contains(ip) {
+						findings = append(findings, fmt.Sprintf("reserved address %s is within the pool %s in the subnet %s configured with the out-of-pool reservations", address, pr.text, subnet.subnet.Subnet))
+						break
+					}
+				}
+			}
+		}
+	}
+	return findings
+}
+
+// Returns the identifiers of the reservation in the type:value form.
+func getLintReservationIdentifiers(r *dbmodel.KeaConfigReservation) (identifiers []string) {
+	for _, id := range []struct {
+		name, value string
+	}{
+		{"hw-address", r.HWAddress},
+		{"duid", r.DUID},
+		{"client-id", r.ClientID},
+		{"circuit-id", r.CircuitID},
+		{"flex-id", r.FlexID},
+	} {
+		if len(id.value) > 0 {
+			identifiers = append(identifiers, id.name+" "+strings.ToLower(id.value))
+		}
+	}
+	return identifiers
+}
+
+// Reports the identifiers used by more than one reservation in the same
+// subnet or in the global scope. Only one of these reservations is used
+// by the server.
+func checkDuplicateReservationIdentifiers(config *lintConfig) (findings []string) {
+	check := func(reservations []dbmodel.KeaConfigReservation, scope string) {
+		seen := make(map[string]int)
+		for i := range reservations {
+			for _, id := range getLintReservationIdentifiers(&reservations[i]) {
+				seen[id]++
+				if seen[id] == 2 {
+					findings = append(findings, fmt.Sprintf("more than one reservation %s has the %s", scope, id))
+				}
+			}
+		}
+	}
+	check(config.globalReservations, "in the global scope")
+	for i := range config.subnets {
+		check(config.subnets[i].subnet.Reservations, "in the subnet "+config.subnets[i].subnet.Subnet)
+	}
+	return findings
+}
+
+// Reports missing lease_cmds hooks library.
+func checkLeaseCmdsHook(config *lintConfig) []string {
+	if _, _, ok := config.config.GetHooksLibrary("libdhcp_lease_cmds"); !ok {
+		return []string{"the lease_cmds hooks library is not loaded"}
+	}
+	return nil
+}
+
+// Reports missing stat_cmds hooks library.
+func checkStatCmdsHook(config *lintConfig) []string {
+	if _, _, ok := config.config.GetHooksLibrary("libdhcp_stat_cmds"); !ok {
+		return []string{"the stat_cmds hooks library is not loaded"}
+	}
+	return nil
+}
+
+// Reports the High Availability configured with the heartbeat disabled.
+// The passive-backup mode doesn't use the heartbeat.
+func checkHAHeartbeat(config *lintConfig) []string {
+	path, ha, ok := config.config.GetHAHooksLibrary()
+	if !ok || len(path) == 0 || ha.Mode == nil || *ha.Mode == "passive-backup" {
+		return nil
+	}
+	if ha.HeartbeatDelay != nil && *ha.HeartbeatDelay == 0 {
+		return []string{fmt.Sprintf("High Availability in the %s mode is configured with the heartbeat-delay of 0 which disables the heartbeat", *ha.Mode)}
+	}
+	return nil
+}
+
+// Returns the numeric value of the parameter.
+func getLintNumber(params map[string]interface{}, name string) (float64, bool) {
+	switch value := params[name].(type) {
+	case float64:
+		return value, true
+	case int:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	default:
+		return 0, false
+	}
+}
+
+// Names of the parameters checked for the conflicting lifetimes.
+var lintLifetimeParams = []string{
+	"renew-timer", "rebind-timer", "valid-lifetime", "min-valid-lifetime", "max-valid-lifetime",
+	"preferred-lifetime", "min-preferred-lifetime", "max-preferred-lifetime",
+}
+
+// Returns the conflicts between the timers and the lifetimes of the scope.
+func getLifetimeConflicts(params map[string]interface{}) (conflicts []string) {
+	compare := func(lower, upper string, strict bool) {
+		l, okl := getLintNumber(params, lower)
+		u, oku := getLintNumber(params, upper)
+		if !okl || !oku {
+			return
+		}
+		if l > u || (strict && l == u) {
+			relation := "greater than"
+			if l == u {
+				relation = "equal to"
+			}
+			conflicts = append(conflicts, fmt.Sprintf("%s %v is %s %s %v", lower, l, relation, upper, u))
+		}
+	}
+	compare("renew-timer", "rebind-timer", false)
+	compare("rebind-timer", "valid-lifetime", true)
+	compare("renew-timer", "valid-lifetime", true)
+	compare("min-valid-lifetime", "valid-lifetime", false)
+	compare("valid-lifetime", "max-valid-lifetime", false)
+	compare("min-valid-lifetime", "max-valid-lifetime", false)
+	compare("preferred-lifetime", "valid-lifetime", false)
+	compare("min-preferred-lifetime", "preferred-lifetime", false)
+	compare("preferred-lifetime", "max-preferred-lifetime", false)
+	return conflicts
+}
+
+// Checks if any of the lifetime parameters is specified in the scope.
+func (scope *lintScope) hasOwnLifetimes() bool {
+	for _, name := range lintLifetimeParams {
+		if _, ok := scope.own[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Reports the timers and the lifetimes conflicting with each other. The
+// scopes are checked with the inherited parameters, but only when they
+// specify any of these parameters, so the global conflict is reported
+// once rather than for each subnet.
+func checkConflictingLifetimes(config *lintConfig) (findings []string) {
+	report := func(scope *lintScope, where string) {
+		for _, conflict := range getLifetimeConflicts(scope.params) {
+			findings = append(findings, fmt.Sprintf("%s %s", conflict, where))
+		}
+	}
+	report(&config.global, "in the global scope")
+	for _, name := range config.networkNames {
+		network := config.networks[name]
+		if network.hasOwnLifetimes() {
+			report(&network, fmt.Sprintf("in the shared network %s", name))
+		}
+	}
+	for i := range config.subnets {
+		if config.subnets[i].hasOwnLifetimes() {
+			report(&config.subnets[i].lintScope, fmt.Sprintf("in the subnet %s", config.subnets[i].subnet.Subnet))
+		}
+	}
+	return findings
+}
+
+// Checks the configuration of the Kea DHCP daemon with the linter rules
+// except the disabled ones. It returns no findings for the other daemons
+// and the daemons without configuration.
+func LintDaemonConfig(daemon *dbmodel.Daemon, disabled map[string]bool) []dbmodel.ConfigLintFinding {
+	config := newLintConfig(daemon)
+	if config == nil {
+		return nil
+	}
+	var findings []dbmodel.ConfigLintFinding
+	for _, rule := range configLintRules {
+		if disabled[rule.Name] {
+			continue
+		}
+		for _, text := range rule.check(config) {
+			findings = append(findings, dbmodel.ConfigLintFinding{
+				DaemonID: daemon.ID,
+				Rule:     rule.Name,
+				Text:     text,
+			})
+		}
+	}
+	return findings
+}
+
+// Checks the configuration of the daemon, stores the findings and raises
+// the warning events about the new findings. The daemon must have the
+// back pointer to the app. The dbIface object may either be a pg.DB
+// object or pg.Tx.
+func commitDaemonConfigLintFindings(dbIface interface{}, daemon *dbmodel.Daemon, disabled map[string]bool, eventCenter eventcenter.EventCenter) error {
+	if newLintConfig(daemon) == nil {
+		return nil
+	}
+	added, err := dbmodel.ReplaceConfigLintFindings(dbIface, daemon.ID, LintDaemonConfig(daemon, disabled))
+	if err != nil {
+		return err
+	}
+	for _, finding := range added {
+		eventCenter.AddWarningEvent("found issue in configuration of {daemon}", finding.Text, daemon,
+			dbmodel.EventCodeConfigLintFinding, dbmodel.EventPayload{"rule": finding.Rule})
+	}
+	return nil
+}
+
+// Checks the configurations of the app's Kea DHCP daemons whose
+// configurations have changed. It is called when the app state is
+// committed into the database.
+func commitConfigLintFindings(dbIface interface{}, app *dbmodel.App, disabled map[string]bool, eventCenter eventcenter.EventCenter, state *AppStateMeta) error {
+	for _, daemon := range app.Daemons {
+		if state != nil && state.SameConfigDaemons[daemon.Name] {
+			continue
+		}
+		daemon.App = app
+		if err := commitDaemonConfigLintFindings(dbIface, daemon, disabled, eventCenter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Checks the configurations of all Kea DHCP daemons again, e.g. after
+// enabling or disabling the linter rules. The findings of the disabled
+// rules are removed.
+func LintAllDaemonConfigs(db *dbops.PgDB, eventCenter eventcenter.EventCenter) error {
+	disabled, err := dbmodel.GetDisabledConfigLintRules(db)
+	if err != nil {
+		return err
+	}
+	apps, err := dbmodel.GetAppsByType(db, dbmodel.AppTypeKea)
+	if err != nil {
+		return errors.WithMessage(err, "failed to fetch Kea apps to check their configurations")
+	}
+	for i := range apps {
+		app := &apps[i]
+		for _, daemon := range app.Daemons {
+			daemon.App = app
+			if err = commitDaemonConfigLintFindings(db, daemon, disabled, eventCenter); err != nil {
+				log.Errorf("problem with checking configuration of daemon %d: %+v", daemon.ID, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package kea
+
+import (
+	"testing"
+
+	require "github.com/stretchr/testify/require"
+
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	storktest "isc.org/stork/server/test"
+)
+
+// Returns the daemon with the given configuration.
+func getTestLintDaemon(t *testing.T, name, config string) *dbmodel.Daemon {
+	daemon := dbmodel.NewKeaDaemon(name, true)
+	err := daemon.SetConfigFromJSON(config)
+	require.NoError(t, err)
+	return daemon
+}
+
+// Returns the texts of the findings of the rule.
+func getLintFindingTexts(findings []dbmodel.ConfigLintFinding, rule string) (texts []string) {
+	for _, finding := range findings {
+		if finding.Rule == rule {
+			texts = append(texts, finding.Text)
+		}
+	}
+	return texts
+}
+
+// Test that the issues in the DHCPv4 configuration are found.
+func TestLintDaemonConfig(t *testing.T) {
+	daemon := getTestLintDaemon(t, dbmodel.DaemonNameDHCPv4, `{
+        "Dhcp4": {
+            "valid-lifetime": 3600,
+            "renew-timer": 900,
+            "rebind-timer": 1800,
+            "reservation-mode": "out-of-pool",
+            "reservations": [
+                {"hw-address": "01:02:03:04:05:06", "ip-address": "10.0.0.1"},
+                {"hw-address": "01:02:03:04:05:06", "ip-address": "10.0.0.2"}
+            ],
+            "subnet4": [
+                {
+                    "id": 1,
+                    "subnet": "192.0.2.0/24",
+                    "rebind-timer": 3600,
+                    "pools": [
+                        {"pool": "192.0.2.10 - 192.0.2.100"},
+                        {"pool": "192.0.2.50 - 192.0.2.150"},
+                        {"pool": "192.0.3.1 - 192.0.3.10"}
+                    ],
+                    "reservations": [
+                        {"hw-address": "0a:0b:0c:0d:0e:0f", "ip-address": "192.0.2.20"},
+                        {"client-id": "01:02", "ip-address": "192.0.2.200"},
+                        {"client-id": "01:02", "ip-address": "192.0.2.201"}
+                    ]
+                }
+            ],
+            "shared-networks": [
+                {
+                    "name": "foo",
+                    "reservations-out-of-pool": false,
+                    "valid-lifetime": 1000,
+                    "subnet4": [
+                        {
+                            "id": 2,
+                            "subnet": "198.51.100.0/24",
+                            "pools": [{"pool": "198.51.100.0/25"}],
+                            "reservations": [
+                                {"hw-address": "0a:0b:0c:0d:0e:0f", "ip-address": "198.51.100.20"}
+                            ]
+                        }
+                    ]
+                }
+            ],
+            "hooks-libraries": [
+                {"library": "/usr/lib/kea/hooks/libdhcp_lease_cmds.so"},
+                {
+                    "library": "/usr/lib/kea/hooks/libdhcp_ha.so",
+                    "parameters": {
+                        "high-availability": [{
+                            "this-server-name": "server1",
+                            "mode": "load-balancing",
+                            "heartbeat-delay": 0,
+                            "peers": []
+                        }]
+                    }
+                }
+            ]
+        }
+    }`)
+	daemon.ID = 5
+
+	findings := LintDaemonConfig(daemon, nil)
+	for _, finding := range findings {
+		require.EqualValues(t, 5, finding.DaemonID)
+	}
+
+	require.Equal(t, []string{"pool 192.0.3.1 - 192.0.3.10 does not belong to the subnet 192.0.2.0/24"},
+		getLintFindingTexts(findings, "pool_outside_subnet"))
+	require.Equal(t, []string{"pool 192.0.2.50 - 192.0.2.150 overlaps with the pool 192.0.2.10 - 192.0.2.100 in the subnet 192.0.2.0/24"},
+		getLintFindingTexts(findings, "overlapping_pools"))
+	// The shared network overrides the reservation mode.
+	require.Equal(t, []string{"reserved address 192.0.2.20 is within the pool 192.0.2.10 - 192.0.2.100 in the subnet 192.0.2.0/24 configured with the out-of-pool reservations"},
+		getLintFindingTexts(findings, "in_pool_reservation"))
+	require.Equal(t, []string{
+		"more than one reservation in the global scope has the hw-address 01:02:03:04:05:06",
+		"more than one reservation in the subnet 192.0.2.0/24 has the client-id 01:02",
+	}, getLintFindingTexts(findings, "duplicate_reservation_identifier"))
+	require.Empty(t, getLintFindingTexts(findings, "lease_cmds_missing"))
+	require.Len(t, getLintFindingTexts(findings, "stat_cmds_missing"), 1)
+	require.Len(t, getLintFindingTexts(findings, "ha_heartbeat_disabled"), 1)
+	require.Equal(t, []string{
+		"rebind-timer 1800 is greater than valid-lifetime 1000 in the shared network foo",
+		"rebind-timer 3600 is equal to valid-lifetime 3600 in the subnet 192.0.2.0/24",
+	}, getLintFindingTexts(findings, "conflicting_lifetimes"))
+
+	// Disabled rules.
+	findings = LintDaemonConfig(daemon, map[string]bool{
+		"stat_cmds_missing":     true,
+		"conflicting_lifetimes": true,
+	})
+	require.Empty(t, getLintFindingTexts(findings, "stat_cmds_missing"))
+	require.Empty(t, getLintFindingTexts(findings, "conflicting_lifetimes"))
+	require.NotEmpty(t, getLintFindingTexts(findings, "overlapping_pools"))
+}
+
+// Test that the issues in the DHCPv6 configuration are found.
+func TestLintDaemonConfigIPv6(t *testing.T) {
+	daemon := getTestLintDaemon(t, dbmodel.DaemonNameDHCPv6, `{
+        "Dhcp6": {
+            "preferred-lifetime": 4000,
+            "valid-lifetime": 3000,
+            "reservations-out-of-pool": true,
+            "subnet6": [
+                {
+                    "id": 1,
+                    "subnet": "2001:db8:1::/64",
+                    "pools": [{"pool": "2001:db8:1::10 - 2001:db8:1::100"}],
+                    "pd-pools": [
+                        {"prefix": "3000::", "prefix-len": 48, "delegated-len": 64},
+                        {"prefix": "3000::", "prefix-len": 56, "delegated-len": 64}
+                    ],
+                    "reservations": [
+                        {"duid": "01:02:03", "ip-addresses": ["2001:db8:1::20"]}
+                    ]
+                }
+            ],
+            "hooks-libraries": [
+                {"library": "libdhcp_stat_cmds.so"}
+            ]
+        }
+    }`)
+
+	findings := LintDaemonConfig(daemon, nil)
+	require.Equal(t, []string{"prefix pool 3000::/56 overlaps with the prefix pool 3000::/48 in the subnet 2001:db8:1::/64"},
+		getLintFindingTexts(findings, "overlapping_pools"))
+	require.Len(t, getLintFindingTexts(findings, "in_pool_reservation"), 1)
+	require.Len(t, getLintFindingTexts(findings, "lease_cmds_missing"), 1)
+	require.Empty(t, getLintFindingTexts(findings, "stat_cmds_missing"))
+	require.Equal(t, []string{"preferred-lifetime 4000 is greater than valid-lifetime 3000 in the global scope"},
+		getLintFindingTexts(findings, "conflicting_lifetimes"))
+}
+
+// Test that the configurations of the daemons other than the DHCP
+// daemons are not checked.
+func TestLintDaemonConfigNonDHCP(t *testing.T) {
+	daemon := getTestLintDaemon(t, "ca", `{"Control-agent": {}}`)
+	require.Empty(t, LintDaemonConfig(daemon, nil))
+
+	daemon = dbmodel.NewKeaDaemon(dbmodel.DaemonNameDHCPv4, true)
+	require.Empty(t, LintDaemonConfig(daemon, nil))
+
+	require.NotNil(t, GetConfigLintRule("overlapping_pools"))
+	require.Nil(t, GetConfigLintRule("foo"))
+}
+
+// Returns the events raised for the config lint findings.
+func getLintEvents(fec *storktest.FakeEventCenter) (events []*dbmodel.Event) {
+	for _, ev := range fec.Events {
+		if ev.Code == dbmodel.EventCodeConfigLintFinding {
+			events = append(events, ev)
+		}
+	}
+	return events
+}
+
+// Test that the findings are stored when the app is committed, that the
+// events are raised for the new findings only and that the findings of
+// the disabled rules are removed.
+func TestCommitAppIntoDBConfigLint(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	fec := &storktest.FakeEventCenter{}
+
+	machine := &dbmodel.Machine{
+		Address:   "localhost",
+		AgentPort: 8080,
+	}
+	err := dbmodel.AddMachine(db, machine)
+	require.NoError(t, err)
+
+	daemon := getTestLintDaemon(t, dbmodel.DaemonNameDHCPv4, `{"Dhcp4": {
+        "hooks-libraries": [{"library": "libdhcp_lease_cmds.so"}]
+    }}`)
+	app := &dbmodel.App{
+		MachineID: machine.ID,
+		Machine:   machine,
+		Type:      dbmodel.AppTypeKea,
+		Active:    true,
+		Daemons:   []*dbmodel.Daemon{daemon},
+	}
+	err = CommitAppIntoDB(db, app, fec, nil)
+	require.NoError(t, err)
+
+	findings, err := dbmodel.GetConfigLintFindingsByDaemon(db, daemon.ID)
+	require.NoError(t, err)
+	require.Len(t, findings, 1)
+	require.Equal(t, "stat_cmds_missing", findings[0].Rule)
+	events := getLintEvents(fec)
+	require.Len(t, events, 1)
+	require.Equal(t, dbmodel.EvWarning, events[0].Level)
+	require.Equal(t, "stat_cmds_missing", events[0].Payload["rule"])
+	require.EqualValues(t, daemon.ID, events[0].Relations.DaemonID)
+
+	// The same finding doesn't raise the event again.
+	fec.Events = nil
+	err = daemon.SetConfigFromJSON(`{"Dhcp4": {
+        "valid-lifetime": 1000,
+        "rebind-timer": 2000,
+        "hooks-libraries": [{"library": "libdhcp_lease_cmds.so"}]
+    }}`)
+	require.NoError(t, err)
+	err = CommitAppIntoDB(db, app, fec, nil)
+	require.NoError(t, err)
+	findings, err = dbmodel.GetConfigLintFindingsByDaemon(db, daemon.ID)
+	require.NoError(t, err)
+	require.Len(t, findings, 2)
+	events = getLintEvents(fec)
+	require.Len(t, events, 1)
+	require.Equal(t, "conflicting_lifetimes", events[0].Payload["rule"])
+
+	// Disabling the rule removes its findings.
+	err = dbmodel.SetConfigLintRuleEnabled(db, "stat_cmds_missing", false)
+	require.NoError(t, err)
+	err = LintAllDaemonConfigs(db, fec)
+	require.NoError(t, err)
+	findings, err = dbmodel.GetConfigLintFindingsByDaemon(db, daemon.ID)
+	require.NoError(t, err)
+	require.Len(t, findings, 1)
+	require.Equal(t, "conflicting_lifetimes", findings[0].Rule)
+}
@@ -0,0 +1,426 @@
+package kea
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	keactrl "isc.org/stork/appctrl/kea"
+	"isc.org/stork/server/agentcomm"
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/eventcenter"
+)
+
+// Outcomes of pushing the configuration to the Kea daemon.
+const (
+	// The configuration has been rejected by config-test and the daemon
+	// still uses its previous configuration.
+	ConfigPushRejected = "rejected"
+	// The configuration could not be applied or verified, and the
+	// previous configuration could not be restored.
+	ConfigPushFailed = "failed"
+	// The configuration has been applied but it could not be verified,
+	// so the previous configuration has been restored.
+	ConfigPushRolledBack = "rolled-back"
+	// The configuration has been applied and verified.
+	ConfigPushApplied = "applied"
+)
+
+// Default interval between the checks of the daemon's status during the
+// grace period following the configuration push.
+const ConfigPushCheckInterval = 5 * time.Second
+
+// Names of the root nodes of the configurations of the daemons to which
+// the configuration can be pushed.
+var configPushRootNames = map[string]string{
+	dbmodel.DaemonNameDHCPv4: "Dhcp4",
+	dbmodel.DaemonNameDHCPv6: "Dhcp6",
+}
+
+// Single command sent while pushing the configuration and its outcome.
+// The communication errors are reported with the error result and the
+// error message as the text.
+type ConfigPushStep struct {
+	Command string
+	Result  int
+	Text    string
+}
+
+// Outcome of pushing the configuration with the list of the commands
+// sent to the daemon.
+type ConfigPushResult struct {
+	Status string
+	Steps  []ConfigPushStep
+}
+
+// Configuration to be pushed to the Kea DHCP daemon. The daemon must
+// include the app with the access points and the machine. The previous
+// configuration is restored when the pushed configuration cannot be
+// verified. The configuration is also written to the daemon's
+// configuration file if Write is set. The user is optional and, when
+// specified, the events refer to the user who pushed the configuration.
+type ConfigPush struct {
+	Daemon   *dbmodel.Daemon
+	Config   *dbmodel.KeaConfig
+	Previous *dbmodel.KeaConfig
+	Write    bool
+	User     *dbmodel.SystemUser
+
+	// Generation of the daemon's configuration set by this push.
+	generation int64
+}
+
+// Serializes the pushes to a single daemon and the checks made while
+// watching the daemon after the push. The generation is incremented by
+// each push, so the watching of the superseded push stops without
+// restoring the configuration preceding it.
+type configPushLock struct {
+	mutex      sync.Mutex
+	generation int64
+}
+
+var (
+	configPushLocks      = make(map[int64]*configPushLock)
+	configPushLocksMutex sync.Mutex
+)
+
+// Returns the lock serializing the pushes to the daemon with the given ID.
+func getConfigPushLock(daemonID int64) *configPushLock {
+	configPushLocksMutex.Lock()
+	defer configPushLocksMutex.Unlock()
+	lock, ok := configPushLocks[daemonID]
+	if !ok {
+		lock = &configPushLock{}
+		configPushLocks[daemonID] = lock
+	}
+	return lock
+}
+
+// Checks if the configuration can be pushed to the daemon, i.e. if the
+// daemon is a Kea DHCP daemon and the configuration is meant for it.
+func (push *ConfigPush) Validate() error {
+	if push.Daemon == nil || push.Daemon.App == nil || push.Daemon.App.Machine == nil {
+		return errors.New("daemon with the app and the machine is required to push the configuration")
+	}
+	expectedRoot, ok := configPushRootNames[push.Daemon.Name]
+	if !ok {
+		return errors.Errorf("pushing the configuration to the %s daemon is not supported", push.Daemon.Name)
+	}
+	if push.Config == nil {
+		return errors.New("configuration to push is missing")
+	}
+	root, ok := push.Config.GetRootName()
+	if !ok || root != expectedRoot {
+		return errors.Errorf("configuration for the %s daemon must have the single %s root node", push.Daemon.Name, expectedRoot)
+	}
+	return nil
+}
+
+// Checks if the actual configuration includes all parameters of the
+// submitted configuration with the same values. The parameters which are
+// not submitted, e.g. the defaults added by Kea, are ignored. The lists
+// must have the same lengths and their elements are compared in order.
+func containsConfig(actual, submitted interface{}) bool {
+	switch submittedValue := submitted.(type) {
+	case map[string]interface{}:
+		actualValue, ok := actual.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, value := range submittedValue {
+			if !containsConfig(actualValue[key], value) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		actualValue, ok := actual.([]interface{})
+		if !ok || len(actualValue) != len(submittedValue) {
+			return false
+		}
+		for i := range submittedValue {
+			if !containsConfig(actualValue[i], submittedValue[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(actual, submitted)
+	}
+}
+
+// Converts the configuration to the generic JSON representation, so the
+// values of the same parameters have the same types regardless of the
+// source of the configuration.
+func normalizeConfig(config map[string]interface{}) interface{} {
+	bytes, err := json.Marshal(config)
+	if err != nil {
+		return nil
+	}
+	var normalized interface{}
+	if err = json.Unmarshal(bytes, &normalized); err != nil {
+		return nil
+	}
+	return normalized
+}
+
+// Returns the objects the events about the push refer to, followed by
+// the details, the code and the payload.
+func (push *ConfigPush) eventObjects(details string, code dbmodel.EventCode, payload dbmodel.EventPayload) []interface{} {
+	objects := []interface{}{push.Daemon, push.Daemon.App, push.Daemon.App.Machine}
+	if push.User != nil {
+		objects = append(objects, push.User)
+	}
+	return append(objects, details, code, payload)
+}
+
+// Sends the command to the daemon and appends its outcome to the steps
+// of the result. It returns the response or nil if the command could not
+// be sent. The step is successful if the response is not nil and its
+// result is equal to keactrl.ResponseSuccess.
+func (push *ConfigPush) sendCommand(ctx context.Context, agents agentcomm.ConnectedAgents, name string, arguments map[string]interface{}, result *ConfigPushResult) *keactrl.Response {
+	step := ConfigPushStep{
+		Command: name,
+		Result:  keactrl.ResponseError,
+	}
+	response, err := func() (*keactrl.Response, error) {
+		daemons, err := keactrl.NewDaemons(push.Daemon.Name)
+		if err != nil {
+			return nil, err
+		}
+		var args *map[string]interface{}
+		if arguments != nil {
+			args = &arguments
+		}
+		command, err := keactrl.NewCommand(name, daemons, args)
+		if err != nil {
+			return nil, err
+		}
+		response := []keactrl.Response{}
+		respResult, err := agents.ForwardToKeaOverHTTP(ctx, push.Daemon.App, []*keactrl.Command{command}, &response)
+		switch {
+		case err != nil:
+			return nil, err
+		case respResult.Error != nil:
+			return nil, respResult.Error
+		case len(respResult.CmdsErrors) > 0 && respResult.CmdsErrors[0] != nil:
+			return nil, respResult.CmdsErrors[0]
+		case len(response) == 0:
+			return nil, errors.Errorf("empty response to the %s command", name)
+		}
+		return &response[0], nil
+	}()
+	if err != nil {
+		step.Text = err.Error()
+		result.Steps = append(result.Steps, step)
+		return nil
+	}
+	step.Result = response.Result
+	step.Text = response.Text
+	result.Steps = append(result.Steps, step)
+	return response
+}
+
+// Returns the last step of the result or an empty step if there are no
+// steps.
+func (result *ConfigPushResult) lastStep() ConfigPushStep {
+	if len(result.Steps) == 0 {
+		return ConfigPushStep{}
+	}
+	return result.Steps[len(result.Steps)-1]
+}
+
+// Returns the hash of the configuration returned by Kea in the arguments
+// of the response or an empty string if there is no hash. The hash is
+// returned by the newer Kea versions.
+func getResponseConfigHash(response *keactrl.Response) string {
+	if response == nil || response.Arguments == nil {
+		return ""
+	}
+	hash, _ := (*response.Arguments)["hash"].(string)
+	return hash
+}
+
+// Sends the config-set command with the configuration, followed by the
+// config-write command if the configuration should be persisted. It
+// returns true if all commands succeeded and the hash of the applied
+// configuration returned by Kea in response to config-set, if any.
+func (push *ConfigPush) setConfig(ctx context.Context, agents agentcomm.ConnectedAgents, config *dbmodel.KeaConfig, result *ConfigPushResult) (bool, string) {
+	response := push.sendCommand(ctx, agents, "config-set", *config, result)
+	if response == nil || response.Result != keactrl.ResponseSuccess {
+		return false, ""
+	}
+	hash := getResponseConfigHash(response)
+	if push.Write {
+		response = push.sendCommand(ctx, agents, "config-write", nil, result)
+		if response == nil || response.Result != keactrl.ResponseSuccess {
+			return false, ""
+		}
+	}
+	return true, hash
+}
+
+// Restores the previous configuration after a failed push and raises
+// the event with the reason of the rollback. It returns the status of
+// the push, i.e. rolled-back or failed if the previous configuration
+// could not be restored.
+func (push *ConfigPush) rollback(ctx context.Context, agents agentcomm.ConnectedAgents, eventCenter eventcenter.EventCenter, reason string, result *ConfigPushResult) string {
+	if push.Previous == nil {
+		eventCenter.AddErrorEvent("cannot roll back the configuration of {daemon}: previous configuration is unknown",
+			push.eventObjects(reason, dbmodel.EventCodeConfigPushFailed, dbmodel.EventPayload{"reason": reason})...)
+		return ConfigPushFailed
+	}
+	if ok, _ := push.setConfig(ctx, agents, push.Previous, result); !ok {
+		step := result.lastStep()
+		details := fmt.Sprintf("%s; %s failed: %s", reason, step.Command, step.Text)
+		eventCenter.AddErrorEvent("failed to roll back the configuration of {daemon}",
+			push.eventObjects(details, dbmodel.EventCodeConfigPushFailed, dbmodel.EventPayload{
+				"reason":  reason,
+				"command": step.Command,
+			})...)
+		return ConfigPushFailed
+	}
+	eventCenter.AddWarningEvent("rolled back the configuration of {daemon}",
+		push.eventObjects(reason, dbmodel.EventCodeConfigRolledBack, dbmodel.EventPayload{"reason": reason})...)
+	return ConfigPushRolledBack
+}
+
+// Pushes the configuration to the daemon. The configuration is first
+// checked with the config-test command. If it is accepted, it is applied
+// with the config-set command and optionally persisted with the
+// config-write command. Finally, the configuration returned by the
+// config-get command is verified by comparing its hash with the hash
+// returned by Kea in response to config-set or, if Kea doesn't return
+// the hashes, by checking that it includes the pushed parameters. If any
+// of the commands following config-test fails or the verification fails,
+// the previous configuration is restored. An event is raised for each
+// outcome. The pushes to the same daemon are serialized. It returns an
+// error only if the configuration cannot be pushed to the daemon at all.
+func (push *ConfigPush) Push(ctx context.Context, agents agentcomm.ConnectedAgents, eventCenter eventcenter.EventCenter) (*ConfigPushResult, error) {
+	if err := push.Validate(); err != nil {
+		return nil, err
+	}
+	lock := getConfigPushLock(push.Daemon.ID)
+	lock.mutex.Lock()
+	defer lock.mutex.Unlock()
+	lock.generation++
+	push.generation = lock.generation
+
+	result := &ConfigPushResult{}
+
+	response := push.sendCommand(ctx, agents, "config-test", *push.Config, result)
+	if response == nil || response.Result != keactrl.ResponseSuccess {
+		step := result.lastStep()
+		eventCenter.AddWarningEvent("configuration pushed to {daemon} has been rejected",
+			push.eventObjects(step.Text, dbmodel.EventCodeConfigRejected, dbmodel.EventPayload{"text": step.Text})...)
+		result.Status = ConfigPushRejected
+		return result, nil
+	}
+
+	ok, appliedHash := push.setConfig(ctx, agents, push.Config, result)
+	if !ok {
+		step := result.lastStep()
+		reason := fmt.Sprintf("%s failed: %s", step.Command, step.Text)
+		result.Status = push.rollback(ctx, agents, eventCenter, reason, result)
+		return result, nil
+	}
+
+	// Kea returns the hash of the applied configuration in response to
+	// config-set and config-get, so the hashes are compared when they
+	// are available. Otherwise, the configuration returned by config-get
+	// must include the pushed parameters. It may also include the
+	// defaults added by Kea.
+	response = push.sendCommand(ctx, agents, "config-get", nil, result)
+	var reason string
+	switch {
+	case response == nil || response.Result != keactrl.ResponseSuccess:
+		reason = fmt.Sprintf("config-get failed: %s", result.lastStep().Text)
+	case response.Arguments == nil:
+		reason = "config-get returned no configuration"
+	case len(appliedHash) > 0 && len(getResponseConfigHash(response)) > 0:
+		if getResponseConfigHash(response) != appliedHash {
+			reason = "hash of the configuration returned by config-get differs from the hash of the applied configuration"
+		}
+	case !containsConfig(normalizeConfig(*response.Arguments), normalizeConfig(*push.Config)):
+		reason = "configuration returned by config-get differs from the pushed configuration"
+	}
+	if len(reason) > 0 {
+		result.Status = push.rollback(ctx, agents, eventCenter, reason, result)
+		return result, nil
+	}
+
+	eventCenter.AddInfoEvent("applied new configuration to {daemon}",
+		push.eventObjects("", dbmodel.EventCodeConfigApplied, dbmodel.EventPayload{"written": push.Write})...)
+	result.Status = ConfigPushApplied
+	return result, nil
+}
+
+// Watches the daemon during the grace period following the successful
+// push. The daemon's status is checked with the status-get command every
+// interval. If the daemon becomes unreachable or reports an error, the
+// previous configuration is restored. Otherwise, the configuration is
+// confirmed when the grace period elapses. It returns the final status
+// of the push, i.e. applied if the configuration has been confirmed, or
+// the status of the rollback. The watching stops with the applied status
+// when the context is canceled or when another configuration has been
+// pushed to the daemon in the meantime, so the newer configuration is
+// not rolled back.
+func (push *ConfigPush) Watch(ctx context.Context, agents agentcomm.ConnectedAgents, eventCenter eventcenter.EventCenter, gracePeriod, interval time.Duration) string {
+	lock := getConfigPushLock(push.Daemon.ID)
+	lock.mutex.Lock()
+	if push.generation == 0 {
+		// The configuration has not been pushed by this push, so it
+		// is considered current when the watching starts.
+		lock.generation++
+		push.generation = lock.generation
+	}
+	lock.mutex.Unlock()
+
+	deadline := time.Now().Add(gracePeriod)
+	for time.Now().Before(deadline) {
+		wait := interval
+		if remaining := time.Until(deadline); remaining < wait {
+			wait = remaining
+		}
+		select {
+		case <-ctx.Done():
+			return ConfigPushApplied
+		case <-time.After(wait):
+		}
+
+		if status, done := push.check(ctx, agents, eventCenter, lock); done {
+			return status
+		}
+	}
+	lock.mutex.Lock()
+	defer lock.mutex.Unlock()
+	if lock.generation == push.generation {
+		eventCenter.AddInfoEvent("confirmed new configuration of {daemon}",
+			push.eventObjects("", dbmodel.EventCodeConfigConfirmed, dbmodel.EventPayload{"gracePeriod": int64(gracePeriod.Seconds())})...)
+	}
+	return ConfigPushApplied
+}
+
+// Checks the daemon's status while watching it and restores the previous
+// configuration if the daemon fails. It returns the final status of the
+// push and true if the watching should stop, i.e. the configuration has
+// been rolled back or superseded by another push.
+func (push *ConfigPush) check(ctx context.Context, agents agentcomm.ConnectedAgents, eventCenter eventcenter.EventCenter, lock *configPushLock) (string, bool) {
+	lock.mutex.Lock()
+	defer lock.mutex.Unlock()
+	if lock.generation != push.generation {
+		return ConfigPushApplied, true
+	}
+	result := &ConfigPushResult{}
+	response := push.sendCommand(ctx, agents, "status-get", nil, result)
+	if response == nil || response.Result != keactrl.ResponseSuccess {
+		reason := fmt.Sprintf("status-get failed within the grace period: %s", result.lastStep().Text)
+		return push.rollback(ctx, agents, eventCenter, reason, result), true
+	}
+	return "", false
+}
@@ -0,0 +1,373 @@
+package kea
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	require "github.com/stretchr/testify/require"
+
+	keactrl "isc.org/stork/appctrl/kea"
+	agentcommtest "isc.org/stork/server/agentcomm/test"
+	dbmodel "isc.org/stork/server/database/model"
+	storktest "isc.org/stork/server/test"
+)
+
+// Returns the function generating the responses to the consecutive
+// commands sent to the DHCPv4 daemon.
+func mockConfigPushResponses(responses ...string) func(int, []interface{}) {
+	return func(callNo int, cmdResponses []interface{}) {
+		if callNo >= len(responses) {
+			return
+		}
+		daemons, _ := keactrl.NewDaemons("dhcp4")
+		command, _ := keactrl.NewCommand("config-get", daemons, nil)
+		_ = keactrl.UnmarshalResponseList(command, []byte(responses[callNo]), cmdResponses[0])
+	}
+}
+
+// Returns the configuration push to the DHCPv4 daemon in the app with
+// the control access point.
+func getTestConfigPush(t *testing.T) *ConfigPush {
+	accessPoints := []*dbmodel.AccessPoint{}
+	accessPoints = dbmodel.AppendAccessPoint(accessPoints, dbmodel.AccessPointControl, "localhost", "", 8000)
+	app := &dbmodel.App{
+		ID:           1,
+		Type:         dbmodel.AppTypeKea,
+		AccessPoints: accessPoints,
+		Machine: &dbmodel.Machine{
+			ID:        1,
+			Address:   "localhost",
+			AgentPort: 8080,
+		},
+	}
+	daemon := &dbmodel.Daemon{
+		ID:   1,
+		Name: dbmodel.DaemonNameDHCPv4,
+		App:  app,
+	}
+	config, err := dbmodel.NewKeaConfigFromJSON(`{"Dhcp4": {"valid-lifetime": 2000, "renew-timer": 500}}`)
+	require.NoError(t, err)
+	previous, err := dbmodel.NewKeaConfigFromJSON(`{"Dhcp4": {"valid-lifetime": 1000}}`)
+	require.NoError(t, err)
+	return &ConfigPush{
+		Daemon:   daemon,
+		Config:   config,
+		Previous: previous,
+	}
+}
+
+// Returns the names of the commands recorded by the fake agents.
+func getRecordedCommandNames(fa *agentcommtest.FakeAgents) (names []string) {
+	for _, command := range fa.RecordedCommands {
+		names = append(names, command.Command)
+	}
+	return names
+}
+
+// Test that the configuration is applied, written and verified.
+func TestConfigPushApplied(t *testing.T) {
+	push := getTestConfigPush(t)
+	push.Write = true
+	fa := agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 0, "text": "Configuration seems sane."}]`,
+		`[{"result": 0, "text": "Configuration successful."}]`,
+		`[{"result": 0, "text": "Configuration written."}]`,
+		`[{"result": 0, "arguments": {"hash": "1234", "Dhcp4": {"renew-timer": 500, "valid-lifetime": 2000}}}]`,
+	), nil)
+	fec := &storktest.FakeEventCenter{}
+
+	result, err := push.Push(context.Background(), fa, fec)
+	require.NoError(t, err)
+	require.Equal(t, ConfigPushApplied, result.Status)
+	require.Equal(t, []string{"config-test", "config-set", "config-write", "config-get"}, getRecordedCommandNames(fa))
+	require.Len(t, result.Steps, 4)
+	require.Equal(t, "config-set", result.Steps[1].Command)
+	require.Equal(t, keactrl.ResponseSuccess, result.Steps[1].Result)
+	require.Equal(t, "Configuration successful.", result.Steps[1].Text)
+
+	// The configuration is sent as arguments.
+	require.NotNil(t, fa.RecordedCommands[1].Arguments)
+	require.Contains(t, *fa.RecordedCommands[1].Arguments, "Dhcp4")
+
+	require.Len(t, fec.Events, 1)
+	require.Equal(t, dbmodel.EventCodeConfigApplied, fec.Events[0].Code)
+	require.Equal(t, true, fec.Events[0].Payload["written"])
+	require.EqualValues(t, 1, fec.Events[0].Relations.DaemonID)
+}
+
+// Test that the configuration rejected by config-test is not applied.
+func TestConfigPushRejected(t *testing.T) {
+	push := getTestConfigPush(t)
+	fa := agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 1, "text": "unsupported parameter 'foo'"}]`,
+	), nil)
+	fec := &storktest.FakeEventCenter{}
+
+	result, err := push.Push(context.Background(), fa, fec)
+	require.NoError(t, err)
+	require.Equal(t, ConfigPushRejected, result.Status)
+	require.Equal(t, []string{"config-test"}, getRecordedCommandNames(fa))
+	require.Len(t, result.Steps, 1)
+	require.Equal(t, keactrl.ResponseError, result.Steps[0].Result)
+	require.Equal(t, "unsupported parameter 'foo'", result.Steps[0].Text)
+
+	require.Len(t, fec.Events, 1)
+	require.Equal(t, dbmodel.EventCodeConfigRejected, fec.Events[0].Code)
+	require.Equal(t, "unsupported parameter 'foo'", fec.Events[0].Details)
+}
+
+// Test that the previous configuration is restored when the configuration
+// returned by config-get differs from the pushed one.
+func TestConfigPushHashMismatch(t *testing.T) {
+	push := getTestConfigPush(t)
+	fa := agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 0, "text": "Configuration seems sane."}]`,
+		`[{"result": 0, "text": "Configuration successful."}]`,
+		`[{"result": 0, "arguments": {"Dhcp4": {"valid-lifetime": 3000}}}]`,
+		`[{"result": 0, "text": "Configuration successful."}]`,
+	), nil)
+	fec := &storktest.FakeEventCenter{}
+
+	result, err := push.Push(context.Background(), fa, fec)
+	require.NoError(t, err)
+	require.Equal(t, ConfigPushRolledBack, result.Status)
+	require.Equal(t, []string{"config-test", "config-set", "config-get", "config-set"}, getRecordedCommandNames(fa))
+
+	// The previous configuration is restored.
+	dhcp4 := (*fa.GetLastCommand().Arguments)["Dhcp4"].(map[string]interface{})
+	require.EqualValues(t, 1000, dhcp4["valid-lifetime"])
+
+	require.Len(t, fec.Events, 1)
+	require.Equal(t, dbmodel.EventCodeConfigRolledBack, fec.Events[0].Code)
+	require.Contains(t, fec.Events[0].Details, "differs")
+}
+
+// Test that the hashes returned by Kea in response to config-set and
+// config-get are compared when they are available, so the defaults added
+// by Kea to the configuration don't fail the verification.
+func TestConfigPushKeaHash(t *testing.T) {
+	push := getTestConfigPush(t)
+	fa := agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 0, "text": "Configuration seems sane."}]`,
+		`[{"result": 0, "text": "Configuration successful.", "arguments": {"hash": "abcd"}}]`,
+		`[{"result": 0, "arguments": {"hash": "abcd", "Dhcp4": {"valid-lifetime": 2000, "renew-timer": 500, "rebind-timer": 1750}}}]`,
+	), nil)
+	fec := &storktest.FakeEventCenter{}
+
+	result, err := push.Push(context.Background(), fa, fec)
+	require.NoError(t, err)
+	require.Equal(t, ConfigPushApplied, result.Status)
+
+	fa = agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 0, "text": "Configuration seems sane."}]`,
+		`[{"result": 0, "text": "Configuration successful.", "arguments": {"hash": "abcd"}}]`,
+		`[{"result": 0, "arguments": {"hash": "ef01", "Dhcp4": {"valid-lifetime": 2000, "renew-timer": 500}}}]`,
+		`[{"result": 0, "text": "Configuration successful."}]`,
+	), nil)
+	fec = &storktest.FakeEventCenter{}
+
+	result, err = push.Push(context.Background(), fa, fec)
+	require.NoError(t, err)
+	require.Equal(t, ConfigPushRolledBack, result.Status)
+	require.Len(t, fec.Events, 1)
+	require.Contains(t, fec.Events[0].Details, "hash")
+}
+
+// Test that the configuration returned by config-get is verified against
+// the pushed parameters when Kea doesn't return the hashes, so the
+// defaults added by Kea don't fail the verification.
+func TestConfigPushDefaultsWithoutHash(t *testing.T) {
+	push := getTestConfigPush(t)
+	fa := agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 0, "text": "Configuration seems sane."}]`,
+		`[{"result": 0, "text": "Configuration successful."}]`,
+		`[{"result": 0, "arguments": {"Dhcp4": {"valid-lifetime": 2000, "renew-timer": 500, "rebind-timer": 1750}}}]`,
+	), nil)
+	fec := &storktest.FakeEventCenter{}
+
+	result, err := push.Push(context.Background(), fa, fec)
+	require.NoError(t, err)
+	require.Equal(t, ConfigPushApplied, result.Status)
+	require.Len(t, fec.Events, 1)
+	require.Equal(t, dbmodel.EventCodeConfigApplied, fec.Events[0].Code)
+}
+
+// Test checking if the configuration includes the submitted parameters.
+func TestContainsConfig(t *testing.T) {
+	actual := map[string]interface{}{
+		"Dhcp4": map[string]interface{}{
+			"valid-lifetime": 2000.0,
+			"rebind-timer":   1750.0,
+			"subnet4": []interface{}{
+				map[string]interface{}{"id": 1.0, "subnet": "192.0.2.0/24", "valid-lifetime": 2000.0},
+			},
+		},
+	}
+	require.True(t, containsConfig(actual, map[string]interface{}{
+		"Dhcp4": map[string]interface{}{
+			"valid-lifetime": 2000.0,
+			"subnet4": []interface{}{
+				map[string]interface{}{"id": 1.0, "subnet": "192.0.2.0/24"},
+			},
+		},
+	}))
+	require.False(t, containsConfig(actual, map[string]interface{}{
+		"Dhcp4": map[string]interface{}{
+			"valid-lifetime": 3000.0,
+		},
+	}))
+	require.False(t, containsConfig(actual, map[string]interface{}{
+		"Dhcp4": map[string]interface{}{
+			"renew-timer": 500.0,
+		},
+	}))
+	require.False(t, containsConfig(actual, map[string]interface{}{
+		"Dhcp4": map[string]interface{}{
+			"subnet4": []interface{}{},
+		},
+	}))
+}
+
+// Test that the push fails when neither the configuration nor the
+// previous configuration can be set.
+func TestConfigPushFailed(t *testing.T) {
+	push := getTestConfigPush(t)
+	push.Write = true
+	fa := agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 0, "text": "Configuration seems sane."}]`,
+		`[{"result": 1, "text": "failed to open socket"}]`,
+		`[{"result": 1, "text": "failed to open socket"}]`,
+	), nil)
+	fec := &storktest.FakeEventCenter{}
+
+	result, err := push.Push(context.Background(), fa, fec)
+	require.NoError(t, err)
+	require.Equal(t, ConfigPushFailed, result.Status)
+	require.Equal(t, []string{"config-test", "config-set", "config-set"}, getRecordedCommandNames(fa))
+
+	require.Len(t, fec.Events, 1)
+	require.Equal(t, dbmodel.EventCodeConfigPushFailed, fec.Events[0].Code)
+	require.Equal(t, "config-set", fec.Events[0].Payload["command"])
+
+	// Without the previous configuration there is nothing to restore.
+	push.Previous = nil
+	fa = agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 0, "text": "Configuration seems sane."}]`,
+		`[{"result": 1, "text": "failed to open socket"}]`,
+	), nil)
+	fec = &storktest.FakeEventCenter{}
+	result, err = push.Push(context.Background(), fa, fec)
+	require.NoError(t, err)
+	require.Equal(t, ConfigPushFailed, result.Status)
+	require.Len(t, fa.RecordedCommands, 2)
+	require.Len(t, fec.Events, 1)
+	require.Equal(t, dbmodel.EventCodeConfigPushFailed, fec.Events[0].Code)
+}
+
+// Test that the configuration is not pushed to the daemons other than
+// DHCP daemons nor when it is meant for another daemon.
+func TestConfigPushValidate(t *testing.T) {
+	push := getTestConfigPush(t)
+	require.NoError(t, push.Validate())
+
+	config, err := dbmodel.NewKeaConfigFromJSON(`{"Dhcp6": {}}`)
+	require.NoError(t, err)
+	push.Config = config
+	require.Error(t, push.Validate())
+
+	push.Config = nil
+	require.Error(t, push.Validate())
+
+	push = getTestConfigPush(t)
+	push.Daemon.Name = "ca"
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	_, err = push.Push(context.Background(), fa, &storktest.FakeEventCenter{})
+	require.Error(t, err)
+	require.Empty(t, fa.RecordedCommands)
+}
+
+// Test that the configuration is confirmed when the daemon stays healthy
+// during the grace period.
+func TestConfigPushWatchConfirmed(t *testing.T) {
+	push := getTestConfigPush(t)
+	fa := agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 0, "arguments": {"pid": 1234}}]`,
+		`[{"result": 0, "arguments": {"pid": 1234}}]`,
+		`[{"result": 0, "arguments": {"pid": 1234}}]`,
+	), nil)
+	fec := &storktest.FakeEventCenter{}
+
+	status := push.Watch(context.Background(), fa, fec, 30*time.Millisecond, 10*time.Millisecond)
+	require.Equal(t, ConfigPushApplied, status)
+	require.NotEmpty(t, fa.RecordedCommands)
+	require.Equal(t, "status-get", fa.RecordedCommands[0].Command)
+	require.Len(t, fec.Events, 1)
+	require.Equal(t, dbmodel.EventCodeConfigConfirmed, fec.Events[0].Code)
+}
+
+// Test that the previous configuration is restored when the daemon
+// reports an error during the grace period.
+func TestConfigPushWatchRolledBack(t *testing.T) {
+	push := getTestConfigPush(t)
+	push.Write = true
+	fa := agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 0, "arguments": {"pid": 1234}}]`,
+		`[{"result": 1, "text": "server is shutting down"}]`,
+		`[{"result": 0, "text": "Configuration successful."}]`,
+		`[{"result": 0, "text": "Configuration written."}]`,
+	), nil)
+	fec := &storktest.FakeEventCenter{}
+
+	status := push.Watch(context.Background(), fa, fec, time.Second, 10*time.Millisecond)
+	require.Equal(t, ConfigPushRolledBack, status)
+	require.Equal(t, []string{"status-get", "status-get", "config-set", "config-write"}, getRecordedCommandNames(fa))
+	require.Len(t, fec.Events, 1)
+	require.Equal(t, dbmodel.EventCodeConfigRolledBack, fec.Events[0].Code)
+	require.Contains(t, fec.Events[0].Details, "server is shutting down")
+}
+
+// Test that watching stops when the context is canceled.
+func TestConfigPushWatchCanceled(t *testing.T) {
+	push := getTestConfigPush(t)
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	fec := &storktest.FakeEventCenter{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	status := push.Watch(ctx, fa, fec, time.Second, 10*time.Millisecond)
+	require.Equal(t, ConfigPushApplied, status)
+	require.Empty(t, fa.RecordedCommands)
+	require.Empty(t, fec.Events)
+}
+
+// Test that watching stops without rolling back when another configuration
+// has been pushed to the daemon in the meantime.
+func TestConfigPushWatchSuperseded(t *testing.T) {
+	push := getTestConfigPush(t)
+	applied := []string{
+		`[{"result": 0, "text": "Configuration seems sane."}]`,
+		`[{"result": 0, "text": "Configuration successful.", "arguments": {"hash": "abcd"}}]`,
+		`[{"result": 0, "arguments": {"hash": "abcd", "Dhcp4": {"valid-lifetime": 2000, "renew-timer": 500}}}]`,
+	}
+	fa := agentcommtest.NewFakeAgents(mockConfigPushResponses(applied...), nil)
+	fec := &storktest.FakeEventCenter{}
+	result, err := push.Push(context.Background(), fa, fec)
+	require.NoError(t, err)
+	require.Equal(t, ConfigPushApplied, result.Status)
+
+	newer := getTestConfigPush(t)
+	result, err = newer.Push(context.Background(), agentcommtest.NewFakeAgents(mockConfigPushResponses(applied...), nil), fec)
+	require.NoError(t, err)
+	require.Equal(t, ConfigPushApplied, result.Status)
+
+	// The daemon is not checked and the configuration is not confirmed.
+	fa = agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 1, "text": "server is shutting down"}]`,
+	), nil)
+	fec = &storktest.FakeEventCenter{}
+	status := push.Watch(context.Background(), fa, fec, 30*time.Millisecond, 10*time.Millisecond)
+	require.Equal(t, ConfigPushApplied, status)
+	require.Empty(t, fa.RecordedCommands)
+	require.Empty(t, fec.Events)
+}
@@ -0,0 +1,88 @@
+package kea
+
+import (
+	"context"
+	"strings"
+
+	keactrl "isc.org/stork/appctrl/kea"
+	"isc.org/stork/server/agentcomm"
+	dbmodel "isc.org/stork/server/database/model"
+)
+
+// Kea daemon to which the commands modifying its configuration, e.g.
+// host reservations or subnets, are sent and the ID of the modified
+// subnet in the daemon's configuration. The subnet ID is 0 for the
+// global host reservations.
+type DaemonCmdsTarget struct {
+	App           *dbmodel.App
+	DaemonName    string
+	LocalSubnetID int64
+}
+
+// Outcome of the command sent to the single daemon. The communication
+// errors are reported with the error result and the error message as
+// the text.
+type DaemonCmdsResult struct {
+	Target  DaemonCmdsTarget
+	Command string
+	Result  int
+	Text    string
+
+	// Arguments returned by the daemon.
+	arguments *map[string]interface{}
+}
+
+// Checks if the command succeeded. The deleted object which doesn't
+// exist is treated as deleted. Similarly, wiping no leases succeeds.
+func (result *DaemonCmdsResult) Succeeded() bool {
+	return result.Result == keactrl.ResponseSuccess ||
+		((strings.HasSuffix(result.Command, "-del") || strings.HasSuffix(result.Command, "-wipe")) &&
+			result.Result == keactrl.ResponseEmpty)
+}
+
+// Sends the command to the target daemon and returns its outcome.
+func sendDaemonCommand(ctx context.Context, agents agentcomm.ConnectedAgents, target DaemonCmdsTarget, name string, arguments map[string]interface{}) DaemonCmdsResult {
+	result := DaemonCmdsResult{
+		Target:  target,
+		Command: name,
+		Result:  keactrl.ResponseError,
+	}
+	daemons, err := keactrl.NewDaemons(target.DaemonName)
+	if err != nil {
+		result.Text = err.Error()
+		return result
+	}
+	command, err := keactrl.NewCommand(name, daemons, &arguments)
+	if err != nil {
+		result.Text = err.Error()
+		return result
+	}
+	response := []keactrl.Response{}
+	respResult, err := agents.ForwardToKeaOverHTTP(ctx, target.App, []*keactrl.Command{command}, &response)
+	switch {
+	case err != nil:
+		result.Text = err.Error()
+	case respResult.Error != nil:
+		result.Text = respResult.Error.Error()
+	case len(respResult.CmdsErrors) > 0 && respResult.CmdsErrors[0] != nil:
+		result.Text = respResult.CmdsErrors[0].Error()
+	case len(response) == 0:
+		result.Text = "empty response"
+	default:
+		result.Result = response[0].Result
+		result.Text = response[0].Text
+		result.arguments = response[0].Arguments
+	}
+	return result
+}
+
+// Returns the apps for which the commands succeeded by their IDs.
+func getSucceededApps(results []DaemonCmdsResult) map[int64]*dbmodel.App {
+	apps := make(map[int64]*dbmodel.App)
+	for i := range results {
+		if results[i].Succeeded() {
+			apps[results[i].Target.App.ID] = results[i].Target.App
+		}
+	}
+	return apps
+}
@@ -0,0 +1,22 @@
+package kea
+
+import (
+	"testing"
+
+	require "github.com/stretchr/testify/require"
+
+	keactrl "isc.org/stork/appctrl/kea"
+)
+
+// Test that the missing object is treated as deleted and that wiping
+// no leases succeeds.
+func TestDaemonCmdsResultSucceeded(t *testing.T) {
+	result := DaemonCmdsResult{Command: "reservation-del", Result: keactrl.ResponseEmpty}
+	require.True(t, result.Succeeded())
+	result.Command = "reservation-add"
+	require.False(t, result.Succeeded())
+	result.Result = keactrl.ResponseSuccess
+	require.True(t, result.Succeeded())
+	result = DaemonCmdsResult{Command: "lease4-wipe", Result: keactrl.ResponseEmpty}
+	require.True(t, result.Succeeded())
+}
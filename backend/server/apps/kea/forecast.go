@@ -0,0 +1,256 @@
+package kea
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"isc.org/stork/server/agentcomm"
+	dbops "isc.org/stork/server/database"
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/eventcenter"
+	storkutil "isc.org/stork/util"
+)
+
+// The exhaustion projected farther in the future than this is not
+// foreseen.
+const maxExhaustionForecastHorizon = 10 * 365 * 24 * time.Hour
+
+// Returns the assigned and total counts of the sample used in the forecast.
+type utilizationCounts func(sample *dbmodel.UtilizationSample) (assigned, total int64)
+
+// Returns the assigned and total addresses of the sample.
+func addressCounts(sample *dbmodel.UtilizationSample) (int64, int64) {
+	return sample.AssignedAddresses, sample.TotalAddresses
+}
+
+// Returns the assigned and total delegated prefixes of the sample.
+func pdCounts(sample *dbmodel.UtilizationSample) (int64, int64) {
+	return sample.AssignedPds, sample.TotalPds
+}
+
+// Selects the samples of a single subnet or shared network used in the
+// forecast. The samples with the given resolution are supplemented with
+// the raw samples collected after the last period already aggregated.
+// The samples must be ordered by time.
+func selectForecastSamples(samples []dbmodel.UtilizationSample, resolution string) []dbmodel.UtilizationSample {
+	var period time.Duration
+	switch resolution {
+	case dbmodel.UtilizationResolutionHour:
+		period = time.Hour
+	case dbmodel.UtilizationResolutionDay:
+		period = 24 * time.Hour
+	}
+	var selected, raw []dbmodel.UtilizationSample
+	for _, sample := range samples {
+		if sample.Resolution == resolution {
+			selected = append(selected, sample)
+		} else if sample.Resolution == dbmodel.UtilizationResolutionRaw {
+			raw = append(raw, sample)
+		}
+	}
+	var aggregatedUntil time.Time
+	if len(selected) > 0 {
+		aggregatedUntil = selected[len(selected)-1].SampledAt.Add(period)
+	}
+	for _, sample := range raw {
+		if !sample.SampledAt.Before(aggregatedUntil) {
+			selected = append(selected, sample)
+		}
+	}
+	return selected
+}
+
+// Fits the linear trend to the assigned counts of the samples ordered by
+// time and returns the projected time when the assigned count reaches the
+// total count of the last sample. It returns the zero time when the
+// exhaustion is not foreseen, i.e. there are not enough samples, the
+// assigned count does not grow or the exhaustion is projected too far in
+// the future. If the assigned count already reached the total count, the
+// time of the last sample is returned.
+func forecastExhaustion(samples []dbmodel.UtilizationSample, counts utilizationCounts, now time.Time) time.Time {
+	if len(samples) < 2 {
+		return time.Time{}
+	}
+	last := &samples[len(samples)-1]
+	lastAssigned, total := counts(last)
+	if total <= 0 {
+		return time.Time{}
+	}
+	if lastAssigned >= total {
+		return last.SampledAt
+	}
+
+	// Least squares fit of the assigned count over the hours elapsed since
+	// the first sample.
+	start := samples[0].SampledAt
+	n := float64(len(samples))
+	var sumX, sumY, sumXY, sumXX float64
+	for i := range samples {
+		assigned, _ := counts(&samples[i])
+		x := samples[i].SampledAt.Sub(start).Hours()
+		y := float64(assigned)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return time.Time{}
+	}
+	slope := (n*sumXY - sumX*sumY) / denominator
+	if slope <= 0 {
+		return time.Time{}
+	}
+	intercept := (sumY - slope*sumX) / n
+
+	// Compare the hours before converting them to the duration which could
+	// overflow.
+	hours := (float64(total) - intercept) / slope
+	if hours > now.Add(maxExhaustionForecastHorizon).Sub(start).Hours() {
+		return time.Time{}
+	}
+	exhaustionAt := start.Add(time.Duration(hours * float64(time.Hour)))
+	// The fitted trend may cross the total count in the past while the
+	// counts are still below it.
+	if exhaustionAt.Before(last.SampledAt) {
+		exhaustionAt = last.SampledAt
+	}
+	return exhaustionAt.Truncate(time.Second)
+}
+
+// Instance of the puller which periodically forecasts the exhaustion of
+// the addresses and delegated prefixes in the subnets and shared networks.
+type ExhaustionForecastPuller struct {
+	*agentcomm.PeriodicPuller
+	EventCenter eventcenter.EventCenter
+}
+
+// Create an instance of the puller which periodically forecasts the
+// addresses and delegated prefixes exhaustion.
+func NewExhaustionForecastPuller(db *dbops.PgDB, agents agentcomm.ConnectedAgents, eventCenter eventcenter.EventCenter) (*ExhaustionForecastPuller, error) {
+	puller := &ExhaustionForecastPuller{
+		EventCenter: eventCenter,
+	}
+	periodicPuller, err := agentcomm.NewPeriodicPuller(db, agents, "Exhaustion Forecast",
+		"exhaustion_forecast_puller_interval", puller.forecast)
+	if err != nil {
+		return nil, err
+	}
+	puller.PeriodicPuller = periodicPuller
+	return puller, nil
+}
+
+// Stops the timer triggering the forecast.
+func (puller *ExhaustionForecastPuller) Shutdown() {
+	puller.PeriodicPuller.Shutdown()
+}
+
+// Raises the warning event when the projected exhaustion falls before the
+// deadline for the first time. The object is described by the text.
+func (puller *ExhaustionForecastPuller) reportExhaustion(previous, current, deadline time.Time, resources, object string, objects ...interface{}) {
+	if current.IsZero() || current.After(deadline) {
+		return
+	}
+	if !previous.IsZero() && !previous.After(deadline) {
+		return
+	}
+	text := fmt.Sprintf("%s in %s are projected to be exhausted by %s", resources, object,
+		current.Format("2006-01-02 15:04 MST"))
+	objects = append([]interface{}{
+		dbmodel.EventCodeExhaustionForecast,
+		dbmodel.EventPayload{"resources": resources, "exhaustionAt": current},
+	}, objects...)
+	puller.EventCenter.AddWarningEvent(text, objects...)
+}
+
+// Forecasts the exhaustion of the addresses and delegated prefixes in all
+// subnets and shared networks using the utilization samples from the
+// configured window. The function returns the number of subnets and shared
+// networks for which the forecast was updated and the last encountered error.
+func (puller *ExhaustionForecastPuller) forecast() (int, error) {
+	window, err := dbmodel.GetSettingInt(puller.DB, "exhaustion_forecast_window")
+	if err != nil {
+		return 0, err
+	}
+	threshold, err := dbmodel.GetSettingInt(puller.DB, "exhaustion_forecast_threshold")
+	if err != nil {
+		return 0, err
+	}
+	now := storkutil.UTCNow()
+	deadline := now.Add(time.Duration(threshold) * 24 * time.Hour)
+	from := now.Add(-time.Duration(window) * 24 * time.Hour)
+
+	resolution := dbmodel.GetUtilizationResolution(from, now)
+	resolutions := []string{resolution}
+	if resolution != dbmodel.UtilizationResolutionRaw {
+		resolutions = append(resolutions, dbmodel.UtilizationResolutionRaw)
+	}
+	samples, err := dbmodel.GetSubnetsUtilizationSamplesSince(puller.DB, resolutions, from)
+	if err != nil {
+		return 0, err
+	}
+	subnetSamples := make(map[int64][]dbmodel.UtilizationSample)
+	networkSamples := make(map[int64][]dbmodel.UtilizationSample)
+	for _, sample := range samples {
+		if sample.SubnetID != 0 {
+			subnetSamples[sample.SubnetID] = append(subnetSamples[sample.SubnetID], sample)
+		} else {
+			networkSamples[sample.SharedNetworkID] = append(networkSamples[sample.SharedNetworkID], sample)
+		}
+	}
+
+	subnets, err := dbmodel.GetAllSubnets(puller.DB, 0)
+	if err != nil {
+		return 0, err
+	}
+	okCnt := 0
+	var lastErr error
+	for i := range subnets {
+		sn := &subnets[i]
+		selected := selectForecastSamples(subnetSamples[sn.ID], resolution)
+		addrExhaustionAt := forecastExhaustion(selected, addressCounts, now)
+		pdExhaustionAt := forecastExhaustion(selected, pdCounts, now)
+
+		puller.reportExhaustion(sn.AddrExhaustionAt, addrExhaustionAt, deadline, "addresses", "{subnet}", sn)
+		puller.reportExhaustion(sn.PdExhaustionAt, pdExhaustionAt, deadline, "delegated prefixes", "{subnet}", sn)
+
+		err = sn.UpdateExhaustionForecast(puller.DB, addrExhaustionAt, pdExhaustionAt)
+		if err != nil {
+			lastErr = err
+			log.Errorf("cannot update exhaustion forecast in subnet %d: %s", sn.ID, err)
+			continue
+		}
+		okCnt++
+	}
+
+	networks, err := dbmodel.GetAllSharedNetworks(puller.DB, 0)
+	if err != nil {
+		return okCnt, err
+	}
+	for i := range networks {
+		net := &networks[i]
+		selected := selectForecastSamples(networkSamples[net.ID], resolution)
+		addrExhaustionAt := forecastExhaustion(selected, addressCounts, now)
+		pdExhaustionAt := forecastExhaustion(selected, pdCounts, now)
+
+		object := fmt.Sprintf("shared network %s", net.Name)
+		puller.reportExhaustion(net.AddrExhaustionAt, addrExhaustionAt, deadline, "addresses", object,
+			dbmodel.EventPayload{"sharedNetworkId": net.ID, "sharedNetworkName": net.Name})
+		puller.reportExhaustion(net.PdExhaustionAt, pdExhaustionAt, deadline, "delegated prefixes", object,
+			dbmodel.EventPayload{"sharedNetworkId": net.ID, "sharedNetworkName": net.Name})
+
+		err = dbmodel.UpdateExhaustionForecastInSharedNetwork(puller.DB, net.ID, addrExhaustionAt, pdExhaustionAt)
+		if err != nil {
+			lastErr = err
+			log.Errorf("cannot update exhaustion forecast in shared network %d: %s", net.ID, err)
+			continue
+		}
+		okCnt++
+	}
+
+	log.Printf("completed forecasting exhaustion of %d subnets and %d shared networks", len(subnets), len(networks))
+	return okCnt, lastErr
+}
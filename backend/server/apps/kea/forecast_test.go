@@ -0,0 +1,175 @@
+package kea
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	agentcommtest "isc.org/stork/server/agentcomm/test"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	storktest "isc.org/stork/server/test"
+	storkutil "isc.org/stork/util"
+)
+
+// Creates the raw samples of the assigned addresses and prefixes sampled
+// every hour until the given time.
+func makeForecastSamples(until time.Time, total int64, assigned ...int64) []dbmodel.UtilizationSample {
+	var samples []dbmodel.UtilizationSample
+	for i, a := range assigned {
+		samples = append(samples, dbmodel.UtilizationSample{
+			Resolution:        dbmodel.UtilizationResolutionRaw,
+			SampledAt:         until.Add(-time.Duration(len(assigned)-1-i) * time.Hour),
+			AssignedAddresses: a,
+			TotalAddresses:    total,
+			AssignedPds:       a,
+			TotalPds:          total,
+		})
+	}
+	return samples
+}
+
+// Test that the exhaustion is projected from the linear trend.
+func TestForecastExhaustion(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	// 10 addresses per hour, 50 addresses left.
+	samples := makeForecastSamples(now, 100, 10, 20, 30, 40, 50)
+	require.Equal(t, now.Add(5*time.Hour), forecastExhaustion(samples, addressCounts, now))
+	require.Equal(t, now.Add(5*time.Hour), forecastExhaustion(samples, pdCounts, now))
+
+	// Not enough samples.
+	require.True(t, forecastExhaustion(samples[:1], addressCounts, now).IsZero())
+	require.True(t, forecastExhaustion(nil, addressCounts, now).IsZero())
+
+	// The utilization does not grow.
+	samples = makeForecastSamples(now, 100, 50, 40, 50, 40)
+	require.True(t, forecastExhaustion(samples, addressCounts, now).IsZero())
+
+	// No addresses.
+	samples = makeForecastSamples(now, 0, 0, 0)
+	require.True(t, forecastExhaustion(samples, addressCounts, now).IsZero())
+
+	// Already exhausted.
+	samples = makeForecastSamples(now, 100, 90, 100)
+	require.Equal(t, now, forecastExhaustion(samples, addressCounts, now))
+
+	// Too far in the future.
+	samples = makeForecastSamples(now, 1000000000, 1, 2)
+	require.True(t, forecastExhaustion(samples, addressCounts, now).IsZero())
+}
+
+// Test that the coarse samples are supplemented with the raw samples
+// which have not been aggregated yet.
+func TestSelectForecastSamples(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 30, 0, 0, time.UTC)
+	samples := []dbmodel.UtilizationSample{
+		{Resolution: dbmodel.UtilizationResolutionHour, SampledAt: now.Add(-2 * time.Hour).Truncate(time.Hour)},
+		{Resolution: dbmodel.UtilizationResolutionRaw, SampledAt: now.Add(-2 * time.Hour)},
+		{Resolution: dbmodel.UtilizationResolutionHour, SampledAt: now.Add(-time.Hour).Truncate(time.Hour)},
+		{Resolution: dbmodel.UtilizationResolutionRaw, SampledAt: now.Add(-time.Hour)},
+		{Resolution: dbmodel.UtilizationResolutionRaw, SampledAt: now},
+	}
+	selected := selectForecastSamples(samples, dbmodel.UtilizationResolutionHour)
+	require.Len(t, selected, 3)
+	require.Equal(t, dbmodel.UtilizationResolutionHour, selected[0].Resolution)
+	require.Equal(t, dbmodel.UtilizationResolutionHour, selected[1].Resolution)
+	require.Equal(t, now, selected[2].SampledAt)
+
+	// Only the raw samples are selected when nothing is aggregated.
+	selected = selectForecastSamples(samples[3:], dbmodel.UtilizationResolutionHour)
+	require.Len(t, selected, 2)
+
+	selected = selectForecastSamples(samples, dbmodel.UtilizationResolutionRaw)
+	require.Len(t, selected, 3)
+}
+
+// Test that the puller stores the forecast in the subnets and shared
+// networks and raises the event when the exhaustion is near.
+func TestExhaustionForecastPuller(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	err := dbmodel.InitializeSettings(db)
+	require.NoError(t, err)
+
+	network := &dbmodel.SharedNetwork{
+		Name:   "frog",
+		Family: 4,
+	}
+	err = dbmodel.AddSharedNetwork(db, network)
+	require.NoError(t, err)
+	subnet := &dbmodel.Subnet{
+		Prefix:          "192.0.2.0/24",
+		SharedNetworkID: network.ID,
+	}
+	err = dbmodel.AddSubnet(db, subnet)
+	require.NoError(t, err)
+	stableSubnet := &dbmodel.Subnet{
+		Prefix: "192.0.3.0/24",
+	}
+	err = dbmodel.AddSubnet(db, stableSubnet)
+	require.NoError(t, err)
+
+	// The subnet and its shared network are filling up quickly while the
+	// other subnet is stable.
+	now := storkutil.UTCNow()
+	var samples []*dbmodel.UtilizationSample
+	for i, a := range []int64{10, 20, 30, 40} {
+		sampledAt := now.Add(-time.Duration(3-i) * time.Minute)
+		samples = append(samples, &dbmodel.UtilizationSample{
+			Resolution:        dbmodel.UtilizationResolutionRaw,
+			SampledAt:         sampledAt,
+			SubnetID:          subnet.ID,
+			AssignedAddresses: a,
+			TotalAddresses:    100,
+		}, &dbmodel.UtilizationSample{
+			Resolution:        dbmodel.UtilizationResolutionRaw,
+			SampledAt:         sampledAt,
+			SharedNetworkID:   network.ID,
+			AssignedAddresses: a,
+			TotalAddresses:    100,
+		}, &dbmodel.UtilizationSample{
+			Resolution:        dbmodel.UtilizationResolutionRaw,
+			SampledAt:         sampledAt,
+			SubnetID:          stableSubnet.ID,
+			AssignedAddresses: 10,
+			TotalAddresses:    100,
+		})
+	}
+	err = dbmodel.AddUtilizationSamples(db, samples)
+	require.NoError(t, err)
+
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	fec := &storktest.FakeEventCenter{}
+	puller, err := NewExhaustionForecastPuller(db, fa, fec)
+	require.NoError(t, err)
+	defer puller.Shutdown()
+
+	count, err := puller.forecast()
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+
+	returnedSubnet, err := dbmodel.GetSubnet(db, subnet.ID)
+	require.NoError(t, err)
+	require.WithinDuration(t, now.Add(6*time.Minute), returnedSubnet.AddrExhaustionAt, 2*time.Second)
+	require.True(t, returnedSubnet.PdExhaustionAt.IsZero())
+
+	returnedSubnet, err = dbmodel.GetSubnet(db, stableSubnet.ID)
+	require.NoError(t, err)
+	require.True(t, returnedSubnet.AddrExhaustionAt.IsZero())
+
+	returnedNetwork, err := dbmodel.GetSharedNetwork(db, network.ID)
+	require.NoError(t, err)
+	require.WithinDuration(t, now.Add(6*time.Minute), returnedNetwork.AddrExhaustionAt, 2*time.Second)
+
+	require.Len(t, fec.Events, 2)
+	require.Contains(t, fec.Events[0].Text, "addresses in <subnet")
+	require.EqualValues(t, subnet.ID, fec.Events[0].Relations.SubnetID)
+	require.Contains(t, fec.Events[1].Text, "addresses in shared network frog")
+
+	// The events are not repeated while the exhaustion remains near.
+	_, err = puller.forecast()
+	require.NoError(t, err)
+	require.Len(t, fec.Events, 2)
+}
@@ -0,0 +1,322 @@
+package kea
+
+import (
+	"fmt"
+	"strings"
+
+	keaconfig "isc.org/stork/appcfg/kea"
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/eventcenter"
+	storkutil "isc.org/stork/util"
+)
+
+// Element of the HA configuration difference reported for the lifetime
+// parameters.
+const haConfigElementLifetime = "lifetime"
+
+// Lifetime parameters which must match between the HA peers in all scopes.
+var haLifetimeParams = map[string]bool{
+	"valid-lifetime":         true,
+	"min-valid-lifetime":     true,
+	"max-valid-lifetime":     true,
+	"preferred-lifetime":     true,
+	"min-preferred-lifetime": true,
+	"max-preferred-lifetime": true,
+	"renew-timer":            true,
+	"rebind-timer":           true,
+}
+
+// Global parameters compared between the HA peers besides the lifetimes.
+var haGlobalParams = map[string]bool{
+	"subnet4":         true,
+	"subnet6":         true,
+	"shared-networks": true,
+	"reservations":    true,
+	"client-classes":  true,
+}
+
+// Shared network parameters compared between the HA peers besides the
+// lifetimes.
+var haSharedNetworkParams = map[string]bool{
+	"name":                   true,
+	"subnet4":                true,
+	"subnet6":                true,
+	"client-class":           true,
+	"require-client-classes": true,
+}
+
+// Subnet parameters compared between the HA peers besides the lifetimes.
+var haSubnetParams = map[string]bool{
+	"id":                     true,
+	"subnet":                 true,
+	"pools":                  true,
+	"pd-pools":               true,
+	"reservations":           true,
+	"client-class":           true,
+	"require-client-classes": true,
+}
+
+// Returns a copy of the map holding only the specified parameters and
+// the lifetimes.
+func filterHAParams(m map[string]interface{}, params map[string]bool) map[string]interface{} {
+	filtered := make(map[string]interface{})
+	for key, value := range m {
+		if params[key] || haLifetimeParams[key] {
+			filtered[key] = value
+		}
+	}
+	return filtered
+}
+
+// Filters the parameters of the maps in the list. The elements which are
+// not maps are left untouched.
+func filterHAParamsList(list interface{}, params map[string]bool) interface{} {
+	items, ok := list.([]interface{})
+	if !ok {
+		return list
+	}
+	filtered := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		if m, ok := item.(map[string]interface{}); ok {
+			item = filterHAParams(m, params)
+		}
+		filtered = append(filtered, item)
+	}
+	return filtered
+}
+
+// Filters the parameters of the subnets in the list. The whitespace in
+// the pool ranges is removed, so the same ranges written differently are
+// not reported as the differences.
+func filterHASubnets(list interface{}) interface{} {
+	subnets := filterHAParamsList(list, haSubnetParams)
+	items, ok := subnets.([]interface{})
+	if !ok {
+		return subnets
+	}
+	for _, item := range items {
+		subnet, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		pools, ok := subnet["pools"].([]interface{})
+		if !ok {
+			continue
+		}
+		normalized := make([]interface{}, 0, len(pools))
+		for _, p := range pools {
+			if pool, ok := p.(map[string]interface{}); ok {
+				if poolRange, ok := pool["pool"].(string); ok {
+					copied := make(map[string]interface{})
+					for key, value := range pool {
+						copied[key] = value
+					}
+					copied["pool"] = strings.ReplaceAll(poolRange, " ", "")
+					p = copied
+				}
+			}
+			normalized = append(normalized, p)
+		}
+		subnet["pools"] = normalized
+	}
+	return items
+}
+
+// Returns the part of the Kea DHCP server configuration which must match
+// between the HA peers, i.e. the subnets, pools, reservations, client
+// classes and lifetimes. It returns nil if the configuration has no root
+// node.
+func getHAComparedConfig(config *dbmodel.KeaConfig) *keaconfig.Map {
+	if config == nil {
+		return nil
+	}
+	rootName, ok := config.GetRootName()
+	if !ok {
+		return nil
+	}
+	root, ok := (*config)[rootName].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	filtered := filterHAParams(root, haGlobalParams)
+	for _, subnetsName := range []string{"subnet4", "subnet6"} {
+		if subnets, ok := filtered[subnetsName]; ok {
+			filtered[subnetsName] = filterHASubnets(subnets)
+		}
+	}
+	if networks, ok := filtered["shared-networks"]; ok {
+		networks = filterHAParamsList(networks, haSharedNetworkParams)
+		if items, ok := networks.([]interface{}); ok {
+			for _, item := range items {
+				network, ok := item.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				for _, subnetsName := range []string{"subnet4", "subnet6"} {
+					if subnets, ok := network[subnetsName]; ok {
+						network[subnetsName] = filterHASubnets(subnets)
+					}
+				}
+			}
+		}
+		filtered["shared-networks"] = networks
+	}
+	return &keaconfig.Map{rootName: filtered}
+}
+
+// Compares the configurations of the primary server and its HA peer and
+// returns their differences in the subnets, pools, reservations, client
+// classes and lifetimes. Other parameters, e.g. the interfaces or the
+// HA configurations, differ between the peers by design, so they are not
+// compared.
+func compareHAPeerConfigs(primary, peer *dbmodel.Daemon) []dbmodel.HAConfigDiff {
+	diffs := []dbmodel.HAConfigDiff{}
+	primaryConfig := getHAComparedConfig(primary.KeaDaemon.Config)
+	peerConfig := getHAComparedConfig(peer.KeaDaemon.Config)
+	if primaryConfig == nil || peerConfig == nil {
+		return diffs
+	}
+	for _, change := range keaconfig.DiffConfigs(primaryConfig, peerConfig) {
+		diff := dbmodel.HAConfigDiff{
+			DaemonID: peer.ID,
+			Element:  change.Element,
+			Path:     change.Path,
+			Primary:  change.Old,
+			Peer:     change.New,
+		}
+		switch change.Operation {
+		case keaconfig.ConfigChangeRemoved:
+			diff.Difference = dbmodel.HAConfigDiffPrimaryOnly
+		case keaconfig.ConfigChangeAdded:
+			diff.Difference = dbmodel.HAConfigDiffPeerOnly
+		default:
+			// The modified subnets and shared networks are followed by
+			// their differing contents, so they are not reported.
+			if change.Element == keaconfig.ConfigElementSubnet || change.Element == keaconfig.ConfigElementSharedNetwork {
+				continue
+			}
+			diff.Difference = dbmodel.HAConfigDiffDifferent
+		}
+		if change.Element == keaconfig.ConfigElementParameter &&
+			haLifetimeParams[change.Path[strings.LastIndex(change.Path, "/")+1:]] {
+			diff.Element = haConfigElementLifetime
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs
+}
+
+// Returns the key identifying the difference between the HA peers.
+func getHAConfigDiffKey(diff *dbmodel.HAConfigDiff) string {
+	return fmt.Sprintf("%d|%s|%s|%s", diff.DaemonID, diff.Element, diff.Difference, diff.Path)
+}
+
+// Returns the name of the HA peer used in the event texts.
+func getHAPeerName(daemon *dbmodel.Daemon) string {
+	if daemon.App != nil && len(daemon.App.Name) > 0 {
+		return daemon.App.Name
+	}
+	return fmt.Sprintf("[%d]", daemon.ID)
+}
+
+// Compares the configuration of the primary server of the HA service with
+// the configurations of its peers and stores the differences in the
+// service. The daemon replaces its stale instance fetched with the
+// service. It raises a warning event when new differences appear and an
+// informational event when the configurations match again. It returns
+// false if the configurations could not be compared, e.g. because the
+// primary server is not known yet.
+func checkHAServiceConfigs(service *dbmodel.Service, daemon *dbmodel.Daemon, eventCenter eventcenter.EventCenter) bool {
+	ha := service.HAService
+	if ha == nil || ha.PrimaryID == 0 {
+		return false
+	}
+	daemons := make(map[int64]*dbmodel.Daemon)
+	for _, d := range service.Daemons {
+		daemons[d.ID] = d
+	}
+	daemons[daemon.ID] = daemon
+
+	primary, ok := daemons[ha.PrimaryID]
+	if !ok || primary.KeaDaemon == nil || primary.KeaDaemon.Config == nil {
+		return false
+	}
+	peerIDs := append([]int64{}, ha.BackupID...)
+	if ha.SecondaryID != 0 {
+		peerIDs = append([]int64{ha.SecondaryID}, peerIDs...)
+	}
+
+	oldDiffs := make(map[int64]map[string]bool)
+	for i := range ha.ConfigDiffs {
+		if _, ok := oldDiffs[ha.ConfigDiffs[i].DaemonID]; !ok {
+			oldDiffs[ha.ConfigDiffs[i].DaemonID] = make(map[string]bool)
+		}
+		oldDiffs[ha.ConfigDiffs[i].DaemonID][getHAConfigDiffKey(&ha.ConfigDiffs[i])] = true
+	}
+
+	diffs := []dbmodel.HAConfigDiff{}
+	for _, peerID := range peerIDs {
+		peer, ok := daemons[peerID]
+		if !ok || peer.KeaDaemon == nil || peer.KeaDaemon.Config == nil {
+			// Keep the differences found before until the peer's
+			// configuration is known again.
+			for i := range ha.ConfigDiffs {
+				if ha.ConfigDiffs[i].DaemonID == peerID {
+					diffs = append(diffs, ha.ConfigDiffs[i])
+				}
+			}
+			continue
+		}
+		peerDiffs := compareHAPeerConfigs(primary, peer)
+		diffs = append(diffs, peerDiffs...)
+
+		var appeared []string
+		for i := range peerDiffs {
+			if !oldDiffs[peerID][getHAConfigDiffKey(&peerDiffs[i])] {
+				appeared = append(appeared, fmt.Sprintf("%s %s: %s", peerDiffs[i].Element, peerDiffs[i].Difference, peerDiffs[i].Path))
+			}
+		}
+		if primary.App == nil {
+			continue
+		}
+		payload := dbmodel.EventPayload{
+			"partnerDaemonId": peer.ID,
+			"differences":     len(peerDiffs),
+		}
+		switch {
+		case len(appeared) > 0:
+			text := fmt.Sprintf("configuration of {daemon} differs from its HA partner %s", getHAPeerName(peer))
+			eventCenter.AddWarningEvent(text, strings.Join(appeared, "\n"), primary, primary.App,
+				dbmodel.EventCodeHAConfigMismatch, payload)
+		case len(peerDiffs) == 0 && len(oldDiffs[peerID]) > 0:
+			text := fmt.Sprintf("configuration of {daemon} matches its HA partner %s again", getHAPeerName(peer))
+			eventCenter.AddInfoEvent(text, primary, primary.App, dbmodel.EventCodeHAConfigMatched, payload)
+		}
+	}
+	ha.ConfigDiffs = diffs
+	ha.ConfigCheckedAt = storkutil.UTCNow()
+	return true
+}
+
+// Compares the configurations of the HA peers of the services the daemon
+// belongs to and stores the differences in the database. The services are
+// checked only when the daemon's configuration has changed or they have
+// not been checked yet.
+func commitHAConfigDiffs(dbIface interface{}, services []dbmodel.Service, daemon *dbmodel.Daemon, eventCenter eventcenter.EventCenter, state *AppStateMeta) error {
+	for i := range services {
+		ha := services[i].HAService
+		if ha == nil {
+			continue
+		}
+		if state != nil && state.SameConfigDaemons[daemon.Name] && !ha.ConfigCheckedAt.IsZero() {
+			continue
+		}
+		if !checkHAServiceConfigs(&services[i], daemon, eventCenter) {
+			continue
+		}
+		if err := dbmodel.UpdateHAServiceConfigDiffs(dbIface, ha); err != nil {
+			return err
+		}
+	}
+	return nil
+}
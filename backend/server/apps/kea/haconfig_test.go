@@ -0,0 +1,191 @@
+package kea
+
+import (
+	"testing"
+
+	require "github.com/stretchr/testify/require"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	storktest "isc.org/stork/server/test"
+)
+
+// Returns the DHCPv4 daemon with the given configuration.
+func getTestHAConfigDaemon(t *testing.T, id int64, config string) *dbmodel.Daemon {
+	daemon := dbmodel.NewKeaDaemon(dbmodel.DaemonNameDHCPv4, true)
+	daemon.ID = id
+	err := daemon.SetConfigFromJSON(config)
+	require.NoError(t, err)
+	return daemon
+}
+
+// Test that the differences in the subnets, pools, reservations, client
+// classes and lifetimes are reported and that other parameters are
+// ignored.
+func TestCompareHAPeerConfigs(t *testing.T) {
+	primary := getTestHAConfigDaemon(t, 1, `{
+        "Dhcp4": {
+            "valid-lifetime": 3600,
+            "interfaces-config": {"interfaces": ["eth0"]},
+            "client-classes": [{"name": "foo", "test": "member('ALL')"}],
+            "subnet4": [
+                {
+                    "id": 1,
+                    "subnet": "192.0.2.0/24",
+                    "interface": "eth0",
+                    "pools": [{"pool": "192.0.2.10 - 192.0.2.100"}],
+                    "reservations": [{"hw-address": "01:02:03:04:05:06", "ip-address": "192.0.2.5"}]
+                },
+                {
+                    "id": 2,
+                    "subnet": "198.51.100.0/24"
+                }
+            ]
+        }
+    }`)
+	peer := getTestHAConfigDaemon(t, 2, `{
+        "Dhcp4": {
+            "valid-lifetime": 7200,
+            "interfaces-config": {"interfaces": ["eth1"]},
+            "client-classes": [{"name": "foo", "test": "member('KNOWN')"}],
+            "subnet4": [
+                {
+                    "id": 1,
+                    "subnet": "192.0.2.0/24",
+                    "interface": "eth1",
+                    "pools": [{"pool": "192.0.2.10-192.0.2.100"}, {"pool": "192.0.2.150-192.0.2.200"}],
+                    "reservations": [{"hw-address": "01:02:03:04:05:06", "ip-address": "192.0.2.6"}]
+                }
+            ]
+        }
+    }`)
+
+	diffs := compareHAPeerConfigs(primary, peer)
+	require.Len(t, diffs, 5)
+	for _, diff := range diffs {
+		require.EqualValues(t, 2, diff.DaemonID)
+	}
+
+	require.Equal(t, "client-class", diffs[0].Element)
+	require.Equal(t, dbmodel.HAConfigDiffDifferent, diffs[0].Difference)
+	require.Equal(t, "/Dhcp4/client-classes[foo]", diffs[0].Path)
+
+	require.Equal(t, "pool", diffs[1].Element)
+	require.Equal(t, dbmodel.HAConfigDiffPeerOnly, diffs[1].Difference)
+	require.Equal(t, "/Dhcp4/subnet4[192.0.2.0/24]/pools[192.0.2.150-192.0.2.200]", diffs[1].Path)
+	require.Nil(t, diffs[1].Primary)
+	require.NotNil(t, diffs[1].Peer)
+
+	require.Equal(t, "reservation", diffs[2].Element)
+	require.Equal(t, dbmodel.HAConfigDiffDifferent, diffs[2].Difference)
+
+	require.Equal(t, "subnet", diffs[3].Element)
+	require.Equal(t, dbmodel.HAConfigDiffPrimaryOnly, diffs[3].Difference)
+	require.Equal(t, "/Dhcp4/subnet4[198.51.100.0/24]", diffs[3].Path)
+
+	require.Equal(t, "lifetime", diffs[4].Element)
+	require.Equal(t, dbmodel.HAConfigDiffDifferent, diffs[4].Difference)
+	require.Equal(t, "/Dhcp4/valid-lifetime", diffs[4].Path)
+	require.EqualValues(t, 3600, diffs[4].Primary)
+	require.EqualValues(t, 7200, diffs[4].Peer)
+
+	require.Empty(t, compareHAPeerConfigs(primary, primary))
+}
+
+// Test that the differences are stored in the HA service and that the
+// events are raised when the differences appear and disappear.
+func TestCheckHAServiceConfigs(t *testing.T) {
+	fec := &storktest.FakeEventCenter{}
+	primary := getTestHAConfigDaemon(t, 1, `{"Dhcp4": {"valid-lifetime": 3600}}`)
+	primary.App = &dbmodel.App{ID: 1, Name: "kea-1"}
+	secondary := getTestHAConfigDaemon(t, 2, `{"Dhcp4": {"valid-lifetime": 3600}}`)
+	secondary.App = &dbmodel.App{ID: 2, Name: "kea-2"}
+	service := &dbmodel.Service{
+		BaseService: dbmodel.BaseService{
+			Daemons: []*dbmodel.Daemon{primary, secondary},
+		},
+		HAService: &dbmodel.BaseHAService{
+			HAMode:      "load-balancing",
+			PrimaryID:   primary.ID,
+			SecondaryID: secondary.ID,
+		},
+	}
+
+	// The configurations match.
+	require.True(t, checkHAServiceConfigs(service, secondary, fec))
+	require.Empty(t, service.HAService.ConfigDiffs)
+	require.False(t, service.HAService.ConfigCheckedAt.IsZero())
+	require.Empty(t, fec.Events)
+
+	// The updated configuration of the secondary differs.
+	updated := getTestHAConfigDaemon(t, 2, `{"Dhcp4": {"valid-lifetime": 7200}}`)
+	updated.App = secondary.App
+	require.True(t, checkHAServiceConfigs(service, updated, fec))
+	require.Len(t, service.HAService.ConfigDiffs, 1)
+	require.Len(t, fec.Events, 1)
+	require.Equal(t, dbmodel.EvWarning, fec.Events[0].Level)
+	require.Equal(t, dbmodel.EventCodeHAConfigMismatch, fec.Events[0].Code)
+	require.Contains(t, fec.Events[0].Text, "differs from its HA partner kea-2")
+	require.EqualValues(t, primary.ID, fec.Events[0].Relations.DaemonID)
+
+	// The same difference doesn't raise the event again.
+	require.True(t, checkHAServiceConfigs(service, updated, fec))
+	require.Len(t, service.HAService.ConfigDiffs, 1)
+	require.Len(t, fec.Events, 1)
+
+	// The configurations match again.
+	require.True(t, checkHAServiceConfigs(service, secondary, fec))
+	require.Empty(t, service.HAService.ConfigDiffs)
+	require.Len(t, fec.Events, 2)
+	require.Equal(t, dbmodel.EvInfo, fec.Events[1].Level)
+	require.Equal(t, dbmodel.EventCodeHAConfigMatched, fec.Events[1].Code)
+
+	// The configurations are not compared without the primary server.
+	service.HAService.PrimaryID = 0
+	require.False(t, checkHAServiceConfigs(service, secondary, fec))
+}
+
+// Test that the differences between the configurations of the HA peers
+// are stored in the database when the apps are committed.
+func TestCommitAppIntoDBHAConfigDiffs(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	fec := &storktest.FakeEventCenter{}
+
+	var apps []*dbmodel.App
+	for i, serverName := range []string{"server1", "server2"} {
+		machine := &dbmodel.Machine{
+			Address:   "localhost",
+			AgentPort: int64(8080 + i),
+		}
+		err := dbmodel.AddMachine(db, machine)
+		require.NoError(t, err)
+
+		daemon := dbmodel.NewKeaDaemon(dbmodel.DaemonNameDHCPv4, true)
+		daemon.KeaDaemon.Config = getHATestConfig("Dhcp4", serverName, "load-balancing", "server1", "server2")
+		(*daemon.KeaDaemon.Config)["Dhcp4"].(map[string]interface{})["valid-lifetime"] = float64(3600 * (i + 1))
+		app := &dbmodel.App{
+			MachineID: machine.ID,
+			Machine:   machine,
+			Type:      dbmodel.AppTypeKea,
+			Active:    true,
+			Daemons:   []*dbmodel.Daemon{daemon},
+		}
+		err = CommitAppIntoDB(db, app, fec, nil)
+		require.NoError(t, err)
+		apps = append(apps, app)
+	}
+
+	services, err := dbmodel.GetDetailedAllServices(db)
+	require.NoError(t, err)
+	require.Len(t, services, 1)
+	ha := services[0].HAService
+	require.NotNil(t, ha)
+	require.False(t, ha.ConfigCheckedAt.IsZero())
+	require.Len(t, ha.ConfigDiffs, 1)
+	require.Equal(t, "lifetime", ha.ConfigDiffs[0].Element)
+	require.Equal(t, apps[1].Daemons[0].ID, ha.ConfigDiffs[0].DaemonID)
+	require.Equal(t, "/Dhcp4/valid-lifetime", ha.ConfigDiffs[0].Path)
+	require.EqualValues(t, 3600, ha.ConfigDiffs[0].Primary)
+	require.EqualValues(t, 7200, ha.ConfigDiffs[0].Peer)
+}
@@ -3,6 +3,7 @@ package kea
 import (
 	"context"
 	"fmt"
+	"time"
 
 	errors "github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
@@ -53,6 +54,11 @@ func NewHostsPuller(db *dbops.PgDB, agents agentcomm.ConnectedAgents) (*HostsPul
 	if err != nil {
 		return nil, err
 	}
+	// The same hosts may be fetched from different apps, so the apps
+	// are pulled one at a time to avoid conflicting updates.
+	periodicPuller.SetMaxWorkers(1)
+	// Fetching a large number of hosts page by page takes long.
+	periodicPuller.SetPullTimeout(10 * time.Minute)
 	hostsPuller.PeriodicPuller = periodicPuller
 	return hostsPuller, nil
 }
@@ -78,23 +84,18 @@ func (puller *HostsPuller) pullData() (int, error) {
 	}
 
 	// Synchronize hosts from all Kea apps.
-	var lastErr error
-	appsOkCnt := 0
-	for i := range apps {
-		err := updateHostsFromHostCmds(puller.DB, puller.Agents, &apps[i], seq)
-		if err != nil {
-			lastErr = err
-			log.Errorf("error occurred while fetching hosts from app %d: %+v", apps[i].ID, err)
-		} else {
-			appsOkCnt++
-		}
-	}
+	appsOkCnt, lastErr := puller.PullApps(apps, func(ctx context.Context, app *dbmodel.App) error {
+		return updateHostsFromHostCmds(puller.DB, puller.Agents, app, seq)
+	})
 
 	// Remove all associations between the hosts and tha apps that are no longer
-	// present.
-	err = dbmodel.DeleteLocalHostsWithOtherSeq(puller.DB, seq, "api")
-	if err != nil {
-		log.Errorf("error occurred while deleting old hosts after update from Kea apps: %+v", err)
+	// present. The hosts of the other apps haven't been updated when the action
+	// has been triggered for a selected app, so they must not be removed.
+	if puller.TriggeredAppID() == 0 {
+		err = dbmodel.DeleteLocalHostsWithOtherSeq(puller.DB, seq, "api")
+		if err != nil {
+			log.Errorf("error occurred while deleting old hosts after update from Kea apps: %+v", err)
+		}
 	}
 
 	log.Printf("completed pulling hosts from Kea apps: %d/%d succeeded", appsOkCnt, len(apps))
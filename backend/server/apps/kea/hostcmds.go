@@ -0,0 +1,402 @@
+package kea
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"isc.org/stork/server/agentcomm"
+	dbops "isc.org/stork/server/database"
+	dbmodel "isc.org/stork/server/database/model"
+	storkutil "isc.org/stork/util"
+)
+
+// Types of the host identifiers supported by the DHCPv4 and DHCPv6 servers.
+var hostIdentifierTypes = map[int][]string{
+	4: {"hw-address", "duid", "circuit-id", "client-id", "flex-id"},
+	6: {"hw-address", "duid", "flex-id"},
+}
+
+// Host reservation with the family of its addresses and the daemons to
+// which it is sent. All daemons serving the reservation's subnet, e.g.
+// both servers of the HA pair, hold the subnet reservation. The global
+// reservation is held by the daemons of the selected apps.
+type HostReservation struct {
+	Host    *dbmodel.Host
+	Family  int
+	Targets []DaemonCmdsTarget
+}
+
+// Returns the family of the host reservation. It is the family of the
+// subnet if the subnet is specified. Otherwise, it is determined from the
+// reserved addresses and the identifiers. It defaults to 4.
+func getHostFamily(host *dbmodel.Host, subnet *dbmodel.Subnet) int {
+	if subnet != nil {
+		return subnet.GetFamily()
+	}
+	for _, r := range host.IPReservations {
+		if strings.Contains(r.Address, ":") {
+			return 6
+		}
+		return 4
+	}
+	for _, id := range host.HostIdentifiers {
+		if id.Type == "duid" {
+			return 6
+		}
+	}
+	return 4
+}
+
+// Checks if the host reservation is valid for the subnet or, if the
+// subnet is nil, as the global reservation. The reservation must have
+// exactly one identifier, as the reservations in Kea. The reserved
+// addresses must belong to the family of the reservation and to the
+// subnet. The DHCPv4 reservation may include at most one address and
+// no prefixes.
+func validateHost(host *dbmodel.Host, subnet *dbmodel.Subnet, family int) error {
+	if len(host.HostIdentifiers) != 1 {
+		return errors.New("host reservation must have exactly one identifier")
+	}
+	id := host.HostIdentifiers[0]
+	supported := false
+	for _, idType := range hostIdentifierTypes[family] {
+		if id.Type == idType {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return errors.Errorf("identifier type %s is not supported in DHCPv%d host reservations", id.Type, family)
+	}
+	if len(id.Value) == 0 {
+		return errors.Errorf("value of the %s identifier must not be empty", id.Type)
+	}
+
+	var subnetNet *net.IPNet
+	if subnet != nil {
+		_, parsed, err := net.ParseCIDR(subnet.Prefix)
+		if err != nil {
+			return errors.Errorf("invalid prefix %s of the subnet with id %d", subnet.Prefix, subnet.ID)
+		}
+		subnetNet = parsed
+	}
+	addresses := 0
+	for _, r := range host.IPReservations {
+		cidr, err := storkutil.MakeCIDR(r.Address)
+		if err != nil {
+			return errors.Errorf("invalid reserved address %s", r.Address)
+		}
+		ip, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return errors.Errorf("invalid reserved address %s", r.Address)
+		}
+		if (ip.To4() != nil) != (family == 4) {
+			return errors.Errorf("reserved address %s is not an IPv%d address", r.Address, family)
+		}
+		ones, bits := ipNet.Mask.Size()
+		if ones != bits {
+			// The delegated prefixes don't have to belong to the subnet.
+			if family == 4 {
+				return errors.Errorf("prefix %s cannot be reserved in DHCPv4 host reservation", r.Address)
+			}
+			continue
+		}
+		addresses++
+		if subnetNet != nil && !subnetNet.Contains(ip) {
+			return errors.Errorf("reserved address %s does not belong to the subnet %s", ip, subnet.Prefix)
+		}
+	}
+	if family == 4 && addresses > 1 {
+		return errors.New("DHCPv4 host reservation may include at most one address")
+	}
+	for _, option := range host.OptionData {
+		if option.Code == 0 && len(option.Name) == 0 {
+			return errors.New("option must have the code or the name")
+		}
+	}
+	return nil
+}
+
+// Validates the host reservation and creates the host reservation with
+// the targets. The subnet is the subnet of the reservation with the local
+// subnets or nil for the global reservation. The apps hold the daemons to
+// which the reservation is sent. The apps serving the subnet which are
+// not on the list are skipped. The apps must include the access points.
+func NewHostReservation(host *dbmodel.Host, subnet *dbmodel.Subnet, apps []*dbmodel.App) (*HostReservation, error) {
+	family := getHostFamily(host, subnet)
+	if err := validateHost(host, subnet, family); err != nil {
+		return nil, err
+	}
+
+	daemonName := dbmodel.DaemonNameDHCPv4
+	if family == 6 {
+		daemonName = dbmodel.DaemonNameDHCPv6
+	}
+	reservation := &HostReservation{
+		Host:   host,
+		Family: family,
+	}
+	for _, app := range apps {
+		target := DaemonCmdsTarget{
+			App:        app,
+			DaemonName: daemonName,
+		}
+		if subnet != nil {
+			found := false
+			for _, ls := range subnet.LocalSubnets {
+				if ls.AppID == app.ID {
+					target.LocalSubnetID = ls.LocalSubnetID
+					found = true
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+		for _, daemon := range app.Daemons {
+			if daemon.Name == daemonName {
+				reservation.Targets = append(reservation.Targets, target)
+				break
+			}
+		}
+	}
+	if len(reservation.Targets) == 0 {
+		if subnet != nil {
+			return nil, errors.Errorf("no DHCPv%d server serves the subnet %s", family, subnet.Prefix)
+		}
+		return nil, errors.Errorf("no DHCPv%d server has been selected for the global host reservation", family)
+	}
+	return reservation, nil
+}
+
+// Returns the reservation in the format of the Kea host_cmds hooks library
+// for the given subnet ID.
+func (reservation *HostReservation) toKea(localSubnetID int64) map[string]interface{} {
+	host := reservation.Host
+	keaHost := map[string]interface{}{
+		"subnet-id": localSubnetID,
+	}
+	for _, id := range host.HostIdentifiers {
+		keaHost[id.Type] = id.ToHex(":")
+	}
+	var addresses, prefixes []string
+	for _, r := range host.IPReservations {
+		cidr, err := storkutil.MakeCIDR(r.Address)
+		if err != nil {
+			continue
+		}
+		ip, isPrefix, ok := storkutil.ParseIP(cidr)
+		if !ok {
+			continue
+		}
+		if isPrefix {
+			prefixes = append(prefixes, ip)
+		} else {
+			addresses = append(addresses, ip)
+		}
+	}
+	if reservation.Family == 4 {
+		if len(addresses) > 0 {
+			keaHost["ip-address"] = addresses[0]
+		}
+	} else {
+		if len(addresses) > 0 {
+			keaHost["ip-addresses"] = addresses
+		}
+		if len(prefixes) > 0 {
+			keaHost["prefixes"] = prefixes
+		}
+	}
+	if len(host.Hostname) > 0 {
+		keaHost["hostname"] = host.Hostname
+	}
+	if len(host.ClientClasses) > 0 {
+		keaHost["client-classes"] = host.ClientClasses
+	}
+	if len(host.OptionData) > 0 {
+		keaHost["option-data"] = host.OptionData
+	}
+	return keaHost
+}
+
+// Sends the reservation-add command to all target daemons.
+func (reservation *HostReservation) add(ctx context.Context, agents agentcomm.ConnectedAgents) (results []DaemonCmdsResult) {
+	for _, target := range reservation.Targets {
+		arguments := map[string]interface{}{
+			"reservation": reservation.toKea(target.LocalSubnetID),
+		}
+		results = append(results, sendDaemonCommand(ctx, agents, target, "reservation-add", arguments))
+	}
+	return results
+}
+
+// Sends the reservation-del command to all target daemons. The
+// reservation is identified by its identifier.
+func (reservation *HostReservation) del(ctx context.Context, agents agentcomm.ConnectedAgents) (results []DaemonCmdsResult) {
+	for _, target := range reservation.Targets {
+		arguments := map[string]interface{}{
+			"subnet-id": target.LocalSubnetID,
+		}
+		if len(reservation.Host.HostIdentifiers) > 0 {
+			id := reservation.Host.HostIdentifiers[0]
+			arguments["identifier-type"] = id.Type
+			arguments["identifier"] = id.ToHex(":")
+		}
+		results = append(results, sendDaemonCommand(ctx, agents, target, "reservation-del", arguments))
+	}
+	return results
+}
+
+// Adds the host reservation to the target daemons with the reservation-add
+// command. If any daemon accepts the reservation, the host is stored in
+// the database and associated with the apps whose daemons accepted it.
+// It returns the outcomes of the commands. The error is returned if the
+// host could not be stored.
+func AddHostReservation(ctx context.Context, db *dbops.PgDB, agents agentcomm.ConnectedAgents, reservation *HostReservation) ([]DaemonCmdsResult, error) {
+	results := reservation.add(ctx, agents)
+	apps := getSucceededApps(results)
+	if len(apps) == 0 {
+		return results, nil
+	}
+
+	tx, rollback, commit, err := dbops.Transaction(db)
+	if err != nil {
+		return results, err
+	}
+	defer rollback()
+
+	reservation.Host.ID = 0
+	err = dbmodel.AddHost(tx, reservation.Host)
+	if err != nil {
+		return results, err
+	}
+	for _, app := range apps {
+		err = dbmodel.AddAppToHost(tx, reservation.Host, app, "api", 0)
+		if err != nil {
+			return results, err
+		}
+	}
+	err = commit()
+	if err != nil {
+		err = errors.WithMessagef(err, "problem with committing host added to Kea apps")
+	}
+	return results, err
+}
+
+// Replaces the existing host reservation with the updated one. The
+// existing reservation is deleted from its target daemons with the
+// reservation-del command and the updated reservation is added to its
+// target daemons with the reservation-add command. The host in the
+// database is updated and associated with the apps whose daemons accepted
+// the updated reservation. The existing reservation is added back with
+// the reservation-add command to the daemons which deleted it and
+// rejected the updated one, so they don't lose the reservation. The apps
+// whose daemons also failed to restore the existing reservation are
+// dissociated from the host. The host is deleted from the database when
+// no apps hold it. It returns the outcomes of the commands and the error
+// if the database could not be updated.
+func UpdateHostReservation(ctx context.Context, db *dbops.PgDB, agents agentcomm.ConnectedAgents, existing, updated *HostReservation) ([]DaemonCmdsResult, error) {
+	results := existing.del(ctx, agents)
+	deletedApps := getSucceededApps(results)
+	addResults := updated.add(ctx, agents)
+	addedApps := getSucceededApps(addResults)
+	results = append(results, addResults...)
+	if len(deletedApps) == 0 && len(addedApps) == 0 {
+		return results, nil
+	}
+
+	restore := &HostReservation{
+		Host:   existing.Host,
+		Family: existing.Family,
+	}
+	for _, target := range existing.Targets {
+		_, added := addedApps[target.App.ID]
+		_, deleted := deletedApps[target.App.ID]
+		if deleted && !added {
+			restore.Targets = append(restore.Targets, target)
+		}
+	}
+	restoreResults := restore.add(ctx, agents)
+	restoredApps := getSucceededApps(restoreResults)
+	results = append(results, restoreResults...)
+
+	tx, rollback, commit, err := dbops.Transaction(db)
+	if err != nil {
+		return results, err
+	}
+	defer rollback()
+
+	hostID := existing.Host.ID
+	if len(addedApps) > 0 {
+		updated.Host.ID = hostID
+		err = dbmodel.UpdateHost(tx, updated.Host)
+		if err != nil {
+			return results, err
+		}
+		for _, app := range addedApps {
+			err = dbmodel.AddAppToHost(tx, updated.Host, app, "api", 0)
+			if err != nil {
+				return results, err
+			}
+		}
+	}
+	remaining := 0
+	for _, lh := range existing.Host.LocalHosts {
+		_, added := addedApps[lh.AppID]
+		_, deleted := deletedApps[lh.AppID]
+		_, restored := restoredApps[lh.AppID]
+		if deleted && !added && !restored {
+			_, err = dbmodel.DeleteAppFromHost(tx, hostID, lh.AppID)
+			if err != nil {
+				return results, err
+			}
+			continue
+		}
+		if !added {
+			remaining++
+		}
+	}
+	err = commit()
+	if err != nil {
+		return results, errors.WithMessagef(err, "problem with committing host %d updated in Kea apps", hostID)
+	}
+	if len(addedApps) == 0 && remaining == 0 {
+		err = dbmodel.DeleteHost(db, hostID)
+	}
+	return results, err
+}
+
+// Deletes the host reservation from the target daemons with the
+// reservation-del command. The apps whose daemons deleted the
+// reservation are dissociated from the host in the database, and the
+// host is deleted when no apps hold it. It returns the outcomes of the
+// commands and the error if the database could not be updated.
+func DeleteHostReservation(ctx context.Context, db *dbops.PgDB, agents agentcomm.ConnectedAgents, reservation *HostReservation) ([]DaemonCmdsResult, error) {
+	results := reservation.del(ctx, agents)
+	deletedApps := getSucceededApps(results)
+	if len(deletedApps) == 0 {
+		return results, nil
+	}
+
+	hostID := reservation.Host.ID
+	remaining := 0
+	for _, lh := range reservation.Host.LocalHosts {
+		if _, deleted := deletedApps[lh.AppID]; !deleted {
+			remaining++
+		}
+	}
+	if remaining == 0 {
+		return results, dbmodel.DeleteHost(db, hostID)
+	}
+	for appID := range deletedApps {
+		_, err := dbmodel.DeleteAppFromHost(db, hostID, appID)
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
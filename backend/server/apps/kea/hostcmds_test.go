@@ -0,0 +1,410 @@
+package kea
+
+import (
+	"context"
+	"testing"
+
+	require "github.com/stretchr/testify/require"
+
+	keactrl "isc.org/stork/appctrl/kea"
+	agentcommtest "isc.org/stork/server/agentcomm/test"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Returns the app with the control access point and the Kea daemon
+// of the given name.
+func getTestDaemonCmdsApp(id int64, daemonName string) *dbmodel.App {
+	accessPoints := []*dbmodel.AccessPoint{}
+	accessPoints = dbmodel.AppendAccessPoint(accessPoints, dbmodel.AccessPointControl, "localhost", "", 8000+id)
+	return &dbmodel.App{
+		ID:           id,
+		Type:         dbmodel.AppTypeKea,
+		AccessPoints: accessPoints,
+		Machine: &dbmodel.Machine{
+			ID:        id,
+			Address:   "localhost",
+			AgentPort: 8080,
+		},
+		Daemons: []*dbmodel.Daemon{
+			dbmodel.NewKeaDaemon(daemonName, true),
+		},
+	}
+}
+
+// Returns the subnet served by the apps with the given IDs. The local
+// subnet ID in each app is 10 times the app ID.
+func getTestHostCmdsSubnet(prefix string, appIDs ...int64) *dbmodel.Subnet {
+	subnet := &dbmodel.Subnet{
+		ID:     1,
+		Prefix: prefix,
+	}
+	for _, appID := range appIDs {
+		subnet.LocalSubnets = append(subnet.LocalSubnets, &dbmodel.LocalSubnet{
+			AppID:         appID,
+			LocalSubnetID: appID * 10,
+		})
+	}
+	return subnet
+}
+
+// Returns the host with the hw-address identifier and the reserved address.
+func getTestHostCmdsHost(address string) *dbmodel.Host {
+	return &dbmodel.Host{
+		HostIdentifiers: []dbmodel.HostIdentifier{
+			{
+				Type:  "hw-address",
+				Value: []byte{1, 2, 3, 4, 5, 6},
+			},
+		},
+		IPReservations: []dbmodel.IPReservation{
+			{
+				Address: address,
+			},
+		},
+		Hostname: "host.example.org",
+	}
+}
+
+// Test that the host reservation is validated against the subnet.
+func TestNewHostReservationValidation(t *testing.T) {
+	apps := []*dbmodel.App{getTestDaemonCmdsApp(1, dbmodel.DaemonNameDHCPv4)}
+	subnet := getTestHostCmdsSubnet("192.0.2.0/24", 1)
+
+	host := getTestHostCmdsHost("192.0.2.10")
+	reservation, err := NewHostReservation(host, subnet, apps)
+	require.NoError(t, err)
+	require.Equal(t, 4, reservation.Family)
+	require.Len(t, reservation.Targets, 1)
+	require.EqualValues(t, 10, reservation.Targets[0].LocalSubnetID)
+
+	// Address outside of the subnet.
+	host = getTestHostCmdsHost("192.0.3.10")
+	_, err = NewHostReservation(host, subnet, apps)
+	require.Error(t, err)
+
+	// Address of the wrong family.
+	host = getTestHostCmdsHost("2001:db8:1::10")
+	_, err = NewHostReservation(host, subnet, apps)
+	require.Error(t, err)
+
+	// Prefix in the DHCPv4 reservation.
+	host = getTestHostCmdsHost("192.0.2.0/28")
+	_, err = NewHostReservation(host, subnet, apps)
+	require.Error(t, err)
+
+	// Two addresses in the DHCPv4 reservation.
+	host = getTestHostCmdsHost("192.0.2.10")
+	host.IPReservations = append(host.IPReservations, dbmodel.IPReservation{Address: "192.0.2.11"})
+	_, err = NewHostReservation(host, subnet, apps)
+	require.Error(t, err)
+
+	// No identifier.
+	host = getTestHostCmdsHost("192.0.2.10")
+	host.HostIdentifiers = nil
+	_, err = NewHostReservation(host, subnet, apps)
+	require.Error(t, err)
+
+	// Identifier not supported by the DHCPv6 server.
+	subnet6 := getTestHostCmdsSubnet("2001:db8:1::/64", 2)
+	apps6 := []*dbmodel.App{getTestDaemonCmdsApp(2, dbmodel.DaemonNameDHCPv6)}
+	host = getTestHostCmdsHost("2001:db8:1::10")
+	host.HostIdentifiers[0].Type = "circuit-id"
+	_, err = NewHostReservation(host, subnet6, apps6)
+	require.Error(t, err)
+
+	// Option without the code and the name.
+	host = getTestHostCmdsHost("192.0.2.10")
+	host.OptionData = []dbmodel.KeaConfigOptionData{{Data: "192.0.2.1"}}
+	_, err = NewHostReservation(host, subnet, apps)
+	require.Error(t, err)
+
+	// The subnet is not served by the apps.
+	host = getTestHostCmdsHost("192.0.2.10")
+	_, err = NewHostReservation(host, getTestHostCmdsSubnet("192.0.2.0/24", 3), apps)
+	require.Error(t, err)
+}
+
+// Test that the targets of the subnet reservation include all daemons
+// serving the subnet and the global reservation is sent to the selected
+// apps.
+func TestNewHostReservationTargets(t *testing.T) {
+	apps := []*dbmodel.App{
+		getTestDaemonCmdsApp(1, dbmodel.DaemonNameDHCPv6),
+		getTestDaemonCmdsApp(2, dbmodel.DaemonNameDHCPv6),
+		getTestDaemonCmdsApp(3, dbmodel.DaemonNameDHCPv6),
+		getTestDaemonCmdsApp(4, dbmodel.DaemonNameDHCPv4),
+	}
+	subnet := getTestHostCmdsSubnet("2001:db8:1::/64", 1, 2, 4)
+
+	host := getTestHostCmdsHost("2001:db8:1::10")
+	host.IPReservations = append(host.IPReservations, dbmodel.IPReservation{Address: "3000::/64"})
+	reservation, err := NewHostReservation(host, subnet, apps)
+	require.NoError(t, err)
+	require.Equal(t, 6, reservation.Family)
+	require.Len(t, reservation.Targets, 2)
+	require.EqualValues(t, 1, reservation.Targets[0].App.ID)
+	require.EqualValues(t, 10, reservation.Targets[0].LocalSubnetID)
+	require.EqualValues(t, 2, reservation.Targets[1].App.ID)
+	require.EqualValues(t, 20, reservation.Targets[1].LocalSubnetID)
+
+	// Global reservation.
+	host = getTestHostCmdsHost("192.0.2.10")
+	reservation, err = NewHostReservation(host, nil, apps)
+	require.NoError(t, err)
+	require.Equal(t, 4, reservation.Family)
+	require.Len(t, reservation.Targets, 1)
+	require.EqualValues(t, 4, reservation.Targets[0].App.ID)
+	require.Zero(t, reservation.Targets[0].LocalSubnetID)
+}
+
+// Test that the reservation is converted to the host_cmds format.
+func TestHostReservationToKea(t *testing.T) {
+	apps := []*dbmodel.App{getTestDaemonCmdsApp(1, dbmodel.DaemonNameDHCPv6)}
+	subnet := getTestHostCmdsSubnet("2001:db8:1::/64", 1)
+	host := getTestHostCmdsHost("2001:db8:1::10")
+	host.HostIdentifiers[0].Type = "duid"
+	host.IPReservations = append(host.IPReservations, dbmodel.IPReservation{Address: "3000::/64"})
+	host.ClientClasses = []string{"foo"}
+	host.OptionData = []dbmodel.KeaConfigOptionData{{Name: "dns-servers", Data: "2001:db8:1::1"}}
+
+	reservation, err := NewHostReservation(host, subnet, apps)
+	require.NoError(t, err)
+
+	keaHost := reservation.toKea(10)
+	require.EqualValues(t, 10, keaHost["subnet-id"])
+	require.Equal(t, "01:02:03:04:05:06", keaHost["duid"])
+	require.Equal(t, []string{"2001:db8:1::10"}, keaHost["ip-addresses"])
+	require.Equal(t, []string{"3000::/64"}, keaHost["prefixes"])
+	require.Equal(t, "host.example.org", keaHost["hostname"])
+	require.Equal(t, []string{"foo"}, keaHost["client-classes"])
+	require.Len(t, keaHost["option-data"], 1)
+	require.NotContains(t, keaHost, "ip-address")
+}
+
+// Test that the command outcomes are reported per daemon and the
+// database is not updated when all daemons reject the reservation.
+func TestAddHostReservationRejected(t *testing.T) {
+	apps := []*dbmodel.App{
+		getTestDaemonCmdsApp(1, dbmodel.DaemonNameDHCPv4),
+		getTestDaemonCmdsApp(2, dbmodel.DaemonNameDHCPv4),
+	}
+	subnet := getTestHostCmdsSubnet("192.0.2.0/24", 1, 2)
+	reservation, err := NewHostReservation(getTestHostCmdsHost("192.0.2.10"), subnet, apps)
+	require.NoError(t, err)
+
+	fa := agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 1, "text": "Host already exists."}]`,
+		`[{"result": 2, "text": "'reservation-add' command not supported."}]`,
+	), nil)
+
+	results, err := AddHostReservation(context.Background(), nil, fa, reservation)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	require.False(t, results[0].Succeeded())
+	require.Equal(t, "Host already exists.", results[0].Text)
+	require.False(t, results[1].Succeeded())
+	require.Equal(t, keactrl.ResponseCommandUnsupported, results[1].Result)
+
+	require.Len(t, fa.RecordedCommands, 2)
+	command := fa.RecordedCommands[0]
+	require.Equal(t, "reservation-add", command.Command)
+	keaHost := (*command.Arguments)["reservation"].(map[string]interface{})
+	require.EqualValues(t, 10, keaHost["subnet-id"])
+	require.Equal(t, "192.0.2.10", keaHost["ip-address"])
+	keaHost = (*fa.RecordedCommands[1].Arguments)["reservation"].(map[string]interface{})
+	require.EqualValues(t, 20, keaHost["subnet-id"])
+}
+
+// Test adding, updating and deleting the host reservation in the HA pair
+// when one of the daemons fails.
+func TestHostReservationPartialFailure(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	m := &dbmodel.Machine{
+		Address:   "localhost",
+		AgentPort: 8080,
+	}
+	err := dbmodel.AddMachine(db, m)
+	require.NoError(t, err)
+	var apps []*dbmodel.App
+	for i := 0; i < 2; i++ {
+		app := getTestDaemonCmdsApp(0, dbmodel.DaemonNameDHCPv4)
+		app.AccessPoints[0].Port = int64(8000 + i)
+		app.Machine = nil
+		app.MachineID = m.ID
+		_, err = dbmodel.AddApp(db, app)
+		require.NoError(t, err)
+		apps = append(apps, app)
+	}
+	subnet := getTestHostCmdsSubnet("192.0.2.0/24", apps[0].ID, apps[1].ID)
+
+	// The reservation is added to the first daemon only.
+	reservation, err := NewHostReservation(getTestHostCmdsHost("192.0.2.10"), subnet, apps)
+	require.NoError(t, err)
+	fa := agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 0, "text": "Host added."}]`,
+		`[{"result": 1, "text": "Database error."}]`,
+	), nil)
+	results, err := AddHostReservation(context.Background(), db, fa, reservation)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	require.True(t, results[0].Succeeded())
+	require.False(t, results[1].Succeeded())
+
+	host, err := dbmodel.GetHost(db, reservation.Host.ID)
+	require.NoError(t, err)
+	require.NotNil(t, host)
+	require.Equal(t, "host.example.org", host.Hostname)
+	require.Len(t, host.LocalHosts, 1)
+	require.Equal(t, apps[0].ID, host.LocalHosts[0].AppID)
+	require.Equal(t, "api", host.LocalHosts[0].DataSource)
+
+	// The reservation is updated in both daemons.
+	existing, err := NewHostReservation(host, subnet, apps)
+	require.NoError(t, err)
+	updatedHost := getTestHostCmdsHost("192.0.2.20")
+	updatedHost.ClientClasses = []string{"foo"}
+	updated, err := NewHostReservation(updatedHost, subnet, apps)
+	require.NoError(t, err)
+	fa = agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 0, "text": "Host deleted."}]`,
+		`[{"result": 3, "text": "Host not deleted (not found)."}]`,
+		`[{"result": 0, "text": "Host added."}]`,
+		`[{"result": 0, "text": "Host added."}]`,
+	), nil)
+	results, err = UpdateHostReservation(context.Background(), db, fa, existing, updated)
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+	require.Equal(t, "reservation-del", results[0].Command)
+	require.Equal(t, "reservation-add", results[3].Command)
+
+	host, err = dbmodel.GetHost(db, host.ID)
+	require.NoError(t, err)
+	require.NotNil(t, host)
+	require.Equal(t, []string{"foo"}, host.ClientClasses)
+	require.Len(t, host.IPReservations, 1)
+	require.Equal(t, "192.0.2.20/32", host.IPReservations[0].Address)
+	require.Len(t, host.LocalHosts, 2)
+
+	// The reservation is deleted from the second daemon only.
+	reservation, err = NewHostReservation(host, subnet, apps)
+	require.NoError(t, err)
+	fa = agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 1, "text": "Database error."}]`,
+		`[{"result": 0, "text": "Host deleted."}]`,
+	), nil)
+	results, err = DeleteHostReservation(context.Background(), db, fa, reservation)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	require.Equal(t, "hw-address", (*fa.RecordedCommands[0].Arguments)["identifier-type"])
+	require.Equal(t, "01:02:03:04:05:06", (*fa.RecordedCommands[0].Arguments)["identifier"])
+
+	host, err = dbmodel.GetHost(db, host.ID)
+	require.NoError(t, err)
+	require.NotNil(t, host)
+	require.Len(t, host.LocalHosts, 1)
+	require.Equal(t, apps[0].ID, host.LocalHosts[0].AppID)
+
+	// The reservation is deleted from the remaining daemon.
+	fa = agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 0, "text": "Host deleted."}]`,
+		`[{"result": 3, "text": "Host not deleted (not found)."}]`,
+	), nil)
+	reservation, err = NewHostReservation(host, subnet, apps)
+	require.NoError(t, err)
+	_, err = DeleteHostReservation(context.Background(), db, fa, reservation)
+	require.NoError(t, err)
+
+	host, err = dbmodel.GetHost(db, host.ID)
+	require.NoError(t, err)
+	require.Nil(t, host)
+}
+
+// Test that the existing reservation is added back to the daemon which
+// deleted it and rejected the updated reservation.
+func TestUpdateHostReservationRestore(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	m := &dbmodel.Machine{
+		Address:   "localhost",
+		AgentPort: 8080,
+	}
+	err := dbmodel.AddMachine(db, m)
+	require.NoError(t, err)
+	var apps []*dbmodel.App
+	for i := 0; i < 2; i++ {
+		app := getTestDaemonCmdsApp(0, dbmodel.DaemonNameDHCPv4)
+		app.AccessPoints[0].Port = int64(8000 + i)
+		app.Machine = nil
+		app.MachineID = m.ID
+		_, err = dbmodel.AddApp(db, app)
+		require.NoError(t, err)
+		apps = append(apps, app)
+	}
+	subnet := getTestHostCmdsSubnet("192.0.2.0/24", apps[0].ID, apps[1].ID)
+
+	reservation, err := NewHostReservation(getTestHostCmdsHost("192.0.2.10"), subnet, apps)
+	require.NoError(t, err)
+	fa := agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 0, "text": "Host added."}]`,
+		`[{"result": 0, "text": "Host added."}]`,
+	), nil)
+	_, err = AddHostReservation(context.Background(), db, fa, reservation)
+	require.NoError(t, err)
+	host, err := dbmodel.GetHost(db, reservation.Host.ID)
+	require.NoError(t, err)
+	require.NotNil(t, host)
+
+	// The second daemon rejects the updated reservation, so the existing
+	// one is added back.
+	existing, err := NewHostReservation(host, subnet, apps)
+	require.NoError(t, err)
+	updated, err := NewHostReservation(getTestHostCmdsHost("192.0.2.20"), subnet, apps)
+	require.NoError(t, err)
+	fa = agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 0, "text": "Host deleted."}]`,
+		`[{"result": 0, "text": "Host deleted."}]`,
+		`[{"result": 0, "text": "Host added."}]`,
+		`[{"result": 1, "text": "Database error."}]`,
+		`[{"result": 0, "text": "Host added."}]`,
+	), nil)
+	results, err := UpdateHostReservation(context.Background(), db, fa, existing, updated)
+	require.NoError(t, err)
+	require.Len(t, results, 5)
+	require.Equal(t, "reservation-add", results[4].Command)
+	require.Equal(t, apps[1].ID, results[4].Target.App.ID)
+	require.True(t, results[4].Succeeded())
+	keaHost := (*fa.RecordedCommands[4].Arguments)["reservation"].(map[string]interface{})
+	require.Equal(t, "192.0.2.10", keaHost["ip-address"])
+
+	// Both apps still hold the host.
+	host, err = dbmodel.GetHost(db, host.ID)
+	require.NoError(t, err)
+	require.NotNil(t, host)
+	require.Len(t, host.LocalHosts, 2)
+
+	// The app is dissociated from the host when the existing reservation
+	// cannot be restored.
+	existing, err = NewHostReservation(host, subnet, apps)
+	require.NoError(t, err)
+	fa = agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 0, "text": "Host deleted."}]`,
+		`[{"result": 0, "text": "Host deleted."}]`,
+		`[{"result": 0, "text": "Host added."}]`,
+		`[{"result": 1, "text": "Database error."}]`,
+		`[{"result": 1, "text": "Database error."}]`,
+	), nil)
+	results, err = UpdateHostReservation(context.Background(), db, fa, existing, updated)
+	require.NoError(t, err)
+	require.Len(t, results, 5)
+	require.False(t, results[4].Succeeded())
+
+	host, err = dbmodel.GetHost(db, host.ID)
+	require.NoError(t, err)
+	require.NotNil(t, host)
+	require.Len(t, host.LocalHosts, 1)
+	require.Equal(t, apps[0].ID, host.LocalHosts[0].AppID)
+}
@@ -0,0 +1,311 @@
+package kea
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	keactrl "isc.org/stork/appctrl/kea"
+	"isc.org/stork/server/agentcomm"
+	dbops "isc.org/stork/server/database"
+	dbmodel "isc.org/stork/server/database/model"
+)
+
+// Lease deleted, updated, wiped or for which the DDNS update is resent
+// with the commands of the lease_cmds hooks library. The action is sent
+// to all target daemons, e.g. both servers of the HA pair holding the
+// lease. The lease is nil when the leases of the subnet are wiped.
+type LeaseAction struct {
+	Lease   *dbmodel.Lease
+	Family  int
+	Targets []DaemonCmdsTarget
+}
+
+// Returns the family of the lease IP address or 0 if the address is
+// invalid.
+func getLeaseFamily(ipAddress string) int {
+	ip := net.ParseIP(ipAddress)
+	switch {
+	case ip == nil:
+		return 0
+	case ip.To4() != nil:
+		return 4
+	default:
+		return 6
+	}
+}
+
+// Returns the targets being the daemons of the subnet's family serving
+// the subnet. All daemons must have the lease_cmds hooks library loaded,
+// so the leases are consistent between the HA peers.
+func getLeaseSubnetTargets(subnet *dbmodel.Subnet, apps []*dbmodel.App) ([]DaemonCmdsTarget, error) {
+	daemonName := getFamilyDaemonName(subnet.GetFamily())
+	var targets []DaemonCmdsTarget
+	for _, ls := range subnet.LocalSubnets {
+		for _, app := range apps {
+			if app.ID != ls.AppID {
+				continue
+			}
+			if !hasLeaseCmdsHook(app, daemonName) {
+				return nil, errors.Errorf("app %s lacks the lease_cmds hooks library", app.Name)
+			}
+			targets = append(targets, DaemonCmdsTarget{
+				App:           app,
+				DaemonName:    daemonName,
+				LocalSubnetID: ls.LocalSubnetID,
+			})
+			break
+		}
+	}
+	if len(targets) == 0 {
+		return nil, errors.Errorf("subnet %s is not served by any DHCPv%d server", subnet.Prefix, subnet.GetFamily())
+	}
+	return targets, nil
+}
+
+// Searches for the lease with the given IP address in all Kea servers
+// having the lease_cmds hooks library loaded. The lease type is used
+// for the IPv6 leases. If it is empty, the IA_NA and IA_PD leases are
+// searched. It returns the action with the servers holding the lease as
+// the targets and the lease returned by the first of them. The action is
+// nil if none of the servers has the lease. The communication failures
+// are returned as the failed commands. The error is returned if the
+// apps cannot be fetched from the database.
+func FindLeaseHolders(db *dbops.PgDB, agents agentcomm.ConnectedAgents, ipAddress, leaseType string) (*LeaseAction, []DaemonCmdsResult, error) {
+	family := getLeaseFamily(ipAddress)
+	if family == 0 {
+		return nil, nil, errors.Errorf("invalid lease IP address %s", ipAddress)
+	}
+	leaseTypes := []string{"IA_NA", "IA_PD"}
+	if len(leaseType) > 0 {
+		leaseTypes = []string{leaseType}
+	}
+	apps, err := dbmodel.GetAppsByType(db, dbmodel.AppTypeKea)
+	if err != nil {
+		err = errors.WithMessagef(err, "failed to fetch Kea apps while searching for lease %s", ipAddress)
+		return nil, nil, err
+	}
+
+	action := &LeaseAction{
+		Family: family,
+	}
+	var failures []DaemonCmdsResult
+	daemonName := getFamilyDaemonName(family)
+	for i := range apps {
+		app := &apps[i]
+		if !hasLeaseCmdsHook(app, daemonName) {
+			continue
+		}
+		target := DaemonCmdsTarget{
+			App:        app,
+			DaemonName: daemonName,
+		}
+		var lease *dbmodel.Lease
+		if family == 4 {
+			lease, err = GetLease4ByIPAddress(agents, app, ipAddress)
+		} else {
+			for _, t := range leaseTypes {
+				lease, err = GetLease6ByIPAddress(agents, app, t, ipAddress)
+				if err != nil || lease != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			failures = append(failures, DaemonCmdsResult{
+				Target:  target,
+				Command: fmt.Sprintf("lease%d-get", family),
+				Result:  keactrl.ResponseError,
+				Text:    err.Error(),
+			})
+			continue
+		}
+		if lease == nil {
+			continue
+		}
+		if action.Lease == nil {
+			action.Lease = lease
+		}
+		target.LocalSubnetID = int64(lease.SubnetID)
+		action.Targets = append(action.Targets, target)
+	}
+	if len(action.Targets) == 0 {
+		return nil, failures, nil
+	}
+	return action, failures, nil
+}
+
+// Validates the lease and creates the action adding or updating it in all
+// servers serving the subnet. The lease must belong to the subnet. The
+// IPv4 lease requires the hardware address. The IPv6 lease requires the
+// DUID, the IAID and the lease type, i.e. IA_NA or IA_PD. The apps must
+// include the access points and the daemons with the configurations.
+func NewLeaseUpdateAction(lease *dbmodel.Lease, subnet *dbmodel.Subnet, apps []*dbmodel.App) (*LeaseAction, error) {
+	ip := net.ParseIP(strings.TrimSpace(lease.IPAddress))
+	if ip == nil {
+		return nil, errors.Errorf("invalid lease IP address %s", lease.IPAddress)
+	}
+	lease.IPAddress = ip.String()
+	family := getLeaseFamily(lease.IPAddress)
+	if family != subnet.GetFamily() {
+		return nil, errors.Errorf("lease %s does not belong to the subnet %s", lease.IPAddress, subnet.Prefix)
+	}
+	subnetNet, err := parseSubnetPrefix(subnet.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	if family == 4 {
+		if len(lease.HWAddress) == 0 {
+			return nil, errors.Errorf("missing hardware address of the lease %s", lease.IPAddress)
+		}
+		lease.Type = ""
+	} else {
+		if len(lease.DUID) == 0 {
+			return nil, errors.Errorf("missing DUID of the lease %s", lease.IPAddress)
+		}
+		if len(lease.Type) == 0 {
+			lease.Type = "IA_NA"
+		}
+		switch lease.Type {
+		case "IA_NA":
+			lease.PrefixLength = 0
+		case "IA_PD":
+			if lease.PrefixLength == 0 || lease.PrefixLength > 128 {
+				return nil, errors.Errorf("invalid prefix length %d of the lease %s", lease.PrefixLength, lease.IPAddress)
+			}
+		default:
+			return nil, errors.Errorf("invalid type %s of the lease %s", lease.Type, lease.IPAddress)
+		}
+	}
+	// The delegated prefixes don't have to belong to the subnet.
+	if lease.Type != "IA_PD" && !subnetNet.Contains(ip) {
+		return nil, errors.Errorf("lease %s does not belong to the subnet %s", lease.IPAddress, subnet.Prefix)
+	}
+	targets, err := getLeaseSubnetTargets(subnet, apps)
+	if err != nil {
+		return nil, err
+	}
+	return &LeaseAction{
+		Lease:   lease,
+		Family:  family,
+		Targets: targets,
+	}, nil
+}
+
+// Creates the action wiping all leases in the subnet in all servers
+// serving it.
+func NewLeaseWipeAction(subnet *dbmodel.Subnet, apps []*dbmodel.App) (*LeaseAction, error) {
+	targets, err := getLeaseSubnetTargets(subnet, apps)
+	if err != nil {
+		return nil, err
+	}
+	return &LeaseAction{
+		Family:  subnet.GetFamily(),
+		Targets: targets,
+	}, nil
+}
+
+// Returns the prefix of the commands, i.e. lease4 or lease6.
+func (action *LeaseAction) commandPrefix() string {
+	if action.Family == 6 {
+		return "lease6"
+	}
+	return "lease4"
+}
+
+// Sends the command with the same arguments to all targets.
+func (action *LeaseAction) send(ctx context.Context, agents agentcomm.ConnectedAgents, name string, arguments map[string]interface{}) []DaemonCmdsResult {
+	var results []DaemonCmdsResult
+	for _, target := range action.Targets {
+		results = append(results, sendDaemonCommand(ctx, agents, target, name, arguments))
+	}
+	return results
+}
+
+// Deletes the lease from all targets with the lease4-del or lease6-del
+// command. It returns the outcomes of the commands.
+func (action *LeaseAction) Delete(ctx context.Context, agents agentcomm.ConnectedAgents) []DaemonCmdsResult {
+	arguments := map[string]interface{}{
+		"ip-address": action.Lease.IPAddress,
+	}
+	if action.Family == 6 {
+		arguments["type"] = action.Lease.Type
+	}
+	return action.send(ctx, agents, action.commandPrefix()+"-del", arguments)
+}
+
+// Triggers the DDNS update for the lease in all targets with the
+// lease4-resend-ddns or lease6-resend-ddns command. It returns the
+// outcomes of the commands.
+func (action *LeaseAction) ResendDDNS(ctx context.Context, agents agentcomm.ConnectedAgents) []DaemonCmdsResult {
+	arguments := map[string]interface{}{
+		"ip-address": action.Lease.IPAddress,
+	}
+	return action.send(ctx, agents, action.commandPrefix()+"-resend-ddns", arguments)
+}
+
+// Returns the lease in the format of the lease4-update and lease6-update
+// commands, with the local ID of the subnet in the target.
+func (action *LeaseAction) toKea(localSubnetID int64) map[string]interface{} {
+	lease := action.Lease
+	keaLease := map[string]interface{}{
+		"ip-address":   lease.IPAddress,
+		"subnet-id":    localSubnetID,
+		"fqdn-fwd":     lease.FqdnFwd,
+		"fqdn-rev":     lease.FqdnRev,
+		"state":        lease.State,
+		"force-create": true,
+	}
+	if lease.ValidLifetime > 0 {
+		keaLease["valid-lft"] = lease.ValidLifetime
+	}
+	if len(lease.HWAddress) > 0 {
+		keaLease["hw-address"] = lease.HWAddress
+	}
+	if len(lease.Hostname) > 0 {
+		keaLease["hostname"] = lease.Hostname
+	}
+	if action.Family == 4 {
+		if len(lease.ClientID) > 0 {
+			keaLease["client-id"] = lease.ClientID
+		}
+		return keaLease
+	}
+	keaLease["duid"] = lease.DUID
+	keaLease["iaid"] = lease.IAID
+	keaLease["type"] = lease.Type
+	if lease.Type == "IA_PD" {
+		keaLease["prefix-len"] = lease.PrefixLength
+	}
+	if lease.PreferredLifetime > 0 {
+		keaLease["preferred-lft"] = lease.PreferredLifetime
+	}
+	return keaLease
+}
+
+// Adds or updates the lease in all targets with the lease4-update or
+// lease6-update command creating the lease if it doesn't exist. It
+// returns the outcomes of the commands.
+func (action *LeaseAction) Update(ctx context.Context, agents agentcomm.ConnectedAgents) []DaemonCmdsResult {
+	var results []DaemonCmdsResult
+	for _, target := range action.Targets {
+		results = append(results, sendDaemonCommand(ctx, agents, target, action.commandPrefix()+"-update", action.toKea(target.LocalSubnetID)))
+	}
+	return results
+}
+
+// Deletes all leases of the subnet in all targets with the lease4-wipe
+// or lease6-wipe command. It returns the outcomes of the commands.
+func (action *LeaseAction) Wipe(ctx context.Context, agents agentcomm.ConnectedAgents) []DaemonCmdsResult {
+	var results []DaemonCmdsResult
+	for _, target := range action.Targets {
+		arguments := map[string]interface{}{
+			"subnet-id": target.LocalSubnetID,
+		}
+		results = append(results, sendDaemonCommand(ctx, agents, target, action.commandPrefix()+"-wipe", arguments))
+	}
+	return results
+}
@@ -0,0 +1,235 @@
+package kea
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	require "github.com/stretchr/testify/require"
+
+	keadata "isc.org/stork/appdata/kea"
+	agentcommtest "isc.org/stork/server/agentcomm/test"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Returns the app with the DHCP daemon of the given name having the
+// lease_cmds hooks library loaded.
+func getTestLeaseCmdsApp(t *testing.T, id int64, daemonName string) *dbmodel.App {
+	config := fmt.Sprintf(`{"Dhcp%s": {"hooks-libraries": [{"library": "libdhcp_lease_cmds.so"}]}}`, daemonName[len(daemonName)-1:])
+	return getTestSubnetCmdsApp(t, id, daemonName, config)
+}
+
+// Test that the lease is validated and the targets are the servers
+// serving the subnet.
+func TestNewLeaseUpdateAction(t *testing.T) {
+	apps := []*dbmodel.App{
+		getTestLeaseCmdsApp(t, 1, dbmodel.DaemonNameDHCPv4),
+		getTestLeaseCmdsApp(t, 2, dbmodel.DaemonNameDHCPv4),
+	}
+	subnet := getTestHostCmdsSubnet("192.0.2.0/24", 1, 2)
+
+	lease := &dbmodel.Lease{
+		Lease: keadata.Lease{
+			IPAddress: "192.0.2.10",
+			HWAddress: "01:02:03:04:05:06",
+		},
+	}
+	action, err := NewLeaseUpdateAction(lease, subnet, apps)
+	require.NoError(t, err)
+	require.Equal(t, 4, action.Family)
+	require.Len(t, action.Targets, 2)
+	require.EqualValues(t, 10, action.Targets[0].LocalSubnetID)
+	require.EqualValues(t, 20, action.Targets[1].LocalSubnetID)
+
+	// The lease outside of the subnet.
+	lease.IPAddress = "192.0.3.10"
+	_, err = NewLeaseUpdateAction(lease, subnet, apps)
+	require.Error(t, err)
+
+	// Missing hardware address.
+	lease.IPAddress = "192.0.2.10"
+	lease.HWAddress = ""
+	_, err = NewLeaseUpdateAction(lease, subnet, apps)
+	require.Error(t, err)
+
+	// The server lacks the lease_cmds hooks library.
+	lease.HWAddress = "01:02:03:04:05:06"
+	apps[1] = getTestDaemonCmdsApp(2, dbmodel.DaemonNameDHCPv4)
+	_, err = NewLeaseUpdateAction(lease, subnet, apps)
+	require.Error(t, err)
+}
+
+// Test that the IPv6 lease is validated.
+func TestNewLeaseUpdateActionIPv6(t *testing.T) {
+	apps := []*dbmodel.App{
+		getTestLeaseCmdsApp(t, 1, dbmodel.DaemonNameDHCPv6),
+	}
+	subnet := getTestHostCmdsSubnet("2001:db8:1::/64", 1)
+
+	lease := &dbmodel.Lease{
+		Lease: keadata.Lease{
+			IPAddress: "2001:db8:1::10",
+			DUID:      "01:02:03:04",
+			IAID:      5,
+		},
+	}
+	action, err := NewLeaseUpdateAction(lease, subnet, apps)
+	require.NoError(t, err)
+	require.Equal(t, 6, action.Family)
+	require.Equal(t, "IA_NA", lease.Type)
+
+	// The delegated prefix requires the prefix length.
+	lease.IPAddress = "3000::"
+	lease.Type = "IA_PD"
+	_, err = NewLeaseUpdateAction(lease, subnet, apps)
+	require.Error(t, err)
+	lease.PrefixLength = 56
+	_, err = NewLeaseUpdateAction(lease, subnet, apps)
+	require.NoError(t, err)
+
+	lease.Type = "IA_TA"
+	_, err = NewLeaseUpdateAction(lease, subnet, apps)
+	require.Error(t, err)
+
+	lease.Type = "IA_NA"
+	lease.IPAddress = "2001:db8:1::10"
+	lease.DUID = ""
+	_, err = NewLeaseUpdateAction(lease, subnet, apps)
+	require.Error(t, err)
+
+	// Family mismatch.
+	lease.DUID = "01:02:03:04"
+	lease.IPAddress = "192.0.2.10"
+	_, err = NewLeaseUpdateAction(lease, subnet, apps)
+	require.Error(t, err)
+}
+
+// Test that the lease actions send the commands to all targets.
+func TestLeaseActionCommands(t *testing.T) {
+	apps := []*dbmodel.App{
+		getTestLeaseCmdsApp(t, 1, dbmodel.DaemonNameDHCPv6),
+		getTestLeaseCmdsApp(t, 2, dbmodel.DaemonNameDHCPv6),
+	}
+	subnet := getTestHostCmdsSubnet("2001:db8:1::/64", 1, 2)
+	lease := &dbmodel.Lease{
+		Lease: keadata.Lease{
+			IPAddress:     "3000::",
+			DUID:          "01:02:03:04",
+			IAID:          5,
+			Type:          "IA_PD",
+			PrefixLength:  56,
+			ValidLifetime: 3600,
+		},
+	}
+	action, err := NewLeaseUpdateAction(lease, subnet, apps)
+	require.NoError(t, err)
+
+	fa := agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 0, "text": "Lease for address 3000::, subnet-id 10 updated."}]`,
+		`[{"result": 1, "text": "invalid subnet-id"}]`,
+		`[{"result": 0, "text": "Lease deleted."}]`,
+		`[{"result": 3, "text": "IPv6 lease not found."}]`,
+		`[{"result": 0, "text": "NCR generated"}]`,
+		`[{"result": 0, "text": "NCR generated"}]`,
+	), nil)
+
+	results := action.Update(context.Background(), fa)
+	require.Len(t, results, 2)
+	require.True(t, results[0].Succeeded())
+	require.False(t, results[1].Succeeded())
+	require.Equal(t, "lease6-update", fa.RecordedCommands[0].Command)
+	arguments := *fa.RecordedCommands[0].Arguments
+	require.EqualValues(t, 10, arguments["subnet-id"])
+	require.Equal(t, "IA_PD", arguments["type"])
+	require.EqualValues(t, 56, arguments["prefix-len"])
+	require.EqualValues(t, 3600, arguments["valid-lft"])
+	require.Equal(t, true, arguments["force-create"])
+	require.EqualValues(t, 20, (*fa.RecordedCommands[1].Arguments)["subnet-id"])
+
+	results = action.Delete(context.Background(), fa)
+	require.Len(t, results, 2)
+	require.True(t, results[0].Succeeded())
+	require.True(t, results[1].Succeeded())
+	require.Equal(t, "lease6-del", fa.RecordedCommands[2].Command)
+	require.Equal(t, "IA_PD", (*fa.RecordedCommands[2].Arguments)["type"])
+
+	results = action.ResendDDNS(context.Background(), fa)
+	require.Len(t, results, 2)
+	require.Equal(t, "lease6-resend-ddns", fa.RecordedCommands[4].Command)
+	require.Equal(t, "3000::", (*fa.RecordedCommands[4].Arguments)["ip-address"])
+}
+
+// Test that the leases are wiped in all servers serving the subnet.
+func TestLeaseActionWipe(t *testing.T) {
+	apps := []*dbmodel.App{
+		getTestLeaseCmdsApp(t, 1, dbmodel.DaemonNameDHCPv4),
+		getTestLeaseCmdsApp(t, 2, dbmodel.DaemonNameDHCPv4),
+	}
+	action, err := NewLeaseWipeAction(getTestHostCmdsSubnet("192.0.2.0/24", 1, 2), apps)
+	require.NoError(t, err)
+
+	fa := agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 0, "text": "Deleted 5 IPv4 lease(s) from subnet(s) 10"}]`,
+		`[{"result": 3, "text": "Deleted 0 IPv4 lease(s) from subnet(s) 20"}]`,
+	), nil)
+	results := action.Wipe(context.Background(), fa)
+	require.Len(t, results, 2)
+	require.True(t, results[0].Succeeded())
+	require.True(t, results[1].Succeeded())
+	require.Equal(t, "lease4-wipe", fa.RecordedCommands[0].Command)
+	require.EqualValues(t, 10, (*fa.RecordedCommands[0].Arguments)["subnet-id"])
+	require.EqualValues(t, 20, (*fa.RecordedCommands[1].Arguments)["subnet-id"])
+
+	_, err = NewLeaseWipeAction(getTestHostCmdsSubnet("192.0.2.0/24", 3), apps)
+	require.Error(t, err)
+}
+
+// Test that the servers holding the lease are found.
+func TestFindLeaseHolders(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	for i := 0; i < 3; i++ {
+		m := &dbmodel.Machine{
+			Address:   "localhost",
+			AgentPort: int64(8080 + i),
+		}
+		err := dbmodel.AddMachine(db, m)
+		require.NoError(t, err)
+		app := getTestLeaseCmdsApp(t, 0, dbmodel.DaemonNameDHCPv4)
+		app.Machine = nil
+		app.MachineID = m.ID
+		_, err = dbmodel.AddApp(db, app)
+		require.NoError(t, err)
+	}
+
+	fa := agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 0, "arguments": {"ip-address": "192.0.2.10", "hw-address": "01:02:03:04:05:06", "subnet-id": 7}}]`,
+		`[{"result": 3, "text": "Lease not found."}]`,
+		`[{"result": 0, "arguments": {"ip-address": "192.0.2.10", "hw-address": "01:02:03:04:05:06", "subnet-id": 8}}]`,
+	), nil)
+	action, failures, err := FindLeaseHolders(db, fa, "192.0.2.10", "")
+	require.NoError(t, err)
+	require.Empty(t, failures)
+	require.NotNil(t, action)
+	require.NotNil(t, action.Lease)
+	require.Equal(t, "01:02:03:04:05:06", action.Lease.HWAddress)
+	require.Len(t, action.Targets, 2)
+	require.EqualValues(t, 7, action.Targets[0].LocalSubnetID)
+	require.EqualValues(t, 8, action.Targets[1].LocalSubnetID)
+
+	fa = agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 3, "text": "Lease not found."}]`,
+		`[{"result": 3, "text": "Lease not found."}]`,
+		`[{"result": 1, "text": "unable to communicate"}]`,
+	), nil)
+	action, failures, err = FindLeaseHolders(db, fa, "192.0.2.10", "")
+	require.NoError(t, err)
+	require.Nil(t, action)
+	require.Len(t, failures, 1)
+	require.Equal(t, "lease4-get", failures[0].Command)
+
+	_, _, err = FindLeaseHolders(db, fa, "foo", "")
+	require.Error(t, err)
+}
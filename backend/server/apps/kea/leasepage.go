@@ -0,0 +1,240 @@
+package kea
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"net"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	keactrl "isc.org/stork/appctrl/kea"
+	"isc.org/stork/server/agentcomm"
+	dbmodel "isc.org/stork/server/database/model"
+)
+
+// Maximum number of the pages fetched from each server to build a single
+// page of the subnet leases. It bounds the walk through the leases of
+// other subnets when the leases of the IPv6 subnet with the prefix pools
+// are listed. The page holding fewer leases than the limit is returned
+// when it is reached.
+const subnetLeasesPageMaxFetches = 10
+
+// Lease returned in the listing of the subnet leases. The lease held by
+// several servers, e.g. both servers of the HA pair, is listed once per
+// server. All but the first occurrence are marked as duplicates.
+type SubnetLease struct {
+	dbmodel.Lease
+	Duplicate bool
+}
+
+// Page of the subnet leases merged from all servers serving the subnet
+// and sorted by the IP address. Next is the address from which the next
+// page starts. It is empty for the last page.
+type SubnetLeasesPage struct {
+	Leases []SubnetLease
+	Next   string
+}
+
+// Returns the address preceding the given one or nil if the address is
+// the first one in its family.
+func getPreviousAddress(ip net.IP) net.IP {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	value := new(big.Int).SetBytes(ip)
+	if value.Sign() == 0 {
+		return nil
+	}
+	value.Sub(value, big.NewInt(1))
+	previous := make(net.IP, len(ip))
+	b := value.Bytes()
+	copy(previous[len(previous)-len(b):], b)
+	return previous
+}
+
+// Returns the last address of the subnet.
+func getLastAddress(ipNet *net.IPNet) net.IP {
+	last := make(net.IP, len(ipNet.IP))
+	for i := range ipNet.IP {
+		last[i] = ipNet.IP[i] | ^ipNet.Mask[i]
+	}
+	return last
+}
+
+// Compares two IP addresses of the same family. It returns a negative
+// value when a precedes b, 0 when they are equal and a positive value
+// otherwise.
+func compareAddresses(a, b net.IP) int {
+	return bytes.Compare(a.To16(), b.To16())
+}
+
+// Fetches the page of the leases following the given address from the
+// target daemon with the lease4-get-page or lease6-get-page command.
+// The "start" address denotes the first page. The returned leases are
+// sorted by the IP address and belong to all subnets of the daemon.
+func getLeasesPage(ctx context.Context, agents agentcomm.ConnectedAgents, target DaemonCmdsTarget, family int, from string, limit int64) ([]dbmodel.Lease, error) {
+	commandName := fmt.Sprintf("lease%d-get-page", family)
+	daemons, err := keactrl.NewDaemons(target.DaemonName)
+	if err != nil {
+		return nil, err
+	}
+	arguments := map[string]interface{}{
+		"from":  from,
+		"limit": limit,
+	}
+	command, err := keactrl.NewCommand(commandName, daemons, &arguments)
+	if err != nil {
+		return nil, err
+	}
+	response := []LeaseGetMultipleResponse{}
+	respResult, err := agents.ForwardToKeaOverHTTP(ctx, target.App, []*keactrl.Command{command}, &response)
+	switch {
+	case err != nil:
+		return nil, err
+	case respResult.Error != nil:
+		return nil, respResult.Error
+	case len(respResult.CmdsErrors) > 0 && respResult.CmdsErrors[0] != nil:
+		return nil, respResult.CmdsErrors[0]
+	case len(response) == 0:
+		return nil, errors.Errorf("invalid response received from Kea to the %s command", commandName)
+	case response[0].Result == keactrl.ResponseEmpty:
+		return nil, nil
+	}
+	if err = validateGetLeasesResponse(commandName, response[0].Result, response[0].Arguments); err != nil {
+		return nil, err
+	}
+	return response[0].Arguments.Leases, nil
+}
+
+// Returns the page of the leases belonging to the subnet. The leases are
+// fetched from all servers serving the subnet with the lease4-get-page
+// or lease6-get-page command, so all servers must have the lease_cmds
+// hooks library loaded. The page starts after the from address or at
+// the beginning of the subnet if the address is empty. It holds at
+// least the limit of leases unless it is the last page. It may hold a
+// few more leases when the last address of the page is leased by more
+// than one server. The lease returned by more than one server is marked
+// as duplicate in all but the first occurrence. The leases are searched
+// within the subnet range. The IPv6 subnets with the prefix pools are
+// the exception because the delegated prefixes don't have to belong to
+// the subnet, so the leases of all subnets are walked through. The walk
+// is bounded, so the page may hold fewer leases than the limit even if
+// it is not the last page; Next is not empty then. The error is returned
+// when any of the servers fails to return the leases.
+func GetSubnetLeasesPage(ctx context.Context, agents agentcomm.ConnectedAgents, subnet *dbmodel.Subnet, apps []*dbmodel.App, from string, limit int64) (*SubnetLeasesPage, error) {
+	if limit <= 0 {
+		return nil, errors.Errorf("invalid limit %d of the leases page", limit)
+	}
+	targets, err := getLeaseSubnetTargets(subnet, apps)
+	if err != nil {
+		return nil, err
+	}
+	subnetNet, err := parseSubnetPrefix(subnet.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	family := subnet.GetFamily()
+	// The last address of the walked range or nil when the leases
+	// of all subnets are walked.
+	var last net.IP
+	if family == 4 || len(subnet.PrefixPools) == 0 {
+		last = getLastAddress(subnetNet)
+	}
+
+	cursor := "start"
+	if len(from) > 0 {
+		ip := net.ParseIP(from)
+		if ip == nil || getLeaseFamily(from) != family {
+			return nil, errors.Errorf("invalid address %s from which the leases of the subnet %s are fetched", from, subnet.Prefix)
+		}
+		cursor = ip.String()
+	} else if last != nil {
+		if previous := getPreviousAddress(subnetNet.IP); previous != nil {
+			cursor = previous.String()
+		}
+	}
+
+	page := &SubnetLeasesPage{}
+	for fetches := 1; ; fetches++ {
+		// Each server returns the page of its leases. The leases up to the
+		// lowest last address of the full pages have been returned by all
+		// servers, so they can be merged. The remaining ones are fetched
+		// again in the next iteration.
+		var boundary net.IP
+		leases := make([][]dbmodel.Lease, len(targets))
+		for i, target := range targets {
+			leases[i], err = getLeasesPage(ctx, agents, target, family, cursor, limit)
+			if err != nil {
+				return nil, errors.WithMessagef(err, "failed to fetch the leases of the subnet %s from %s", subnet.Prefix, target.App.Name)
+			}
+			if int64(len(leases[i])) < limit {
+				continue
+			}
+			lastLeased := net.ParseIP(leases[i][len(leases[i])-1].IPAddress)
+			if lastLeased == nil {
+				return nil, errors.Errorf("invalid lease address %s returned by %s", leases[i][len(leases[i])-1].IPAddress, target.App.Name)
+			}
+			if boundary == nil || compareAddresses(lastLeased, boundary) < 0 {
+				boundary = lastLeased
+			}
+		}
+
+		var merged []SubnetLease
+		for i, target := range targets {
+			for _, lease := range leases[i] {
+				ip := net.ParseIP(lease.IPAddress)
+				if ip == nil || (boundary != nil && compareAddresses(ip, boundary) > 0) {
+					continue
+				}
+				if int64(lease.SubnetID) != target.LocalSubnetID {
+					continue
+				}
+				lease.AppID = target.App.ID
+				lease.App = target.App
+				merged = append(merged, SubnetLease{Lease: lease})
+			}
+		}
+		sort.SliceStable(merged, func(i, j int) bool {
+			return compareAddresses(net.ParseIP(merged[i].IPAddress), net.ParseIP(merged[j].IPAddress)) < 0
+		})
+		page.Leases = append(page.Leases, merged...)
+
+		// There are no more leases or the remaining leases are beyond the
+		// subnet.
+		if boundary == nil || (last != nil && compareAddresses(boundary, last) >= 0) {
+			page.Next = ""
+			break
+		}
+		cursor = boundary.String()
+		page.Next = cursor
+		if int64(len(page.Leases)) >= limit || fetches >= subnetLeasesPageMaxFetches {
+			break
+		}
+	}
+
+	// Cut the page after the lease at the limit including other servers'
+	// leases of the same address.
+	if int64(len(page.Leases)) > limit {
+		cut := net.ParseIP(page.Leases[limit-1].IPAddress)
+		n := int(limit)
+		for n < len(page.Leases) && compareAddresses(net.ParseIP(page.Leases[n].IPAddress), cut) == 0 {
+			n++
+		}
+		if n < len(page.Leases) {
+			page.Leases = page.Leases[:n]
+			page.Next = cut.String()
+		}
+	}
+
+	// Mark the leases returned by more than one server.
+	seen := make(map[string]bool)
+	for i := range page.Leases {
+		key := page.Leases[i].Type + "/" + page.Leases[i].IPAddress
+		page.Leases[i].Duplicate = seen[key]
+		seen[key] = true
+	}
+	return page, nil
+}
@@ -0,0 +1,122 @@
+package kea
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	require "github.com/stretchr/testify/require"
+
+	agentcommtest "isc.org/stork/server/agentcomm/test"
+	dbmodel "isc.org/stork/server/database/model"
+)
+
+// Test that the preceding address is returned.
+func TestGetPreviousAddress(t *testing.T) {
+	require.Equal(t, "192.0.1.255", getPreviousAddress(net.ParseIP("192.0.2.0")).String())
+	require.Equal(t, "2001:db8:0:ffff:ffff:ffff:ffff:ffff", getPreviousAddress(net.ParseIP("2001:db8:1::")).String())
+	require.Nil(t, getPreviousAddress(net.ParseIP("0.0.0.0")))
+	require.Nil(t, getPreviousAddress(net.ParseIP("::")))
+}
+
+// Test that the leases returned by the HA peers are merged and paged.
+func TestGetSubnetLeasesPage(t *testing.T) {
+	apps := []*dbmodel.App{
+		getTestLeaseCmdsApp(t, 1, dbmodel.DaemonNameDHCPv4),
+		getTestLeaseCmdsApp(t, 2, dbmodel.DaemonNameDHCPv4),
+	}
+	subnet := getTestHostCmdsSubnet("192.0.2.0/24", 1, 2)
+
+	fa := agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 0, "arguments": {"leases": [
+            {"ip-address": "192.0.2.1", "hw-address": "01:02:03:04:05:06", "subnet-id": 10},
+            {"ip-address": "192.0.2.2", "hw-address": "01:02:03:04:05:07", "subnet-id": 10}
+        ], "count": 2}}]`,
+		`[{"result": 0, "arguments": {"leases": [
+            {"ip-address": "192.0.2.1", "hw-address": "01:02:03:04:05:06", "subnet-id": 20},
+            {"ip-address": "192.0.2.3", "hw-address": "01:02:03:04:05:08", "subnet-id": 20}
+        ], "count": 2}}]`,
+		`[{"result": 0, "arguments": {"leases": [
+            {"ip-address": "192.0.2.2", "hw-address": "01:02:03:04:05:07", "subnet-id": 10}
+        ], "count": 1}}]`,
+		`[{"result": 0, "arguments": {"leases": [
+            {"ip-address": "192.0.2.3", "hw-address": "01:02:03:04:05:08", "subnet-id": 20},
+            {"ip-address": "192.0.3.1", "hw-address": "01:02:03:04:05:09", "subnet-id": 30}
+        ], "count": 2}}]`,
+	), nil)
+
+	page, err := GetSubnetLeasesPage(context.Background(), fa, subnet, apps, "", 2)
+	require.NoError(t, err)
+	require.Len(t, page.Leases, 2)
+	require.Equal(t, "192.0.2.1", page.Leases[0].IPAddress)
+	require.EqualValues(t, 1, page.Leases[0].AppID)
+	require.False(t, page.Leases[0].Duplicate)
+	require.Equal(t, "192.0.2.1", page.Leases[1].IPAddress)
+	require.EqualValues(t, 2, page.Leases[1].AppID)
+	require.True(t, page.Leases[1].Duplicate)
+	require.Equal(t, "192.0.2.1", page.Next)
+
+	require.Equal(t, "lease4-get-page", fa.RecordedCommands[0].Command)
+	require.Equal(t, "192.0.1.255", (*fa.RecordedCommands[0].Arguments)["from"])
+	require.EqualValues(t, 2, (*fa.RecordedCommands[0].Arguments)["limit"])
+
+	page, err = GetSubnetLeasesPage(context.Background(), fa, subnet, apps, page.Next, 2)
+	require.NoError(t, err)
+	require.Equal(t, "192.0.2.1", (*fa.RecordedCommands[2].Arguments)["from"])
+	require.Len(t, page.Leases, 2)
+	require.Equal(t, "192.0.2.2", page.Leases[0].IPAddress)
+	require.Equal(t, "192.0.2.3", page.Leases[1].IPAddress)
+	require.False(t, page.Leases[1].Duplicate)
+	require.Empty(t, page.Next)
+}
+
+// Test that the walk through the leases of other subnets is bounded
+// when the leases of the IPv6 subnet with the prefix pools are listed.
+func TestGetSubnetLeasesPageMaxFetches(t *testing.T) {
+	apps := []*dbmodel.App{
+		getTestLeaseCmdsApp(t, 1, dbmodel.DaemonNameDHCPv6),
+	}
+	subnet := getTestHostCmdsSubnet("2001:db8:1::/64", 1)
+	subnet.PrefixPools = []dbmodel.PrefixPool{
+		{Prefix: "3000::/48", DelegatedLen: 64},
+	}
+
+	// The server returns only the leases of other subnets.
+	var responses []string
+	for i := 1; i <= subnetLeasesPageMaxFetches+1; i++ {
+		responses = append(responses, fmt.Sprintf(`[{"result": 0, "arguments": {"leases": [
+            {"ip-address": "2001:db8:2::%x", "duid": "01:02:03", "subnet-id": 20}
+        ], "count": 1}}]`, i))
+	}
+	fa := agentcommtest.NewFakeAgents(mockConfigPushResponses(responses...), nil)
+
+	page, err := GetSubnetLeasesPage(context.Background(), fa, subnet, apps, "", 1)
+	require.NoError(t, err)
+	require.Empty(t, page.Leases)
+	require.Len(t, fa.RecordedCommands, subnetLeasesPageMaxFetches)
+	require.Equal(t, fmt.Sprintf("2001:db8:2::%x", subnetLeasesPageMaxFetches), page.Next)
+}
+
+// Test that the leases page is not returned when any of the servers
+// fails to return the leases.
+func TestGetSubnetLeasesPageError(t *testing.T) {
+	apps := []*dbmodel.App{
+		getTestLeaseCmdsApp(t, 1, dbmodel.DaemonNameDHCPv6),
+		getTestLeaseCmdsApp(t, 2, dbmodel.DaemonNameDHCPv6),
+	}
+	subnet := getTestHostCmdsSubnet("2001:db8:1::/64", 1, 2)
+
+	fa := agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 3, "text": "0 IPv6 lease(s) found."}]`,
+		`[{"result": 1, "text": "unable to communicate"}]`,
+	), nil)
+	_, err := GetSubnetLeasesPage(context.Background(), fa, subnet, apps, "", 10)
+	require.Error(t, err)
+	require.Equal(t, "lease6-get-page", fa.RecordedCommands[0].Command)
+
+	_, err = GetSubnetLeasesPage(context.Background(), fa, subnet, apps, "192.0.2.1", 10)
+	require.Error(t, err)
+	_, err = GetSubnetLeasesPage(context.Background(), fa, subnet, apps, "", 0)
+	require.Error(t, err)
+}
@@ -0,0 +1,402 @@
+package kea
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"isc.org/stork/server/agentcomm"
+	dbops "isc.org/stork/server/database"
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/eventcenter"
+)
+
+// Format of the timestamp in the Kea log messages.
+const logTimeFormat = "2006-01-02 15:04:05.999"
+
+// Maximum length of the tail of the log file scanned when checking for
+// the repeated errors.
+const logErrorsTailLength = 64 * 1024
+
+// Matches the Kea log message produced with the default pattern, e.g.
+// "2020-10-12 10:11:12.123 ERROR [kea-dhcp4.dhcpsrv/1234.139] DHCPSRV_OPEN_SOCKET_FAIL ...".
+// The process and thread IDs following the logger name are optional.
+var logLinePattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\.\d+)\s+(FATAL|ERROR|WARN|INFO|DEBUG)\s+\[([^\]/]+)(?:/[^\]]*)?\]\s+([A-Z][A-Z0-9_]+)\s?(.*)$`)
+
+// Kea log message parsed from the log file. The Kea log messages don't
+// include the time zone and the zone of the agent's machine is not known,
+// so the time is the wall-clock time of the message expressed in UTC,
+// e.g. the message logged at 10:11 is at 10:11 UTC regardless of the
+// zones of the agent and the server.
+type LogRecord struct {
+	Time      time.Time
+	Severity  string
+	Logger    string
+	MessageID string
+	Text      string
+}
+
+// Criteria for selecting the log records. The zero values are ignored.
+type LogRecordFilter struct {
+	// Severities of the selected records, e.g. ERROR.
+	Severities []string
+	// Prefix of the message IDs of the selected records, e.g.
+	// DHCP4_LEASE_ALLOC.
+	MessageIDPrefix string
+	// Time range of the selected records. The records are compared with
+	// the wall-clock times of the range, i.e. its zone is ignored.
+	From time.Time
+	To   time.Time
+}
+
+// Number of the log records with the given message ID.
+type LogMessageCount struct {
+	MessageID string
+	Severity  string
+	Count     int64
+}
+
+// Parses a single line of the Kea log. The timestamp is the wall-clock
+// time expressed in UTC (see LogRecord). It returns nil if the line is
+// not a Kea log message, e.g. it is a continuation of the multi-line
+// message.
+func ParseLogLine(line string) *LogRecord {
+	m := logLinePattern.FindStringSubmatch(line)
+	if m == nil {
+		return nil
+	}
+	t, err := time.Parse(logTimeFormat, m[1])
+	if err != nil {
+		return nil
+	}
+	return &LogRecord{
+		Time:      t,
+		Severity:  m[2],
+		Logger:    m[3],
+		MessageID: m[4],
+		Text:      m[5],
+	}
+}
+
+// Parses the lines of the Kea log into records. The lines which are not
+// Kea log messages are appended to the text of the preceding record. Such
+// lines preceding the first record are dropped.
+func ParseLogLines(lines []string) (records []*LogRecord) {
+	var last *LogRecord
+	for _, line := range lines {
+		record := ParseLogLine(line)
+		if record == nil {
+			if last != nil {
+				last.Text += "\n" + line
+			}
+			continue
+		}
+		records = append(records, record)
+		last = record
+	}
+	return records
+}
+
+// Checks if the filter selects any records.
+func (filter *LogRecordFilter) IsEmpty() bool {
+	return len(filter.Severities) == 0 && len(filter.MessageIDPrefix) == 0 &&
+		filter.From.IsZero() && filter.To.IsZero()
+}
+
+// Returns the wall-clock time of the given time expressed in UTC, so it
+// can be compared with the times of the log records.
+func getWallClockTime(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// Checks if the record matches the filter.
+func (filter *LogRecordFilter) Match(record *LogRecord) bool {
+	if len(filter.Severities) > 0 {
+		matched := false
+		for _, severity := range filter.Severities {
+			if strings.EqualFold(severity, record.Severity) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if !strings.HasPrefix(record.MessageID, filter.MessageIDPrefix) {
+		return false
+	}
+	if !filter.From.IsZero() && record.Time.Before(getWallClockTime(filter.From)) {
+		return false
+	}
+	if !filter.To.IsZero() && record.Time.After(getWallClockTime(filter.To)) {
+		return false
+	}
+	return true
+}
+
+// Returns the regular expression selecting the lines by severity and
+// message ID prefix. It is sent to the agent, so the lines which don't
+// match the filter are not sent to the server. It returns an empty
+// string if the filter doesn't select the records by severity nor
+// message ID prefix.
+func (filter *LogRecordFilter) GetLineFilter() string {
+	if len(filter.Severities) == 0 && len(filter.MessageIDPrefix) == 0 {
+		return ""
+	}
+	severities := `\S+`
+	if len(filter.Severities) > 0 {
+		var quoted []string
+		for _, severity := range filter.Severities {
+			quoted = append(quoted, regexp.QuoteMeta(strings.ToUpper(severity)))
+		}
+		severities = "(" + strings.Join(quoted, "|") + ")"
+	}
+	return fmt.Sprintf(`^\S+ \S+ %s\s+\[[^\]]*\]\s+%s`, severities, regexp.QuoteMeta(filter.MessageIDPrefix))
+}
+
+// Returns the records matching the filter.
+func FilterLogRecords(records []*LogRecord, filter *LogRecordFilter) (filtered []*LogRecord) {
+	for _, record := range records {
+		if filter.Match(record) {
+			filtered = append(filtered, record)
+		}
+	}
+	return filtered
+}
+
+// Counts the records per message ID. The counts are sorted from the
+// most frequent message ID.
+func CountLogRecords(records []*LogRecord) (counts []LogMessageCount) {
+	indexes := make(map[string]int)
+	for _, record := range records {
+		if i, ok := indexes[record.MessageID]; ok {
+			counts[i].Count++
+			continue
+		}
+		indexes[record.MessageID] = len(counts)
+		counts = append(counts, LogMessageCount{
+			MessageID: record.MessageID,
+			Severity:  record.Severity,
+			Count:     1,
+		})
+	}
+	sort.SliceStable(counts, func(i, j int) bool {
+		if counts[i].Count == counts[j].Count {
+			return counts[i].MessageID < counts[j].MessageID
+		}
+		return counts[i].Count > counts[j].Count
+	})
+	return counts
+}
+
+// Log target state remembered by the log errors checker. It holds the
+// time of the last checked record and the number of the records logged
+// at this time, so the records logged at the same time but received in
+// the subsequent checks are counted.
+type logErrorsTargetState struct {
+	lastTime    time.Time
+	lastRecords map[string]int
+}
+
+// Checks the Kea log files for the error messages repeated more times
+// than the threshold and raises an event for each such message. It
+// remembers the time of the last checked record for each log target,
+// so each record is counted only once. The records found during the
+// first check of the log target are not counted because they may be
+// arbitrarily old.
+type LogErrorsChecker struct {
+	targets map[int64]*logErrorsTargetState
+	mutex   *sync.Mutex
+}
+
+// Creates new instance of the checker.
+func NewLogErrorsChecker() *LogErrorsChecker {
+	return &LogErrorsChecker{
+		targets: make(map[int64]*logErrorsTargetState),
+		mutex:   new(sync.Mutex),
+	}
+}
+
+// Returns the key identifying the records logged at the same time.
+func getLogRecordKey(record *LogRecord) string {
+	return fmt.Sprintf("%s %s %s %s", record.Severity, record.Logger, record.MessageID, record.Text)
+}
+
+// Returns the records logged after the last check of the log target and
+// remembers the last of them. The records logged at the time of the last
+// record of the previous check are new if they outnumber the same records
+// seen before. The records must be ordered by time. It returns false if
+// the log target has not been checked before.
+func (checker *LogErrorsChecker) getNewRecords(target *dbmodel.LogTarget, records []*LogRecord) ([]*LogRecord, bool) {
+	checker.mutex.Lock()
+	defer checker.mutex.Unlock()
+
+	state, checked := checker.targets[target.ID]
+	if !checked {
+		state = &logErrorsTargetState{}
+		checker.targets[target.ID] = state
+	}
+
+	var newRecords []*LogRecord
+	seen := make(map[string]int)
+	for _, record := range records {
+		if record.Time.Before(state.lastTime) {
+			continue
+		}
+		if record.Time.Equal(state.lastTime) {
+			key := getLogRecordKey(record)
+			seen[key]++
+			if seen[key] <= state.lastRecords[key] {
+				continue
+			}
+		}
+		newRecords = append(newRecords, record)
+	}
+
+	// Remember the records logged at the time of the last record.
+	if len(newRecords) > 0 {
+		lastTime := newRecords[len(newRecords)-1].Time
+		if !lastTime.Equal(state.lastTime) {
+			state.lastTime = lastTime
+			state.lastRecords = make(map[string]int)
+		}
+		for _, record := range newRecords {
+			if record.Time.Equal(lastTime) {
+				state.lastRecords[getLogRecordKey(record)]++
+			}
+		}
+	}
+	return newRecords, checked
+}
+
+// Counts the error records logged after the last check of the log target
+// and raises the events for the message IDs repeated at least threshold
+// times. It returns true if the log target has been checked before.
+func (checker *LogErrorsChecker) checkRecords(daemon *dbmodel.Daemon, target *dbmodel.LogTarget, records []*LogRecord, threshold int64, eventCenter eventcenter.EventCenter) bool {
+	records, checked := checker.getNewRecords(target, records)
+	if !checked {
+		return false
+	}
+
+	var newRecords []*LogRecord
+	for _, record := range records {
+		if record.Severity == "ERROR" || record.Severity == "FATAL" {
+			newRecords = append(newRecords, record)
+		}
+	}
+	for _, count := range CountLogRecords(newRecords) {
+		if count.Count < threshold {
+			continue
+		}
+		// The text of the last record with the message ID is a good example.
+		var details string
+		for i := len(newRecords) - 1; i >= 0; i-- {
+			if newRecords[i].MessageID == count.MessageID {
+				details = fmt.Sprintf("%s %s", count.MessageID, newRecords[i].Text)
+				break
+			}
+		}
+		text := fmt.Sprintf("{daemon} logged %s %d times in %s", count.MessageID, count.Count, target.Output)
+		payload := dbmodel.EventPayload{
+			"messageId": count.MessageID,
+			"count":     count.Count,
+			"output":    target.Output,
+		}
+		eventCenter.AddErrorEvent(text, details, daemon, daemon.App, daemon.App.Machine,
+			dbmodel.EventCodeDaemonLogErrors, payload)
+	}
+	return true
+}
+
+// Fetches the error messages from the Kea log files of the monitored
+// daemons of the app and raises the events when the same error message
+// has been logged at least threshold times since the last check. Only
+// the tail of each log file is scanned for the error messages. It returns
+// the last error which occurred while fetching the log files.
+func (checker *LogErrorsChecker) checkApp(ctx context.Context, app *dbmodel.App, agents agentcomm.ConnectedAgents, threshold int64, eventCenter eventcenter.EventCenter) (lastErr error) {
+	filter := &LogRecordFilter{
+		Severities: []string{"ERROR", "FATAL"},
+	}
+	for _, daemon := range app.Daemons {
+		if !daemon.Monitored || !daemon.Active {
+			continue
+		}
+		daemon.App = app
+		for _, target := range daemon.LogTargets {
+			if target.Output == "stdout" || target.Output == "stderr" ||
+				strings.HasPrefix(target.Output, "syslog") {
+				continue
+			}
+			tail, err := agents.TailTextFile(ctx, app.Machine.Address, app.Machine.AgentPort,
+				target.Output, logErrorsTailLength, agentcomm.LogAnchor{}, filter.GetLineFilter(), logErrorsTailLength)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"file":   target.Output,
+					"daemon": daemon.ID,
+				}).Warnf("problem with checking errors in the Kea log: %+v", err)
+				lastErr = err
+				continue
+			}
+			checker.checkRecords(daemon, target, ParseLogLines(tail.Lines), threshold, eventCenter)
+		}
+	}
+	return lastErr
+}
+
+// Instance of the puller which periodically checks the Kea log files for
+// the repeated error messages.
+type LogErrorsPuller struct {
+	*agentcomm.PeriodicPuller
+	EventCenter eventcenter.EventCenter
+	Checker     *LogErrorsChecker
+}
+
+// Create an instance of the puller which periodically checks the Kea log
+// files for the repeated error messages.
+func NewLogErrorsPuller(db *dbops.PgDB, agents agentcomm.ConnectedAgents, eventCenter eventcenter.EventCenter) (*LogErrorsPuller, error) {
+	puller := &LogErrorsPuller{
+		EventCenter: eventCenter,
+		Checker:     NewLogErrorsChecker(),
+	}
+	periodicPuller, err := agentcomm.NewPeriodicPuller(db, agents, "Kea Log Errors",
+		"kea_log_errors_puller_interval", puller.checkLogErrors)
+	if err != nil {
+		return nil, err
+	}
+	puller.PeriodicPuller = periodicPuller
+	return puller, nil
+}
+
+// Stops the timer triggering the log checks.
+func (puller *LogErrorsPuller) Shutdown() {
+	puller.PeriodicPuller.Shutdown()
+}
+
+// Fetches the error messages from the Kea log files of all monitored
+// daemons and raises the events when the same error message has been
+// logged at least as many times as specified in the
+// kea_log_errors_threshold setting since the last check.
+func (puller *LogErrorsPuller) checkLogErrors() (int, error) {
+	threshold, err := dbmodel.GetSettingInt(puller.DB, "kea_log_errors_threshold")
+	if err != nil {
+		return 0, err
+	}
+	if threshold == 0 {
+		return 0, nil
+	}
+
+	apps, err := dbmodel.GetAppsByType(puller.DB, dbmodel.AppTypeKea)
+	if err != nil {
+		return 0, err
+	}
+	return puller.PullApps(apps, func(ctx context.Context, app *dbmodel.App) error {
+		return puller.Checker.checkApp(ctx, app, puller.Agents, threshold, puller.EventCenter)
+	})
+}
@@ -0,0 +1,342 @@
+package kea
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	agentcommtest "isc.org/stork/server/agentcomm/test"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	storktest "isc.org/stork/server/test"
+)
+
+// Returns the lines of the sample Kea log.
+func getTestLogLines() []string {
+	return []string{
+		"of the previous message",
+		"2020-10-12 10:11:12.123 INFO  [kea-dhcp4.dhcp4/1234.139] DHCP4_STARTED Kea DHCPv4 server version 1.8.0 started",
+		"2020-10-12 10:11:13.456 ERROR [kea-dhcp4.dhcpsrv/1234.139] DHCPSRV_OPEN_SOCKET_FAIL failed to open socket: the interface eth1 has no usable IPv4 addresses configured",
+		"2020-10-12 10:11:14.001 INFO  [kea-dhcp4.leases/1234.140] DHCP4_LEASE_ALLOC [hwtype=1 00:01:02:03:04:05], cid=[no info], tid=0x1: lease 192.0.2.1 has been allocated",
+		"2020-10-12 10:11:15.002 INFO  [kea-dhcp4.leases/1234.140] DHCP4_LEASE_ADVERT [hwtype=1 00:01:02:03:04:06], cid=[no info], tid=0x2: lease 192.0.2.2 will be advertised",
+		"2020-10-12 10:11:16.003 INFO  [kea-dhcp4.leases] DHCP4_LEASE_ALLOC [hwtype=1 00:01:02:03:04:06], cid=[no info], tid=0x2: lease 192.0.2.2 has been allocated",
+		"2020-10-12 10:11:17.004 ERROR [kea-dhcp4.commands/1234.139] COMMAND_PROCESS_ERROR1 Error during command processing: unable to parse",
+		"  continuation of the error",
+	}
+}
+
+// Test that a single Kea log line is parsed.
+func TestParseLogLine(t *testing.T) {
+	record := ParseLogLine(getTestLogLines()[2])
+	require.NotNil(t, record)
+	require.Equal(t, time.Date(2020, 10, 12, 10, 11, 13, 456000000, time.UTC), record.Time)
+	require.Equal(t, "ERROR", record.Severity)
+	require.Equal(t, "kea-dhcp4.dhcpsrv", record.Logger)
+	require.Equal(t, "DHCPSRV_OPEN_SOCKET_FAIL", record.MessageID)
+	require.Equal(t, "failed to open socket: the interface eth1 has no usable IPv4 addresses configured", record.Text)
+
+	// Logger without process and thread IDs.
+	record = ParseLogLine(getTestLogLines()[5])
+	require.NotNil(t, record)
+	require.Equal(t, "kea-dhcp4.leases", record.Logger)
+	require.Equal(t, "DHCP4_LEASE_ALLOC", record.MessageID)
+
+	require.Nil(t, ParseLogLine("lorem ipsum"))
+	require.Nil(t, ParseLogLine(""))
+	require.Nil(t, ParseLogLine("2020-13-12 10:11:12.123 INFO  [kea-dhcp4.dhcp4] DHCP4_STARTED started"))
+}
+
+// Test that the Kea log lines are parsed into records and the continuation
+// lines are appended to the preceding records.
+func TestParseLogLines(t *testing.T) {
+	records := ParseLogLines(getTestLogLines())
+	require.Len(t, records, 6)
+	require.Equal(t, "DHCP4_STARTED", records[0].MessageID)
+	require.Equal(t, "COMMAND_PROCESS_ERROR1", records[5].MessageID)
+	require.Equal(t, "Error during command processing: unable to parse\n  continuation of the error", records[5].Text)
+
+	require.Empty(t, ParseLogLines([]string{"lorem ipsum"}))
+}
+
+// Test that the log records are filtered by severity, message ID prefix
+// and time range.
+func TestFilterLogRecords(t *testing.T) {
+	records := ParseLogLines(getTestLogLines())
+
+	filter := &LogRecordFilter{}
+	require.True(t, filter.IsEmpty())
+	require.Len(t, FilterLogRecords(records, filter), 6)
+
+	filter = &LogRecordFilter{
+		Severities: []string{"error", "FATAL"},
+	}
+	require.False(t, filter.IsEmpty())
+	filtered := FilterLogRecords(records, filter)
+	require.Len(t, filtered, 2)
+	require.Equal(t, "DHCPSRV_OPEN_SOCKET_FAIL", filtered[0].MessageID)
+	require.Equal(t, "COMMAND_PROCESS_ERROR1", filtered[1].MessageID)
+
+	filter = &LogRecordFilter{
+		MessageIDPrefix: "DHCP4_LEASE_ALLOC",
+	}
+	filtered = FilterLogRecords(records, filter)
+	require.Len(t, filtered, 2)
+
+	filter = &LogRecordFilter{
+		MessageIDPrefix: "DHCP4_LEASE",
+		From:            time.Date(2020, 10, 12, 10, 11, 15, 0, time.Local),
+		To:              time.Date(2020, 10, 12, 10, 11, 16, 0, time.Local),
+	}
+	filtered = FilterLogRecords(records, filter)
+	require.Len(t, filtered, 1)
+	require.Equal(t, "DHCP4_LEASE_ADVERT", filtered[0].MessageID)
+}
+
+// Test that the log records are selected by the wall-clock time regardless
+// of the zones of the server and the time range.
+func TestFilterLogRecordsTimeZones(t *testing.T) {
+	local := time.Local
+	defer func() {
+		time.Local = local
+	}()
+
+	for _, zone := range []string{"America/New_York", "Asia/Tokyo"} {
+		location, err := time.LoadLocation(zone)
+		require.NoError(t, err)
+		time.Local = location
+		records := ParseLogLines(getTestLogLines())
+
+		// The time range in the zone is compared with the logged times.
+		filter := &LogRecordFilter{
+			From: time.Date(2020, 10, 12, 10, 11, 15, 0, location),
+			To:   time.Date(2020, 10, 12, 10, 11, 16, 0, location),
+		}
+		filtered := FilterLogRecords(records, filter)
+		require.Len(t, filtered, 1, zone)
+		require.Equal(t, "DHCP4_LEASE_ADVERT", filtered[0].MessageID, zone)
+		// The zone of the server doesn't affect the parsed times.
+		require.Equal(t, time.Date(2020, 10, 12, 10, 11, 15, 2000000, time.UTC), filtered[0].Time, zone)
+	}
+}
+
+// Test that the regular expression sent to the agent selects the same
+// lines as the filter.
+func TestLogRecordFilterGetLineFilter(t *testing.T) {
+	filter := &LogRecordFilter{
+		From: time.Now(),
+	}
+	require.Empty(t, filter.GetLineFilter())
+
+	filter = &LogRecordFilter{
+		Severities:      []string{"info"},
+		MessageIDPrefix: "DHCP4_LEASE_AL",
+	}
+	re := regexp.MustCompile(filter.GetLineFilter())
+	var matched []string
+	for _, line := range getTestLogLines() {
+		if re.MatchString(line) {
+			matched = append(matched, line)
+		}
+	}
+	require.Len(t, matched, 2)
+	require.Contains(t, matched[0], "tid=0x1")
+	require.Contains(t, matched[1], "tid=0x2")
+
+	filter = &LogRecordFilter{
+		Severities: []string{"ERROR", "FATAL"},
+	}
+	re = regexp.MustCompile(filter.GetLineFilter())
+	require.True(t, re.MatchString(getTestLogLines()[2]))
+	require.False(t, re.MatchString(getTestLogLines()[3]))
+}
+
+// Test that the log records are counted per message ID.
+func TestCountLogRecords(t *testing.T) {
+	counts := CountLogRecords(ParseLogLines(getTestLogLines()))
+	require.Len(t, counts, 5)
+	require.Equal(t, "DHCP4_LEASE_ALLOC", counts[0].MessageID)
+	require.Equal(t, "INFO", counts[0].Severity)
+	require.EqualValues(t, 2, counts[0].Count)
+	for _, count := range counts[1:] {
+		require.EqualValues(t, 1, count.Count)
+	}
+	// The message IDs with the same count are ordered alphabetically.
+	require.Equal(t, "COMMAND_PROCESS_ERROR1", counts[1].MessageID)
+
+	require.Empty(t, CountLogRecords(nil))
+}
+
+// Test that the events are raised for the errors repeated since the
+// previous check.
+func TestLogErrorsCheckerCheckRecords(t *testing.T) {
+	machine := &dbmodel.Machine{
+		ID:      3,
+		Address: "192.0.2.1",
+	}
+	app := &dbmodel.App{
+		ID:      2,
+		Type:    dbmodel.AppTypeKea,
+		Machine: machine,
+	}
+	daemon := &dbmodel.Daemon{
+		ID:   1,
+		Name: "dhcp4",
+		App:  app,
+	}
+	target := &dbmodel.LogTarget{
+		ID:     4,
+		Output: "/var/log/kea-dhcp4.log",
+	}
+	line := "2020-10-12 10:11:%02d.000 ERROR [kea-dhcp4.dhcpsrv/1234.139] DHCPSRV_OPEN_SOCKET_FAIL failed to open socket"
+	var lines []string
+	for i := 0; i < 3; i++ {
+		lines = append(lines, fmt.Sprintf(line, i))
+	}
+
+	checker := NewLogErrorsChecker()
+	fec := &storktest.FakeEventCenter{}
+
+	// The first check only records the time of the last record.
+	require.False(t, checker.checkRecords(daemon, target, ParseLogLines(lines), 2, fec))
+	require.Empty(t, fec.Events)
+
+	// The same records are not counted again.
+	require.True(t, checker.checkRecords(daemon, target, ParseLogLines(lines), 2, fec))
+	require.Empty(t, fec.Events)
+
+	// One new error is below the threshold.
+	lines = append(lines, fmt.Sprintf(line, 3))
+	require.True(t, checker.checkRecords(daemon, target, ParseLogLines(lines), 2, fec))
+	require.Empty(t, fec.Events)
+
+	// Two new errors reach the threshold.
+	lines = append(lines, fmt.Sprintf(line, 4), fmt.Sprintf(line, 5))
+	require.True(t, checker.checkRecords(daemon, target, ParseLogLines(lines), 2, fec))
+	require.Len(t, fec.Events, 1)
+	require.Equal(t, dbmodel.EvError, fec.Events[0].Level)
+	require.Contains(t, fec.Events[0].Text, "logged DHCPSRV_OPEN_SOCKET_FAIL 2 times in /var/log/kea-dhcp4.log")
+	require.Equal(t, "DHCPSRV_OPEN_SOCKET_FAIL failed to open socket", fec.Events[0].Details)
+	require.EqualValues(t, 1, fec.Events[0].Relations.DaemonID)
+	require.EqualValues(t, 2, fec.Events[0].Relations.AppID)
+	require.EqualValues(t, 3, fec.Events[0].Relations.MachineID)
+}
+
+// Test that the records logged at the time of the last record of the
+// previous check are counted when they were not received before.
+func TestLogErrorsCheckerCheckRecordsSameTime(t *testing.T) {
+	app := &dbmodel.App{
+		ID:      2,
+		Type:    dbmodel.AppTypeKea,
+		Machine: &dbmodel.Machine{ID: 3},
+	}
+	daemon := &dbmodel.Daemon{
+		ID:  1,
+		App: app,
+	}
+	target := &dbmodel.LogTarget{
+		ID:     4,
+		Output: "/var/log/kea-dhcp4.log",
+	}
+	line := "2020-10-12 10:11:00.000 ERROR [kea-dhcp4.dhcpsrv/1234.139] DHCPSRV_OPEN_SOCKET_FAIL failed to open socket %d"
+	lines := []string{fmt.Sprintf(line, 0)}
+
+	checker := NewLogErrorsChecker()
+	fec := &storktest.FakeEventCenter{}
+	require.False(t, checker.checkRecords(daemon, target, ParseLogLines(lines), 2, fec))
+
+	// Two more records logged at the same time are new, the first one
+	// is not counted again.
+	lines = append(lines, fmt.Sprintf(line, 1), fmt.Sprintf(line, 2))
+	require.True(t, checker.checkRecords(daemon, target, ParseLogLines(lines), 2, fec))
+	require.Len(t, fec.Events, 1)
+	require.Contains(t, fec.Events[0].Text, "logged DHCPSRV_OPEN_SOCKET_FAIL 2 times")
+
+	// A repeated identical record logged at the same time is new too.
+	lines = append(lines, fmt.Sprintf(line, 2))
+	fec = &storktest.FakeEventCenter{}
+	require.True(t, checker.checkRecords(daemon, target, ParseLogLines(lines), 1, fec))
+	require.Len(t, fec.Events, 1)
+	require.Contains(t, fec.Events[0].Text, "logged DHCPSRV_OPEN_SOCKET_FAIL 1 times")
+
+	// Nothing new.
+	fec = &storktest.FakeEventCenter{}
+	require.True(t, checker.checkRecords(daemon, target, ParseLogLines(lines), 1, fec))
+	require.Empty(t, fec.Events)
+}
+
+// Test that the puller fetches the errors from the Kea log files of the
+// monitored daemons.
+func TestLogErrorsPullerCheckLogErrors(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	err := dbmodel.InitializeSettings(db)
+	require.NoError(t, err)
+
+	m := &dbmodel.Machine{
+		Address:   "localhost",
+		AgentPort: 8080,
+	}
+	err = dbmodel.AddMachine(db, m)
+	require.NoError(t, err)
+
+	a := &dbmodel.App{
+		MachineID: m.ID,
+		Type:      dbmodel.AppTypeKea,
+		Active:    true,
+		Daemons: []*dbmodel.Daemon{
+			{
+				Name:      "dhcp4",
+				Active:    true,
+				Monitored: true,
+				LogTargets: []*dbmodel.LogTarget{
+					{
+						Name:   "kea-dhcp4",
+						Output: "/var/log/kea-dhcp4.log",
+					},
+					{
+						Name:   "kea-dhcp4.leases",
+						Output: "stdout",
+					},
+				},
+			},
+		},
+	}
+	_, err = dbmodel.AddApp(db, a)
+	require.NoError(t, err)
+
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	fec := &storktest.FakeEventCenter{}
+	puller, err := NewLogErrorsPuller(db, fa, fec)
+	require.NoError(t, err)
+	defer puller.Shutdown()
+
+	line := "2020-10-12 10:11:%02d.000 ERROR [kea-dhcp4.dhcpsrv/1234.139] DHCPSRV_OPEN_SOCKET_FAIL failed to open socket"
+	fa.TextFileLines = []string{fmt.Sprintf(line, 0)}
+	_, err = puller.checkLogErrors()
+	require.NoError(t, err)
+	require.Empty(t, fec.Events)
+	require.NotEmpty(t, fa.RecordedFilter)
+
+	for i := 1; i <= 10; i++ {
+		fa.TextFileLines = append(fa.TextFileLines, fmt.Sprintf(line, i))
+	}
+	_, err = puller.checkLogErrors()
+	require.NoError(t, err)
+	require.Len(t, fec.Events, 1)
+	require.Contains(t, fec.Events[0].Text, "logged DHCPSRV_OPEN_SOCKET_FAIL 10 times")
+
+	// The check is disabled when the threshold is 0.
+	err = dbmodel.SetSettingInt(db, "kea_log_errors_threshold", 0)
+	require.NoError(t, err)
+	for i := 11; i <= 30; i++ {
+		fa.TextFileLines = append(fa.TextFileLines, fmt.Sprintf(line, i))
+	}
+	fec = &storktest.FakeEventCenter{}
+	puller.EventCenter = fec
+	_, err = puller.checkLogErrors()
+	require.NoError(t, err)
+	require.Empty(t, fec.Events)
+}
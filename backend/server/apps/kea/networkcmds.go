@@ -0,0 +1,352 @@
+package kea
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	keactrl "isc.org/stork/appctrl/kea"
+	"isc.org/stork/server/agentcomm"
+	dbops "isc.org/stork/server/database"
+	dbmodel "isc.org/stork/server/database/model"
+)
+
+// Shared network added to or deleted from the Kea daemons, or the subnet
+// moved in or out of the shared network, with the commands of the
+// subnet_cmds hooks library. The change is applied to all target daemons
+// or to none of them. The subnet is nil unless it is moved.
+type SharedNetworkChange struct {
+	Network *dbmodel.SharedNetwork
+	Subnet  *dbmodel.Subnet
+	Family  int
+	Targets []DaemonCmdsTarget
+}
+
+// Returns the name of the DHCP daemon of the given family.
+func getFamilyDaemonName(family int) string {
+	if family == 6 {
+		return dbmodel.DaemonNameDHCPv6
+	}
+	return dbmodel.DaemonNameDHCPv4
+}
+
+// Checks if the app has the DHCP daemon with the given name.
+func hasDaemon(app *dbmodel.App, daemonName string) bool {
+	for _, daemon := range app.Daemons {
+		if daemon.Name == daemonName {
+			return true
+		}
+	}
+	return false
+}
+
+// Validates the shared network and creates the change with the targets
+// being all apps from the list having the DHCP daemon of the network's
+// family. The name of the network must be unique among the existing
+// networks of the same family. The change is used to add the network to
+// the daemons or to delete it from them.
+func NewSharedNetworkChange(network *dbmodel.SharedNetwork, existingNetworks []dbmodel.SharedNetwork, apps []*dbmodel.App) (*SharedNetworkChange, error) {
+	network.Name = strings.TrimSpace(network.Name)
+	if len(network.Name) == 0 {
+		return nil, errors.New("shared network name must not be empty")
+	}
+	if network.Family != 4 && network.Family != 6 {
+		return nil, errors.Errorf("invalid family %d of the shared network %s", network.Family, network.Name)
+	}
+	for _, existing := range existingNetworks {
+		if existing.ID != network.ID && existing.Family == network.Family && existing.Name == network.Name {
+			return nil, errors.Errorf("shared network %s already exists", network.Name)
+		}
+	}
+	change := &SharedNetworkChange{
+		Network: network,
+		Family:  network.Family,
+	}
+	daemonName := getFamilyDaemonName(change.Family)
+	for _, app := range apps {
+		if hasDaemon(app, daemonName) {
+			change.Targets = append(change.Targets, DaemonCmdsTarget{
+				App:        app,
+				DaemonName: daemonName,
+			})
+		}
+	}
+	if len(change.Targets) == 0 && network.ID == 0 {
+		return nil, errors.Errorf("no DHCPv%d server has been selected for the shared network %s", change.Family, network.Name)
+	}
+	return change, nil
+}
+
+// Creates the change moving the subnet in or out of the shared network.
+// The targets are all apps from the list serving the subnet. The subnet
+// and the network must be of the same family.
+func NewSharedNetworkSubnetChange(network *dbmodel.SharedNetwork, subnet *dbmodel.Subnet, apps []*dbmodel.App) (*SharedNetworkChange, error) {
+	if subnet.GetFamily() != network.Family {
+		return nil, errors.Errorf("IPv%d subnet %s cannot belong to the IPv%d shared network %s",
+			subnet.GetFamily(), subnet.Prefix, network.Family, network.Name)
+	}
+	change := &SharedNetworkChange{
+		Network: network,
+		Subnet:  subnet,
+		Family:  network.Family,
+	}
+	daemonName := getFamilyDaemonName(change.Family)
+	for _, ls := range subnet.LocalSubnets {
+		for _, app := range apps {
+			if app.ID == ls.AppID && hasDaemon(app, daemonName) {
+				change.Targets = append(change.Targets, DaemonCmdsTarget{
+					App:           app,
+					DaemonName:    daemonName,
+					LocalSubnetID: ls.LocalSubnetID,
+				})
+				break
+			}
+		}
+	}
+	if len(change.Targets) == 0 {
+		return nil, errors.Errorf("subnet %s is not served by any DHCPv%d server", subnet.Prefix, change.Family)
+	}
+	return change, nil
+}
+
+// Returns the prefix of the commands, i.e. network4 or network6.
+func (change *SharedNetworkChange) networkKey() string {
+	return fmt.Sprintf("network%d", change.Family)
+}
+
+// Returns the name of the list holding the subnets within the shared
+// network, i.e. subnet4 or subnet6.
+func (change *SharedNetworkChange) subnetKey() string {
+	return fmt.Sprintf("subnet%d", change.Family)
+}
+
+// Sends the network4-add or network6-add command with the network to the
+// target. The network is added without subnets.
+func (change *SharedNetworkChange) sendAdd(ctx context.Context, agents agentcomm.ConnectedAgents, target DaemonCmdsTarget, keaNetwork map[string]interface{}) DaemonCmdsResult {
+	arguments := map[string]interface{}{
+		"shared-networks": []interface{}{keaNetwork},
+	}
+	return sendDaemonCommand(ctx, agents, target, change.networkKey()+"-add", arguments)
+}
+
+// Sends the network4-del or network6-del command to the target. The
+// subnets of the network are kept in the daemon's configuration as the
+// top level subnets.
+func (change *SharedNetworkChange) sendDel(ctx context.Context, agents agentcomm.ConnectedAgents, target DaemonCmdsTarget) DaemonCmdsResult {
+	arguments := map[string]interface{}{
+		"name":           change.Network.Name,
+		"subnets-action": "keep",
+	}
+	return sendDaemonCommand(ctx, agents, target, change.networkKey()+"-del", arguments)
+}
+
+// Sends the network4-subnet-add or network6-subnet-add command moving the
+// subnet with the given local ID to the network in the target.
+func (change *SharedNetworkChange) sendSubnetAdd(ctx context.Context, agents agentcomm.ConnectedAgents, target DaemonCmdsTarget, localSubnetID int64) DaemonCmdsResult {
+	arguments := map[string]interface{}{
+		"name": change.Network.Name,
+		"id":   localSubnetID,
+	}
+	return sendDaemonCommand(ctx, agents, target, change.networkKey()+"-subnet-add", arguments)
+}
+
+// Sends the network4-subnet-del or network6-subnet-del command moving the
+// subnet with the given local ID out of the network in the target.
+func (change *SharedNetworkChange) sendSubnetDel(ctx context.Context, agents agentcomm.ConnectedAgents, target DaemonCmdsTarget, localSubnetID int64) DaemonCmdsResult {
+	arguments := map[string]interface{}{
+		"name": change.Network.Name,
+		"id":   localSubnetID,
+	}
+	return sendDaemonCommand(ctx, agents, target, change.networkKey()+"-subnet-del", arguments)
+}
+
+// Fetches the network from the target with the network4-get or network6-get
+// command. The network is nil if the command fails or the target lacks the
+// network.
+func (change *SharedNetworkChange) get(ctx context.Context, agents agentcomm.ConnectedAgents, target DaemonCmdsTarget) (map[string]interface{}, DaemonCmdsResult) {
+	arguments := map[string]interface{}{
+		"name": change.Network.Name,
+	}
+	result := sendDaemonCommand(ctx, agents, target, change.networkKey()+"-get", arguments)
+	if result.Result != keactrl.ResponseSuccess || result.arguments == nil {
+		return nil, result
+	}
+	var keaNetwork map[string]interface{}
+	if list, ok := (*result.arguments)["shared-networks"].([]interface{}); ok && len(list) > 0 {
+		keaNetwork, _ = list[0].(map[string]interface{})
+	}
+	if keaNetwork == nil {
+		result.Result = keactrl.ResponseError
+		result.Text = fmt.Sprintf("shared network %s not returned", change.Network.Name)
+	}
+	return keaNetwork, result
+}
+
+// Returns the local IDs of the subnets belonging to the network fetched
+// from Kea.
+func (change *SharedNetworkChange) getLocalSubnetIDs(keaNetwork map[string]interface{}) []int64 {
+	var ids []int64
+	if list, ok := keaNetwork[change.subnetKey()].([]interface{}); ok {
+		for _, s := range list {
+			if subnet, ok := s.(map[string]interface{}); ok {
+				if id, ok := subnet["id"].(float64); ok {
+					ids = append(ids, int64(id))
+				}
+			}
+		}
+	}
+	return ids
+}
+
+// Returns the network fetched from Kea without its subnets, in the format
+// accepted by the network4-add and network6-add commands.
+func (change *SharedNetworkChange) toKeaWithoutSubnets(original map[string]interface{}) map[string]interface{} {
+	keaNetwork := map[string]interface{}{}
+	for key, value := range original {
+		keaNetwork[key] = value
+	}
+	keaNetwork[change.subnetKey()] = []interface{}{}
+	return keaNetwork
+}
+
+// Adds the shared network without subnets to all target daemons with the
+// network4-add or network6-add command. If any daemon rejects the network,
+// it is deleted from the daemons which accepted it. Otherwise, the network
+// is stored in the database. It returns a boolean value indicating whether
+// the network has been added and the outcomes of all commands sent. The
+// error is returned if the network could not be stored.
+func AddSharedNetworkToDaemons(ctx context.Context, db *dbops.PgDB, agents agentcomm.ConnectedAgents, change *SharedNetworkChange) (bool, []DaemonCmdsResult, error) {
+	var results []DaemonCmdsResult
+	for _, target := range change.Targets {
+		keaNetwork := map[string]interface{}{
+			"name":             change.Network.Name,
+			change.subnetKey(): []interface{}{},
+		}
+		results = append(results, change.sendAdd(ctx, agents, target, keaNetwork))
+	}
+	if !allSucceeded(results) {
+		for i := range change.Targets {
+			if results[i].Succeeded() {
+				results = append(results, change.sendDel(ctx, agents, change.Targets[i]))
+			}
+		}
+		return false, results, nil
+	}
+
+	change.Network.ID = 0
+	change.Network.Subnets = nil
+	err := dbmodel.AddSharedNetwork(db, change.Network)
+	return true, results, err
+}
+
+// Moves the subnet to the shared network in all daemons serving the subnet
+// with the network4-subnet-add or network6-subnet-add command. The network
+// must exist in these daemons. If any daemon rejects the command, the
+// subnet is moved back out of the network in the daemons which accepted
+// it. Otherwise, the subnet is associated with the network in the
+// database. It returns a boolean value indicating whether the subnet has
+// been moved and the outcomes of all commands sent. The error is returned
+// if the database could not be updated.
+func AddSubnetToSharedNetworkInDaemons(ctx context.Context, db *dbops.PgDB, agents agentcomm.ConnectedAgents, change *SharedNetworkChange) (bool, []DaemonCmdsResult, error) {
+	var results []DaemonCmdsResult
+	for _, target := range change.Targets {
+		results = append(results, change.sendSubnetAdd(ctx, agents, target, target.LocalSubnetID))
+	}
+	if !allSucceeded(results) {
+		for i, target := range change.Targets {
+			if results[i].Succeeded() {
+				results = append(results, change.sendSubnetDel(ctx, agents, target, target.LocalSubnetID))
+			}
+		}
+		return false, results, nil
+	}
+
+	err := dbmodel.SetSubnetSharedNetwork(db, change.Subnet.ID, change.Network.ID)
+	return true, results, err
+}
+
+// Moves the subnet out of the shared network in all daemons serving the
+// subnet with the network4-subnet-del or network6-subnet-del command. The
+// subnet becomes the top level subnet. If any daemon rejects the command,
+// the subnet is moved back to the network in the daemons which accepted
+// it. Otherwise, the subnet is dissociated from the network in the
+// database. It returns a boolean value indicating whether the subnet has
+// been moved and the outcomes of all commands sent. The error is returned
+// if the database could not be updated.
+func DeleteSubnetFromSharedNetworkInDaemons(ctx context.Context, db *dbops.PgDB, agents agentcomm.ConnectedAgents, change *SharedNetworkChange) (bool, []DaemonCmdsResult, error) {
+	var results []DaemonCmdsResult
+	for _, target := range change.Targets {
+		results = append(results, change.sendSubnetDel(ctx, agents, target, target.LocalSubnetID))
+	}
+	if !allSucceeded(results) {
+		for i, target := range change.Targets {
+			if results[i].Succeeded() {
+				results = append(results, change.sendSubnetAdd(ctx, agents, target, target.LocalSubnetID))
+			}
+		}
+		return false, results, nil
+	}
+
+	err := dbmodel.SetSubnetSharedNetwork(db, change.Subnet.ID, 0)
+	return true, results, err
+}
+
+// Deletes the shared network from all target daemons having it with the
+// network4-del or network6-del command. The subnets of the network are
+// kept as the top level subnets. The network is first fetched from the
+// targets to skip the ones lacking it and to restore it, along with its
+// subnets, if any daemon fails to delete it. Otherwise, the network is
+// deleted from the database and its subnets become the top level subnets.
+// It returns a boolean value indicating whether the network has been
+// deleted and the outcomes of all commands sent. The error is returned if
+// the network could not be deleted from the database.
+func DeleteSharedNetworkFromDaemons(ctx context.Context, db *dbops.PgDB, agents agentcomm.ConnectedAgents, change *SharedNetworkChange) (bool, []DaemonCmdsResult, error) {
+	var (
+		results   []DaemonCmdsResult
+		targets   []DaemonCmdsTarget
+		originals []map[string]interface{}
+	)
+	failed := false
+	for _, target := range change.Targets {
+		keaNetwork, result := change.get(ctx, agents, target)
+		results = append(results, result)
+		switch {
+		case keaNetwork != nil:
+			targets = append(targets, target)
+			originals = append(originals, keaNetwork)
+		case result.Result != keactrl.ResponseEmpty:
+			failed = true
+		}
+	}
+	if failed {
+		return false, results, nil
+	}
+
+	var delResults []DaemonCmdsResult
+	for _, target := range targets {
+		delResults = append(delResults, change.sendDel(ctx, agents, target))
+	}
+	results = append(results, delResults...)
+	if !allSucceeded(delResults) {
+		for i, target := range targets {
+			if !delResults[i].Succeeded() {
+				continue
+			}
+			result := change.sendAdd(ctx, agents, target, change.toKeaWithoutSubnets(originals[i]))
+			results = append(results, result)
+			if !result.Succeeded() {
+				continue
+			}
+			for _, localSubnetID := range change.getLocalSubnetIDs(originals[i]) {
+				results = append(results, change.sendSubnetAdd(ctx, agents, target, localSubnetID))
+			}
+		}
+		return false, results, nil
+	}
+
+	// The subnets are dissociated from the deleted network by the database.
+	err := dbmodel.DeleteSharedNetwork(db, change.Network.ID)
+	return true, results, err
+}
@@ -0,0 +1,252 @@
+package kea
+
+import (
+	"context"
+	"testing"
+
+	require "github.com/stretchr/testify/require"
+
+	agentcommtest "isc.org/stork/server/agentcomm/test"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Test that the shared network is validated and the targets are the apps
+// having the DHCP daemon of the network's family.
+func TestNewSharedNetworkChange(t *testing.T) {
+	apps := []*dbmodel.App{
+		getTestDaemonCmdsApp(1, dbmodel.DaemonNameDHCPv4),
+		getTestDaemonCmdsApp(2, dbmodel.DaemonNameDHCPv6),
+		getTestDaemonCmdsApp(3, dbmodel.DaemonNameDHCPv4),
+	}
+	existing := []dbmodel.SharedNetwork{
+		{ID: 1, Name: "foo", Family: 6},
+	}
+
+	change, err := NewSharedNetworkChange(&dbmodel.SharedNetwork{Name: " foo ", Family: 4}, existing, apps)
+	require.NoError(t, err)
+	require.Equal(t, "foo", change.Network.Name)
+	require.Len(t, change.Targets, 2)
+	require.EqualValues(t, 1, change.Targets[0].App.ID)
+	require.EqualValues(t, 3, change.Targets[1].App.ID)
+	require.Equal(t, dbmodel.DaemonNameDHCPv4, change.Targets[0].DaemonName)
+
+	_, err = NewSharedNetworkChange(&dbmodel.SharedNetwork{Name: "foo", Family: 6}, existing, apps)
+	require.Error(t, err)
+	_, err = NewSharedNetworkChange(&dbmodel.SharedNetwork{Name: "", Family: 4}, existing, apps)
+	require.Error(t, err)
+	_, err = NewSharedNetworkChange(&dbmodel.SharedNetwork{Name: "bar", Family: 5}, existing, apps)
+	require.Error(t, err)
+	_, err = NewSharedNetworkChange(&dbmodel.SharedNetwork{Name: "bar", Family: 6}, existing, apps[:1])
+	require.Error(t, err)
+}
+
+// Test that moving the subnet targets the daemons serving it and that
+// the families of the subnet and the network must match.
+func TestNewSharedNetworkSubnetChange(t *testing.T) {
+	apps := []*dbmodel.App{
+		getTestDaemonCmdsApp(1, dbmodel.DaemonNameDHCPv4),
+		getTestDaemonCmdsApp(2, dbmodel.DaemonNameDHCPv4),
+	}
+	network := &dbmodel.SharedNetwork{ID: 1, Name: "foo", Family: 4}
+
+	change, err := NewSharedNetworkSubnetChange(network, getTestHostCmdsSubnet("192.0.2.0/24", 2), apps)
+	require.NoError(t, err)
+	require.Len(t, change.Targets, 1)
+	require.EqualValues(t, 2, change.Targets[0].App.ID)
+	require.EqualValues(t, 20, change.Targets[0].LocalSubnetID)
+
+	_, err = NewSharedNetworkSubnetChange(network, getTestHostCmdsSubnet("2001:db8:1::/64", 1), apps)
+	require.Error(t, err)
+	_, err = NewSharedNetworkSubnetChange(network, getTestHostCmdsSubnet("192.0.2.0/24", 3), apps)
+	require.Error(t, err)
+}
+
+// Test that the network added to one of the daemons is deleted when the
+// other daemon rejects it.
+func TestAddSharedNetworkToDaemonsRolledBack(t *testing.T) {
+	apps := []*dbmodel.App{
+		getTestDaemonCmdsApp(1, dbmodel.DaemonNameDHCPv6),
+		getTestDaemonCmdsApp(2, dbmodel.DaemonNameDHCPv6),
+	}
+	change, err := NewSharedNetworkChange(&dbmodel.SharedNetwork{Name: "foo", Family: 6}, nil, apps)
+	require.NoError(t, err)
+
+	fa := agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 0, "text": "A new IPv6 shared network 'foo' added"}]`,
+		`[{"result": 1, "text": "unable to parse the command"}]`,
+		`[{"result": 0, "text": "IPv6 shared network 'foo' deleted"}]`,
+	), nil)
+	applied, results, err := AddSharedNetworkToDaemons(context.Background(), nil, fa, change)
+	require.NoError(t, err)
+	require.False(t, applied)
+	require.Len(t, results, 3)
+	require.Equal(t, "network6-add", results[0].Command)
+	require.Equal(t, "network6-del", results[2].Command)
+	require.EqualValues(t, 1, results[2].Target.App.ID)
+
+	keaNetworks := (*fa.RecordedCommands[0].Arguments)["shared-networks"].([]interface{})
+	require.Len(t, keaNetworks, 1)
+	require.Equal(t, "foo", keaNetworks[0].(map[string]interface{})["name"])
+	require.Empty(t, keaNetworks[0].(map[string]interface{})["subnet6"])
+	require.Equal(t, "keep", (*fa.RecordedCommands[2].Arguments)["subnets-action"])
+}
+
+// Test that the subnet moved to the network in one of the daemons is moved
+// back when the other daemon rejects the command.
+func TestAddSubnetToSharedNetworkInDaemonsRolledBack(t *testing.T) {
+	apps := []*dbmodel.App{
+		getTestDaemonCmdsApp(1, dbmodel.DaemonNameDHCPv4),
+		getTestDaemonCmdsApp(2, dbmodel.DaemonNameDHCPv4),
+	}
+	network := &dbmodel.SharedNetwork{ID: 1, Name: "foo", Family: 4}
+	change, err := NewSharedNetworkSubnetChange(network, getTestHostCmdsSubnet("192.0.2.0/24", 1, 2), apps)
+	require.NoError(t, err)
+
+	fa := agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 0, "text": "IPv4 subnet 10 added to shared network 'foo'"}]`,
+		`[{"result": 1, "text": "no shared network named 'foo'"}]`,
+		`[{"result": 0, "text": "IPv4 subnet 10 deleted from shared network 'foo'"}]`,
+	), nil)
+	applied, results, err := AddSubnetToSharedNetworkInDaemons(context.Background(), nil, fa, change)
+	require.NoError(t, err)
+	require.False(t, applied)
+	require.Len(t, results, 3)
+	require.Equal(t, "network4-subnet-add", results[0].Command)
+	require.Equal(t, "network4-subnet-del", results[2].Command)
+	require.EqualValues(t, 10, (*fa.RecordedCommands[0].Arguments)["id"])
+	require.Equal(t, "foo", (*fa.RecordedCommands[0].Arguments)["name"])
+	require.EqualValues(t, 20, (*fa.RecordedCommands[1].Arguments)["id"])
+	require.EqualValues(t, 10, (*fa.RecordedCommands[2].Arguments)["id"])
+}
+
+// Test that the network deleted from one of the daemons is restored along
+// with its subnets when the other daemon fails to delete it. The daemon
+// lacking the network is skipped.
+func TestDeleteSharedNetworkFromDaemonsRolledBack(t *testing.T) {
+	apps := []*dbmodel.App{
+		getTestDaemonCmdsApp(1, dbmodel.DaemonNameDHCPv4),
+		getTestDaemonCmdsApp(2, dbmodel.DaemonNameDHCPv4),
+		getTestDaemonCmdsApp(3, dbmodel.DaemonNameDHCPv4),
+	}
+	network := &dbmodel.SharedNetwork{ID: 1, Name: "foo", Family: 4}
+	change, err := NewSharedNetworkChange(network, nil, apps)
+	require.NoError(t, err)
+
+	fa := agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 0, "arguments": {"shared-networks": [{"name": "foo", "interface": "eth0", "subnet4": [{"id": 5, "subnet": "192.0.2.0/24"}]}]}}]`,
+		`[{"result": 3, "text": "no shared network named 'foo' found"}]`,
+		`[{"result": 0, "arguments": {"shared-networks": [{"name": "foo", "subnet4": []}]}}]`,
+		`[{"result": 0, "text": "IPv4 shared network 'foo' deleted"}]`,
+		`[{"result": 1, "text": "unable to delete the shared network"}]`,
+		`[{"result": 0, "text": "A new IPv4 shared network 'foo' added"}]`,
+		`[{"result": 0, "text": "IPv4 subnet 5 added to shared network 'foo'"}]`,
+	), nil)
+	applied, results, err := DeleteSharedNetworkFromDaemons(context.Background(), nil, fa, change)
+	require.NoError(t, err)
+	require.False(t, applied)
+	require.Len(t, results, 7)
+	require.Equal(t, "network4-get", results[0].Command)
+	require.Equal(t, "network4-del", results[3].Command)
+	require.EqualValues(t, 1, results[3].Target.App.ID)
+	require.EqualValues(t, 3, results[4].Target.App.ID)
+
+	require.Equal(t, "network4-add", fa.RecordedCommands[5].Command)
+	keaNetwork := (*fa.RecordedCommands[5].Arguments)["shared-networks"].([]interface{})[0].(map[string]interface{})
+	require.Equal(t, "eth0", keaNetwork["interface"])
+	require.Empty(t, keaNetwork["subnet4"])
+	require.Equal(t, "network4-subnet-add", fa.RecordedCommands[6].Command)
+	require.EqualValues(t, 5, (*fa.RecordedCommands[6].Arguments)["id"])
+}
+
+// Test adding the shared network, moving the subnet in and out of it and
+// deleting it.
+func TestSharedNetworkChangeInDaemons(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	m := &dbmodel.Machine{
+		Address:   "localhost",
+		AgentPort: 8080,
+	}
+	err := dbmodel.AddMachine(db, m)
+	require.NoError(t, err)
+	app := getTestSubnetCmdsApp(t, 0, dbmodel.DaemonNameDHCPv4, `{"Dhcp4": {"subnet4": [{"id": 3, "subnet": "192.0.2.0/24"}]}}`)
+	app.Machine = nil
+	app.MachineID = m.ID
+	_, err = dbmodel.AddApp(db, app)
+	require.NoError(t, err)
+	apps := []*dbmodel.App{app}
+
+	subnet := &dbmodel.Subnet{
+		Prefix: "192.0.2.0/24",
+	}
+	err = dbmodel.AddSubnet(db, subnet)
+	require.NoError(t, err)
+	err = dbmodel.AddAppToSubnet(db, subnet, app)
+	require.NoError(t, err)
+	subnet, err = dbmodel.GetSubnet(db, subnet.ID)
+	require.NoError(t, err)
+
+	// Add the network.
+	network := &dbmodel.SharedNetwork{Name: "foo", Family: 4}
+	change, err := NewSharedNetworkChange(network, nil, apps)
+	require.NoError(t, err)
+	fa := agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 0, "text": "A new IPv4 shared network 'foo' added"}]`,
+	), nil)
+	applied, _, err := AddSharedNetworkToDaemons(context.Background(), db, fa, change)
+	require.NoError(t, err)
+	require.True(t, applied)
+	require.NotZero(t, network.ID)
+
+	// Move the subnet to the network.
+	change, err = NewSharedNetworkSubnetChange(network, subnet, apps)
+	require.NoError(t, err)
+	fa = agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 0, "text": "IPv4 subnet 3 added to shared network 'foo'"}]`,
+	), nil)
+	applied, _, err = AddSubnetToSharedNetworkInDaemons(context.Background(), db, fa, change)
+	require.NoError(t, err)
+	require.True(t, applied)
+	require.EqualValues(t, 3, (*fa.RecordedCommands[0].Arguments)["id"])
+
+	returnedNetwork, err := dbmodel.GetSharedNetworkWithSubnets(db, network.ID)
+	require.NoError(t, err)
+	require.NotNil(t, returnedNetwork)
+	require.Len(t, returnedNetwork.Subnets, 1)
+
+	// Move the subnet out of the network.
+	fa = agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 0, "text": "IPv4 subnet 3 deleted from shared network 'foo'"}]`,
+	), nil)
+	applied, _, err = DeleteSubnetFromSharedNetworkInDaemons(context.Background(), db, fa, change)
+	require.NoError(t, err)
+	require.True(t, applied)
+
+	returnedNetwork, err = dbmodel.GetSharedNetworkWithSubnets(db, network.ID)
+	require.NoError(t, err)
+	require.NotNil(t, returnedNetwork)
+	require.Empty(t, returnedNetwork.Subnets)
+
+	// Move the subnet back and delete the network keeping the subnet.
+	err = dbmodel.SetSubnetSharedNetwork(db, subnet.ID, network.ID)
+	require.NoError(t, err)
+	change, err = NewSharedNetworkChange(network, nil, apps)
+	require.NoError(t, err)
+	fa = agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 0, "arguments": {"shared-networks": [{"name": "foo", "subnet4": [{"id": 3, "subnet": "192.0.2.0/24"}]}]}}]`,
+		`[{"result": 0, "text": "IPv4 shared network 'foo' deleted"}]`,
+	), nil)
+	applied, _, err = DeleteSharedNetworkFromDaemons(context.Background(), db, fa, change)
+	require.NoError(t, err)
+	require.True(t, applied)
+
+	returnedNetwork, err = dbmodel.GetSharedNetwork(db, network.ID)
+	require.NoError(t, err)
+	require.Nil(t, returnedNetwork)
+	returnedSubnet, err := dbmodel.GetSubnet(db, subnet.ID)
+	require.NoError(t, err)
+	require.NotNil(t, returnedSubnet)
+	require.Zero(t, returnedSubnet.SharedNetworkID)
+}
@@ -1,6 +1,7 @@
 package kea
 
 import (
+	"sync"
 	"time"
 
 	"github.com/go-pg/pg/v9"
@@ -17,6 +18,7 @@ type RpsWorker struct {
 	PreviousRps map[int64]StatSample // map of last known values per Daemon
 	Interval1   time.Duration
 	Interval2   time.Duration
+	mutex       *sync.Mutex // protects PreviousRps when the apps are pulled concurrently
 }
 
 // Represents a time/value pair.
@@ -78,6 +80,7 @@ func NewRpsWorker(db *pg.DB) (*RpsWorker, error) {
 
 	rpsWorker.db = db
 	rpsWorker.PreviousRps = map[int64]StatSample{}
+	rpsWorker.mutex = new(sync.Mutex)
 
 	// The interval values may some day be configurable
 	rpsWorker.Interval1 = (time.Minute * 15)
@@ -235,8 +238,14 @@ func (rpsWorker *RpsWorker) updateDaemonRpsIntervals(daemon *dbmodel.Daemon, sam
 		value = int64(0)
 	}
 
+	// Always update the last reported values for the Daemon.
+	rpsWorker.mutex.Lock()
+	previous, exist := rpsWorker.PreviousRps[daemonID]
+	rpsWorker.PreviousRps[daemonID] = StatSample{sampledAt, value}
+	rpsWorker.mutex.Unlock()
+
 	// If we have a previous recording, calculate a delta row for it
-	if previous, exist := rpsWorker.PreviousRps[daemonID]; exist {
+	if exist {
 		// Make a new interval
 		interval := &dbmodel.RpsInterval{}
 		interval.KeaDaemonID = daemonID
@@ -258,9 +267,6 @@ func (rpsWorker *RpsWorker) updateDaemonRpsIntervals(daemon *dbmodel.Daemon, sam
 		err = dbmodel.AddRpsInterval(rpsWorker.db, interval)
 	}
 
-	// Always update the last reported values for the Daemon.
-	rpsWorker.PreviousRps[daemonID] = StatSample{sampledAt, value}
-
 	return err
 }
 
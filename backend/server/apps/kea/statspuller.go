@@ -130,18 +130,7 @@ func (statsPuller *StatsPuller) pullStats() (int, error) {
 	}
 
 	// get lease stats from each kea app
-	var lastErr error
-	appsOkCnt := 0
-	for _, dbApp := range dbApps {
-		dbApp2 := dbApp
-		err := statsPuller.getStatsFromApp(&dbApp2)
-		if err != nil {
-			lastErr = err
-			log.Errorf("error occurred while getting stats from app %d: %+v", dbApp.ID, err)
-		} else {
-			appsOkCnt++
-		}
-	}
+	appsOkCnt, lastErr := statsPuller.PullApps(dbApps, statsPuller.getStatsFromApp)
 	log.Printf("completed pulling lease stats from Kea apps: %d/%d succeeded", appsOkCnt, len(dbApps))
 
 	// estimate addresses utilization for subnets
@@ -174,6 +163,7 @@ func (statsPuller *StatsPuller) pullStats() (int, error) {
 	netTotalPds := int64(0)
 	netAssignedPds := int64(0)
 	sharedNetworkID := subnets[0].SharedNetworkID
+	sampler := newUtilizationSampler(dbApps)
 	for _, sn := range subnets {
 		// We go through subnets which are sorted by shared network ID.
 		// When this ID changes it means that we completed scanning subnets of given
@@ -214,6 +204,15 @@ func (statsPuller *StatsPuller) pullStats() (int, error) {
 		// go through LocalSubnets and get max stats about assigned, total and declined addresses and pds
 		snAssigned, snTotal, snDeclined, snAssignedPds, snTotalPds, snMaxUsed, snMaxUsedPds := getStatsFromLocalSubnets(sn.LocalSubnets, family, assignedKeys, totalKeys, declinedKeys)
 
+		// record the counts for the utilization history
+		sampler.addSubnet(sn, &dbmodel.UtilizationSample{
+			AssignedAddresses: snAssigned,
+			TotalAddresses:    snTotal,
+			DeclinedAddresses: snDeclined,
+			AssignedPds:       snAssignedPds,
+			TotalPds:          snTotalPds,
+		}, family, assignedKeys, totalKeys, declinedKeys)
+
 		// add subnet counts to shared network ones and global stats
 		netTotal += snTotal
 		netAssigned += snAssigned
@@ -249,6 +248,13 @@ func (statsPuller *StatsPuller) pullStats() (int, error) {
 		lastErr = err
 	}
 
+	// store the utilization history
+	err = dbmodel.AddUtilizationSamples(statsPuller.DB, sampler.getSamples())
+	if err != nil {
+		lastErr = err
+		log.Errorf("cannot store utilization samples: %+v", err)
+	}
+
 	return appsOkCnt, lastErr
 }
 
@@ -337,7 +343,8 @@ func (statsPuller *StatsPuller) storeDaemonStats(response interface{}, subnetsMa
 	return lastErr
 }
 
-func (statsPuller *StatsPuller) getStatsFromApp(dbApp *dbmodel.App) error {
+// Get lease and RPS stats from given Kea app.
+func (statsPuller *StatsPuller) getStatsFromApp(ctx context.Context, dbApp *dbmodel.App) error {
 	// get active dhcp daemons
 	dhcpDaemons := make(keactrl.Daemons)
 	found := false
@@ -410,8 +417,6 @@ func (statsPuller *StatsPuller) getStatsFromApp(dbApp *dbmodel.App) error {
 	}
 
 	// forward commands to kea
-	ctx := context.Background()
-
 	cmdsResult, err := statsPuller.Agents.ForwardToKeaOverHTTP(ctx, dbApp, cmds, responses...)
 	if err != nil {
 		return err
@@ -3,8 +3,10 @@ package kea
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	keactrl "isc.org/stork/appctrl/kea"
 	"isc.org/stork/server/agentcomm"
@@ -104,6 +106,9 @@ func NewHAStatusPuller(db *dbops.PgDB, agents agentcomm.ConnectedAgents) (*HASta
 	if err != nil {
 		return nil, err
 	}
+	// The HA services are shared by the apps, so the apps are pulled one
+	// at a time to avoid conflicting updates.
+	periodicPuller.SetMaxWorkers(1)
 	puller.PeriodicPuller = periodicPuller
 	return puller, nil
 }
@@ -247,18 +252,24 @@ func (puller *HAStatusPuller) pullData() (int, error) {
 		return 0, err
 	}
 
-	var lastErr error
+	var mutex sync.Mutex
 	appsOkCnt := 0
 	appsCnt := 0
-	for i := range apps {
-		pulled, ok := puller.pullDataForApp(&apps[i])
+	_, lastErr := puller.PullApps(apps, func(ctx context.Context, app *dbmodel.App) error {
+		pulled, ok := puller.pullDataForApp(ctx, app)
+		mutex.Lock()
+		defer mutex.Unlock()
 		if pulled {
 			appsCnt++
 		}
 		if ok {
 			appsOkCnt++
 		}
-	}
+		if pulled && !ok {
+			return errors.Errorf("failed to pull the status of Kea app %d", app.ID)
+		}
+		return nil
+	})
 	log.Printf("completed pulling DHCP status from Kea apps: %d/%d succeeded", appsOkCnt, appsCnt)
 
 	return appsOkCnt, lastErr
@@ -267,7 +278,7 @@ func (puller *HAStatusPuller) pullData() (int, error) {
 // Gets the status of a Kea app and stores useful information in the database.
 // The High Availability status is stored in the database for those apps which
 // have the HA enabled.
-func (puller *HAStatusPuller) pullDataForApp(app *dbmodel.App) (bool, bool) {
+func (puller *HAStatusPuller) pullDataForApp(ctx context.Context, app *dbmodel.App) (bool, bool) {
 	// Before contacting the DHCP server, let's check if there is any service
 	// the app belongs to.
 	dbServices, err := dbmodel.GetDetailedServicesByAppID(puller.DB, app.ID)
@@ -304,7 +315,6 @@ func (puller *HAStatusPuller) pullDataForApp(app *dbmodel.App) (bool, bool) {
 		}
 	}
 
-	ctx := context.Background()
 	// Send the status-get command to both DHCPv4 and DHCPv6 servers.
 	appStatus, err := getDHCPStatus(ctx, puller.Agents, app)
 	if err != nil {
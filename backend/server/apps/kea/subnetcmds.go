@@ -0,0 +1,494 @@
+package kea
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"isc.org/stork/server/agentcomm"
+	dbops "isc.org/stork/server/database"
+	dbmodel "isc.org/stork/server/database/model"
+)
+
+// Subnet added, updated or deleted in the Kea daemons with the commands
+// of the subnet_cmds hooks library. The change is applied to all daemons
+// sharing the subnet, e.g. both servers of the HA pair, or to none of
+// them.
+type SubnetChange struct {
+	Subnet  *dbmodel.Subnet
+	Family  int
+	Targets []DaemonCmdsTarget
+}
+
+// Parses the subnet prefix or the pool prefix.
+func parseSubnetPrefix(prefix string) (*net.IPNet, error) {
+	_, ipNet, err := net.ParseCIDR(strings.TrimSpace(prefix))
+	if err != nil {
+		return nil, errors.Errorf("invalid prefix %s", prefix)
+	}
+	return ipNet, nil
+}
+
+// Checks if two prefixes overlap, i.e. one of them includes the other.
+func prefixesOverlap(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+// Checks if the subnet prefix is valid and the pools belong to the subnet
+// and don't overlap. The prefix pools are allowed in the IPv6 subnets
+// only. The subnet prefix is normalized.
+func validateSubnet(subnet *dbmodel.Subnet) error {
+	subnetNet, err := parseSubnetPrefix(subnet.Prefix)
+	if err != nil {
+		return err
+	}
+	if !subnetNet.IP.Equal(net.ParseIP(strings.Split(strings.TrimSpace(subnet.Prefix), "/")[0])) {
+		return errors.Errorf("subnet prefix %s has non-zero host bits", subnet.Prefix)
+	}
+	subnet.Prefix = subnetNet.String()
+	family := subnet.GetFamily()
+
+	type poolRange struct {
+		lower, upper net.IP
+		text         string
+	}
+	var ranges []poolRange
+	for i, pool := range subnet.AddressPools {
+		lower := net.ParseIP(strings.TrimSpace(pool.LowerBound))
+		upper := net.ParseIP(strings.TrimSpace(pool.UpperBound))
+		text := fmt.Sprintf("%s-%s", pool.LowerBound, pool.UpperBound)
+		if lower == nil || upper == nil {
+			return errors.Errorf("invalid address pool %s", text)
+		}
+		subnet.AddressPools[i].LowerBound = lower.String()
+		subnet.AddressPools[i].UpperBound = upper.String()
+		if !subnetNet.Contains(lower) || !subnetNet.Contains(upper) {
+			return errors.Errorf("address pool %s does not belong to the subnet %s", text, subnet.Prefix)
+		}
+		if bytes.Compare(lower.To16(), upper.To16()) > 0 {
+			return errors.Errorf("lower bound of the address pool %s is greater than the upper bound", text)
+		}
+		for _, r := range ranges {
+			if bytes.Compare(lower.To16(), r.upper.To16()) <= 0 && bytes.Compare(r.lower.To16(), upper.To16()) <= 0 {
+				return errors.Errorf("address pool %s overlaps with the address pool %s", text, r.text)
+			}
+		}
+		ranges = append(ranges, poolRange{lower, upper, text})
+	}
+
+	if len(subnet.PrefixPools) > 0 && family == 4 {
+		return errors.Errorf("prefix pools cannot be specified for the IPv4 subnet %s", subnet.Prefix)
+	}
+	var prefixes []*net.IPNet
+	for i, pool := range subnet.PrefixPools {
+		poolNet, err := parseSubnetPrefix(pool.Prefix)
+		if err != nil {
+			return err
+		}
+		subnet.PrefixPools[i].Prefix = poolNet.String()
+		if poolNet.IP.To4() != nil {
+			return errors.Errorf("prefix pool %s is not an IPv6 prefix", pool.Prefix)
+		}
+		ones, _ := poolNet.Mask.Size()
+		if pool.DelegatedLen < ones || pool.DelegatedLen > 128 {
+			return errors.Errorf("invalid delegated length %d in the prefix pool %s", pool.DelegatedLen, pool.Prefix)
+		}
+		for _, p := range prefixes {
+			if prefixesOverlap(poolNet, p) {
+				return errors.Errorf("prefix pool %s overlaps with the prefix pool %s", pool.Prefix, p)
+			}
+		}
+		prefixes = append(prefixes, poolNet)
+	}
+	return nil
+}
+
+// Checks if the subnet overlaps with any of the existing subnets. The
+// existing subnet having the same ID as the checked subnet is skipped.
+func checkSubnetOverlaps(subnet *dbmodel.Subnet, existingSubnets []dbmodel.Subnet) error {
+	subnetNet, err := parseSubnetPrefix(subnet.Prefix)
+	if err != nil {
+		return err
+	}
+	for _, existing := range existingSubnets {
+		if subnet.ID != 0 && existing.ID == subnet.ID {
+			continue
+		}
+		existingNet, err := parseSubnetPrefix(existing.Prefix)
+		if err != nil {
+			continue
+		}
+		if prefixesOverlap(subnetNet, existingNet) {
+			return errors.Errorf("subnet %s overlaps with the existing subnet %s", subnet.Prefix, existing.Prefix)
+		}
+	}
+	return nil
+}
+
+// Validates the subnet and creates the subnet change with the targets.
+// The existing subnets are used to check for the overlaps. The subnet
+// without ID is added to all apps from the list having the DHCP daemon
+// of the subnet's family. The new subnet gets the same ID in all the
+// daemons, greater than the IDs of their existing subnets. The subnet
+// with ID is updated or deleted in the apps sharing it, i.e. the apps
+// of its local subnets. The apps must include the access points and the
+// daemons with the configurations.
+func NewSubnetChange(subnet *dbmodel.Subnet, existingSubnets []dbmodel.Subnet, apps []*dbmodel.App) (*SubnetChange, error) {
+	if err := validateSubnet(subnet); err != nil {
+		return nil, err
+	}
+	if err := checkSubnetOverlaps(subnet, existingSubnets); err != nil {
+		return nil, err
+	}
+	change := &SubnetChange{
+		Subnet: subnet,
+		Family: subnet.GetFamily(),
+	}
+	daemonName := dbmodel.DaemonNameDHCPv4
+	if change.Family == 6 {
+		daemonName = dbmodel.DaemonNameDHCPv6
+	}
+
+	maxLocalSubnetID := int64(0)
+	for _, app := range apps {
+		target := DaemonCmdsTarget{
+			App:        app,
+			DaemonName: daemonName,
+		}
+		if subnet.ID != 0 {
+			found := false
+			for _, ls := range subnet.LocalSubnets {
+				if ls.AppID == app.ID {
+					target.LocalSubnetID = ls.LocalSubnetID
+					found = true
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+		for _, daemon := range app.Daemons {
+			if daemon.Name != daemonName {
+				continue
+			}
+			if daemon.KeaDaemon != nil && daemon.KeaDaemon.Config != nil {
+				if id := daemon.KeaDaemon.Config.GetMaxLocalSubnetID(); id > maxLocalSubnetID {
+					maxLocalSubnetID = id
+				}
+			}
+			change.Targets = append(change.Targets, target)
+			break
+		}
+	}
+	if len(change.Targets) == 0 {
+		return nil, errors.Errorf("no DHCPv%d server has been selected for the subnet %s", change.Family, subnet.Prefix)
+	}
+	if subnet.ID == 0 {
+		for i := range change.Targets {
+			change.Targets[i].LocalSubnetID = maxLocalSubnetID + 1
+		}
+	}
+	return change, nil
+}
+
+// Returns the name of the list holding the subnets in the subnet_cmds
+// commands and the prefix of the commands, i.e. subnet4 or subnet6.
+func (change *SubnetChange) subnetKey() string {
+	return fmt.Sprintf("subnet%d", change.Family)
+}
+
+// Returns the subnet in the format of the Kea subnet_cmds hooks library.
+// The parameters of the base subnet fetched from Kea which are not
+// managed by Stork, e.g. options or reservations, are preserved. The
+// pools which didn't change preserve their parameters as well.
+func (change *SubnetChange) toKea(localSubnetID int64, base map[string]interface{}) map[string]interface{} {
+	subnet := change.Subnet
+	keaSubnet := map[string]interface{}{}
+	for key, value := range base {
+		keaSubnet[key] = value
+	}
+	// The subnet_cmds don't accept the name of the shared network in the
+	// subnet returned by subnet4-get and subnet6-get.
+	delete(keaSubnet, "shared-network-name")
+	keaSubnet["id"] = localSubnetID
+	keaSubnet["subnet"] = subnet.Prefix
+	if len(subnet.ClientClass) > 0 {
+		keaSubnet["client-class"] = subnet.ClientClass
+	} else {
+		delete(keaSubnet, "client-class")
+	}
+
+	basePools := map[string]interface{}{}
+	if list, ok := base["pools"].([]interface{}); ok {
+		for _, p := range list {
+			if pool, ok := p.(map[string]interface{}); ok {
+				if text, ok := pool["pool"].(string); ok {
+					if dbPool, err := dbmodel.NewAddressPoolFromRange(text); err == nil {
+						basePools[dbPool.LowerBound+"-"+dbPool.UpperBound] = pool
+					}
+				}
+			}
+		}
+	}
+	pools := []interface{}{}
+	for _, pool := range subnet.AddressPools {
+		text := pool.LowerBound + "-" + pool.UpperBound
+		if basePool, ok := basePools[text]; ok {
+			pools = append(pools, basePool)
+			continue
+		}
+		pools = append(pools, map[string]interface{}{
+			"pool": text,
+		})
+	}
+	keaSubnet["pools"] = pools
+
+	if change.Family == 6 {
+		basePdPools := map[string]interface{}{}
+		if list, ok := base["pd-pools"].([]interface{}); ok {
+			for _, p := range list {
+				if pool, ok := p.(map[string]interface{}); ok {
+					prefix, _ := pool["prefix"].(string)
+					prefixLen, _ := pool["prefix-len"].(float64)
+					delegatedLen, _ := pool["delegated-len"].(float64)
+					if poolNet, err := parseSubnetPrefix(fmt.Sprintf("%s/%d", prefix, int(prefixLen))); err == nil {
+						basePdPools[fmt.Sprintf("%s:%d", poolNet, int(delegatedLen))] = pool
+					}
+				}
+			}
+		}
+		pdPools := []interface{}{}
+		for _, pool := range subnet.PrefixPools {
+			poolNet, err := parseSubnetPrefix(pool.Prefix)
+			if err != nil {
+				continue
+			}
+			if basePool, ok := basePdPools[fmt.Sprintf("%s:%d", poolNet, pool.DelegatedLen)]; ok {
+				pdPools = append(pdPools, basePool)
+				continue
+			}
+			ones, _ := poolNet.Mask.Size()
+			pdPools = append(pdPools, map[string]interface{}{
+				"prefix":        poolNet.IP.String(),
+				"prefix-len":    ones,
+				"delegated-len": pool.DelegatedLen,
+			})
+		}
+		keaSubnet["pd-pools"] = pdPools
+	}
+	return keaSubnet
+}
+
+// Sends the subnet4-add or subnet6-add command with the subnet to the target.
+func (change *SubnetChange) sendAdd(ctx context.Context, agents agentcomm.ConnectedAgents, target DaemonCmdsTarget, keaSubnet map[string]interface{}) DaemonCmdsResult {
+	arguments := map[string]interface{}{
+		change.subnetKey(): []interface{}{keaSubnet},
+	}
+	return sendDaemonCommand(ctx, agents, target, change.subnetKey()+"-add", arguments)
+}
+
+// Sends the subnet4-update or subnet6-update command with the subnet to
+// the target.
+func (change *SubnetChange) sendUpdate(ctx context.Context, agents agentcomm.ConnectedAgents, target DaemonCmdsTarget, keaSubnet map[string]interface{}) DaemonCmdsResult {
+	arguments := map[string]interface{}{
+		change.subnetKey(): []interface{}{keaSubnet},
+	}
+	return sendDaemonCommand(ctx, agents, target, change.subnetKey()+"-update", arguments)
+}
+
+// Sends the subnet4-del or subnet6-del command to the target.
+func (change *SubnetChange) sendDel(ctx context.Context, agents agentcomm.ConnectedAgents, target DaemonCmdsTarget) DaemonCmdsResult {
+	arguments := map[string]interface{}{
+		"id": target.LocalSubnetID,
+	}
+	return sendDaemonCommand(ctx, agents, target, change.subnetKey()+"-del", arguments)
+}
+
+// Fetches the subnets from all targets with the subnet4-get or subnet6-get
+// command. It returns the subnets in the order of the targets and the
+// outcomes of the commands. The subnets are nil if any command fails.
+func (change *SubnetChange) get(ctx context.Context, agents agentcomm.ConnectedAgents) (subnets []map[string]interface{}, results []DaemonCmdsResult) {
+	failed := false
+	for _, target := range change.Targets {
+		arguments := map[string]interface{}{
+			"id": target.LocalSubnetID,
+		}
+		result := sendDaemonCommand(ctx, agents, target, change.subnetKey()+"-get", arguments)
+		var keaSubnet map[string]interface{}
+		if result.Succeeded() && result.arguments != nil {
+			if list, ok := (*result.arguments)[change.subnetKey()].([]interface{}); ok && len(list) > 0 {
+				keaSubnet, _ = list[0].(map[string]interface{})
+			}
+		}
+		if keaSubnet == nil {
+			failed = true
+			if result.Succeeded() {
+				result.Text = fmt.Sprintf("subnet with id %d not returned", target.LocalSubnetID)
+			}
+		}
+		subnets = append(subnets, keaSubnet)
+		results = append(results, result)
+	}
+	if failed {
+		return nil, results
+	}
+	return subnets, results
+}
+
+// Checks if all commands succeeded.
+func allSucceeded(results []DaemonCmdsResult) bool {
+	for i := range results {
+		if !results[i].Succeeded() {
+			return false
+		}
+	}
+	return true
+}
+
+// Adds the subnet to all target daemons with the subnet4-add or subnet6-add
+// command. If any daemon rejects the subnet, it is deleted from the daemons
+// which accepted it. Otherwise, the subnet is stored in the database and
+// associated with the target apps. It returns a boolean value indicating
+// whether the subnet has been added and the outcomes of all commands sent.
+// The error is returned if the subnet could not be stored.
+func AddSubnetToDaemons(ctx context.Context, db *dbops.PgDB, agents agentcomm.ConnectedAgents, change *SubnetChange) (bool, []DaemonCmdsResult, error) {
+	var results []DaemonCmdsResult
+	for _, target := range change.Targets {
+		results = append(results, change.sendAdd(ctx, agents, target, change.toKea(target.LocalSubnetID, nil)))
+	}
+	if !allSucceeded(results) {
+		for i := range change.Targets {
+			if results[i].Succeeded() {
+				results = append(results, change.sendDel(ctx, agents, change.Targets[i]))
+			}
+		}
+		return false, results, nil
+	}
+
+	tx, rollback, commit, err := dbops.Transaction(db)
+	if err != nil {
+		return true, results, err
+	}
+	defer rollback()
+
+	change.Subnet.ID = 0
+	err = dbmodel.AddSubnet(tx, change.Subnet)
+	if err != nil {
+		return true, results, err
+	}
+	for _, target := range change.Targets {
+		err = dbmodel.AddAppToSubnetWithLocalID(tx, change.Subnet, target.App, target.LocalSubnetID)
+		if err != nil {
+			return true, results, err
+		}
+	}
+	err = commit()
+	if err != nil {
+		err = errors.WithMessagef(err, "problem with committing subnet %s added to Kea apps", change.Subnet.Prefix)
+	}
+	return true, results, err
+}
+
+// Updates the subnet in all target daemons with the subnet4-update or
+// subnet6-update command. The subnets are first fetched from the daemons
+// to preserve their parameters not managed by Stork. If any daemon rejects
+// the update, the original subnet is restored in the daemons which
+// accepted it, including its membership in the shared network. Otherwise, the subnet is updated in the database. It returns
+// a boolean value indicating whether the subnet has been updated and the
+// outcomes of all commands sent. The error is returned if the subnet could
+// not be updated in the database.
+func UpdateSubnetInDaemons(ctx context.Context, db *dbops.PgDB, agents agentcomm.ConnectedAgents, change *SubnetChange) (bool, []DaemonCmdsResult, error) {
+	originals, results := change.get(ctx, agents)
+	if originals == nil {
+		return false, results, nil
+	}
+	var updateResults []DaemonCmdsResult
+	for i, target := range change.Targets {
+		updateResults = append(updateResults, change.sendUpdate(ctx, agents, target, change.toKea(target.LocalSubnetID, originals[i])))
+	}
+	results = append(results, updateResults...)
+	if !allSucceeded(updateResults) {
+		for i := range change.Targets {
+			if updateResults[i].Succeeded() {
+				results = append(results, change.restore(ctx, agents, change.Targets[i], originals[i], change.sendUpdate)...)
+			}
+		}
+		return false, results, nil
+	}
+
+	err := dbmodel.UpdateSubnet(db, change.Subnet)
+	return true, results, err
+}
+
+// Deletes the subnet from all target daemons with the subnet4-del or
+// subnet6-del command. The subnets are first fetched from the daemons to
+// restore them, including their membership in the shared networks, if any
+// daemon fails to delete the subnet. Otherwise, the
+// subnet is deleted from the database. It returns a boolean value
+// indicating whether the subnet has been deleted and the outcomes of all
+// commands sent. The error is returned if the subnet could not be deleted
+// from the database.
+func DeleteSubnetFromDaemons(ctx context.Context, db *dbops.PgDB, agents agentcomm.ConnectedAgents, change *SubnetChange) (bool, []DaemonCmdsResult, error) {
+	originals, results := change.get(ctx, agents)
+	if originals == nil {
+		return false, results, nil
+	}
+	var delResults []DaemonCmdsResult
+	for _, target := range change.Targets {
+		delResults = append(delResults, change.sendDel(ctx, agents, target))
+	}
+	results = append(results, delResults...)
+	if !allSucceeded(delResults) {
+		for i := range change.Targets {
+			if delResults[i].Succeeded() {
+				results = append(results, change.restore(ctx, agents, change.Targets[i], originals[i], change.sendAdd)...)
+			}
+		}
+		return false, results, nil
+	}
+
+	// The subnet is deleted by the database trigger when it is no longer
+	// associated with any app.
+	for _, target := range change.Targets {
+		_, err := dbmodel.DeleteAppFromSubnet(db, change.Subnet.ID, target.App.ID)
+		if err != nil {
+			return true, results, err
+		}
+	}
+	return true, results, nil
+}
+
+// Returns the original subnet fetched from Kea in the format accepted by
+// the subnet4-add and subnet6-add commands.
+func (change *SubnetChange) toKeaOriginal(original map[string]interface{}) map[string]interface{} {
+	keaSubnet := map[string]interface{}{}
+	for key, value := range original {
+		keaSubnet[key] = value
+	}
+	delete(keaSubnet, "shared-network-name")
+	return keaSubnet
+}
+
+// Restores the original subnet fetched from Kea in the target using the
+// given function sending the subnet. The subnet_cmds don't accept the
+// name of the shared network, so the restored subnet is moved back to
+// its shared network with the network4-subnet-add or network6-subnet-add
+// command. It returns the outcomes of the commands sent.
+func (change *SubnetChange) restore(ctx context.Context, agents agentcomm.ConnectedAgents, target DaemonCmdsTarget, original map[string]interface{}, send func(context.Context, agentcomm.ConnectedAgents, DaemonCmdsTarget, map[string]interface{}) DaemonCmdsResult) []DaemonCmdsResult {
+	result := send(ctx, agents, target, change.toKeaOriginal(original))
+	results := []DaemonCmdsResult{result}
+	networkName, _ := original["shared-network-name"].(string)
+	if len(networkName) == 0 || !result.Succeeded() {
+		return results
+	}
+	arguments := map[string]interface{}{
+		"name": networkName,
+		"id":   target.LocalSubnetID,
+	}
+	return append(results, sendDaemonCommand(ctx, agents, target, fmt.Sprintf("network%d-subnet-add", change.Family), arguments))
+}
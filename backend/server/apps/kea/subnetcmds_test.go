@@ -0,0 +1,429 @@
+package kea
+
+import (
+	"context"
+	"testing"
+
+	require "github.com/stretchr/testify/require"
+
+	agentcommtest "isc.org/stork/server/agentcomm/test"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Returns the app with the DHCP daemon of the given name having the
+// configuration with the given subnets.
+func getTestSubnetCmdsApp(t *testing.T, id int64, daemonName, config string) *dbmodel.App {
+	app := getTestDaemonCmdsApp(id, daemonName)
+	err := app.Daemons[0].SetConfigFromJSON(config)
+	require.NoError(t, err)
+	return app
+}
+
+// Test that the subnet and its pools are validated.
+func TestValidateSubnet(t *testing.T) {
+	subnet := &dbmodel.Subnet{
+		Prefix: "2001:db8:1:0::/64",
+		AddressPools: []dbmodel.AddressPool{
+			{LowerBound: "2001:db8:1::1", UpperBound: "2001:db8:1::FF"},
+			{LowerBound: "2001:db8:1::100", UpperBound: "2001:db8:1::1ff"},
+		},
+		PrefixPools: []dbmodel.PrefixPool{
+			{Prefix: "3000::/48", DelegatedLen: 64},
+		},
+	}
+	require.NoError(t, validateSubnet(subnet))
+	require.Equal(t, "2001:db8:1::/64", subnet.Prefix)
+	require.Equal(t, "2001:db8:1::ff", subnet.AddressPools[0].UpperBound)
+
+	// Overlapping address pools.
+	subnet.AddressPools[1].LowerBound = "2001:db8:1::ff"
+	require.Error(t, validateSubnet(subnet))
+	subnet.AddressPools[1].LowerBound = "2001:db8:1::100"
+
+	// Address pool outside of the subnet.
+	subnet.AddressPools[1].UpperBound = "2001:db8:2::1"
+	require.Error(t, validateSubnet(subnet))
+	subnet.AddressPools[1].UpperBound = "2001:db8:1::1ff"
+
+	// Delegated length shorter than the prefix length.
+	subnet.PrefixPools[0].DelegatedLen = 32
+	require.Error(t, validateSubnet(subnet))
+
+	// Non-zero host bits.
+	subnet = &dbmodel.Subnet{Prefix: "192.0.2.1/24"}
+	require.Error(t, validateSubnet(subnet))
+
+	// Prefix pool in the IPv4 subnet.
+	subnet = &dbmodel.Subnet{
+		Prefix: "192.0.2.0/24",
+		PrefixPools: []dbmodel.PrefixPool{
+			{Prefix: "3000::/48", DelegatedLen: 64},
+		},
+	}
+	require.Error(t, validateSubnet(subnet))
+
+	// Lower bound greater than upper bound.
+	subnet = &dbmodel.Subnet{
+		Prefix: "192.0.2.0/24",
+		AddressPools: []dbmodel.AddressPool{
+			{LowerBound: "192.0.2.100", UpperBound: "192.0.2.10"},
+		},
+	}
+	require.Error(t, validateSubnet(subnet))
+}
+
+// Test that the subnet overlapping with the existing subnets is rejected.
+func TestNewSubnetChangeOverlaps(t *testing.T) {
+	apps := []*dbmodel.App{
+		getTestSubnetCmdsApp(t, 1, dbmodel.DaemonNameDHCPv4, `{"Dhcp4": {"subnet4": [{"id": 5, "subnet": "192.0.2.0/24"}]}}`),
+	}
+	existing := []dbmodel.Subnet{
+		{ID: 1, Prefix: "192.0.2.0/24"},
+		{ID: 2, Prefix: "10.0.0.0/8"},
+	}
+
+	_, err := NewSubnetChange(&dbmodel.Subnet{Prefix: "192.0.2.128/25"}, existing, apps)
+	require.Error(t, err)
+	_, err = NewSubnetChange(&dbmodel.Subnet{Prefix: "10.0.0.0/16"}, existing, apps)
+	require.Error(t, err)
+	_, err = NewSubnetChange(&dbmodel.Subnet{Prefix: "0.0.0.0/0"}, existing, apps)
+	require.Error(t, err)
+
+	change, err := NewSubnetChange(&dbmodel.Subnet{Prefix: "192.0.3.0/24"}, existing, apps)
+	require.NoError(t, err)
+	require.Len(t, change.Targets, 1)
+	require.EqualValues(t, 6, change.Targets[0].LocalSubnetID)
+
+	// The updated subnet doesn't overlap with itself.
+	subnet := &dbmodel.Subnet{
+		ID:     1,
+		Prefix: "192.0.2.0/23",
+		LocalSubnets: []*dbmodel.LocalSubnet{
+			{AppID: 1, LocalSubnetID: 5},
+		},
+	}
+	change, err = NewSubnetChange(subnet, existing, apps)
+	require.NoError(t, err)
+	require.Len(t, change.Targets, 1)
+	require.EqualValues(t, 5, change.Targets[0].LocalSubnetID)
+}
+
+// Test that the new subnet gets the same ID in all daemons and the daemons
+// of the other family are skipped.
+func TestNewSubnetChangeTargets(t *testing.T) {
+	apps := []*dbmodel.App{
+		getTestSubnetCmdsApp(t, 1, dbmodel.DaemonNameDHCPv6, `{"Dhcp6": {"subnet6": [{"id": 3, "subnet": "2001:db8:1::/64"}]}}`),
+		getTestSubnetCmdsApp(t, 2, dbmodel.DaemonNameDHCPv6, `{"Dhcp6": {"subnet6": [{"id": 9, "subnet": "2001:db8:3::/64"}]}}`),
+		getTestSubnetCmdsApp(t, 3, dbmodel.DaemonNameDHCPv4, `{"Dhcp4": {"subnet4": [{"id": 20, "subnet": "192.0.2.0/24"}]}}`),
+	}
+	change, err := NewSubnetChange(&dbmodel.Subnet{Prefix: "2001:db8:2::/64"}, nil, apps)
+	require.NoError(t, err)
+	require.Equal(t, 6, change.Family)
+	require.Len(t, change.Targets, 2)
+	require.EqualValues(t, 10, change.Targets[0].LocalSubnetID)
+	require.EqualValues(t, 10, change.Targets[1].LocalSubnetID)
+
+	_, err = NewSubnetChange(&dbmodel.Subnet{Prefix: "2001:db8:2::/64"}, nil, apps[2:])
+	require.Error(t, err)
+}
+
+// Test that the subnet is converted to the subnet_cmds format preserving
+// the parameters not managed by Stork.
+func TestSubnetChangeToKea(t *testing.T) {
+	change := &SubnetChange{
+		Subnet: &dbmodel.Subnet{
+			Prefix:      "2001:db8:1::/64",
+			ClientClass: "foo",
+			AddressPools: []dbmodel.AddressPool{
+				{LowerBound: "2001:db8:1::1", UpperBound: "2001:db8:1::ff"},
+				{LowerBound: "2001:db8:1::100", UpperBound: "2001:db8:1::1ff"},
+			},
+			PrefixPools: []dbmodel.PrefixPool{
+				{Prefix: "3000::/48", DelegatedLen: 64},
+			},
+		},
+		Family: 6,
+	}
+	base := map[string]interface{}{
+		"id":                  float64(7),
+		"subnet":              "2001:db8:1::/64",
+		"shared-network-name": "bar",
+		"valid-lifetime":      float64(3600),
+		"pools": []interface{}{
+			map[string]interface{}{
+				"pool":        "2001:db8:1::1 - 2001:db8:1::ff",
+				"option-data": []interface{}{},
+			},
+			map[string]interface{}{
+				"pool": "2001:db8:1::1000-2001:db8:1::10ff",
+			},
+		},
+		"pd-pools": []interface{}{
+			map[string]interface{}{
+				"prefix":        "3000::",
+				"prefix-len":    float64(48),
+				"delegated-len": float64(64),
+				"client-class":  "baz",
+			},
+		},
+	}
+	keaSubnet := change.toKea(7, base)
+	require.EqualValues(t, 7, keaSubnet["id"])
+	require.Equal(t, "foo", keaSubnet["client-class"])
+	require.EqualValues(t, 3600, keaSubnet["valid-lifetime"])
+	require.NotContains(t, keaSubnet, "shared-network-name")
+
+	pools := keaSubnet["pools"].([]interface{})
+	require.Len(t, pools, 2)
+	require.Contains(t, pools[0], "option-data")
+	require.Equal(t, "2001:db8:1::100-2001:db8:1::1ff", pools[1].(map[string]interface{})["pool"])
+
+	pdPools := keaSubnet["pd-pools"].([]interface{})
+	require.Len(t, pdPools, 1)
+	require.Equal(t, "baz", pdPools[0].(map[string]interface{})["client-class"])
+
+	// The base subnet is not modified.
+	require.Contains(t, base, "shared-network-name")
+}
+
+// Test that the subnet added to one of the daemons is deleted when the
+// other daemon rejects it.
+func TestAddSubnetToDaemonsRolledBack(t *testing.T) {
+	apps := []*dbmodel.App{
+		getTestSubnetCmdsApp(t, 1, dbmodel.DaemonNameDHCPv4, `{"Dhcp4": {}}`),
+		getTestSubnetCmdsApp(t, 2, dbmodel.DaemonNameDHCPv4, `{"Dhcp4": {}}`),
+	}
+	change, err := NewSubnetChange(&dbmodel.Subnet{Prefix: "192.0.2.0/24"}, nil, apps)
+	require.NoError(t, err)
+
+	fa := agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 0, "text": "IPv4 subnet added"}]`,
+		`[{"result": 1, "text": "subnet with the same id already exists"}]`,
+		`[{"result": 0, "text": "IPv4 subnet deleted"}]`,
+	), nil)
+
+	applied, results, err := AddSubnetToDaemons(context.Background(), nil, fa, change)
+	require.NoError(t, err)
+	require.False(t, applied)
+	require.Len(t, results, 3)
+	require.Equal(t, "subnet4-add", results[0].Command)
+	require.Equal(t, "subnet with the same id already exists", results[1].Text)
+	require.Equal(t, "subnet4-del", results[2].Command)
+	require.EqualValues(t, 1, results[2].Target.App.ID)
+
+	keaSubnets := (*fa.RecordedCommands[0].Arguments)["subnet4"].([]interface{})
+	require.Len(t, keaSubnets, 1)
+	require.EqualValues(t, 1, keaSubnets[0].(map[string]interface{})["id"])
+	require.Equal(t, "192.0.2.0/24", keaSubnets[0].(map[string]interface{})["subnet"])
+	require.EqualValues(t, 1, (*fa.RecordedCommands[2].Arguments)["id"])
+}
+
+// Test that the update is not sent when the subnet cannot be fetched from
+// any of the daemons.
+func TestUpdateSubnetInDaemonsGetFailed(t *testing.T) {
+	apps := []*dbmodel.App{
+		getTestSubnetCmdsApp(t, 1, dbmodel.DaemonNameDHCPv4, `{"Dhcp4": {}}`),
+		getTestSubnetCmdsApp(t, 2, dbmodel.DaemonNameDHCPv4, `{"Dhcp4": {}}`),
+	}
+	subnet := &dbmodel.Subnet{
+		ID:     1,
+		Prefix: "192.0.2.0/24",
+		LocalSubnets: []*dbmodel.LocalSubnet{
+			{AppID: 1, LocalSubnetID: 5},
+			{AppID: 2, LocalSubnetID: 5},
+		},
+	}
+	change, err := NewSubnetChange(subnet, nil, apps)
+	require.NoError(t, err)
+
+	fa := agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 0, "text": "Info about IPv4 subnet 5 returned", "arguments": {"subnet4": [{"id": 5, "subnet": "192.0.2.0/24"}]}}]`,
+		`[{"result": 3, "text": "No 5 subnet found"}]`,
+	), nil)
+	applied, results, err := UpdateSubnetInDaemons(context.Background(), nil, fa, change)
+	require.NoError(t, err)
+	require.False(t, applied)
+	require.Len(t, results, 2)
+	require.Len(t, fa.RecordedCommands, 2)
+	require.Equal(t, "subnet4-get", fa.RecordedCommands[1].Command)
+}
+
+// Test that the subnet updated in one of the daemons is restored in its
+// shared network when the other daemon rejects the update.
+func TestUpdateSubnetInDaemonsRestoreSharedNetwork(t *testing.T) {
+	apps := []*dbmodel.App{
+		getTestSubnetCmdsApp(t, 1, dbmodel.DaemonNameDHCPv4, `{"Dhcp4": {}}`),
+		getTestSubnetCmdsApp(t, 2, dbmodel.DaemonNameDHCPv4, `{"Dhcp4": {}}`),
+	}
+	subnet := &dbmodel.Subnet{
+		ID:     1,
+		Prefix: "192.0.2.0/24",
+		LocalSubnets: []*dbmodel.LocalSubnet{
+			{AppID: 1, LocalSubnetID: 5},
+			{AppID: 2, LocalSubnetID: 5},
+		},
+	}
+	change, err := NewSubnetChange(subnet, nil, apps)
+	require.NoError(t, err)
+
+	fa := agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 0, "arguments": {"subnet4": [{"id": 5, "subnet": "192.0.2.0/24", "shared-network-name": "frog"}]}}]`,
+		`[{"result": 0, "arguments": {"subnet4": [{"id": 5, "subnet": "192.0.2.0/24", "shared-network-name": "frog"}]}}]`,
+		`[{"result": 0, "text": "IPv4 subnet updated"}]`,
+		`[{"result": 1, "text": "unable to update subnet"}]`,
+		`[{"result": 0, "text": "IPv4 subnet updated"}]`,
+		`[{"result": 0, "text": "IPv4 subnet added to shared network"}]`,
+	), nil)
+	applied, results, err := UpdateSubnetInDaemons(context.Background(), nil, fa, change)
+	require.NoError(t, err)
+	require.False(t, applied)
+	require.Len(t, results, 6)
+	require.Equal(t, "subnet4-update", results[4].Command)
+	require.EqualValues(t, 1, results[4].Target.App.ID)
+	keaSubnet := (*fa.RecordedCommands[4].Arguments)["subnet4"].([]interface{})[0].(map[string]interface{})
+	require.NotContains(t, keaSubnet, "shared-network-name")
+	require.Equal(t, "network4-subnet-add", results[5].Command)
+	require.EqualValues(t, 1, results[5].Target.App.ID)
+	require.Equal(t, "frog", (*fa.RecordedCommands[5].Arguments)["name"])
+	require.EqualValues(t, 5, (*fa.RecordedCommands[5].Arguments)["id"])
+}
+
+// Test that the subnet deleted from one of the daemons is restored in its
+// shared network when the other daemon fails to delete it. The subnet
+// outside of the shared network is restored at the top level.
+func TestDeleteSubnetFromDaemonsRestoreSharedNetwork(t *testing.T) {
+	apps := []*dbmodel.App{
+		getTestSubnetCmdsApp(t, 1, dbmodel.DaemonNameDHCPv6, `{"Dhcp6": {}}`),
+		getTestSubnetCmdsApp(t, 2, dbmodel.DaemonNameDHCPv6, `{"Dhcp6": {}}`),
+		getTestSubnetCmdsApp(t, 3, dbmodel.DaemonNameDHCPv6, `{"Dhcp6": {}}`),
+	}
+	subnet := &dbmodel.Subnet{
+		ID:     1,
+		Prefix: "2001:db8:1::/64",
+		LocalSubnets: []*dbmodel.LocalSubnet{
+			{AppID: 1, LocalSubnetID: 7},
+			{AppID: 2, LocalSubnetID: 7},
+			{AppID: 3, LocalSubnetID: 7},
+		},
+	}
+	change, err := NewSubnetChange(subnet, nil, apps)
+	require.NoError(t, err)
+
+	fa := agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 0, "arguments": {"subnet6": [{"id": 7, "subnet": "2001:db8:1::/64", "shared-network-name": "mouse"}]}}]`,
+		`[{"result": 0, "arguments": {"subnet6": [{"id": 7, "subnet": "2001:db8:1::/64", "shared-network-name": null}]}}]`,
+		`[{"result": 0, "arguments": {"subnet6": [{"id": 7, "subnet": "2001:db8:1::/64", "shared-network-name": "mouse"}]}}]`,
+		`[{"result": 0, "text": "IPv6 subnet deleted"}]`,
+		`[{"result": 0, "text": "IPv6 subnet deleted"}]`,
+		`[{"result": 1, "text": "unable to delete subnet"}]`,
+		`[{"result": 0, "text": "IPv6 subnet added"}]`,
+		`[{"result": 0, "text": "IPv6 subnet added to shared network"}]`,
+		`[{"result": 0, "text": "IPv6 subnet added"}]`,
+	), nil)
+	applied, results, err := DeleteSubnetFromDaemons(context.Background(), nil, fa, change)
+	require.NoError(t, err)
+	require.False(t, applied)
+	require.Len(t, results, 9)
+	require.Equal(t, "subnet6-add", results[6].Command)
+	require.EqualValues(t, 1, results[6].Target.App.ID)
+	require.Equal(t, "network6-subnet-add", results[7].Command)
+	require.EqualValues(t, 1, results[7].Target.App.ID)
+	require.Equal(t, "mouse", (*fa.RecordedCommands[7].Arguments)["name"])
+	require.EqualValues(t, 7, (*fa.RecordedCommands[7].Arguments)["id"])
+	require.Equal(t, "subnet6-add", results[8].Command)
+	require.EqualValues(t, 2, results[8].Target.App.ID)
+}
+
+// Test adding, updating and deleting the subnet in two daemons.
+func TestSubnetChangeInDaemons(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	m := &dbmodel.Machine{
+		Address:   "localhost",
+		AgentPort: 8080,
+	}
+	err := dbmodel.AddMachine(db, m)
+	require.NoError(t, err)
+	var apps []*dbmodel.App
+	for i := 0; i < 2; i++ {
+		app := getTestSubnetCmdsApp(t, 0, dbmodel.DaemonNameDHCPv6, `{"Dhcp6": {"subnet6": [{"id": 3, "subnet": "2001:db8:1::/64"}]}}`)
+		app.AccessPoints[0].Port = int64(8000 + i)
+		app.Machine = nil
+		app.MachineID = m.ID
+		_, err = dbmodel.AddApp(db, app)
+		require.NoError(t, err)
+		apps = append(apps, app)
+	}
+
+	// Add the subnet.
+	subnet := &dbmodel.Subnet{
+		Prefix: "2001:db8:2::/64",
+		AddressPools: []dbmodel.AddressPool{
+			{LowerBound: "2001:db8:2::1", UpperBound: "2001:db8:2::ff"},
+		},
+	}
+	change, err := NewSubnetChange(subnet, nil, apps)
+	require.NoError(t, err)
+	fa := agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 0, "text": "IPv6 subnet added"}]`,
+		`[{"result": 0, "text": "IPv6 subnet added"}]`,
+	), nil)
+	applied, _, err := AddSubnetToDaemons(context.Background(), db, fa, change)
+	require.NoError(t, err)
+	require.True(t, applied)
+
+	returnedSubnet, err := dbmodel.GetSubnet(db, subnet.ID)
+	require.NoError(t, err)
+	require.NotNil(t, returnedSubnet)
+	require.Len(t, returnedSubnet.AddressPools, 1)
+	require.Len(t, returnedSubnet.LocalSubnets, 2)
+	require.EqualValues(t, 4, returnedSubnet.LocalSubnets[0].LocalSubnetID)
+	require.EqualValues(t, 4, returnedSubnet.LocalSubnets[1].LocalSubnetID)
+
+	// Update the subnet.
+	returnedSubnet.AddressPools = append(returnedSubnet.AddressPools, dbmodel.AddressPool{
+		LowerBound: "2001:db8:2::100",
+		UpperBound: "2001:db8:2::1ff",
+	})
+	existing, err := dbmodel.GetAllSubnets(db, 6)
+	require.NoError(t, err)
+	change, err = NewSubnetChange(returnedSubnet, existing, apps)
+	require.NoError(t, err)
+	fa = agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 0, "arguments": {"subnet6": [{"id": 4, "subnet": "2001:db8:2::/64", "preferred-lifetime": 100}]}}]`,
+		`[{"result": 0, "arguments": {"subnet6": [{"id": 4, "subnet": "2001:db8:2::/64"}]}}]`,
+		`[{"result": 0, "text": "IPv6 subnet updated"}]`,
+		`[{"result": 0, "text": "IPv6 subnet updated"}]`,
+	), nil)
+	applied, results, err := UpdateSubnetInDaemons(context.Background(), db, fa, change)
+	require.NoError(t, err)
+	require.True(t, applied)
+	require.Len(t, results, 4)
+	keaSubnet := (*fa.RecordedCommands[2].Arguments)["subnet6"].([]interface{})[0].(map[string]interface{})
+	require.EqualValues(t, 100, keaSubnet["preferred-lifetime"])
+	require.Len(t, keaSubnet["pools"], 2)
+
+	returnedSubnet, err = dbmodel.GetSubnet(db, subnet.ID)
+	require.NoError(t, err)
+	require.NotNil(t, returnedSubnet)
+	require.Len(t, returnedSubnet.AddressPools, 2)
+
+	// Delete the subnet.
+	change, err = NewSubnetChange(returnedSubnet, nil, apps)
+	require.NoError(t, err)
+	fa = agentcommtest.NewFakeAgents(mockConfigPushResponses(
+		`[{"result": 0, "arguments": {"subnet6": [{"id": 4, "subnet": "2001:db8:2::/64"}]}}]`,
+		`[{"result": 0, "arguments": {"subnet6": [{"id": 4, "subnet": "2001:db8:2::/64"}]}}]`,
+		`[{"result": 0, "text": "IPv6 subnet deleted"}]`,
+		`[{"result": 3, "text": "no subnet with id 4"}]`,
+	), nil)
+	applied, _, err = DeleteSubnetFromDaemons(context.Background(), db, fa, change)
+	require.NoError(t, err)
+	require.True(t, applied)
+
+	returnedSubnet, err = dbmodel.GetSubnet(db, subnet.ID)
+	require.NoError(t, err)
+	require.Nil(t, returnedSubnet)
+}
@@ -0,0 +1,142 @@
+package kea
+
+import (
+	"isc.org/stork/server/agentcomm"
+	dbops "isc.org/stork/server/database"
+	dbmodel "isc.org/stork/server/database/model"
+	storkutil "isc.org/stork/util"
+)
+
+// Collects the raw utilization samples of the subnets, shared networks
+// and daemons while the stats puller estimates the utilization of the
+// subnets. The counts of the subnet are the counts estimated by the stats
+// puller, i.e. taken from the most utilized local subnet, because the
+// servers may share the same pools. The counts of the shared network are
+// the sums of the counts of its subnets. The counts of the daemon are the
+// sums of the counts of its local subnets.
+type utilizationSampler struct {
+	samples []*dbmodel.UtilizationSample
+	// Samples of the shared networks by shared network ID.
+	networks map[int64]*dbmodel.UtilizationSample
+	// Samples of the daemons by daemon ID.
+	daemons map[int64]*dbmodel.UtilizationSample
+	// DHCPv4 and DHCPv6 daemon IDs by app ID.
+	daemonIDs map[int64]map[int]int64
+}
+
+// Creates the sampler for the given Kea apps.
+func newUtilizationSampler(apps []dbmodel.App) *utilizationSampler {
+	sampler := &utilizationSampler{
+		networks:  make(map[int64]*dbmodel.UtilizationSample),
+		daemons:   make(map[int64]*dbmodel.UtilizationSample),
+		daemonIDs: make(map[int64]map[int]int64),
+	}
+	for _, app := range apps {
+		sampler.daemonIDs[app.ID] = make(map[int]int64)
+		for _, daemon := range app.Daemons {
+			switch daemon.Name {
+			case dhcp4:
+				sampler.daemonIDs[app.ID][4] = daemon.ID
+			case dhcp6:
+				sampler.daemonIDs[app.ID][6] = daemon.ID
+			}
+		}
+	}
+	return sampler
+}
+
+// Adds the counts of the sample to the aggregated sample.
+func addUtilizationCounts(aggregate, sample *dbmodel.UtilizationSample) {
+	aggregate.AssignedAddresses += sample.AssignedAddresses
+	aggregate.TotalAddresses += sample.TotalAddresses
+	aggregate.DeclinedAddresses += sample.DeclinedAddresses
+	aggregate.AssignedPds += sample.AssignedPds
+	aggregate.TotalPds += sample.TotalPds
+}
+
+// Adds the sample of the subnet with the given counts. The counts are also
+// added to the sample of its shared network. The counts of the local subnets
+// are added to the samples of their daemons. The stats keys are the keys used
+// to get the counts from the stats of the local subnets.
+func (sampler *utilizationSampler) addSubnet(sn *dbmodel.Subnet, sample *dbmodel.UtilizationSample, family int, assignedKeys, totalKeys, declinedKeys []string) {
+	sample.Resolution = dbmodel.UtilizationResolutionRaw
+	sample.SubnetID = sn.ID
+	for _, lsn := range sn.LocalSubnets {
+		lsnSample := &dbmodel.UtilizationSample{}
+		lsnSample.AssignedAddresses, lsnSample.TotalAddresses, lsnSample.DeclinedAddresses,
+			lsnSample.AssignedPds, lsnSample.TotalPds, _, _ = getStatsFromLocalSubnets([]*dbmodel.LocalSubnet{lsn},
+			family, assignedKeys, totalKeys, declinedKeys)
+
+		daemonID, ok := sampler.daemonIDs[lsn.AppID][family]
+		if !ok {
+			continue
+		}
+		if _, ok := sampler.daemons[daemonID]; !ok {
+			sampler.daemons[daemonID] = &dbmodel.UtilizationSample{
+				Resolution: dbmodel.UtilizationResolutionRaw,
+				DaemonID:   daemonID,
+			}
+		}
+		addUtilizationCounts(sampler.daemons[daemonID], lsnSample)
+	}
+	sampler.samples = append(sampler.samples, sample)
+
+	if sn.SharedNetworkID != 0 {
+		if _, ok := sampler.networks[sn.SharedNetworkID]; !ok {
+			sampler.networks[sn.SharedNetworkID] = &dbmodel.UtilizationSample{
+				Resolution:      dbmodel.UtilizationResolutionRaw,
+				SharedNetworkID: sn.SharedNetworkID,
+			}
+		}
+		addUtilizationCounts(sampler.networks[sn.SharedNetworkID], sample)
+	}
+}
+
+// Returns all collected samples with the current time.
+func (sampler *utilizationSampler) getSamples() []*dbmodel.UtilizationSample {
+	samples := sampler.samples
+	for _, sample := range sampler.networks {
+		samples = append(samples, sample)
+	}
+	for _, sample := range sampler.daemons {
+		samples = append(samples, sample)
+	}
+	now := storkutil.UTCNow()
+	for _, sample := range samples {
+		sample.SampledAt = now
+	}
+	return samples
+}
+
+// Instance of the puller which periodically downsamples the utilization
+// samples and removes the samples older than their retention periods.
+type UtilizationRetentionPuller struct {
+	*agentcomm.PeriodicPuller
+}
+
+// Create an instance of the puller which periodically downsamples the
+// utilization samples.
+func NewUtilizationRetentionPuller(db *dbops.PgDB, agents agentcomm.ConnectedAgents) (*UtilizationRetentionPuller, error) {
+	puller := &UtilizationRetentionPuller{}
+	periodicPuller, err := agentcomm.NewPeriodicPuller(db, agents, "Utilization Retention",
+		"utilization_retention_puller_interval", puller.downsample)
+	if err != nil {
+		return nil, err
+	}
+	puller.PeriodicPuller = periodicPuller
+	return puller, nil
+}
+
+// Stops the timer triggering the downsampling.
+func (puller *UtilizationRetentionPuller) Shutdown() {
+	puller.PeriodicPuller.Shutdown()
+}
+
+// Downsamples the utilization samples and removes the old samples.
+func (puller *UtilizationRetentionPuller) downsample() (int, error) {
+	err := dbmodel.DownsampleUtilizationSamples(puller.DB, storkutil.UTCNow())
+	if err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
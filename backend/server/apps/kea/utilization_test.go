@@ -0,0 +1,127 @@
+package kea
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbmodel "isc.org/stork/server/database/model"
+)
+
+// Test that the sampler produces the samples of the subnets, shared
+// networks and daemons.
+func TestUtilizationSampler(t *testing.T) {
+	apps := []dbmodel.App{
+		{
+			ID: 1,
+			Daemons: []*dbmodel.Daemon{
+				{ID: 11, Name: dhcp4},
+				{ID: 12, Name: dhcp6},
+			},
+		},
+		{
+			ID: 2,
+			Daemons: []*dbmodel.Daemon{
+				{ID: 21, Name: dhcp4},
+			},
+		},
+	}
+	sampler := newUtilizationSampler(apps)
+
+	totalKeys := []string{"total-addresses"}
+	assignedKeys := []string{"assigned-addresses"}
+	declinedKeys := []string{"declined-addresses"}
+
+	// Subnet served by both apps.
+	sn1 := &dbmodel.Subnet{
+		ID:              1,
+		SharedNetworkID: 5,
+		LocalSubnets: []*dbmodel.LocalSubnet{
+			{
+				AppID: 1,
+				Stats: map[string]interface{}{
+					"total-addresses":    float64(100),
+					"assigned-addresses": float64(10),
+					"declined-addresses": float64(1),
+				},
+			},
+			{
+				AppID: 2,
+				Stats: map[string]interface{}{
+					"total-addresses":    float64(100),
+					"assigned-addresses": float64(20),
+					"declined-addresses": float64(2),
+				},
+			},
+		},
+	}
+	sampler.addSubnet(sn1, &dbmodel.UtilizationSample{
+		AssignedAddresses: 20,
+		TotalAddresses:    100,
+		DeclinedAddresses: 2,
+	}, 4, assignedKeys, totalKeys, declinedKeys)
+
+	// Subnet served by the first app.
+	sn2 := &dbmodel.Subnet{
+		ID:              2,
+		SharedNetworkID: 5,
+		LocalSubnets: []*dbmodel.LocalSubnet{
+			{
+				AppID: 1,
+				Stats: map[string]interface{}{
+					"total-addresses":    float64(50),
+					"assigned-addresses": float64(5),
+					"declined-addresses": float64(0),
+				},
+			},
+		},
+	}
+	sampler.addSubnet(sn2, &dbmodel.UtilizationSample{
+		AssignedAddresses: 5,
+		TotalAddresses:    50,
+	}, 4, assignedKeys, totalKeys, declinedKeys)
+
+	samples := sampler.getSamples()
+	require.Len(t, samples, 5)
+
+	var subnets, networks, daemons []*dbmodel.UtilizationSample
+	for _, s := range samples {
+		require.Equal(t, dbmodel.UtilizationResolutionRaw, s.Resolution)
+		require.Equal(t, samples[0].SampledAt, s.SampledAt)
+		switch {
+		case s.SubnetID != 0:
+			subnets = append(subnets, s)
+		case s.SharedNetworkID != 0:
+			networks = append(networks, s)
+		case s.DaemonID != 0:
+			daemons = append(daemons, s)
+		}
+	}
+	require.Len(t, subnets, 2)
+	require.EqualValues(t, 1, subnets[0].SubnetID)
+	require.EqualValues(t, 20, subnets[0].AssignedAddresses)
+	require.EqualValues(t, 2, subnets[1].SubnetID)
+
+	// The shared network counts are the sums of its subnets.
+	require.Len(t, networks, 1)
+	require.EqualValues(t, 5, networks[0].SharedNetworkID)
+	require.EqualValues(t, 25, networks[0].AssignedAddresses)
+	require.EqualValues(t, 150, networks[0].TotalAddresses)
+	require.EqualValues(t, 2, networks[0].DeclinedAddresses)
+
+	// The daemon counts are the sums of its local subnets.
+	require.Len(t, daemons, 2)
+	for _, d := range daemons {
+		switch d.DaemonID {
+		case 11:
+			require.EqualValues(t, 15, d.AssignedAddresses)
+			require.EqualValues(t, 150, d.TotalAddresses)
+			require.EqualValues(t, 1, d.DeclinedAddresses)
+		case 21:
+			require.EqualValues(t, 20, d.AssignedAddresses)
+			require.EqualValues(t, 100, d.TotalAddresses)
+			require.EqualValues(t, 2, d.DeclinedAddresses)
+		default:
+			require.Fail(t, "unexpected daemon %d", d.DaemonID)
+		}
+	}
+}
@@ -1,15 +1,63 @@
 package apps
 
 import (
+	"isc.org/stork/server/agentcomm"
 	"isc.org/stork/server/apps/bind9"
 	"isc.org/stork/server/apps/kea"
 )
 
 // Collection of pullers used by the server.
 type Pullers struct {
-	AppsStatePuller  *StatePuller
-	Bind9StatsPuller *bind9.StatsPuller
-	KeaStatsPuller   *kea.StatsPuller
-	KeaHostsPuller   *kea.HostsPuller
-	HAStatusPuller   *kea.HAStatusPuller
+	AppsStatePuller            *StatePuller
+	Bind9StatsPuller           *bind9.StatsPuller
+	KeaStatsPuller             *kea.StatsPuller
+	KeaHostsPuller             *kea.HostsPuller
+	HAStatusPuller             *kea.HAStatusPuller
+	UtilizationRetentionPuller *kea.UtilizationRetentionPuller
+	ExhaustionForecastPuller   *kea.ExhaustionForecastPuller
+	LogErrorsPuller            *kea.LogErrorsPuller
+	EventRetentionPuller       *EventRetentionPuller
+}
+
+// Returns the periodic pullers which have been created.
+func (pullers *Pullers) GetAll() (all []*agentcomm.PeriodicPuller) {
+	if pullers.AppsStatePuller != nil {
+		all = append(all, pullers.AppsStatePuller.PeriodicPuller)
+	}
+	if pullers.Bind9StatsPuller != nil {
+		all = append(all, pullers.Bind9StatsPuller.PeriodicPuller)
+	}
+	if pullers.KeaStatsPuller != nil {
+		all = append(all, pullers.KeaStatsPuller.PeriodicPuller)
+	}
+	if pullers.KeaHostsPuller != nil {
+		all = append(all, pullers.KeaHostsPuller.PeriodicPuller)
+	}
+	if pullers.HAStatusPuller != nil {
+		all = append(all, pullers.HAStatusPuller.PeriodicPuller)
+	}
+	if pullers.UtilizationRetentionPuller != nil {
+		all = append(all, pullers.UtilizationRetentionPuller.PeriodicPuller)
+	}
+	if pullers.ExhaustionForecastPuller != nil {
+		all = append(all, pullers.ExhaustionForecastPuller.PeriodicPuller)
+	}
+	if pullers.LogErrorsPuller != nil {
+		all = append(all, pullers.LogErrorsPuller.PeriodicPuller)
+	}
+	if pullers.EventRetentionPuller != nil {
+		all = append(all, pullers.EventRetentionPuller.PeriodicPuller)
+	}
+	return all
+}
+
+// Returns the periodic puller with the given ID, e.g. kea_stats, or nil
+// if there is no such puller.
+func (pullers *Pullers) GetByID(id string) *agentcomm.PeriodicPuller {
+	for _, puller := range pullers.GetAll() {
+		if puller.GetID() == id {
+			return puller
+		}
+	}
+	return nil
 }
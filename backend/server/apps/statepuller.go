@@ -2,6 +2,7 @@ package apps
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/pkg/errors"
@@ -32,6 +33,9 @@ func NewStatePuller(db *dbops.PgDB, agents agentcomm.ConnectedAgents, eventCente
 	if err != nil {
 		return nil, err
 	}
+	// The apps from different machines may form the same HA service, so
+	// the machines are pulled one at a time to avoid conflicting updates.
+	periodicPuller.SetMaxWorkers(1)
 	puller.PeriodicPuller = periodicPuller
 	return puller, nil
 }
@@ -50,21 +54,46 @@ func (puller *StatePuller) pullData() (int, error) {
 		return 0, err
 	}
 
+	// When the action has been triggered for an app, only the state of
+	// the machine running this app is pulled.
+	machineID := int64(0)
+	if appID := puller.TriggeredAppID(); appID != 0 {
+		dbApp, err := dbmodel.GetAppByID(puller.DB, appID)
+		if err != nil {
+			return 0, err
+		}
+		if dbApp == nil {
+			return 0, errors.Errorf("app %d does not exist", appID)
+		}
+		machineID = dbApp.MachineID
+	}
+
 	// get state from machines and their apps
-	var lastErr error
-	okCnt := 0
-	for _, dbM := range dbMachines {
-		dbM2 := dbM
-		ctx := context.Background()
-		errStr := GetMachineAndAppsState(ctx, puller.DB, &dbM2, puller.Agents, puller.EventCenter)
+	var (
+		keys    []string
+		indexes []int
+	)
+	for i := range dbMachines {
+		if machineID != 0 && dbMachines[i].ID != machineID {
+			continue
+		}
+		keys = append(keys, fmt.Sprintf("machine %d", dbMachines[i].ID))
+		indexes = append(indexes, i)
+	}
+	okCnt, lastErr := puller.PullEach(keys, func(ctx context.Context, i int) error {
+		errStr := GetMachineAndAppsState(ctx, puller.DB, &dbMachines[indexes[i]], puller.Agents, puller.EventCenter)
 		if errStr != "" {
-			lastErr = errors.New(errStr)
-			log.Errorf("error occurred while getting info from machine %d: %s", dbM2.ID, errStr)
-		} else {
-			okCnt++
+			return errors.New(errStr)
 		}
+		return nil
+	})
+	log.Printf("completed pulling information from machines: %d/%d succeeded", okCnt, len(keys))
+
+	// compare the clocks of the HA peers using the refreshed clock skews
+	err = checkHAPeersClockSkew(puller.DB, puller.EventCenter)
+	if err != nil {
+		log.Errorf("error occurred while comparing clocks of HA peers: %+v", err)
 	}
-	log.Printf("completed pulling information from machines: %d/%d succeeded", okCnt, len(dbMachines))
 	return okCnt, lastErr
 }
 
@@ -87,6 +116,46 @@ func updateMachineFields(db *dbops.PgDB, dbMachine *dbmodel.Machine, m *agentcom
 	dbMachine.State.VirtualizationSystem = m.VirtualizationSystem
 	dbMachine.State.VirtualizationRole = m.VirtualizationRole
 	dbMachine.State.HostID = m.HostID
+	dbMachine.State.Interfaces = nil
+	for _, iface := range m.Interfaces {
+		dbMachine.State.Interfaces = append(dbMachine.State.Interfaces, dbmodel.NetworkInterface{
+			Name:            iface.Name,
+			HardwareAddress: iface.HardwareAddress,
+			Mtu:             iface.Mtu,
+			Flags:           iface.Flags,
+			Addresses:       iface.Addresses,
+		})
+	}
+	dbMachine.State.Filesystems = nil
+	for _, filesystem := range m.Filesystems {
+		dbMachine.State.Filesystems = append(dbMachine.State.Filesystems, dbmodel.FilesystemUsage{
+			Path:        filesystem.Path,
+			Kind:        filesystem.Kind,
+			Fstype:      filesystem.Fstype,
+			Total:       filesystem.Total,
+			Used:        filesystem.Used,
+			UsedPercent: filesystem.UsedPercent,
+			Error:       filesystem.Error,
+		})
+	}
+	dbMachine.State.Databases = nil
+	for _, database := range m.Databases {
+		dbMachine.State.Databases = append(dbMachine.State.Databases, dbmodel.DatabaseBackend{
+			Kind:      database.Kind,
+			Type:      database.Type,
+			Host:      database.Host,
+			Port:      database.Port,
+			Name:      database.Name,
+			Reachable: database.Reachable,
+			Error:     database.Error,
+		})
+	}
+	dbMachine.State.ClockSyncStatus = m.ClockSyncStatus
+	if !m.AgentTime.IsZero() {
+		dbMachine.State.ClockSkew = int64(m.ClockSkew / time.Millisecond)
+		dbMachine.State.ClockRoundTrip = int64(m.ClockRoundTrip / time.Millisecond)
+		dbMachine.State.ClockCheckedAt = m.LastVisitedAt
+	}
 	dbMachine.LastVisitedAt = m.LastVisitedAt
 	dbMachine.Error = m.Error
 	err := db.Update(dbMachine)
@@ -208,6 +277,12 @@ func mergeNewAndOldApps(db *dbops.PgDB, dbMachine *dbmodel.Machine, discoveredAp
 			})
 		}
 		dbApp.AccessPoints = accessPoints
+
+		// BIND 9 log targets are reported by the agent while Kea log
+		// targets are extracted from the Kea configuration.
+		if app.Type == dbmodel.AppTypeBind9 {
+			bind9.SetAppLogTargets(dbApp, app.LogTargets)
+		}
 	}
 
 	// add old, not matched apps to all apps
@@ -246,6 +321,11 @@ func GetMachineAndAppsState(ctx context.Context, db *dbops.PgDB, dbMachine *dbmo
 		return ""
 	}
 
+	// raise events if the inventory or the clock skew of the machine
+	// crossed the thresholds
+	checkMachineInventoryWithSettings(db, dbMachine, state, eventCenter)
+	checkMachineClockSkewWithSettings(db, dbMachine, state, eventCenter)
+
 	// store machine's state in db
 	err = updateMachineFields(db, dbMachine, state)
 	if err != nil {
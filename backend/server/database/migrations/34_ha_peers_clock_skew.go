@@ -0,0 +1,21 @@
+package dbmigs
+
+import (
+	"github.com/go-pg/migrations/v7"
+)
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+             -- Difference between the clocks of the HA peers in milliseconds.
+             -- It is NULL when the clocks have not been compared yet.
+             ALTER TABLE ha_service ADD COLUMN peers_clock_skew BIGINT;
+        `)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+             ALTER TABLE ha_service DROP COLUMN IF EXISTS peers_clock_skew;
+        `)
+		return err
+	})
+}
@@ -0,0 +1,61 @@
+package dbmigs
+
+import (
+	"github.com/go-pg/migrations/v7"
+)
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+             -- Historical address and prefix delegation counts of the subnets,
+             -- shared networks and daemons. Each sample refers to exactly one
+             -- of them. The raw samples are periodically downsampled into the
+             -- hourly and daily samples.
+             CREATE TABLE IF NOT EXISTS utilization_sample (
+                 id BIGSERIAL NOT NULL,
+                 resolution TEXT NOT NULL,
+                 sampled_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+                 subnet_id BIGINT,
+                 shared_network_id BIGINT,
+                 daemon_id BIGINT,
+                 assigned_addresses BIGINT NOT NULL DEFAULT 0,
+                 total_addresses BIGINT NOT NULL DEFAULT 0,
+                 declined_addresses BIGINT NOT NULL DEFAULT 0,
+                 assigned_pds BIGINT NOT NULL DEFAULT 0,
+                 total_pds BIGINT NOT NULL DEFAULT 0,
+                 CONSTRAINT utilization_sample_pkey PRIMARY KEY (id),
+                 CONSTRAINT utilization_sample_resolution_check CHECK (resolution IN ('raw', 'hour', 'day')),
+                 CONSTRAINT utilization_sample_object_check CHECK (
+                     (subnet_id IS NOT NULL)::int + (shared_network_id IS NOT NULL)::int +
+                     (daemon_id IS NOT NULL)::int = 1),
+                 CONSTRAINT utilization_sample_subnet_id FOREIGN KEY (subnet_id)
+                     REFERENCES subnet (id) MATCH SIMPLE
+                     ON UPDATE NO ACTION
+                     ON DELETE CASCADE,
+                 CONSTRAINT utilization_sample_shared_network_id FOREIGN KEY (shared_network_id)
+                     REFERENCES shared_network (id) MATCH SIMPLE
+                     ON UPDATE NO ACTION
+                     ON DELETE CASCADE,
+                 CONSTRAINT utilization_sample_daemon_id FOREIGN KEY (daemon_id)
+                     REFERENCES daemon (id) MATCH SIMPLE
+                     ON UPDATE NO ACTION
+                     ON DELETE CASCADE
+             );
+
+             -- Only one sample per object, resolution and time. It also speeds up
+             -- selecting the series of the object.
+             CREATE UNIQUE INDEX utilization_sample_series_idx ON utilization_sample
+                 (COALESCE(subnet_id, 0), COALESCE(shared_network_id, 0), COALESCE(daemon_id, 0),
+                  resolution, sampled_at);
+
+             -- Speeds up downsampling and removing the old samples.
+             CREATE INDEX utilization_sample_resolution_idx ON utilization_sample (resolution, sampled_at);
+        `)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+             DROP TABLE IF EXISTS utilization_sample;
+        `)
+		return err
+	})
+}
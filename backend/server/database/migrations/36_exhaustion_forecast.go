@@ -0,0 +1,27 @@
+package dbmigs
+
+import (
+	"github.com/go-pg/migrations/v7"
+)
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+             -- Projected times when the addresses and delegated prefixes
+             -- of the subnets and shared networks will be exhausted.
+             ALTER TABLE subnet ADD COLUMN addr_exhaustion_at TIMESTAMP WITHOUT TIME ZONE;
+             ALTER TABLE subnet ADD COLUMN pd_exhaustion_at TIMESTAMP WITHOUT TIME ZONE;
+             ALTER TABLE shared_network ADD COLUMN addr_exhaustion_at TIMESTAMP WITHOUT TIME ZONE;
+             ALTER TABLE shared_network ADD COLUMN pd_exhaustion_at TIMESTAMP WITHOUT TIME ZONE;
+        `)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+             ALTER TABLE subnet DROP COLUMN IF EXISTS addr_exhaustion_at;
+             ALTER TABLE subnet DROP COLUMN IF EXISTS pd_exhaustion_at;
+             ALTER TABLE shared_network DROP COLUMN IF EXISTS addr_exhaustion_at;
+             ALTER TABLE shared_network DROP COLUMN IF EXISTS pd_exhaustion_at;
+        `)
+		return err
+	})
+}
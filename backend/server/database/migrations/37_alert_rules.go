@@ -0,0 +1,54 @@
+package dbmigs
+
+import (
+	"github.com/go-pg/migrations/v7"
+)
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+             -- Rules raising the events when the metric of a subnet, shared
+             -- network or daemon crosses the threshold.
+             CREATE TABLE IF NOT EXISTS alert_rule (
+                 id BIGSERIAL NOT NULL,
+                 created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+                 name TEXT NOT NULL,
+                 metric TEXT NOT NULL,
+                 operator TEXT NOT NULL,
+                 threshold DOUBLE PRECISION NOT NULL DEFAULT 0,
+                 hysteresis DOUBLE PRECISION NOT NULL DEFAULT 0,
+                 duration BIGINT NOT NULL DEFAULT 0,
+                 level INTEGER NOT NULL DEFAULT 1,
+                 enabled BOOLEAN NOT NULL DEFAULT TRUE,
+                 CONSTRAINT alert_rule_pkey PRIMARY KEY (id),
+                 CONSTRAINT alert_rule_operator_check CHECK (operator IN ('>', '>=', '<', '<=', '==', '!=')),
+                 CONSTRAINT alert_rule_hysteresis_check CHECK (hysteresis >= 0),
+                 CONSTRAINT alert_rule_duration_check CHECK (duration >= 0)
+             );
+
+             -- Pending and firing rules for the particular objects. The state
+             -- is removed when the rule is resolved.
+             CREATE TABLE IF NOT EXISTS alert_state (
+                 rule_id BIGINT NOT NULL,
+                 object_type TEXT NOT NULL,
+                 object_id BIGINT NOT NULL,
+                 pending_since TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+                 firing BOOLEAN NOT NULL DEFAULT FALSE,
+                 fired_at TIMESTAMP WITHOUT TIME ZONE,
+                 value DOUBLE PRECISION NOT NULL DEFAULT 0,
+                 CONSTRAINT alert_state_pkey PRIMARY KEY (rule_id, object_type, object_id),
+                 CONSTRAINT alert_state_rule_id FOREIGN KEY (rule_id)
+                     REFERENCES alert_rule (id) MATCH SIMPLE
+                     ON UPDATE NO ACTION
+                     ON DELETE CASCADE
+             );
+        `)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+             DROP TABLE IF EXISTS alert_state;
+             DROP TABLE IF EXISTS alert_rule;
+        `)
+		return err
+	})
+}
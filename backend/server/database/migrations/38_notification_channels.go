@@ -0,0 +1,41 @@
+package dbmigs
+
+import (
+	"github.com/go-pg/migrations/v7"
+)
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+             -- Channels through which the events are sent outside of Stork.
+             -- The webhook, email and syslog columns hold the configuration
+             -- specific to the channel type. The remaining columns record
+             -- the state of the deliveries.
+             CREATE TABLE IF NOT EXISTS notification_channel (
+                 id BIGSERIAL NOT NULL,
+                 created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+                 name TEXT NOT NULL,
+                 type TEXT NOT NULL,
+                 enabled BOOLEAN NOT NULL DEFAULT TRUE,
+                 level INTEGER NOT NULL DEFAULT 0,
+                 filter JSONB,
+                 webhook JSONB,
+                 email JSONB,
+                 syslog JSONB,
+                 delivered_count BIGINT NOT NULL DEFAULT 0,
+                 failed_count BIGINT NOT NULL DEFAULT 0,
+                 last_delivered_at TIMESTAMP WITHOUT TIME ZONE,
+                 last_failed_at TIMESTAMP WITHOUT TIME ZONE,
+                 last_error TEXT,
+                 CONSTRAINT notification_channel_pkey PRIMARY KEY (id),
+                 CONSTRAINT notification_channel_type_check CHECK (type IN ('webhook', 'email', 'syslog'))
+             );
+        `)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+             DROP TABLE IF EXISTS notification_channel;
+        `)
+		return err
+	})
+}
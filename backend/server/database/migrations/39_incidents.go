@@ -0,0 +1,71 @@
+package dbmigs
+
+import (
+	"github.com/go-pg/migrations/v7"
+)
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+             -- Groups of related events. The key identifies the problem and
+             -- the objects it relates to. There is at most one unresolved
+             -- incident with the given key.
+             CREATE TABLE IF NOT EXISTS incident (
+                 id BIGSERIAL NOT NULL,
+                 created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+                 key TEXT NOT NULL,
+                 text TEXT NOT NULL,
+                 level INTEGER NOT NULL DEFAULT 0,
+                 relations JSONB,
+                 state TEXT NOT NULL DEFAULT 'open',
+                 event_count BIGINT NOT NULL DEFAULT 0,
+                 last_event_at TIMESTAMP WITHOUT TIME ZONE,
+                 acknowledged_by_id INTEGER,
+                 acknowledged_at TIMESTAMP WITHOUT TIME ZONE,
+                 resolved_at TIMESTAMP WITHOUT TIME ZONE,
+                 comment TEXT,
+                 CONSTRAINT incident_pkey PRIMARY KEY (id),
+                 CONSTRAINT incident_state_check CHECK (state IN ('open', 'acknowledged', 'resolved')),
+                 CONSTRAINT incident_acknowledged_by_id FOREIGN KEY (acknowledged_by_id)
+                     REFERENCES system_user (id) MATCH SIMPLE
+                     ON UPDATE NO ACTION
+                     ON DELETE SET NULL
+             );
+             CREATE UNIQUE INDEX IF NOT EXISTS incident_unresolved_key_idx ON incident (key) WHERE state != 'resolved';
+             CREATE INDEX IF NOT EXISTS incident_state_idx ON incident (state);
+
+             -- The optional state of the events.
+             ALTER TABLE event
+                 ADD COLUMN state TEXT,
+                 ADD COLUMN acknowledged_by_id INTEGER,
+                 ADD COLUMN acknowledged_at TIMESTAMP WITHOUT TIME ZONE,
+                 ADD COLUMN resolved_at TIMESTAMP WITHOUT TIME ZONE,
+                 ADD COLUMN comment TEXT,
+                 ADD COLUMN incident_id BIGINT,
+                 ADD CONSTRAINT event_state_check CHECK (state IN ('open', 'acknowledged', 'resolved')),
+                 ADD CONSTRAINT event_acknowledged_by_id FOREIGN KEY (acknowledged_by_id)
+                     REFERENCES system_user (id) MATCH SIMPLE
+                     ON UPDATE NO ACTION
+                     ON DELETE SET NULL,
+                 ADD CONSTRAINT event_incident_id FOREIGN KEY (incident_id)
+                     REFERENCES incident (id) MATCH SIMPLE
+                     ON UPDATE NO ACTION
+                     ON DELETE SET NULL;
+             CREATE INDEX IF NOT EXISTS event_incident_id_idx ON event (incident_id);
+        `)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+             DROP INDEX IF EXISTS event_incident_id_idx;
+             ALTER TABLE event
+                 DROP COLUMN IF EXISTS incident_id,
+                 DROP COLUMN IF EXISTS comment,
+                 DROP COLUMN IF EXISTS resolved_at,
+                 DROP COLUMN IF EXISTS acknowledged_at,
+                 DROP COLUMN IF EXISTS acknowledged_by_id,
+                 DROP COLUMN IF EXISTS state;
+             DROP TABLE IF EXISTS incident;
+        `)
+		return err
+	})
+}
@@ -0,0 +1,27 @@
+package dbmigs
+
+import (
+	"github.com/go-pg/migrations/v7"
+)
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+             -- The identical events are collapsed into one record holding
+             -- the number of occurrences. The creation time of the event
+             -- is the time when the event was seen first.
+             ALTER TABLE event
+                 ADD COLUMN occurrences BIGINT NOT NULL DEFAULT 1,
+                 ADD COLUMN last_seen_at TIMESTAMP WITHOUT TIME ZONE;
+             UPDATE event SET last_seen_at = created_at;
+        `)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+             ALTER TABLE event
+                 DROP COLUMN IF EXISTS last_seen_at,
+                 DROP COLUMN IF EXISTS occurrences;
+        `)
+		return err
+	})
+}
@@ -0,0 +1,27 @@
+package dbmigs
+
+import (
+	"github.com/go-pg/migrations/v7"
+)
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+             -- Machine readable code of the event, e.g. daemon.unreachable,
+             -- and the structured data describing the event.
+             ALTER TABLE event
+                 ADD COLUMN code TEXT,
+                 ADD COLUMN payload JSONB;
+             CREATE INDEX IF NOT EXISTS event_code_idx ON event (code);
+        `)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+             DROP INDEX IF EXISTS event_code_idx;
+             ALTER TABLE event
+                 DROP COLUMN IF EXISTS payload,
+                 DROP COLUMN IF EXISTS code;
+        `)
+		return err
+	})
+}
@@ -0,0 +1,37 @@
+package dbmigs
+
+import (
+	"github.com/go-pg/migrations/v7"
+)
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+             -- Distinct versions of the Kea daemons' configurations.
+             CREATE TABLE IF NOT EXISTS kea_config_revision (
+                 id BIGSERIAL NOT NULL,
+                 created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+                 daemon_id BIGINT NOT NULL,
+                 config_hash TEXT,
+                 config JSONB NOT NULL,
+                 CONSTRAINT kea_config_revision_pkey PRIMARY KEY (id),
+                 CONSTRAINT kea_config_revision_daemon_id FOREIGN KEY (daemon_id)
+                     REFERENCES daemon (id) MATCH SIMPLE
+                     ON UPDATE NO ACTION
+                     ON DELETE CASCADE
+             );
+             CREATE INDEX IF NOT EXISTS kea_config_revision_daemon_id_idx ON kea_config_revision (daemon_id, created_at);
+
+             -- The current configurations become the first revisions.
+             INSERT INTO kea_config_revision (daemon_id, config_hash, config)
+                 SELECT daemon_id, config_hash, config FROM kea_daemon
+                     WHERE config IS NOT NULL AND config != 'null'::jsonb;
+        `)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+             DROP TABLE IF EXISTS kea_config_revision;
+        `)
+		return err
+	})
+}
@@ -0,0 +1,25 @@
+package dbmigs
+
+import (
+	"github.com/go-pg/migrations/v7"
+)
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+             -- Client classes and DHCP options assigned to the host
+             -- reservation. The options are stored in the Kea format.
+             ALTER TABLE host
+                 ADD COLUMN client_classes TEXT[],
+                 ADD COLUMN option_data JSONB;
+        `)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+             ALTER TABLE host
+                 DROP COLUMN IF EXISTS option_data,
+                 DROP COLUMN IF EXISTS client_classes;
+        `)
+		return err
+	})
+}
@@ -0,0 +1,42 @@
+package dbmigs
+
+import (
+	"github.com/go-pg/migrations/v7"
+)
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+             -- Issues found in the Kea daemons' configurations by the linter
+             -- rules. They are replaced whenever the configuration is checked.
+             CREATE TABLE IF NOT EXISTS config_lint_finding (
+                 id BIGSERIAL NOT NULL,
+                 created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+                 daemon_id BIGINT NOT NULL,
+                 rule TEXT NOT NULL,
+                 text TEXT NOT NULL,
+                 CONSTRAINT config_lint_finding_pkey PRIMARY KEY (id),
+                 CONSTRAINT config_lint_finding_daemon_id FOREIGN KEY (daemon_id)
+                     REFERENCES daemon (id) MATCH SIMPLE
+                     ON UPDATE NO ACTION
+                     ON DELETE CASCADE
+             );
+             CREATE INDEX IF NOT EXISTS config_lint_finding_daemon_id_idx ON config_lint_finding (daemon_id);
+
+             -- States of the linter rules set by the users. The rules not
+             -- listed here are enabled.
+             CREATE TABLE IF NOT EXISTS config_lint_rule (
+                 name TEXT NOT NULL,
+                 enabled BOOLEAN NOT NULL DEFAULT TRUE,
+                 CONSTRAINT config_lint_rule_pkey PRIMARY KEY (name)
+             );
+        `)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+             DROP TABLE IF EXISTS config_lint_rule;
+             DROP TABLE IF EXISTS config_lint_finding;
+        `)
+		return err
+	})
+}
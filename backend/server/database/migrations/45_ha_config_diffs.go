@@ -0,0 +1,23 @@
+package dbmigs
+
+import (
+	"github.com/go-pg/migrations/v7"
+)
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+             -- Differences between the configurations of the HA peers and
+             -- the time when the configurations were compared last time.
+             ALTER TABLE ha_service ADD COLUMN config_diffs JSONB;
+             ALTER TABLE ha_service ADD COLUMN config_checked_at TIMESTAMP WITHOUT TIME ZONE;
+        `)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+             ALTER TABLE ha_service DROP COLUMN IF EXISTS config_checked_at;
+             ALTER TABLE ha_service DROP COLUMN IF EXISTS config_diffs;
+        `)
+		return err
+	})
+}
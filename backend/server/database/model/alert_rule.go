@@ -0,0 +1,207 @@
+package dbmodel
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+	pkgerrors "github.com/pkg/errors"
+	dbops "isc.org/stork/server/database"
+)
+
+// Metrics evaluated by the alert rules.
+const (
+	// Percentage of assigned addresses in a subnet.
+	AlertMetricSubnetUtilization = "subnet_utilization"
+	// Percentage of assigned addresses in a shared network.
+	AlertMetricSharedNetworkUtilization = "shared_network_utilization"
+	// Number of declined addresses in a subnet.
+	AlertMetricSubnetDeclinedAddresses = "subnet_declined_addresses"
+	// Responses per second sent by a Kea DHCP daemon.
+	AlertMetricDaemonRPS = "daemon_rps"
+	// 1 if a Kea DHCP daemon is in the partner-down HA state, 0 otherwise.
+	AlertMetricHAPartnerDown = "ha_partner_down"
+)
+
+// Types of the objects for which the alert rules are evaluated.
+const (
+	AlertObjectSubnet        = "subnet"
+	AlertObjectSharedNetwork = "shared_network"
+	AlertObjectDaemon        = "daemon"
+)
+
+// Rule raising an event when the metric of a subnet, shared network or
+// daemon crosses the threshold for at least the given duration (in seconds).
+// The rule fires when the metric compared with the threshold using the
+// operator holds. The firing rule is resolved when the comparison no longer
+// holds for the threshold moved by the hysteresis, e.g. the rule firing when
+// the utilization is greater than 90 with the hysteresis 5 is resolved
+// when the utilization drops to 85 or below.
+type AlertRule struct {
+	ID         int64
+	CreatedAt  time.Time
+	Name       string
+	Metric     string
+	Operator   string
+	Threshold  float64 `pg:",use_zero"`
+	Hysteresis float64 `pg:",use_zero"`
+	Duration   int64   `pg:",use_zero"`
+	Level      int     `pg:",use_zero"`
+	Enabled    bool    `pg:",use_zero"`
+
+	States []*AlertState `pg:"fk:rule_id"`
+}
+
+// State of the pending or firing rule for the particular object.
+type AlertState struct {
+	RuleID       int64  `pg:",pk"`
+	ObjectType   string `pg:",pk"`
+	ObjectID     int64  `pg:",pk"`
+	PendingSince time.Time
+	Firing       bool `pg:",use_zero"`
+	FiredAt      time.Time
+	Value        float64 `pg:",use_zero"`
+}
+
+// Returns the object type evaluated by the metric or an empty string if
+// the metric is unknown.
+func GetAlertMetricObjectType(metric string) string {
+	switch metric {
+	case AlertMetricSubnetUtilization, AlertMetricSubnetDeclinedAddresses:
+		return AlertObjectSubnet
+	case AlertMetricSharedNetworkUtilization:
+		return AlertObjectSharedNetwork
+	case AlertMetricDaemonRPS, AlertMetricHAPartnerDown:
+		return AlertObjectDaemon
+	default:
+		return ""
+	}
+}
+
+// Checks whether the value compared with the threshold using the rule's
+// operator holds. The offset moves the threshold in the direction which
+// makes the comparison hold for more values. The offset is ignored by the
+// equality operators.
+func (rule *AlertRule) Holds(value float64, offset float64) bool {
+	switch rule.Operator {
+	case ">":
+		return value > rule.Threshold-offset
+	case ">=":
+		return value >= rule.Threshold-offset
+	case "<":
+		return value < rule.Threshold+offset
+	case "<=":
+		return value <= rule.Threshold+offset
+	case "==":
+		return value == rule.Threshold
+	case "!=":
+		return value != rule.Threshold
+	default:
+		return false
+	}
+}
+
+// Adds new alert rule to the database.
+func AddAlertRule(db *pg.DB, rule *AlertRule) error {
+	err := db.Insert(rule)
+	if err != nil {
+		err = pkgerrors.Wrapf(err, "problem with inserting alert rule %s", rule.Name)
+	}
+	return err
+}
+
+// Updates the alert rule in the database. The states of the rule are
+// removed, so the rule is evaluated from scratch.
+func UpdateAlertRule(db *pg.DB, rule *AlertRule) error {
+	tx, rollback, commit, err := dbops.Transaction(db)
+	if err != nil {
+		return pkgerrors.WithMessagef(err, "problem with starting transaction for updating alert rule %d", rule.ID)
+	}
+	defer rollback()
+	_, err = tx.Model(rule).ExcludeColumn("created_at").WherePK().Update()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem with updating alert rule %d", rule.ID)
+	}
+	_, err = tx.Model((*AlertState)(nil)).Where("rule_id = ?", rule.ID).Delete()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem with deleting states of alert rule %d", rule.ID)
+	}
+	err = commit()
+	if err != nil {
+		err = pkgerrors.WithMessagef(err, "problem with committing updated alert rule %d", rule.ID)
+	}
+	return err
+}
+
+// Fetches the alert rule with its states by ID. It returns nil if the rule
+// does not exist.
+func GetAlertRule(db *pg.DB, id int64) (*AlertRule, error) {
+	rule := &AlertRule{}
+	err := db.Model(rule).
+		Relation("States", func(q *orm.Query) (*orm.Query, error) {
+			return q.Order("object_type ASC", "object_id ASC"), nil
+		}).
+		Where("alert_rule.id = ?", id).
+		Select()
+	if err != nil {
+		if errors.Is(err, pg.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, pkgerrors.Wrapf(err, "problem with getting alert rule %d", id)
+	}
+	return rule, nil
+}
+
+// Fetches all alert rules with their states ordered by ID.
+func GetAlertRules(db *pg.DB) ([]*AlertRule, error) {
+	rules := []*AlertRule{}
+	err := db.Model(&rules).
+		Relation("States", func(q *orm.Query) (*orm.Query, error) {
+			return q.Order("object_type ASC", "object_id ASC"), nil
+		}).
+		OrderExpr("id ASC").
+		Select()
+	if err != nil {
+		return nil, pkgerrors.Wrapf(err, "problem with getting alert rules")
+	}
+	return rules, nil
+}
+
+// Deletes the alert rule and its states.
+func DeleteAlertRule(db *pg.DB, id int64) error {
+	rule := &AlertRule{
+		ID: id,
+	}
+	_, err := db.Model(rule).WherePK().Delete()
+	if err != nil {
+		err = pkgerrors.Wrapf(err, "problem with deleting alert rule %d", id)
+	}
+	return err
+}
+
+// Inserts or updates the state of the rule for the object.
+func SetAlertState(db *pg.DB, state *AlertState) error {
+	_, err := db.Model(state).
+		OnConflict("(rule_id, object_type, object_id) DO UPDATE").
+		Set("pending_since = EXCLUDED.pending_since").
+		Set("firing = EXCLUDED.firing").
+		Set("fired_at = EXCLUDED.fired_at").
+		Set("value = EXCLUDED.value").
+		Insert()
+	if err != nil {
+		err = pkgerrors.Wrapf(err, "problem with setting state of alert rule %d for %s %d",
+			state.RuleID, state.ObjectType, state.ObjectID)
+	}
+	return err
+}
+
+// Deletes the state of the rule for the object.
+func DeleteAlertState(db *pg.DB, state *AlertState) error {
+	_, err := db.Model(state).WherePK().Delete()
+	if err != nil {
+		err = pkgerrors.Wrapf(err, "problem with deleting state of alert rule %d for %s %d",
+			state.RuleID, state.ObjectType, state.ObjectID)
+	}
+	return err
+}
@@ -0,0 +1,154 @@
+package dbmodel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Test that the rule's condition is evaluated using its operator and
+// the threshold moved by the offset.
+func TestAlertRuleHolds(t *testing.T) {
+	rule := &AlertRule{
+		Operator:  ">",
+		Threshold: 90,
+	}
+	require.True(t, rule.Holds(91, 0))
+	require.False(t, rule.Holds(90, 0))
+	require.True(t, rule.Holds(90, 5))
+	require.False(t, rule.Holds(85, 5))
+
+	rule.Operator = ">="
+	require.True(t, rule.Holds(90, 0))
+	require.True(t, rule.Holds(85, 5))
+	require.False(t, rule.Holds(84, 5))
+
+	rule.Operator = "<"
+	rule.Threshold = 1
+	require.True(t, rule.Holds(0, 0))
+	require.False(t, rule.Holds(1, 0))
+	require.True(t, rule.Holds(1, 1))
+
+	rule.Operator = "<="
+	require.True(t, rule.Holds(1, 0))
+	require.True(t, rule.Holds(2, 1))
+	require.False(t, rule.Holds(3, 1))
+
+	rule.Operator = "=="
+	require.True(t, rule.Holds(1, 5))
+	require.False(t, rule.Holds(2, 5))
+
+	rule.Operator = "!="
+	require.True(t, rule.Holds(2, 5))
+	require.False(t, rule.Holds(1, 5))
+
+	rule.Operator = "foo"
+	require.False(t, rule.Holds(1, 0))
+}
+
+// Test that the metrics are mapped to the object types.
+func TestGetAlertMetricObjectType(t *testing.T) {
+	require.Equal(t, AlertObjectSubnet, GetAlertMetricObjectType(AlertMetricSubnetUtilization))
+	require.Equal(t, AlertObjectSubnet, GetAlertMetricObjectType(AlertMetricSubnetDeclinedAddresses))
+	require.Equal(t, AlertObjectSharedNetwork, GetAlertMetricObjectType(AlertMetricSharedNetworkUtilization))
+	require.Equal(t, AlertObjectDaemon, GetAlertMetricObjectType(AlertMetricDaemonRPS))
+	require.Equal(t, AlertObjectDaemon, GetAlertMetricObjectType(AlertMetricHAPartnerDown))
+	require.Empty(t, GetAlertMetricObjectType("foo"))
+}
+
+// Test that the alert rules and their states are added, updated, fetched
+// and deleted.
+func TestAlertRules(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rule := &AlertRule{
+		Name:       "subnet full",
+		Metric:     AlertMetricSubnetUtilization,
+		Operator:   ">",
+		Threshold:  90,
+		Hysteresis: 5,
+		Duration:   600,
+		Level:      EvWarning,
+		Enabled:    true,
+	}
+	err := AddAlertRule(db, rule)
+	require.NoError(t, err)
+	require.NotZero(t, rule.ID)
+
+	returned, err := GetAlertRule(db, rule.ID)
+	require.NoError(t, err)
+	require.NotNil(t, returned)
+	require.Equal(t, "subnet full", returned.Name)
+	require.EqualValues(t, 90, returned.Threshold)
+	require.EqualValues(t, 5, returned.Hysteresis)
+	require.EqualValues(t, 600, returned.Duration)
+	require.True(t, returned.Enabled)
+	require.NotZero(t, returned.CreatedAt)
+	require.Empty(t, returned.States)
+
+	// Add the states.
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	state := &AlertState{
+		RuleID:       rule.ID,
+		ObjectType:   AlertObjectSubnet,
+		ObjectID:     2,
+		PendingSince: now,
+		Value:        95,
+	}
+	err = SetAlertState(db, state)
+	require.NoError(t, err)
+	err = SetAlertState(db, &AlertState{
+		RuleID:       rule.ID,
+		ObjectType:   AlertObjectSubnet,
+		ObjectID:     1,
+		PendingSince: now,
+		Value:        91,
+	})
+	require.NoError(t, err)
+
+	// Update the state.
+	state.Firing = true
+	state.FiredAt = now.Add(time.Minute)
+	state.Value = 96
+	err = SetAlertState(db, state)
+	require.NoError(t, err)
+
+	rules, err := GetAlertRules(db)
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	require.Len(t, rules[0].States, 2)
+	require.EqualValues(t, 1, rules[0].States[0].ObjectID)
+	require.False(t, rules[0].States[0].Firing)
+	require.EqualValues(t, 2, rules[0].States[1].ObjectID)
+	require.True(t, rules[0].States[1].Firing)
+	require.EqualValues(t, 96, rules[0].States[1].Value)
+	require.WithinDuration(t, now.Add(time.Minute), rules[0].States[1].FiredAt, 0)
+
+	// Delete the state.
+	err = DeleteAlertState(db, state)
+	require.NoError(t, err)
+	returned, err = GetAlertRule(db, rule.ID)
+	require.NoError(t, err)
+	require.Len(t, returned.States, 1)
+
+	// Updating the rule removes its states.
+	rule.Enabled = false
+	rule.Threshold = 0
+	err = UpdateAlertRule(db, rule)
+	require.NoError(t, err)
+	returned, err = GetAlertRule(db, rule.ID)
+	require.NoError(t, err)
+	require.False(t, returned.Enabled)
+	require.Zero(t, returned.Threshold)
+	require.Empty(t, returned.States)
+
+	// Delete the rule.
+	err = DeleteAlertRule(db, rule.ID)
+	require.NoError(t, err)
+	returned, err = GetAlertRule(db, rule.ID)
+	require.NoError(t, err)
+	require.Nil(t, returned)
+}
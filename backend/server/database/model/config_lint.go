@@ -0,0 +1,124 @@
+package dbmodel
+
+import (
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	pkgerrors "github.com/pkg/errors"
+	dbops "isc.org/stork/server/database"
+)
+
+// Issue found in the Kea daemon's configuration by the linter rule.
+type ConfigLintFinding struct {
+	ID        int64
+	CreatedAt time.Time
+	DaemonID  int64
+	Rule      string
+	Text      string
+}
+
+// State of the linter rule set by the user. The rules without the state
+// are enabled.
+type ConfigLintRule struct {
+	Name    string `pg:",pk"`
+	Enabled bool   `pg:",use_zero"`
+}
+
+// Replaces the findings of the daemon with the new findings. The findings
+// having the same rule and text as the existing ones are kept with their
+// creation time, the others are added and the existing findings which are
+// no longer reported are deleted. The dbIface object may either be a
+// pg.DB object or pg.Tx. It returns the added findings.
+func ReplaceConfigLintFindings(dbIface interface{}, daemonID int64, findings []ConfigLintFinding) ([]ConfigLintFinding, error) {
+	tx, rollback, commit, err := dbops.Transaction(dbIface)
+	if err != nil {
+		return nil, err
+	}
+	defer rollback()
+
+	var existing []ConfigLintFinding
+	err = tx.Model(&existing).Where("daemon_id = ?", daemonID).Select()
+	if err != nil {
+		return nil, pkgerrors.Wrapf(err, "problem with getting config lint findings of daemon %d", daemonID)
+	}
+	kept := make(map[string]bool)
+	for _, finding := range findings {
+		kept[finding.Rule+"/"+finding.Text] = true
+	}
+	found := make(map[string]bool)
+	for i := range existing {
+		key := existing[i].Rule + "/" + existing[i].Text
+		if kept[key] && !found[key] {
+			found[key] = true
+			continue
+		}
+		_, err = tx.Model(&existing[i]).WherePK().Delete()
+		if err != nil {
+			return nil, pkgerrors.Wrapf(err, "problem with deleting config lint finding %d", existing[i].ID)
+		}
+	}
+
+	var added []ConfigLintFinding
+	for _, finding := range findings {
+		key := finding.Rule + "/" + finding.Text
+		if found[key] {
+			continue
+		}
+		found[key] = true
+		finding.ID = 0
+		finding.DaemonID = daemonID
+		err = tx.Insert(&finding)
+		if err != nil {
+			return nil, pkgerrors.Wrapf(err, "problem with inserting config lint finding of daemon %d", daemonID)
+		}
+		added = append(added, finding)
+	}
+	err = commit()
+	if err != nil {
+		return nil, pkgerrors.WithMessagef(err, "problem with committing config lint findings of daemon %d", daemonID)
+	}
+	return added, nil
+}
+
+// Fetches the findings of the daemon ordered by the rule and ID.
+func GetConfigLintFindingsByDaemon(db *pg.DB, daemonID int64) ([]ConfigLintFinding, error) {
+	findings := []ConfigLintFinding{}
+	err := db.Model(&findings).
+		Where("daemon_id = ?", daemonID).
+		OrderExpr("rule ASC, id ASC").
+		Select()
+	if err != nil {
+		return nil, pkgerrors.Wrapf(err, "problem with getting config lint findings of daemon %d", daemonID)
+	}
+	return findings, nil
+}
+
+// Returns the names of the disabled linter rules.
+func GetDisabledConfigLintRules(db *pg.DB) (map[string]bool, error) {
+	var rules []ConfigLintRule
+	err := db.Model(&rules).Where("enabled = FALSE").Select()
+	if err != nil {
+		return nil, pkgerrors.Wrapf(err, "problem with getting disabled config lint rules")
+	}
+	disabled := make(map[string]bool)
+	for _, rule := range rules {
+		disabled[rule.Name] = true
+	}
+	return disabled, nil
+}
+
+// Enables or disables the linter rule.
+func SetConfigLintRuleEnabled(db *pg.DB, name string, enabled bool) error {
+	rule := &ConfigLintRule{
+		Name:    name,
+		Enabled: enabled,
+	}
+	_, err := db.Model(rule).
+		OnConflict("(name) DO UPDATE").
+		Set("enabled = EXCLUDED.enabled").
+		Insert()
+	if err != nil {
+		err = pkgerrors.Wrapf(err, "problem with setting state of config lint rule %s", name)
+	}
+	return err
+}
@@ -0,0 +1,70 @@
+package dbmodel
+
+import (
+	"testing"
+
+	require "github.com/stretchr/testify/require"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Test that the findings are replaced keeping the findings reported
+// again.
+func TestReplaceConfigLintFindings(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	daemon := addTestKeaDaemon(t, db)
+
+	added, err := ReplaceConfigLintFindings(db, daemon.ID, []ConfigLintFinding{
+		{Rule: "stat_cmds_missing", Text: "foo"},
+		{Rule: "overlapping_pools", Text: "bar"},
+	})
+	require.NoError(t, err)
+	require.Len(t, added, 2)
+
+	findings, err := GetConfigLintFindingsByDaemon(db, daemon.ID)
+	require.NoError(t, err)
+	require.Len(t, findings, 2)
+	require.Equal(t, "overlapping_pools", findings[0].Rule)
+	keptID := findings[1].ID
+
+	added, err = ReplaceConfigLintFindings(db, daemon.ID, []ConfigLintFinding{
+		{Rule: "stat_cmds_missing", Text: "foo"},
+		{Rule: "lease_cmds_missing", Text: "baz"},
+	})
+	require.NoError(t, err)
+	require.Len(t, added, 1)
+	require.Equal(t, "lease_cmds_missing", added[0].Rule)
+
+	findings, err = GetConfigLintFindingsByDaemon(db, daemon.ID)
+	require.NoError(t, err)
+	require.Len(t, findings, 2)
+	require.Equal(t, "lease_cmds_missing", findings[0].Rule)
+	require.Equal(t, keptID, findings[1].ID)
+
+	added, err = ReplaceConfigLintFindings(db, daemon.ID, nil)
+	require.NoError(t, err)
+	require.Empty(t, added)
+	findings, err = GetConfigLintFindingsByDaemon(db, daemon.ID)
+	require.NoError(t, err)
+	require.Empty(t, findings)
+}
+
+// Test that the linter rules are disabled and enabled.
+func TestSetConfigLintRuleEnabled(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	disabled, err := GetDisabledConfigLintRules(db)
+	require.NoError(t, err)
+	require.Empty(t, disabled)
+
+	require.NoError(t, SetConfigLintRuleEnabled(db, "stat_cmds_missing", false))
+	require.NoError(t, SetConfigLintRuleEnabled(db, "lease_cmds_missing", false))
+	require.NoError(t, SetConfigLintRuleEnabled(db, "lease_cmds_missing", true))
+
+	disabled, err = GetDisabledConfigLintRules(db)
+	require.NoError(t, err)
+	require.Len(t, disabled, 1)
+	require.True(t, disabled["stat_cmds_missing"])
+}
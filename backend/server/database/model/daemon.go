@@ -360,32 +360,38 @@ func (d *Daemon) SetConfigWithHash(config interface{}, configHash string) error
 			return pkgerrors.Errorf("error setting non Kea config for Kea daemon %s", d.Name)
 		}
 
-		existingLogTargets := d.LogTargets
-		d.LogTargets = []*LogTarget{}
+		targets := []*LogTarget{}
 		loggers := parsedConfig.GetLoggers()
 		for _, logger := range loggers {
-			targets := NewLogTargetsFromKea(logger)
-			for i := range targets {
-				// For each target check if it already exists and inherit its
-				// ID and creation time.
-				for _, existingTarget := range existingLogTargets {
-					if targets[i].Name == existingTarget.Name &&
-						targets[i].Output == existingTarget.Output &&
-						existingTarget.DaemonID == d.ID {
-						targets[i].ID = existingTarget.ID
-						targets[i].DaemonID = d.ID
-						targets[i].CreatedAt = existingTarget.CreatedAt
-					}
-				}
-				d.LogTargets = append(d.LogTargets, targets[i])
-			}
+			targets = append(targets, NewLogTargetsFromKea(logger)...)
 		}
+		d.SetLogTargets(targets)
 		d.KeaDaemon.Config = parsedConfig
 		d.KeaDaemon.ConfigHash = configHash
 	}
 	return nil
 }
 
+// Replaces the log targets of the daemon. For each new target it checks
+// if the target already exists and inherits its ID and creation time, so
+// the existing targets are updated rather than re-created in the database.
+func (d *Daemon) SetLogTargets(targets []*LogTarget) {
+	existingLogTargets := d.LogTargets
+	d.LogTargets = []*LogTarget{}
+	for i := range targets {
+		for _, existingTarget := range existingLogTargets {
+			if targets[i].Name == existingTarget.Name &&
+				targets[i].Output == existingTarget.Output &&
+				existingTarget.DaemonID == d.ID {
+				targets[i].ID = existingTarget.ID
+				targets[i].DaemonID = d.ID
+				targets[i].CreatedAt = existingTarget.CreatedAt
+			}
+		}
+		d.LogTargets = append(d.LogTargets, targets[i])
+	}
+}
+
 // Sets new configuration of the daemon with empty hash.
 func (d *Daemon) SetConfig(config interface{}) error {
 	return d.SetConfigWithHash(config, "")
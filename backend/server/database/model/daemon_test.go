@@ -427,6 +427,46 @@ func TestSetConfig(t *testing.T) {
 	require.Empty(t, daemon.KeaDaemon.ConfigHash)
 }
 
+// Tests that the log targets of the BIND 9 daemon can be replaced and the
+// existing targets are preserved.
+func TestSetLogTargets(t *testing.T) {
+	daemon := NewBind9Daemon(true)
+	daemon.SetLogTargets([]*LogTarget{
+		{
+			Name:     "default_log",
+			Severity: "info",
+			Output:   "/var/log/named.log",
+		},
+	})
+	require.Len(t, daemon.LogTargets, 1)
+
+	// Simulate adding this to the database and set some identifiers.
+	daemon.ID = 1
+	daemon.LogTargets[0].ID = 2
+	daemon.LogTargets[0].DaemonID = 1
+
+	daemon.SetLogTargets([]*LogTarget{
+		{
+			Name:     "default_log",
+			Severity: "debug",
+			Output:   "/var/log/named.log",
+		},
+		{
+			Name:     "queries",
+			Severity: "info",
+			Output:   "/var/log/queries.log",
+		},
+	})
+	require.Len(t, daemon.LogTargets, 2)
+	require.EqualValues(t, 2, daemon.LogTargets[0].ID)
+	require.EqualValues(t, 1, daemon.LogTargets[0].DaemonID)
+	require.Equal(t, "debug", daemon.LogTargets[0].Severity)
+	require.Zero(t, daemon.LogTargets[1].ID)
+
+	daemon.SetLogTargets(nil)
+	require.Empty(t, daemon.LogTargets)
+}
+
 // Test that shallow copy of a Kea daemon can be created.
 func TestShallowCopyKeaDaemon(t *testing.T) {
 	// Create Daemon instance with not nil KeaDaemon.
@@ -2,6 +2,7 @@ package dbmodel
 
 import (
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/go-pg/pg/v9"
@@ -22,16 +23,32 @@ type Relations struct {
 	SubnetID  int64 `json:",omitempty"`
 	DaemonID  int64 `json:",omitempty"`
 	UserID    int64 `json:",omitempty"`
+	RuleID    int64 `json:",omitempty"`
 }
 
-// Represents an event held in event table in the database.
+// Represents an event held in event table in the database. The events
+// grouped into incidents have a state (open, acknowledged or resolved).
+// The state of the remaining events is empty. The identical events
+// occurring within a short time are collapsed into one event. The
+// creation time of such event is the time when it was seen first.
 type Event struct {
-	ID        int64
-	CreatedAt time.Time
-	Text      string
-	Level     int `pg:",use_zero"`
-	Relations *Relations
-	Details   string
+	ID          int64
+	CreatedAt   time.Time
+	Text        string
+	Level       int `pg:",use_zero"`
+	Relations   *Relations
+	Details     string
+	Code        EventCode
+	Payload     EventPayload
+	Occurrences int64
+	LastSeenAt  time.Time
+
+	State            string
+	AcknowledgedByID int
+	AcknowledgedAt   time.Time
+	ResolvedAt       time.Time
+	Comment          string
+	IncidentID       int64
 }
 
 // Add given event to the database.
@@ -43,6 +60,20 @@ func AddEvent(db *pg.DB, event *Event) error {
 	return err
 }
 
+// Records another occurrence of the event seen at the given time. It
+// returns false if the event does not exist, e.g. it has been deleted.
+func AddEventOccurrence(db *pg.DB, id int64, at time.Time) (bool, error) {
+	result, err := db.Model(&Event{}).
+		Set("occurrences = occurrences + 1").
+		Set("last_seen_at = ?", at).
+		Where("id = ?", id).
+		Update()
+	if err != nil {
+		return false, pkgerrors.Wrapf(err, "problem with adding occurrence of event %d", id)
+	}
+	return result.RowsAffected() > 0, nil
+}
+
 // Fetches a collection of events from the database. The offset and
 // limit specify the beginning of the page and the maximum size of the
 // page. Limit has to be greater then 0, otherwise error is returned.
@@ -51,11 +82,13 @@ func AddEvent(db *pg.DB, event *Event) error {
 // allows selecting events only from given type of app ('kea',
 // 'bind9') or daemon (e.g. 'named' or 'dhcp4'. machineID and userID
 // allows selecting events connected with indicated machine or
-// user. sortField allows indicating sort column in database and
-// sortDir allows selection the order of sorting. If sortField is
-// empty then id is used for sorting. If SortDirAny is used then ASC
-// order is used.
-func GetEventsByPage(db *pg.DB, offset int64, limit int64, level int64, daemonType *string, appType *string, machineID *int64, userID *int64, sortField string, sortDir SortDirEnum) ([]Event, int64, error) {
+// user. code allows selecting events with the given code or, if it
+// ends with an asterisk, with the codes starting with the given prefix
+// (e.g. 'daemon.*'). sortField allows indicating sort column in
+// database and sortDir allows selection the order of sorting. If
+// sortField is empty then id is used for sorting. If SortDirAny is
+// used then ASC order is used.
+func GetEventsByPage(db *pg.DB, offset int64, limit int64, level int64, daemonType *string, appType *string, machineID *int64, userID *int64, code *string, sortField string, sortDir SortDirEnum) ([]Event, int64, error) {
 	if limit == 0 {
 		return nil, 0, pkgerrors.New("limit should be greater than 0")
 	}
@@ -80,6 +113,15 @@ func GetEventsByPage(db *pg.DB, offset int64, limit int64, level int64, daemonTy
 	if userID != nil {
 		q = q.Where("CAST (relations->'UserID' AS INTEGER) = ?", *userID)
 	}
+	if code != nil && len(*code) > 0 {
+		if strings.HasSuffix(*code, "*") {
+			prefix := strings.TrimSuffix(*code, "*")
+			prefix = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix)
+			q = q.Where("event.code LIKE ?", prefix+"%")
+		} else {
+			q = q.Where("event.code = ?", *code)
+		}
+	}
 
 	// prepare sorting expression, offset and limit
 	ordExpr := prepareOrderExpr("event", sortField, sortDir)
@@ -96,3 +138,117 @@ func GetEventsByPage(db *pg.DB, offset int64, limit int64, level int64, daemonTy
 	}
 	return events, int64(total), nil
 }
+
+// Fetches the event by ID. It returns nil if the event does not exist.
+func GetEvent(db *pg.DB, id int64) (*Event, error) {
+	event := &Event{}
+	err := db.Model(event).Where("id = ?", id).Select()
+	if err != nil {
+		if errors.Is(err, pg.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, pkgerrors.Wrapf(err, "problem with getting event %d", id)
+	}
+	return event, nil
+}
+
+// Fetches the page of the events created within the specified time range,
+// i.e. not earlier than from and earlier than to. The level indicates the
+// lowest level of the returned events. The offset and limit specify the
+// beginning and the maximum size of the page; the limit of 0 returns all
+// events. The events are ordered by ID. It also returns the total number
+// of the events within the time range.
+func GetEventsByTimeRange(db *pg.DB, from, to time.Time, level int64, offset, limit int64) ([]Event, int64, error) {
+	events := []Event{}
+	q := db.Model(&events).
+		Where("created_at >= ?", from).
+		Where("created_at < ?", to)
+	if level > 0 {
+		q = q.Where("level >= ?", level)
+	}
+	q = q.OrderExpr("id ASC").Offset(int(offset))
+	if limit > 0 {
+		q = q.Limit(int(limit))
+	}
+	total, err := q.SelectAndCount()
+	if err != nil && !errors.Is(err, pg.ErrNoRows) {
+		return nil, 0, pkgerrors.Wrapf(err, "problem with getting events created between %s and %s", from, to)
+	}
+	return events, int64(total), nil
+}
+
+// Fetches up to limit most recent events with IDs greater than the
+// specified ID. The events are ordered by ID.
+func GetEventsAfter(db *pg.DB, id int64, limit int) ([]Event, error) {
+	events := []Event{}
+	err := db.Model(&events).
+		Where("id > ?", id).
+		OrderExpr("id DESC").
+		Limit(limit).
+		Select()
+	if err != nil && !errors.Is(err, pg.ErrNoRows) {
+		return nil, pkgerrors.Wrapf(err, "problem with getting events after event %d", id)
+	}
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	return events, nil
+}
+
+// Fetches up to limit oldest events with the given level last seen before
+// the specified time. The events collapsing the recurring occurrences are
+// kept as long as they recur. The events without the time when they were
+// last seen are selected by the creation time. The events are ordered by
+// ID.
+func GetEventsLastSeenBefore(db *pg.DB, level int, before time.Time, limit int) ([]Event, error) {
+	events := []Event{}
+	err := db.Model(&events).
+		Where("level = ?", level).
+		Where("COALESCE(last_seen_at, created_at) < ?", before).
+		OrderExpr("id ASC").
+		Limit(limit).
+		Select()
+	if err != nil && !errors.Is(err, pg.ErrNoRows) {
+		return nil, pkgerrors.Wrapf(err, "problem with getting events with level %d last seen before %s", level, before)
+	}
+	return events, nil
+}
+
+// Deletes the events with the given IDs. It returns the number of deleted
+// events.
+func DeleteEvents(db *pg.DB, ids []int64) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result, err := db.Model(&Event{}).Where("id IN (?)", pg.In(ids)).Delete()
+	if err != nil {
+		return 0, pkgerrors.Wrapf(err, "problem with deleting events")
+	}
+	return result.RowsAffected(), nil
+}
+
+// Changes the state of the single event. The user is the one acknowledging
+// the event. The comment is stored in the event unless it is empty.
+func SetEventState(db *pg.DB, id int64, state string, userID int, comment string, at time.Time) error {
+	if !IsValidEventState(state) {
+		return pkgerrors.Errorf("invalid event state %s", state)
+	}
+	event := &Event{
+		ID:      id,
+		State:   state,
+		Comment: comment,
+	}
+	setStateFields(state, userID, at, &event.AcknowledgedByID, &event.AcknowledgedAt, &event.ResolvedAt)
+	columns := []string{"state", "resolved_at"}
+	if state != EventStateResolved {
+		columns = append(columns, "acknowledged_by_id", "acknowledged_at")
+	}
+	if len(comment) > 0 {
+		columns = append(columns, "comment")
+	}
+	_, err := db.Model(event).Column(columns...).WherePK().Update()
+	if err != nil {
+		err = pkgerrors.Wrapf(err, "problem with setting state of event %d", id)
+	}
+	return err
+}
@@ -0,0 +1,102 @@
+package dbmodel
+
+import (
+	"strings"
+)
+
+// Machine readable code of the event. It identifies the kind of the event
+// independently of its text, so the integrations can act on the events
+// without parsing the text. The codes have the form of the object kind
+// and the action or state separated by a dot, e.g. daemon.unreachable.
+type EventCode string
+
+// Structured data describing the event, e.g. the IDs and names of the
+// related objects and the values which crossed the thresholds. It is
+// stored in the database as JSONB.
+type EventPayload map[string]interface{}
+
+// Codes of the events raised by the server.
+const (
+	EventCodeServerStarted  EventCode = "server.started"
+	EventCodeServerStopping EventCode = "server.stopping"
+
+	EventCodeMachineAdded                      EventCode = "machine.added"
+	EventCodeMachineReregistered               EventCode = "machine.reregistered"
+	EventCodeMachineRemoved                    EventCode = "machine.removed"
+	EventCodeMachineClockSkew                  EventCode = "machine.clock_skew"
+	EventCodeMachineClockSkewDropped           EventCode = "machine.clock_skew_dropped"
+	EventCodeMachineDiskUsageHigh              EventCode = "machine.disk_usage_high"
+	EventCodeMachineDiskUsageDropped           EventCode = "machine.disk_usage_dropped"
+	EventCodeMachineInterfaceDisappeared       EventCode = "machine.interface_disappeared"
+	EventCodeMachineInterfaceAddressesLost     EventCode = "machine.interface_addresses_lost"
+	EventCodeMachineInterfaceAddressesRestored EventCode = "machine.interface_addresses_restored"
+
+	EventCodeAgentUnreachable EventCode = "agent.unreachable"
+	EventCodeAgentReachable   EventCode = "agent.reachable"
+
+	EventCodeDatabaseUnreachable EventCode = "database.unreachable"
+	EventCodeDatabaseReachable   EventCode = "database.reachable"
+
+	EventCodeAppAdded                EventCode = "app.added"
+	EventCodeAppRenamed              EventCode = "app.renamed"
+	EventCodeAppUnreachable          EventCode = "app.unreachable"
+	EventCodeAppCommunicationFailed  EventCode = "app.communication_failed"
+	EventCodeAppCommunicationResumed EventCode = "app.communication_resumed"
+
+	EventCodeDaemonAdded                EventCode = "daemon.added"
+	EventCodeDaemonRemoved              EventCode = "daemon.removed"
+	EventCodeDaemonUnreachable          EventCode = "daemon.unreachable"
+	EventCodeDaemonReachable            EventCode = "daemon.reachable"
+	EventCodeDaemonRestarted            EventCode = "daemon.restarted"
+	EventCodeDaemonVersionChanged       EventCode = "daemon.version_changed"
+	EventCodeDaemonCommunicationFailed  EventCode = "daemon.communication_failed"
+	EventCodeDaemonCommunicationResumed EventCode = "daemon.communication_resumed"
+	EventCodeDaemonMonitoringEnabled    EventCode = "daemon.monitoring_enabled"
+	EventCodeDaemonMonitoringDisabled   EventCode = "daemon.monitoring_disabled"
+	EventCodeDaemonLogErrors            EventCode = "daemon.log_errors"
+
+	EventCodeConfigChanged     EventCode = "config.changed"
+	EventCodeConfigRejected    EventCode = "config.rejected"
+	EventCodeConfigApplied     EventCode = "config.applied"
+	EventCodeConfigPushFailed  EventCode = "config.push_failed"
+	EventCodeConfigRolledBack  EventCode = "config.rolled_back"
+	EventCodeConfigConfirmed   EventCode = "config.confirmed"
+	EventCodeConfigLintFinding EventCode = "config.lint_finding"
+
+	EventCodeSubnetAdded  EventCode = "subnet.added"
+	EventCodeSubnetsAdded EventCode = "subnet.added_many"
+
+	EventCodeLeaseDeleted      EventCode = "lease.deleted"
+	EventCodeLeaseUpdated      EventCode = "lease.updated"
+	EventCodeLeaseDDNSResent   EventCode = "lease.ddns_resent"
+	EventCodeLeasesWiped       EventCode = "lease.wiped"
+	EventCodeLeaseActionFailed EventCode = "lease.action_failed"
+
+	EventCodeHAClockDrift        EventCode = "ha.clock_drift"
+	EventCodeHAClockDriftDropped EventCode = "ha.clock_drift_dropped"
+	EventCodeHAConfigMismatch    EventCode = "ha.config_mismatch"
+	EventCodeHAConfigMatched     EventCode = "ha.config_matched"
+
+	EventCodeExhaustionForecast EventCode = "exhaustion.forecast"
+
+	EventCodeAlertFired    EventCode = "alert.fired"
+	EventCodeAlertResolved EventCode = "alert.resolved"
+
+	EventCodeEventFlapping EventCode = "event.flapping"
+
+	EventCodeNotificationTest EventCode = "notification.test"
+)
+
+// Checks if the event code matches the filter. The filter is either the
+// exact code or the prefix of the codes followed by an asterisk, e.g.
+// daemon.* matches all codes of the events about the daemons. The empty
+// filter matches all codes.
+func MatchEventCode(code, filter string) bool {
+	if len(filter) == 0 {
+		return true
+	}
+	if strings.HasSuffix(filter, "*") {
+		return strings.HasPrefix(code, strings.TrimSuffix(filter, "*"))
+	}
+	return code == filter
+}
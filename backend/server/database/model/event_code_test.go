@@ -0,0 +1,18 @@
+package dbmodel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test matching the event codes against the filters.
+func TestMatchEventCode(t *testing.T) {
+	require.True(t, MatchEventCode("daemon.unreachable", ""))
+	require.True(t, MatchEventCode("daemon.unreachable", "daemon.unreachable"))
+	require.False(t, MatchEventCode("daemon.unreachable", "daemon.reachable"))
+	require.True(t, MatchEventCode("daemon.unreachable", "daemon.*"))
+	require.True(t, MatchEventCode("daemon.unreachable", "*"))
+	require.False(t, MatchEventCode("app.unreachable", "daemon.*"))
+	require.False(t, MatchEventCode("", "daemon.*"))
+}
@@ -2,6 +2,7 @@ package dbmodel
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	dbtest "isc.org/stork/server/database/test"
@@ -87,7 +88,7 @@ func TestEvent(t *testing.T) {
 	require.NotZero(t, uEv.ID)
 
 	// get all events
-	events, total, err := GetEventsByPage(db, 0, 10, EvInfo, nil, nil, nil, nil, "", SortDirAny)
+	events, total, err := GetEventsByPage(db, 0, 10, EvInfo, nil, nil, nil, nil, nil, "", SortDirAny)
 	require.NoError(t, err)
 	require.EqualValues(t, 4, total)
 	require.Len(t, events, 4)
@@ -102,7 +103,7 @@ func TestEvent(t *testing.T) {
 	}
 
 	// get warning and error events
-	events, total, err = GetEventsByPage(db, 0, 10, EvWarning, nil, nil, nil, nil, "", SortDirAny)
+	events, total, err = GetEventsByPage(db, 0, 10, EvWarning, nil, nil, nil, nil, nil, "", SortDirAny)
 	require.NoError(t, err)
 	require.EqualValues(t, 3, total)
 	require.Len(t, events, 3)
@@ -111,7 +112,7 @@ func TestEvent(t *testing.T) {
 	}
 
 	// get only error events
-	events, total, err = GetEventsByPage(db, 0, 10, EvError, nil, nil, nil, nil, "", SortDirAny)
+	events, total, err = GetEventsByPage(db, 0, 10, EvError, nil, nil, nil, nil, nil, "", SortDirAny)
 	require.NoError(t, err)
 	require.EqualValues(t, 1, total)
 	require.Len(t, events, 1)
@@ -121,7 +122,7 @@ func TestEvent(t *testing.T) {
 
 	// get daemon events
 	d := "dhcp4"
-	events, total, err = GetEventsByPage(db, 0, 10, EvInfo, &d, nil, nil, nil, "", SortDirAny)
+	events, total, err = GetEventsByPage(db, 0, 10, EvInfo, &d, nil, nil, nil, nil, "", SortDirAny)
 	require.NoError(t, err)
 	require.EqualValues(t, 1, total)
 	require.Len(t, events, 1)
@@ -131,7 +132,7 @@ func TestEvent(t *testing.T) {
 
 	// get app events
 	a := "kea"
-	events, total, err = GetEventsByPage(db, 0, 10, EvInfo, nil, &a, nil, nil, "", SortDirAny)
+	events, total, err = GetEventsByPage(db, 0, 10, EvInfo, nil, &a, nil, nil, nil, "", SortDirAny)
 	require.NoError(t, err)
 	require.EqualValues(t, 1, total)
 	require.Len(t, events, 1)
@@ -141,7 +142,7 @@ func TestEvent(t *testing.T) {
 
 	// get machine events
 	m := mEv.Relations.MachineID
-	events, total, err = GetEventsByPage(db, 0, 10, EvInfo, nil, nil, &m, nil, "", SortDirAny)
+	events, total, err = GetEventsByPage(db, 0, 10, EvInfo, nil, nil, &m, nil, nil, "", SortDirAny)
 	require.NoError(t, err)
 	require.EqualValues(t, 1, total)
 	require.Len(t, events, 1)
@@ -151,7 +152,7 @@ func TestEvent(t *testing.T) {
 
 	// get user events
 	u := uEv.Relations.UserID
-	events, total, err = GetEventsByPage(db, 0, 10, EvInfo, nil, nil, nil, &u, "", SortDirAny)
+	events, total, err = GetEventsByPage(db, 0, 10, EvInfo, nil, nil, nil, &u, nil, "", SortDirAny)
 	require.NoError(t, err)
 	require.EqualValues(t, 1, total)
 	require.Len(t, events, 1)
@@ -159,3 +160,188 @@ func TestEvent(t *testing.T) {
 	require.EqualValues(t, u, events[0].Relations.UserID)
 	require.EqualValues(t, "some warning event", events[0].Text)
 }
+
+// Test that the occurrences of the event are counted.
+func TestAddEventOccurrence(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	event := &Event{
+		CreatedAt:  now,
+		Text:       "some event",
+		Level:      EvWarning,
+		LastSeenAt: now,
+	}
+	err := AddEvent(db, event)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, event.Occurrences)
+
+	found, err := AddEventOccurrence(db, event.ID, now.Add(time.Minute))
+	require.NoError(t, err)
+	require.True(t, found)
+	found, err = AddEventOccurrence(db, event.ID, now.Add(2*time.Minute))
+	require.NoError(t, err)
+	require.True(t, found)
+
+	// The occurrence of the not existing event is not recorded.
+	found, err = AddEventOccurrence(db, event.ID+1, now.Add(2*time.Minute))
+	require.NoError(t, err)
+	require.False(t, found)
+
+	returned, err := GetEvent(db, event.ID)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, returned.Occurrences)
+	require.WithinDuration(t, now, returned.CreatedAt, 0)
+	require.WithinDuration(t, now.Add(2*time.Minute), returned.LastSeenAt, 0)
+}
+
+// Test getting the events by time range and deleting the old events.
+func TestGetAndDeleteOldEvents(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		for _, level := range []int{EvInfo, EvWarning, EvError} {
+			event := &Event{
+				CreatedAt: now.Add(-time.Duration(i) * 24 * time.Hour),
+				Text:      "some event",
+				Level:     level,
+			}
+			err := AddEvent(db, event)
+			require.NoError(t, err)
+		}
+	}
+
+	// Get the events from the last two days.
+	events, total, err := GetEventsByTimeRange(db, now.Add(-24*time.Hour), now.Add(time.Second), 0, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, events, 6)
+	require.EqualValues(t, 6, total)
+	require.Less(t, events[0].ID, events[1].ID)
+
+	// The page of the events.
+	page, total, err := GetEventsByTimeRange(db, now.Add(-24*time.Hour), now.Add(time.Second), 0, 4, 10)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	require.EqualValues(t, 6, total)
+	require.Equal(t, events[4].ID, page[0].ID)
+
+	// Only the warnings and errors.
+	events, total, err = GetEventsByTimeRange(db, now.Add(-24*time.Hour), now.Add(time.Second), EvWarning, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, events, 4)
+	require.EqualValues(t, 4, total)
+
+	// No events.
+	events, total, err = GetEventsByTimeRange(db, now.Add(time.Hour), now.Add(2*time.Hour), 0, 0, 0)
+	require.NoError(t, err)
+	require.Empty(t, events)
+	require.Zero(t, total)
+
+	// Get the info events older than a day.
+	events, err = GetEventsLastSeenBefore(db, EvInfo, now.Add(-time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	for _, event := range events {
+		require.Equal(t, EvInfo, event.Level)
+	}
+	events, err = GetEventsLastSeenBefore(db, EvInfo, now.Add(-time.Hour), 1)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+
+	// Delete them.
+	count, err := DeleteEvents(db, []int64{events[0].ID})
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	count, err = DeleteEvents(db, nil)
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	events, err = GetEventsLastSeenBefore(db, EvInfo, now.Add(-time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+
+	// The old event which recurred recently is not selected.
+	found, err := AddEventOccurrence(db, events[0].ID, now)
+	require.NoError(t, err)
+	require.True(t, found)
+	events, err = GetEventsLastSeenBefore(db, EvInfo, now.Add(-time.Hour), 10)
+	require.NoError(t, err)
+	require.Empty(t, events)
+}
+
+// Test getting the most recent events after the given event.
+func TestGetEventsAfter(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	var ids []int64
+	for i := 0; i < 5; i++ {
+		event := &Event{
+			Text:  "some event",
+			Level: EvInfo,
+		}
+		err := AddEvent(db, event)
+		require.NoError(t, err)
+		ids = append(ids, event.ID)
+	}
+
+	events, err := GetEventsAfter(db, ids[1], 10)
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	require.Equal(t, ids[2], events[0].ID)
+	require.Equal(t, ids[4], events[2].ID)
+
+	// The most recent events are returned when there are more.
+	events, err = GetEventsAfter(db, ids[0], 2)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, ids[3], events[0].ID)
+	require.Equal(t, ids[4], events[1].ID)
+
+	events, err = GetEventsAfter(db, ids[4], 10)
+	require.NoError(t, err)
+	require.Empty(t, events)
+}
+
+// Test that the events are filtered by code and that the payload is
+// stored.
+func TestGetEventsByCode(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	for _, code := range []EventCode{EventCodeDaemonUnreachable, EventCodeDaemonReachable, EventCodeAppUnreachable, ""} {
+		event := &Event{
+			Text:  "some event",
+			Level: EvWarning,
+			Code:  code,
+			Payload: EventPayload{
+				"code": string(code),
+				"port": 8000,
+			},
+		}
+		err := AddEvent(db, event)
+		require.NoError(t, err)
+	}
+
+	code := string(EventCodeDaemonUnreachable)
+	events, total, err := GetEventsByPage(db, 0, 10, EvInfo, nil, nil, nil, nil, &code, "", SortDirAny)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, total)
+	require.Equal(t, EventCodeDaemonUnreachable, events[0].Code)
+	require.Equal(t, code, events[0].Payload["code"])
+	require.EqualValues(t, 8000, events[0].Payload["port"])
+
+	code = "daemon.*"
+	_, total, err = GetEventsByPage(db, 0, 10, EvInfo, nil, nil, nil, nil, &code, "", SortDirAny)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, total)
+
+	// The underscore in the prefix is not a wildcard.
+	code = "daemon_*"
+	_, total, err = GetEventsByPage(db, 0, 10, EvInfo, nil, nil, nil, nil, &code, "", SortDirAny)
+	require.NoError(t, err)
+	require.Zero(t, total)
+}
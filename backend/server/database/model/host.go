@@ -45,6 +45,10 @@ type Host struct {
 
 	Hostname string
 
+	// Client classes and DHCP options assigned to the host.
+	ClientClasses []string `pg:",array"`
+	OptionData    []KeaConfigOptionData
+
 	HostIdentifiers []HostIdentifier
 	IPReservations  []IPReservation
 
@@ -426,6 +430,37 @@ func DeleteHost(db *pg.DB, hostID int64) error {
 	return err
 }
 
+// Dissociates an application from the host having a specified id. The
+// dbIface object may either be a pg.DB object or pg.Tx. The first returned
+// value indicates if any row was removed from the local_host table.
+func DeleteAppFromHost(dbIface interface{}, hostID int64, appID int64) (bool, error) {
+	tx, rollback, commit, err := dbops.Transaction(dbIface)
+	if err != nil {
+		err = pkgerrors.WithMessagef(err, "problem with starting transaction for deleting an app %d from the host %d",
+			appID, hostID)
+		return false, err
+	}
+	defer rollback()
+
+	localHost := &LocalHost{
+		AppID:  appID,
+		HostID: hostID,
+	}
+	rows, err := tx.Model(localHost).WherePK().Delete()
+	if err != nil && !errors.Is(err, pg.ErrNoRows) {
+		err = pkgerrors.Wrapf(err, "problem with deleting an app with id %d from the host with id %d",
+			appID, hostID)
+		return false, err
+	}
+	err = commit()
+	if err != nil {
+		err = pkgerrors.WithMessagef(err, "problem with committing transaction deleting an app %d from the host %d",
+			appID, hostID)
+		return false, err
+	}
+	return rows.RowsAffected() > 0, nil
+}
+
 // Delete associations of the apps with hosts for which the sequence number is
 // different than the specified sequence number. These are usually hosts which
 // are no longer present in any of the apps monitored by Stork. The dataSource
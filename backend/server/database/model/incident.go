@@ -0,0 +1,272 @@
+package dbmodel
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+	pkgerrors "github.com/pkg/errors"
+	dbops "isc.org/stork/server/database"
+)
+
+// States of the events and incidents.
+const (
+	EventStateOpen         = "open"
+	EventStateAcknowledged = "acknowledged"
+	EventStateResolved     = "resolved"
+)
+
+// Group of related events, e.g. the events about a daemon being unreachable
+// and the event about the daemon being reachable again. The key identifies
+// the problem and the objects it relates to. The text and relations are
+// taken from the first event of the incident, and the level is the highest
+// level of its events.
+type Incident struct {
+	ID               int64
+	CreatedAt        time.Time
+	Key              string
+	Text             string
+	Level            int `pg:",use_zero"`
+	Relations        *Relations
+	State            string
+	EventCount       int64
+	LastEventAt      time.Time
+	AcknowledgedByID int
+	AcknowledgedAt   time.Time
+	ResolvedAt       time.Time
+	Comment          string
+
+	Events []*Event `pg:"fk:incident_id"`
+}
+
+// Checks if the state of the event or incident is valid.
+func IsValidEventState(state string) bool {
+	switch state {
+	case EventStateOpen, EventStateAcknowledged, EventStateResolved:
+		return true
+	default:
+		return false
+	}
+}
+
+// Adds the stored event to the incident with the given key. The problem
+// event is added to the unresolved incident with the key or to the
+// incident resolved after reopenSince, which is reopened. Otherwise, new
+// incident is created. The recovery event resolves the unresolved incident
+// with the key and its events. The recovery event is ignored when there is
+// no such incident. It returns the incident to which the event has been
+// added or nil if the event has been ignored.
+func AddEventToIncident(db *pg.DB, event *Event, key string, recovery bool, reopenSince time.Time) (*Incident, error) {
+	tx, rollback, commit, err := dbops.Transaction(db)
+	if err != nil {
+		return nil, pkgerrors.WithMessagef(err, "problem with starting transaction for adding event %d to incident", event.ID)
+	}
+	defer rollback()
+
+	incident := &Incident{}
+	err = tx.Model(incident).
+		Where("key = ?", key).
+		WhereGroup(func(q *orm.Query) (*orm.Query, error) {
+			return q.Where("state != ?", EventStateResolved).WhereOr("resolved_at >= ?", reopenSince), nil
+		}).
+		OrderExpr("id DESC").
+		Limit(1).
+		For("UPDATE").
+		Select()
+	found := true
+	if err != nil {
+		if !errors.Is(err, pg.ErrNoRows) {
+			return nil, pkgerrors.Wrapf(err, "problem with getting incident %s", key)
+		}
+		found = false
+	}
+
+	at := event.CreatedAt
+	switch {
+	case recovery && (!found || incident.State == EventStateResolved):
+		return nil, nil
+	case recovery:
+		incident.State = EventStateResolved
+		incident.ResolvedAt = at
+		incident.EventCount++
+		incident.LastEventAt = at
+		_, err = tx.Model(incident).Column("state", "resolved_at", "event_count", "last_event_at").WherePK().Update()
+		if err != nil {
+			return nil, pkgerrors.Wrapf(err, "problem with resolving incident %d", incident.ID)
+		}
+		_, err = tx.Model((*Event)(nil)).
+			Set("state = ?", EventStateResolved).
+			Set("resolved_at = ?", at).
+			Where("incident_id = ?", incident.ID).
+			Where("state != ?", EventStateResolved).
+			Update()
+		if err != nil {
+			return nil, pkgerrors.Wrapf(err, "problem with resolving events of incident %d", incident.ID)
+		}
+		event.State = EventStateResolved
+		event.ResolvedAt = at
+	case !found:
+		incident = &Incident{
+			Key:         key,
+			Text:        event.Text,
+			Level:       event.Level,
+			Relations:   event.Relations,
+			State:       EventStateOpen,
+			EventCount:  1,
+			LastEventAt: at,
+		}
+		err = tx.Insert(incident)
+		if err != nil {
+			return nil, pkgerrors.Wrapf(err, "problem with inserting incident %s", key)
+		}
+		event.State = EventStateOpen
+	default:
+		if incident.State == EventStateResolved {
+			incident.State = EventStateOpen
+			incident.ResolvedAt = time.Time{}
+			incident.AcknowledgedByID = 0
+			incident.AcknowledgedAt = time.Time{}
+		}
+		if event.Level > incident.Level {
+			incident.Level = event.Level
+		}
+		incident.EventCount++
+		incident.LastEventAt = at
+		_, err = tx.Model(incident).
+			Column("state", "resolved_at", "acknowledged_by_id", "acknowledged_at", "level", "event_count", "last_event_at").
+			WherePK().
+			Update()
+		if err != nil {
+			return nil, pkgerrors.Wrapf(err, "problem with updating incident %d", incident.ID)
+		}
+		event.State = EventStateOpen
+	}
+
+	event.IncidentID = incident.ID
+	_, err = tx.Model(event).Column("state", "resolved_at", "incident_id").WherePK().Update()
+	if err != nil {
+		return nil, pkgerrors.Wrapf(err, "problem with adding event %d to incident %d", event.ID, incident.ID)
+	}
+	err = commit()
+	if err != nil {
+		return nil, pkgerrors.WithMessagef(err, "problem with committing event %d added to incident %d", event.ID, incident.ID)
+	}
+	return incident, nil
+}
+
+// Fetches a collection of incidents from the database. The offset and
+// limit specify the beginning of the page and the maximum size of the
+// page. Limit has to be greater than 0, otherwise error is returned.
+// The states restrict the returned incidents to the ones in these states.
+// All incidents are returned if the states are not specified. The most
+// recent incidents are returned first.
+func GetIncidentsByPage(db *pg.DB, offset, limit int64, states []string) ([]Incident, int64, error) {
+	if limit == 0 {
+		return nil, 0, pkgerrors.New("limit should be greater than 0")
+	}
+	var incidents []Incident
+	q := db.Model(&incidents)
+	if len(states) > 0 {
+		q = q.Where("state IN (?)", pg.In(states))
+	}
+	q = q.OrderExpr("id DESC").Offset(int(offset)).Limit(int(limit))
+	total, err := q.SelectAndCount()
+	if err != nil {
+		if errors.Is(err, pg.ErrNoRows) {
+			return []Incident{}, 0, nil
+		}
+		return nil, 0, pkgerrors.Wrapf(err, "problem with getting incidents")
+	}
+	return incidents, int64(total), nil
+}
+
+// Fetches the incident with its events by ID. It returns nil if the
+// incident does not exist.
+func GetIncident(db *pg.DB, id int64) (*Incident, error) {
+	incident := &Incident{}
+	err := db.Model(incident).
+		Relation("Events", func(q *orm.Query) (*orm.Query, error) {
+			return q.Order("id ASC"), nil
+		}).
+		Where("incident.id = ?", id).
+		Select()
+	if err != nil {
+		if errors.Is(err, pg.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, pkgerrors.Wrapf(err, "problem with getting incident %d", id)
+	}
+	return incident, nil
+}
+
+// Sets the state fields of the event or incident according to the new
+// state. The acknowledging user is only set for the acknowledged state.
+func setStateFields(state string, userID int, at time.Time, ackByID *int, ackAt, resolvedAt *time.Time) {
+	switch state {
+	case EventStateOpen:
+		*ackByID = 0
+		*ackAt = time.Time{}
+		*resolvedAt = time.Time{}
+	case EventStateAcknowledged:
+		*ackByID = userID
+		*ackAt = at
+		*resolvedAt = time.Time{}
+	case EventStateResolved:
+		*resolvedAt = at
+	}
+}
+
+// Changes the state of the incident and its events which are not in the
+// final state. The user is the one acknowledging the incident. The comment
+// is stored in the incident unless it is empty.
+func SetIncidentState(db *pg.DB, id int64, state string, userID int, comment string, at time.Time) error {
+	if !IsValidEventState(state) {
+		return pkgerrors.Errorf("invalid incident state %s", state)
+	}
+	tx, rollback, commit, err := dbops.Transaction(db)
+	if err != nil {
+		return pkgerrors.WithMessagef(err, "problem with starting transaction for setting state of incident %d", id)
+	}
+	defer rollback()
+
+	incident := &Incident{ID: id}
+	err = tx.Model(incident).WherePK().For("UPDATE").Select()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem with getting incident %d", id)
+	}
+	incident.State = state
+	setStateFields(state, userID, at, &incident.AcknowledgedByID, &incident.AcknowledgedAt, &incident.ResolvedAt)
+	if len(comment) > 0 {
+		incident.Comment = comment
+	}
+	_, err = tx.Model(incident).
+		Column("state", "acknowledged_by_id", "acknowledged_at", "resolved_at", "comment").
+		WherePK().
+		Update()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem with setting state of incident %d", id)
+	}
+
+	// The resolved events keep the acknowledging user.
+	event := &Event{State: state}
+	setStateFields(state, userID, at, &event.AcknowledgedByID, &event.AcknowledgedAt, &event.ResolvedAt)
+	columns := []string{"state", "resolved_at"}
+	if state != EventStateResolved {
+		columns = append(columns, "acknowledged_by_id", "acknowledged_at")
+	}
+	_, err = tx.Model(event).
+		Column(columns...).
+		Where("incident_id = ?", id).
+		Where("state IN (?)", pg.In([]string{EventStateOpen, EventStateAcknowledged})).
+		Update()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem with setting state of events of incident %d", id)
+	}
+
+	err = commit()
+	if err != nil {
+		err = pkgerrors.WithMessagef(err, "problem with committing state of incident %d", id)
+	}
+	return err
+}
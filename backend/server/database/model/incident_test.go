@@ -0,0 +1,219 @@
+package dbmodel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbops "isc.org/stork/server/database"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Adds the event with the given level and creation time to the database.
+func addTestEvent(t *testing.T, db *dbops.PgDB, level int, text string, createdAt time.Time) *Event {
+	event := &Event{
+		CreatedAt: createdAt,
+		Text:      text,
+		Level:     level,
+		Relations: &Relations{
+			DaemonID: 1,
+		},
+	}
+	err := AddEvent(db, event)
+	require.NoError(t, err)
+	return event
+}
+
+// Test that the problem events are grouped into incidents resolved by the
+// recovery events and that the recurring problems reopen the incidents.
+func TestAddEventToIncident(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	window := time.Hour
+
+	// The recovery without the problem is ignored.
+	event := addTestEvent(t, db, EvInfo, "reachable", now)
+	incident, err := AddEventToIncident(db, event, "key", true, now.Add(-window))
+	require.NoError(t, err)
+	require.Nil(t, incident)
+
+	// The problem opens the incident.
+	event = addTestEvent(t, db, EvWarning, "unreachable", now)
+	incident, err = AddEventToIncident(db, event, "key", false, now.Add(-window))
+	require.NoError(t, err)
+	require.NotNil(t, incident)
+	require.Equal(t, EventStateOpen, incident.State)
+	require.Equal(t, EventStateOpen, event.State)
+	require.Equal(t, incident.ID, event.IncidentID)
+	incidentID := incident.ID
+
+	// Another problem is added to the same incident.
+	event = addTestEvent(t, db, EvError, "unreachable", now.Add(time.Minute))
+	incident, err = AddEventToIncident(db, event, "key", false, now.Add(time.Minute-window))
+	require.NoError(t, err)
+	require.Equal(t, incidentID, incident.ID)
+
+	// The problem with a different key opens another incident.
+	event = addTestEvent(t, db, EvWarning, "restarted", now.Add(time.Minute))
+	incident, err = AddEventToIncident(db, event, "other", false, now.Add(time.Minute-window))
+	require.NoError(t, err)
+	require.NotEqual(t, incidentID, incident.ID)
+
+	// The recovery resolves the incident and its events.
+	recoveryTime := now.Add(2 * time.Minute)
+	event = addTestEvent(t, db, EvInfo, "reachable", recoveryTime)
+	incident, err = AddEventToIncident(db, event, "key", true, recoveryTime.Add(-window))
+	require.NoError(t, err)
+	require.Equal(t, incidentID, incident.ID)
+
+	returned, err := GetIncident(db, incidentID)
+	require.NoError(t, err)
+	require.Equal(t, EventStateResolved, returned.State)
+	require.Equal(t, "unreachable", returned.Text)
+	require.Equal(t, EvError, returned.Level)
+	require.EqualValues(t, 3, returned.EventCount)
+	require.WithinDuration(t, recoveryTime, returned.ResolvedAt, 0)
+	require.WithinDuration(t, recoveryTime, returned.LastEventAt, 0)
+	require.Len(t, returned.Events, 3)
+	for _, e := range returned.Events {
+		require.Equal(t, EventStateResolved, e.State)
+		require.WithinDuration(t, recoveryTime, e.ResolvedAt, 0)
+	}
+
+	// The recurring problem reopens the incident.
+	event = addTestEvent(t, db, EvWarning, "unreachable", now.Add(30*time.Minute))
+	incident, err = AddEventToIncident(db, event, "key", false, now.Add(30*time.Minute-window))
+	require.NoError(t, err)
+	require.Equal(t, incidentID, incident.ID)
+	require.Equal(t, EventStateOpen, incident.State)
+	require.True(t, incident.ResolvedAt.IsZero())
+
+	// Resolve it again.
+	event = addTestEvent(t, db, EvInfo, "reachable", now.Add(31*time.Minute))
+	_, err = AddEventToIncident(db, event, "key", true, now.Add(31*time.Minute-window))
+	require.NoError(t, err)
+
+	// The problem long after the resolution opens new incident.
+	event = addTestEvent(t, db, EvWarning, "unreachable", now.Add(3*time.Hour))
+	incident, err = AddEventToIncident(db, event, "key", false, now.Add(3*time.Hour-window))
+	require.NoError(t, err)
+	require.NotEqual(t, incidentID, incident.ID)
+
+	// Get the unresolved incidents.
+	incidents, total, err := GetIncidentsByPage(db, 0, 10, []string{EventStateOpen, EventStateAcknowledged})
+	require.NoError(t, err)
+	require.EqualValues(t, 2, total)
+	require.Len(t, incidents, 2)
+	require.Equal(t, incident.ID, incidents[0].ID)
+
+	incidents, total, err = GetIncidentsByPage(db, 0, 10, nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, total)
+	require.Len(t, incidents, 3)
+
+	_, _, err = GetIncidentsByPage(db, 0, 0, nil)
+	require.Error(t, err)
+}
+
+// Test that the state of the incident and its events is changed.
+func TestSetIncidentState(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	user := &SystemUser{
+		Login:    "user",
+		Email:    "user@example.org",
+		Lastname: "Smith",
+		Name:     "John",
+		Password: "pass",
+	}
+	_, err := CreateUser(db, user)
+	require.NoError(t, err)
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	var incident *Incident
+	for i := 0; i < 2; i++ {
+		event := addTestEvent(t, db, EvWarning, "unreachable", now)
+		incident, err = AddEventToIncident(db, event, "key", false, now.Add(-time.Hour))
+		require.NoError(t, err)
+	}
+
+	// Acknowledge the incident.
+	err = SetIncidentState(db, incident.ID, EventStateAcknowledged, user.ID, "working on it", now.Add(time.Minute))
+	require.NoError(t, err)
+	returned, err := GetIncident(db, incident.ID)
+	require.NoError(t, err)
+	require.Equal(t, EventStateAcknowledged, returned.State)
+	require.Equal(t, user.ID, returned.AcknowledgedByID)
+	require.WithinDuration(t, now.Add(time.Minute), returned.AcknowledgedAt, 0)
+	require.Equal(t, "working on it", returned.Comment)
+	require.Len(t, returned.Events, 2)
+	for _, e := range returned.Events {
+		require.Equal(t, EventStateAcknowledged, e.State)
+		require.Equal(t, user.ID, e.AcknowledgedByID)
+	}
+
+	// Resolve the incident. The comment and the acknowledging user are
+	// preserved.
+	err = SetIncidentState(db, incident.ID, EventStateResolved, 0, "", now.Add(2*time.Minute))
+	require.NoError(t, err)
+	returned, err = GetIncident(db, incident.ID)
+	require.NoError(t, err)
+	require.Equal(t, EventStateResolved, returned.State)
+	require.Equal(t, user.ID, returned.AcknowledgedByID)
+	require.WithinDuration(t, now.Add(2*time.Minute), returned.ResolvedAt, 0)
+	require.Equal(t, "working on it", returned.Comment)
+	for _, e := range returned.Events {
+		require.Equal(t, EventStateResolved, e.State)
+		require.Equal(t, user.ID, e.AcknowledgedByID)
+	}
+
+	// Reopen the incident.
+	err = SetIncidentState(db, incident.ID, EventStateOpen, 0, "again", now.Add(3*time.Minute))
+	require.NoError(t, err)
+	returned, err = GetIncident(db, incident.ID)
+	require.NoError(t, err)
+	require.Equal(t, EventStateOpen, returned.State)
+	require.Zero(t, returned.AcknowledgedByID)
+	require.True(t, returned.ResolvedAt.IsZero())
+	require.Equal(t, "again", returned.Comment)
+
+	// Invalid state.
+	err = SetIncidentState(db, incident.ID, "foo", 0, "", now)
+	require.Error(t, err)
+}
+
+// Test that the state of the single event is changed.
+func TestSetEventState(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	event := addTestEvent(t, db, EvInfo, "added", now)
+
+	err := SetEventState(db, event.ID, EventStateAcknowledged, 1, "seen", now.Add(time.Minute))
+	require.NoError(t, err)
+	returned, err := GetEvent(db, event.ID)
+	require.NoError(t, err)
+	require.Equal(t, EventStateAcknowledged, returned.State)
+	require.Equal(t, 1, returned.AcknowledgedByID)
+	require.WithinDuration(t, now.Add(time.Minute), returned.AcknowledgedAt, 0)
+	require.Equal(t, "seen", returned.Comment)
+
+	err = SetEventState(db, event.ID, EventStateResolved, 0, "", now.Add(2*time.Minute))
+	require.NoError(t, err)
+	returned, err = GetEvent(db, event.ID)
+	require.NoError(t, err)
+	require.Equal(t, EventStateResolved, returned.State)
+	require.Equal(t, 1, returned.AcknowledgedByID)
+	require.Equal(t, "seen", returned.Comment)
+
+	err = SetEventState(db, event.ID, "foo", 0, "", now)
+	require.Error(t, err)
+
+	returned, err = GetEvent(db, event.ID+1)
+	require.NoError(t, err)
+	require.Nil(t, returned)
+}
@@ -126,6 +126,8 @@ func NewSubnetFromKea(rawSubnet *map[string]interface{}) (*Subnet, error) {
 func NewHostFromKeaConfigReservation(reservation KeaConfigReservation) (*Host, error) {
 	var host Host
 	host.Hostname = reservation.Hostname
+	host.ClientClasses = reservation.ClientClasses
+	host.OptionData = reservation.OptionData
 	structType := reflect.TypeOf(reservation)
 	value := reflect.ValueOf(reservation)
 
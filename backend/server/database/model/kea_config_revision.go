@@ -0,0 +1,171 @@
+package dbmodel
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	pkgerrors "github.com/pkg/errors"
+	dbops "isc.org/stork/server/database"
+)
+
+// Distinct version of the Kea daemon's configuration. A new revision is
+// stored whenever the configuration fetched from the daemon differs from
+// the configuration of its latest revision.
+type KeaConfigRevision struct {
+	ID         int64
+	CreatedAt  time.Time
+	DaemonID   int64
+	ConfigHash string
+	Config     *KeaConfig
+}
+
+// Stores the current configuration of the Kea daemon as a new revision
+// if it differs from the latest revision of the daemon's configuration.
+// The configurations are compared by their hashes or, if the hashes are
+// not available, by their contents. The dbIface object may either be a
+// pg.DB object or pg.Tx. It returns the added revision, or nil if the
+// configuration has not changed, and the revision preceding the added
+// one, or nil if there is no such revision. The previous revision is
+// returned without the configuration.
+func AddKeaConfigRevisionIfChanged(dbIface interface{}, daemon *Daemon) (*KeaConfigRevision, *KeaConfigRevision, error) {
+	if daemon.KeaDaemon == nil || daemon.KeaDaemon.Config == nil {
+		return nil, nil, nil
+	}
+	tx, rollback, commit, err := dbops.Transaction(dbIface)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rollback()
+
+	previous := &KeaConfigRevision{}
+	q := tx.Model(previous).
+		Where("daemon_id = ?", daemon.ID).
+		OrderExpr("id DESC").
+		Limit(1)
+	if len(daemon.KeaDaemon.ConfigHash) > 0 {
+		q = q.ExcludeColumn("config")
+	}
+	err = q.Select()
+	switch {
+	case errors.Is(err, pg.ErrNoRows):
+		previous = nil
+	case err != nil:
+		return nil, nil, pkgerrors.Wrapf(err, "problem with getting latest config revision of daemon %d", daemon.ID)
+	case len(daemon.KeaDaemon.ConfigHash) > 0 && previous.ConfigHash == daemon.KeaDaemon.ConfigHash:
+		return nil, nil, nil
+	case len(daemon.KeaDaemon.ConfigHash) == 0 && previous.Config != nil &&
+		reflect.DeepEqual(normalizeKeaConfig(previous.Config), normalizeKeaConfig(daemon.KeaDaemon.Config)):
+		return nil, nil, nil
+	}
+	if previous != nil {
+		previous.Config = nil
+	}
+
+	revision := &KeaConfigRevision{
+		DaemonID:   daemon.ID,
+		ConfigHash: daemon.KeaDaemon.ConfigHash,
+		Config:     daemon.KeaDaemon.Config,
+	}
+	err = tx.Insert(revision)
+	if err != nil {
+		return nil, nil, pkgerrors.Wrapf(err, "problem with inserting config revision of daemon %d", daemon.ID)
+	}
+	err = commit()
+	if err != nil {
+		return nil, nil, pkgerrors.WithMessagef(err, "problem with committing config revision of daemon %d", daemon.ID)
+	}
+	return revision, previous, nil
+}
+
+// Converts the configuration to the form returned by the JSON decoder,
+// so the configurations read from the database and received from Kea
+// can be compared.
+func normalizeKeaConfig(config *KeaConfig) interface{} {
+	var normalized interface{}
+	bytes, err := json.Marshal(config)
+	if err != nil {
+		return config
+	}
+	if err = json.Unmarshal(bytes, &normalized); err != nil {
+		return config
+	}
+	return normalized
+}
+
+// Fetches a collection of the config revisions of the daemon from the
+// database, from the newest to the oldest. The offset and limit specify
+// the beginning of the page and the maximum size of the page. The
+// configurations are not fetched. It returns the revisions and the total
+// number of the revisions of the daemon.
+func GetKeaConfigRevisionsByPage(db *pg.DB, daemonID, offset, limit int64) ([]KeaConfigRevision, int64, error) {
+	if limit == 0 {
+		return nil, 0, pkgerrors.New("limit should be greater than 0")
+	}
+	var revisions []KeaConfigRevision
+	total, err := db.Model(&revisions).
+		ExcludeColumn("config").
+		Where("daemon_id = ?", daemonID).
+		OrderExpr("id DESC").
+		Offset(int(offset)).
+		Limit(int(limit)).
+		SelectAndCount()
+	if err != nil {
+		if errors.Is(err, pg.ErrNoRows) {
+			return []KeaConfigRevision{}, 0, nil
+		}
+		return nil, 0, pkgerrors.Wrapf(err, "problem with getting config revisions of daemon %d", daemonID)
+	}
+	return revisions, int64(total), nil
+}
+
+// Fetches the config revision with the configuration by ID. It returns
+// nil if the revision does not exist.
+func GetKeaConfigRevisionByID(db *pg.DB, id int64) (*KeaConfigRevision, error) {
+	revision := &KeaConfigRevision{}
+	err := db.Model(revision).Where("id = ?", id).Select()
+	if errors.Is(err, pg.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, pkgerrors.Wrapf(err, "problem with getting config revision %d", id)
+	}
+	return revision, nil
+}
+
+// Fetches the config revision of the same daemon preceding the given
+// revision, with the configuration. It returns nil if the given revision
+// is the first revision of the daemon.
+func GetPreviousKeaConfigRevision(db *pg.DB, revision *KeaConfigRevision) (*KeaConfigRevision, error) {
+	previous := &KeaConfigRevision{}
+	err := db.Model(previous).
+		Where("daemon_id = ?", revision.DaemonID).
+		Where("id < ?", revision.ID).
+		OrderExpr("id DESC").
+		Limit(1).
+		Select()
+	if errors.Is(err, pg.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, pkgerrors.Wrapf(err, "problem with getting config revision preceding revision %d", revision.ID)
+	}
+	return previous, nil
+}
+
+// Fetches the latest config revision of the daemon, with the
+// configuration. It returns nil if the daemon has no revisions.
+func GetLatestKeaConfigRevision(db *pg.DB, daemonID int64) (*KeaConfigRevision, error) {
+	revision := &KeaConfigRevision{}
+	err := db.Model(revision).
+		Where("daemon_id = ?", daemonID).
+		OrderExpr("id DESC").
+		Limit(1).
+		Select()
+	if errors.Is(err, pg.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, pkgerrors.Wrapf(err, "problem with getting latest config revision of daemon %d", daemonID)
+	}
+	return revision, nil
+}
@@ -0,0 +1,117 @@
+package dbmodel
+
+import (
+	"testing"
+
+	require "github.com/stretchr/testify/require"
+	dbops "isc.org/stork/server/database"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Adds a machine and Kea app with the DHCPv4 daemon to the database.
+func addTestKeaDaemon(t *testing.T, db *dbops.PgDB) *Daemon {
+	m := &Machine{
+		Address:   "localhost",
+		AgentPort: 8080,
+	}
+	err := AddMachine(db, m)
+	require.NoError(t, err)
+
+	app := &App{
+		MachineID: m.ID,
+		Type:      AppTypeKea,
+		Daemons: []*Daemon{
+			NewKeaDaemon(DaemonNameDHCPv4, true),
+		},
+	}
+	_, err = AddApp(db, app)
+	require.NoError(t, err)
+	return app.Daemons[0]
+}
+
+// Test that the config revisions are added only when the configuration
+// changes and that they can be fetched.
+func TestAddKeaConfigRevisionIfChanged(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	daemon := addTestKeaDaemon(t, db)
+
+	// No configuration, no revision.
+	added, previous, err := AddKeaConfigRevisionIfChanged(db, daemon)
+	require.NoError(t, err)
+	require.Nil(t, added)
+	require.Nil(t, previous)
+
+	// The first revision.
+	err = daemon.SetConfigFromJSON(`{"Dhcp4": {"valid-lifetime": 1000}}`)
+	require.NoError(t, err)
+	added, previous, err = AddKeaConfigRevisionIfChanged(db, daemon)
+	require.NoError(t, err)
+	require.NotNil(t, added)
+	require.Nil(t, previous)
+	firstID := added.ID
+
+	// The same configuration.
+	added, previous, err = AddKeaConfigRevisionIfChanged(db, daemon)
+	require.NoError(t, err)
+	require.Nil(t, added)
+	require.Nil(t, previous)
+
+	// The changed configuration.
+	err = daemon.SetConfigFromJSON(`{"Dhcp4": {"valid-lifetime": 2000}}`)
+	require.NoError(t, err)
+	added, previous, err = AddKeaConfigRevisionIfChanged(db, daemon)
+	require.NoError(t, err)
+	require.NotNil(t, added)
+	require.NotNil(t, previous)
+	require.Equal(t, firstID, previous.ID)
+	secondID := added.ID
+
+	// The configurations without hashes are compared by contents.
+	err = daemon.SetConfig(daemon.KeaDaemon.Config)
+	require.NoError(t, err)
+	added, _, err = AddKeaConfigRevisionIfChanged(db, daemon)
+	require.NoError(t, err)
+	require.Nil(t, added)
+
+	// The revisions are returned from the newest.
+	revisions, total, err := GetKeaConfigRevisionsByPage(db, daemon.ID, 0, 10)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, total)
+	require.Len(t, revisions, 2)
+	require.Equal(t, secondID, revisions[0].ID)
+	require.Equal(t, firstID, revisions[1].ID)
+	require.Nil(t, revisions[0].Config)
+	require.NotEmpty(t, revisions[0].ConfigHash)
+
+	revision, err := GetKeaConfigRevisionByID(db, secondID)
+	require.NoError(t, err)
+	require.NotNil(t, revision)
+	require.NotNil(t, revision.Config)
+	require.Equal(t, daemon.ID, revision.DaemonID)
+
+	previousRevision, err := GetPreviousKeaConfigRevision(db, revision)
+	require.NoError(t, err)
+	require.NotNil(t, previousRevision)
+	require.Equal(t, firstID, previousRevision.ID)
+	require.NotNil(t, previousRevision.Config)
+
+	latestRevision, err := GetLatestKeaConfigRevision(db, daemon.ID)
+	require.NoError(t, err)
+	require.NotNil(t, latestRevision)
+	require.Equal(t, secondID, latestRevision.ID)
+	require.NotNil(t, latestRevision.Config)
+
+	latestRevision, err = GetLatestKeaConfigRevision(db, daemon.ID+1)
+	require.NoError(t, err)
+	require.Nil(t, latestRevision)
+
+	previousRevision, err = GetPreviousKeaConfigRevision(db, previousRevision)
+	require.NoError(t, err)
+	require.Nil(t, previousRevision)
+
+	revision, err = GetKeaConfigRevisionByID(db, secondID+1)
+	require.NoError(t, err)
+	require.Nil(t, revision)
+}
@@ -146,6 +146,22 @@ func TestNewHostFromKea(t *testing.T) {
 			"3000:2::/64",
 		},
 		"hostname": "hostname.example.org",
+		"client-classes": []interface{}{
+			"foo",
+			"bar",
+		},
+		"option-data": []interface{}{
+			map[string]interface{}{
+				"code":       float64(23),
+				"data":       "2001:db8:1::53",
+				"csv-format": true,
+			},
+			map[string]interface{}{
+				"name":  "domain-search",
+				"space": "dhcp6",
+				"data":  "example.org",
+			},
+		},
 	}
 
 	parsedHost, err := NewHostFromKea(&rawHost)
@@ -160,6 +176,14 @@ func TestNewHostFromKea(t *testing.T) {
 	require.Equal(t, "3000:1::/64", parsedHost.IPReservations[2].Address)
 	require.Equal(t, "3000:2::/64", parsedHost.IPReservations[3].Address)
 	require.Equal(t, "hostname.example.org", parsedHost.Hostname)
+	require.Equal(t, []string{"foo", "bar"}, parsedHost.ClientClasses)
+	require.Len(t, parsedHost.OptionData, 2)
+	require.EqualValues(t, 23, parsedHost.OptionData[0].Code)
+	require.Equal(t, "2001:db8:1::53", parsedHost.OptionData[0].Data)
+	require.NotNil(t, parsedHost.OptionData[0].CSVFormat)
+	require.True(t, *parsedHost.OptionData[0].CSVFormat)
+	require.Equal(t, "domain-search", parsedHost.OptionData[1].Name)
+	require.Equal(t, "dhcp6", parsedHost.OptionData[1].Space)
 }
 
 // Test that log targets can be created from parsed Kea logger config.
@@ -11,6 +11,20 @@ type KeaConfigReservation struct {
 	IPAddresses []string `mapstructure:"ip-addresses" json:"ip-addresses,omitempty"`
 	Prefixes    []string `mapstructure:"prefixes" json:"prefixes,omitempty"`
 	Hostname    string   `mapstructure:"hostname" json:"hostname,omitempty"`
+
+	ClientClasses []string              `mapstructure:"client-classes" json:"client-classes,omitempty"`
+	OptionData    []KeaConfigOptionData `mapstructure:"option-data" json:"option-data,omitempty"`
+}
+
+// Represents DHCP option data within Kea configuration. The option is
+// identified by the code or the name, and the option space.
+type KeaConfigOptionData struct {
+	Code       int64  `mapstructure:"code" json:"code,omitempty"`
+	Name       string `mapstructure:"name" json:"name,omitempty"`
+	Space      string `mapstructure:"space" json:"space,omitempty"`
+	Data       string `mapstructure:"data" json:"data"`
+	CSVFormat  *bool  `mapstructure:"csv-format" json:"csv-format,omitempty"`
+	AlwaysSend bool   `mapstructure:"always-send" json:"always-send,omitempty"`
 }
 
 // Represents address pool structure within Kea configuration.
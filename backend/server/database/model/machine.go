@@ -9,6 +9,39 @@ import (
 	pkgerrors "github.com/pkg/errors"
 )
 
+// Network interface of the machine with its addresses.
+type NetworkInterface struct {
+	Name            string
+	HardwareAddress string
+	Mtu             int64
+	Flags           []string
+	Addresses       []string
+}
+
+// Usage of the filesystem holding a directory with the apps' data,
+// e.g. Kea lease files or BIND 9 zone files.
+type FilesystemUsage struct {
+	Path        string
+	Kind        string
+	Fstype      string
+	Total       int64
+	Used        int64
+	UsedPercent float64
+	Error       string
+}
+
+// Database backend configured in Kea and its reachability from the
+// machine.
+type DatabaseBackend struct {
+	Kind      string
+	Type      string
+	Host      string
+	Port      int64
+	Name      string
+	Reachable bool
+	Error     string
+}
+
 // Part of machine table in database that describes state of machine. In DB it is stored as JSONB.
 type MachineState struct {
 	AgentVersion         string
@@ -27,6 +60,13 @@ type MachineState struct {
 	VirtualizationSystem string
 	VirtualizationRole   string
 	HostID               string
+	Interfaces           []NetworkInterface
+	Filesystems          []FilesystemUsage
+	Databases            []DatabaseBackend
+	ClockSkew            int64 // agent's clock minus server's clock in milliseconds
+	ClockRoundTrip       int64 // in milliseconds
+	ClockSyncStatus      string
+	ClockCheckedAt       time.Time
 }
 
 // Represents a machine held in machine table in the database.
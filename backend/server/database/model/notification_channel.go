@@ -0,0 +1,172 @@
+package dbmodel
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	pkgerrors "github.com/pkg/errors"
+)
+
+// Types of the notification channels.
+const (
+	NotificationChannelWebhook = "webhook"
+	NotificationChannelEmail   = "email"
+	NotificationChannelSyslog  = "syslog"
+)
+
+// Configuration of the channel posting the events as JSON to the URL.
+// When the secret is set, the payload is signed with HMAC-SHA256 and the
+// signature is sent in the X-Stork-Signature header. The failed posts are
+// repeated up to the given number of retries.
+type WebhookConfig struct {
+	URL     string
+	Secret  string `json:",omitempty"`
+	Retries int    `json:",omitempty"`
+}
+
+// Configuration of the channel sending the events as emails via the SMTP
+// server. The authentication is used when the username is set.
+type EmailConfig struct {
+	Host     string
+	Port     int
+	Username string `json:",omitempty"`
+	Password string `json:",omitempty"`
+	From     string
+	To       []string
+}
+
+// Configuration of the channel sending the events as RFC 5424 messages to
+// the syslog server over UDP or TCP.
+type SyslogConfig struct {
+	Network  string
+	Address  string
+	Facility int `json:",omitempty"`
+}
+
+// Channel through which the events are sent outside of Stork. The events
+// are sent when their level is at least the channel's level and their
+// relations match all non-zero relations in the filter. The channel also
+// records the state of the deliveries.
+type NotificationChannel struct {
+	ID        int64
+	CreatedAt time.Time
+	Name      string
+	Type      string
+	Enabled   bool `pg:",use_zero"`
+	Level     int  `pg:",use_zero"`
+	Filter    *Relations
+
+	Webhook *WebhookConfig
+	Email   *EmailConfig
+	Syslog  *SyslogConfig
+
+	DeliveredCount  int64
+	FailedCount     int64
+	LastDeliveredAt time.Time
+	LastFailedAt    time.Time
+	LastError       string
+}
+
+// Returns a boolean value indicating if the event should be sent through
+// the channel.
+func (c *NotificationChannel) AcceptsEvent(event *Event) bool {
+	if !c.Enabled || event.Level < c.Level {
+		return false
+	}
+	if c.Filter == nil {
+		return true
+	}
+	relations := event.Relations
+	if relations == nil {
+		relations = &Relations{}
+	}
+	return (c.Filter.MachineID == 0 || relations.MachineID == c.Filter.MachineID) &&
+		(c.Filter.AppID == 0 || relations.AppID == c.Filter.AppID) &&
+		(c.Filter.SubnetID == 0 || relations.SubnetID == c.Filter.SubnetID) &&
+		(c.Filter.DaemonID == 0 || relations.DaemonID == c.Filter.DaemonID) &&
+		(c.Filter.UserID == 0 || relations.UserID == c.Filter.UserID) &&
+		(c.Filter.RuleID == 0 || relations.RuleID == c.Filter.RuleID)
+}
+
+// Adds new notification channel to the database.
+func AddNotificationChannel(db *pg.DB, channel *NotificationChannel) error {
+	err := db.Insert(channel)
+	if err != nil {
+		err = pkgerrors.Wrapf(err, "problem with inserting notification channel %s", channel.Name)
+	}
+	return err
+}
+
+// Updates the configuration of the notification channel in the database.
+// The delivery state of the channel is preserved.
+func UpdateNotificationChannel(db *pg.DB, channel *NotificationChannel) error {
+	_, err := db.Model(channel).
+		Column("name", "type", "enabled", "level", "filter", "webhook", "email", "syslog").
+		WherePK().
+		Update()
+	if err != nil {
+		err = pkgerrors.Wrapf(err, "problem with updating notification channel %d", channel.ID)
+	}
+	return err
+}
+
+// Fetches the notification channel by ID. It returns nil if the channel
+// does not exist.
+func GetNotificationChannel(db *pg.DB, id int64) (*NotificationChannel, error) {
+	channel := &NotificationChannel{}
+	err := db.Model(channel).Where("id = ?", id).Select()
+	if err != nil {
+		if errors.Is(err, pg.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, pkgerrors.Wrapf(err, "problem with getting notification channel %d", id)
+	}
+	return channel, nil
+}
+
+// Fetches the notification channels ordered by ID. If enabledOnly is true
+// only the enabled channels are returned.
+func GetNotificationChannels(db *pg.DB, enabledOnly bool) ([]*NotificationChannel, error) {
+	channels := []*NotificationChannel{}
+	q := db.Model(&channels)
+	if enabledOnly {
+		q = q.Where("enabled = TRUE")
+	}
+	err := q.OrderExpr("id ASC").Select()
+	if err != nil {
+		return nil, pkgerrors.Wrapf(err, "problem with getting notification channels")
+	}
+	return channels, nil
+}
+
+// Deletes the notification channel.
+func DeleteNotificationChannel(db *pg.DB, id int64) error {
+	channel := &NotificationChannel{
+		ID: id,
+	}
+	_, err := db.Model(channel).WherePK().Delete()
+	if err != nil {
+		err = pkgerrors.Wrapf(err, "problem with deleting notification channel %d", id)
+	}
+	return err
+}
+
+// Records the result of sending the event through the notification channel.
+// The nil deliveryErr indicates the successful delivery.
+func RecordNotificationDelivery(db *pg.DB, id int64, at time.Time, deliveryErr error) error {
+	q := db.Model((*NotificationChannel)(nil)).Where("id = ?", id)
+	if deliveryErr == nil {
+		q = q.Set("delivered_count = delivered_count + 1").
+			Set("last_delivered_at = ?", at)
+	} else {
+		q = q.Set("failed_count = failed_count + 1").
+			Set("last_failed_at = ?", at).
+			Set("last_error = ?", deliveryErr.Error())
+	}
+	_, err := q.Update()
+	if err != nil {
+		err = pkgerrors.Wrapf(err, "problem with recording delivery through notification channel %d", id)
+	}
+	return err
+}
@@ -0,0 +1,137 @@
+package dbmodel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Test that the channel accepts the events matching its level and filter.
+func TestNotificationChannelAcceptsEvent(t *testing.T) {
+	channel := &NotificationChannel{
+		Enabled: true,
+	}
+	event := &Event{
+		Level: EvWarning,
+		Relations: &Relations{
+			SubnetID: 1,
+			RuleID:   2,
+		},
+	}
+	require.True(t, channel.AcceptsEvent(event))
+	require.True(t, channel.AcceptsEvent(&Event{}))
+
+	channel.Level = EvError
+	require.False(t, channel.AcceptsEvent(event))
+	channel.Level = EvWarning
+	require.True(t, channel.AcceptsEvent(event))
+
+	channel.Filter = &Relations{
+		RuleID: 2,
+	}
+	require.True(t, channel.AcceptsEvent(event))
+	require.False(t, channel.AcceptsEvent(&Event{Level: EvError}))
+
+	channel.Filter.SubnetID = 3
+	require.False(t, channel.AcceptsEvent(event))
+
+	channel.Filter = nil
+	channel.Enabled = false
+	require.False(t, channel.AcceptsEvent(event))
+}
+
+// Test that the notification channels are added, updated, fetched and
+// deleted and that their deliveries are recorded.
+func TestNotificationChannels(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	webhook := &NotificationChannel{
+		Name:    "hook",
+		Type:    NotificationChannelWebhook,
+		Enabled: true,
+		Level:   EvWarning,
+		Filter: &Relations{
+			SubnetID: 5,
+		},
+		Webhook: &WebhookConfig{
+			URL:     "https://example.org/hook",
+			Secret:  "secret",
+			Retries: 3,
+		},
+	}
+	err := AddNotificationChannel(db, webhook)
+	require.NoError(t, err)
+	require.NotZero(t, webhook.ID)
+
+	syslog := &NotificationChannel{
+		Name: "syslog",
+		Type: NotificationChannelSyslog,
+		Syslog: &SyslogConfig{
+			Network: "tcp",
+			Address: "192.0.2.1:514",
+		},
+	}
+	err = AddNotificationChannel(db, syslog)
+	require.NoError(t, err)
+
+	returned, err := GetNotificationChannel(db, webhook.ID)
+	require.NoError(t, err)
+	require.NotNil(t, returned)
+	require.Equal(t, "hook", returned.Name)
+	require.True(t, returned.Enabled)
+	require.Equal(t, EvWarning, returned.Level)
+	require.NotNil(t, returned.Filter)
+	require.EqualValues(t, 5, returned.Filter.SubnetID)
+	require.NotNil(t, returned.Webhook)
+	require.Equal(t, "https://example.org/hook", returned.Webhook.URL)
+	require.Equal(t, "secret", returned.Webhook.Secret)
+	require.Equal(t, 3, returned.Webhook.Retries)
+	require.Nil(t, returned.Email)
+	require.Nil(t, returned.Syslog)
+
+	channels, err := GetNotificationChannels(db, false)
+	require.NoError(t, err)
+	require.Len(t, channels, 2)
+	channels, err = GetNotificationChannels(db, true)
+	require.NoError(t, err)
+	require.Len(t, channels, 1)
+	require.Equal(t, webhook.ID, channels[0].ID)
+
+	// Record the deliveries.
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	err = RecordNotificationDelivery(db, webhook.ID, now, nil)
+	require.NoError(t, err)
+	err = RecordNotificationDelivery(db, webhook.ID, now.Add(time.Minute), nil)
+	require.NoError(t, err)
+	err = RecordNotificationDelivery(db, webhook.ID, now.Add(2*time.Minute), errors.New("timeout"))
+	require.NoError(t, err)
+
+	// Updating the configuration preserves the delivery state.
+	webhook.Enabled = false
+	webhook.Level = EvInfo
+	webhook.Filter = nil
+	err = UpdateNotificationChannel(db, webhook)
+	require.NoError(t, err)
+
+	returned, err = GetNotificationChannel(db, webhook.ID)
+	require.NoError(t, err)
+	require.False(t, returned.Enabled)
+	require.Equal(t, EvInfo, returned.Level)
+	require.Nil(t, returned.Filter)
+	require.EqualValues(t, 2, returned.DeliveredCount)
+	require.EqualValues(t, 1, returned.FailedCount)
+	require.WithinDuration(t, now.Add(time.Minute), returned.LastDeliveredAt, 0)
+	require.WithinDuration(t, now.Add(2*time.Minute), returned.LastFailedAt, 0)
+	require.Equal(t, "timeout", returned.LastError)
+
+	// Delete the channel.
+	err = DeleteNotificationChannel(db, webhook.ID)
+	require.NoError(t, err)
+	returned, err = GetNotificationChannel(db, webhook.ID)
+	require.NoError(t, err)
+	require.Nil(t, returned)
+}
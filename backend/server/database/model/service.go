@@ -62,6 +62,31 @@ type BaseHAService struct {
 	SecondaryUnackedClientsLeft int64
 	PrimaryAnalyzedPackets      int64
 	SecondaryAnalyzedPackets    int64
+	PeersClockSkew              *int64
+	ConfigDiffs                 []HAConfigDiff
+	ConfigCheckedAt             time.Time
+}
+
+// Kinds of the differences between the configurations of the primary
+// server and its HA peer.
+const (
+	HAConfigDiffPrimaryOnly = "primary-only"
+	HAConfigDiffPeerOnly    = "peer-only"
+	HAConfigDiffDifferent   = "different"
+)
+
+// Single difference between the configurations of the primary server and
+// its HA peer, e.g. the subnet configured on the primary server only. The
+// path identifies the element in the configuration and the values are the
+// element's values on the primary server and the peer. The differences
+// are stored in the ha_service table as JSONB.
+type HAConfigDiff struct {
+	DaemonID   int64       `json:"daemonId"`
+	Element    string      `json:"element"`
+	Difference string      `json:"difference"`
+	Path       string      `json:"path"`
+	Primary    interface{} `json:"primary,omitempty"`
+	Peer       interface{} `json:"peer,omitempty"`
 }
 
 // A structure reflecting all SQL tables holding information about the
@@ -250,7 +275,9 @@ func UpdateBaseService(dbIface interface{}, service *BaseService) error {
 }
 
 // Updates HA specific information for a service. It only affects the contents of
-// the ha_service table.
+// the ha_service table. The clock skew of the HA peers and the differences
+// between their configurations are not updated; they are stored with
+// UpdateHAServicePeersClockSkew and UpdateHAServiceConfigDiffs.
 func UpdateBaseHAService(dbIface interface{}, service *BaseHAService) error {
 	tx, rollback, commit, err := dbops.Transaction(dbIface)
 	if err != nil {
@@ -258,7 +285,7 @@ func UpdateBaseHAService(dbIface interface{}, service *BaseHAService) error {
 	}
 	defer rollback()
 
-	err = tx.Update(service)
+	_, err = tx.Model(service).ExcludeColumn("peers_clock_skew", "config_diffs", "config_checked_at").WherePK().Update()
 	if err != nil {
 		err = pkgerrors.Wrapf(err, "problem with updating the HA information for service with id %d",
 			service.ServiceID)
@@ -273,6 +300,44 @@ func UpdateBaseHAService(dbIface interface{}, service *BaseHAService) error {
 	return err
 }
 
+// Updates the clock skew between the HA peers of the service. Other
+// columns are not updated, so the HA status stored concurrently by the
+// status puller is not overwritten with the stale values.
+func UpdateHAServicePeersClockSkew(db *pg.DB, service *BaseHAService) error {
+	_, err := db.Model(service).Column("peers_clock_skew").WherePK().Update()
+	if err != nil {
+		err = pkgerrors.Wrapf(err, "problem with updating the clock skew of the HA peers for service with id %d",
+			service.ServiceID)
+	}
+	return err
+}
+
+// Updates the differences between the configurations of the HA peers of
+// the service and the time when they were checked. Other columns are not
+// updated, so the HA status stored concurrently by the status puller is
+// not overwritten with the stale values.
+func UpdateHAServiceConfigDiffs(dbIface interface{}, service *BaseHAService) error {
+	tx, rollback, commit, err := dbops.Transaction(dbIface)
+	if err != nil {
+		return err
+	}
+	defer rollback()
+
+	_, err = tx.Model(service).Column("config_diffs", "config_checked_at").WherePK().Update()
+	if err != nil {
+		err = pkgerrors.Wrapf(err, "problem with updating the HA configuration differences for service with id %d",
+			service.ServiceID)
+		return err
+	}
+
+	err = commit()
+	if err != nil {
+		err = pkgerrors.WithMessagef(err, "problem with committing HA configuration differences for service with id %d after update",
+			service.ServiceID)
+	}
+	return err
+}
+
 // Updates basic and detailed information about the service.
 func UpdateService(dbIface interface{}, service *Service) error {
 	tx, rollback, commit, err := dbops.Transaction(dbIface)
@@ -278,6 +278,105 @@ func TestUpdateBaseHAService(t *testing.T) {
 	require.Equal(t, service.SecondaryLastState, returned.HAService.SecondaryLastState)
 }
 
+// Test that updating the clock skew of the HA peers doesn't overwrite
+// the HA status stored after the service has been read.
+func TestUpdateHAServicePeersClockSkew(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	services := addTestServices(t, db)
+	require.GreaterOrEqual(t, len(services), 2)
+
+	// Read the service before its status changes.
+	stale, err := GetDetailedService(db, services[1].ID)
+	require.NoError(t, err)
+	require.NotNil(t, stale.HAService)
+
+	// The status puller stores the new status.
+	service := services[1].HAService
+	service.SecondaryLastState = "partner-down"
+	err = UpdateBaseHAService(db, service)
+	require.NoError(t, err)
+
+	// The clock skew is stored using the stale copy.
+	skew := int64(3000)
+	stale.HAService.PeersClockSkew = &skew
+	err = UpdateHAServicePeersClockSkew(db, stale.HAService)
+	require.NoError(t, err)
+
+	returned, err := GetDetailedService(db, service.ServiceID)
+	require.NoError(t, err)
+	require.NotNil(t, returned.HAService)
+	require.Equal(t, "partner-down", returned.HAService.SecondaryLastState)
+	require.NotNil(t, returned.HAService.PeersClockSkew)
+	require.EqualValues(t, 3000, *returned.HAService.PeersClockSkew)
+
+	// The status puller stores the status again using its copy read
+	// before the clock skew was stored.
+	service.SecondaryLastState = "load-balancing"
+	err = UpdateBaseHAService(db, service)
+	require.NoError(t, err)
+
+	returned, err = GetDetailedService(db, service.ServiceID)
+	require.NoError(t, err)
+	require.Equal(t, "load-balancing", returned.HAService.SecondaryLastState)
+	require.NotNil(t, returned.HAService.PeersClockSkew)
+	require.EqualValues(t, 3000, *returned.HAService.PeersClockSkew)
+}
+
+// Test that the differences between the configurations of the HA peers
+// and the HA status don't overwrite each other when they are stored using
+// the copies of the service read before the other update.
+func TestUpdateHAServiceConfigDiffs(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	services := addTestServices(t, db)
+	require.GreaterOrEqual(t, len(services), 2)
+
+	// Both writers read the service.
+	stale, err := GetDetailedService(db, services[1].ID)
+	require.NoError(t, err)
+	require.NotNil(t, stale.HAService)
+	service := services[1].HAService
+
+	// The configurations are compared.
+	checkedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	stale.HAService.ConfigDiffs = []HAConfigDiff{
+		{
+			Element:    "lifetime",
+			Difference: HAConfigDiffDifferent,
+			Path:       "/Dhcp4/valid-lifetime",
+		},
+	}
+	stale.HAService.ConfigCheckedAt = checkedAt
+	err = UpdateHAServiceConfigDiffs(db, stale.HAService)
+	require.NoError(t, err)
+
+	// The status puller stores the new status using its copy.
+	service.SecondaryLastState = "partner-down"
+	err = UpdateBaseHAService(db, service)
+	require.NoError(t, err)
+
+	returned, err := GetDetailedService(db, service.ServiceID)
+	require.NoError(t, err)
+	require.NotNil(t, returned.HAService)
+	require.Equal(t, "partner-down", returned.HAService.SecondaryLastState)
+	require.Len(t, returned.HAService.ConfigDiffs, 1)
+	require.Equal(t, "lifetime", returned.HAService.ConfigDiffs[0].Element)
+	require.True(t, checkedAt.Equal(returned.HAService.ConfigCheckedAt))
+
+	// The configurations are compared again using the stale copy.
+	stale.HAService.ConfigDiffs = nil
+	err = UpdateHAServiceConfigDiffs(db, stale.HAService)
+	require.NoError(t, err)
+
+	returned, err = GetDetailedService(db, service.ServiceID)
+	require.NoError(t, err)
+	require.Equal(t, "partner-down", returned.HAService.SecondaryLastState)
+	require.Empty(t, returned.HAService.ConfigDiffs)
+}
+
 // Test that the entire service information can be updated.
 func TestUpdateService(t *testing.T) {
 	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
@@ -62,6 +62,96 @@ func InitializeSettings(db *pg.DB) error {
 			ValType: SettingValTypeInt,
 			Value:   "30",
 		},
+		{
+			Name:    "event_retention_puller_interval", // in seconds
+			ValType: SettingValTypeInt,
+			Value:   "3600",
+		},
+		{
+			Name:    "utilization_retention_puller_interval", // in seconds
+			ValType: SettingValTypeInt,
+			Value:   "3600",
+		},
+		{
+			Name:    "exhaustion_forecast_puller_interval", // in seconds
+			ValType: SettingValTypeInt,
+			Value:   "3600",
+		},
+		{
+			Name:    "exhaustion_forecast_window", // in days
+			ValType: SettingValTypeInt,
+			Value:   "7",
+		},
+		{
+			Name:    "exhaustion_forecast_threshold", // in days
+			ValType: SettingValTypeInt,
+			Value:   "30",
+		},
+		{
+			Name:    "disk_usage_warning_threshold", // in percent
+			ValType: SettingValTypeInt,
+			Value:   "80",
+		},
+		{
+			Name:    "disk_usage_error_threshold", // in percent
+			ValType: SettingValTypeInt,
+			Value:   "90",
+		},
+		{
+			Name:    "clock_skew_warning_threshold", // in seconds
+			ValType: SettingValTypeInt,
+			Value:   "10",
+		},
+		{
+			Name:    "clock_skew_error_threshold", // in seconds
+			ValType: SettingValTypeInt,
+			Value:   "30",
+		},
+		{
+			Name:    "kea_log_errors_puller_interval", // in seconds
+			ValType: SettingValTypeInt,
+			Value:   "60",
+		},
+		{
+			Name:    "kea_log_errors_threshold", // number of repeated errors, 0 disables
+			ValType: SettingValTypeInt,
+			Value:   "10",
+		},
+		{
+			Name:    "event_dedup_window", // in seconds, 0 disables
+			ValType: SettingValTypeInt,
+			Value:   "300",
+		},
+		{
+			Name:    "event_flap_threshold", // number of state changes, 0 disables
+			ValType: SettingValTypeInt,
+			Value:   "5",
+		},
+		{
+			Name:    "event_flap_window", // in minutes
+			ValType: SettingValTypeInt,
+			Value:   "10",
+		},
+		{
+			Name:    "event_retention_info", // in days, 0 keeps the events forever
+			ValType: SettingValTypeInt,
+			Value:   "30",
+		},
+		{
+			Name:    "event_retention_warning", // in days, 0 keeps the events forever
+			ValType: SettingValTypeInt,
+			Value:   "90",
+		},
+		{
+			Name:    "event_retention_error", // in days, 0 keeps the events forever
+			ValType: SettingValTypeInt,
+			Value:   "365",
+		},
+		{
+			Name:    "config_push_grace_period", // in seconds, 0 disables
+			ValType: SettingValTypeInt,
+			Value:   "60",
+		},
 		{
 			Name:    "grafana_url",
 			ValType: SettingValTypeStr,
@@ -23,6 +23,11 @@ type SharedNetwork struct {
 
 	AddrUtilization int16
 	PdUtilization   int16
+
+	// Projected times of the addresses and delegated prefixes exhaustion.
+	// They are zero if the exhaustion is not foreseen.
+	AddrExhaustionAt time.Time
+	PdExhaustionAt   time.Time
 }
 
 // Adds new shared network to the database.
@@ -301,3 +306,22 @@ func UpdateUtilizationInSharedNetwork(db *pg.DB, sharedNetworkID int64, addrUtil
 	}
 	return err
 }
+
+// Update projected times of the addresses and delegated prefixes exhaustion
+// in a SharedNetwork.
+func UpdateExhaustionForecastInSharedNetwork(db *pg.DB, sharedNetworkID int64, addrExhaustionAt, pdExhaustionAt time.Time) error {
+	net := &SharedNetwork{
+		ID:               sharedNetworkID,
+		AddrExhaustionAt: addrExhaustionAt,
+		PdExhaustionAt:   pdExhaustionAt,
+	}
+	q := db.Model(net)
+	q = q.Column("addr_exhaustion_at", "pd_exhaustion_at")
+	q = q.WherePK()
+	_, err := q.Update()
+	if err != nil {
+		err = pkgerrors.Wrapf(err, "problem with updating exhaustion forecast in the shared network: %d",
+			sharedNetworkID)
+	}
+	return err
+}
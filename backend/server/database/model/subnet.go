@@ -52,6 +52,11 @@ type Subnet struct {
 
 	AddrUtilization int16
 	PdUtilization   int16
+
+	// Projected times of the addresses and delegated prefixes exhaustion.
+	// They are zero if the exhaustion is not foreseen.
+	AddrExhaustionAt time.Time
+	PdExhaustionAt   time.Time
 }
 
 // Hook executed after inserting a subnet to the database. It updates subnet
@@ -195,6 +200,78 @@ func GetSubnet(db *pg.DB, subnetID int64) (*Subnet, error) {
 	return subnet, err
 }
 
+// Updates the subnet prefix, client class and shared network association
+// and replaces its address and prefix pools in the database.
+func UpdateSubnet(dbIface interface{}, subnet *Subnet) error {
+	tx, rollback, commit, err := dbops.Transaction(dbIface)
+	if err != nil {
+		err = pkgerrors.WithMessagef(err, "problem with starting transaction for updating subnet with id %d",
+			subnet.ID)
+		return err
+	}
+	defer rollback()
+
+	_, err = tx.Model(subnet).
+		Column("prefix", "client_class", "shared_network_id").
+		WherePK().
+		Update()
+	if err != nil {
+		err = pkgerrors.Wrapf(err, "problem with updating subnet with id %d", subnet.ID)
+		return err
+	}
+
+	// Replace the pools.
+	_, err = tx.Model((*AddressPool)(nil)).
+		Where("address_pool.subnet_id = ?", subnet.ID).
+		Delete()
+	if err != nil {
+		err = pkgerrors.Wrapf(err, "problem with deleting address pools of subnet with id %d", subnet.ID)
+		return err
+	}
+	_, err = tx.Model((*PrefixPool)(nil)).
+		Where("prefix_pool.subnet_id = ?", subnet.ID).
+		Delete()
+	if err != nil {
+		err = pkgerrors.Wrapf(err, "problem with deleting prefix pools of subnet with id %d", subnet.ID)
+		return err
+	}
+	for i := range subnet.AddressPools {
+		subnet.AddressPools[i].ID = 0
+	}
+	for i := range subnet.PrefixPools {
+		subnet.PrefixPools[i].ID = 0
+	}
+	err = addSubnetPools(tx, subnet)
+	if err != nil {
+		return err
+	}
+
+	err = commit()
+	if err != nil {
+		err = pkgerrors.WithMessagef(err, "problem with committing updated subnet with id %d", subnet.ID)
+	}
+	return err
+}
+
+// Moves the subnet having the specified ID to the shared network having
+// the specified ID. The subnet is removed from its shared network if the
+// network ID is 0.
+func SetSubnetSharedNetwork(db *pg.DB, subnetID, networkID int64) error {
+	subnet := &Subnet{
+		ID:              subnetID,
+		SharedNetworkID: networkID,
+	}
+	_, err := db.Model(subnet).
+		Column("shared_network_id").
+		WherePK().
+		Update()
+	if err != nil {
+		err = pkgerrors.Wrapf(err, "problem with setting shared network with id %d for subnet with id %d",
+			networkID, subnetID)
+	}
+	return err
+}
+
 // Fetches the subnet and its pools by local subnet ID and application ID.
 // Applications may use their own numbering scheme for subnets. Therefore,
 // the local subnet ID must be accompanied by the app ID.
@@ -419,6 +496,21 @@ func GetSubnetsWithLocalSubnets(db *pg.DB) ([]*Subnet, error) {
 // information about the subnet from the given app perspective, local subnet
 // id, statistics etc.
 func AddAppToSubnet(dbIface interface{}, subnet *Subnet, app *App) error {
+	localSubnetID := int64(0)
+	// If the prefix is available we should try to match the subnet prefix
+	// with the app's configuration and retrieve the local subnet id from
+	// there.
+	if len(subnet.Prefix) > 0 {
+		localSubnetID = app.GetLocalSubnetID(subnet.Prefix)
+	}
+	return AddAppToSubnetWithLocalID(dbIface, subnet, app, localSubnetID)
+}
+
+// Associates an application with the subnet using the specified local
+// subnet id. It is useful when the subnet has been added to the app
+// but the app's configuration held in the database doesn't include it
+// yet.
+func AddAppToSubnetWithLocalID(dbIface interface{}, subnet *Subnet, app *App, localSubnetID int64) error {
 	tx, rollback, commit, err := dbops.Transaction(dbIface)
 	if err != nil {
 		err = pkgerrors.WithMessagef(err, "problem with starting transaction for associating an app with id %d with the subnet %s",
@@ -427,13 +519,6 @@ func AddAppToSubnet(dbIface interface{}, subnet *Subnet, app *App) error {
 	}
 	defer rollback()
 
-	localSubnetID := int64(0)
-	// If the prefix is available we should try to match the subnet prefix
-	// with the app's configuration and retrieve the local subnet id from
-	// there.
-	if len(subnet.Prefix) > 0 {
-		localSubnetID = app.GetLocalSubnetID(subnet.Prefix)
-	}
 	localSubnet := LocalSubnet{
 		AppID:         app.ID,
 		SubnetID:      subnet.ID,
@@ -616,3 +701,19 @@ func (s *Subnet) UpdateUtilization(db *pg.DB, addrUtilization, pdUtilization int
 	}
 	return err
 }
+
+// Update projected times of the addresses and delegated prefixes exhaustion
+// in Subnet.
+func (s *Subnet) UpdateExhaustionForecast(db *pg.DB, addrExhaustionAt, pdExhaustionAt time.Time) error {
+	s.AddrExhaustionAt = addrExhaustionAt
+	s.PdExhaustionAt = pdExhaustionAt
+	q := db.Model(s)
+	q = q.Column("addr_exhaustion_at", "pd_exhaustion_at")
+	q = q.WherePK()
+	_, err := q.Update()
+	if err != nil {
+		err = pkgerrors.Wrapf(err, "problem with updating exhaustion forecast in the subnet: %d",
+			s.ID)
+	}
+	return err
+}
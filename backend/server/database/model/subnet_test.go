@@ -265,6 +265,116 @@ func TestAddDeleteAppToSubnet(t *testing.T) {
 	require.Len(t, returnedSubnet.LocalSubnets, 1)
 }
 
+// Test that the app can be associated with the subnet using the explicit
+// local subnet id.
+func TestAddAppToSubnetWithLocalID(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	apps := addTestSubnetApps(t, db)
+	subnet := &Subnet{
+		Prefix: "192.0.5.0/24",
+	}
+	err := AddSubnet(db, subnet)
+	require.NoError(t, err)
+
+	err = AddAppToSubnetWithLocalID(db, subnet, apps[0], 77)
+	require.NoError(t, err)
+
+	returnedSubnet, err := GetSubnet(db, subnet.ID)
+	require.NoError(t, err)
+	require.NotNil(t, returnedSubnet)
+	require.Len(t, returnedSubnet.LocalSubnets, 1)
+	require.EqualValues(t, 77, returnedSubnet.LocalSubnets[0].LocalSubnetID)
+}
+
+// Test that the subnet and its pools are updated.
+func TestUpdateSubnet(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	subnet := &Subnet{
+		Prefix: "2001:db8:1::/64",
+		AddressPools: []AddressPool{
+			{
+				LowerBound: "2001:db8:1::1",
+				UpperBound: "2001:db8:1::10",
+			},
+		},
+		PrefixPools: []PrefixPool{
+			{
+				Prefix:       "3000::/48",
+				DelegatedLen: 64,
+			},
+		},
+	}
+	err := AddSubnet(db, subnet)
+	require.NoError(t, err)
+
+	subnet.Prefix = "2001:db8:2::/64"
+	subnet.ClientClass = "foo"
+	subnet.AddressPools = []AddressPool{
+		{
+			LowerBound: "2001:db8:2::1",
+			UpperBound: "2001:db8:2::10",
+		},
+		{
+			LowerBound: "2001:db8:2::20",
+			UpperBound: "2001:db8:2::30",
+		},
+	}
+	subnet.PrefixPools = nil
+	err = UpdateSubnet(db, subnet)
+	require.NoError(t, err)
+
+	returnedSubnet, err := GetSubnet(db, subnet.ID)
+	require.NoError(t, err)
+	require.NotNil(t, returnedSubnet)
+	require.Equal(t, "2001:db8:2::/64", returnedSubnet.Prefix)
+	require.Equal(t, "foo", returnedSubnet.ClientClass)
+	require.Len(t, returnedSubnet.AddressPools, 2)
+	require.Equal(t, "2001:db8:2::20", returnedSubnet.AddressPools[1].LowerBound)
+	require.Empty(t, returnedSubnet.PrefixPools)
+}
+
+// Tests that the subnet can be moved in and out of the shared network.
+func TestSetSubnetSharedNetwork(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	network := &SharedNetwork{
+		Name:   "foo",
+		Family: 4,
+	}
+	err := AddSharedNetwork(db, network)
+	require.NoError(t, err)
+
+	subnet := &Subnet{
+		Prefix: "192.0.2.0/24",
+	}
+	err = AddSubnet(db, subnet)
+	require.NoError(t, err)
+
+	err = SetSubnetSharedNetwork(db, subnet.ID, network.ID)
+	require.NoError(t, err)
+
+	returnedSubnet, err := GetSubnet(db, subnet.ID)
+	require.NoError(t, err)
+	require.NotNil(t, returnedSubnet)
+	require.EqualValues(t, network.ID, returnedSubnet.SharedNetworkID)
+	require.NotNil(t, returnedSubnet.SharedNetwork)
+	require.Equal(t, "foo", returnedSubnet.SharedNetwork.Name)
+
+	err = SetSubnetSharedNetwork(db, subnet.ID, 0)
+	require.NoError(t, err)
+
+	returnedSubnet, err = GetSubnet(db, subnet.ID)
+	require.NoError(t, err)
+	require.NotNil(t, returnedSubnet)
+	require.Zero(t, returnedSubnet.SharedNetworkID)
+	require.Nil(t, returnedSubnet.SharedNetwork)
+}
+
 // Tests that a subnet which is no longer associated with any app is deleted
 // from the database.
 func TestDeleteDanglingSubnets(t *testing.T) {
@@ -0,0 +1,181 @@
+package dbmodel
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	errors "github.com/pkg/errors"
+	dbops "isc.org/stork/server/database"
+)
+
+// Resolutions of the utilization samples.
+const (
+	UtilizationResolutionRaw  = "raw"
+	UtilizationResolutionHour = "hour"
+	UtilizationResolutionDay  = "day"
+)
+
+// Retention periods of the utilization samples with different resolutions.
+const (
+	UtilizationRawRetention  = 48 * time.Hour
+	UtilizationHourRetention = 90 * 24 * time.Hour
+	UtilizationDayRetention  = 2 * 365 * 24 * time.Hour
+)
+
+// Address and prefix delegation counts of a subnet, shared network or
+// daemon at the given time (in UTC). Exactly one of the SubnetID, SharedNetworkID
+// and DaemonID is non-zero. The hourly and daily samples hold the average
+// assigned and declined counts and the maximum total counts.
+type UtilizationSample struct {
+	ID                int64
+	Resolution        string
+	SampledAt         time.Time
+	SubnetID          int64
+	SharedNetworkID   int64
+	DaemonID          int64
+	AssignedAddresses int64 `pg:",use_zero"`
+	TotalAddresses    int64 `pg:",use_zero"`
+	DeclinedAddresses int64 `pg:",use_zero"`
+	AssignedPds       int64 `pg:",use_zero"`
+	TotalPds          int64 `pg:",use_zero"`
+}
+
+// Inserts the utilization samples into the database.
+func AddUtilizationSamples(db *pg.DB, samples []*UtilizationSample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+	_, err := db.Model(&samples).Insert()
+	if err != nil {
+		err = errors.Wrapf(err, "problem with inserting %d utilization samples", len(samples))
+	}
+	return err
+}
+
+// Returns the finest resolution of the utilization samples which is still
+// retained for the given start time of the series.
+func GetUtilizationResolution(from, now time.Time) string {
+	switch {
+	case !from.Before(now.Add(-UtilizationRawRetention)):
+		return UtilizationResolutionRaw
+	case !from.Before(now.Add(-UtilizationHourRetention)):
+		return UtilizationResolutionHour
+	default:
+		return UtilizationResolutionDay
+	}
+}
+
+// Returns the utilization samples of the subnet, shared network or daemon
+// with the given resolution within the time range ordered by time. Exactly
+// one of the subnetID, sharedNetworkID and daemonID must be non-zero.
+func GetUtilizationSamples(db *pg.DB, subnetID, sharedNetworkID, daemonID int64, resolution string, from, to time.Time) ([]UtilizationSample, error) {
+	samples := []UtilizationSample{}
+	q := db.Model(&samples)
+	switch {
+	case subnetID != 0:
+		q = q.Where("subnet_id = ?", subnetID)
+	case sharedNetworkID != 0:
+		q = q.Where("shared_network_id = ?", sharedNetworkID)
+	case daemonID != 0:
+		q = q.Where("daemon_id = ?", daemonID)
+	default:
+		return nil, errors.New("subnet, shared network or daemon must be specified to get utilization samples")
+	}
+	q = q.Where("resolution = ?", resolution)
+	q = q.Where("sampled_at >= ?", from.UTC())
+	q = q.Where("sampled_at <= ?", to.UTC())
+	q = q.Order("sampled_at ASC")
+	err := q.Select()
+	if err != nil {
+		return nil, errors.Wrapf(err, "problem with getting utilization samples")
+	}
+	return samples, nil
+}
+
+// Returns the utilization samples of all subnets and shared networks with
+// the given resolutions since the given time. The samples are ordered by
+// the subnet, shared network and time.
+func GetSubnetsUtilizationSamplesSince(db *pg.DB, resolutions []string, from time.Time) ([]UtilizationSample, error) {
+	samples := []UtilizationSample{}
+	err := db.Model(&samples).
+		Where("daemon_id IS NULL").
+		Where("resolution IN (?)", pg.In(resolutions)).
+		Where("sampled_at >= ?", from.UTC()).
+		OrderExpr("subnet_id ASC NULLS LAST, shared_network_id ASC NULLS LAST, sampled_at ASC").
+		Select()
+	if err != nil {
+		return nil, errors.Wrapf(err, "problem with getting utilization samples of subnets and shared networks")
+	}
+	return samples, nil
+}
+
+// Aggregates the samples with the source resolution into the samples with
+// the target resolution, e.g. raw samples into hourly samples. Only the
+// complete periods preceding the given time are aggregated. The periods
+// already aggregated are skipped.
+func aggregateUtilizationSamples(tx *pg.Tx, source, target string, now time.Time) error {
+	query := fmt.Sprintf(`
+        INSERT INTO utilization_sample (resolution, sampled_at, subnet_id, shared_network_id, daemon_id,
+                                        assigned_addresses, total_addresses, declined_addresses,
+                                        assigned_pds, total_pds)
+        SELECT ?, date_trunc('%[1]s', sampled_at) AS period, subnet_id, shared_network_id, daemon_id,
+               round(avg(assigned_addresses)), max(total_addresses), round(avg(declined_addresses)),
+               round(avg(assigned_pds)), max(total_pds)
+        FROM utilization_sample
+        WHERE resolution = ? AND sampled_at < date_trunc('%[1]s', ?::timestamp)
+        GROUP BY period, subnet_id, shared_network_id, daemon_id
+        ON CONFLICT DO NOTHING`, target)
+	_, err := tx.Exec(query, target, source, now)
+	if err != nil {
+		err = errors.Wrapf(err, "problem with aggregating %s utilization samples into %s samples", source, target)
+	}
+	return err
+}
+
+// Aggregates the raw utilization samples into the hourly samples and the
+// hourly samples into the daily samples. Next, it removes the samples older
+// than their retention periods, i.e. 48 hours for the raw samples, 90 days
+// for the hourly samples and 2 years for the daily samples.
+func DownsampleUtilizationSamples(db *pg.DB, now time.Time) error {
+	// The samples are stored in UTC.
+	now = now.UTC()
+	tx, rollback, commit, err := dbops.Transaction(db)
+	if err != nil {
+		return errors.WithMessagef(err, "problem with starting transaction for downsampling utilization samples")
+	}
+	defer rollback()
+
+	err = aggregateUtilizationSamples(tx, UtilizationResolutionRaw, UtilizationResolutionHour, now)
+	if err != nil {
+		return err
+	}
+	err = aggregateUtilizationSamples(tx, UtilizationResolutionHour, UtilizationResolutionDay, now)
+	if err != nil {
+		return err
+	}
+
+	retentions := []struct {
+		resolution string
+		retention  time.Duration
+	}{
+		{UtilizationResolutionRaw, UtilizationRawRetention},
+		{UtilizationResolutionHour, UtilizationHourRetention},
+		{UtilizationResolutionDay, UtilizationDayRetention},
+	}
+	for _, r := range retentions {
+		_, err = tx.Model(&UtilizationSample{}).
+			Where("resolution = ?", r.resolution).
+			Where("sampled_at < ?", now.Add(-r.retention)).
+			Delete()
+		if err != nil {
+			return errors.Wrapf(err, "problem with deleting old %s utilization samples", r.resolution)
+		}
+	}
+
+	err = commit()
+	if err != nil {
+		return errors.WithMessagef(err, "problem with committing downsampled utilization samples")
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package dbmodel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Test that the finest retained resolution is selected for the beginning
+// of the series.
+func TestGetUtilizationResolution(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	require.Equal(t, UtilizationResolutionRaw, GetUtilizationResolution(now.Add(-time.Hour), now))
+	require.Equal(t, UtilizationResolutionRaw, GetUtilizationResolution(now.Add(-48*time.Hour), now))
+	require.Equal(t, UtilizationResolutionHour, GetUtilizationResolution(now.Add(-49*time.Hour), now))
+	require.Equal(t, UtilizationResolutionHour, GetUtilizationResolution(now.Add(-90*24*time.Hour), now))
+	require.Equal(t, UtilizationResolutionDay, GetUtilizationResolution(now.Add(-91*24*time.Hour), now))
+}
+
+// Test that the utilization samples are inserted and selected by the
+// subnet, shared network and daemon.
+func TestAddGetUtilizationSamples(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	sharedNetwork := &SharedNetwork{
+		Name:   "test",
+		Family: 4,
+	}
+	err := AddSharedNetwork(db, sharedNetwork)
+	require.NoError(t, err)
+	subnet := &Subnet{
+		Prefix:          "192.0.2.0/24",
+		SharedNetworkID: sharedNetwork.ID,
+	}
+	err = AddSubnet(db, subnet)
+	require.NoError(t, err)
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	samples := []*UtilizationSample{
+		{
+			Resolution:        UtilizationResolutionRaw,
+			SampledAt:         now,
+			SubnetID:          subnet.ID,
+			AssignedAddresses: 10,
+			TotalAddresses:    100,
+		},
+		{
+			Resolution:        UtilizationResolutionRaw,
+			SampledAt:         now.Add(-time.Minute),
+			SubnetID:          subnet.ID,
+			AssignedAddresses: 5,
+			TotalAddresses:    100,
+			DeclinedAddresses: 1,
+		},
+		{
+			Resolution:        UtilizationResolutionRaw,
+			SampledAt:         now,
+			SharedNetworkID:   sharedNetwork.ID,
+			AssignedAddresses: 10,
+			TotalAddresses:    100,
+		},
+	}
+	err = AddUtilizationSamples(db, samples)
+	require.NoError(t, err)
+
+	// Adding no samples is fine.
+	err = AddUtilizationSamples(db, nil)
+	require.NoError(t, err)
+
+	returned, err := GetUtilizationSamples(db, subnet.ID, 0, 0, UtilizationResolutionRaw, now.Add(-time.Hour), now)
+	require.NoError(t, err)
+	require.Len(t, returned, 2)
+	// The samples are ordered by time.
+	require.EqualValues(t, 5, returned[0].AssignedAddresses)
+	require.EqualValues(t, 1, returned[0].DeclinedAddresses)
+	require.EqualValues(t, 10, returned[1].AssignedAddresses)
+	require.Zero(t, returned[1].DeclinedAddresses)
+
+	// Time range excludes the older sample.
+	returned, err = GetUtilizationSamples(db, subnet.ID, 0, 0, UtilizationResolutionRaw, now.Add(-time.Second), now)
+	require.NoError(t, err)
+	require.Len(t, returned, 1)
+
+	returned, err = GetUtilizationSamples(db, 0, sharedNetwork.ID, 0, UtilizationResolutionRaw, now.Add(-time.Hour), now)
+	require.NoError(t, err)
+	require.Len(t, returned, 1)
+
+	returned, err = GetUtilizationSamples(db, subnet.ID, 0, 0, UtilizationResolutionHour, now.Add(-time.Hour), now)
+	require.NoError(t, err)
+	require.Empty(t, returned)
+
+	// The object must be specified.
+	_, err = GetUtilizationSamples(db, 0, 0, 0, UtilizationResolutionRaw, now.Add(-time.Hour), now)
+	require.Error(t, err)
+
+	// Deleting the shared network and subnet deletes their samples.
+	err = DeleteSharedNetworkWithSubnets(db, sharedNetwork.ID)
+	require.NoError(t, err)
+	returned, err = GetUtilizationSamples(db, subnet.ID, 0, 0, UtilizationResolutionRaw, now.Add(-time.Hour), now)
+	require.NoError(t, err)
+	require.Empty(t, returned)
+	returned, err = GetUtilizationSamples(db, 0, sharedNetwork.ID, 0, UtilizationResolutionRaw, now.Add(-time.Hour), now)
+	require.NoError(t, err)
+	require.Empty(t, returned)
+}
+
+// Test that the raw samples are aggregated into the hourly and daily
+// samples and the samples older than their retention periods are removed.
+func TestDownsampleUtilizationSamples(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	subnet := &Subnet{
+		Prefix: "192.0.2.0/24",
+	}
+	err := AddSubnet(db, subnet)
+	require.NoError(t, err)
+
+	now := time.Date(2020, 6, 3, 12, 30, 0, 0, time.UTC)
+	dayAgo := time.Date(2020, 6, 2, 10, 0, 0, 0, time.UTC)
+	samples := []*UtilizationSample{
+		// Two samples within the same hour a day ago.
+		{
+			Resolution:        UtilizationResolutionRaw,
+			SampledAt:         dayAgo.Add(10 * time.Minute),
+			SubnetID:          subnet.ID,
+			AssignedAddresses: 10,
+			TotalAddresses:    100,
+		},
+		{
+			Resolution:        UtilizationResolutionRaw,
+			SampledAt:         dayAgo.Add(20 * time.Minute),
+			SubnetID:          subnet.ID,
+			AssignedAddresses: 20,
+			TotalAddresses:    200,
+			DeclinedAddresses: 2,
+		},
+		// The current hour is incomplete and is not aggregated.
+		{
+			Resolution:        UtilizationResolutionRaw,
+			SampledAt:         now.Add(-time.Minute),
+			SubnetID:          subnet.ID,
+			AssignedAddresses: 30,
+			TotalAddresses:    200,
+		},
+		// Sample past the raw retention period.
+		{
+			Resolution:        UtilizationResolutionRaw,
+			SampledAt:         now.Add(-72 * time.Hour),
+			SubnetID:          subnet.ID,
+			AssignedAddresses: 40,
+			TotalAddresses:    200,
+		},
+		// Hourly sample past the hourly retention period.
+		{
+			Resolution:        UtilizationResolutionHour,
+			SampledAt:         now.Add(-100 * 24 * time.Hour).Truncate(time.Hour),
+			SubnetID:          subnet.ID,
+			AssignedAddresses: 50,
+			TotalAddresses:    200,
+		},
+	}
+	err = AddUtilizationSamples(db, samples)
+	require.NoError(t, err)
+
+	err = DownsampleUtilizationSamples(db, now)
+	require.NoError(t, err)
+
+	// Only the recent raw samples remain.
+	raw, err := GetUtilizationSamples(db, subnet.ID, 0, 0, UtilizationResolutionRaw, now.Add(-100*24*time.Hour), now)
+	require.NoError(t, err)
+	require.Len(t, raw, 3)
+
+	hourly, err := GetUtilizationSamples(db, subnet.ID, 0, 0, UtilizationResolutionHour, now.Add(-100*24*time.Hour), now)
+	require.NoError(t, err)
+	require.Len(t, hourly, 2)
+	require.WithinDuration(t, now.Add(-72*time.Hour).Truncate(time.Hour), hourly[0].SampledAt, 0)
+	require.WithinDuration(t, dayAgo, hourly[1].SampledAt, 0)
+	require.EqualValues(t, 15, hourly[1].AssignedAddresses)
+	require.EqualValues(t, 200, hourly[1].TotalAddresses)
+	require.EqualValues(t, 1, hourly[1].DeclinedAddresses)
+
+	daily, err := GetUtilizationSamples(db, subnet.ID, 0, 0, UtilizationResolutionDay, now.Add(-1000*24*time.Hour), now)
+	require.NoError(t, err)
+	require.Len(t, daily, 3)
+	require.EqualValues(t, 50, daily[0].AssignedAddresses)
+	require.EqualValues(t, 40, daily[1].AssignedAddresses)
+	require.EqualValues(t, 15, daily[2].AssignedAddresses)
+
+	// Downsampling again does not duplicate the samples.
+	err = DownsampleUtilizationSamples(db, now)
+	require.NoError(t, err)
+	hourly, err = GetUtilizationSamples(db, subnet.ID, 0, 0, UtilizationResolutionHour, now.Add(-100*24*time.Hour), now)
+	require.NoError(t, err)
+	require.Len(t, hourly, 2)
+}
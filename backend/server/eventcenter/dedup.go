@@ -0,0 +1,229 @@
+package eventcenter
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	dbops "isc.org/stork/server/database"
+	dbmodel "isc.org/stork/server/database/model"
+)
+
+// Default values of the deduplication settings used when the settings
+// cannot be read from the database.
+const (
+	defaultDedupWindow   = 5 * time.Minute
+	defaultFlapThreshold = 5
+	defaultFlapWindow    = 10 * time.Minute
+)
+
+// The recently stored event which the identical events are collapsed into.
+type recentEvent struct {
+	id       int64
+	lastSeen time.Time
+}
+
+// The changes of the state of a known problem, e.g. a daemon becoming
+// unreachable and reachable again. While the problem flaps, the events
+// are suppressed and the last of them is held until the state settles.
+type flapState struct {
+	recovery        bool
+	changes         []time.Time
+	flapping        bool
+	suppressed      *dbmodel.Event
+	suppressedCount int
+}
+
+// Deduplicator collapses the identical events occurring within the dedup
+// window into one event and suppresses the events about the problems
+// whose state changes at least flap threshold times within the flap
+// window. It is used only by the main loop of the EventCenter, so it is
+// not protected by a mutex.
+type eventDeduplicator struct {
+	window        time.Duration
+	flapThreshold int
+	flapWindow    time.Duration
+
+	// Recently stored events by the dedup keys.
+	recent map[string]*recentEvent
+	// Dedup keys of the last stored events by the incident keys.
+	lastStateEvents map[string]string
+	// Changes of the states by the incident keys.
+	flaps map[string]*flapState
+}
+
+// Create new deduplicator with default settings.
+func newEventDeduplicator() *eventDeduplicator {
+	return &eventDeduplicator{
+		window:          defaultDedupWindow,
+		flapThreshold:   defaultFlapThreshold,
+		flapWindow:      defaultFlapWindow,
+		recent:          make(map[string]*recentEvent),
+		lastStateEvents: make(map[string]string),
+		flaps:           make(map[string]*flapState),
+	}
+}
+
+// Returns the key identifying the identical events, i.e. the events
+// with the same level, text and relations.
+func getDedupKey(event *dbmodel.Event) string {
+	return fmt.Sprintf("%d|%s%s", event.Level, event.Text, getRelationsSuffix(event.Relations))
+}
+
+// Reads the deduplication settings from the database. The current
+// settings are preserved when they cannot be read.
+func (d *eventDeduplicator) configure(db *dbops.PgDB) {
+	window, err := dbmodel.GetSettingInt(db, "event_dedup_window")
+	if err != nil {
+		log.Warnf("cannot get event dedup window setting: %+v", err)
+		return
+	}
+	threshold, err := dbmodel.GetSettingInt(db, "event_flap_threshold")
+	if err != nil {
+		log.Warnf("cannot get event flap threshold setting: %+v", err)
+		return
+	}
+	flapWindow, err := dbmodel.GetSettingInt(db, "event_flap_window")
+	if err != nil {
+		log.Warnf("cannot get event flap window setting: %+v", err)
+		return
+	}
+	d.window = time.Duration(window) * time.Second
+	d.flapThreshold = int(threshold)
+	d.flapWindow = time.Duration(flapWindow) * time.Minute
+}
+
+// Records the change of the state of the known problem reported by the
+// event. It returns true when the event must be suppressed because the
+// state of the problem flaps. When the problem starts flapping, the
+// returned event reporting it should be stored instead.
+func (d *eventDeduplicator) checkFlapping(event *dbmodel.Event, now time.Time) (*dbmodel.Event, bool) {
+	if d.flapThreshold <= 0 {
+		return nil, false
+	}
+	key, recovery, ok := matchIncidentPattern(event)
+	if !ok {
+		return nil, false
+	}
+	flap, ok := d.flaps[key]
+	if !ok {
+		flap = &flapState{}
+		d.flaps[key] = flap
+	}
+	flap.changes = pruneChanges(flap.changes, now.Add(-d.flapWindow))
+	// The repeated event about the same state is not a change.
+	if !ok || flap.recovery != recovery {
+		flap.changes = append(flap.changes, now)
+		flap.recovery = recovery
+	}
+
+	if flap.flapping {
+		flap.suppressed = event
+		flap.suppressedCount++
+		return nil, true
+	}
+	if len(flap.changes) < d.flapThreshold {
+		return nil, false
+	}
+
+	flap.flapping = true
+	flap.suppressed = event
+	flap.suppressedCount = 1
+	flapEvent := &dbmodel.Event{
+		Text:      "flapping detected: " + event.Text,
+		Level:     dbmodel.EvWarning,
+		Relations: event.Relations,
+		Details: fmt.Sprintf("state changed %d times within %s, the events are suppressed until the state settles",
+			len(flap.changes), d.flapWindow),
+		Code: dbmodel.EventCodeEventFlapping,
+		Payload: dbmodel.EventPayload{
+			"changes": len(flap.changes),
+		},
+	}
+	if len(event.Code) > 0 {
+		flapEvent.Payload["code"] = event.Code
+	}
+	return flapEvent, true
+}
+
+// Returns the last suppressed events about the problems which stopped
+// flapping, i.e. their state has not changed within the flap window. The
+// returned events report the current states of the problems. It also
+// forgets the old state changes.
+func (d *eventDeduplicator) settle(now time.Time) []*dbmodel.Event {
+	var events []*dbmodel.Event
+	for key, flap := range d.flaps {
+		flap.changes = pruneChanges(flap.changes, now.Add(-d.flapWindow))
+		if len(flap.changes) > 0 {
+			continue
+		}
+		if flap.flapping {
+			event := flap.suppressed
+			details := fmt.Sprintf("state settled after flapping, %d events were suppressed", flap.suppressedCount)
+			if len(event.Details) > 0 {
+				details = event.Details + "; " + details
+			}
+			event.Details = details
+			events = append(events, event)
+		}
+		delete(d.flaps, key)
+	}
+	return events
+}
+
+// Returns the ID of the recently stored event identical to the given
+// event or 0 if there is no such event. The identical event is the one
+// seen within the dedup window.
+func (d *eventDeduplicator) findDuplicate(event *dbmodel.Event, now time.Time) int64 {
+	if d.window <= 0 {
+		return 0
+	}
+	recent, ok := d.recent[getDedupKey(event)]
+	if !ok || now.Sub(recent.lastSeen) > d.window {
+		return 0
+	}
+	recent.lastSeen = now
+	return recent.id
+}
+
+// Remembers the stored event, so the identical events are collapsed into
+// it. The event reporting the change of the state of a known problem
+// ends the collapsing of the events about the previous state, so the
+// events about each state change are stored.
+func (d *eventDeduplicator) remember(event *dbmodel.Event, now time.Time) {
+	dedupKey := getDedupKey(event)
+	d.recent[dedupKey] = &recentEvent{
+		id:       event.ID,
+		lastSeen: now,
+	}
+	if key, _, ok := matchIncidentPattern(event); ok {
+		if previous, ok := d.lastStateEvents[key]; ok && previous != dedupKey {
+			delete(d.recent, previous)
+		}
+		d.lastStateEvents[key] = dedupKey
+	}
+}
+
+// Forgets the events which were not seen within the dedup window.
+func (d *eventDeduplicator) expire(now time.Time) {
+	for dedupKey, recent := range d.recent {
+		if now.Sub(recent.lastSeen) > d.window {
+			delete(d.recent, dedupKey)
+		}
+	}
+	for key, dedupKey := range d.lastStateEvents {
+		if _, ok := d.recent[dedupKey]; !ok {
+			delete(d.lastStateEvents, key)
+		}
+	}
+}
+
+// Returns the times of the state changes which occurred after the
+// specified time.
+func pruneChanges(changes []time.Time, since time.Time) []time.Time {
+	i := 0
+	for i < len(changes) && !changes[i].After(since) {
+		i++
+	}
+	return changes[i:]
+}
@@ -0,0 +1,180 @@
+package eventcenter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Returns the daemon used in the deduplication tests.
+func getDedupTestDaemon() *dbmodel.Daemon {
+	return &dbmodel.Daemon{
+		ID:    2,
+		Name:  "dhcp4",
+		AppID: 1,
+		App: &dbmodel.App{
+			ID:   1,
+			Type: dbmodel.AppTypeKea,
+		},
+	}
+}
+
+// Test that the identical events seen within the dedup window are
+// collapsed.
+func TestFindDuplicate(t *testing.T) {
+	d := newEventDeduplicator()
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	daemon := getDedupTestDaemon()
+
+	event := CreateEvent(dbmodel.EvWarning, "{daemon} has been restarted", daemon)
+	require.Zero(t, d.findDuplicate(event, now))
+	event.ID = 10
+	d.remember(event, now)
+
+	// The identical event is collapsed and extends the window.
+	event = CreateEvent(dbmodel.EvWarning, "{daemon} has been restarted", daemon)
+	require.EqualValues(t, 10, d.findDuplicate(event, now.Add(4*time.Minute)))
+	require.EqualValues(t, 10, d.findDuplicate(event, now.Add(8*time.Minute)))
+
+	// The events with different level, text or relations are not
+	// collapsed.
+	require.Zero(t, d.findDuplicate(CreateEvent(dbmodel.EvError, "{daemon} has been restarted", daemon), now))
+	require.Zero(t, d.findDuplicate(CreateEvent(dbmodel.EvWarning, "{daemon} has been stopped", daemon), now))
+	require.Zero(t, d.findDuplicate(CreateEvent(dbmodel.EvWarning, "{daemon} has been restarted"), now))
+
+	// The event seen after the window is not collapsed.
+	require.Zero(t, d.findDuplicate(event, now.Add(14*time.Minute)))
+
+	// Expired events are forgotten.
+	d.expire(now.Add(14 * time.Minute))
+	require.Empty(t, d.recent)
+
+	// Deduplication is disabled.
+	d.window = 0
+	d.remember(event, now)
+	require.Zero(t, d.findDuplicate(event, now))
+}
+
+// Test that the change of the state of a known problem ends collapsing
+// the events about the previous state.
+func TestFindDuplicateStateChange(t *testing.T) {
+	d := newEventDeduplicator()
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	daemon := getDedupTestDaemon()
+
+	unreachable := CreateEvent(dbmodel.EvError, "{daemon} is unreachable", daemon, dbmodel.EventCodeDaemonUnreachable)
+	unreachable.ID = 1
+	d.remember(unreachable, now)
+	require.EqualValues(t, 1, d.findDuplicate(unreachable, now))
+
+	reachable := CreateEvent(dbmodel.EvWarning, "{daemon} is reachable now", daemon, dbmodel.EventCodeDaemonReachable)
+	reachable.ID = 2
+	d.remember(reachable, now)
+	require.Zero(t, d.findDuplicate(unreachable, now))
+	require.EqualValues(t, 2, d.findDuplicate(reachable, now))
+}
+
+// Test that the events about the flapping problem are suppressed until
+// the state settles.
+func TestCheckFlapping(t *testing.T) {
+	d := newEventDeduplicator()
+	d.flapThreshold = 4
+	d.flapWindow = 10 * time.Minute
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	daemon := getDedupTestDaemon()
+
+	unreachable := func() *dbmodel.Event {
+		return CreateEvent(dbmodel.EvError, "{daemon} is unreachable", daemon, dbmodel.EventCodeDaemonUnreachable)
+	}
+	reachable := func() *dbmodel.Event {
+		return CreateEvent(dbmodel.EvWarning, "{daemon} is reachable now", daemon, dbmodel.EventCodeDaemonReachable)
+	}
+
+	// The events which are not about known problems are not checked.
+	flapEvent, suppressed := d.checkFlapping(CreateEvent(dbmodel.EvWarning, "{daemon} has been restarted", daemon), now)
+	require.Nil(t, flapEvent)
+	require.False(t, suppressed)
+	require.Empty(t, d.flaps)
+
+	// The repeated event about the same state is not a change.
+	for i := 0; i < 5; i++ {
+		flapEvent, suppressed = d.checkFlapping(unreachable(), now)
+		require.Nil(t, flapEvent)
+		require.False(t, suppressed)
+	}
+
+	// The state changes below the threshold are not suppressed.
+	_, suppressed = d.checkFlapping(reachable(), now.Add(time.Minute))
+	require.False(t, suppressed)
+	_, suppressed = d.checkFlapping(unreachable(), now.Add(2*time.Minute))
+	require.False(t, suppressed)
+
+	// The change reaching the threshold is replaced with the flapping event.
+	flapEvent, suppressed = d.checkFlapping(reachable(), now.Add(3*time.Minute))
+	require.True(t, suppressed)
+	require.NotNil(t, flapEvent)
+	require.Equal(t, dbmodel.EvWarning, flapEvent.Level)
+	require.Contains(t, flapEvent.Text, "flapping detected")
+	require.EqualValues(t, daemon.ID, flapEvent.Relations.DaemonID)
+
+	// Further events are suppressed.
+	flapEvent, suppressed = d.checkFlapping(unreachable(), now.Add(4*time.Minute))
+	require.True(t, suppressed)
+	require.Nil(t, flapEvent)
+	flapEvent, suppressed = d.checkFlapping(unreachable(), now.Add(5*time.Minute))
+	require.True(t, suppressed)
+	require.Nil(t, flapEvent)
+
+	// The state has not settled yet.
+	require.Empty(t, d.settle(now.Add(13*time.Minute)))
+
+	// The last suppressed event is returned when the state settles.
+	events := d.settle(now.Add(16 * time.Minute))
+	require.Len(t, events, 1)
+	require.Contains(t, events[0].Text, "is unreachable")
+	require.Contains(t, events[0].Details, "3 events were suppressed")
+	require.Empty(t, d.flaps)
+
+	// The next event is not suppressed.
+	_, suppressed = d.checkFlapping(reachable(), now.Add(17*time.Minute))
+	require.False(t, suppressed)
+
+	// Flap detection is disabled.
+	d.flapThreshold = 0
+	_, suppressed = d.checkFlapping(unreachable(), now.Add(18*time.Minute))
+	require.False(t, suppressed)
+}
+
+// Test that the identical events are collapsed into one event in the
+// database.
+func TestAddDuplicateEvents(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	ec := NewEventCenter(db)
+	defer ec.Shutdown()
+
+	for i := 0; i < 3; i++ {
+		ec.AddWarningEvent("some text")
+	}
+	ec.AddErrorEvent("some text")
+
+	var events []dbmodel.Event
+	var total int64
+	var err error
+	for i := 1; i <= 10; i++ {
+		time.Sleep(10 * time.Millisecond)
+		events, total, err = dbmodel.GetEventsByPage(db, 0, 10, 0, nil, nil, nil, nil, nil, "", dbmodel.SortDirAny)
+		if total == 2 && events[0].Occurrences == 3 {
+			break
+		}
+	}
+	require.NoError(t, err)
+	require.EqualValues(t, 2, total)
+	require.EqualValues(t, 3, events[0].Occurrences)
+	require.EqualValues(t, 1, events[1].Occurrences)
+	require.False(t, events[0].LastSeenAt.Before(events[0].CreatedAt))
+}
@@ -5,13 +5,20 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/go-pg/pg/v9"
 	log "github.com/sirupsen/logrus"
 	dbops "isc.org/stork/server/database"
 	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/notifications"
+	storkutil "isc.org/stork/util"
 )
 
+// Interval of refreshing the deduplication settings, storing the events
+// about the problems which stopped flapping and forgetting the old events.
+const dedupCheckInterval = 30 * time.Second
+
 // An interface to EventCenter.
 type EventCenter interface {
 	AddInfoEvent(text string, objects ...interface{})
@@ -22,25 +29,31 @@ type EventCenter interface {
 	ServeHTTP(w http.ResponseWriter, req *http.Request)
 }
 
-// EventCenter. It has channel for receiving events
-// and a SSE broker for dispatching events to subscribers.
+// EventCenter. It has channel for receiving events, a deduplicator
+// collapsing the identical events and suppressing the flapping ones, a
+// SSE broker for dispatching events to subscribers and a dispatcher
+// sending events through the notification channels.
 type eventCenter struct {
 	db     *dbops.PgDB
 	done   chan bool
 	wg     *sync.WaitGroup
 	events chan *dbmodel.Event
 
-	sseBroker *SSEBroker
+	dedup      *eventDeduplicator
+	sseBroker  *SSEBroker
+	dispatcher *notifications.Dispatcher
 }
 
 // Create new EventCenter object.
 func NewEventCenter(db *pg.DB) EventCenter {
 	ec := &eventCenter{
-		db:        db,
-		done:      make(chan bool),
-		wg:        &sync.WaitGroup{},
-		events:    make(chan *dbmodel.Event),
-		sseBroker: NewSSEBroker(db),
+		db:         db,
+		done:       make(chan bool),
+		wg:         &sync.WaitGroup{},
+		events:     make(chan *dbmodel.Event),
+		dedup:      newEventDeduplicator(),
+		sseBroker:  NewSSEBroker(db),
+		dispatcher: notifications.NewDispatcher(db),
 	}
 	ec.wg.Add(1)
 	go ec.mainLoop()
@@ -69,25 +82,54 @@ func (ec *eventCenter) AddErrorEvent(text string, objects ...interface{}) {
 
 // Create an event without passing it to EventCenter. It can be added later using
 // AddEvent method of EventCenter. It takes event level, text and relating objects.
+// The objects may also include the event code (dbmodel.EventCode), the structured
+// payload (dbmodel.EventPayload) and the details (string). The payload is populated
+// with the IDs and the names of the relating objects, which can be overridden by
+// the passed payload.
 func CreateEvent(level int, text string, objects ...interface{}) *dbmodel.Event {
 	relations := &dbmodel.Relations{}
-	var details string
+	payload := dbmodel.EventPayload{}
+	var (
+		details      string
+		code         dbmodel.EventCode
+		extraPayload []dbmodel.EventPayload
+	)
 	for _, obj := range objects {
 		if d, ok := obj.(*dbmodel.Daemon); ok {
 			text = strings.ReplaceAll(text, "{daemon}", daemonTag(d))
 			relations.DaemonID = d.ID
+			payload["daemonId"] = d.ID
+			payload["daemonName"] = d.Name
 		} else if app, ok := obj.(*dbmodel.App); ok {
 			text = strings.ReplaceAll(text, "{app}", appTag(app))
 			relations.AppID = app.ID
+			payload["appId"] = app.ID
+			payload["appName"] = app.Name
+			payload["appType"] = app.Type
 		} else if m, ok := obj.(*dbmodel.Machine); ok {
 			text = strings.ReplaceAll(text, "{machine}", machineTag(m))
 			relations.MachineID = m.ID
+			payload["machineId"] = m.ID
+			payload["machineAddress"] = m.Address
 		} else if s, ok := obj.(*dbmodel.Subnet); ok {
 			text = strings.ReplaceAll(text, "{subnet}", subnetTag(s))
 			relations.SubnetID = s.ID
+			payload["subnetId"] = s.ID
+			payload["subnetPrefix"] = s.Prefix
+		} else if r, ok := obj.(*dbmodel.AlertRule); ok {
+			text = strings.ReplaceAll(text, "{rule}", ruleTag(r))
+			relations.RuleID = r.ID
+			payload["ruleId"] = r.ID
+			payload["ruleName"] = r.Name
 		} else if u, ok := obj.(*dbmodel.SystemUser); ok {
 			text = strings.ReplaceAll(text, "{user}", userTag(u))
 			relations.UserID = int64(u.ID)
+			payload["userId"] = u.ID
+			payload["userLogin"] = u.Login
+		} else if c, ok := obj.(dbmodel.EventCode); ok {
+			code = c
+		} else if p, ok := obj.(dbmodel.EventPayload); ok {
+			extraPayload = append(extraPayload, p)
 		} else if s, ok := obj.(string); ok {
 			if len(s) > 0 {
 				details = s
@@ -96,11 +138,21 @@ func CreateEvent(level int, text string, objects ...interface{}) *dbmodel.Event
 			log.Warnf("unknown object passed to CreateEvent: %v", obj)
 		}
 	}
+	for _, p := range extraPayload {
+		for key, value := range p {
+			payload[key] = value
+		}
+	}
+	if len(payload) == 0 {
+		payload = nil
+	}
 	e := &dbmodel.Event{
 		Text:      text,
 		Level:     level,
 		Relations: relations,
 		Details:   details,
+		Code:      code,
+		Payload:   payload,
 	}
 	return e
 }
@@ -123,13 +175,18 @@ func (ec *eventCenter) Shutdown() {
 	log.Printf("Stopping EventCenter")
 	ec.done <- true
 	ec.wg.Wait()
+	ec.dispatcher.Shutdown()
 	log.Printf("Stopped EventCenter")
 }
 
-// A main loop of EventCenter. It receives events via channel, stores
-// them into database and dispatches them to subscribers using SSE broker.
+// A main loop of EventCenter. It receives events via channel and
+// handles them. Periodically it refreshes the deduplication settings and
+// stores the events about the problems which stopped flapping.
 func (ec *eventCenter) mainLoop() {
 	defer ec.wg.Done()
+	ec.dedup.configure(ec.db)
+	ticker := time.NewTicker(dedupCheckInterval)
+	defer ticker.Stop()
 	for {
 		select {
 		// wait for done signal from shutdown function
@@ -137,14 +194,61 @@ func (ec *eventCenter) mainLoop() {
 			return
 		// get events from channel
 		case event := <-ec.events:
-			err := dbmodel.AddEvent(ec.db, event)
-			if err != nil {
-				log.Errorf("problem with adding event to db: %+v", err)
-				continue
+			ec.handleEvent(event)
+		case <-ticker.C:
+			ec.dedup.configure(ec.db)
+			now := storkutil.UTCNow()
+			for _, event := range ec.dedup.settle(now) {
+				ec.storeEvent(event, now)
 			}
-			ec.sseBroker.dispatchEvent(event)
+			ec.dedup.expire(now)
+		}
+	}
+}
+
+// Handle the received event. The events about the flapping problems are
+// suppressed and the identical events seen recently are collapsed into
+// the stored event. Other events are stored.
+func (ec *eventCenter) handleEvent(event *dbmodel.Event) {
+	now := storkutil.UTCNow()
+	if flapEvent, suppressed := ec.dedup.checkFlapping(event, now); suppressed {
+		if flapEvent != nil {
+			ec.storeEvent(flapEvent, now)
+		}
+		return
+	}
+	if id := ec.dedup.findDuplicate(event, now); id != 0 {
+		found, err := dbmodel.AddEventOccurrence(ec.db, id, now)
+		if err != nil {
+			log.Errorf("problem with adding event occurrence to db: %+v", err)
+			return
+		}
+		if found {
+			return
 		}
+		// The duplicated event has been deleted in the meantime, e.g.
+		// by the event retention. Store the event as a new one.
+	}
+	ec.storeEvent(event, now)
+}
+
+// Store the event into database, group it into an incident, dispatch it
+// to subscribers using SSE broker and send it through the notification
+// channels.
+func (ec *eventCenter) storeEvent(event *dbmodel.Event, now time.Time) {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = now
+	}
+	event.LastSeenAt = now
+	err := dbmodel.AddEvent(ec.db, event)
+	if err != nil {
+		log.Errorf("problem with adding event to db: %+v", err)
+		return
 	}
+	ec.dedup.remember(event, now)
+	addEventToIncident(ec.db, event)
+	ec.sseBroker.dispatchEvent(event)
+	ec.dispatcher.Dispatch(event)
 }
 
 // Forward SSE requests to SSE Broker.
@@ -185,3 +289,10 @@ func userTag(user *dbmodel.SystemUser) string {
 		user.ID, user.Login, user.Email)
 	return tag
 }
+
+// Prepare a tag describing an alert rule.
+func ruleTag(rule *dbmodel.AlertRule) string {
+	tag := fmt.Sprintf("<rule id=\"%d\" name=\"%s\">",
+		rule.ID, rule.Name)
+	return tag
+}
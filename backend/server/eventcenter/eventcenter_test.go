@@ -62,6 +62,33 @@ func TestCreateEvent(t *testing.T) {
 	require.EqualValues(t, 234, ev.Relations.DaemonID)
 	require.EqualValues(t, 345, ev.Relations.SubnetID)
 	require.EqualValues(t, 456, ev.Relations.MachineID)
+
+	// warning event with ref to alert rule and subnet
+	rule := &dbmodel.AlertRule{
+		ID:   678,
+		Name: "full",
+	}
+	ev = CreateEvent(dbmodel.EvWarning, "alert rule {rule} fired for {subnet}", rule, subnet)
+	require.EqualValues(t, "alert rule <rule id=\"678\" name=\"full\"> fired for <subnet id=\"345\" prefix=\"192.0.0.0/8\">", ev.Text)
+	require.EqualValues(t, 678, ev.Relations.RuleID)
+	require.EqualValues(t, 345, ev.Relations.SubnetID)
+	require.Empty(t, ev.Code)
+
+	// event with code and payload
+	ev = CreateEvent(dbmodel.EvError, "{daemon} is unreachable", "connection refused", daemon, app,
+		dbmodel.EventCodeDaemonUnreachable, dbmodel.EventPayload{"daemonName": "dhcp4-server", "port": 8000})
+	require.Equal(t, dbmodel.EventCodeDaemonUnreachable, ev.Code)
+	require.Equal(t, "connection refused", ev.Details)
+	require.EqualValues(t, 234, ev.Payload["daemonId"])
+	require.EqualValues(t, 123, ev.Payload["appId"])
+	require.Equal(t, "dhcp-server", ev.Payload["appName"])
+	require.EqualValues(t, 8000, ev.Payload["port"])
+	// The passed payload overrides the values taken from the objects.
+	require.Equal(t, "dhcp4-server", ev.Payload["daemonName"])
+
+	// no payload without objects
+	ev = CreateEvent(dbmodel.EvInfo, "started Stork server")
+	require.Nil(t, ev.Payload)
 }
 
 // Check adding event.
@@ -101,7 +128,7 @@ func TestAddEvent(t *testing.T) {
 	var err error
 	for i := 1; i <= 10; i++ {
 		time.Sleep(10 * time.Millisecond)
-		events, total, err = dbmodel.GetEventsByPage(db, 0, 10, 0, nil, nil, nil, nil, "", dbmodel.SortDirAny)
+		events, total, err = dbmodel.GetEventsByPage(db, 0, 10, 0, nil, nil, nil, nil, nil, "", dbmodel.SortDirAny)
 		if total == 3 {
 			break
 		}
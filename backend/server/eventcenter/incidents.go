@@ -0,0 +1,104 @@
+package eventcenter
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	dbops "isc.org/stork/server/database"
+	dbmodel "isc.org/stork/server/database/model"
+)
+
+// Time after the resolution of an incident during which the recurring
+// problem reopens the incident instead of creating new one. It groups the
+// events about the flapping daemons into a single incident.
+const incidentReopenWindow = time.Hour
+
+// Pair of the codes of the event reporting a problem and the event
+// reporting the recovery from this problem. The problem is identified by
+// the objects the event relates to and by the payload fields, e.g. the
+// path of the filesystem which is full.
+type incidentPattern struct {
+	problem  dbmodel.EventCode
+	recovery dbmodel.EventCode
+	// Names of the payload fields identifying the problem in addition to
+	// the related objects.
+	payloadKeys []string
+}
+
+// Known problems with the matching recoveries.
+var incidentPatterns = []incidentPattern{
+	{dbmodel.EventCodeAgentUnreachable, dbmodel.EventCodeAgentReachable, nil},
+	{dbmodel.EventCodeDaemonUnreachable, dbmodel.EventCodeDaemonReachable, nil},
+	{dbmodel.EventCodeAppCommunicationFailed, dbmodel.EventCodeAppCommunicationResumed, nil},
+	{dbmodel.EventCodeDaemonCommunicationFailed, dbmodel.EventCodeDaemonCommunicationResumed, []string{"daemonName"}},
+	{dbmodel.EventCodeDatabaseUnreachable, dbmodel.EventCodeDatabaseReachable, []string{"kind", "type", "host", "port", "database"}},
+	{dbmodel.EventCodeMachineInterfaceAddressesLost, dbmodel.EventCodeMachineInterfaceAddressesRestored, []string{"interface"}},
+	{dbmodel.EventCodeMachineDiskUsageHigh, dbmodel.EventCodeMachineDiskUsageDropped, []string{"path"}},
+	{dbmodel.EventCodeMachineClockSkew, dbmodel.EventCodeMachineClockSkewDropped, nil},
+	{dbmodel.EventCodeHAClockDrift, dbmodel.EventCodeHAClockDriftDropped, []string{"partnerAddress"}},
+	{dbmodel.EventCodeAlertFired, dbmodel.EventCodeAlertResolved, []string{"objectId"}},
+	{dbmodel.EventCodeHAConfigMismatch, dbmodel.EventCodeHAConfigMatched, []string{"partnerDaemonId"}},
+}
+
+// Returns the suffix of the keys identifying the objects the event
+// relates to.
+func getRelationsSuffix(relations *dbmodel.Relations) string {
+	if relations == nil {
+		relations = &dbmodel.Relations{}
+	}
+	return fmt.Sprintf("|%d|%d|%d|%d|%d|%d", relations.MachineID, relations.AppID,
+		relations.SubnetID, relations.DaemonID, relations.UserID, relations.RuleID)
+}
+
+// Returns the key identifying the known problem reported by the event and
+// the objects it relates to, a boolean value indicating if the event
+// reports the recovery from the problem and a boolean value indicating if
+// the event code matches any of the known problems or recoveries. The
+// problems are matched only for the warnings and errors.
+func matchIncidentPattern(event *dbmodel.Event) (string, bool, bool) {
+	if len(event.Code) == 0 {
+		return "", false, false
+	}
+	for i, pattern := range incidentPatterns {
+		recovery := event.Code == pattern.recovery
+		if !recovery && (event.Code != pattern.problem || event.Level < dbmodel.EvWarning) {
+			continue
+		}
+		key := fmt.Sprintf("%d:%s", i, pattern.problem)
+		for _, name := range pattern.payloadKeys {
+			key += fmt.Sprintf("|%v", event.Payload[name])
+		}
+		return key + getRelationsSuffix(event.Relations), recovery, true
+	}
+	return "", false, false
+}
+
+// Returns the key identifying the problem reported by the event and the
+// objects it relates to, and a boolean value indicating if the event
+// reports the recovery from the problem. The events reporting the known
+// problems about the same objects are grouped. The warnings and errors
+// about other problems are grouped when they have the same text. The
+// empty key is returned for the informational events which are not
+// recoveries.
+func getIncidentKey(event *dbmodel.Event) (string, bool) {
+	if key, recovery, ok := matchIncidentPattern(event); ok {
+		return key, recovery
+	}
+	if event.Level < dbmodel.EvWarning {
+		return "", false
+	}
+	return "text:" + event.Text + getRelationsSuffix(event.Relations), false
+}
+
+// Adds the stored event to the incident grouping the related events.
+func addEventToIncident(db *dbops.PgDB, event *dbmodel.Event) {
+	key, recovery := getIncidentKey(event)
+	if len(key) == 0 {
+		return
+	}
+	_, err := dbmodel.AddEventToIncident(db, event, key, recovery, event.CreatedAt.Add(-incidentReopenWindow))
+	if err != nil {
+		log.Errorf("problem with adding event to incident: %+v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package eventcenter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbmodel "isc.org/stork/server/database/model"
+)
+
+// Test that the problems and recoveries relating to the same objects get
+// the same incident keys.
+func TestGetIncidentKey(t *testing.T) {
+	app := &dbmodel.App{
+		ID:   1,
+		Type: dbmodel.AppTypeKea,
+	}
+	daemon := &dbmodel.Daemon{
+		ID:    2,
+		Name:  "dhcp4",
+		App:   app,
+		AppID: app.ID,
+	}
+	otherDaemon := &dbmodel.Daemon{
+		ID:    3,
+		Name:  "dhcp6",
+		App:   app,
+		AppID: app.ID,
+	}
+	machine := &dbmodel.Machine{
+		ID:      4,
+		Address: "agent",
+	}
+
+	unreachable := CreateEvent(dbmodel.EvError, "{daemon} is unreachable", app, daemon, dbmodel.EventCodeDaemonUnreachable)
+	reachable := CreateEvent(dbmodel.EvWarning, "{daemon} is reachable now", app, daemon, dbmodel.EventCodeDaemonReachable)
+	otherUnreachable := CreateEvent(dbmodel.EvError, "{daemon} is unreachable", app, otherDaemon, dbmodel.EventCodeDaemonUnreachable)
+
+	key, recovery := getIncidentKey(unreachable)
+	require.NotEmpty(t, key)
+	require.False(t, recovery)
+	recoveryKey, recovery := getIncidentKey(reachable)
+	require.True(t, recovery)
+	require.Equal(t, key, recoveryKey)
+	otherKey, _ := getIncidentKey(otherUnreachable)
+	require.NotEqual(t, key, otherKey)
+
+	// The recovery from the agent connection problem is not confused with
+	// the recovery from the communication with a daemon.
+	key, _ = getIncidentKey(CreateEvent(dbmodel.EvError, "cannot connect to agent on {machine}", machine,
+		dbmodel.EventCodeAgentUnreachable))
+	recoveryKey, recovery = getIncidentKey(CreateEvent(dbmodel.EvWarning, "communication with stork agent on {machine} resumed", machine,
+		dbmodel.EventCodeAgentReachable))
+	require.True(t, recovery)
+	require.Equal(t, key, recoveryKey)
+
+	key, _ = getIncidentKey(CreateEvent(dbmodel.EvError, "communication with {daemon} of {app} failed", "details", daemon, app,
+		dbmodel.EventCodeDaemonCommunicationFailed))
+	recoveryKey, recovery = getIncidentKey(CreateEvent(dbmodel.EvWarning, "communication with {daemon} of {app} resumed", daemon, app,
+		dbmodel.EventCodeDaemonCommunicationResumed))
+	require.True(t, recovery)
+	require.Equal(t, key, recoveryKey)
+	otherKey, _ = getIncidentKey(CreateEvent(dbmodel.EvError, "communication with {daemon} of {app} failed", "details", daemon, app,
+		dbmodel.EventCodeAgentUnreachable))
+	require.NotEqual(t, key, otherKey)
+
+	// The text is not included in the key, but the payload fields
+	// identifying the problem are.
+	key, _ = getIncidentKey(CreateEvent(dbmodel.EvWarning, "disk usage of /var on {machine} reached 91%", machine,
+		dbmodel.EventCodeMachineDiskUsageHigh, dbmodel.EventPayload{"path": "/var", "usedPercent": 91}))
+	otherKey, _ = getIncidentKey(CreateEvent(dbmodel.EvError, "disk usage of /var on {machine} reached 97%", machine,
+		dbmodel.EventCodeMachineDiskUsageHigh, dbmodel.EventPayload{"path": "/var", "usedPercent": 97}))
+	require.Equal(t, key, otherKey)
+	recoveryKey, recovery = getIncidentKey(CreateEvent(dbmodel.EvInfo, "disk usage of /var on {machine} dropped to 50%", machine,
+		dbmodel.EventCodeMachineDiskUsageDropped, dbmodel.EventPayload{"path": "/var", "usedPercent": 50}))
+	require.True(t, recovery)
+	require.Equal(t, key, recoveryKey)
+	otherKey, _ = getIncidentKey(CreateEvent(dbmodel.EvWarning, "disk usage of /home on {machine} reached 91%", machine,
+		dbmodel.EventCodeMachineDiskUsageHigh, dbmodel.EventPayload{"path": "/home", "usedPercent": 91}))
+	require.NotEqual(t, key, otherKey)
+
+	key, _ = getIncidentKey(CreateEvent(dbmodel.EvWarning, "clocks of {daemon} and its HA partner on 192.0.2.1 drift apart by 5s", daemon,
+		dbmodel.EventCodeHAClockDrift, dbmodel.EventPayload{"partnerAddress": "192.0.2.1"}))
+	recoveryKey, recovery = getIncidentKey(CreateEvent(dbmodel.EvInfo, "clocks of {daemon} and its HA partner on 192.0.2.1 drift apart by 1s only", daemon,
+		dbmodel.EventCodeHAClockDriftDropped, dbmodel.EventPayload{"partnerAddress": "192.0.2.1"}))
+	require.True(t, recovery)
+	require.Equal(t, key, recoveryKey)
+
+	rule := &dbmodel.AlertRule{ID: 5, Name: "full"}
+	subnet := &dbmodel.Subnet{ID: 6, Prefix: "2001:db8:1::/64"}
+	key, _ = getIncidentKey(CreateEvent(dbmodel.EvWarning, "alert rule {rule} fired for {subnet}: subnet_utilization is 95", rule, subnet,
+		dbmodel.EventCodeAlertFired, dbmodel.EventPayload{"objectId": int64(6)}))
+	recoveryKey, recovery = getIncidentKey(CreateEvent(dbmodel.EvInfo, "alert rule {rule} resolved for {subnet}: subnet_utilization is 80", rule, subnet,
+		dbmodel.EventCodeAlertResolved, dbmodel.EventPayload{"objectId": int64(6)}))
+	require.True(t, recovery)
+	require.Equal(t, key, recoveryKey)
+
+	// The alerts for the shared networks are distinguished by the
+	// object ID because the shared networks are not related objects.
+	key, _ = getIncidentKey(CreateEvent(dbmodel.EvWarning, "alert rule {rule} fired for shared network foo: shared_network_utilization is 95", rule,
+		dbmodel.EventCodeAlertFired, dbmodel.EventPayload{"objectId": int64(7)}))
+	otherKey, _ = getIncidentKey(CreateEvent(dbmodel.EvWarning, "alert rule {rule} fired for shared network bar: shared_network_utilization is 95", rule,
+		dbmodel.EventCodeAlertFired, dbmodel.EventPayload{"objectId": int64(8)}))
+	require.NotEqual(t, key, otherKey)
+
+	// The HA configuration mismatch is paired with the match by the code
+	// and the partner, although the texts differ.
+	key, _ = getIncidentKey(CreateEvent(dbmodel.EvWarning, "configuration of {daemon} differs from its HA partner server2", "details", daemon, app,
+		dbmodel.EventCodeHAConfigMismatch, dbmodel.EventPayload{"partnerDaemonId": int64(3), "differences": 2}))
+	recoveryKey, recovery = getIncidentKey(CreateEvent(dbmodel.EvInfo, "configuration of {daemon} matches its HA partner server2 again", daemon, app,
+		dbmodel.EventCodeHAConfigMatched, dbmodel.EventPayload{"partnerDaemonId": int64(3), "differences": 0}))
+	require.True(t, recovery)
+	require.Equal(t, key, recoveryKey)
+	otherKey, _ = getIncidentKey(CreateEvent(dbmodel.EvWarning, "configuration of {daemon} differs from its HA partner server3", "details", daemon, app,
+		dbmodel.EventCodeHAConfigMismatch, dbmodel.EventPayload{"partnerDaemonId": int64(4), "differences": 2}))
+	require.NotEqual(t, key, otherKey)
+
+	// The informational events with the problem codes are not grouped.
+	key, recovery = getIncidentKey(CreateEvent(dbmodel.EvInfo, "{daemon} is unreachable", daemon, dbmodel.EventCodeDaemonUnreachable))
+	require.Empty(t, key)
+	require.False(t, recovery)
+
+	// Other warnings are grouped by the text.
+	key, recovery = getIncidentKey(CreateEvent(dbmodel.EvWarning, "{daemon} has been restarted", daemon, dbmodel.EventCodeDaemonRestarted))
+	require.False(t, recovery)
+	otherKey, _ = getIncidentKey(CreateEvent(dbmodel.EvWarning, "{daemon} has been restarted", daemon, dbmodel.EventCodeDaemonRestarted))
+	require.Equal(t, key, otherKey)
+
+	// The events without the codes are not matched by the text.
+	key, recovery = getIncidentKey(CreateEvent(dbmodel.EvWarning, "{daemon} is reachable now", daemon))
+	require.False(t, recovery)
+	require.Contains(t, key, "text:")
+
+	// Informational events which are not recoveries are not grouped.
+	key, recovery = getIncidentKey(CreateEvent(dbmodel.EvInfo, "added {machine}", machine))
+	require.Empty(t, key)
+	require.False(t, recovery)
+}
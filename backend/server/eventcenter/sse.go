@@ -4,17 +4,35 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"sync"
 
+	errors "github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	dbops "isc.org/stork/server/database"
 	dbmodel "isc.org/stork/server/database/model"
 )
 
+// Maximum number of the missed events replayed to the reconnecting
+// subscriber. The most recent events are replayed.
+const sseMaxReplayedEvents = 1000
+
+// Maximum number of the events waiting for being sent to a subscriber.
+// The subscriber which does not keep up with the events, e.g. because
+// its connection is slow, is disconnected. It reconnects and gets the
+// missed events replayed.
+const sseSubscriberQueueSize = 100
+
+// Event serialized to JSON with its ID sent in the SSE id field.
+type sseEvent struct {
+	id   int64
+	data []byte
+}
+
 // SSE Broker. It stores subscribers in a map which is protected by mutex.
 type SSEBroker struct {
 	db               *dbops.PgDB
-	subscribers      map[chan []byte]*Subscriber
+	subscribers      map[chan *sseEvent]*Subscriber
 	subscribersMutex *sync.Mutex
 }
 
@@ -22,13 +40,72 @@ type SSEBroker struct {
 func NewSSEBroker(db *dbops.PgDB) *SSEBroker {
 	sb := &SSEBroker{
 		db:               db,
-		subscribers:      map[chan []byte]*Subscriber{},
+		subscribers:      map[chan *sseEvent]*Subscriber{},
 		subscribersMutex: &sync.Mutex{},
 	}
 	return sb
 }
 
-// Server SSE request for new session.
+// Returns the ID of the last event received by the reconnecting
+// subscriber. It is taken from the Last-Event-ID header sent by the
+// browsers reconnecting the event source or, if the header is absent,
+// from the lastEventId query parameter. It returns 0 when the subscriber
+// connects for the first time.
+func getLastEventID(req *http.Request) (int64, error) {
+	value := req.Header.Get("Last-Event-ID")
+	if len(value) == 0 {
+		value = req.URL.Query().Get("lastEventId")
+	}
+	if len(value) == 0 {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 0 {
+		return 0, errors.Errorf("last event id %s is not a valid event id", value)
+	}
+	return id, nil
+}
+
+// Writes the event to the subscriber and flushes the connection.
+func writeEvent(w http.ResponseWriter, event *sseEvent) {
+	if event.id != 0 {
+		fmt.Fprintf(w, "id: %d\n", event.id)
+	}
+	fmt.Fprintf(w, "data: %s\n\n", event.data)
+	// Not all ResponseWriter instances implement http.Flusher interface.
+	// Test if this instance implement it before attempting to use it.
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Fetches the events missed by the reconnecting subscriber from the
+// database and serializes the ones accepted by the subscriber.
+func (sb *SSEBroker) getMissedEvents(s *Subscriber, lastEventID int64) ([]*sseEvent, error) {
+	dbEvents, err := dbmodel.GetEventsAfter(sb.db, lastEventID, sseMaxReplayedEvents)
+	if err != nil {
+		return nil, err
+	}
+	var events []*sseEvent
+	for i := range dbEvents {
+		if !s.AcceptsEvent(&dbEvents[i]) {
+			continue
+		}
+		evJSON, err := json.Marshal(&dbEvents[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "problem with serializing event %d to json", dbEvents[i].ID)
+		}
+		events = append(events, &sseEvent{
+			id:   dbEvents[i].ID,
+			data: evJSON,
+		})
+	}
+	return events, nil
+}
+
+// Server SSE request for new session. If the subscriber reconnects
+// after receiving an event with the given ID, the events it missed are
+// replayed from the database before the live events are sent.
 func (sb *SSEBroker) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	s := newSubscriber(req.URL)
 
@@ -38,6 +115,13 @@ func (sb *SSEBroker) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	lastEventID, err := getLastEventID(req)
+	if err != nil {
+		log.Errorf("failed to accept new SSE connection: %+v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
 	log.Printf("new SSE subscriber from %s", req.RemoteAddr)
 
 	// prepare proper HTTP headers for SSE response
@@ -49,26 +133,47 @@ func (sb *SSEBroker) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	h.Set("X-Accel-Buffering", "no") // make nginx working: https://blog.icod.de/2018/12/17/angular-eventsource-go-and-wasted-lifetime/
 
 	// create a subscriber and a channel which is used
-	// to dispatch an event to this subscriber
-	ch := make(chan []byte)
+	// to dispatch an event to this subscriber; the live events
+	// dispatched during the replay are buffered in the channel
+	ch := make(chan *sseEvent, sseSubscriberQueueSize)
 
 	// store subscriber and its channel in a map, protect the map with mutex
 	sb.subscribersMutex.Lock()
 	sb.subscribers[ch] = s
 	sb.subscribersMutex.Unlock()
 
+	// The subscriber is registered before fetching the missed events, so
+	// no event is lost in between. The live events which have been
+	// already replayed are skipped.
+	if lastEventID > 0 {
+		missed, err := sb.getMissedEvents(s, lastEventID)
+		if err != nil {
+			log.Errorf("problem with replaying events to %p: %+v", s, err)
+		}
+		for _, event := range missed {
+			writeEvent(w, event)
+			lastEventID = event.id
+		}
+		log.Printf("replayed %d events to %p", len(missed), s)
+	}
+
 	// and now listen for events dispatched to this subscriber
 	for {
 		select {
-		case event := <-ch:
-			// send received event to subscriber and flush the connection
-			log.Printf("to %p sent %s", s, event)
-			fmt.Fprintf(w, "data: %s\n\n", event)
-			// Not all ResponseWriter instances implement http.Flusher interface.
-			// Test if this instance implement it before attempting to use it.
-			if flusher, ok := w.(http.Flusher); ok {
-				flusher.Flush()
+		case event, ok := <-ch:
+			if !ok {
+				// The subscriber has been dropped because it does
+				// not keep up with the events. Closing the connection
+				// makes it reconnect and replay the missed events.
+				log.Printf("connection with %p closed because it is too slow", s)
+				return
+			}
+			if event.id != 0 && event.id <= lastEventID {
+				continue
 			}
+			// send received event to subscriber and flush the connection
+			log.Printf("to %p sent %s", s, event.data)
+			writeEvent(w, event)
 
 		case <-req.Context().Done():
 			// connection is closed so unsubscribe subscriber
@@ -81,20 +186,29 @@ func (sb *SSEBroker) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
-// Dispatch event to subscribers using filtering.
+// Dispatch event to subscribers using filtering. It never blocks. The
+// subscribers whose queues are full are dropped and their channels are
+// closed, so they reconnect and replay the missed events.
 func (sb *SSEBroker) dispatchEvent(event *dbmodel.Event) {
-	sb.subscribersMutex.Lock()
-	defer sb.subscribersMutex.Unlock()
-
 	evJSON, err := json.Marshal(event)
 	if err != nil {
 		log.Errorf("problem with serializing event to json: %+v", err)
 		return
 	}
 
-	for ch := range sb.subscribers {
-		if sb.subscribers[ch].AcceptsEvent(event) {
-			ch <- evJSON
+	sb.subscribersMutex.Lock()
+	defer sb.subscribersMutex.Unlock()
+
+	for ch, s := range sb.subscribers {
+		if !s.AcceptsEvent(event) {
+			continue
+		}
+		select {
+		case ch <- &sseEvent{id: event.ID, data: evJSON}:
+		default:
+			log.Warnf("SSE subscriber %p does not keep up with the events, dropping it", s)
+			delete(sb.subscribers, ch)
+			close(ch)
 		}
 	}
 }
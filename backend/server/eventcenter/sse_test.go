@@ -2,8 +2,11 @@ package eventcenter
 
 import (
 	"context"
+	"fmt"
 	"io/ioutil"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -38,6 +41,9 @@ func TestSSEBroker(t *testing.T) {
 		}
 		ec.(*eventCenter).sseBroker.dispatchEvent(ev)
 
+		// The event is queued for the subscriber, so give it time to
+		// send it before closing the connection.
+		time.Sleep(100 * time.Millisecond)
 		cancel()
 	}()
 
@@ -46,5 +52,123 @@ func TestSSEBroker(t *testing.T) {
 	body, _ := ioutil.ReadAll(resp.Body)
 	resp.Body.Close()
 	require.Equal(t, 200, resp.StatusCode)
-	require.Equal(t, "data: {\"ID\":0,\"CreatedAt\":\"0001-01-01T00:00:00Z\",\"Text\":\"some text\",\"Level\":0,\"Relations\":null,\"Details\":\"\"}\n\n", string(body))
+	require.Equal(t, "data: {\"ID\":0,\"CreatedAt\":\"0001-01-01T00:00:00Z\",\"Text\":\"some text\",\"Level\":0,\"Relations\":null,\"Details\":\"\",\"Code\":\"\",\"Payload\":null,\"Occurrences\":0,\"LastSeenAt\":\"0001-01-01T00:00:00Z\",\"State\":\"\",\"AcknowledgedByID\":0,\"AcknowledgedAt\":\"0001-01-01T00:00:00Z\",\"ResolvedAt\":\"0001-01-01T00:00:00Z\",\"Comment\":\"\",\"IncidentID\":0}\n\n", string(body))
+}
+
+// Check that the ID of the last received event is taken from the header
+// or from the query.
+func TestGetLastEventID(t *testing.T) {
+	req := httptest.NewRequest("GET", "http://localhost/sse", nil)
+	id, err := getLastEventID(req)
+	require.NoError(t, err)
+	require.Zero(t, id)
+
+	req = httptest.NewRequest("GET", "http://localhost/sse?lastEventId=12", nil)
+	id, err = getLastEventID(req)
+	require.NoError(t, err)
+	require.EqualValues(t, 12, id)
+
+	// The header takes precedence.
+	req.Header.Set("Last-Event-ID", "15")
+	id, err = getLastEventID(req)
+	require.NoError(t, err)
+	require.EqualValues(t, 15, id)
+
+	req.Header.Set("Last-Event-ID", "foo")
+	_, err = getLastEventID(req)
+	require.Error(t, err)
+}
+
+// Check that the events missed by the reconnecting subscriber are
+// replayed before the live events.
+func TestSSEBrokerReplay(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	var ids []int64
+	for _, level := range []int{dbmodel.EvInfo, dbmodel.EvWarning, dbmodel.EvInfo, dbmodel.EvError} {
+		event := &dbmodel.Event{
+			Text:  "stored event",
+			Level: level,
+		}
+		err := dbmodel.AddEvent(db, event)
+		require.NoError(t, err)
+		ids = append(ids, event.ID)
+	}
+
+	sb := NewSSEBroker(db)
+
+	// Subscribe to the warnings and errors after receiving the first event.
+	req := httptest.NewRequest("GET", "http://localhost/sse?level=1", nil)
+	req.Header.Set("Last-Event-ID", fmt.Sprintf("%d", ids[0]))
+	w := httptest.NewRecorder()
+	context, cancel := context.WithCancel(context.Background())
+	req = req.WithContext(context)
+
+	go func() {
+		for i := 1; i <= 10; i++ {
+			time.Sleep(10 * time.Millisecond)
+			if sb.getSubscribersCount() > 0 {
+				break
+			}
+		}
+		// The replayed event is not sent again.
+		sb.dispatchEvent(&dbmodel.Event{ID: ids[3], Text: "stored event", Level: dbmodel.EvError})
+		sb.dispatchEvent(&dbmodel.Event{ID: ids[3] + 1, Text: "live event", Level: dbmodel.EvError})
+		// The events are queued for the subscriber, so give it time to
+		// send them before closing the connection.
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+
+	sb.ServeHTTP(w, req)
+	resp := w.Result()
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.Equal(t, 200, resp.StatusCode)
+
+	lines := strings.Split(string(body), "\n")
+	var sentIDs []string
+	for _, line := range lines {
+		if strings.HasPrefix(line, "id: ") {
+			sentIDs = append(sentIDs, strings.TrimPrefix(line, "id: "))
+		}
+	}
+	require.Equal(t, []string{
+		fmt.Sprintf("%d", ids[1]),
+		fmt.Sprintf("%d", ids[3]),
+		fmt.Sprintf("%d", ids[3]+1),
+	}, sentIDs)
+	require.Contains(t, string(body), "live event")
+
+	// Invalid last event ID.
+	req = httptest.NewRequest("GET", "http://localhost/sse?lastEventId=foo", nil)
+	w = httptest.NewRecorder()
+	sb.ServeHTTP(w, req)
+	require.Equal(t, 400, w.Result().StatusCode)
+}
+
+// Check that the dispatching does not block on the subscriber which does
+// not keep up with the events and that such subscriber is dropped.
+func TestSSEBrokerSlowSubscriber(t *testing.T) {
+	sb := NewSSEBroker(nil)
+	ch := make(chan *sseEvent, sseSubscriberQueueSize)
+	u, _ := url.Parse("http://localhost/sse")
+	sb.subscribers[ch] = newSubscriber(u)
+
+	for i := 1; i <= sseSubscriberQueueSize; i++ {
+		sb.dispatchEvent(&dbmodel.Event{ID: int64(i), Text: "event"})
+	}
+	require.Equal(t, 1, sb.getSubscribersCount())
+
+	// The queue is full, so the subscriber is dropped.
+	sb.dispatchEvent(&dbmodel.Event{ID: sseSubscriberQueueSize + 1, Text: "event"})
+	require.Zero(t, sb.getSubscribersCount())
+
+	// The queued events are still delivered and the channel is closed.
+	count := 0
+	for range ch {
+		count++
+	}
+	require.Equal(t, sseSubscriberQueueSize, count)
 }
@@ -18,14 +18,16 @@ type subscriberFilters dbmodel.Relations
 // for events. Filtering parameters are stored in filters structure
 // and they are populated by parsing the URL used to connect to the
 // server. In addition, the desired event level can be specified and
-// is stored in this structure. Finally, the useFilter boolean value
-// is set to true when it is detected that no filtering rules have
-// been set. If this value is set to false (which is a default), the
+// is stored in this structure, as well as the event code, which may
+// end with an asterisk to match the codes by prefix (e.g. daemon.*).
+// Finally, the useFilter boolean value is set to true when it is
+// detected that no filtering rules have been set. If this value is set to false (which is a default), the
 // server sends all events to the subscriber.
 type Subscriber struct {
 	serverURL *url.URL
 	useFilter bool
 	level     int
+	code      string
 	filters   subscriberFilters
 }
 
@@ -86,6 +88,9 @@ func (s *Subscriber) applyFiltersFromQuery(db *dbops.PgDB) (err error) {
 	if f.UserID, err = getQueryValueAsInt64("user", queryValues); err != nil {
 		return err
 	}
+	if f.RuleID, err = getQueryValueAsInt64("rule", queryValues); err != nil {
+		return err
+	}
 
 	// Level is also specified as numeric value. Possible values are 0, 1, 2.
 	level, err := getQueryValueAsInt64("level", queryValues)
@@ -94,6 +99,9 @@ func (s *Subscriber) applyFiltersFromQuery(db *dbops.PgDB) (err error) {
 	}
 	s.level = int(level)
 
+	// Event code is a string matched exactly or by prefix.
+	s.code = queryValues.Get("code")
+
 	// There are additional query parameters supported by the server: appType and
 	// daemonName. They are mutually exclusive with app and daemon parmameters.
 	// Also, daemonName require appType to be specified. Let's get those parameters
@@ -158,12 +166,15 @@ func (s *Subscriber) applyFiltersFromQuery(db *dbops.PgDB) (err error) {
 	// any of these values. If all of them happen to be zero we leave the useFilter
 	// value as false reducing the number of checks to be performed to only this
 	// value. Otherwise, we need to do the matching for each event.
-	for _, id := range []int64{f.MachineID, f.AppID, f.SubnetID, f.DaemonID, f.UserID, level} {
+	for _, id := range []int64{f.MachineID, f.AppID, f.SubnetID, f.DaemonID, f.UserID, f.RuleID, level} {
 		if id != 0 {
 			s.useFilter = true
 			break
 		}
 	}
+	if len(s.code) > 0 {
+		s.useFilter = true
+	}
 
 	return nil
 }
@@ -177,5 +188,7 @@ func (s *Subscriber) AcceptsEvent(event *dbmodel.Event) bool {
 			(s.filters.SubnetID == 0 || event.Relations.SubnetID == s.filters.SubnetID) &&
 			(s.filters.DaemonID == 0 || event.Relations.DaemonID == s.filters.DaemonID) &&
 			(s.filters.UserID == 0 || event.Relations.UserID == s.filters.UserID) &&
-			(s.level == 0 || event.Level >= s.level))
+			(s.filters.RuleID == 0 || event.Relations.RuleID == s.filters.RuleID) &&
+			(s.level == 0 || event.Level >= s.level) &&
+			dbmodel.MatchEventCode(string(event.Code), s.code))
 }
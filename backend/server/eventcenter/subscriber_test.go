@@ -36,7 +36,7 @@ func TestSetFilterValues(t *testing.T) {
 	defer teardown()
 
 	// Use an URL with all parameters set.
-	url, err := url.Parse("http://example.org/sse?machine=1&app=2&subnet=3&daemon=4&user=5&level=1")
+	url, err := url.Parse("http://example.org/sse?machine=1&app=2&subnet=3&daemon=4&user=5&rule=6&level=1")
 	require.NoError(t, err)
 
 	subscriber := newSubscriber(url)
@@ -55,6 +55,7 @@ func TestSetFilterValues(t *testing.T) {
 	require.EqualValues(t, 3, subscriber.filters.SubnetID)
 	require.EqualValues(t, 4, subscriber.filters.DaemonID)
 	require.EqualValues(t, 5, subscriber.filters.UserID)
+	require.EqualValues(t, 6, subscriber.filters.RuleID)
 	require.EqualValues(t, 1, subscriber.level)
 }
 
@@ -63,7 +64,7 @@ func TestAcceptEventsSingleFilter(t *testing.T) {
 	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
 	defer teardown()
 
-	testCases := []string{"machine", "app", "subnet", "daemon", "user"}
+	testCases := []string{"machine", "app", "subnet", "daemon", "user", "rule"}
 	for i := range testCases {
 		tc := testCases[i]
 		t.Run(tc, func(t *testing.T) {
@@ -95,6 +96,8 @@ func TestAcceptEventsSingleFilter(t *testing.T) {
 				ev.Relations.DaemonID = 123
 			case "user":
 				ev.Relations.UserID = 123
+			case "rule":
+				ev.Relations.RuleID = 123
 			}
 			// Event should be accepted.
 			require.True(t, subscriber.AcceptsEvent(ev))
@@ -106,6 +109,34 @@ func TestAcceptEventsSingleFilter(t *testing.T) {
 	}
 }
 
+// Test that the events are filtered by code.
+func TestAcceptEventsCode(t *testing.T) {
+	sseURL, err := url.Parse("http://example.org/sse?code=daemon.*")
+	require.NoError(t, err)
+	subscriber := newSubscriber(sseURL)
+	err = subscriber.applyFiltersFromQuery(nil)
+	require.NoError(t, err)
+	require.True(t, subscriber.useFilter)
+
+	ev := &dbmodel.Event{
+		Relations: &dbmodel.Relations{},
+		Code:      dbmodel.EventCodeDaemonUnreachable,
+	}
+	require.True(t, subscriber.AcceptsEvent(ev))
+	ev.Code = dbmodel.EventCodeAppUnreachable
+	require.False(t, subscriber.AcceptsEvent(ev))
+	ev.Code = ""
+	require.False(t, subscriber.AcceptsEvent(ev))
+
+	sseURL, err = url.Parse("http://example.org/sse?code=app.unreachable")
+	require.NoError(t, err)
+	subscriber = newSubscriber(sseURL)
+	err = subscriber.applyFiltersFromQuery(nil)
+	require.NoError(t, err)
+	ev.Code = dbmodel.EventCodeAppUnreachable
+	require.True(t, subscriber.AcceptsEvent(ev))
+}
+
 // Test verifying that complex filter can be applied and the event
 // must match all of the filtering rules.
 func TestAcceptEventsMultipleFilters(t *testing.T) {
@@ -0,0 +1,68 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	dbmodel "isc.org/stork/server/database/model"
+)
+
+// Function sending the email. It is replaced in the unit tests.
+var smtpSendMail = smtp.SendMail
+
+// Builds the email message describing the event.
+func buildEmailMessage(config *dbmodel.EmailConfig, event *dbmodel.Event) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", config.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(config.To, ", "))
+	fmt.Fprintf(&b, "Subject: [Stork] %s: %s\r\n", strings.ToUpper(getLevelName(event.Level)), getSummary(event))
+	fmt.Fprintf(&b, "Date: %s\r\n", event.CreatedAt.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	fmt.Fprintf(&b, "%s\r\n", getPlainText(event.Text))
+	if len(event.Details) > 0 {
+		fmt.Fprintf(&b, "\r\n%s\r\n", strings.ReplaceAll(event.Details, "\n", "\r\n"))
+	}
+	return []byte(b.String())
+}
+
+// Sends the event as an email via the SMTP server configured in the channel.
+func sendEmail(ctx context.Context, channel *dbmodel.NotificationChannel, event *dbmodel.Event) error {
+	config := channel.Email
+	if config == nil || len(config.Host) == 0 || len(config.From) == 0 || len(config.To) == 0 {
+		return errors.Errorf("SMTP server, sender or recipients are not configured in notification channel %s", channel.Name)
+	}
+	port := config.Port
+	if port == 0 {
+		port = 25
+	}
+	var auth smtp.Auth
+	if len(config.Username) > 0 {
+		auth = smtp.PlainAuth("", config.Username, config.Password, config.Host)
+	}
+	addr := net.JoinHostPort(config.Host, strconv.Itoa(port))
+
+	// The SMTP client does not accept the context, so the email is sent in
+	// the background and abandoned when the context expires.
+	result := make(chan error, 1)
+	go func() {
+		result <- smtpSendMail(addr, auth, config.From, config.To, buildEmailMessage(config, event))
+	}()
+	select {
+	case err := <-result:
+		if err != nil {
+			return errors.Wrapf(err, "problem with sending email via %s", addr)
+		}
+		return nil
+	case <-ctx.Done():
+		return errors.Errorf("timeout sending email via %s", addr)
+	}
+}
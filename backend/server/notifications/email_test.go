@@ -0,0 +1,77 @@
+package notifications
+
+import (
+	"context"
+	"net/smtp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbmodel "isc.org/stork/server/database/model"
+)
+
+// Test that the email is sent to the configured recipients.
+func TestSendEmail(t *testing.T) {
+	var sentAddr, sentFrom string
+	var sentTo []string
+	var sentMsg []byte
+	var sentAuth smtp.Auth
+	defaultSendMail := smtpSendMail
+	smtpSendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		sentAddr = addr
+		sentAuth = a
+		sentFrom = from
+		sentTo = to
+		sentMsg = msg
+		return nil
+	}
+	defer func() {
+		smtpSendMail = defaultSendMail
+	}()
+
+	channel := &dbmodel.NotificationChannel{
+		Name: "mail",
+		Type: dbmodel.NotificationChannelEmail,
+		Email: &dbmodel.EmailConfig{
+			Host: "smtp.example.org",
+			From: "stork@example.org",
+			To:   []string{"admin@example.org", "ops@example.org"},
+		},
+	}
+	event := &dbmodel.Event{
+		CreatedAt: time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC),
+		Text:      "<machine id=\"1\" address=\"agent\" hostname=\"\">\nis down",
+		Level:     dbmodel.EvError,
+		Details:   "line 1\nline 2",
+	}
+	err := sendEmail(context.Background(), channel, event)
+	require.NoError(t, err)
+	require.Equal(t, "smtp.example.org:25", sentAddr)
+	require.Nil(t, sentAuth)
+	require.Equal(t, "stork@example.org", sentFrom)
+	require.Equal(t, []string{"admin@example.org", "ops@example.org"}, sentTo)
+	require.Equal(t, "From: stork@example.org\r\n"+
+		"To: admin@example.org, ops@example.org\r\n"+
+		"Subject: [Stork] ERROR: machine agent is down\r\n"+
+		"Date: Mon, 01 Jun 2020 12:00:00 +0000\r\n"+
+		"MIME-Version: 1.0\r\n"+
+		"Content-Type: text/plain; charset=UTF-8\r\n"+
+		"\r\n"+
+		"machine agent\nis down\r\n"+
+		"\r\n"+
+		"line 1\r\nline 2\r\n", string(sentMsg))
+
+	// The authentication is used when the username is set.
+	channel.Email.Port = 587
+	channel.Email.Username = "user"
+	channel.Email.Password = "pass"
+	err = sendEmail(context.Background(), channel, event)
+	require.NoError(t, err)
+	require.Equal(t, "smtp.example.org:587", sentAddr)
+	require.NotNil(t, sentAuth)
+
+	// Missing recipients.
+	channel.Email.To = nil
+	err = sendEmail(context.Background(), channel, event)
+	require.Error(t, err)
+}
@@ -0,0 +1,241 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	dbops "isc.org/stork/server/database"
+	dbmodel "isc.org/stork/server/database/model"
+	storkutil "isc.org/stork/util"
+)
+
+// Maximum number of events waiting for being sent through a single
+// channel. The events exceeding this number are dropped and counted as
+// failed deliveries.
+const queueSize = 100
+
+// Maximum time of sending a single event through a channel, including
+// the retries.
+const sendTimeout = 30 * time.Second
+
+// Maximum time of sending the remaining queued events when the dispatcher
+// is stopped. The events which are not sent within this time are dropped.
+const shutdownTimeout = 10 * time.Second
+
+// Function sending the event through the channel of a particular type.
+type sender func(ctx context.Context, channel *dbmodel.NotificationChannel, event *dbmodel.Event) error
+
+// Senders for all supported channel types.
+var senders = map[string]sender{
+	dbmodel.NotificationChannelWebhook: sendWebhook,
+	dbmodel.NotificationChannelEmail:   sendEmail,
+	dbmodel.NotificationChannelSyslog:  sendSyslog,
+}
+
+// Event queued for sending through the channel. The channel is captured
+// when the event is queued, so the event is sent using the channel
+// configuration valid at the time of the event.
+type delivery struct {
+	channel *dbmodel.NotificationChannel
+	event   *dbmodel.Event
+}
+
+// Sends the events through the notification channels configured in the
+// database. Each channel has its own queue and worker sending the events,
+// so the slow or unreachable receivers delay neither the caller nor the
+// other channels. The result of each delivery is recorded in the channel.
+type Dispatcher struct {
+	db      *dbops.PgDB
+	queues  map[int64]chan *delivery
+	mutex   *sync.Mutex
+	wg      *sync.WaitGroup
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stopped bool
+}
+
+// Creates the dispatcher.
+func NewDispatcher(db *dbops.PgDB) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		db:     db,
+		queues: make(map[int64]chan *delivery),
+		mutex:  &sync.Mutex{},
+		wg:     &sync.WaitGroup{},
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Queues the event for sending through all enabled channels accepting
+// it. If the queue of the channel is full, the event is dropped for this
+// channel and it is recorded as a failed delivery. The workers of the
+// channels which have been deleted or disabled are stopped.
+func (d *Dispatcher) Dispatch(event *dbmodel.Event) {
+	channels, err := dbmodel.GetNotificationChannels(d.db, true)
+	if err != nil {
+		log.Errorf("problem with getting notification channels: %+v", err)
+		return
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.stopped {
+		return
+	}
+
+	enabled := make(map[int64]bool)
+	for _, channel := range channels {
+		enabled[channel.ID] = true
+	}
+	for id, queue := range d.queues {
+		if !enabled[id] {
+			close(queue)
+			delete(d.queues, id)
+		}
+	}
+
+	for _, channel := range channels {
+		if !channel.AcceptsEvent(event) {
+			continue
+		}
+		queue, ok := d.queues[channel.ID]
+		if !ok {
+			queue = make(chan *delivery, queueSize)
+			d.queues[channel.ID] = queue
+			d.wg.Add(1)
+			go d.worker(queue)
+		}
+		select {
+		case queue <- &delivery{channel: channel, event: event}:
+		default:
+			err := errors.Errorf("notification queue is full, dropping event %d", event.ID)
+			log.Warnf("problem with sending event through notification channel %s: %s", channel.Name, err)
+			if recordErr := dbmodel.RecordNotificationDelivery(d.db, channel.ID, storkutil.UTCNow(), err); recordErr != nil {
+				log.Errorf("%+v", recordErr)
+			}
+		}
+	}
+}
+
+// Sends the remaining queued events and stops the dispatcher. The events
+// which are not sent within the shutdown timeout are dropped.
+func (d *Dispatcher) Shutdown() {
+	d.mutex.Lock()
+	d.stopped = true
+	for id, queue := range d.queues {
+		close(queue)
+		delete(d.queues, id)
+	}
+	d.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(shutdownTimeout):
+		log.Warnf("timeout sending queued notifications, dropping remaining events")
+		// Interrupt the ongoing deliveries. The workers drop the
+		// remaining events once the context is canceled.
+		d.cancel()
+		<-done
+	}
+	d.cancel()
+}
+
+// Sends the events queued for the channel until the queue is closed.
+func (d *Dispatcher) worker(queue chan *delivery) {
+	defer d.wg.Done()
+	for item := range queue {
+		if d.ctx.Err() != nil {
+			continue
+		}
+		d.deliver(item)
+	}
+}
+
+// Sends the event through the channel and records the result of the
+// delivery.
+func (d *Dispatcher) deliver(item *delivery) {
+	err := send(d.ctx, item.channel, item.event)
+	if err != nil {
+		log.Warnf("problem with sending event through notification channel %s: %+v", item.channel.Name, err)
+	}
+	if recordErr := dbmodel.RecordNotificationDelivery(d.db, item.channel.ID, storkutil.UTCNow(), err); recordErr != nil {
+		log.Errorf("%+v", recordErr)
+	}
+}
+
+// Sends the event through the channel regardless of the channel's level
+// and filter. It is used to test the channel configuration.
+func Send(channel *dbmodel.NotificationChannel, event *dbmodel.Event) error {
+	return send(context.Background(), channel, event)
+}
+
+// Sends the event through the channel within the send timeout.
+func send(ctx context.Context, channel *dbmodel.NotificationChannel, event *dbmodel.Event) error {
+	sendFn, ok := senders[channel.Type]
+	if !ok {
+		return errors.Errorf("unsupported notification channel type %s", channel.Type)
+	}
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	return sendFn(ctx, channel, event)
+}
+
+// Returns the name of the event level.
+func getLevelName(level int) string {
+	switch level {
+	case dbmodel.EvWarning:
+		return "warning"
+	case dbmodel.EvError:
+		return "error"
+	default:
+		return "info"
+	}
+}
+
+var (
+	// Matches the tags describing the objects in the event text, e.g.
+	// <subnet id="1" prefix="192.0.2.0/24">.
+	tagPattern = regexp.MustCompile(`<(\w+)((?:\s+\w+="[^"]*")*)>`)
+	// Matches a single attribute of the tag.
+	attrPattern = regexp.MustCompile(`(\w+)="([^"]*)"`)
+)
+
+// Converts the event text to the plain text by replacing the tags
+// describing the objects with their names, e.g. <subnet id="1"
+// prefix="192.0.2.0/24"> is replaced with subnet 192.0.2.0/24.
+func getPlainText(text string) string {
+	return tagPattern.ReplaceAllStringFunc(text, func(tag string) string {
+		match := tagPattern.FindStringSubmatch(tag)
+		attrs := make(map[string]string)
+		for _, attr := range attrPattern.FindAllStringSubmatch(match[2], -1) {
+			attrs[attr[1]] = attr[2]
+		}
+		for _, name := range []string{"name", "prefix", "address", "login", "id"} {
+			if value, ok := attrs[name]; ok && len(value) > 0 {
+				return fmt.Sprintf("%s %s", match[1], value)
+			}
+		}
+		return match[1]
+	})
+}
+
+// Returns the single line summary of the event.
+func getSummary(event *dbmodel.Event) string {
+	summary := getPlainText(event.Text)
+	summary = strings.ReplaceAll(summary, "\r", " ")
+	summary = strings.ReplaceAll(summary, "\n", " ")
+	return summary
+}
@@ -0,0 +1,148 @@
+package notifications
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Test that the tags describing the objects are replaced with their names.
+func TestGetPlainText(t *testing.T) {
+	text := getPlainText(`alert rule <rule id="1" name="full"> fired for <subnet id="2" prefix="192.0.2.0/24">`)
+	require.Equal(t, "alert rule rule full fired for subnet 192.0.2.0/24", text)
+
+	text = getPlainText(`<machine id="3" address="agent" hostname=""> is unreachable`)
+	require.Equal(t, "machine agent is unreachable", text)
+
+	text = getPlainText(`<foo> <bar id="">`)
+	require.Equal(t, "foo bar", text)
+
+	require.Equal(t, "no tags", getPlainText("no tags"))
+}
+
+// Test that the event is sent only through the channels of the supported
+// types.
+func TestSendUnsupportedType(t *testing.T) {
+	channel := &dbmodel.NotificationChannel{
+		Name: "foo",
+		Type: "pager",
+	}
+	err := Send(channel, &dbmodel.Event{})
+	require.Error(t, err)
+}
+
+// Test that the dispatcher sends the events through the enabled channels
+// accepting them and records the deliveries.
+func TestDispatcher(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	var mutex sync.Mutex
+	var received []webhookPayload
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		payload := webhookPayload{}
+		_ = json.Unmarshal(body, &payload)
+		mutex.Lock()
+		received = append(received, payload)
+		mutex.Unlock()
+	}))
+	defer ts.Close()
+
+	channels := []*dbmodel.NotificationChannel{
+		{
+			Name:    "all",
+			Type:    dbmodel.NotificationChannelWebhook,
+			Enabled: true,
+			Webhook: &dbmodel.WebhookConfig{URL: ts.URL},
+		},
+		{
+			Name:    "errors",
+			Type:    dbmodel.NotificationChannelWebhook,
+			Enabled: true,
+			Level:   dbmodel.EvError,
+			Webhook: &dbmodel.WebhookConfig{URL: ts.URL},
+		},
+		{
+			Name:    "disabled",
+			Type:    dbmodel.NotificationChannelWebhook,
+			Webhook: &dbmodel.WebhookConfig{URL: ts.URL},
+		},
+		{
+			Name:    "broken",
+			Type:    dbmodel.NotificationChannelSyslog,
+			Enabled: true,
+			Syslog:  &dbmodel.SyslogConfig{Network: "sctp", Address: "localhost:514"},
+		},
+	}
+	for _, channel := range channels {
+		err := dbmodel.AddNotificationChannel(db, channel)
+		require.NoError(t, err)
+	}
+
+	d := NewDispatcher(db)
+	d.Dispatch(&dbmodel.Event{ID: 1, Text: "info", Level: dbmodel.EvInfo})
+	d.Dispatch(&dbmodel.Event{ID: 2, Text: "error", Level: dbmodel.EvError})
+	d.Shutdown()
+
+	// The channels are served by the independent workers, so the order
+	// of the events received through different channels is not defined.
+	require.Len(t, received, 3)
+	receivedIDs := []int64{received[0].ID, received[1].ID, received[2].ID}
+	require.ElementsMatch(t, []int64{1, 2, 2}, receivedIDs)
+
+	returned, err := dbmodel.GetNotificationChannels(db, false)
+	require.NoError(t, err)
+	require.Len(t, returned, 4)
+	require.EqualValues(t, 2, returned[0].DeliveredCount)
+	require.EqualValues(t, 1, returned[1].DeliveredCount)
+	require.Zero(t, returned[2].DeliveredCount)
+	require.Zero(t, returned[3].DeliveredCount)
+	require.EqualValues(t, 2, returned[3].FailedCount)
+	require.Contains(t, returned[3].LastError, "unsupported syslog network sctp")
+	require.WithinDuration(t, time.Now(), returned[3].LastFailedAt, time.Minute)
+}
+
+// Test that the events exceeding the channel's queue size are dropped and
+// counted as failed deliveries.
+func TestDispatcherQueueFull(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer ts.Close()
+
+	channel := &dbmodel.NotificationChannel{
+		Name:    "slow",
+		Type:    dbmodel.NotificationChannelWebhook,
+		Enabled: true,
+		Webhook: &dbmodel.WebhookConfig{URL: ts.URL},
+	}
+	err := dbmodel.AddNotificationChannel(db, channel)
+	require.NoError(t, err)
+
+	d := NewDispatcher(db)
+	for i := 1; i <= queueSize+2; i++ {
+		d.Dispatch(&dbmodel.Event{ID: int64(i), Text: "info", Level: dbmodel.EvInfo})
+	}
+	close(release)
+	d.Shutdown()
+
+	returned, err := dbmodel.GetNotificationChannels(db, false)
+	require.NoError(t, err)
+	require.Len(t, returned, 1)
+	require.GreaterOrEqual(t, returned[0].FailedCount, int64(1))
+	require.EqualValues(t, queueSize+2, returned[0].DeliveredCount+returned[0].FailedCount)
+	require.Contains(t, returned[0].LastError, "notification queue is full")
+}
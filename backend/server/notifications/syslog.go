@@ -0,0 +1,81 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/pkg/errors"
+
+	dbmodel "isc.org/stork/server/database/model"
+)
+
+// Default syslog facility (user-level messages) used when the facility is
+// not configured.
+const defaultSyslogFacility = 1
+
+// Returns the syslog severity corresponding to the event level.
+func getSyslogSeverity(level int) int {
+	switch level {
+	case dbmodel.EvWarning:
+		return 4
+	case dbmodel.EvError:
+		return 3
+	default:
+		return 6
+	}
+}
+
+// Formats the event as the RFC 5424 syslog message.
+func formatSyslogMessage(config *dbmodel.SyslogConfig, event *dbmodel.Event, hostname string, pid int) string {
+	facility := config.Facility
+	if facility == 0 {
+		facility = defaultSyslogFacility
+	}
+	if len(hostname) == 0 {
+		hostname = "-"
+	}
+	return fmt.Sprintf("<%d>1 %s %s stork-server %d event - %s",
+		facility*8+getSyslogSeverity(event.Level),
+		event.CreatedAt.UTC().Format("2006-01-02T15:04:05.000000Z"),
+		hostname, pid, getSummary(event))
+}
+
+// Sends the event as the RFC 5424 message to the syslog server configured
+// in the channel. The messages sent over TCP are framed using the octet
+// counting method described in RFC 6587.
+func sendSyslog(ctx context.Context, channel *dbmodel.NotificationChannel, event *dbmodel.Event) error {
+	config := channel.Syslog
+	if config == nil || len(config.Address) == 0 {
+		return errors.Errorf("syslog server address is not configured in notification channel %s", channel.Name)
+	}
+	network := config.Network
+	if len(network) == 0 {
+		network = "udp"
+	}
+	if network != "udp" && network != "tcp" {
+		return errors.Errorf("unsupported syslog network %s in notification channel %s", network, channel.Name)
+	}
+
+	hostname, _ := os.Hostname()
+	msg := formatSyslogMessage(config, event, hostname, os.Getpid())
+	if network == "tcp" {
+		msg = fmt.Sprintf("%d %s", len(msg), msg)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, config.Address)
+	if err != nil {
+		return errors.Wrapf(err, "problem with connecting to syslog server %s", config.Address)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetWriteDeadline(deadline)
+	}
+	_, err = conn.Write([]byte(msg))
+	if err != nil {
+		return errors.Wrapf(err, "problem with sending message to syslog server %s", config.Address)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package notifications
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbmodel "isc.org/stork/server/database/model"
+)
+
+// Test that the event is formatted as the RFC 5424 message.
+func TestFormatSyslogMessage(t *testing.T) {
+	event := &dbmodel.Event{
+		CreatedAt: time.Date(2020, 6, 1, 12, 0, 0, 5000, time.UTC),
+		Text:      `<subnet id="2" prefix="192.0.2.0/24"> is full`,
+		Level:     dbmodel.EvWarning,
+	}
+	msg := formatSyslogMessage(&dbmodel.SyslogConfig{}, event, "server", 123)
+	require.Equal(t, "<12>1 2020-06-01T12:00:00.000005Z server stork-server 123 event - subnet 192.0.2.0/24 is full", msg)
+
+	event.Level = dbmodel.EvError
+	msg = formatSyslogMessage(&dbmodel.SyslogConfig{Facility: 16}, event, "", 123)
+	require.True(t, strings.HasPrefix(msg, "<131>1 2020-06-01T12:00:00.000005Z - stork-server"))
+
+	event.Level = dbmodel.EvInfo
+	msg = formatSyslogMessage(&dbmodel.SyslogConfig{}, event, "server", 123)
+	require.True(t, strings.HasPrefix(msg, "<14>1 "))
+}
+
+// Test that the message is sent to the syslog server over UDP.
+func TestSendSyslogUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	channel := &dbmodel.NotificationChannel{
+		Name: "syslog",
+		Type: dbmodel.NotificationChannelSyslog,
+		Syslog: &dbmodel.SyslogConfig{
+			Address: conn.LocalAddr().String(),
+		},
+	}
+	err = sendSyslog(context.Background(), channel, &dbmodel.Event{Text: "foo"})
+	require.NoError(t, err)
+
+	buf := make([]byte, 1024)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(buf[:n]), "<14>1 "))
+	require.True(t, strings.HasSuffix(string(buf[:n]), " event - foo"))
+}
+
+// Test that the message is sent to the syslog server over TCP with the
+// octet counting framing.
+func TestSendSyslogTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			received <- ""
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString(0)
+		received <- line
+	}()
+
+	channel := &dbmodel.NotificationChannel{
+		Name: "syslog",
+		Type: dbmodel.NotificationChannelSyslog,
+		Syslog: &dbmodel.SyslogConfig{
+			Network: "tcp",
+			Address: listener.Addr().String(),
+		},
+	}
+	err = sendSyslog(context.Background(), channel, &dbmodel.Event{Text: "foo"})
+	require.NoError(t, err)
+
+	msg := <-received
+	parts := strings.SplitN(msg, " ", 2)
+	require.Len(t, parts, 2)
+	length, err := strconv.Atoi(parts[0])
+	require.NoError(t, err)
+	require.Equal(t, length, len(parts[1]))
+	require.True(t, strings.HasSuffix(parts[1], " event - foo"))
+
+	// Unsupported network.
+	channel.Syslog.Network = "unix"
+	err = sendSyslog(context.Background(), channel, &dbmodel.Event{Text: "foo"})
+	require.Error(t, err)
+}
@@ -0,0 +1,110 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	dbmodel "isc.org/stork/server/database/model"
+)
+
+// Delay before the first retry of the failed webhook post. The delay is
+// doubled before each subsequent retry.
+var webhookRetryDelay = time.Second
+
+// Event sent to the webhook.
+type webhookPayload struct {
+	ID        int64                `json:"id"`
+	CreatedAt time.Time            `json:"createdAt"`
+	Level     int                  `json:"level"`
+	LevelName string               `json:"levelName"`
+	Text      string               `json:"text"`
+	Summary   string               `json:"summary"`
+	Details   string               `json:"details,omitempty"`
+	Code      dbmodel.EventCode    `json:"code,omitempty"`
+	Payload   dbmodel.EventPayload `json:"payload,omitempty"`
+	Relations *dbmodel.Relations   `json:"relations,omitempty"`
+}
+
+// Computes the HMAC-SHA256 signature of the payload in the format sent in
+// the X-Stork-Signature header.
+func signPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Posts the payload to the webhook once.
+func postWebhook(ctx context.Context, config *dbmodel.WebhookConfig, eventID int64, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, config.URL, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrapf(err, "problem with creating request to webhook %s", config.URL)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Stork")
+	req.Header.Set("X-Stork-Event", fmt.Sprintf("%d", eventID))
+	if len(config.Secret) > 0 {
+		req.Header.Set("X-Stork-Signature", signPayload(config.Secret, body))
+	}
+
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "problem with posting event to webhook %s", config.URL)
+	}
+	defer rsp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, rsp.Body)
+	if rsp.StatusCode < 200 || rsp.StatusCode > 299 {
+		return errors.Errorf("webhook %s returned status %d", config.URL, rsp.StatusCode)
+	}
+	return nil
+}
+
+// Posts the event as JSON to the URL configured in the channel. The failed
+// posts are retried with exponential backoff until the number of retries
+// configured in the channel is reached or the context expires.
+func sendWebhook(ctx context.Context, channel *dbmodel.NotificationChannel, event *dbmodel.Event) error {
+	config := channel.Webhook
+	if config == nil || len(config.URL) == 0 {
+		return errors.Errorf("webhook URL is not configured in notification channel %s", channel.Name)
+	}
+	body, err := json.Marshal(&webhookPayload{
+		ID:        event.ID,
+		CreatedAt: event.CreatedAt,
+		Level:     event.Level,
+		LevelName: getLevelName(event.Level),
+		Text:      event.Text,
+		Summary:   getSummary(event),
+		Details:   event.Details,
+		Code:      event.Code,
+		Payload:   event.Payload,
+		Relations: event.Relations,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "problem with serializing event %d", event.ID)
+	}
+
+	delay := webhookRetryDelay
+	for attempt := 0; ; attempt++ {
+		err = postWebhook(ctx, config, event.ID, body)
+		if err == nil || attempt >= config.Retries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
@@ -0,0 +1,118 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbmodel "isc.org/stork/server/database/model"
+)
+
+// Test that the event is posted to the webhook with the signature.
+func TestSendWebhook(t *testing.T) {
+	var body []byte
+	var header http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		header = r.Header
+	}))
+	defer ts.Close()
+
+	channel := &dbmodel.NotificationChannel{
+		Name: "hook",
+		Type: dbmodel.NotificationChannelWebhook,
+		Webhook: &dbmodel.WebhookConfig{
+			URL:    ts.URL,
+			Secret: "secret",
+		},
+	}
+	event := &dbmodel.Event{
+		ID:        7,
+		CreatedAt: time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC),
+		Text:      `<subnet id="2" prefix="192.0.2.0/24"> is full`,
+		Level:     dbmodel.EvWarning,
+		Details:   "details",
+		Code:      dbmodel.EventCodeAlertFired,
+		Payload:   dbmodel.EventPayload{"subnetId": 2},
+		Relations: &dbmodel.Relations{SubnetID: 2},
+	}
+	err := sendWebhook(context.Background(), channel, event)
+	require.NoError(t, err)
+
+	require.Equal(t, "application/json", header.Get("Content-Type"))
+	require.Equal(t, "7", header.Get("X-Stork-Event"))
+	require.Equal(t, signPayload("secret", body), header.Get("X-Stork-Signature"))
+
+	payload := webhookPayload{}
+	err = json.Unmarshal(body, &payload)
+	require.NoError(t, err)
+	require.EqualValues(t, 7, payload.ID)
+	require.Equal(t, event.CreatedAt, payload.CreatedAt)
+	require.Equal(t, dbmodel.EvWarning, payload.Level)
+	require.Equal(t, "warning", payload.LevelName)
+	require.Equal(t, event.Text, payload.Text)
+	require.Equal(t, "subnet 192.0.2.0/24 is full", payload.Summary)
+	require.Equal(t, "details", payload.Details)
+	require.Equal(t, dbmodel.EventCodeAlertFired, payload.Code)
+	require.EqualValues(t, 2, payload.Payload["subnetId"])
+	require.EqualValues(t, 2, payload.Relations.SubnetID)
+
+	// No signature without the secret.
+	channel.Webhook.Secret = ""
+	err = sendWebhook(context.Background(), channel, event)
+	require.NoError(t, err)
+	require.Empty(t, header.Get("X-Stork-Signature"))
+}
+
+// Test that the signature is the HMAC-SHA256 of the payload.
+func TestSignPayload(t *testing.T) {
+	require.Equal(t, "sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8",
+		signPayload("key", []byte("The quick brown fox jumps over the lazy dog")))
+}
+
+// Test that the failed posts are retried.
+func TestSendWebhookRetries(t *testing.T) {
+	defaultDelay := webhookRetryDelay
+	webhookRetryDelay = time.Millisecond
+	defer func() {
+		webhookRetryDelay = defaultDelay
+	}()
+
+	attempts := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	channel := &dbmodel.NotificationChannel{
+		Name: "hook",
+		Type: dbmodel.NotificationChannelWebhook,
+		Webhook: &dbmodel.WebhookConfig{
+			URL:     ts.URL,
+			Retries: 1,
+		},
+	}
+	err := sendWebhook(context.Background(), channel, &dbmodel.Event{})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "returned status 503")
+	require.Equal(t, 2, attempts)
+
+	attempts = 0
+	channel.Webhook.Retries = 2
+	err = sendWebhook(context.Background(), channel, &dbmodel.Event{})
+	require.NoError(t, err)
+	require.Equal(t, 3, attempts)
+
+	// Missing URL.
+	channel.Webhook = nil
+	err = sendWebhook(context.Background(), channel, &dbmodel.Event{})
+	require.Error(t, err)
+}
@@ -0,0 +1,215 @@
+package restservice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/go-openapi/runtime/middleware"
+	"github.com/go-openapi/strfmt"
+	log "github.com/sirupsen/logrus"
+
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/events"
+)
+
+// Convert the alert rule with its states to the format used in REST API.
+func alertRuleToRestAPI(dbRule *dbmodel.AlertRule) *models.AlertRule {
+	rule := &models.AlertRule{
+		ID:         dbRule.ID,
+		CreatedAt:  strfmt.DateTime(dbRule.CreatedAt),
+		Name:       dbRule.Name,
+		Metric:     dbRule.Metric,
+		Operator:   dbRule.Operator,
+		Threshold:  dbRule.Threshold,
+		Hysteresis: dbRule.Hysteresis,
+		Duration:   dbRule.Duration,
+		Level:      int64(dbRule.Level),
+		Enabled:    dbRule.Enabled,
+		States:     []*models.AlertState{},
+	}
+	for _, dbState := range dbRule.States {
+		state := &models.AlertState{
+			ObjectType:   dbState.ObjectType,
+			ObjectID:     dbState.ObjectID,
+			PendingSince: strfmt.DateTime(dbState.PendingSince),
+			Firing:       dbState.Firing,
+			Value:        dbState.Value,
+		}
+		if dbState.Firing {
+			state.FiredAt = strfmt.DateTime(dbState.FiredAt)
+		}
+		rule.States = append(rule.States, state)
+	}
+	return rule
+}
+
+// Convert the alert rule received over REST API to the database model. It
+// returns an error message if the rule is invalid.
+func alertRuleFromRestAPI(rule *models.AlertRule) (*dbmodel.AlertRule, string) {
+	if rule == nil {
+		return nil, "missing alert rule"
+	}
+	if len(rule.Name) == 0 {
+		return nil, "alert rule name must not be empty"
+	}
+	if dbmodel.GetAlertMetricObjectType(rule.Metric) == "" {
+		return nil, fmt.Sprintf("unsupported alert rule metric %s", rule.Metric)
+	}
+	dbRule := &dbmodel.AlertRule{
+		Name:       rule.Name,
+		Metric:     rule.Metric,
+		Operator:   rule.Operator,
+		Threshold:  rule.Threshold,
+		Hysteresis: rule.Hysteresis,
+		Duration:   rule.Duration,
+		Level:      int(rule.Level),
+		Enabled:    rule.Enabled,
+	}
+	switch dbRule.Operator {
+	case ">", ">=", "<", "<=", "==", "!=":
+	default:
+		return nil, fmt.Sprintf("unsupported alert rule operator %s", rule.Operator)
+	}
+	if dbRule.Hysteresis < 0 {
+		return nil, "alert rule hysteresis must not be negative"
+	}
+	if dbRule.Duration < 0 {
+		return nil, "alert rule duration must not be negative"
+	}
+	if dbRule.Level < dbmodel.EvInfo || dbRule.Level > dbmodel.EvError {
+		return nil, fmt.Sprintf("unsupported alert rule level %d", rule.Level)
+	}
+	return dbRule, ""
+}
+
+// Get the list of alert rules with their states.
+func (r *RestAPI) GetAlertRules(ctx context.Context, params events.GetAlertRulesParams) middleware.Responder {
+	dbRules, err := dbmodel.GetAlertRules(r.DB)
+	if err != nil {
+		msg := "cannot get alert rules from db"
+		log.Error(err)
+		rsp := events.NewGetAlertRulesDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	rules := &models.AlertRules{
+		Items: []*models.AlertRule{},
+		Total: int64(len(dbRules)),
+	}
+	for _, dbRule := range dbRules {
+		rules.Items = append(rules.Items, alertRuleToRestAPI(dbRule))
+	}
+	rsp := events.NewGetAlertRulesOK().WithPayload(rules)
+	return rsp
+}
+
+// Get the alert rule with its states by ID.
+func (r *RestAPI) GetAlertRule(ctx context.Context, params events.GetAlertRuleParams) middleware.Responder {
+	dbRule, err := dbmodel.GetAlertRule(r.DB, params.ID)
+	if err != nil {
+		msg := fmt.Sprintf("cannot get alert rule with id %d from db", params.ID)
+		log.Error(err)
+		rsp := events.NewGetAlertRuleDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if dbRule == nil {
+		msg := fmt.Sprintf("cannot find alert rule with id %d", params.ID)
+		rsp := events.NewGetAlertRuleDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	rsp := events.NewGetAlertRuleOK().WithPayload(alertRuleToRestAPI(dbRule))
+	return rsp
+}
+
+// Create new alert rule.
+func (r *RestAPI) CreateAlertRule(ctx context.Context, params events.CreateAlertRuleParams) middleware.Responder {
+	dbRule, msg := alertRuleFromRestAPI(params.Rule)
+	if dbRule == nil {
+		log.Warn(msg)
+		rsp := events.NewCreateAlertRuleDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	err := dbmodel.AddAlertRule(r.DB, dbRule)
+	if err != nil {
+		msg := "cannot store alert rule in db"
+		log.Error(err)
+		rsp := events.NewCreateAlertRuleDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	rsp := events.NewCreateAlertRuleOK().WithPayload(alertRuleToRestAPI(dbRule))
+	return rsp
+}
+
+// Update the alert rule. The firing alerts of the rule are resolved.
+func (r *RestAPI) UpdateAlertRule(ctx context.Context, params events.UpdateAlertRuleParams) middleware.Responder {
+	dbRule, msg := alertRuleFromRestAPI(params.Rule)
+	if dbRule == nil {
+		log.Warn(msg)
+		rsp := events.NewUpdateAlertRuleDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	existing, err := dbmodel.GetAlertRule(r.DB, params.ID)
+	if err != nil {
+		msg := fmt.Sprintf("cannot get alert rule with id %d from db", params.ID)
+		log.Error(err)
+		rsp := events.NewUpdateAlertRuleDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if existing == nil {
+		msg := fmt.Sprintf("cannot find alert rule with id %d", params.ID)
+		rsp := events.NewUpdateAlertRuleDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	dbRule.ID = existing.ID
+	dbRule.CreatedAt = existing.CreatedAt
+	// The alerts of the rule are resolved because the modified rule is
+	// evaluated from scratch.
+	err = r.AlertsEngine.ResolveRule(dbRule.ID, "rule has been modified", func() error {
+		return dbmodel.UpdateAlertRule(r.DB, dbRule)
+	})
+	if err != nil {
+		msg := fmt.Sprintf("cannot update alert rule with id %d", params.ID)
+		log.Error(err)
+		rsp := events.NewUpdateAlertRuleDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	rsp := events.NewUpdateAlertRuleOK().WithPayload(alertRuleToRestAPI(dbRule))
+	return rsp
+}
+
+// Delete the alert rule. The firing alerts of the rule are resolved.
+func (r *RestAPI) DeleteAlertRule(ctx context.Context, params events.DeleteAlertRuleParams) middleware.Responder {
+	err := r.AlertsEngine.ResolveRule(params.ID, "rule has been deleted", func() error {
+		return dbmodel.DeleteAlertRule(r.DB, params.ID)
+	})
+	if err != nil {
+		msg := fmt.Sprintf("cannot delete alert rule with id %d", params.ID)
+		log.Error(err)
+		rsp := events.NewDeleteAlertRuleDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	rsp := events.NewDeleteAlertRuleOK()
+	return rsp
+}
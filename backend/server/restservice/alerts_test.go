@@ -0,0 +1,128 @@
+package restservice
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	agentcommtest "isc.org/stork/server/agentcomm/test"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/events"
+	storktest "isc.org/stork/server/test"
+)
+
+// Check creating, getting, updating and deleting alert rules via rest api
+// functions.
+func TestAlertRules(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	settings := RestAPISettings{}
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	fec := &storktest.FakeEventCenter{}
+	rapi, err := NewRestAPI(&settings, dbSettings, db, fa, fec, nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// Create the rule.
+	rule := &models.AlertRule{
+		Name:       "subnet full",
+		Metric:     dbmodel.AlertMetricSubnetUtilization,
+		Operator:   ">",
+		Threshold:  90,
+		Hysteresis: 5,
+		Duration:   600,
+		Level:      dbmodel.EvWarning,
+		Enabled:    true,
+	}
+	rsp := rapi.CreateAlertRule(ctx, events.CreateAlertRuleParams{Rule: rule})
+	require.IsType(t, &events.CreateAlertRuleOK{}, rsp)
+	createRsp := rsp.(*events.CreateAlertRuleOK)
+	require.NotZero(t, createRsp.Payload.ID)
+	require.Equal(t, "subnet full", createRsp.Payload.Name)
+	id := createRsp.Payload.ID
+
+	// Get the rules.
+	rsp = rapi.GetAlertRules(ctx, events.GetAlertRulesParams{})
+	require.IsType(t, &events.GetAlertRulesOK{}, rsp)
+	rulesRsp := rsp.(*events.GetAlertRulesOK)
+	require.EqualValues(t, 1, rulesRsp.Payload.Total)
+	require.Len(t, rulesRsp.Payload.Items, 1)
+	require.Equal(t, id, rulesRsp.Payload.Items[0].ID)
+	require.Empty(t, rulesRsp.Payload.Items[0].States)
+
+	// Update the firing rule.
+	setFiring := func() {
+		err := dbmodel.SetAlertState(db, &dbmodel.AlertState{
+			RuleID:     id,
+			ObjectType: dbmodel.AlertObjectSubnet,
+			ObjectID:   12345,
+			Firing:     true,
+		})
+		require.NoError(t, err)
+	}
+	setFiring()
+	rule.Threshold = 95
+	rule.Enabled = false
+	rsp = rapi.UpdateAlertRule(ctx, events.UpdateAlertRuleParams{ID: id, Rule: rule})
+	require.IsType(t, &events.UpdateAlertRuleOK{}, rsp)
+
+	// The alert is resolved.
+	require.Len(t, fec.Events, 1)
+	require.Equal(t, dbmodel.EventCodeAlertResolved, fec.Events[0].Code)
+	require.Contains(t, fec.Events[0].Text, "rule has been modified")
+	require.EqualValues(t, id, fec.Events[0].Relations.RuleID)
+
+	rsp = rapi.GetAlertRule(ctx, events.GetAlertRuleParams{ID: id})
+	require.IsType(t, &events.GetAlertRuleOK{}, rsp)
+	ruleRsp := rsp.(*events.GetAlertRuleOK)
+	require.EqualValues(t, 95, ruleRsp.Payload.Threshold)
+	require.False(t, ruleRsp.Payload.Enabled)
+	require.EqualValues(t, 600, ruleRsp.Payload.Duration)
+
+	// Updating non-existing rule.
+	rsp = rapi.UpdateAlertRule(ctx, events.UpdateAlertRuleParams{ID: id + 1, Rule: rule})
+	require.IsType(t, &events.UpdateAlertRuleDefault{}, rsp)
+	updateDefaultRsp := rsp.(*events.UpdateAlertRuleDefault)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*updateDefaultRsp))
+
+	// Invalid rules.
+	invalidRules := []*models.AlertRule{
+		nil,
+		{Metric: dbmodel.AlertMetricSubnetUtilization, Operator: ">"},
+		{Name: "foo", Metric: "foo", Operator: ">"},
+		{Name: "foo", Metric: dbmodel.AlertMetricSubnetUtilization, Operator: "=>"},
+		{Name: "foo", Metric: dbmodel.AlertMetricSubnetUtilization, Operator: ">", Hysteresis: -1},
+		{Name: "foo", Metric: dbmodel.AlertMetricSubnetUtilization, Operator: ">", Duration: -1},
+		{Name: "foo", Metric: dbmodel.AlertMetricSubnetUtilization, Operator: ">", Level: 3},
+	}
+	for _, invalid := range invalidRules {
+		rsp = rapi.CreateAlertRule(ctx, events.CreateAlertRuleParams{Rule: invalid})
+		require.IsType(t, &events.CreateAlertRuleDefault{}, rsp)
+		createDefaultRsp := rsp.(*events.CreateAlertRuleDefault)
+		require.Equal(t, http.StatusBadRequest, getStatusCode(*createDefaultRsp))
+
+		rsp = rapi.UpdateAlertRule(ctx, events.UpdateAlertRuleParams{ID: id, Rule: invalid})
+		require.IsType(t, &events.UpdateAlertRuleDefault{}, rsp)
+		updateDefaultRsp := rsp.(*events.UpdateAlertRuleDefault)
+		require.Equal(t, http.StatusBadRequest, getStatusCode(*updateDefaultRsp))
+	}
+
+	// Delete the firing rule.
+	setFiring()
+	rsp = rapi.DeleteAlertRule(ctx, events.DeleteAlertRuleParams{ID: id})
+	require.IsType(t, &events.DeleteAlertRuleOK{}, rsp)
+
+	// The alert is resolved.
+	require.Len(t, fec.Events, 2)
+	require.Equal(t, dbmodel.EventCodeAlertResolved, fec.Events[1].Code)
+	require.Contains(t, fec.Events[1].Text, "rule has been deleted")
+
+	rsp = rapi.GetAlertRule(ctx, events.GetAlertRuleParams{ID: id})
+	require.IsType(t, &events.GetAlertRuleDefault{}, rsp)
+	getDefaultRsp := rsp.(*events.GetAlertRuleDefault)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*getDefaultRsp))
+}
@@ -2,6 +2,8 @@ package restservice
 
 import (
 	"reflect"
+
+	keactrl "isc.org/stork/appctrl/kea"
 )
 
 // Extracts HTTP status code from the response received via the
@@ -10,3 +12,16 @@ func getStatusCode(rsp interface{}) int {
 	code := int(reflect.ValueOf(rsp).FieldByName("_statusCode").Int())
 	return code
 }
+
+// Returns the function generating the responses to the consecutive
+// commands sent to the Kea servers.
+func mockKeaCmdResponses(responses ...string) func(int, []interface{}) {
+	return func(callNo int, cmdResponses []interface{}) {
+		if callNo >= len(responses) {
+			return
+		}
+		daemons, _ := keactrl.NewDaemons("dhcp4")
+		command, _ := keactrl.NewCommand("config-get", daemons, nil)
+		_ = keactrl.UnmarshalResponseList(command, []byte(responses[callNo]), cmdResponses[0])
+	}
+}
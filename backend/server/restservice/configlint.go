@@ -0,0 +1,122 @@
+package restservice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/go-openapi/runtime/middleware"
+	"github.com/go-openapi/strfmt"
+	log "github.com/sirupsen/logrus"
+
+	"isc.org/stork/server/apps/kea"
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/services"
+)
+
+// Get the issues found in the daemon's configuration by the linter rules.
+func (r *RestAPI) GetDaemonConfigLintFindings(ctx context.Context, params services.GetDaemonConfigLintFindingsParams) middleware.Responder {
+	dbFindings, err := dbmodel.GetConfigLintFindingsByDaemon(r.DB, params.ID)
+	if err != nil {
+		msg := fmt.Sprintf("cannot get config lint findings of daemon with id %d from db", params.ID)
+		log.Error(err)
+		rsp := services.NewGetDaemonConfigLintFindingsDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	findings := &models.ConfigLintFindings{
+		Items: []*models.ConfigLintFinding{},
+		Total: int64(len(dbFindings)),
+	}
+	for _, dbFinding := range dbFindings {
+		findings.Items = append(findings.Items, &models.ConfigLintFinding{
+			ID:        dbFinding.ID,
+			CreatedAt: strfmt.DateTime(dbFinding.CreatedAt),
+			DaemonID:  dbFinding.DaemonID,
+			Rule:      dbFinding.Rule,
+			Text:      dbFinding.Text,
+		})
+	}
+	rsp := services.NewGetDaemonConfigLintFindingsOK().WithPayload(findings)
+	return rsp
+}
+
+// Get all linter rules with their states.
+func (r *RestAPI) GetConfigLintRules(ctx context.Context, params services.GetConfigLintRulesParams) middleware.Responder {
+	disabled, err := dbmodel.GetDisabledConfigLintRules(r.DB)
+	if err != nil {
+		msg := "cannot get config lint rules from db"
+		log.Error(err)
+		rsp := services.NewGetConfigLintRulesDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	rules := &models.ConfigLintRules{
+		Items: []*models.ConfigLintRule{},
+	}
+	for _, rule := range kea.GetConfigLintRules() {
+		rules.Items = append(rules.Items, &models.ConfigLintRule{
+			Name:        rule.Name,
+			Description: rule.Description,
+			Enabled:     !disabled[rule.Name],
+		})
+	}
+	rules.Total = int64(len(rules.Items))
+	rsp := services.NewGetConfigLintRulesOK().WithPayload(rules)
+	return rsp
+}
+
+// Enable or disable the linter rule. The configurations of all Kea DHCP
+// daemons are checked again, so the findings reflect the enabled rules.
+func (r *RestAPI) UpdateConfigLintRule(ctx context.Context, params services.UpdateConfigLintRuleParams) middleware.Responder {
+	// only super-admin can change the rules
+	_, dbUser := r.SessionManager.Logged(ctx)
+	if !dbUser.InGroup(&dbmodel.SystemGroup{ID: dbmodel.SuperAdminGroupID}) {
+		msg := "user is forbidden to change the config lint rules"
+		rsp := services.NewUpdateConfigLintRuleDefault(http.StatusForbidden).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if params.Rule == nil || params.Rule.Enabled == nil {
+		msg := "missing state of the config lint rule"
+		rsp := services.NewUpdateConfigLintRuleDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	rule := kea.GetConfigLintRule(params.Name)
+	if rule == nil {
+		msg := fmt.Sprintf("cannot find config lint rule %s", params.Name)
+		rsp := services.NewUpdateConfigLintRuleDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	enabled := *params.Rule.Enabled
+	err := dbmodel.SetConfigLintRuleEnabled(r.DB, rule.Name, enabled)
+	if err != nil {
+		msg := fmt.Sprintf("cannot update config lint rule %s", rule.Name)
+		log.Error(err)
+		rsp := services.NewUpdateConfigLintRuleDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if err = kea.LintAllDaemonConfigs(r.DB, r.EventCenter); err != nil {
+		log.Error(err)
+	}
+
+	rsp := services.NewUpdateConfigLintRuleOK().WithPayload(&models.ConfigLintRule{
+		Name:        rule.Name,
+		Description: rule.Description,
+		Enabled:     enabled,
+	})
+	return rsp
+}
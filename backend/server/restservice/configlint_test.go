@@ -0,0 +1,121 @@
+package restservice
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	agentcommtest "isc.org/stork/server/agentcomm/test"
+	"isc.org/stork/server/apps/kea"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/services"
+	storktest "isc.org/stork/server/test"
+)
+
+// Check getting the config lint findings and rules and disabling the
+// rules via rest api functions.
+func TestConfigLint(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	settings := RestAPISettings{}
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	fec := &storktest.FakeEventCenter{}
+	rapi, err := NewRestAPI(&settings, dbSettings, db, fa, fec, nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	m := &dbmodel.Machine{
+		Address:   "localhost",
+		AgentPort: 8080,
+	}
+	err = dbmodel.AddMachine(db, m)
+	require.NoError(t, err)
+	daemon := dbmodel.NewKeaDaemon(dbmodel.DaemonNameDHCPv4, true)
+	err = daemon.SetConfigFromJSON(`{"Dhcp4": {}}`)
+	require.NoError(t, err)
+	app := &dbmodel.App{
+		MachineID: m.ID,
+		Machine:   m,
+		Type:      dbmodel.AppTypeKea,
+		Active:    true,
+		Daemons:   []*dbmodel.Daemon{daemon},
+	}
+	err = kea.CommitAppIntoDB(db, app, fec, nil)
+	require.NoError(t, err)
+
+	// Get the findings.
+	rsp := rapi.GetDaemonConfigLintFindings(ctx, services.GetDaemonConfigLintFindingsParams{ID: daemon.ID})
+	require.IsType(t, &services.GetDaemonConfigLintFindingsOK{}, rsp)
+	findings := rsp.(*services.GetDaemonConfigLintFindingsOK).Payload
+	require.EqualValues(t, 2, findings.Total)
+	require.Equal(t, "lease_cmds_missing", findings.Items[0].Rule)
+	require.Equal(t, "stat_cmds_missing", findings.Items[1].Rule)
+
+	// Get the rules.
+	rsp = rapi.GetConfigLintRules(ctx, services.GetConfigLintRulesParams{})
+	require.IsType(t, &services.GetConfigLintRulesOK{}, rsp)
+	rules := rsp.(*services.GetConfigLintRulesOK).Payload
+	require.EqualValues(t, len(kea.GetConfigLintRules()), rules.Total)
+	for _, rule := range rules.Items {
+		require.True(t, rule.Enabled)
+		require.NotEmpty(t, rule.Description)
+	}
+
+	// Only super-admin can change the rules.
+	user := &dbmodel.SystemUser{
+		Login:    "joe",
+		Lastname: "Doe",
+		Name:     "Joe",
+		Password: "pass",
+	}
+	_, err = dbmodel.CreateUser(db, user)
+	require.NoError(t, err)
+	ctx2, err := rapi.SessionManager.Load(ctx, "")
+	require.NoError(t, err)
+	err = rapi.SessionManager.LoginHandler(ctx2, user)
+	require.NoError(t, err)
+	disabled := false
+	params := services.UpdateConfigLintRuleParams{
+		Name: "stat_cmds_missing",
+		Rule: &models.ConfigLintRuleState{Enabled: &disabled},
+	}
+	rsp = rapi.UpdateConfigLintRule(ctx2, params)
+	require.IsType(t, &services.UpdateConfigLintRuleDefault{}, rsp)
+	require.Equal(t, http.StatusForbidden, getStatusCode(*rsp.(*services.UpdateConfigLintRuleDefault)))
+
+	admin, err := dbmodel.GetUserByID(rapi.DB, 1)
+	require.NoError(t, err)
+	ctx, err = rapi.SessionManager.Load(ctx, "")
+	require.NoError(t, err)
+	err = rapi.SessionManager.LoginHandler(ctx, admin)
+	require.NoError(t, err)
+
+	// Unknown rule.
+	rsp = rapi.UpdateConfigLintRule(ctx, services.UpdateConfigLintRuleParams{
+		Name: "foo",
+		Rule: params.Rule,
+	})
+	require.IsType(t, &services.UpdateConfigLintRuleDefault{}, rsp)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*rsp.(*services.UpdateConfigLintRuleDefault)))
+
+	// Disabling the rule removes its findings.
+	rsp = rapi.UpdateConfigLintRule(ctx, params)
+	require.IsType(t, &services.UpdateConfigLintRuleOK{}, rsp)
+	require.False(t, rsp.(*services.UpdateConfigLintRuleOK).Payload.Enabled)
+
+	rsp = rapi.GetDaemonConfigLintFindings(ctx, services.GetDaemonConfigLintFindingsParams{ID: daemon.ID})
+	require.IsType(t, &services.GetDaemonConfigLintFindingsOK{}, rsp)
+	findings = rsp.(*services.GetDaemonConfigLintFindingsOK).Payload
+	require.EqualValues(t, 1, findings.Total)
+	require.Equal(t, "lease_cmds_missing", findings.Items[0].Rule)
+
+	rsp = rapi.GetConfigLintRules(ctx, services.GetConfigLintRulesParams{})
+	require.IsType(t, &services.GetConfigLintRulesOK{}, rsp)
+	for _, rule := range rsp.(*services.GetConfigLintRulesOK).Payload.Items {
+		require.Equal(t, rule.Name != "stat_cmds_missing", rule.Enabled)
+	}
+}
@@ -0,0 +1,150 @@
+package restservice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-openapi/runtime/middleware"
+	log "github.com/sirupsen/logrus"
+
+	"isc.org/stork/server/apps/kea"
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/services"
+)
+
+// Maximum time of pushing the configuration to the daemon, including
+// the rollback.
+const configPushTimeout = 2 * time.Minute
+
+// Push the configuration to the Kea DHCP daemon. The configuration of
+// the latest revision of the daemon is restored if the pushed
+// configuration cannot be applied or verified. If the configuration is
+// applied and the config_push_grace_period setting is not 0, the daemon
+// is watched in the background during the grace period and the
+// configuration is rolled back if the daemon fails.
+func (r *RestAPI) PushDaemonConfig(ctx context.Context, params services.PushDaemonConfigParams) middleware.Responder {
+	// only super-admin can push the configuration
+	_, dbUser := r.SessionManager.Logged(ctx)
+	if !dbUser.InGroup(&dbmodel.SystemGroup{ID: dbmodel.SuperAdminGroupID}) {
+		msg := "user is forbidden to push the configuration"
+		rsp := services.NewPushDaemonConfigDefault(http.StatusForbidden).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	var rawConfig map[string]interface{}
+	if params.Push != nil {
+		rawConfig, _ = params.Push.Config.(map[string]interface{})
+	}
+	if rawConfig == nil {
+		msg := "missing configuration to push"
+		rsp := services.NewPushDaemonConfigDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	dbDaemon, err := dbmodel.GetDaemonByID(r.DB, params.ID)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("cannot get daemon with id %d from db", params.ID)
+		rsp := services.NewPushDaemonConfigDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if dbDaemon == nil {
+		msg := fmt.Sprintf("cannot find daemon with id %d", params.ID)
+		rsp := services.NewPushDaemonConfigDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	// The app is fetched with the access points which are required to
+	// communicate with the daemon.
+	dbApp, err := dbmodel.GetAppByID(r.DB, dbDaemon.AppID)
+	if err != nil || dbApp == nil {
+		log.Error(err)
+		msg := fmt.Sprintf("cannot get app with id %d from db", dbDaemon.AppID)
+		rsp := services.NewPushDaemonConfigDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	for _, d := range dbApp.Daemons {
+		if d.ID == dbDaemon.ID {
+			dbDaemon = d
+			break
+		}
+	}
+	dbDaemon.App = dbApp
+
+	// The configuration of the latest revision is restored when the push
+	// fails.
+	revision, err := dbmodel.GetLatestKeaConfigRevision(r.DB, dbDaemon.ID)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("cannot get latest config revision of daemon with id %d from db", dbDaemon.ID)
+		rsp := services.NewPushDaemonConfigDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	push := &kea.ConfigPush{
+		Daemon: dbDaemon,
+		Config: dbmodel.NewKeaConfig(&rawConfig),
+		Write:  params.Push.Write,
+		User:   dbUser,
+	}
+	if revision != nil {
+		push.Previous = revision.Config
+	} else if dbDaemon.KeaDaemon != nil {
+		push.Previous = dbDaemon.KeaDaemon.Config
+	}
+
+	// The push runs on the context detached from the request, so the
+	// client disconnecting after config-set doesn't cancel the rollback
+	// and leave the daemon with the unverified configuration.
+	pushCtx, cancel := context.WithTimeout(r.ctx, configPushTimeout)
+	defer cancel()
+	result, err := push.Push(pushCtx, r.Agents, r.EventCenter)
+	if err != nil {
+		msg := fmt.Sprintf("cannot push the configuration to daemon with id %d: %s", dbDaemon.ID, err)
+		log.Warn(msg)
+		rsp := services.NewPushDaemonConfigDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	payload := &models.ConfigPushResult{
+		Status: result.Status,
+		Steps:  []*models.ConfigPushStep{},
+	}
+	for _, step := range result.Steps {
+		payload.Steps = append(payload.Steps, &models.ConfigPushStep{
+			Command: step.Command,
+			Result:  int64(step.Result),
+			Text:    step.Text,
+		})
+	}
+
+	if result.Status == kea.ConfigPushApplied {
+		gracePeriod, err := dbmodel.GetSettingInt(r.DB, "config_push_grace_period")
+		if err != nil {
+			log.Errorf("cannot get the grace period of the configuration push, the daemon is not watched: %+v", err)
+		} else if gracePeriod > 0 {
+			payload.GracePeriod = gracePeriod
+			go push.Watch(r.ctx, r.Agents, r.EventCenter,
+				time.Duration(gracePeriod)*time.Second, kea.ConfigPushCheckInterval)
+		}
+	}
+
+	rsp := services.NewPushDaemonConfigOK().WithPayload(payload)
+	return rsp
+}
@@ -0,0 +1,146 @@
+package restservice
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	keactrl "isc.org/stork/appctrl/kea"
+	agentcommtest "isc.org/stork/server/agentcomm/test"
+	"isc.org/stork/server/apps/kea"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/services"
+	storktest "isc.org/stork/server/test"
+)
+
+// Generates the responses to config-test, config-set and config-get
+// sent while pushing the configuration. The first push is rejected.
+func mockConfigPush(callNo int, cmdResponses []interface{}) {
+	daemons, _ := keactrl.NewDaemons("dhcp4")
+	command, _ := keactrl.NewCommand("config-get", daemons, nil)
+	var json string
+	switch callNo {
+	case 0:
+		json = `[{"result": 1, "text": "unsupported parameter 'foo'"}]`
+	case 1, 2:
+		json = `[{"result": 0, "text": "OK"}]`
+	case 3:
+		json = `[{"result": 0, "arguments": {"Dhcp4": {"valid-lifetime": 2000}}}]`
+	}
+	_ = keactrl.UnmarshalResponseList(command, []byte(json), cmdResponses[0])
+}
+
+// Test pushing the configuration to the Kea daemon via rest api functions.
+func TestPushDaemonConfig(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	settings := RestAPISettings{}
+	fa := agentcommtest.NewFakeAgents(mockConfigPush, nil)
+	fec := &storktest.FakeEventCenter{}
+	rapi, err := NewRestAPI(&settings, dbSettings, db, fa, fec, nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// The daemon is not watched after the push.
+	err = dbmodel.SetSettingInt(db, "config_push_grace_period", 0)
+	require.NoError(t, err)
+
+	m := &dbmodel.Machine{
+		Address:   "localhost",
+		AgentPort: 8080,
+	}
+	err = dbmodel.AddMachine(db, m)
+	require.NoError(t, err)
+	accessPoints := []*dbmodel.AccessPoint{}
+	accessPoints = dbmodel.AppendAccessPoint(accessPoints, dbmodel.AccessPointControl, "localhost", "", 8000)
+	app := &dbmodel.App{
+		MachineID:    m.ID,
+		Type:         dbmodel.AppTypeKea,
+		AccessPoints: accessPoints,
+		Daemons: []*dbmodel.Daemon{
+			dbmodel.NewKeaDaemon(dbmodel.DaemonNameDHCPv4, true),
+		},
+	}
+	_, err = dbmodel.AddApp(db, app)
+	require.NoError(t, err)
+	daemon := app.Daemons[0]
+	err = daemon.SetConfigFromJSON(`{"Dhcp4": {"valid-lifetime": 1000}}`)
+	require.NoError(t, err)
+	_, _, err = dbmodel.AddKeaConfigRevisionIfChanged(db, daemon)
+	require.NoError(t, err)
+
+	params := services.PushDaemonConfigParams{
+		ID: daemon.ID,
+		Push: &models.ConfigPushReq{
+			Config: map[string]interface{}{
+				"Dhcp4": map[string]interface{}{
+					"valid-lifetime": 2000,
+				},
+			},
+		},
+	}
+
+	// Only super-admin can push the configuration.
+	user := &dbmodel.SystemUser{
+		Login:    "joe",
+		Lastname: "Doe",
+		Name:     "Joe",
+		Password: "pass",
+	}
+	_, err = dbmodel.CreateUser(db, user)
+	require.NoError(t, err)
+	ctx2, err := rapi.SessionManager.Load(ctx, "")
+	require.NoError(t, err)
+	err = rapi.SessionManager.LoginHandler(ctx2, user)
+	require.NoError(t, err)
+	rsp := rapi.PushDaemonConfig(ctx2, params)
+	require.IsType(t, &services.PushDaemonConfigDefault{}, rsp)
+	require.Equal(t, http.StatusForbidden, getStatusCode(*rsp.(*services.PushDaemonConfigDefault)))
+	require.Empty(t, fa.RecordedCommands)
+
+	admin, err := dbmodel.GetUserByID(rapi.DB, 1)
+	require.NoError(t, err)
+	ctx, err = rapi.SessionManager.Load(ctx, "")
+	require.NoError(t, err)
+	err = rapi.SessionManager.LoginHandler(ctx, admin)
+	require.NoError(t, err)
+
+	// The configuration is rejected by config-test.
+	rsp = rapi.PushDaemonConfig(ctx, params)
+	require.IsType(t, &services.PushDaemonConfigOK{}, rsp)
+	result := rsp.(*services.PushDaemonConfigOK).Payload
+	require.Equal(t, kea.ConfigPushRejected, result.Status)
+	require.Len(t, result.Steps, 1)
+	require.Equal(t, "unsupported parameter 'foo'", result.Steps[0].Text)
+	require.Len(t, fec.Events, 1)
+	require.Equal(t, dbmodel.EventCodeConfigRejected, fec.Events[0].Code)
+	require.EqualValues(t, admin.ID, fec.Events[0].Relations.UserID)
+
+	// The configuration is applied.
+	rsp = rapi.PushDaemonConfig(ctx, params)
+	require.IsType(t, &services.PushDaemonConfigOK{}, rsp)
+	result = rsp.(*services.PushDaemonConfigOK).Payload
+	require.Equal(t, kea.ConfigPushApplied, result.Status)
+	require.Len(t, result.Steps, 3)
+	require.Zero(t, result.GracePeriod)
+	require.Len(t, fec.Events, 2)
+	require.Equal(t, dbmodel.EventCodeConfigApplied, fec.Events[1].Code)
+
+	// The configuration meant for another daemon.
+	params.Push.Config = map[string]interface{}{
+		"Dhcp6": map[string]interface{}{},
+	}
+	rsp = rapi.PushDaemonConfig(ctx, params)
+	require.IsType(t, &services.PushDaemonConfigDefault{}, rsp)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*rsp.(*services.PushDaemonConfigDefault)))
+
+	// Non-existing daemon.
+	params.ID = daemon.ID + 1
+	rsp = rapi.PushDaemonConfig(ctx, params)
+	require.IsType(t, &services.PushDaemonConfigDefault{}, rsp)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*rsp.(*services.PushDaemonConfigDefault)))
+}
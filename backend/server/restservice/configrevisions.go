@@ -0,0 +1,153 @@
+package restservice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/go-openapi/runtime/middleware"
+	"github.com/go-openapi/strfmt"
+	log "github.com/sirupsen/logrus"
+
+	keaconfig "isc.org/stork/appcfg/kea"
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/services"
+)
+
+// Convert the config revision to the format used in REST API. The
+// configuration is included if it has been fetched.
+func configRevisionToRestAPI(dbRevision *dbmodel.KeaConfigRevision) *models.ConfigRevision {
+	revision := &models.ConfigRevision{
+		ID:         dbRevision.ID,
+		CreatedAt:  strfmt.DateTime(dbRevision.CreatedAt),
+		DaemonID:   dbRevision.DaemonID,
+		ConfigHash: dbRevision.ConfigHash,
+	}
+	if dbRevision.Config != nil {
+		revision.Config = dbRevision.Config
+	}
+	return revision
+}
+
+// Get the revisions of the daemon's configuration, from the newest to the
+// oldest.
+func (r *RestAPI) GetDaemonConfigRevisions(ctx context.Context, params services.GetDaemonConfigRevisionsParams) middleware.Responder {
+	var start int64 = 0
+	if params.Start != nil {
+		start = *params.Start
+	}
+
+	var limit int64 = 10
+	if params.Limit != nil {
+		limit = *params.Limit
+	}
+
+	dbRevisions, total, err := dbmodel.GetKeaConfigRevisionsByPage(r.DB, params.ID, start, limit)
+	if err != nil {
+		msg := fmt.Sprintf("cannot get config revisions of daemon with id %d from db", params.ID)
+		log.Error(err)
+		rsp := services.NewGetDaemonConfigRevisionsDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	revisions := &models.ConfigRevisions{
+		Items: []*models.ConfigRevision{},
+		Total: total,
+	}
+	for i := range dbRevisions {
+		revisions.Items = append(revisions.Items, configRevisionToRestAPI(&dbRevisions[i]))
+	}
+	rsp := services.NewGetDaemonConfigRevisionsOK().WithPayload(revisions)
+	return rsp
+}
+
+// Get the config revision with the configuration by ID.
+func (r *RestAPI) GetConfigRevision(ctx context.Context, params services.GetConfigRevisionParams) middleware.Responder {
+	dbRevision, err := dbmodel.GetKeaConfigRevisionByID(r.DB, params.ID)
+	if err != nil {
+		msg := fmt.Sprintf("cannot get config revision with id %d from db", params.ID)
+		log.Error(err)
+		rsp := services.NewGetConfigRevisionDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if dbRevision == nil {
+		msg := fmt.Sprintf("cannot find config revision with id %d", params.ID)
+		rsp := services.NewGetConfigRevisionDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	rsp := services.NewGetConfigRevisionOK().WithPayload(configRevisionToRestAPI(dbRevision))
+	return rsp
+}
+
+// Get the structural differences between the base config revision and
+// the config revision. The base revision defaults to the revision of the
+// same daemon preceding the revision. If there is no such revision, the
+// whole configuration is reported as added.
+func (r *RestAPI) GetConfigRevisionDiff(ctx context.Context, params services.GetConfigRevisionDiffParams) middleware.Responder {
+	dbRevision, err := dbmodel.GetKeaConfigRevisionByID(r.DB, params.ID)
+	if err != nil {
+		msg := fmt.Sprintf("cannot get config revision with id %d from db", params.ID)
+		log.Error(err)
+		rsp := services.NewGetConfigRevisionDiffDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if dbRevision == nil {
+		msg := fmt.Sprintf("cannot find config revision with id %d", params.ID)
+		rsp := services.NewGetConfigRevisionDiffDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	var dbBase *dbmodel.KeaConfigRevision
+	if params.Base != nil {
+		dbBase, err = dbmodel.GetKeaConfigRevisionByID(r.DB, *params.Base)
+		if err == nil && dbBase == nil {
+			msg := fmt.Sprintf("cannot find config revision with id %d", *params.Base)
+			rsp := services.NewGetConfigRevisionDiffDefault(http.StatusNotFound).WithPayload(&models.APIError{
+				Message: &msg,
+			})
+			return rsp
+		}
+	} else {
+		dbBase, err = dbmodel.GetPreviousKeaConfigRevision(r.DB, dbRevision)
+	}
+	if err != nil {
+		msg := fmt.Sprintf("cannot get base config revision for revision with id %d from db", params.ID)
+		log.Error(err)
+		rsp := services.NewGetConfigRevisionDiffDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	diff := &models.ConfigRevisionDiff{
+		RevisionID: dbRevision.ID,
+		Changes:    []*models.ConfigChange{},
+	}
+	var baseConfig *dbmodel.KeaConfig
+	if dbBase != nil {
+		diff.BaseRevisionID = dbBase.ID
+		baseConfig = dbBase.Config
+	}
+	for _, change := range keaconfig.DiffConfigs(baseConfig, dbRevision.Config) {
+		diff.Changes = append(diff.Changes, &models.ConfigChange{
+			Element:   change.Element,
+			Operation: change.Operation,
+			Path:      change.Path,
+			Old:       change.Old,
+			New:       change.New,
+		})
+	}
+	rsp := services.NewGetConfigRevisionDiffOK().WithPayload(diff)
+	return rsp
+}
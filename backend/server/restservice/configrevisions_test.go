@@ -0,0 +1,120 @@
+package restservice
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	keaconfig "isc.org/stork/appcfg/kea"
+	agentcommtest "isc.org/stork/server/agentcomm/test"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	"isc.org/stork/server/gen/restapi/operations/services"
+	storktest "isc.org/stork/server/test"
+)
+
+// Check listing the config revisions, getting the revision and the diffs
+// between the revisions via rest api functions.
+func TestConfigRevisions(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	settings := RestAPISettings{}
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	fec := &storktest.FakeEventCenter{}
+	rapi, err := NewRestAPI(&settings, dbSettings, db, fa, fec, nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	m := &dbmodel.Machine{
+		Address:   "localhost",
+		AgentPort: 8080,
+	}
+	err = dbmodel.AddMachine(db, m)
+	require.NoError(t, err)
+	app := &dbmodel.App{
+		MachineID: m.ID,
+		Type:      dbmodel.AppTypeKea,
+		Daemons: []*dbmodel.Daemon{
+			dbmodel.NewKeaDaemon(dbmodel.DaemonNameDHCPv4, true),
+		},
+	}
+	_, err = dbmodel.AddApp(db, app)
+	require.NoError(t, err)
+	daemon := app.Daemons[0]
+
+	var ids []int64
+	for _, config := range []string{
+		`{"Dhcp4": {"subnet4": [{"subnet": "192.0.2.0/24"}]}}`,
+		`{"Dhcp4": {"subnet4": [{"subnet": "192.0.2.0/24", "pools": [{"pool": "192.0.2.10-192.0.2.20"}]}]}}`,
+		`{"Dhcp4": {"subnet4": [{"subnet": "198.51.100.0/24"}]}}`,
+	} {
+		err = daemon.SetConfigFromJSON(config)
+		require.NoError(t, err)
+		added, _, err := dbmodel.AddKeaConfigRevisionIfChanged(db, daemon)
+		require.NoError(t, err)
+		require.NotNil(t, added)
+		ids = append(ids, added.ID)
+	}
+
+	// List the revisions.
+	limit := int64(2)
+	rsp := rapi.GetDaemonConfigRevisions(ctx, services.GetDaemonConfigRevisionsParams{ID: daemon.ID, Limit: &limit})
+	require.IsType(t, &services.GetDaemonConfigRevisionsOK{}, rsp)
+	revisions := rsp.(*services.GetDaemonConfigRevisionsOK).Payload
+	require.EqualValues(t, 3, revisions.Total)
+	require.Len(t, revisions.Items, 2)
+	require.Equal(t, ids[2], revisions.Items[0].ID)
+	require.Nil(t, revisions.Items[0].Config)
+
+	// Get the revision.
+	rsp = rapi.GetConfigRevision(ctx, services.GetConfigRevisionParams{ID: ids[0]})
+	require.IsType(t, &services.GetConfigRevisionOK{}, rsp)
+	revision := rsp.(*services.GetConfigRevisionOK).Payload
+	require.Equal(t, daemon.ID, revision.DaemonID)
+	require.NotNil(t, revision.Config)
+
+	rsp = rapi.GetConfigRevision(ctx, services.GetConfigRevisionParams{ID: ids[2] + 1})
+	require.IsType(t, &services.GetConfigRevisionDefault{}, rsp)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*rsp.(*services.GetConfigRevisionDefault)))
+
+	// The diff against the previous revision by default.
+	rsp = rapi.GetConfigRevisionDiff(ctx, services.GetConfigRevisionDiffParams{ID: ids[1]})
+	require.IsType(t, &services.GetConfigRevisionDiffOK{}, rsp)
+	diff := rsp.(*services.GetConfigRevisionDiffOK).Payload
+	require.Equal(t, ids[0], diff.BaseRevisionID)
+	require.Equal(t, ids[1], diff.RevisionID)
+	require.Len(t, diff.Changes, 2)
+	require.Equal(t, keaconfig.ConfigElementSubnet, diff.Changes[0].Element)
+	require.Equal(t, keaconfig.ConfigChangeModified, diff.Changes[0].Operation)
+	require.Equal(t, keaconfig.ConfigElementPool, diff.Changes[1].Element)
+	require.Equal(t, keaconfig.ConfigChangeAdded, diff.Changes[1].Operation)
+	require.Equal(t, "/Dhcp4/subnet4[192.0.2.0/24]/pools[192.0.2.10-192.0.2.20]", diff.Changes[1].Path)
+
+	// The diff against the specified revision.
+	rsp = rapi.GetConfigRevisionDiff(ctx, services.GetConfigRevisionDiffParams{ID: ids[2], Base: &ids[0]})
+	require.IsType(t, &services.GetConfigRevisionDiffOK{}, rsp)
+	diff = rsp.(*services.GetConfigRevisionDiffOK).Payload
+	require.Equal(t, ids[0], diff.BaseRevisionID)
+	require.Len(t, diff.Changes, 2)
+	require.Equal(t, keaconfig.ConfigChangeRemoved, diff.Changes[0].Operation)
+	require.Equal(t, "/Dhcp4/subnet4[192.0.2.0/24]", diff.Changes[0].Path)
+	require.Equal(t, keaconfig.ConfigChangeAdded, diff.Changes[1].Operation)
+	require.Equal(t, "/Dhcp4/subnet4[198.51.100.0/24]", diff.Changes[1].Path)
+
+	// The first revision has no base revision.
+	rsp = rapi.GetConfigRevisionDiff(ctx, services.GetConfigRevisionDiffParams{ID: ids[0]})
+	require.IsType(t, &services.GetConfigRevisionDiffOK{}, rsp)
+	diff = rsp.(*services.GetConfigRevisionDiffOK).Payload
+	require.Zero(t, diff.BaseRevisionID)
+	require.Len(t, diff.Changes, 1)
+	require.Equal(t, keaconfig.ConfigChangeAdded, diff.Changes[0].Operation)
+	require.Equal(t, "/Dhcp4", diff.Changes[0].Path)
+
+	// Non-existing base revision.
+	base := ids[2] + 1
+	rsp = rapi.GetConfigRevisionDiff(ctx, services.GetConfigRevisionDiffParams{ID: ids[2], Base: &base})
+	require.IsType(t, &services.GetConfigRevisionDiffDefault{}, rsp)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*rsp.(*services.GetConfigRevisionDiffDefault)))
+}
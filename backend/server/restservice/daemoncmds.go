@@ -0,0 +1,33 @@
+package restservice
+
+import (
+	"context"
+
+	"isc.org/stork/server/apps/kea"
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/gen/models"
+)
+
+// Checks if the logged user is super-admin which is allowed to modify
+// the DHCP configuration, e.g. host reservations and subnets.
+func (r *RestAPI) canModifyDHCP(ctx context.Context) bool {
+	_, dbUser := r.SessionManager.Logged(ctx)
+	return dbUser.InGroup(&dbmodel.SystemGroup{ID: dbmodel.SuperAdminGroupID})
+}
+
+// Converts the outcomes of the commands sent to the Kea daemons to the
+// format used in REST API.
+func daemonCmdsResultsToRestAPI(results []kea.DaemonCmdsResult) []*models.DaemonCommandResult {
+	converted := []*models.DaemonCommandResult{}
+	for _, result := range results {
+		converted = append(converted, &models.DaemonCommandResult{
+			AppID:   result.Target.App.ID,
+			AppName: result.Target.App.Name,
+			Daemon:  result.Target.DaemonName,
+			Command: result.Command,
+			Result:  int64(result.Result),
+			Text:    result.Text,
+		})
+	}
+	return converted
+}
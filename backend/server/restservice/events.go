@@ -3,6 +3,7 @@ package restservice
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"github.com/go-openapi/runtime/middleware"
 	"github.com/go-openapi/strfmt"
@@ -11,11 +12,42 @@ import (
 	dbmodel "isc.org/stork/server/database/model"
 	"isc.org/stork/server/gen/models"
 	"isc.org/stork/server/gen/restapi/operations/events"
+	storkutil "isc.org/stork/util"
 )
 
-func (r *RestAPI) getEvents(offset, limit int64, level int64, daemonType *string, appType *string, machineID *int64, userID *int64, sortField string, sortDir dbmodel.SortDirEnum) (*models.Events, error) {
+// Convert the event to the format used in REST API.
+func eventToRestAPI(dbEvent *dbmodel.Event) *models.Event {
+	event := &models.Event{
+		ID:             dbEvent.ID,
+		CreatedAt:      strfmt.DateTime(dbEvent.CreatedAt),
+		Text:           dbEvent.Text,
+		Level:          int64(dbEvent.Level),
+		Details:        dbEvent.Details,
+		Code:           string(dbEvent.Code),
+		Occurrences:    dbEvent.Occurrences,
+		State:          dbEvent.State,
+		AcknowledgedBy: int64(dbEvent.AcknowledgedByID),
+		Comment:        dbEvent.Comment,
+		IncidentID:     dbEvent.IncidentID,
+	}
+	if dbEvent.Payload != nil {
+		event.Payload = map[string]interface{}(dbEvent.Payload)
+	}
+	if !dbEvent.LastSeenAt.IsZero() {
+		event.LastSeenAt = strfmt.DateTime(dbEvent.LastSeenAt)
+	}
+	if !dbEvent.AcknowledgedAt.IsZero() {
+		event.AcknowledgedAt = strfmt.DateTime(dbEvent.AcknowledgedAt)
+	}
+	if !dbEvent.ResolvedAt.IsZero() {
+		event.ResolvedAt = strfmt.DateTime(dbEvent.ResolvedAt)
+	}
+	return event
+}
+
+func (r *RestAPI) getEvents(offset, limit int64, level int64, daemonType *string, appType *string, machineID *int64, userID *int64, code *string, sortField string, sortDir dbmodel.SortDirEnum) (*models.Events, error) {
 	// Get the events from the database.
-	dbEvents, total, err := dbmodel.GetEventsByPage(r.DB, offset, limit, level, daemonType, appType, machineID, userID, sortField, sortDir)
+	dbEvents, total, err := dbmodel.GetEventsByPage(r.DB, offset, limit, level, daemonType, appType, machineID, userID, code, sortField, sortDir)
 	if err != nil {
 		return nil, err
 	}
@@ -25,15 +57,8 @@ func (r *RestAPI) getEvents(offset, limit int64, level int64, daemonType *string
 	}
 
 	// Convert events fetched from the database to REST.
-	for _, dbEvent := range dbEvents {
-		event := models.Event{
-			ID:        dbEvent.ID,
-			CreatedAt: strfmt.DateTime(dbEvent.CreatedAt),
-			Text:      dbEvent.Text,
-			Level:     int64(dbEvent.Level),
-			Details:   dbEvent.Details,
-		}
-		events.Items = append(events.Items, &event)
+	for i := range dbEvents {
+		events.Items = append(events.Items, eventToRestAPI(&dbEvents[i]))
 	}
 
 	return events, nil
@@ -57,7 +82,7 @@ func (r *RestAPI) GetEvents(ctx context.Context, params events.GetEventsParams)
 	}
 
 	// get events from db
-	eventRecs, err := r.getEvents(start, limit, level, params.DaemonType, params.AppType, params.Machine, params.User, "created_at", dbmodel.SortDirDesc)
+	eventRecs, err := r.getEvents(start, limit, level, params.DaemonType, params.AppType, params.Machine, params.User, params.Code, "created_at", dbmodel.SortDirDesc)
 	if err != nil {
 		msg := "problem with fetching events from the database"
 		log.Error(err)
@@ -71,3 +96,60 @@ func (r *RestAPI) GetEvents(ctx context.Context, params events.GetEventsParams)
 	rsp := events.NewGetEventsOK().WithPayload(eventRecs)
 	return rsp
 }
+
+// Maximum number of events returned by a single export request. The
+// larger exports must be fetched page by page.
+const eventsExportMaxLimit = 10000
+
+// Export the page of the events created within the specified time range.
+// The page size is capped, so a wide time range does not make the server
+// hold all events in memory at once.
+func (r *RestAPI) ExportEvents(ctx context.Context, params events.ExportEventsParams) middleware.Responder {
+	from := time.Time(params.From)
+	to := storkutil.UTCNow()
+	if params.To != nil {
+		to = time.Time(*params.To)
+	}
+	if !from.Before(to) {
+		msg := "beginning of the time range must be before its end"
+		rsp := events.NewExportEventsDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	var level int64 = 0
+	if params.Level != nil {
+		level = *params.Level
+	}
+
+	var start int64 = 0
+	if params.Start != nil && *params.Start > 0 {
+		start = *params.Start
+	}
+
+	var limit int64 = eventsExportMaxLimit
+	if params.Limit != nil && *params.Limit > 0 && *params.Limit < eventsExportMaxLimit {
+		limit = *params.Limit
+	}
+
+	dbEvents, total, err := dbmodel.GetEventsByTimeRange(r.DB, from.UTC(), to.UTC(), level, start, limit)
+	if err != nil {
+		msg := "problem with fetching events from the database"
+		log.Error(err)
+		rsp := events.NewExportEventsDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	eventRecs := &models.Events{
+		Items: []*models.Event{},
+		Total: total,
+	}
+	for i := range dbEvents {
+		eventRecs.Items = append(eventRecs.Items, eventToRestAPI(&dbEvents[i]))
+	}
+	rsp := events.NewExportEventsOK().WithPayload(eventRecs)
+	return rsp
+}
@@ -2,8 +2,11 @@ package restservice
 
 import (
 	"context"
+	"net/http"
 	"testing"
+	"time"
 
+	"github.com/go-openapi/strfmt"
 	"github.com/stretchr/testify/require"
 	agentcommtest "isc.org/stork/server/agentcomm/test"
 	dbmodel "isc.org/stork/server/database/model"
@@ -48,3 +51,75 @@ func TestEvents(t *testing.T) {
 	require.EqualValues(t, "some event", ev2.Text)
 	require.EqualValues(t, dbmodel.EvInfo, ev2.Level)
 }
+
+// Check exporting events created within a time range.
+func TestExportEvents(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		ev := &dbmodel.Event{
+			CreatedAt: now.Add(-time.Duration(i) * time.Hour),
+			Text:      "some event",
+			Level:     i,
+		}
+		err := dbmodel.AddEvent(db, ev)
+		require.NoError(t, err)
+	}
+
+	settings := RestAPISettings{}
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	fec := &storktest.FakeEventCenter{}
+	rapi, err := NewRestAPI(&settings, dbSettings, db, fa, fec, nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// All events since the given time.
+	params := events.ExportEventsParams{
+		From: strfmt.DateTime(now.Add(-90 * time.Minute)),
+	}
+	rsp := rapi.ExportEvents(ctx, params)
+	require.IsType(t, &events.ExportEventsOK{}, rsp)
+	okRsp := rsp.(*events.ExportEventsOK)
+	require.EqualValues(t, 2, okRsp.Payload.Total)
+	require.Len(t, okRsp.Payload.Items, 2)
+	require.Less(t, okRsp.Payload.Items[0].ID, okRsp.Payload.Items[1].ID)
+
+	// The time range and the level.
+	to := strfmt.DateTime(now.Add(-30 * time.Minute))
+	var level int64 = 1
+	params = events.ExportEventsParams{
+		From:  strfmt.DateTime(now.Add(-3 * time.Hour)),
+		To:    &to,
+		Level: &level,
+	}
+	rsp = rapi.ExportEvents(ctx, params)
+	require.IsType(t, &events.ExportEventsOK{}, rsp)
+	okRsp = rsp.(*events.ExportEventsOK)
+	require.EqualValues(t, 2, okRsp.Payload.Total)
+
+	// The page of the events.
+	var start int64 = 1
+	var limit int64 = 1
+	params = events.ExportEventsParams{
+		From:  strfmt.DateTime(now.Add(-3 * time.Hour)),
+		Start: &start,
+		Limit: &limit,
+	}
+	rsp = rapi.ExportEvents(ctx, params)
+	require.IsType(t, &events.ExportEventsOK{}, rsp)
+	okRsp = rsp.(*events.ExportEventsOK)
+	require.EqualValues(t, 3, okRsp.Payload.Total)
+	require.Len(t, okRsp.Payload.Items, 1)
+
+	// Invalid time range.
+	params = events.ExportEventsParams{
+		From: strfmt.DateTime(now),
+		To:   &to,
+	}
+	rsp = rapi.ExportEvents(ctx, params)
+	require.IsType(t, &events.ExportEventsDefault{}, rsp)
+	defaultRsp := rsp.(*events.ExportEventsDefault)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*defaultRsp))
+}
@@ -2,17 +2,135 @@ package restservice
 
 import (
 	"context"
+	"encoding/hex"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/go-openapi/runtime/middleware"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 
+	"isc.org/stork/server/apps/kea"
 	dbmodel "isc.org/stork/server/database/model"
 	"isc.org/stork/server/gen/models"
 	dhcp "isc.org/stork/server/gen/restapi/operations/d_h_c_p"
 	storkutil "isc.org/stork/util"
 )
 
+// Converts the host fetched from the database to the format used in REST API.
+func hostToRestAPI(dbHost *dbmodel.Host) *models.Host {
+	host := &models.Host{
+		ID:            dbHost.ID,
+		SubnetID:      dbHost.SubnetID,
+		Hostname:      dbHost.Hostname,
+		ClientClasses: dbHost.ClientClasses,
+	}
+	// Include subnet prefix if this is subnet specific host.
+	if dbHost.Subnet != nil {
+		host.SubnetPrefix = dbHost.Subnet.Prefix
+	}
+	// Convert DHCP host identifiers.
+	for _, dbHostID := range dbHost.HostIdentifiers {
+		hostID := models.HostIdentifier{
+			IDType:     dbHostID.Type,
+			IDHexValue: dbHostID.ToHex(":"),
+		}
+		host.HostIdentifiers = append(host.HostIdentifiers, &hostID)
+	}
+	// Convert IP reservations.
+	for _, dbHostIP := range dbHost.IPReservations {
+		ip, prefix, ok := storkutil.ParseIP(dbHostIP.Address)
+		if !ok {
+			continue
+		}
+		hostIP := models.IPReservation{
+			Address: ip,
+		}
+		if prefix {
+			host.PrefixReservations = append(host.PrefixReservations, &hostIP)
+		} else {
+			host.AddressReservations = append(host.AddressReservations, &hostIP)
+		}
+	}
+	// Convert DHCP options.
+	for _, dbOption := range dbHost.OptionData {
+		option := models.HostOption{
+			Code:       dbOption.Code,
+			Name:       dbOption.Name,
+			Space:      dbOption.Space,
+			Data:       dbOption.Data,
+			CsvFormat:  dbOption.CSVFormat,
+			AlwaysSend: dbOption.AlwaysSend,
+		}
+		host.OptionData = append(host.OptionData, &option)
+	}
+	// Append local hosts containing associations of the host with
+	// apps.
+	for _, dbLocalHost := range dbHost.LocalHosts {
+		localHost := models.LocalHost{
+			AppID:      dbLocalHost.AppID,
+			DataSource: dbLocalHost.DataSource,
+		}
+		if dbLocalHost.App != nil {
+			localHost.AppName = dbLocalHost.App.Name
+		}
+		host.LocalHosts = append(host.LocalHosts, &localHost)
+	}
+	return host
+}
+
+// Converts the host received over the REST API to the database format.
+// The identifiers are given as strings of hexadecimal digits optionally
+// separated with colons.
+func restAPIToHost(host *models.Host) (*dbmodel.Host, error) {
+	dbHost := &dbmodel.Host{
+		SubnetID:      host.SubnetID,
+		Hostname:      host.Hostname,
+		ClientClasses: host.ClientClasses,
+	}
+	for _, hostID := range host.HostIdentifiers {
+		if hostID == nil {
+			continue
+		}
+		if !storkutil.IsHexIdentifier(hostID.IDHexValue) {
+			return nil, errors.Errorf("invalid value %s of the %s identifier", hostID.IDHexValue, hostID.IDType)
+		}
+		value, err := hex.DecodeString(strings.ReplaceAll(strings.TrimSpace(hostID.IDHexValue), ":", ""))
+		if err != nil {
+			return nil, errors.Errorf("invalid value %s of the %s identifier", hostID.IDHexValue, hostID.IDType)
+		}
+		dbHost.HostIdentifiers = append(dbHost.HostIdentifiers, dbmodel.HostIdentifier{
+			Type:  hostID.IDType,
+			Value: value,
+		})
+	}
+	for _, reservations := range [][]*models.IPReservation{host.AddressReservations, host.PrefixReservations} {
+		for _, reservation := range reservations {
+			if reservation == nil {
+				continue
+			}
+			dbHost.IPReservations = append(dbHost.IPReservations, dbmodel.IPReservation{
+				Address: strings.TrimSpace(reservation.Address),
+			})
+		}
+	}
+	for _, option := range host.OptionData {
+		if option == nil {
+			continue
+		}
+		dbHost.OptionData = append(dbHost.OptionData, dbmodel.KeaConfigOptionData{
+			Code:       option.Code,
+			Name:       option.Name,
+			Space:      option.Space,
+			Data:       option.Data,
+			CSVFormat:  option.CsvFormat,
+			AlwaysSend: option.AlwaysSend,
+		})
+	}
+	return dbHost, nil
+}
+
 func (r *RestAPI) getHosts(offset, limit, appID int64, subnetID *int64, filterText *string, global *bool, sortField string, sortDir dbmodel.SortDirEnum) (*models.Hosts, error) {
 	// Get the hosts from the database.
 	dbHosts, total, err := dbmodel.GetHostsByPage(r.DB, offset, limit, appID, subnetID, filterText, global, sortField, sortDir)
@@ -25,50 +143,9 @@ func (r *RestAPI) getHosts(offset, limit, appID int64, subnetID *int64, filterTe
 	}
 
 	// Convert hosts fetched from the database to REST.
-	for _, dbHost := range dbHosts {
-		host := models.Host{
-			ID:       dbHost.ID,
-			SubnetID: dbHost.SubnetID,
-			Hostname: dbHost.Hostname,
-		}
-		// Include subnet prefix if this is subnet specific host.
-		if dbHost.Subnet != nil {
-			host.SubnetPrefix = dbHost.Subnet.Prefix
-		}
-		// Convert DHCP host identifiers.
-		for _, dbHostID := range dbHost.HostIdentifiers {
-			hostID := models.HostIdentifier{
-				IDType:     dbHostID.Type,
-				IDHexValue: dbHostID.ToHex(":"),
-			}
-			host.HostIdentifiers = append(host.HostIdentifiers, &hostID)
-		}
-		// Convert IP reservations.
-		for _, dbHostIP := range dbHost.IPReservations {
-			ip, prefix, ok := storkutil.ParseIP(dbHostIP.Address)
-			if !ok {
-				continue
-			}
-			hostIP := models.IPReservation{
-				Address: ip,
-			}
-			if prefix {
-				host.PrefixReservations = append(host.PrefixReservations, &hostIP)
-			} else {
-				host.AddressReservations = append(host.AddressReservations, &hostIP)
-			}
-		}
-		// Append local hosts containing associations of the host with
-		// apps.
-		for _, dbLocalHost := range dbHost.LocalHosts {
-			localHost := models.LocalHost{
-				AppID:      dbLocalHost.AppID,
-				AppName:    dbLocalHost.App.Name,
-				DataSource: dbLocalHost.DataSource,
-			}
-			host.LocalHosts = append(host.LocalHosts, &localHost)
-		}
-		hosts.Items = append(hosts.Items, &host)
+	for i := range dbHosts {
+		host := hostToRestAPI(&dbHosts[i])
+		hosts.Items = append(hosts.Items, host)
 	}
 
 	return hosts, nil
@@ -107,3 +184,247 @@ func (r *RestAPI) GetHosts(ctx context.Context, params dhcp.GetHostsParams) midd
 	rsp := dhcp.NewGetHostsOK().WithPayload(hosts)
 	return rsp
 }
+
+// Creates the host reservation which is sent to the Kea servers. The
+// reservation in the subnet is sent to all apps serving the subnet. The
+// global reservation is sent to the apps with the given IDs. It returns
+// the HTTP status code and the error message if the reservation cannot
+// be created.
+func (r *RestAPI) newHostReservation(host *dbmodel.Host, appIDs []int64) (*kea.HostReservation, int, string) {
+	var subnet *dbmodel.Subnet
+	if host.SubnetID != 0 {
+		var err error
+		subnet, err = dbmodel.GetSubnet(r.DB, host.SubnetID)
+		if err != nil {
+			log.Error(err)
+			return nil, http.StatusInternalServerError, fmt.Sprintf("cannot get subnet with id %d from db", host.SubnetID)
+		}
+		if subnet == nil {
+			return nil, http.StatusBadRequest, fmt.Sprintf("cannot find subnet with id %d", host.SubnetID)
+		}
+		appIDs = []int64{}
+		for _, ls := range subnet.LocalSubnets {
+			appIDs = append(appIDs, ls.AppID)
+		}
+	}
+	// The apps are fetched with the access points and the daemons which
+	// are required to send the commands.
+	apps := []*dbmodel.App{}
+	for _, appID := range appIDs {
+		app, err := dbmodel.GetAppByID(r.DB, appID)
+		if err != nil {
+			log.Error(err)
+			return nil, http.StatusInternalServerError, fmt.Sprintf("cannot get app with id %d from db", appID)
+		}
+		if app == nil {
+			return nil, http.StatusBadRequest, fmt.Sprintf("cannot find app with id %d", appID)
+		}
+		apps = append(apps, app)
+	}
+	reservation, err := kea.NewHostReservation(host, subnet, apps)
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Sprintf("invalid host reservation: %s", err)
+	}
+	return reservation, 0, ""
+}
+
+// Returns the IDs of the apps associated with the host.
+func getHostAppIDs(host *dbmodel.Host) (appIDs []int64) {
+	for _, lh := range host.LocalHosts {
+		appIDs = append(appIDs, lh.AppID)
+	}
+	return appIDs
+}
+
+// Returns the outcome of the host commands and the current state of the
+// host in the database. The host is nil if it has been deleted.
+func (r *RestAPI) getHostReservationResult(hostID int64, results []kea.DaemonCmdsResult) *models.HostReservationResult {
+	payload := &models.HostReservationResult{
+		Results: daemonCmdsResultsToRestAPI(results),
+	}
+	if hostID != 0 {
+		dbHost, err := dbmodel.GetHost(r.DB, hostID)
+		if err != nil {
+			log.Error(err)
+		} else if dbHost != nil {
+			payload.Host = hostToRestAPI(dbHost)
+		}
+	}
+	return payload
+}
+
+// Add new host reservation to the Kea servers with the reservation-add
+// command. The host is stored in the database when any server accepts
+// the reservation. The outcomes of the commands are returned per server.
+func (r *RestAPI) CreateHost(ctx context.Context, params dhcp.CreateHostParams) middleware.Responder {
+	if !r.canModifyDHCP(ctx) {
+		msg := "user is forbidden to add host reservations"
+		rsp := dhcp.NewCreateHostDefault(http.StatusForbidden).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if params.Reservation == nil || params.Reservation.Host == nil {
+		msg := "missing host reservation"
+		rsp := dhcp.NewCreateHostDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	dbHost, err := restAPIToHost(params.Reservation.Host)
+	if err != nil {
+		msg := fmt.Sprintf("invalid host reservation: %s", err)
+		rsp := dhcp.NewCreateHostDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	reservation, code, msg := r.newHostReservation(dbHost, params.Reservation.AppIds)
+	if reservation == nil {
+		rsp := dhcp.NewCreateHostDefault(code).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	results, err := kea.AddHostReservation(ctx, r.DB, r.Agents, reservation)
+	if err != nil {
+		log.Error(err)
+		msg := "problem with storing the added host reservation in the database"
+		rsp := dhcp.NewCreateHostDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	rsp := dhcp.NewCreateHostOK().WithPayload(r.getHostReservationResult(reservation.Host.ID, results))
+	return rsp
+}
+
+// Update the host reservation in the Kea servers. The existing reservation
+// is deleted with the reservation-del command and the updated one is added
+// with the reservation-add command. The outcomes of the commands are
+// returned per server.
+func (r *RestAPI) UpdateHost(ctx context.Context, params dhcp.UpdateHostParams) middleware.Responder {
+	if !r.canModifyDHCP(ctx) {
+		msg := "user is forbidden to update host reservations"
+		rsp := dhcp.NewUpdateHostDefault(http.StatusForbidden).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if params.Reservation == nil || params.Reservation.Host == nil {
+		msg := "missing host reservation"
+		rsp := dhcp.NewUpdateHostDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	dbExisting, err := dbmodel.GetHost(r.DB, params.ID)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("cannot get host with id %d from db", params.ID)
+		rsp := dhcp.NewUpdateHostDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if dbExisting == nil {
+		msg := fmt.Sprintf("cannot find host with id %d", params.ID)
+		rsp := dhcp.NewUpdateHostDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	dbHost, err := restAPIToHost(params.Reservation.Host)
+	if err != nil {
+		msg := fmt.Sprintf("invalid host reservation: %s", err)
+		rsp := dhcp.NewUpdateHostDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	existing, code, msg := r.newHostReservation(dbExisting, getHostAppIDs(dbExisting))
+	if existing == nil {
+		rsp := dhcp.NewUpdateHostDefault(code).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	// The global reservation is held by the same apps unless other apps
+	// are selected.
+	appIDs := params.Reservation.AppIds
+	if len(appIDs) == 0 {
+		appIDs = getHostAppIDs(dbExisting)
+	}
+	updated, code, msg := r.newHostReservation(dbHost, appIDs)
+	if updated == nil {
+		rsp := dhcp.NewUpdateHostDefault(code).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	results, err := kea.UpdateHostReservation(ctx, r.DB, r.Agents, existing, updated)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("problem with storing the updated host reservation with id %d in the database", params.ID)
+		rsp := dhcp.NewUpdateHostDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	rsp := dhcp.NewUpdateHostOK().WithPayload(r.getHostReservationResult(params.ID, results))
+	return rsp
+}
+
+// Delete the host reservation from the Kea servers holding it with the
+// reservation-del command. The outcomes of the commands are returned per
+// server. The host is deleted from the database when all servers have
+// deleted the reservation.
+func (r *RestAPI) DeleteHost(ctx context.Context, params dhcp.DeleteHostParams) middleware.Responder {
+	if !r.canModifyDHCP(ctx) {
+		msg := "user is forbidden to delete host reservations"
+		rsp := dhcp.NewDeleteHostDefault(http.StatusForbidden).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	dbHost, err := dbmodel.GetHost(r.DB, params.ID)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("cannot get host with id %d from db", params.ID)
+		rsp := dhcp.NewDeleteHostDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if dbHost == nil {
+		msg := fmt.Sprintf("cannot find host with id %d", params.ID)
+		rsp := dhcp.NewDeleteHostDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	reservation, code, msg := r.newHostReservation(dbHost, getHostAppIDs(dbHost))
+	if reservation == nil {
+		rsp := dhcp.NewDeleteHostDefault(code).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	results, err := kea.DeleteHostReservation(ctx, r.DB, r.Agents, reservation)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("problem with deleting the host reservation with id %d from the database", params.ID)
+		rsp := dhcp.NewDeleteHostDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	rsp := dhcp.NewDeleteHostOK().WithPayload(r.getHostReservationResult(params.ID, results))
+	return rsp
+}
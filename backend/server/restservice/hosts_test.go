@@ -3,10 +3,12 @@ package restservice
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"testing"
 
 	"github.com/go-pg/pg/v9"
 	"github.com/stretchr/testify/require"
+	keactrl "isc.org/stork/appctrl/kea"
 	agentcommtest "isc.org/stork/server/agentcomm/test"
 	dbmodel "isc.org/stork/server/database/model"
 	dbtest "isc.org/stork/server/database/test"
@@ -307,3 +309,167 @@ func TestGetHostsWithFiltering(t *testing.T) {
 	require.Len(t, okRsp.Payload.Items, 2)
 	require.EqualValues(t, 2, okRsp.Payload.Total)
 }
+
+// Generates the successful responses to the host commands except the
+// second command which fails.
+func mockHostCmds(callNo int, cmdResponses []interface{}) {
+	daemons, _ := keactrl.NewDaemons("dhcp4")
+	command, _ := keactrl.NewCommand("reservation-add", daemons, nil)
+	json := `[{"result": 0, "text": "OK"}]`
+	if callNo == 1 {
+		json = `[{"result": 1, "text": "Database error."}]`
+	}
+	_ = keactrl.UnmarshalResponseList(command, []byte(json), cmdResponses[0])
+}
+
+// Test adding, updating and deleting the host reservation in the subnet
+// served by two Kea servers via rest api functions.
+func TestCreateUpdateDeleteHost(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	settings := RestAPISettings{}
+	fa := agentcommtest.NewFakeAgents(mockHostCmds, nil)
+	fec := &storktest.FakeEventCenter{}
+	rapi, err := NewRestAPI(&settings, dbSettings, db, fa, fec, nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	subnet := &dbmodel.Subnet{
+		Prefix: "192.0.2.0/24",
+	}
+	err = dbmodel.AddSubnet(db, subnet)
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		m := &dbmodel.Machine{
+			Address:   "localhost",
+			AgentPort: int64(8080 + i),
+		}
+		err = dbmodel.AddMachine(db, m)
+		require.NoError(t, err)
+		accessPoints := []*dbmodel.AccessPoint{}
+		accessPoints = dbmodel.AppendAccessPoint(accessPoints, dbmodel.AccessPointControl, "localhost", "", int64(8000+i))
+		daemon := dbmodel.NewKeaDaemon(dbmodel.DaemonNameDHCPv4, true)
+		err = daemon.SetConfigFromJSON(`{"Dhcp4": {"subnet4": [{"id": 7, "subnet": "192.0.2.0/24"}]}}`)
+		require.NoError(t, err)
+		app := &dbmodel.App{
+			MachineID:    m.ID,
+			Type:         dbmodel.AppTypeKea,
+			Name:         fmt.Sprintf("dhcp-server%d", i),
+			AccessPoints: accessPoints,
+			Daemons:      []*dbmodel.Daemon{daemon},
+		}
+		_, err = dbmodel.AddApp(db, app)
+		require.NoError(t, err)
+		err = dbmodel.AddAppToSubnet(db, subnet, app)
+		require.NoError(t, err)
+	}
+
+	params := dhcp.CreateHostParams{
+		Reservation: &models.HostReservationReq{
+			Host: &models.Host{
+				SubnetID: subnet.ID,
+				Hostname: "host.example.org",
+				HostIdentifiers: []*models.HostIdentifier{
+					{
+						IDType:     "hw-address",
+						IDHexValue: "01:02:03:04:05:06",
+					},
+				},
+				AddressReservations: []*models.IPReservation{
+					{
+						Address: "192.0.2.10",
+					},
+				},
+				ClientClasses: []string{"foo"},
+				OptionData: []*models.HostOption{
+					{
+						Name: "routers",
+						Data: "192.0.2.1",
+					},
+				},
+			},
+		},
+	}
+
+	// Only super-admin can add the host reservation.
+	user := &dbmodel.SystemUser{
+		Login:    "joe",
+		Lastname: "Doe",
+		Name:     "Joe",
+		Password: "pass",
+	}
+	_, err = dbmodel.CreateUser(db, user)
+	require.NoError(t, err)
+	ctx2, err := rapi.SessionManager.Load(ctx, "")
+	require.NoError(t, err)
+	err = rapi.SessionManager.LoginHandler(ctx2, user)
+	require.NoError(t, err)
+	rsp := rapi.CreateHost(ctx2, params)
+	require.IsType(t, &dhcp.CreateHostDefault{}, rsp)
+	require.Equal(t, http.StatusForbidden, getStatusCode(*rsp.(*dhcp.CreateHostDefault)))
+	require.Empty(t, fa.RecordedCommands)
+
+	admin, err := dbmodel.GetUserByID(rapi.DB, 1)
+	require.NoError(t, err)
+	ctx, err = rapi.SessionManager.Load(ctx, "")
+	require.NoError(t, err)
+	err = rapi.SessionManager.LoginHandler(ctx, admin)
+	require.NoError(t, err)
+
+	// Address outside of the subnet.
+	params.Reservation.Host.AddressReservations[0].Address = "192.0.3.10"
+	rsp = rapi.CreateHost(ctx, params)
+	require.IsType(t, &dhcp.CreateHostDefault{}, rsp)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*rsp.(*dhcp.CreateHostDefault)))
+	require.Empty(t, fa.RecordedCommands)
+
+	// The reservation is added to the first server only.
+	params.Reservation.Host.AddressReservations[0].Address = "192.0.2.10"
+	rsp = rapi.CreateHost(ctx, params)
+	require.IsType(t, &dhcp.CreateHostOK{}, rsp)
+	result := rsp.(*dhcp.CreateHostOK).Payload
+	require.Len(t, result.Results, 2)
+	require.Zero(t, result.Results[0].Result)
+	require.EqualValues(t, 1, result.Results[1].Result)
+	require.Equal(t, "Database error.", result.Results[1].Text)
+	require.Equal(t, "dhcp-server1", result.Results[1].AppName)
+	require.NotNil(t, result.Host)
+	require.Equal(t, []string{"foo"}, result.Host.ClientClasses)
+	require.Len(t, result.Host.OptionData, 1)
+	require.Len(t, result.Host.LocalHosts, 1)
+	require.Equal(t, "dhcp-server0", result.Host.LocalHosts[0].AppName)
+
+	keaHost := (*fa.RecordedCommands[0].Arguments)["reservation"].(map[string]interface{})
+	require.EqualValues(t, 7, keaHost["subnet-id"])
+	require.Equal(t, "01:02:03:04:05:06", keaHost["hw-address"])
+
+	// The reservation is updated in both servers.
+	hostID := result.Host.ID
+	updateParams := dhcp.UpdateHostParams{
+		ID:          hostID,
+		Reservation: params.Reservation,
+	}
+	updateParams.Reservation.Host.Hostname = "updated.example.org"
+	rsp = rapi.UpdateHost(ctx, updateParams)
+	require.IsType(t, &dhcp.UpdateHostOK{}, rsp)
+	result = rsp.(*dhcp.UpdateHostOK).Payload
+	require.Len(t, result.Results, 4)
+	require.Equal(t, "reservation-del", result.Results[0].Command)
+	require.Equal(t, "reservation-add", result.Results[2].Command)
+	require.NotNil(t, result.Host)
+	require.Equal(t, "updated.example.org", result.Host.Hostname)
+	require.Len(t, result.Host.LocalHosts, 2)
+
+	// The reservation is deleted from both servers.
+	rsp = rapi.DeleteHost(ctx, dhcp.DeleteHostParams{ID: hostID})
+	require.IsType(t, &dhcp.DeleteHostOK{}, rsp)
+	result = rsp.(*dhcp.DeleteHostOK).Payload
+	require.Len(t, result.Results, 2)
+	require.Nil(t, result.Host)
+
+	// Non-existing host.
+	rsp = rapi.DeleteHost(ctx, dhcp.DeleteHostParams{ID: hostID})
+	require.IsType(t, &dhcp.DeleteHostDefault{}, rsp)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*rsp.(*dhcp.DeleteHostDefault)))
+}
@@ -0,0 +1,189 @@
+package restservice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/go-openapi/runtime/middleware"
+	"github.com/go-openapi/strfmt"
+	log "github.com/sirupsen/logrus"
+
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/events"
+	storkutil "isc.org/stork/util"
+)
+
+// Convert the incident to the format used in REST API.
+func incidentToRestAPI(dbIncident *dbmodel.Incident) *models.Incident {
+	incident := &models.Incident{
+		ID:             dbIncident.ID,
+		CreatedAt:      strfmt.DateTime(dbIncident.CreatedAt),
+		Text:           dbIncident.Text,
+		Level:          int64(dbIncident.Level),
+		State:          dbIncident.State,
+		EventCount:     dbIncident.EventCount,
+		LastEventAt:    strfmt.DateTime(dbIncident.LastEventAt),
+		AcknowledgedBy: int64(dbIncident.AcknowledgedByID),
+		Comment:        dbIncident.Comment,
+	}
+	if !dbIncident.AcknowledgedAt.IsZero() {
+		incident.AcknowledgedAt = strfmt.DateTime(dbIncident.AcknowledgedAt)
+	}
+	if !dbIncident.ResolvedAt.IsZero() {
+		incident.ResolvedAt = strfmt.DateTime(dbIncident.ResolvedAt)
+	}
+	for _, dbEvent := range dbIncident.Events {
+		incident.Events = append(incident.Events, eventToRestAPI(dbEvent))
+	}
+	return incident
+}
+
+// Returns the ID of the logged user or 0 if there is no logged user.
+func (r *RestAPI) getLoggedUserID(ctx context.Context) int {
+	ok, dbUser := r.SessionManager.Logged(ctx)
+	if !ok || dbUser == nil {
+		return 0
+	}
+	return dbUser.ID
+}
+
+// Change the state of the event.
+func (r *RestAPI) SetEventState(ctx context.Context, params events.SetEventStateParams) middleware.Responder {
+	if params.State == nil || params.State.State == nil || !dbmodel.IsValidEventState(*params.State.State) {
+		msg := "invalid event state"
+		rsp := events.NewSetEventStateDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	dbEvent, err := dbmodel.GetEvent(r.DB, params.ID)
+	if err != nil {
+		msg := fmt.Sprintf("cannot get event with id %d from db", params.ID)
+		log.Error(err)
+		rsp := events.NewSetEventStateDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if dbEvent == nil {
+		msg := fmt.Sprintf("cannot find event with id %d", params.ID)
+		rsp := events.NewSetEventStateDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	err = dbmodel.SetEventState(r.DB, params.ID, *params.State.State, r.getLoggedUserID(ctx),
+		params.State.Comment, storkutil.UTCNow())
+	if err != nil {
+		msg := fmt.Sprintf("cannot change state of event with id %d", params.ID)
+		log.Error(err)
+		rsp := events.NewSetEventStateDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	rsp := events.NewSetEventStateOK()
+	return rsp
+}
+
+// Get the list of incidents. The unresolved incidents are returned by
+// default.
+func (r *RestAPI) GetIncidents(ctx context.Context, params events.GetIncidentsParams) middleware.Responder {
+	var start int64 = 0
+	if params.Start != nil {
+		start = *params.Start
+	}
+
+	var limit int64 = 10
+	if params.Limit != nil {
+		limit = *params.Limit
+	}
+
+	states := []string{dbmodel.EventStateOpen, dbmodel.EventStateAcknowledged}
+	if params.State != nil {
+		states = []string{*params.State}
+	}
+
+	dbIncidents, total, err := dbmodel.GetIncidentsByPage(r.DB, start, limit, states)
+	if err != nil {
+		msg := "cannot get incidents from db"
+		log.Error(err)
+		rsp := events.NewGetIncidentsDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	incidents := &models.Incidents{
+		Items: []*models.Incident{},
+		Total: total,
+	}
+	for i := range dbIncidents {
+		incidents.Items = append(incidents.Items, incidentToRestAPI(&dbIncidents[i]))
+	}
+	rsp := events.NewGetIncidentsOK().WithPayload(incidents)
+	return rsp
+}
+
+// Get the incident with its events by ID.
+func (r *RestAPI) GetIncident(ctx context.Context, params events.GetIncidentParams) middleware.Responder {
+	dbIncident, err := dbmodel.GetIncident(r.DB, params.ID)
+	if err != nil {
+		msg := fmt.Sprintf("cannot get incident with id %d from db", params.ID)
+		log.Error(err)
+		rsp := events.NewGetIncidentDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if dbIncident == nil {
+		msg := fmt.Sprintf("cannot find incident with id %d", params.ID)
+		rsp := events.NewGetIncidentDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	rsp := events.NewGetIncidentOK().WithPayload(incidentToRestAPI(dbIncident))
+	return rsp
+}
+
+// Change the state of the incident and its unresolved events.
+func (r *RestAPI) SetIncidentState(ctx context.Context, params events.SetIncidentStateParams) middleware.Responder {
+	if params.State == nil || params.State.State == nil || !dbmodel.IsValidEventState(*params.State.State) {
+		msg := "invalid incident state"
+		rsp := events.NewSetIncidentStateDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	dbIncident, err := dbmodel.GetIncident(r.DB, params.ID)
+	if err != nil {
+		msg := fmt.Sprintf("cannot get incident with id %d from db", params.ID)
+		log.Error(err)
+		rsp := events.NewSetIncidentStateDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if dbIncident == nil {
+		msg := fmt.Sprintf("cannot find incident with id %d", params.ID)
+		rsp := events.NewSetIncidentStateDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	err = dbmodel.SetIncidentState(r.DB, params.ID, *params.State.State, r.getLoggedUserID(ctx),
+		params.State.Comment, storkutil.UTCNow())
+	if err != nil {
+		msg := fmt.Sprintf("cannot change state of incident with id %d", params.ID)
+		log.Error(err)
+		rsp := events.NewSetIncidentStateDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	rsp := events.NewSetIncidentStateOK()
+	return rsp
+}
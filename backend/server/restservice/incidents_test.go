@@ -0,0 +1,146 @@
+package restservice
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	agentcommtest "isc.org/stork/server/agentcomm/test"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/events"
+	storktest "isc.org/stork/server/test"
+	storkutil "isc.org/stork/util"
+)
+
+// Check listing incidents and changing the states of the incidents and
+// events via rest api functions.
+func TestIncidents(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	settings := RestAPISettings{}
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	fec := &storktest.FakeEventCenter{}
+	rapi, err := NewRestAPI(&settings, dbSettings, db, fa, fec, nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// Setup a user session, so the acknowledging user is recorded.
+	user, err := dbmodel.GetUserByID(rapi.DB, 1)
+	require.NoError(t, err)
+	ctx, err = rapi.SessionManager.Load(ctx, "")
+	require.NoError(t, err)
+	err = rapi.SessionManager.LoginHandler(ctx, user)
+	require.NoError(t, err)
+
+	now := storkutil.UTCNow()
+	var incident *dbmodel.Incident
+	for i := 0; i < 2; i++ {
+		event := &dbmodel.Event{
+			Text:  "daemon is unreachable",
+			Level: dbmodel.EvError,
+		}
+		err = dbmodel.AddEvent(db, event)
+		require.NoError(t, err)
+		incident, err = dbmodel.AddEventToIncident(db, event, "key", false, now.Add(-time.Hour))
+		require.NoError(t, err)
+	}
+
+	// The open incidents are returned by default.
+	rsp := rapi.GetIncidents(ctx, events.GetIncidentsParams{})
+	require.IsType(t, &events.GetIncidentsOK{}, rsp)
+	incidentsRsp := rsp.(*events.GetIncidentsOK)
+	require.EqualValues(t, 1, incidentsRsp.Payload.Total)
+	require.Len(t, incidentsRsp.Payload.Items, 1)
+	require.Equal(t, incident.ID, incidentsRsp.Payload.Items[0].ID)
+	require.Equal(t, dbmodel.EventStateOpen, incidentsRsp.Payload.Items[0].State)
+	require.EqualValues(t, 2, incidentsRsp.Payload.Items[0].EventCount)
+
+	// Acknowledge the incident.
+	state := dbmodel.EventStateAcknowledged
+	rsp = rapi.SetIncidentState(ctx, events.SetIncidentStateParams{
+		ID: incident.ID,
+		State: &models.EventStateChange{
+			State:   &state,
+			Comment: "looking into it",
+		},
+	})
+	require.IsType(t, &events.SetIncidentStateOK{}, rsp)
+
+	rsp = rapi.GetIncident(ctx, events.GetIncidentParams{ID: incident.ID})
+	require.IsType(t, &events.GetIncidentOK{}, rsp)
+	incidentRsp := rsp.(*events.GetIncidentOK)
+	require.Equal(t, dbmodel.EventStateAcknowledged, incidentRsp.Payload.State)
+	require.EqualValues(t, 1, incidentRsp.Payload.AcknowledgedBy)
+	require.Equal(t, "looking into it", incidentRsp.Payload.Comment)
+	require.Len(t, incidentRsp.Payload.Events, 2)
+	require.Equal(t, dbmodel.EventStateAcknowledged, incidentRsp.Payload.Events[0].State)
+	require.Equal(t, incident.ID, incidentRsp.Payload.Events[0].IncidentID)
+	eventID := incidentRsp.Payload.Events[0].ID
+
+	// The resolved incidents are returned on demand.
+	state = dbmodel.EventStateResolved
+	rsp = rapi.SetIncidentState(ctx, events.SetIncidentStateParams{
+		ID:    incident.ID,
+		State: &models.EventStateChange{State: &state},
+	})
+	require.IsType(t, &events.SetIncidentStateOK{}, rsp)
+
+	rsp = rapi.GetIncidents(ctx, events.GetIncidentsParams{})
+	require.IsType(t, &events.GetIncidentsOK{}, rsp)
+	incidentsRsp = rsp.(*events.GetIncidentsOK)
+	require.Zero(t, incidentsRsp.Payload.Total)
+
+	rsp = rapi.GetIncidents(ctx, events.GetIncidentsParams{State: &state})
+	require.IsType(t, &events.GetIncidentsOK{}, rsp)
+	incidentsRsp = rsp.(*events.GetIncidentsOK)
+	require.EqualValues(t, 1, incidentsRsp.Payload.Total)
+
+	// Reopen the single event.
+	state = dbmodel.EventStateOpen
+	rsp = rapi.SetEventState(ctx, events.SetEventStateParams{
+		ID:    eventID,
+		State: &models.EventStateChange{State: &state, Comment: "not fixed"},
+	})
+	require.IsType(t, &events.SetEventStateOK{}, rsp)
+	dbEvent, err := dbmodel.GetEvent(db, eventID)
+	require.NoError(t, err)
+	require.Equal(t, dbmodel.EventStateOpen, dbEvent.State)
+	require.Equal(t, "not fixed", dbEvent.Comment)
+
+	// Invalid state.
+	invalid := "closed"
+	rsp = rapi.SetEventState(ctx, events.SetEventStateParams{
+		ID:    eventID,
+		State: &models.EventStateChange{State: &invalid},
+	})
+	require.IsType(t, &events.SetEventStateDefault{}, rsp)
+	eventDefaultRsp := rsp.(*events.SetEventStateDefault)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*eventDefaultRsp))
+
+	rsp = rapi.SetIncidentState(ctx, events.SetIncidentStateParams{
+		ID:    incident.ID,
+		State: &models.EventStateChange{State: &invalid},
+	})
+	require.IsType(t, &events.SetIncidentStateDefault{}, rsp)
+	incidentDefaultRsp := rsp.(*events.SetIncidentStateDefault)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*incidentDefaultRsp))
+
+	// Not existing objects.
+	rsp = rapi.SetEventState(ctx, events.SetEventStateParams{
+		ID:    eventID + 100,
+		State: &models.EventStateChange{State: &state},
+	})
+	require.IsType(t, &events.SetEventStateDefault{}, rsp)
+	eventDefaultRsp = rsp.(*events.SetEventStateDefault)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*eventDefaultRsp))
+
+	rsp = rapi.GetIncident(ctx, events.GetIncidentParams{ID: incident.ID + 1})
+	require.IsType(t, &events.GetIncidentDefault{}, rsp)
+	getDefaultRsp := rsp.(*events.GetIncidentDefault)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*getDefaultRsp))
+}
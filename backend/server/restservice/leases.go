@@ -2,17 +2,68 @@ package restservice
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/go-openapi/runtime"
 	"github.com/go-openapi/runtime/middleware"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 
+	keadata "isc.org/stork/appdata/kea"
 	"isc.org/stork/server/apps/kea"
+	dbmodel "isc.org/stork/server/database/model"
 	"isc.org/stork/server/gen/models"
 	dhcp "isc.org/stork/server/gen/restapi/operations/d_h_c_p"
 )
 
+// Converts the lease returned by the Kea server to the format used in
+// REST API.
+func leaseToRestAPI(l *dbmodel.Lease) *models.Lease {
+	var appName string
+	if l.App != nil {
+		appName = l.App.Name
+	}
+	id := int64(0)
+	appID := l.AppID
+	ipAddress := l.IPAddress
+	cltt := int64(l.CLTT)
+	state := int64(l.State)
+	subnetID := int64(l.SubnetID)
+	validLifetime := int64(l.ValidLifetime)
+	lease := &models.Lease{
+		ID:                &id,
+		AppID:             &appID,
+		AppName:           &appName,
+		ClientID:          l.ClientID,
+		Cltt:              &cltt,
+		Duid:              l.DUID,
+		FqdnFwd:           l.FqdnFwd,
+		FqdnRev:           l.FqdnRev,
+		Hostname:          l.Hostname,
+		HwAddress:         l.HWAddress,
+		Iaid:              int64(l.IAID),
+		IPAddress:         &ipAddress,
+		LeaseType:         l.Type,
+		PreferredLifetime: int64(l.PreferredLifetime),
+		PrefixLength:      int64(l.PrefixLength),
+		State:             &state,
+		SubnetID:          &subnetID,
+		ValidLifetime:     &validLifetime,
+	}
+	return lease
+}
+
 // This call searches for leases allocated by monitored DHCP servers.
 // The text parameter may contain an IP address, delegated prefix,
 // MAC address, client identifier, or hostname. The Stork server
@@ -45,37 +96,7 @@ func (r *RestAPI) GetLeases(ctx context.Context, params dhcp.GetLeasesParams) mi
 
 	// Return leases over the REST API.
 	for i := range keaLeases {
-		l := keaLeases[i]
-		var appName string
-		if l.App != nil {
-			appName = l.App.Name
-		}
-		id := int64(0)
-		cltt := int64(l.CLTT)
-		state := int64(l.State)
-		subnetID := int64(l.SubnetID)
-		validLifetime := int64(l.ValidLifetime)
-		lease := models.Lease{
-			ID:                &id,
-			AppID:             &l.AppID,
-			AppName:           &appName,
-			ClientID:          l.ClientID,
-			Cltt:              &cltt,
-			Duid:              l.DUID,
-			FqdnFwd:           l.FqdnFwd,
-			FqdnRev:           l.FqdnRev,
-			Hostname:          l.Hostname,
-			HwAddress:         l.HWAddress,
-			Iaid:              int64(l.IAID),
-			IPAddress:         &l.IPAddress,
-			LeaseType:         l.Type,
-			PreferredLifetime: int64(l.PreferredLifetime),
-			PrefixLength:      int64(l.PrefixLength),
-			State:             &state,
-			SubnetID:          &subnetID,
-			ValidLifetime:     &validLifetime,
-		}
-		leases.Items = append(leases.Items, &lease)
+		leases.Items = append(leases.Items, leaseToRestAPI(&keaLeases[i]))
 	}
 
 	leases.Total = int64(len(leases.Items))
@@ -91,3 +112,642 @@ func (r *RestAPI) GetLeases(ctx context.Context, params dhcp.GetLeasesParams) mi
 	rsp := dhcp.NewGetLeasesOK().WithPayload(leases)
 	return rsp
 }
+
+// Lifetime of the token confirming the wipe of the subnet leases.
+const leaseWipeTokenLifetime = 5 * time.Minute
+
+// Pending wipe of the subnet leases awaiting the confirmation by the
+// user who requested it.
+type leaseWipeToken struct {
+	subnetID  int64
+	userID    int
+	expiresAt time.Time
+}
+
+// Tokens confirming the wipes of the subnet leases. The wipe is first
+// requested without the token. The returned token must be passed in
+// the next request of the same user within the token lifetime to wipe
+// the leases. Each token can be used once.
+type leaseWipeTokens struct {
+	mutex  sync.Mutex
+	tokens map[string]leaseWipeToken
+}
+
+// Returns new token confirming the wipe of the leases in the subnet by
+// the user. The expired tokens are removed.
+func (t *leaseWipeTokens) issue(subnetID int64, userID int) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", errors.Wrap(err, "problem with generating the lease wipe token")
+	}
+	token := hex.EncodeToString(buf)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	now := time.Now()
+	if t.tokens == nil {
+		t.tokens = make(map[string]leaseWipeToken)
+	}
+	for key, pending := range t.tokens {
+		if now.After(pending.expiresAt) {
+			delete(t.tokens, key)
+		}
+	}
+	t.tokens[token] = leaseWipeToken{
+		subnetID:  subnetID,
+		userID:    userID,
+		expiresAt: now.Add(leaseWipeTokenLifetime),
+	}
+	return token, nil
+}
+
+// Checks if the token confirms the wipe of the leases in the subnet by
+// the user and invalidates it.
+func (t *leaseWipeTokens) consume(token string, subnetID int64, userID int) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	pending, ok := t.tokens[token]
+	if !ok {
+		return false
+	}
+	delete(t.tokens, token)
+	return pending.subnetID == subnetID && pending.userID == userID && time.Now().Before(pending.expiresAt)
+}
+
+// Records the outcome of the lease action on each server in the event
+// log. The description follows the user and precedes the app in the
+// event text, e.g. deleted lease 192.0.2.1.
+func (r *RestAPI) addLeaseActionEvents(dbUser *dbmodel.SystemUser, description string, code dbmodel.EventCode, payload dbmodel.EventPayload, results []kea.DaemonCmdsResult, objects ...interface{}) {
+	for _, result := range results {
+		eventObjects := append([]interface{}{dbUser, result.Target.App, payload}, objects...)
+		if result.Succeeded() {
+			eventObjects = append(eventObjects, code)
+			r.EventCenter.AddInfoEvent("{user} "+description+" on {app}", eventObjects...)
+			continue
+		}
+		eventObjects = append(eventObjects, dbmodel.EventCodeLeaseActionFailed,
+			dbmodel.EventPayload{"command": result.Command}, result.Text)
+		r.EventCenter.AddWarningEvent("{user} failed to execute "+result.Command+" on {app}", eventObjects...)
+	}
+}
+
+// Returns the outcome of the lease action.
+func getLeaseActionResult(results []kea.DaemonCmdsResult) *models.LeaseActionResult {
+	applied := len(results) > 0
+	for i := range results {
+		if !results[i].Succeeded() {
+			applied = false
+			break
+		}
+	}
+	return &models.LeaseActionResult{
+		Applied: applied,
+		Results: daemonCmdsResultsToRestAPI(results),
+	}
+}
+
+// Searches for the servers holding the lease. It returns the HTTP status
+// code and the error message if the lease cannot be found.
+func (r *RestAPI) findLeaseHolders(ipAddress string, leaseType *string) (*kea.LeaseAction, []kea.DaemonCmdsResult, int, string) {
+	var t string
+	if leaseType != nil {
+		t = strings.TrimSpace(*leaseType)
+	}
+	ipAddress = strings.TrimSpace(ipAddress)
+	if ip := net.ParseIP(ipAddress); ip == nil {
+		return nil, nil, http.StatusBadRequest, fmt.Sprintf("invalid lease IP address %s", ipAddress)
+	}
+	action, failures, err := kea.FindLeaseHolders(r.DB, r.Agents, ipAddress, t)
+	if err != nil {
+		log.Error(err)
+		return nil, nil, http.StatusInternalServerError, "problem with searching the lease on the Kea servers due to Stork database errors"
+	}
+	if action == nil {
+		if len(failures) > 0 {
+			return nil, nil, http.StatusInternalServerError, fmt.Sprintf("cannot find lease %s: %s", ipAddress, failures[0].Text)
+		}
+		return nil, nil, http.StatusNotFound, fmt.Sprintf("cannot find lease %s", ipAddress)
+	}
+	return action, failures, 0, ""
+}
+
+// Delete the lease from all Kea servers holding it with the lease4-del
+// or lease6-del command. The servers which failed to return the lease
+// are included in the results.
+func (r *RestAPI) DeleteLease(ctx context.Context, params dhcp.DeleteLeaseParams) middleware.Responder {
+	if !r.canModifyDHCP(ctx) {
+		msg := "user is forbidden to delete leases"
+		rsp := dhcp.NewDeleteLeaseDefault(http.StatusForbidden).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	action, failures, code, msg := r.findLeaseHolders(params.IPAddress, params.LeaseType)
+	if action == nil {
+		rsp := dhcp.NewDeleteLeaseDefault(code).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	results := action.Delete(ctx, r.Agents)
+	_, dbUser := r.SessionManager.Logged(ctx)
+	r.addLeaseActionEvents(dbUser, fmt.Sprintf("deleted lease %s", action.Lease.IPAddress), dbmodel.EventCodeLeaseDeleted,
+		dbmodel.EventPayload{"ipAddress": action.Lease.IPAddress}, results)
+
+	rsp := dhcp.NewDeleteLeaseOK().WithPayload(getLeaseActionResult(append(failures, results...)))
+	return rsp
+}
+
+// Trigger the DDNS update for the lease in all Kea servers holding it
+// with the lease4-resend-ddns or lease6-resend-ddns command.
+func (r *RestAPI) ResendLeaseDdns(ctx context.Context, params dhcp.ResendLeaseDdnsParams) middleware.Responder {
+	if !r.canModifyDHCP(ctx) {
+		msg := "user is forbidden to resend DDNS updates"
+		rsp := dhcp.NewResendLeaseDdnsDefault(http.StatusForbidden).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	action, failures, code, msg := r.findLeaseHolders(params.IPAddress, params.LeaseType)
+	if action == nil {
+		rsp := dhcp.NewResendLeaseDdnsDefault(code).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	results := action.ResendDDNS(ctx, r.Agents)
+	_, dbUser := r.SessionManager.Logged(ctx)
+	r.addLeaseActionEvents(dbUser, fmt.Sprintf("resent DDNS update for lease %s", action.Lease.IPAddress), dbmodel.EventCodeLeaseDDNSResent,
+		dbmodel.EventPayload{"ipAddress": action.Lease.IPAddress}, results)
+
+	rsp := dhcp.NewResendLeaseDdnsOK().WithPayload(getLeaseActionResult(append(failures, results...)))
+	return rsp
+}
+
+// Add or update the lease in all Kea servers serving the subnet with the
+// lease4-update or lease6-update command. The lease is created if it
+// doesn't exist.
+func (r *RestAPI) UpdateLease(ctx context.Context, params dhcp.UpdateLeaseParams) middleware.Responder {
+	if !r.canModifyDHCP(ctx) {
+		msg := "user is forbidden to update leases"
+		rsp := dhcp.NewUpdateLeaseDefault(http.StatusForbidden).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if params.Lease == nil || params.Lease.SubnetID == nil {
+		msg := "missing lease"
+		rsp := dhcp.NewUpdateLeaseDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	dbSubnet, err := dbmodel.GetSubnet(r.DB, *params.Lease.SubnetID)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("cannot get subnet with id %d from db", *params.Lease.SubnetID)
+		rsp := dhcp.NewUpdateLeaseDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if dbSubnet == nil {
+		msg := fmt.Sprintf("cannot find subnet with id %d", *params.Lease.SubnetID)
+		rsp := dhcp.NewUpdateLeaseDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	appIDs := []int64{}
+	for _, ls := range dbSubnet.LocalSubnets {
+		appIDs = append(appIDs, ls.AppID)
+	}
+	apps, code, msg := r.getSubnetChangeApps(appIDs)
+	if apps == nil {
+		rsp := dhcp.NewUpdateLeaseDefault(code).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	lease := &dbmodel.Lease{
+		Lease: keadata.Lease{
+			IPAddress:         params.IPAddress,
+			HWAddress:         params.Lease.HwAddress,
+			ClientID:          params.Lease.ClientID,
+			DUID:              params.Lease.Duid,
+			IAID:              uint32(params.Lease.Iaid),
+			Type:              params.Lease.LeaseType,
+			PrefixLength:      uint8(params.Lease.PrefixLength),
+			ValidLifetime:     uint32(params.Lease.ValidLifetime),
+			PreferredLifetime: uint32(params.Lease.PreferredLifetime),
+			Hostname:          params.Lease.Hostname,
+			FqdnFwd:           params.Lease.FqdnFwd,
+			FqdnRev:           params.Lease.FqdnRev,
+			State:             int(params.Lease.State),
+		},
+	}
+	action, err := kea.NewLeaseUpdateAction(lease, dbSubnet, apps)
+	if err != nil {
+		msg := fmt.Sprintf("invalid lease: %s", err)
+		rsp := dhcp.NewUpdateLeaseDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	results := action.Update(ctx, r.Agents)
+	_, dbUser := r.SessionManager.Logged(ctx)
+	r.addLeaseActionEvents(dbUser, fmt.Sprintf("updated lease %s in {subnet}", lease.IPAddress), dbmodel.EventCodeLeaseUpdated,
+		dbmodel.EventPayload{"ipAddress": lease.IPAddress}, results, dbSubnet)
+
+	rsp := dhcp.NewUpdateLeaseOK().WithPayload(getLeaseActionResult(results))
+	return rsp
+}
+
+// Wipe all leases in the subnet in all Kea servers serving it with the
+// lease4-wipe or lease6-wipe command. The request without the token
+// returns the token which must be passed in the next request of the
+// same user to confirm the wipe.
+func (r *RestAPI) WipeSubnetLeases(ctx context.Context, params dhcp.WipeSubnetLeasesParams) middleware.Responder {
+	if !r.canModifyDHCP(ctx) {
+		msg := "user is forbidden to wipe leases"
+		rsp := dhcp.NewWipeSubnetLeasesDefault(http.StatusForbidden).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	dbSubnet, err := dbmodel.GetSubnet(r.DB, params.ID)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("cannot get subnet with id %d from db", params.ID)
+		rsp := dhcp.NewWipeSubnetLeasesDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if dbSubnet == nil {
+		msg := fmt.Sprintf("cannot find subnet with id %d", params.ID)
+		rsp := dhcp.NewWipeSubnetLeasesDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	appIDs := []int64{}
+	for _, ls := range dbSubnet.LocalSubnets {
+		appIDs = append(appIDs, ls.AppID)
+	}
+	apps, code, msg := r.getSubnetChangeApps(appIDs)
+	if apps == nil {
+		rsp := dhcp.NewWipeSubnetLeasesDefault(code).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	action, err := kea.NewLeaseWipeAction(dbSubnet, apps)
+	if err != nil {
+		msg := fmt.Sprintf("cannot wipe leases in subnet with id %d: %s", params.ID, err)
+		rsp := dhcp.NewWipeSubnetLeasesDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	_, dbUser := r.SessionManager.Logged(ctx)
+	if params.Token == nil || len(*params.Token) == 0 {
+		token, err := r.leaseWipeTokens.issue(dbSubnet.ID, dbUser.ID)
+		if err != nil {
+			log.Error(err)
+			msg := "problem with generating the confirmation token"
+			rsp := dhcp.NewWipeSubnetLeasesDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+				Message: &msg,
+			})
+			return rsp
+		}
+		rsp := dhcp.NewWipeSubnetLeasesOK().WithPayload(&models.LeaseActionResult{
+			Applied: false,
+			Token:   token,
+		})
+		return rsp
+	}
+	if !r.leaseWipeTokens.consume(*params.Token, dbSubnet.ID, dbUser.ID) {
+		msg := "invalid or expired confirmation token"
+		rsp := dhcp.NewWipeSubnetLeasesDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	results := action.Wipe(ctx, r.Agents)
+	r.addLeaseActionEvents(dbUser, "wiped leases in {subnet}", dbmodel.EventCodeLeasesWiped,
+		dbmodel.EventPayload{}, results, dbSubnet)
+
+	rsp := dhcp.NewWipeSubnetLeasesOK().WithPayload(getLeaseActionResult(results))
+	return rsp
+}
+
+// Number of the leases fetched from the Kea servers at once while
+// exporting the subnet leases.
+const leasesExportPageSize = 1000
+
+// Fetches the subnet and the apps serving it, which are required to
+// fetch the subnet leases. It returns the HTTP status code and the
+// error message if the subnet or any app cannot be fetched.
+func (r *RestAPI) getSubnetLeasesSource(subnetID int64) (*dbmodel.Subnet, []*dbmodel.App, int, string) {
+	dbSubnet, err := dbmodel.GetSubnet(r.DB, subnetID)
+	if err != nil {
+		log.Error(err)
+		return nil, nil, http.StatusInternalServerError, fmt.Sprintf("cannot get subnet with id %d from db", subnetID)
+	}
+	if dbSubnet == nil {
+		return nil, nil, http.StatusNotFound, fmt.Sprintf("cannot find subnet with id %d", subnetID)
+	}
+	appIDs := []int64{}
+	for _, ls := range dbSubnet.LocalSubnets {
+		appIDs = append(appIDs, ls.AppID)
+	}
+	apps, code, msg := r.getSubnetChangeApps(appIDs)
+	if apps == nil {
+		return nil, nil, code, msg
+	}
+	return dbSubnet, apps, 0, ""
+}
+
+// Converts the page of the subnet leases to the format used in REST API.
+func subnetLeasesToRestAPI(page *kea.SubnetLeasesPage) *models.SubnetLeases {
+	leases := &models.SubnetLeases{
+		Items: []*models.Lease{},
+		Next:  page.Next,
+	}
+	for i := range page.Leases {
+		lease := leaseToRestAPI(&page.Leases[i].Lease)
+		lease.Duplicate = page.Leases[i].Duplicate
+		leases.Items = append(leases.Items, lease)
+	}
+	return leases
+}
+
+// Get the page of the subnet leases merged from all Kea servers serving
+// the subnet. The page starts after the specified address.
+func (r *RestAPI) GetSubnetLeases(ctx context.Context, params dhcp.GetSubnetLeasesParams) middleware.Responder {
+	var from string
+	if params.From != nil {
+		from = strings.TrimSpace(*params.From)
+	}
+
+	var limit int64 = 10
+	if params.Limit != nil {
+		limit = *params.Limit
+	}
+
+	dbSubnet, apps, code, msg := r.getSubnetLeasesSource(params.ID)
+	if dbSubnet == nil {
+		rsp := dhcp.NewGetSubnetLeasesDefault(code).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	page, err := kea.GetSubnetLeasesPage(ctx, r.Agents, dbSubnet, apps, from, limit)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("cannot get leases of subnet with id %d: %s", params.ID, err)
+		rsp := dhcp.NewGetSubnetLeasesDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	rsp := dhcp.NewGetSubnetLeasesOK().WithPayload(subnetLeasesToRestAPI(page))
+	return rsp
+}
+
+// Header of the subnet leases exported in the CSV format.
+var leasesCSVHeader = []string{
+	"ip_address", "lease_type", "prefix_length", "hw_address", "client_id", "duid", "iaid",
+	"hostname", "fqdn_fwd", "fqdn_rev", "cltt", "valid_lifetime", "preferred_lifetime",
+	"state", "subnet_id", "app_id", "app_name", "duplicate",
+}
+
+// Returns the lease as the row of the subnet leases exported in the CSV
+// format.
+func leaseToCSV(lease *kea.SubnetLease) []string {
+	var appName string
+	if lease.App != nil {
+		appName = lease.App.Name
+	}
+	return []string{
+		lease.IPAddress,
+		lease.Type,
+		strconv.FormatUint(uint64(lease.PrefixLength), 10),
+		lease.HWAddress,
+		lease.ClientID,
+		lease.DUID,
+		strconv.FormatUint(uint64(lease.IAID), 10),
+		lease.Hostname,
+		strconv.FormatBool(lease.FqdnFwd),
+		strconv.FormatBool(lease.FqdnRev),
+		strconv.FormatUint(lease.CLTT, 10),
+		strconv.FormatUint(uint64(lease.ValidLifetime), 10),
+		strconv.FormatUint(uint64(lease.PreferredLifetime), 10),
+		strconv.Itoa(lease.State),
+		strconv.FormatUint(uint64(lease.SubnetID), 10),
+		strconv.FormatInt(lease.AppID, 10),
+		appName,
+		strconv.FormatBool(lease.Duplicate),
+	}
+}
+
+// Writer of the exported subnet leases in the CSV or JSON format. The
+// export interrupted by an error is terminated with the error marker
+// instead of closing the writer.
+type leasesExportWriter interface {
+	writeLeases(leases []kea.SubnetLease) error
+	writeError(exportErr error) error
+	close() error
+}
+
+// Prefix of the error marker ending the interrupted export.
+const leasesExportErrorPrefix = "export interrupted: "
+
+// Writes the subnet leases in the CSV format with the header in the
+// first row.
+type leasesCSVWriter struct {
+	writer *csv.Writer
+}
+
+// Creates the CSV writer and writes the header.
+func newLeasesCSVWriter(w io.Writer) (*leasesCSVWriter, error) {
+	writer := &leasesCSVWriter{
+		writer: csv.NewWriter(w),
+	}
+	if err := writer.writer.Write(leasesCSVHeader); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+func (w *leasesCSVWriter) writeLeases(leases []kea.SubnetLease) error {
+	for i := range leases {
+		if err := w.writer.Write(leaseToCSV(&leases[i])); err != nil {
+			return err
+		}
+	}
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// Writes the row with the #error marker in the first column and the
+// error message in the second column.
+func (w *leasesCSVWriter) writeError(exportErr error) error {
+	if err := w.writer.Write([]string{"#error", leasesExportErrorPrefix + exportErr.Error()}); err != nil {
+		return err
+	}
+	return w.close()
+}
+
+func (w *leasesCSVWriter) close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// Writes the subnet leases as the JSON array of the leases in the REST
+// API format. The array is written incrementally, so it is terminated
+// when the writer is closed.
+type leasesJSONWriter struct {
+	writer io.Writer
+	count  int
+}
+
+// Creates the JSON writer and opens the array.
+func newLeasesJSONWriter(w io.Writer) (*leasesJSONWriter, error) {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return nil, err
+	}
+	return &leasesJSONWriter{writer: w}, nil
+}
+
+func (w *leasesJSONWriter) writeLeases(leases []kea.SubnetLease) error {
+	for i := range leases {
+		lease := leaseToRestAPI(&leases[i].Lease)
+		lease.Duplicate = leases[i].Duplicate
+		data, err := json.Marshal(lease)
+		if err != nil {
+			return errors.Wrapf(err, "problem with serializing lease %s", leases[i].IPAddress)
+		}
+		if w.count > 0 {
+			if _, err = io.WriteString(w.writer, ","); err != nil {
+				return err
+			}
+		}
+		if _, err = w.writer.Write(data); err != nil {
+			return err
+		}
+		w.count++
+	}
+	return nil
+}
+
+// Writes the object with the error message as the last element of the
+// array and terminates the array.
+func (w *leasesJSONWriter) writeError(exportErr error) error {
+	data, err := json.Marshal(map[string]string{"error": leasesExportErrorPrefix + exportErr.Error()})
+	if err != nil {
+		return err
+	}
+	if w.count > 0 {
+		if _, err = io.WriteString(w.writer, ","); err != nil {
+			return err
+		}
+	}
+	if _, err = w.writer.Write(data); err != nil {
+		return err
+	}
+	return w.close()
+}
+
+func (w *leasesJSONWriter) close() error {
+	_, err := io.WriteString(w.writer, "]")
+	return err
+}
+
+// Exports all leases of the subnet in the CSV or JSON format. The leases
+// are fetched from the Kea servers page by page and each page is written
+// to the response before the next one is fetched, so the whole list is
+// never held in memory. The first page is fetched before responding to
+// report the errors with the HTTP status code. The later errors interrupt
+// the export, and they are reported with the error marker at the end of
+// the exported data: the row with #error in the first column in the CSV
+// format, or the object with the error field as the last element of the
+// array in the JSON format.
+func (r *RestAPI) ExportSubnetLeases(ctx context.Context, params dhcp.ExportSubnetLeasesParams) middleware.Responder {
+	format := "csv"
+	if params.Format != nil {
+		format = *params.Format
+	}
+	var contentType string
+	switch format {
+	case "csv":
+		contentType = "text/csv"
+	case "json":
+		contentType = "application/json"
+	default:
+		msg := fmt.Sprintf("unsupported format %s of the exported leases", format)
+		rsp := dhcp.NewExportSubnetLeasesDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	dbSubnet, apps, code, msg := r.getSubnetLeasesSource(params.ID)
+	if dbSubnet == nil {
+		rsp := dhcp.NewExportSubnetLeasesDefault(code).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	page, err := kea.GetSubnetLeasesPage(ctx, r.Agents, dbSubnet, apps, "", leasesExportPageSize)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("cannot get leases of subnet with id %d: %s", params.ID, err)
+		rsp := dhcp.NewExportSubnetLeasesDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	return middleware.ResponderFunc(func(rw http.ResponseWriter, _ runtime.Producer) {
+		rw.Header().Set("Content-Type", contentType)
+		rw.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"subnet-%d-leases.%s\"", params.ID, format))
+		rw.WriteHeader(http.StatusOK)
+
+		var writer leasesExportWriter
+		var err error
+		if format == "json" {
+			writer, err = newLeasesJSONWriter(rw)
+		} else {
+			writer, err = newLeasesCSVWriter(rw)
+		}
+		for err == nil {
+			if err = writer.writeLeases(page.Leases); err != nil {
+				break
+			}
+			if flusher, ok := rw.(http.Flusher); ok {
+				flusher.Flush()
+			}
+			if len(page.Next) == 0 {
+				err = writer.close()
+				break
+			}
+			page, err = kea.GetSubnetLeasesPage(ctx, r.Agents, dbSubnet, apps, page.Next, leasesExportPageSize)
+			if err != nil {
+				// The client is informed about the interrupted export.
+				if markerErr := writer.writeError(err); markerErr != nil {
+					log.Errorf("problem with writing the error marker of the leases export: %+v", markerErr)
+				}
+			}
+		}
+		if err != nil {
+			log.Errorf("export of leases of subnet with id %d interrupted: %+v", params.ID, err)
+		}
+	})
+}
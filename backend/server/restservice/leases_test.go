@@ -1,14 +1,25 @@
 package restservice
 
 import (
+	"bytes"
 	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 	keactrl "isc.org/stork/appctrl/kea"
+	keadata "isc.org/stork/appdata/kea"
 	agentcommtest "isc.org/stork/server/agentcomm/test"
+	"isc.org/stork/server/apps/kea"
 	dbmodel "isc.org/stork/server/database/model"
 	dbtest "isc.org/stork/server/database/test"
+	"isc.org/stork/server/gen/models"
 	dhcp "isc.org/stork/server/gen/restapi/operations/d_h_c_p"
 	storktest "isc.org/stork/server/test"
 )
@@ -377,3 +388,342 @@ func TestFindLeasesEmptyText(t *testing.T) {
 	require.Empty(t, okRsp.Payload.ErredApps)
 	require.Zero(t, okRsp.Payload.Total)
 }
+
+// Test that the wipe token confirms the wipe once, for the subnet and
+// the user for which it has been issued.
+func TestLeaseWipeTokens(t *testing.T) {
+	tokens := leaseWipeTokens{}
+	token, err := tokens.issue(1, 2)
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+
+	require.False(t, tokens.consume("foo", 1, 2))
+	require.False(t, tokens.consume(token, 1, 3))
+	// The token is invalidated by the failed attempt.
+	require.False(t, tokens.consume(token, 1, 2))
+
+	token, err = tokens.issue(1, 2)
+	require.NoError(t, err)
+	require.True(t, tokens.consume(token, 1, 2))
+	require.False(t, tokens.consume(token, 1, 2))
+
+	// Expired token.
+	token, err = tokens.issue(1, 2)
+	require.NoError(t, err)
+	pending := tokens.tokens[token]
+	pending.expiresAt = time.Now().Add(-time.Second)
+	tokens.tokens[token] = pending
+	require.False(t, tokens.consume(token, 1, 2))
+}
+
+// Test deleting, updating and wiping the leases and resending the DDNS
+// updates in two Kea servers via REST API.
+func TestLeaseActions(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	settings := RestAPISettings{}
+	fa := agentcommtest.NewFakeAgents(mockKeaCmdResponses(
+		// lease4-get and lease4-del
+		`[{"result": 0, "arguments": {"ip-address": "192.0.2.10", "hw-address": "01:02:03:04:05:06", "subnet-id": 7}}]`,
+		`[{"result": 0, "arguments": {"ip-address": "192.0.2.10", "hw-address": "01:02:03:04:05:06", "subnet-id": 7}}]`,
+		`[{"result": 0, "text": "IPv4 lease deleted."}]`,
+		`[{"result": 3, "text": "IPv4 lease not found."}]`,
+		// lease4-get not finding the lease
+		`[{"result": 3, "text": "IPv4 lease not found."}]`,
+		`[{"result": 3, "text": "IPv4 lease not found."}]`,
+		// lease4-update
+		`[{"result": 0, "text": "IPv4 lease updated."}]`,
+		`[{"result": 1, "text": "invalid subnet-id"}]`,
+		// lease4-get and lease4-resend-ddns
+		`[{"result": 0, "arguments": {"ip-address": "192.0.2.10", "hw-address": "01:02:03:04:05:06", "subnet-id": 7}}]`,
+		`[{"result": 3, "text": "IPv4 lease not found."}]`,
+		`[{"result": 0, "text": "NCR generated"}]`,
+		// lease4-wipe
+		`[{"result": 0, "text": "Deleted 5 IPv4 lease(s)"}]`,
+		`[{"result": 0, "text": "Deleted 5 IPv4 lease(s)"}]`,
+	), nil)
+	fec := &storktest.FakeEventCenter{}
+	rapi, err := NewRestAPI(&settings, dbSettings, db, fa, fec, nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	admin, err := dbmodel.GetUserByID(rapi.DB, 1)
+	require.NoError(t, err)
+	ctx, err = rapi.SessionManager.Load(ctx, "")
+	require.NoError(t, err)
+	err = rapi.SessionManager.LoginHandler(ctx, admin)
+	require.NoError(t, err)
+
+	subnet := &dbmodel.Subnet{
+		Prefix: "192.0.2.0/24",
+	}
+	err = dbmodel.AddSubnet(db, subnet)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		m := &dbmodel.Machine{
+			Address:   "localhost",
+			AgentPort: int64(8080 + i),
+		}
+		err = dbmodel.AddMachine(db, m)
+		require.NoError(t, err)
+		accessPoints := []*dbmodel.AccessPoint{}
+		accessPoints = dbmodel.AppendAccessPoint(accessPoints, dbmodel.AccessPointControl, "localhost", "", int64(8000+i))
+		daemon := dbmodel.NewKeaDaemon(dbmodel.DaemonNameDHCPv4, true)
+		err = daemon.SetConfigFromJSON(`{"Dhcp4": {
+            "subnet4": [{"id": 7, "subnet": "192.0.2.0/24"}],
+            "hooks-libraries": [{"library": "libdhcp_lease_cmds.so"}]
+        }}`)
+		require.NoError(t, err)
+		app := &dbmodel.App{
+			MachineID:    m.ID,
+			Type:         dbmodel.AppTypeKea,
+			Name:         fmt.Sprintf("dhcp-server%d", i),
+			AccessPoints: accessPoints,
+			Daemons:      []*dbmodel.Daemon{daemon},
+		}
+		_, err = dbmodel.AddApp(db, app)
+		require.NoError(t, err)
+		err = dbmodel.AddAppToSubnet(db, subnet, app)
+		require.NoError(t, err)
+	}
+
+	// The lease is deleted from the server holding it.
+	rsp := rapi.DeleteLease(ctx, dhcp.DeleteLeaseParams{IPAddress: "192.0.2.10"})
+	require.IsType(t, &dhcp.DeleteLeaseOK{}, rsp)
+	result := rsp.(*dhcp.DeleteLeaseOK).Payload
+	require.True(t, result.Applied)
+	require.Len(t, result.Results, 2)
+	require.Equal(t, "lease4-del", result.Results[0].Command)
+	require.Len(t, fec.Events, 2)
+	require.Equal(t, dbmodel.EventCodeLeaseDeleted, fec.Events[0].Code)
+	require.EqualValues(t, admin.ID, fec.Events[0].Relations.UserID)
+	require.Contains(t, fec.Events[0].Text, "deleted lease 192.0.2.10")
+
+	// The lease is not found.
+	rsp = rapi.DeleteLease(ctx, dhcp.DeleteLeaseParams{IPAddress: "192.0.2.10"})
+	require.IsType(t, &dhcp.DeleteLeaseDefault{}, rsp)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*rsp.(*dhcp.DeleteLeaseDefault)))
+
+	// The lease is updated in both servers serving the subnet.
+	subnetID := subnet.ID
+	rsp = rapi.UpdateLease(ctx, dhcp.UpdateLeaseParams{
+		IPAddress: "192.0.2.10",
+		Lease: &models.LeaseReq{
+			SubnetID:      &subnetID,
+			HwAddress:     "01:02:03:04:05:06",
+			ValidLifetime: 3600,
+		},
+	})
+	require.IsType(t, &dhcp.UpdateLeaseOK{}, rsp)
+	result = rsp.(*dhcp.UpdateLeaseOK).Payload
+	require.False(t, result.Applied)
+	require.Len(t, result.Results, 2)
+	require.Len(t, fec.Events, 4)
+	require.Equal(t, dbmodel.EventCodeLeaseUpdated, fec.Events[2].Code)
+	require.Equal(t, dbmodel.EventCodeLeaseActionFailed, fec.Events[3].Code)
+	require.EqualValues(t, subnet.ID, fec.Events[3].Relations.SubnetID)
+
+	// The DDNS update is resent.
+	rsp = rapi.ResendLeaseDdns(ctx, dhcp.ResendLeaseDdnsParams{IPAddress: "192.0.2.10"})
+	require.IsType(t, &dhcp.ResendLeaseDdnsOK{}, rsp)
+	result = rsp.(*dhcp.ResendLeaseDdnsOK).Payload
+	require.True(t, result.Applied)
+	require.Len(t, result.Results, 1)
+
+	// The wipe returns the token first.
+	rsp = rapi.WipeSubnetLeases(ctx, dhcp.WipeSubnetLeasesParams{ID: subnet.ID})
+	require.IsType(t, &dhcp.WipeSubnetLeasesOK{}, rsp)
+	result = rsp.(*dhcp.WipeSubnetLeasesOK).Payload
+	require.False(t, result.Applied)
+	require.NotEmpty(t, result.Token)
+	require.Empty(t, result.Results)
+	token := result.Token
+
+	invalidToken := "foo"
+	rsp = rapi.WipeSubnetLeases(ctx, dhcp.WipeSubnetLeasesParams{ID: subnet.ID, Token: &invalidToken})
+	require.IsType(t, &dhcp.WipeSubnetLeasesDefault{}, rsp)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*rsp.(*dhcp.WipeSubnetLeasesDefault)))
+
+	rsp = rapi.WipeSubnetLeases(ctx, dhcp.WipeSubnetLeasesParams{ID: subnet.ID, Token: &token})
+	require.IsType(t, &dhcp.WipeSubnetLeasesOK{}, rsp)
+	result = rsp.(*dhcp.WipeSubnetLeasesOK).Payload
+	require.True(t, result.Applied)
+	require.Len(t, result.Results, 2)
+	require.Equal(t, "lease4-wipe", result.Results[0].Command)
+	require.Equal(t, dbmodel.EventCodeLeasesWiped, fec.Events[len(fec.Events)-1].Code)
+}
+
+// Test that the subnet leases are written in the CSV and JSON formats.
+func TestLeasesExportWriters(t *testing.T) {
+	leases := []kea.SubnetLease{
+		{
+			Lease: dbmodel.Lease{
+				Lease: keadata.Lease{
+					IPAddress: "192.0.2.1",
+					HWAddress: "01:02:03:04:05:06",
+					SubnetID:  7,
+				},
+				AppID: 1,
+				App:   &dbmodel.App{Name: "dhcp-server0"},
+			},
+		},
+		{
+			Lease: dbmodel.Lease{
+				Lease: keadata.Lease{
+					IPAddress: "192.0.2.1",
+					HWAddress: "01:02:03:04:05:06",
+					SubnetID:  8,
+				},
+				AppID: 2,
+			},
+			Duplicate: true,
+		},
+	}
+
+	var buf bytes.Buffer
+	csvWriter, err := newLeasesCSVWriter(&buf)
+	require.NoError(t, err)
+	require.NoError(t, csvWriter.writeLeases(leases[:1]))
+	require.NoError(t, csvWriter.writeLeases(leases[1:]))
+	require.NoError(t, csvWriter.close())
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	require.Equal(t, leasesCSVHeader, records[0])
+	require.Equal(t, "192.0.2.1", records[1][0])
+	require.Equal(t, "dhcp-server0", records[1][16])
+	require.Equal(t, "false", records[1][17])
+	require.Equal(t, "8", records[2][14])
+	require.Equal(t, "true", records[2][17])
+
+	buf.Reset()
+	jsonWriter, err := newLeasesJSONWriter(&buf)
+	require.NoError(t, err)
+	require.NoError(t, jsonWriter.writeLeases(leases[:1]))
+	require.NoError(t, jsonWriter.writeLeases(leases[1:]))
+	require.NoError(t, jsonWriter.close())
+	exported := []models.Lease{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &exported))
+	require.Len(t, exported, 2)
+	require.Equal(t, "192.0.2.1", *exported[0].IPAddress)
+	require.False(t, exported[0].Duplicate)
+	require.True(t, exported[1].Duplicate)
+
+	// No leases.
+	buf.Reset()
+	jsonWriter, err = newLeasesJSONWriter(&buf)
+	require.NoError(t, err)
+	require.NoError(t, jsonWriter.close())
+	require.Equal(t, "[]", buf.String())
+
+	// The interrupted export ends with the error marker.
+	buf.Reset()
+	csvWriter, err = newLeasesCSVWriter(&buf)
+	require.NoError(t, err)
+	require.NoError(t, csvWriter.writeLeases(leases[:1]))
+	require.NoError(t, csvWriter.writeError(errors.New("connection refused")))
+	reader := csv.NewReader(&buf)
+	reader.FieldsPerRecord = -1
+	records, err = reader.ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	require.Equal(t, []string{"#error", "export interrupted: connection refused"}, records[2])
+
+	buf.Reset()
+	jsonWriter, err = newLeasesJSONWriter(&buf)
+	require.NoError(t, err)
+	require.NoError(t, jsonWriter.writeLeases(leases[:1]))
+	require.NoError(t, jsonWriter.writeError(errors.New("connection refused")))
+	elements := []map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &elements))
+	require.Len(t, elements, 2)
+	require.Equal(t, "192.0.2.1", elements[0]["ipAddress"])
+	require.Equal(t, "export interrupted: connection refused", elements[1]["error"])
+}
+
+// Test that the subnet leases are listed and exported via REST API.
+func TestGetExportSubnetLeases(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	settings := RestAPISettings{}
+	fa := agentcommtest.NewFakeAgents(mockKeaCmdResponses(
+		// The listing.
+		`[{"result": 0, "arguments": {"leases": [
+            {"ip-address": "192.0.2.1", "hw-address": "01:02:03:04:05:06", "subnet-id": 7},
+            {"ip-address": "192.0.2.2", "hw-address": "01:02:03:04:05:07", "subnet-id": 7}
+        ], "count": 2}}]`,
+		// The export.
+		`[{"result": 0, "arguments": {"leases": [
+            {"ip-address": "192.0.2.1", "hw-address": "01:02:03:04:05:06", "subnet-id": 7}
+        ], "count": 1}}]`,
+		`[{"result": 0, "arguments": {"leases": [
+            {"ip-address": "192.0.2.1", "hw-address": "01:02:03:04:05:06", "subnet-id": 7}
+        ], "count": 1}}]`,
+	), nil)
+	rapi, err := NewRestAPI(&settings, dbSettings, db, fa, &storktest.FakeEventCenter{}, nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	subnet := &dbmodel.Subnet{
+		Prefix: "192.0.2.0/24",
+	}
+	err = dbmodel.AddSubnet(db, subnet)
+	require.NoError(t, err)
+
+	m := &dbmodel.Machine{
+		Address:   "localhost",
+		AgentPort: 8080,
+	}
+	err = dbmodel.AddMachine(db, m)
+	require.NoError(t, err)
+	accessPoints := []*dbmodel.AccessPoint{}
+	accessPoints = dbmodel.AppendAccessPoint(accessPoints, dbmodel.AccessPointControl, "localhost", "", 8000)
+	daemon := dbmodel.NewKeaDaemon(dbmodel.DaemonNameDHCPv4, true)
+	err = daemon.SetConfigFromJSON(`{"Dhcp4": {
+        "subnet4": [{"id": 7, "subnet": "192.0.2.0/24"}],
+        "hooks-libraries": [{"library": "libdhcp_lease_cmds.so"}]
+    }}`)
+	require.NoError(t, err)
+	app := &dbmodel.App{
+		MachineID:    m.ID,
+		Type:         dbmodel.AppTypeKea,
+		Name:         "dhcp-server",
+		AccessPoints: accessPoints,
+		Daemons:      []*dbmodel.Daemon{daemon},
+	}
+	_, err = dbmodel.AddApp(db, app)
+	require.NoError(t, err)
+	err = dbmodel.AddAppToSubnet(db, subnet, app)
+	require.NoError(t, err)
+
+	limit := int64(2)
+	rsp := rapi.GetSubnetLeases(ctx, dhcp.GetSubnetLeasesParams{ID: subnet.ID, Limit: &limit})
+	require.IsType(t, &dhcp.GetSubnetLeasesOK{}, rsp)
+	leases := rsp.(*dhcp.GetSubnetLeasesOK).Payload
+	require.Len(t, leases.Items, 2)
+	require.Equal(t, "192.0.2.2", *leases.Items[1].IPAddress)
+	require.Equal(t, "dhcp-server", *leases.Items[1].AppName)
+	require.Equal(t, "192.0.2.2", leases.Next)
+
+	rsp = rapi.GetSubnetLeases(ctx, dhcp.GetSubnetLeasesParams{ID: subnet.ID + 1})
+	require.IsType(t, &dhcp.GetSubnetLeasesDefault{}, rsp)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*rsp.(*dhcp.GetSubnetLeasesDefault)))
+
+	for _, format := range []string{"csv", "json"} {
+		format := format
+		rsp = rapi.ExportSubnetLeases(ctx, dhcp.ExportSubnetLeasesParams{ID: subnet.ID, Format: &format})
+		recorder := httptest.NewRecorder()
+		rsp.WriteResponse(recorder, nil)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Contains(t, recorder.Header().Get("Content-Disposition"), "leases."+format)
+		require.Contains(t, recorder.Body.String(), "192.0.2.1")
+	}
+
+	format := "xml"
+	rsp = rapi.ExportSubnetLeases(ctx, dhcp.ExportSubnetLeasesParams{ID: subnet.ID, Format: &format})
+	require.IsType(t, &dhcp.ExportSubnetLeasesDefault{}, rsp)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*rsp.(*dhcp.ExportSubnetLeasesDefault)))
+}
@@ -5,10 +5,14 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/go-openapi/runtime/middleware"
+	"github.com/go-openapi/strfmt"
 	log "github.com/sirupsen/logrus"
 
+	"isc.org/stork/server/agentcomm"
+	"isc.org/stork/server/apps/kea"
 	dbmodel "isc.org/stork/server/database/model"
 	"isc.org/stork/server/gen/models"
 	"isc.org/stork/server/gen/restapi/operations/services"
@@ -55,13 +59,90 @@ func (r *RestAPI) GetLogTail(ctx context.Context, params services.GetLogTailPara
 		maxLength = *params.MaxLength
 	}
 
+	// By default the tail is taken from the end of the log.
+	anchor := agentcomm.LogAnchor{}
+	if params.AnchorFileID != nil {
+		anchor.FileID = *params.AnchorFileID
+	}
+	if params.AnchorOffset != nil {
+		anchor.Offset = *params.AnchorOffset
+	}
+	filter := ""
+	if params.Filter != nil {
+		filter = *params.Filter
+	}
+
+	// The structured filters apply to the Kea log records only.
+	recordFilter := &kea.LogRecordFilter{
+		Severities: params.Severity,
+	}
+	if params.MessageID != nil {
+		recordFilter.MessageIDPrefix = *params.MessageID
+	}
+	if params.From != nil {
+		recordFilter.From = time.Time(*params.From)
+	}
+	if params.To != nil {
+		recordFilter.To = time.Time(*params.To)
+	}
+	isKea := dbLogTarget.Daemon.App.Type == dbmodel.AppTypeKea
+	if !recordFilter.IsEmpty() && !isKea {
+		msg := fmt.Sprintf("filtering log records from %s app is not supported", dbLogTarget.Daemon.App.Type)
+		log.Warn(msg)
+		rsp := services.NewGetLogTailDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	// Let the agent select the lines by severity and message ID if
+	// the lines are not filtered otherwise.
+	if len(filter) == 0 {
+		filter = recordFilter.GetLineFilter()
+	}
+
 	// Send the request to the agent to tail the file.
-	contents, err := r.Agents.TailTextFile(ctx, dbLogTarget.Daemon.App.Machine.Address,
-		dbLogTarget.Daemon.App.Machine.AgentPort, dbLogTarget.Output, maxLength)
+	textFileTail, err := r.Agents.TailTextFile(ctx, dbLogTarget.Daemon.App.Machine.Address,
+		dbLogTarget.Daemon.App.Machine.AgentPort, dbLogTarget.Output, maxLength, anchor, filter, 0)
 
 	errStr := ""
 	if err != nil {
 		errStr = err.Error()
+		textFileTail = &agentcomm.TextFileTail{}
+	}
+
+	var files []*models.LogFile
+	for _, file := range textFileTail.Files {
+		files = append(files, &models.LogFile{
+			ID:         file.ID,
+			Path:       file.Path,
+			Size:       file.Size,
+			ModTime:    strfmt.DateTime(file.ModTime),
+			Compressed: file.Compressed,
+		})
+	}
+
+	var (
+		records       []*models.LogRecord
+		messageCounts []*models.LogMessageCount
+	)
+	if isKea {
+		dbRecords := kea.FilterLogRecords(kea.ParseLogLines(textFileTail.Lines), recordFilter)
+		for _, record := range dbRecords {
+			records = append(records, &models.LogRecord{
+				Time:      strfmt.DateTime(record.Time),
+				Severity:  record.Severity,
+				Logger:    record.Logger,
+				MessageID: record.MessageID,
+				Text:      record.Text,
+			})
+		}
+		for _, count := range kea.CountLogRecords(dbRecords) {
+			messageCounts = append(messageCounts, &models.LogMessageCount{
+				MessageID: count.MessageID,
+				Severity:  count.Severity,
+				Count:     count.Count,
+			})
+		}
 	}
 
 	// Everything ok. Return the response.
@@ -71,12 +152,18 @@ func (r *RestAPI) GetLogTail(ctx context.Context, params services.GetLogTailPara
 			Address:  dbLogTarget.Daemon.App.Machine.Address,
 			Hostname: dbLogTarget.Daemon.App.Machine.State.Hostname,
 		},
-		AppID:           dbLogTarget.Daemon.App.ID,
-		AppName:         dbLogTarget.Daemon.App.Name,
-		AppType:         dbLogTarget.Daemon.App.Type,
-		LogTargetOutput: dbLogTarget.Output,
-		Contents:        contents,
-		Error:           errStr,
+		AppID:            dbLogTarget.Daemon.App.ID,
+		AppName:          dbLogTarget.Daemon.App.Name,
+		AppType:          dbLogTarget.Daemon.App.Type,
+		LogTargetOutput:  dbLogTarget.Output,
+		Contents:         textFileTail.Lines,
+		NextAnchorFileID: textFileTail.Next.FileID,
+		NextAnchorOffset: textFileTail.Next.Offset,
+		Beginning:        textFileTail.Beginning,
+		Files:            files,
+		Records:          records,
+		MessageCounts:    messageCounts,
+		Error:            errStr,
 	}
 	rsp := services.NewGetLogTailOK().WithPayload(tail)
 
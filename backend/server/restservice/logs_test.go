@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"net/http"
 	"testing"
+	"time"
 
+	"github.com/go-openapi/strfmt"
 	"github.com/stretchr/testify/require"
+	"isc.org/stork/server/agentcomm"
 	agentcommtest "isc.org/stork/server/agentcomm/test"
 	dbmodel "isc.org/stork/server/database/model"
 	dbtest "isc.org/stork/server/database/test"
@@ -79,6 +82,141 @@ func TestGetLogTail(t *testing.T) {
 	require.Equal(t, "/tmp/filename.log", okRsp.LogTargetOutput)
 	require.Len(t, okRsp.Contents, 1)
 	require.Equal(t, "lorem ipsum", okRsp.Contents[0])
+	require.Equal(t, "1", okRsp.NextAnchorFileID)
+	require.Zero(t, okRsp.NextAnchorOffset)
+	require.True(t, okRsp.Beginning)
+	require.Len(t, okRsp.Files, 1)
+	require.Equal(t, "1", okRsp.Files[0].ID)
+	require.Equal(t, "/tmp/filename.log", okRsp.Files[0].Path)
+	require.Empty(t, fa.RecordedLogAnchor.FileID)
+
+	// Page backward through the log and filter the lines.
+	anchorFileID := "1"
+	anchorOffset := int64(12)
+	filter := "lorem"
+	params = services.GetLogTailParams{
+		ID:           a.Daemons[0].LogTargets[0].ID,
+		AnchorFileID: &anchorFileID,
+		AnchorOffset: &anchorOffset,
+		Filter:       &filter,
+	}
+	rsp = rapi.GetLogTail(ctx, params)
+	require.IsType(t, &services.GetLogTailOK{}, rsp)
+	okRsp = rsp.(*services.GetLogTailOK).Payload
+	require.Equal(t, agentcomm.LogAnchor{FileID: "1", Offset: 12}, fa.RecordedLogAnchor)
+	require.Equal(t, filter, fa.RecordedFilter)
+	// The line is not a Kea log message.
+	require.Empty(t, okRsp.Records)
+}
+
+// This test verifies that the Kea log lines are parsed into records which
+// can be filtered and counted.
+func TestGetLogTailKeaRecords(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	m := &dbmodel.Machine{
+		Address:   "localhost",
+		AgentPort: 8080,
+	}
+	err := dbmodel.AddMachine(db, m)
+	require.NoError(t, err)
+
+	a := &dbmodel.App{
+		MachineID: m.ID,
+		Type:      dbmodel.AppTypeKea,
+		Active:    true,
+		Daemons: []*dbmodel.Daemon{
+			{
+				Name:   "kea-dhcp4",
+				Active: true,
+				LogTargets: []*dbmodel.LogTarget{
+					{
+						Output: "/tmp/kea-dhcp4.log",
+					},
+				},
+			},
+		},
+	}
+	_, err = dbmodel.AddApp(db, a)
+	require.NoError(t, err)
+
+	bind9App := &dbmodel.App{
+		MachineID: m.ID,
+		Type:      dbmodel.AppTypeBind9,
+		Active:    true,
+		Daemons: []*dbmodel.Daemon{
+			{
+				Name:   "named",
+				Active: true,
+				LogTargets: []*dbmodel.LogTarget{
+					{
+						Output: "/tmp/named.log",
+					},
+				},
+			},
+		},
+	}
+	_, err = dbmodel.AddApp(db, bind9App)
+	require.NoError(t, err)
+
+	settings := RestAPISettings{}
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	fa.TextFileLines = []string{
+		"2020-10-12 10:11:14.001 INFO  [kea-dhcp4.leases/1234.140] DHCP4_LEASE_ALLOC lease 192.0.2.1 has been allocated",
+		"2020-10-12 10:11:15.002 INFO  [kea-dhcp4.leases/1234.140] DHCP4_LEASE_ADVERT lease 192.0.2.2 will be advertised",
+		"2020-10-12 10:11:16.003 INFO  [kea-dhcp4.leases/1234.140] DHCP4_LEASE_ALLOC lease 192.0.2.2 has been allocated",
+	}
+	fec := &storktest.FakeEventCenter{}
+	rapi, err := NewRestAPI(&settings, dbSettings, db, fa, fec, nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// All lines are parsed and counted.
+	params := services.GetLogTailParams{
+		ID: a.Daemons[0].LogTargets[0].ID,
+	}
+	rsp := rapi.GetLogTail(ctx, params)
+	require.IsType(t, &services.GetLogTailOK{}, rsp)
+	okRsp := rsp.(*services.GetLogTailOK).Payload
+	require.Len(t, okRsp.Contents, 3)
+	require.Len(t, okRsp.Records, 3)
+	require.Equal(t, "INFO", okRsp.Records[0].Severity)
+	require.Equal(t, "kea-dhcp4.leases", okRsp.Records[0].Logger)
+	require.Equal(t, "DHCP4_LEASE_ALLOC", okRsp.Records[0].MessageID)
+	require.Equal(t, "lease 192.0.2.1 has been allocated", okRsp.Records[0].Text)
+	require.Len(t, okRsp.MessageCounts, 2)
+	require.Equal(t, "DHCP4_LEASE_ALLOC", okRsp.MessageCounts[0].MessageID)
+	require.EqualValues(t, 2, okRsp.MessageCounts[0].Count)
+	require.Empty(t, fa.RecordedFilter)
+
+	// The severity and message ID filter is sent to the agent and the
+	// time range is applied on the server.
+	messageID := "DHCP4_LEASE_ALLOC"
+	from := strfmt.DateTime(time.Date(2020, 10, 12, 10, 11, 15, 0, time.Local))
+	params = services.GetLogTailParams{
+		ID:        a.Daemons[0].LogTargets[0].ID,
+		Severity:  []string{"INFO"},
+		MessageID: &messageID,
+		From:      &from,
+	}
+	rsp = rapi.GetLogTail(ctx, params)
+	require.IsType(t, &services.GetLogTailOK{}, rsp)
+	okRsp = rsp.(*services.GetLogTailOK).Payload
+	require.NotEmpty(t, fa.RecordedFilter)
+	require.Len(t, okRsp.Records, 1)
+	require.Contains(t, okRsp.Records[0].Text, "192.0.2.2")
+	require.Len(t, okRsp.MessageCounts, 1)
+
+	// Filtering the records is not supported for BIND 9 logs.
+	params = services.GetLogTailParams{
+		ID:       bind9App.Daemons[0].LogTargets[0].ID,
+		Severity: []string{"INFO"},
+	}
+	rsp = rapi.GetLogTail(ctx, params)
+	require.IsType(t, &services.GetLogTailDefault{}, rsp)
+	defaultRsp := rsp.(*services.GetLogTailDefault)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*defaultRsp))
 }
 
 // Test that error is returned when invalid parameters are specified while
@@ -47,6 +47,50 @@ func (r *RestAPI) machineToRestAPI(dbMachine dbmodel.Machine) *models.Machine {
 		apps = append(apps, a)
 	}
 
+	var interfaces []*models.NetworkInterface
+	for _, iface := range dbMachine.State.Interfaces {
+		interfaces = append(interfaces, &models.NetworkInterface{
+			Name:            iface.Name,
+			HardwareAddress: iface.HardwareAddress,
+			Mtu:             iface.Mtu,
+			Flags:           iface.Flags,
+			Addresses:       iface.Addresses,
+		})
+	}
+
+	var filesystems []*models.FilesystemUsage
+	for _, filesystem := range dbMachine.State.Filesystems {
+		filesystems = append(filesystems, &models.FilesystemUsage{
+			Path:        filesystem.Path,
+			Kind:        filesystem.Kind,
+			Fstype:      filesystem.Fstype,
+			Total:       filesystem.Total,
+			Used:        filesystem.Used,
+			UsedPercent: filesystem.UsedPercent,
+			Error:       filesystem.Error,
+		})
+	}
+
+	var databases []*models.DatabaseBackend
+	for _, database := range dbMachine.State.Databases {
+		databases = append(databases, &models.DatabaseBackend{
+			Kind:      database.Kind,
+			Type:      database.Type,
+			Host:      database.Host,
+			Port:      database.Port,
+			Name:      database.Name,
+			Reachable: database.Reachable,
+			Error:     database.Error,
+		})
+	}
+
+	// The time of the last clock check is only returned when the agent
+	// has reported its time.
+	var clockCheckedAt strfmt.DateTime
+	if !dbMachine.State.ClockCheckedAt.IsZero() {
+		clockCheckedAt = strfmt.DateTime(dbMachine.State.ClockCheckedAt)
+	}
+
 	m := models.Machine{
 		ID:                   dbMachine.ID,
 		Address:              &dbMachine.Address,
@@ -69,6 +113,13 @@ func (r *RestAPI) machineToRestAPI(dbMachine dbmodel.Machine) *models.Machine {
 		VirtualizationSystem: dbMachine.State.VirtualizationSystem,
 		VirtualizationRole:   dbMachine.State.VirtualizationRole,
 		HostID:               dbMachine.State.HostID,
+		Interfaces:           interfaces,
+		Filesystems:          filesystems,
+		Databases:            databases,
+		ClockSkew:            dbMachine.State.ClockSkew,
+		ClockRoundTrip:       dbMachine.State.ClockRoundTrip,
+		ClockSyncStatus:      dbMachine.State.ClockSyncStatus,
+		ClockCheckedAt:       clockCheckedAt,
 		LastVisitedAt:        strfmt.DateTime(dbMachine.LastVisitedAt),
 		Error:                dbMachine.Error,
 		Apps:                 apps,
@@ -386,7 +437,7 @@ func (r *RestAPI) CreateMachine(ctx context.Context, params services.CreateMachi
 			})
 			return rsp
 		}
-		r.EventCenter.AddInfoEvent("added {machine}", dbMachine)
+		r.EventCenter.AddInfoEvent("added {machine}", dbMachine, dbmodel.EventCodeMachineAdded)
 	} else {
 		dbMachine.AgentToken = params.Machine.AgentToken
 		dbMachine.CertFingerprint = agentCertFingerprint
@@ -400,7 +451,7 @@ func (r *RestAPI) CreateMachine(ctx context.Context, params services.CreateMachi
 			})
 			return rsp
 		}
-		r.EventCenter.AddInfoEvent("re-registered {machine}", dbMachine)
+		r.EventCenter.AddInfoEvent("re-registered {machine}", dbMachine, dbmodel.EventCodeMachineReregistered)
 	}
 
 	m := &models.NewMachineResp{
@@ -673,7 +724,7 @@ func (r *RestAPI) DeleteMachine(ctx context.Context, params services.DeleteMachi
 		return rsp
 	}
 
-	r.EventCenter.AddInfoEvent("removed {machine}", dbMachine)
+	r.EventCenter.AddInfoEvent("removed {machine}", dbMachine, dbmodel.EventCodeMachineRemoved)
 
 	rsp := services.NewDeleteMachineOK()
 
@@ -812,6 +863,14 @@ func (r *RestAPI) appToRestAPI(dbApp *dbmodel.App) *models.App {
 			QueryHitRatio:   queryHitRatio,
 			AgentCommErrors: agentErrors,
 		}
+		for _, logTarget := range dbApp.Daemons[0].LogTargets {
+			bind9Daemon.LogTargets = append(bind9Daemon.LogTargets, &models.LogTarget{
+				ID:       logTarget.ID,
+				Name:     logTarget.Name,
+				Severity: logTarget.Severity,
+				Output:   logTarget.Output,
+			})
+		}
 		var bind9Stats *agentcomm.AgentBind9CommStats
 		if agentStats != nil && accessPoint != nil {
 			if bind9Stats, _ = agentStats.AppCommStats[agentcomm.AppCommStatsKey{
@@ -1022,7 +1081,32 @@ func getKeaServicesStatus(db *dbops.PgDB, app *dbmodel.App) *models.ServicesStat
 				commInterrupted[i] = 1
 			}
 		}
+		// Negative value indicates that the clocks of the HA peers have not
+		// been compared yet.
+		peersClockSkew := int64(-1)
+		if ha.PeersClockSkew != nil {
+			peersClockSkew = *ha.PeersClockSkew
+		}
+		// Differences between the configurations of the HA peers.
+		var configCheckedAt strfmt.DateTime
+		if !ha.ConfigCheckedAt.IsZero() {
+			configCheckedAt = strfmt.DateTime(ha.ConfigCheckedAt)
+		}
+		configDiffs := []*models.HAConfigDiff{}
+		for _, diff := range ha.ConfigDiffs {
+			configDiffs = append(configDiffs, &models.HAConfigDiff{
+				DaemonID:   diff.DaemonID,
+				Element:    diff.Element,
+				Difference: diff.Difference,
+				Path:       diff.Path,
+				Primary:    diff.Primary,
+				Peer:       diff.Peer,
+			})
+		}
 		keaStatus.HaServers = &models.KeaStatusHaServers{
+			PeersClockSkew:  peersClockSkew,
+			ConfigCheckedAt: configCheckedAt,
+			ConfigDiffs:     configDiffs,
 			PrimaryServer: &models.KeaHAServerStatus{
 				Age:                age[0],
 				AppID:              appID[0],
@@ -1356,9 +1440,9 @@ func (r *RestAPI) UpdateDaemon(ctx context.Context, params services.UpdateDaemon
 
 	if oldMonitored != params.Daemon.Monitored {
 		if params.Daemon.Monitored {
-			r.EventCenter.AddInfoEvent("{user} enabled monitoring {daemon}", dbUser, dbDaemon, dbDaemon.App, dbDaemon.App.Machine)
+			r.EventCenter.AddInfoEvent("{user} enabled monitoring {daemon}", dbUser, dbDaemon, dbDaemon.App, dbDaemon.App.Machine, dbmodel.EventCodeDaemonMonitoringEnabled)
 		} else {
-			r.EventCenter.AddWarningEvent("{user} disabled monitoring {daemon}", dbUser, dbDaemon, dbDaemon.App, dbDaemon.App.Machine)
+			r.EventCenter.AddWarningEvent("{user} disabled monitoring {daemon}", dbUser, dbDaemon, dbDaemon.App, dbDaemon.App.Machine, dbmodel.EventCodeDaemonMonitoringDisabled)
 		}
 	}
 
@@ -1404,7 +1488,8 @@ func (r *RestAPI) RenameApp(ctx context.Context, params services.RenameAppParams
 	machine := &dbmodel.Machine{
 		ID: oldApp.MachineID,
 	}
-	r.EventCenter.AddInfoEvent(fmt.Sprintf("{app} renamed from %s", oldApp.Name), newApp, machine)
+	r.EventCenter.AddInfoEvent(fmt.Sprintf("{app} renamed from %s", oldApp.Name), newApp, machine,
+		dbmodel.EventCodeAppRenamed, dbmodel.EventPayload{"oldName": oldApp.Name})
 
 	log.Infof("app %s successfully renamed to %s", oldApp.Name, newApp.Name)
 
@@ -1191,6 +1191,17 @@ func TestRestGetAppServicesStatus(t *testing.T) {
 				SecondaryUnackedClientsLeft: 9,
 				PrimaryAnalyzedPackets:      10,
 				SecondaryAnalyzedPackets:    11,
+				ConfigDiffs: []dbmodel.HAConfigDiff{
+					{
+						DaemonID:   keaApp.Daemons[0].ID,
+						Element:    "lifetime",
+						Difference: dbmodel.HAConfigDiffDifferent,
+						Path:       "/Dhcp4/valid-lifetime",
+						Primary:    3600,
+						Peer:       7200,
+					},
+				},
+				ConfigCheckedAt: exampleTime,
 			},
 		},
 		{
@@ -1284,6 +1295,14 @@ func TestRestGetAppServicesStatus(t *testing.T) {
 	require.EqualValues(t, 9, haStatus.SecondaryServer.UnackedClientsLeft)
 	require.EqualValues(t, 11, haStatus.SecondaryServer.AnalyzedPackets)
 
+	require.NotEmpty(t, haStatus.ConfigCheckedAt.String())
+	require.Len(t, haStatus.ConfigDiffs, 1)
+	require.Equal(t, "lifetime", haStatus.ConfigDiffs[0].Element)
+	require.Equal(t, dbmodel.HAConfigDiffDifferent, haStatus.ConfigDiffs[0].Difference)
+	require.Equal(t, "/Dhcp4/valid-lifetime", haStatus.ConfigDiffs[0].Path)
+	require.EqualValues(t, 3600, haStatus.ConfigDiffs[0].Primary)
+	require.EqualValues(t, 7200, haStatus.ConfigDiffs[0].Peer)
+
 	// Validate the status of the DHCPv6 pair.
 	status = statusList[1].Status.KeaStatus
 	require.NotNil(t, status.HaServers)
@@ -1316,6 +1335,7 @@ func TestRestGetAppServicesStatus(t *testing.T) {
 	require.Zero(t, haStatus.SecondaryServer.UnackedClients)
 	require.Zero(t, haStatus.SecondaryServer.UnackedClientsLeft)
 	require.Zero(t, haStatus.SecondaryServer.AnalyzedPackets)
+	require.Empty(t, haStatus.ConfigDiffs)
 }
 
 // Test that status of a HA service providing passive-backup mode is
@@ -0,0 +1,370 @@
+package restservice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/go-openapi/runtime/middleware"
+	"github.com/go-openapi/strfmt"
+	log "github.com/sirupsen/logrus"
+
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/eventcenter"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/settings"
+	"isc.org/stork/server/notifications"
+	storkutil "isc.org/stork/util"
+)
+
+// Convert the notification channel to the format used in REST API. The
+// webhook secret and SMTP password are not returned.
+func notificationChannelToRestAPI(dbChannel *dbmodel.NotificationChannel) *models.NotificationChannel {
+	channel := &models.NotificationChannel{
+		ID:             dbChannel.ID,
+		CreatedAt:      strfmt.DateTime(dbChannel.CreatedAt),
+		Name:           dbChannel.Name,
+		Type:           dbChannel.Type,
+		Enabled:        dbChannel.Enabled,
+		Level:          int64(dbChannel.Level),
+		DeliveredCount: dbChannel.DeliveredCount,
+		FailedCount:    dbChannel.FailedCount,
+		LastError:      dbChannel.LastError,
+	}
+	if !dbChannel.LastDeliveredAt.IsZero() {
+		channel.LastDeliveredAt = strfmt.DateTime(dbChannel.LastDeliveredAt)
+	}
+	if !dbChannel.LastFailedAt.IsZero() {
+		channel.LastFailedAt = strfmt.DateTime(dbChannel.LastFailedAt)
+	}
+	if dbChannel.Filter != nil {
+		channel.Filter = &models.NotificationFilter{
+			MachineID: dbChannel.Filter.MachineID,
+			AppID:     dbChannel.Filter.AppID,
+			SubnetID:  dbChannel.Filter.SubnetID,
+			DaemonID:  dbChannel.Filter.DaemonID,
+			UserID:    dbChannel.Filter.UserID,
+			RuleID:    dbChannel.Filter.RuleID,
+		}
+	}
+	if dbChannel.Webhook != nil {
+		channel.Webhook = &models.WebhookConfig{
+			URL:     dbChannel.Webhook.URL,
+			Retries: int64(dbChannel.Webhook.Retries),
+		}
+	}
+	if dbChannel.Email != nil {
+		channel.Email = &models.EmailConfig{
+			Host:     dbChannel.Email.Host,
+			Port:     int64(dbChannel.Email.Port),
+			Username: dbChannel.Email.Username,
+			From:     dbChannel.Email.From,
+			To:       dbChannel.Email.To,
+		}
+	}
+	if dbChannel.Syslog != nil {
+		channel.Syslog = &models.SyslogConfig{
+			Network:  dbChannel.Syslog.Network,
+			Address:  dbChannel.Syslog.Address,
+			Facility: int64(dbChannel.Syslog.Facility),
+		}
+	}
+	return channel
+}
+
+// Convert the notification channel received over REST API to the database
+// model. Only the configuration matching the channel type is taken. It
+// returns an error message if the channel is invalid.
+func notificationChannelFromRestAPI(channel *models.NotificationChannel) (*dbmodel.NotificationChannel, string) {
+	if channel == nil {
+		return nil, "missing notification channel"
+	}
+	if len(channel.Name) == 0 {
+		return nil, "notification channel name must not be empty"
+	}
+	if channel.Level < dbmodel.EvInfo || channel.Level > dbmodel.EvError {
+		return nil, fmt.Sprintf("unsupported notification channel level %d", channel.Level)
+	}
+	dbChannel := &dbmodel.NotificationChannel{
+		Name:    channel.Name,
+		Type:    channel.Type,
+		Enabled: channel.Enabled,
+		Level:   int(channel.Level),
+	}
+	if channel.Filter != nil {
+		dbChannel.Filter = &dbmodel.Relations{
+			MachineID: channel.Filter.MachineID,
+			AppID:     channel.Filter.AppID,
+			SubnetID:  channel.Filter.SubnetID,
+			DaemonID:  channel.Filter.DaemonID,
+			UserID:    channel.Filter.UserID,
+			RuleID:    channel.Filter.RuleID,
+		}
+	}
+	switch channel.Type {
+	case dbmodel.NotificationChannelWebhook:
+		if channel.Webhook == nil || len(channel.Webhook.URL) == 0 {
+			return nil, "webhook URL must not be empty"
+		}
+		if channel.Webhook.Retries < 0 {
+			return nil, "webhook retries must not be negative"
+		}
+		dbChannel.Webhook = &dbmodel.WebhookConfig{
+			URL:     channel.Webhook.URL,
+			Secret:  channel.Webhook.Secret,
+			Retries: int(channel.Webhook.Retries),
+		}
+	case dbmodel.NotificationChannelEmail:
+		if channel.Email == nil || len(channel.Email.Host) == 0 {
+			return nil, "SMTP server host must not be empty"
+		}
+		if len(channel.Email.From) == 0 || len(channel.Email.To) == 0 {
+			return nil, "email sender and recipients must not be empty"
+		}
+		dbChannel.Email = &dbmodel.EmailConfig{
+			Host:     channel.Email.Host,
+			Port:     int(channel.Email.Port),
+			Username: channel.Email.Username,
+			Password: string(channel.Email.Password),
+			From:     channel.Email.From,
+			To:       channel.Email.To,
+		}
+	case dbmodel.NotificationChannelSyslog:
+		if channel.Syslog == nil || len(channel.Syslog.Address) == 0 {
+			return nil, "syslog server address must not be empty"
+		}
+		network := channel.Syslog.Network
+		if len(network) == 0 {
+			network = "udp"
+		}
+		if network != "udp" && network != "tcp" {
+			return nil, fmt.Sprintf("unsupported syslog network %s", channel.Syslog.Network)
+		}
+		if channel.Syslog.Facility < 0 || channel.Syslog.Facility > 23 {
+			return nil, fmt.Sprintf("unsupported syslog facility %d", channel.Syslog.Facility)
+		}
+		dbChannel.Syslog = &dbmodel.SyslogConfig{
+			Network:  network,
+			Address:  channel.Syslog.Address,
+			Facility: int(channel.Syslog.Facility),
+		}
+	default:
+		return nil, fmt.Sprintf("unsupported notification channel type %s", channel.Type)
+	}
+	return dbChannel, ""
+}
+
+// Get the list of notification channels.
+func (r *RestAPI) GetNotificationChannels(ctx context.Context, params settings.GetNotificationChannelsParams) middleware.Responder {
+	dbChannels, err := dbmodel.GetNotificationChannels(r.DB, false)
+	if err != nil {
+		msg := "cannot get notification channels from db"
+		log.Error(err)
+		rsp := settings.NewGetNotificationChannelsDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	channels := &models.NotificationChannels{
+		Items: []*models.NotificationChannel{},
+		Total: int64(len(dbChannels)),
+	}
+	for _, dbChannel := range dbChannels {
+		channels.Items = append(channels.Items, notificationChannelToRestAPI(dbChannel))
+	}
+	rsp := settings.NewGetNotificationChannelsOK().WithPayload(channels)
+	return rsp
+}
+
+// Get the notification channel by ID.
+func (r *RestAPI) GetNotificationChannel(ctx context.Context, params settings.GetNotificationChannelParams) middleware.Responder {
+	dbChannel, err := dbmodel.GetNotificationChannel(r.DB, params.ID)
+	if err != nil {
+		msg := fmt.Sprintf("cannot get notification channel with id %d from db", params.ID)
+		log.Error(err)
+		rsp := settings.NewGetNotificationChannelDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if dbChannel == nil {
+		msg := fmt.Sprintf("cannot find notification channel with id %d", params.ID)
+		rsp := settings.NewGetNotificationChannelDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	rsp := settings.NewGetNotificationChannelOK().WithPayload(notificationChannelToRestAPI(dbChannel))
+	return rsp
+}
+
+// Create new notification channel.
+func (r *RestAPI) CreateNotificationChannel(ctx context.Context, params settings.CreateNotificationChannelParams) middleware.Responder {
+	// only super-admin can manage the notification channels
+	_, dbUser := r.SessionManager.Logged(ctx)
+	if !dbUser.InGroup(&dbmodel.SystemGroup{ID: dbmodel.SuperAdminGroupID}) {
+		msg := "user is forbidden to create notification channels"
+		rsp := settings.NewCreateNotificationChannelDefault(http.StatusForbidden).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	dbChannel, msg := notificationChannelFromRestAPI(params.Channel)
+	if dbChannel == nil {
+		log.Warn(msg)
+		rsp := settings.NewCreateNotificationChannelDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	err := dbmodel.AddNotificationChannel(r.DB, dbChannel)
+	if err != nil {
+		msg := "cannot store notification channel in db"
+		log.Error(err)
+		rsp := settings.NewCreateNotificationChannelDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	rsp := settings.NewCreateNotificationChannelOK().WithPayload(notificationChannelToRestAPI(dbChannel))
+	return rsp
+}
+
+// Update the notification channel. The webhook secret and SMTP password
+// are preserved when they are not specified.
+func (r *RestAPI) UpdateNotificationChannel(ctx context.Context, params settings.UpdateNotificationChannelParams) middleware.Responder {
+	// only super-admin can manage the notification channels
+	_, dbUser := r.SessionManager.Logged(ctx)
+	if !dbUser.InGroup(&dbmodel.SystemGroup{ID: dbmodel.SuperAdminGroupID}) {
+		msg := "user is forbidden to update notification channels"
+		rsp := settings.NewUpdateNotificationChannelDefault(http.StatusForbidden).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	dbChannel, msg := notificationChannelFromRestAPI(params.Channel)
+	if dbChannel == nil {
+		log.Warn(msg)
+		rsp := settings.NewUpdateNotificationChannelDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	existing, err := dbmodel.GetNotificationChannel(r.DB, params.ID)
+	if err != nil {
+		msg := fmt.Sprintf("cannot get notification channel with id %d from db", params.ID)
+		log.Error(err)
+		rsp := settings.NewUpdateNotificationChannelDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if existing == nil {
+		msg := fmt.Sprintf("cannot find notification channel with id %d", params.ID)
+		rsp := settings.NewUpdateNotificationChannelDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if dbChannel.Webhook != nil && len(dbChannel.Webhook.Secret) == 0 && existing.Webhook != nil {
+		dbChannel.Webhook.Secret = existing.Webhook.Secret
+	}
+	if dbChannel.Email != nil && len(dbChannel.Email.Password) == 0 && existing.Email != nil {
+		dbChannel.Email.Password = existing.Email.Password
+	}
+	dbChannel.ID = existing.ID
+	err = dbmodel.UpdateNotificationChannel(r.DB, dbChannel)
+	if err != nil {
+		msg := fmt.Sprintf("cannot update notification channel with id %d", params.ID)
+		log.Error(err)
+		rsp := settings.NewUpdateNotificationChannelDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	dbChannel.CreatedAt = existing.CreatedAt
+	dbChannel.DeliveredCount = existing.DeliveredCount
+	dbChannel.FailedCount = existing.FailedCount
+	dbChannel.LastDeliveredAt = existing.LastDeliveredAt
+	dbChannel.LastFailedAt = existing.LastFailedAt
+	dbChannel.LastError = existing.LastError
+	rsp := settings.NewUpdateNotificationChannelOK().WithPayload(notificationChannelToRestAPI(dbChannel))
+	return rsp
+}
+
+// Delete the notification channel.
+func (r *RestAPI) DeleteNotificationChannel(ctx context.Context, params settings.DeleteNotificationChannelParams) middleware.Responder {
+	// only super-admin can manage the notification channels
+	_, dbUser := r.SessionManager.Logged(ctx)
+	if !dbUser.InGroup(&dbmodel.SystemGroup{ID: dbmodel.SuperAdminGroupID}) {
+		msg := "user is forbidden to delete notification channels"
+		rsp := settings.NewDeleteNotificationChannelDefault(http.StatusForbidden).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	err := dbmodel.DeleteNotificationChannel(r.DB, params.ID)
+	if err != nil {
+		msg := fmt.Sprintf("cannot delete notification channel with id %d", params.ID)
+		log.Error(err)
+		rsp := settings.NewDeleteNotificationChannelDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	rsp := settings.NewDeleteNotificationChannelOK()
+	return rsp
+}
+
+// Send a test event through the notification channel and record the
+// result of the delivery.
+func (r *RestAPI) TestNotificationChannel(ctx context.Context, params settings.TestNotificationChannelParams) middleware.Responder {
+	// only super-admin can manage the notification channels
+	_, dbUser := r.SessionManager.Logged(ctx)
+	if !dbUser.InGroup(&dbmodel.SystemGroup{ID: dbmodel.SuperAdminGroupID}) {
+		msg := "user is forbidden to test notification channels"
+		rsp := settings.NewTestNotificationChannelDefault(http.StatusForbidden).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	dbChannel, err := dbmodel.GetNotificationChannel(r.DB, params.ID)
+	if err != nil {
+		msg := fmt.Sprintf("cannot get notification channel with id %d from db", params.ID)
+		log.Error(err)
+		rsp := settings.NewTestNotificationChannelDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if dbChannel == nil {
+		msg := fmt.Sprintf("cannot find notification channel with id %d", params.ID)
+		rsp := settings.NewTestNotificationChannelDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	event := eventcenter.CreateEvent(dbmodel.EvInfo, fmt.Sprintf("test event sent through notification channel %s", dbChannel.Name),
+		dbmodel.EventCodeNotificationTest, dbmodel.EventPayload{"channelId": dbChannel.ID, "channelName": dbChannel.Name})
+	event.CreatedAt = storkutil.UTCNow()
+	err = notifications.Send(dbChannel, event)
+	if recordErr := dbmodel.RecordNotificationDelivery(r.DB, dbChannel.ID, event.CreatedAt, err); recordErr != nil {
+		log.Error(recordErr)
+	}
+	if err != nil {
+		msg := fmt.Sprintf("cannot send test event through notification channel %s: %s", dbChannel.Name, err)
+		log.Warn(msg)
+		rsp := settings.NewTestNotificationChannelDefault(http.StatusBadGateway).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	rsp := settings.NewTestNotificationChannelOK()
+	return rsp
+}
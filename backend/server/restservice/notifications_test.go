@@ -0,0 +1,195 @@
+package restservice
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	agentcommtest "isc.org/stork/server/agentcomm/test"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/settings"
+	storktest "isc.org/stork/server/test"
+)
+
+// Check creating, getting, updating, testing and deleting notification
+// channels via rest api functions.
+func TestNotificationChannels(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	s := RestAPISettings{}
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	fec := &storktest.FakeEventCenter{}
+	rapi, err := NewRestAPI(&s, dbSettings, db, fa, fec, nil)
+	require.NoError(t, err)
+	ctx, err := rapi.SessionManager.Load(context.Background(), "")
+	require.NoError(t, err)
+	admin, err := dbmodel.GetUserByID(rapi.DB, 1)
+	require.NoError(t, err)
+	err = rapi.SessionManager.LoginHandler(ctx, admin)
+	require.NoError(t, err)
+
+	var signature string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get("X-Stork-Signature")
+	}))
+	defer ts.Close()
+
+	// Create the channel.
+	channel := &models.NotificationChannel{
+		Name:    "hook",
+		Type:    dbmodel.NotificationChannelWebhook,
+		Enabled: true,
+		Level:   dbmodel.EvWarning,
+		Filter: &models.NotificationFilter{
+			SubnetID: 3,
+		},
+		Webhook: &models.WebhookConfig{
+			URL:    ts.URL,
+			Secret: "secret",
+		},
+		// The configuration not matching the type is ignored.
+		Syslog: &models.SyslogConfig{
+			Address: "192.0.2.1:514",
+		},
+	}
+	rsp := rapi.CreateNotificationChannel(ctx, settings.CreateNotificationChannelParams{Channel: channel})
+	require.IsType(t, &settings.CreateNotificationChannelOK{}, rsp)
+	createRsp := rsp.(*settings.CreateNotificationChannelOK)
+	id := createRsp.Payload.ID
+	require.NotZero(t, id)
+	require.Equal(t, ts.URL, createRsp.Payload.Webhook.URL)
+	require.Empty(t, createRsp.Payload.Webhook.Secret)
+	require.Nil(t, createRsp.Payload.Syslog)
+
+	// Get the channels.
+	rsp = rapi.GetNotificationChannels(ctx, settings.GetNotificationChannelsParams{})
+	require.IsType(t, &settings.GetNotificationChannelsOK{}, rsp)
+	channelsRsp := rsp.(*settings.GetNotificationChannelsOK)
+	require.EqualValues(t, 1, channelsRsp.Payload.Total)
+	require.Len(t, channelsRsp.Payload.Items, 1)
+	require.EqualValues(t, 3, channelsRsp.Payload.Items[0].Filter.SubnetID)
+
+	// Update the channel without the secret preserves the secret.
+	channel.Webhook.Secret = ""
+	channel.Level = dbmodel.EvError
+	rsp = rapi.UpdateNotificationChannel(ctx, settings.UpdateNotificationChannelParams{ID: id, Channel: channel})
+	require.IsType(t, &settings.UpdateNotificationChannelOK{}, rsp)
+	dbChannel, err := dbmodel.GetNotificationChannel(db, id)
+	require.NoError(t, err)
+	require.Equal(t, dbmodel.EvError, dbChannel.Level)
+	require.Equal(t, "secret", dbChannel.Webhook.Secret)
+
+	// Send the test event.
+	rsp = rapi.TestNotificationChannel(ctx, settings.TestNotificationChannelParams{ID: id})
+	require.IsType(t, &settings.TestNotificationChannelOK{}, rsp)
+	require.NotEmpty(t, signature)
+
+	rsp = rapi.GetNotificationChannel(ctx, settings.GetNotificationChannelParams{ID: id})
+	require.IsType(t, &settings.GetNotificationChannelOK{}, rsp)
+	channelRsp := rsp.(*settings.GetNotificationChannelOK)
+	require.EqualValues(t, 1, channelRsp.Payload.DeliveredCount)
+	require.Zero(t, channelRsp.Payload.FailedCount)
+
+	// Invalid channels.
+	invalidChannels := []*models.NotificationChannel{
+		nil,
+		{Type: dbmodel.NotificationChannelWebhook, Webhook: &models.WebhookConfig{URL: ts.URL}},
+		{Name: "foo", Type: "pager"},
+		{Name: "foo", Type: dbmodel.NotificationChannelWebhook},
+		{Name: "foo", Type: dbmodel.NotificationChannelWebhook, Webhook: &models.WebhookConfig{URL: ts.URL, Retries: -1}},
+		{Name: "foo", Type: dbmodel.NotificationChannelEmail, Email: &models.EmailConfig{Host: "localhost"}},
+		{Name: "foo", Type: dbmodel.NotificationChannelSyslog, Syslog: &models.SyslogConfig{Address: "localhost:514", Network: "unix"}},
+		{Name: "foo", Type: dbmodel.NotificationChannelSyslog, Syslog: &models.SyslogConfig{Address: "localhost:514", Facility: 24}},
+		{Name: "foo", Type: dbmodel.NotificationChannelSyslog, Syslog: &models.SyslogConfig{Address: "localhost:514"}, Level: 3},
+	}
+	for _, invalid := range invalidChannels {
+		rsp = rapi.CreateNotificationChannel(ctx, settings.CreateNotificationChannelParams{Channel: invalid})
+		require.IsType(t, &settings.CreateNotificationChannelDefault{}, rsp)
+		defaultRsp := rsp.(*settings.CreateNotificationChannelDefault)
+		require.Equal(t, http.StatusBadRequest, getStatusCode(*defaultRsp))
+	}
+
+	// Not existing channel.
+	rsp = rapi.UpdateNotificationChannel(ctx, settings.UpdateNotificationChannelParams{ID: id + 1, Channel: channel})
+	require.IsType(t, &settings.UpdateNotificationChannelDefault{}, rsp)
+	updateDefaultRsp := rsp.(*settings.UpdateNotificationChannelDefault)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*updateDefaultRsp))
+
+	rsp = rapi.TestNotificationChannel(ctx, settings.TestNotificationChannelParams{ID: id + 1})
+	require.IsType(t, &settings.TestNotificationChannelDefault{}, rsp)
+	testDefaultRsp := rsp.(*settings.TestNotificationChannelDefault)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*testDefaultRsp))
+
+	// Delete the channel.
+	rsp = rapi.DeleteNotificationChannel(ctx, settings.DeleteNotificationChannelParams{ID: id})
+	require.IsType(t, &settings.DeleteNotificationChannelOK{}, rsp)
+	rsp = rapi.GetNotificationChannel(ctx, settings.GetNotificationChannelParams{ID: id})
+	require.IsType(t, &settings.GetNotificationChannelDefault{}, rsp)
+	getDefaultRsp := rsp.(*settings.GetNotificationChannelDefault)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*getDefaultRsp))
+}
+
+// Check that only super-admin can manage the notification channels.
+func TestNotificationChannelsForbidden(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	s := RestAPISettings{}
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	fec := &storktest.FakeEventCenter{}
+	rapi, err := NewRestAPI(&s, dbSettings, db, fa, fec, nil)
+	require.NoError(t, err)
+
+	dbChannel := &dbmodel.NotificationChannel{
+		Name:    "hook",
+		Type:    dbmodel.NotificationChannelWebhook,
+		Webhook: &dbmodel.WebhookConfig{URL: "http://localhost"},
+	}
+	err = dbmodel.AddNotificationChannel(db, dbChannel)
+	require.NoError(t, err)
+
+	user := &dbmodel.SystemUser{
+		Login:    "joe",
+		Lastname: "Doe",
+		Name:     "Joe",
+		Password: "pass",
+	}
+	_, err = dbmodel.CreateUser(db, user)
+	require.NoError(t, err)
+	ctx, err := rapi.SessionManager.Load(context.Background(), "")
+	require.NoError(t, err)
+	err = rapi.SessionManager.LoginHandler(ctx, user)
+	require.NoError(t, err)
+
+	channel := &models.NotificationChannel{
+		Name:    "other",
+		Type:    dbmodel.NotificationChannelWebhook,
+		Webhook: &models.WebhookConfig{URL: "http://localhost"},
+	}
+	rsp := rapi.CreateNotificationChannel(ctx, settings.CreateNotificationChannelParams{Channel: channel})
+	require.IsType(t, &settings.CreateNotificationChannelDefault{}, rsp)
+	require.Equal(t, http.StatusForbidden, getStatusCode(*rsp.(*settings.CreateNotificationChannelDefault)))
+
+	rsp = rapi.UpdateNotificationChannel(ctx, settings.UpdateNotificationChannelParams{ID: dbChannel.ID, Channel: channel})
+	require.IsType(t, &settings.UpdateNotificationChannelDefault{}, rsp)
+	require.Equal(t, http.StatusForbidden, getStatusCode(*rsp.(*settings.UpdateNotificationChannelDefault)))
+
+	rsp = rapi.TestNotificationChannel(ctx, settings.TestNotificationChannelParams{ID: dbChannel.ID})
+	require.IsType(t, &settings.TestNotificationChannelDefault{}, rsp)
+	require.Equal(t, http.StatusForbidden, getStatusCode(*rsp.(*settings.TestNotificationChannelDefault)))
+
+	rsp = rapi.DeleteNotificationChannel(ctx, settings.DeleteNotificationChannelParams{ID: dbChannel.ID})
+	require.IsType(t, &settings.DeleteNotificationChannelDefault{}, rsp)
+	require.Equal(t, http.StatusForbidden, getStatusCode(*rsp.(*settings.DeleteNotificationChannelDefault)))
+
+	// The channel is left intact.
+	returned, err := dbmodel.GetNotificationChannels(db, false)
+	require.NoError(t, err)
+	require.Len(t, returned, 1)
+	require.Equal(t, "hook", returned[0].Name)
+}
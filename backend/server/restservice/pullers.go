@@ -0,0 +1,109 @@
+package restservice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/go-openapi/runtime/middleware"
+	"github.com/go-openapi/strfmt"
+	log "github.com/sirupsen/logrus"
+
+	"isc.org/stork/server/agentcomm"
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/settings"
+)
+
+// Convert the puller status to the format used in REST API.
+func pullerStatusToRestAPI(status *agentcomm.PullerStatus) *models.PullerStatus {
+	s := &models.PullerStatus{
+		ID:            status.ID,
+		Name:          status.Name,
+		Interval:      status.Interval,
+		Active:        status.Active,
+		Running:       status.Running,
+		LastStartedAt: strfmt.DateTime(status.LastStartedAt),
+		LastDuration:  status.LastDuration.Milliseconds(),
+		LastSucceeded: int64(status.LastSucceeded),
+		LastFailed:    int64(status.LastFailed),
+		LastError:     status.LastError,
+		NextRunAt:     strfmt.DateTime(status.NextRunAt),
+		Targets:       []*models.PullerTargetStatus{},
+	}
+	for _, target := range status.Targets {
+		s.Targets = append(s.Targets, &models.PullerTargetStatus{
+			Key:          target.Key,
+			LastPulledAt: strfmt.DateTime(target.LastPulledAt),
+			LastError:    target.LastError,
+		})
+	}
+	return s
+}
+
+// Get the status of the pullers.
+func (r *RestAPI) GetPullers(ctx context.Context, params settings.GetPullersParams) middleware.Responder {
+	pullers := &models.Pullers{
+		Items: []*models.PullerStatus{},
+	}
+	if r.Pullers != nil {
+		for _, puller := range r.Pullers.GetAll() {
+			pullers.Items = append(pullers.Items, pullerStatusToRestAPI(puller.GetStatus()))
+		}
+	}
+	pullers.Total = int64(len(pullers.Items))
+
+	rsp := settings.NewGetPullersOK().WithPayload(pullers)
+	return rsp
+}
+
+// Schedule the immediate run of the puller action for all apps or for the
+// selected app.
+func (r *RestAPI) TriggerPuller(ctx context.Context, params settings.TriggerPullerParams) middleware.Responder {
+	var puller *agentcomm.PeriodicPuller
+	if r.Pullers != nil {
+		puller = r.Pullers.GetByID(params.ID)
+	}
+	if puller == nil {
+		msg := fmt.Sprintf("cannot find puller with id %s", params.ID)
+		log.Warn(msg)
+		rsp := settings.NewTriggerPullerDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	appID := int64(0)
+	if params.AppID != nil {
+		dbApp, err := dbmodel.GetAppByID(r.DB, *params.AppID)
+		if err != nil {
+			msg := fmt.Sprintf("cannot get app with id %d from db", *params.AppID)
+			log.Error(err)
+			rsp := settings.NewTriggerPullerDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+				Message: &msg,
+			})
+			return rsp
+		}
+		if dbApp == nil {
+			msg := fmt.Sprintf("cannot find app with id %d", *params.AppID)
+			rsp := settings.NewTriggerPullerDefault(http.StatusNotFound).WithPayload(&models.APIError{
+				Message: &msg,
+			})
+			return rsp
+		}
+		appID = dbApp.ID
+	}
+
+	err := puller.Trigger(appID)
+	if err != nil {
+		msg := fmt.Sprintf("cannot trigger puller %s", params.ID)
+		log.Error(err)
+		rsp := settings.NewTriggerPullerDefault(http.StatusServiceUnavailable).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	rsp := settings.NewTriggerPullerOK()
+	return rsp
+}
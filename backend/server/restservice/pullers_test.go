@@ -0,0 +1,99 @@
+package restservice
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	agentcommtest "isc.org/stork/server/agentcomm/test"
+	"isc.org/stork/server/apps"
+	"isc.org/stork/server/apps/kea"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	"isc.org/stork/server/gen/restapi/operations/settings"
+	storktest "isc.org/stork/server/test"
+)
+
+// Check that the status of the pullers is returned and the puller can
+// be triggered via rest api functions.
+func TestGetAndTriggerPullers(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	err := dbmodel.InitializeSettings(db)
+	require.NoError(t, err)
+
+	m := &dbmodel.Machine{
+		Address:   "localhost",
+		AgentPort: 8080,
+	}
+	err = dbmodel.AddMachine(db, m)
+	require.NoError(t, err)
+
+	a := &dbmodel.App{
+		MachineID: m.ID,
+		Type:      dbmodel.AppTypeKea,
+	}
+	_, err = dbmodel.AddApp(db, a)
+	require.NoError(t, err)
+
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	fec := &storktest.FakeEventCenter{}
+	hostsPuller, err := kea.NewHostsPuller(db, fa)
+	require.NoError(t, err)
+	defer hostsPuller.Shutdown()
+	pullers := &apps.Pullers{
+		KeaHostsPuller: hostsPuller,
+	}
+
+	rSettings := RestAPISettings{}
+	rapi, err := NewRestAPI(&rSettings, dbSettings, db, fa, fec, pullers)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	rsp := rapi.GetPullers(ctx, settings.GetPullersParams{})
+	require.IsType(t, &settings.GetPullersOK{}, rsp)
+	okRsp := rsp.(*settings.GetPullersOK)
+	require.EqualValues(t, 1, okRsp.Payload.Total)
+	require.Len(t, okRsp.Payload.Items, 1)
+	status := okRsp.Payload.Items[0]
+	require.Equal(t, "kea_hosts", status.ID)
+	require.Equal(t, "Kea Hosts", status.Name)
+	require.True(t, status.Active)
+	require.False(t, status.Running)
+	require.Empty(t, status.Targets)
+
+	// Trigger the puller for the app.
+	appID := a.ID
+	rsp = rapi.TriggerPuller(ctx, settings.TriggerPullerParams{
+		ID:    "kea_hosts",
+		AppID: &appID,
+	})
+	require.IsType(t, &settings.TriggerPullerOK{}, rsp)
+
+	require.Eventually(t, func() bool {
+		rsp := rapi.GetPullers(ctx, settings.GetPullersParams{})
+		status := rsp.(*settings.GetPullersOK).Payload.Items[0]
+		return !status.Running && len(status.Targets) == 1
+	}, 5*time.Second, 100*time.Millisecond)
+
+	// Unknown puller.
+	rsp = rapi.TriggerPuller(ctx, settings.TriggerPullerParams{
+		ID: "foo",
+	})
+	require.IsType(t, &settings.TriggerPullerDefault{}, rsp)
+	defaultRsp := rsp.(*settings.TriggerPullerDefault)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*defaultRsp))
+
+	// Unknown app.
+	appID = a.ID + 100
+	rsp = rapi.TriggerPuller(ctx, settings.TriggerPullerParams{
+		ID:    "kea_hosts",
+		AppID: &appID,
+	})
+	require.IsType(t, &settings.TriggerPullerDefault{}, rsp)
+	defaultRsp = rsp.(*settings.TriggerPullerDefault)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*defaultRsp))
+}
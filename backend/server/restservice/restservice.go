@@ -20,6 +20,7 @@ import (
 	"golang.org/x/net/netutil"
 
 	"isc.org/stork/server/agentcomm"
+	"isc.org/stork/server/alerts"
 	"isc.org/stork/server/apps"
 	dbops "isc.org/stork/server/database"
 	dbsession "isc.org/stork/server/database/session"
@@ -55,9 +56,20 @@ type RestAPI struct {
 	SessionManager *dbsession.SessionMgr
 	EventCenter    eventcenter.EventCenter
 	Pullers        *apps.Pullers
+	// Resolves the alerts of the modified and deleted alert rules. It
+	// should be the engine evaluating the rules.
+	AlertsEngine *alerts.Engine
 
 	Agents agentcomm.ConnectedAgents
 
+	leaseWipeTokens leaseWipeTokens
+
+	// Context canceled when the server shuts down. The background tasks
+	// started by the handlers, e.g. watching the pushed configurations,
+	// run on this context rather than on the request's context.
+	ctx    context.Context
+	cancel context.CancelFunc
+
 	TLS          bool
 	HTTPServer   *http.Server
 	srvListener  net.Listener
@@ -76,6 +88,7 @@ func NewRestAPI(settings *RestAPISettings, dbSettings *dbops.DatabaseSettings, d
 		return nil, pkgerrors.Wrap(err, "unable to establish connection to the session database")
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
 	r := &RestAPI{
 		Settings:       settings,
 		DBSettings:     dbSettings,
@@ -84,6 +97,9 @@ func NewRestAPI(settings *RestAPISettings, dbSettings *dbops.DatabaseSettings, d
 		Agents:         agents,
 		EventCenter:    eventCenter,
 		Pullers:        pullers,
+		AlertsEngine:   alerts.NewEngine(db, eventCenter),
+		ctx:            ctx,
+		cancel:         cancel,
 	}
 
 	return r, nil
@@ -311,5 +327,6 @@ func (r *RestAPI) Shutdown() {
 			log.Warnf("Could not gracefully shutdown the server: %v\n", err)
 		}
 	}
+	r.cancel()
 	log.Printf("Stopped ReST API Service")
 }
@@ -25,13 +25,31 @@ func (r *RestAPI) GetSettings(ctx context.Context, params settings.GetSettingsPa
 	}
 
 	s := &models.Settings{
-		Bind9StatsPullerInterval: dbSettingsMap["bind9_stats_puller_interval"].(int64),
-		GrafanaURL:               dbSettingsMap["grafana_url"].(string),
-		KeaHostsPullerInterval:   dbSettingsMap["kea_hosts_puller_interval"].(int64),
-		KeaStatsPullerInterval:   dbSettingsMap["kea_stats_puller_interval"].(int64),
-		KeaStatusPullerInterval:  dbSettingsMap["kea_status_puller_interval"].(int64),
-		AppsStatePullerInterval:  dbSettingsMap["apps_state_puller_interval"].(int64),
-		PrometheusURL:            dbSettingsMap["prometheus_url"].(string),
+		Bind9StatsPullerInterval:           dbSettingsMap["bind9_stats_puller_interval"].(int64),
+		GrafanaURL:                         dbSettingsMap["grafana_url"].(string),
+		KeaHostsPullerInterval:             dbSettingsMap["kea_hosts_puller_interval"].(int64),
+		KeaStatsPullerInterval:             dbSettingsMap["kea_stats_puller_interval"].(int64),
+		KeaStatusPullerInterval:            dbSettingsMap["kea_status_puller_interval"].(int64),
+		AppsStatePullerInterval:            dbSettingsMap["apps_state_puller_interval"].(int64),
+		PrometheusURL:                      dbSettingsMap["prometheus_url"].(string),
+		UtilizationRetentionPullerInterval: dbSettingsMap["utilization_retention_puller_interval"].(int64),
+		ExhaustionForecastPullerInterval:   dbSettingsMap["exhaustion_forecast_puller_interval"].(int64),
+		ExhaustionForecastWindow:           dbSettingsMap["exhaustion_forecast_window"].(int64),
+		ExhaustionForecastThreshold:        dbSettingsMap["exhaustion_forecast_threshold"].(int64),
+		DiskUsageWarningThreshold:          dbSettingsMap["disk_usage_warning_threshold"].(int64),
+		DiskUsageErrorThreshold:            dbSettingsMap["disk_usage_error_threshold"].(int64),
+		ClockSkewWarningThreshold:          dbSettingsMap["clock_skew_warning_threshold"].(int64),
+		ClockSkewErrorThreshold:            dbSettingsMap["clock_skew_error_threshold"].(int64),
+		KeaLogErrorsPullerInterval:         dbSettingsMap["kea_log_errors_puller_interval"].(int64),
+		KeaLogErrorsThreshold:              dbSettingsMap["kea_log_errors_threshold"].(int64),
+		EventDedupWindow:                   dbSettingsMap["event_dedup_window"].(int64),
+		EventFlapThreshold:                 dbSettingsMap["event_flap_threshold"].(int64),
+		EventFlapWindow:                    dbSettingsMap["event_flap_window"].(int64),
+		EventRetentionPullerInterval:       dbSettingsMap["event_retention_puller_interval"].(int64),
+		EventRetentionInfo:                 dbSettingsMap["event_retention_info"].(int64),
+		EventRetentionWarning:              dbSettingsMap["event_retention_warning"].(int64),
+		EventRetentionError:                dbSettingsMap["event_retention_error"].(int64),
+		ConfigPushGracePeriod:              dbSettingsMap["config_push_grace_period"].(int64),
 	}
 	rsp := settings.NewGetSettingsOK().WithPayload(s)
 
@@ -90,6 +108,96 @@ func (r *RestAPI) UpdateSettings(ctx context.Context, params settings.UpdateSett
 		log.Error(err)
 		return errRsp
 	}
+	err = dbmodel.SetSettingInt(r.DB, "utilization_retention_puller_interval", s.UtilizationRetentionPullerInterval)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "exhaustion_forecast_puller_interval", s.ExhaustionForecastPullerInterval)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "exhaustion_forecast_window", s.ExhaustionForecastWindow)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "exhaustion_forecast_threshold", s.ExhaustionForecastThreshold)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "disk_usage_warning_threshold", s.DiskUsageWarningThreshold)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "disk_usage_error_threshold", s.DiskUsageErrorThreshold)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "clock_skew_warning_threshold", s.ClockSkewWarningThreshold)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "clock_skew_error_threshold", s.ClockSkewErrorThreshold)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "kea_log_errors_puller_interval", s.KeaLogErrorsPullerInterval)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "kea_log_errors_threshold", s.KeaLogErrorsThreshold)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "event_dedup_window", s.EventDedupWindow)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "event_flap_threshold", s.EventFlapThreshold)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "event_flap_window", s.EventFlapWindow)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "event_retention_puller_interval", s.EventRetentionPullerInterval)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "event_retention_info", s.EventRetentionInfo)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "event_retention_warning", s.EventRetentionWarning)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "event_retention_error", s.EventRetentionError)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "config_push_grace_period", s.ConfigPushGracePeriod)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
 
 	rsp := settings.NewUpdateSettingsOK()
 	return rsp
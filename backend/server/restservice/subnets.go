@@ -2,11 +2,14 @@ package restservice
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/go-openapi/runtime/middleware"
 	"github.com/go-openapi/strfmt"
 	log "github.com/sirupsen/logrus"
+	"isc.org/stork/server/apps/kea"
 	dbmodel "isc.org/stork/server/database/model"
 
 	"isc.org/stork/server/gen/models"
@@ -20,12 +23,26 @@ func subnetToRestAPI(sn *dbmodel.Subnet) *models.Subnet {
 		ClientClass:     sn.ClientClass,
 		AddrUtilization: float64(sn.AddrUtilization) / 10,
 	}
+	if !sn.AddrExhaustionAt.IsZero() {
+		subnet.AddrExhaustionAt = strfmt.DateTime(sn.AddrExhaustionAt)
+	}
+	if !sn.PdExhaustionAt.IsZero() {
+		subnet.PdExhaustionAt = strfmt.DateTime(sn.PdExhaustionAt)
+	}
 
 	for _, poolDetails := range sn.AddressPools {
 		pool := poolDetails.LowerBound + "-" + poolDetails.UpperBound
 		subnet.Pools = append(subnet.Pools, pool)
 	}
 
+	for _, poolDetails := range sn.PrefixPools {
+		pool := &models.PrefixPool{
+			Prefix:          poolDetails.Prefix,
+			DelegatedLength: int64(poolDetails.DelegatedLen),
+		}
+		subnet.PrefixDelegationPools = append(subnet.PrefixDelegationPools, pool)
+	}
+
 	if sn.SharedNetwork != nil {
 		subnet.SharedNetwork = sn.SharedNetwork.Name
 	}
@@ -45,6 +62,33 @@ func subnetToRestAPI(sn *dbmodel.Subnet) *models.Subnet {
 	return subnet
 }
 
+// Converts the subnet received over the REST API to the database format.
+// The address pools are given as address ranges or prefixes.
+func restAPIToSubnet(subnet *models.Subnet) (*dbmodel.Subnet, error) {
+	dbSubnet := &dbmodel.Subnet{
+		Prefix:      strings.TrimSpace(subnet.Subnet),
+		ClientClass: subnet.ClientClass,
+	}
+	for _, poolRange := range subnet.Pools {
+		pool, err := dbmodel.NewAddressPoolFromRange(poolRange)
+		if err != nil {
+			return nil, err
+		}
+		dbSubnet.AddressPools = append(dbSubnet.AddressPools, *pool)
+	}
+	for _, poolDetails := range subnet.PrefixDelegationPools {
+		if poolDetails == nil {
+			continue
+		}
+		pool, err := dbmodel.NewPrefixPool(poolDetails.Prefix, int(poolDetails.DelegatedLength))
+		if err != nil {
+			return nil, err
+		}
+		dbSubnet.PrefixPools = append(dbSubnet.PrefixPools, *pool)
+	}
+	return dbSubnet, nil
+}
+
 func (r *RestAPI) getSubnets(offset, limit, appID, family int64, filterText *string, sortField string, sortDir dbmodel.SortDirEnum) (*models.Subnets, error) {
 	// get subnets from db
 	dbSubnets, total, err := dbmodel.GetSubnetsByPage(r.DB, offset, limit, appID, family, filterText, sortField, sortDir)
@@ -103,6 +147,28 @@ func (r *RestAPI) GetSubnets(ctx context.Context, params dhcp.GetSubnetsParams)
 	return rsp
 }
 
+func sharedNetworkToRestAPI(net *dbmodel.SharedNetwork) *models.SharedNetwork {
+	subnets := []*models.Subnet{}
+	for _, snTmp := range net.Subnets {
+		sn := snTmp
+		subnet := subnetToRestAPI(&sn)
+		subnets = append(subnets, subnet)
+	}
+	sharedNetwork := &models.SharedNetwork{
+		ID:              net.ID,
+		Name:            net.Name,
+		Subnets:         subnets,
+		AddrUtilization: float64(net.AddrUtilization) / 10,
+	}
+	if !net.AddrExhaustionAt.IsZero() {
+		sharedNetwork.AddrExhaustionAt = strfmt.DateTime(net.AddrExhaustionAt)
+	}
+	if !net.PdExhaustionAt.IsZero() {
+		sharedNetwork.PdExhaustionAt = strfmt.DateTime(net.PdExhaustionAt)
+	}
+	return sharedNetwork
+}
+
 func (r *RestAPI) getSharedNetworks(offset, limit, appID, family int64, filterText *string, sortField string, sortDir dbmodel.SortDirEnum) (*models.SharedNetworks, error) {
 	// get shared networks from db
 	dbSharedNetworks, total, err := dbmodel.GetSharedNetworksByPage(r.DB, offset, limit, appID, family, filterText, sortField, sortDir)
@@ -120,22 +186,8 @@ func (r *RestAPI) getSharedNetworks(offset, limit, appID, family int64, filterTe
 		if len(net.Subnets) == 0 || len(net.Subnets[0].LocalSubnets) == 0 {
 			continue
 		}
-		subnets := []*models.Subnet{}
-		// Exclude the subnets that are not attached to any app. This shouldn't
-		// be the case but let's be safe.
-		for _, snTmp := range net.Subnets {
-			sn := snTmp
-			subnet := subnetToRestAPI(&sn)
-			subnets = append(subnets, subnet)
-		}
-		// Create shared network.
-		sharedNetwork := &models.SharedNetwork{
-			ID:              net.ID,
-			Name:            net.Name,
-			Subnets:         subnets,
-			AddrUtilization: float64(net.AddrUtilization) / 10,
-		}
-		sharedNetworks.Items = append(sharedNetworks.Items, sharedNetwork)
+		netTmp := net
+		sharedNetworks.Items = append(sharedNetworks.Items, sharedNetworkToRestAPI(&netTmp))
 	}
 
 	return sharedNetworks, nil
@@ -177,3 +229,605 @@ func (r *RestAPI) GetSharedNetworks(ctx context.Context, params dhcp.GetSharedNe
 	rsp := dhcp.NewGetSharedNetworksOK().WithPayload(sharedNetworks)
 	return rsp
 }
+
+// Fetches the apps with the given IDs along with the machines, the access
+// points and the daemons which are required to send the commands. It
+// returns the HTTP status code and the error message if any app cannot
+// be fetched.
+func (r *RestAPI) getSubnetChangeApps(appIDs []int64) ([]*dbmodel.App, int, string) {
+	apps := []*dbmodel.App{}
+	for _, appID := range appIDs {
+		app, err := dbmodel.GetAppByID(r.DB, appID)
+		if err != nil {
+			log.Error(err)
+			return nil, http.StatusInternalServerError, fmt.Sprintf("cannot get app with id %d from db", appID)
+		}
+		if app == nil {
+			return nil, http.StatusBadRequest, fmt.Sprintf("cannot find app with id %d", appID)
+		}
+		apps = append(apps, app)
+	}
+	return apps, 0, ""
+}
+
+// Creates the subnet change validated against the existing subnets. It
+// returns the HTTP status code and the error message if the change
+// cannot be created.
+func (r *RestAPI) newSubnetChange(subnet *dbmodel.Subnet, apps []*dbmodel.App) (*kea.SubnetChange, int, string) {
+	existingSubnets, err := dbmodel.GetAllSubnets(r.DB, subnet.GetFamily())
+	if err != nil {
+		log.Error(err)
+		return nil, http.StatusInternalServerError, "cannot get subnets from db"
+	}
+	change, err := kea.NewSubnetChange(subnet, existingSubnets, apps)
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Sprintf("invalid subnet: %s", err)
+	}
+	return change, 0, ""
+}
+
+// Returns the outcome of the subnet change and the current state of the
+// subnet in the database. The subnet is nil if it doesn't exist.
+func (r *RestAPI) getSubnetChangeResult(subnetID int64, applied bool, results []kea.DaemonCmdsResult, apps []*dbmodel.App) *models.SubnetChangeResult {
+	payload := &models.SubnetChangeResult{
+		Applied: applied,
+		Results: daemonCmdsResultsToRestAPI(results),
+	}
+	if subnetID == 0 {
+		return payload
+	}
+	dbSubnet, err := dbmodel.GetSubnet(r.DB, subnetID)
+	if err != nil {
+		log.Error(err)
+		return payload
+	}
+	if dbSubnet == nil {
+		return payload
+	}
+	if !r.setLocalSubnetApps(dbSubnet.LocalSubnets, apps) {
+		return payload
+	}
+	payload.Subnet = subnetToRestAPI(dbSubnet)
+	return payload
+}
+
+// Sets the apps of the local subnets. The apps fetched with the subnets
+// lack the machines which are returned in the local subnets. The apps
+// are taken from the list or fetched from the database. It returns false
+// if any app cannot be fetched.
+func (r *RestAPI) setLocalSubnetApps(localSubnets []*dbmodel.LocalSubnet, apps []*dbmodel.App) bool {
+	for _, ls := range localSubnets {
+		for _, app := range apps {
+			if app.ID == ls.AppID {
+				ls.App = app
+				break
+			}
+		}
+		if ls.App == nil || ls.App.Machine == nil {
+			app, err := dbmodel.GetAppByID(r.DB, ls.AppID)
+			if err != nil || app == nil {
+				log.Errorf("cannot get app with id %d from db: %+v", ls.AppID, err)
+				return false
+			}
+			ls.App = app
+		}
+	}
+	return true
+}
+
+// Add new subnet with pools to the selected Kea servers with the
+// subnet4-add or subnet6-add command. The subnet must not overlap with
+// the existing subnets. The subnet is stored in the database if all
+// servers accept it. Otherwise, it is deleted from the servers which
+// accepted it.
+func (r *RestAPI) CreateSubnet(ctx context.Context, params dhcp.CreateSubnetParams) middleware.Responder {
+	if !r.canModifyDHCP(ctx) {
+		msg := "user is forbidden to add subnets"
+		rsp := dhcp.NewCreateSubnetDefault(http.StatusForbidden).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if params.Subnet == nil || params.Subnet.Subnet == nil {
+		msg := "missing subnet"
+		rsp := dhcp.NewCreateSubnetDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	dbSubnet, err := restAPIToSubnet(params.Subnet.Subnet)
+	if err != nil {
+		msg := fmt.Sprintf("invalid subnet: %s", err)
+		rsp := dhcp.NewCreateSubnetDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	apps, code, msg := r.getSubnetChangeApps(params.Subnet.AppIds)
+	if apps == nil {
+		rsp := dhcp.NewCreateSubnetDefault(code).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	change, code, msg := r.newSubnetChange(dbSubnet, apps)
+	if change == nil {
+		rsp := dhcp.NewCreateSubnetDefault(code).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	applied, results, err := kea.AddSubnetToDaemons(ctx, r.DB, r.Agents, change)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("problem with storing the added subnet %s in the database", dbSubnet.Prefix)
+		rsp := dhcp.NewCreateSubnetDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	subnetID := int64(0)
+	if applied {
+		subnetID = dbSubnet.ID
+	}
+	rsp := dhcp.NewCreateSubnetOK().WithPayload(r.getSubnetChangeResult(subnetID, applied, results, apps))
+	return rsp
+}
+
+// Update the subnet and its pools in all Kea servers sharing the subnet
+// with the subnet4-update or subnet6-update command. The subnet must not
+// overlap with the other existing subnets. The subnet is updated in the
+// database if all servers accept the update. Otherwise, the original
+// subnet is restored in the servers which accepted it.
+func (r *RestAPI) UpdateSubnet(ctx context.Context, params dhcp.UpdateSubnetParams) middleware.Responder {
+	if !r.canModifyDHCP(ctx) {
+		msg := "user is forbidden to update subnets"
+		rsp := dhcp.NewUpdateSubnetDefault(http.StatusForbidden).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if params.Subnet == nil || params.Subnet.Subnet == nil {
+		msg := "missing subnet"
+		rsp := dhcp.NewUpdateSubnetDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	dbExisting, err := dbmodel.GetSubnet(r.DB, params.ID)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("cannot get subnet with id %d from db", params.ID)
+		rsp := dhcp.NewUpdateSubnetDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if dbExisting == nil {
+		msg := fmt.Sprintf("cannot find subnet with id %d", params.ID)
+		rsp := dhcp.NewUpdateSubnetDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	dbSubnet, err := restAPIToSubnet(params.Subnet.Subnet)
+	if err != nil {
+		msg := fmt.Sprintf("invalid subnet: %s", err)
+		rsp := dhcp.NewUpdateSubnetDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if dbSubnet.GetFamily() != dbExisting.GetFamily() {
+		msg := fmt.Sprintf("cannot change the family of the subnet %s", dbExisting.Prefix)
+		rsp := dhcp.NewUpdateSubnetDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	// The subnet remains in the same shared network and apps.
+	dbSubnet.ID = dbExisting.ID
+	dbSubnet.SharedNetworkID = dbExisting.SharedNetworkID
+	dbSubnet.LocalSubnets = dbExisting.LocalSubnets
+	appIDs := []int64{}
+	for _, ls := range dbExisting.LocalSubnets {
+		appIDs = append(appIDs, ls.AppID)
+	}
+	apps, code, msg := r.getSubnetChangeApps(appIDs)
+	if apps == nil {
+		rsp := dhcp.NewUpdateSubnetDefault(code).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	change, code, msg := r.newSubnetChange(dbSubnet, apps)
+	if change == nil {
+		rsp := dhcp.NewUpdateSubnetDefault(code).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	applied, results, err := kea.UpdateSubnetInDaemons(ctx, r.DB, r.Agents, change)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("problem with storing the updated subnet with id %d in the database", params.ID)
+		rsp := dhcp.NewUpdateSubnetDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	rsp := dhcp.NewUpdateSubnetOK().WithPayload(r.getSubnetChangeResult(params.ID, applied, results, apps))
+	return rsp
+}
+
+// Delete the subnet from all Kea servers sharing the subnet with the
+// subnet4-del or subnet6-del command. The subnet is deleted from the
+// database if all servers delete it. Otherwise, it is restored in the
+// servers which deleted it.
+func (r *RestAPI) DeleteSubnet(ctx context.Context, params dhcp.DeleteSubnetParams) middleware.Responder {
+	if !r.canModifyDHCP(ctx) {
+		msg := "user is forbidden to delete subnets"
+		rsp := dhcp.NewDeleteSubnetDefault(http.StatusForbidden).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	dbSubnet, err := dbmodel.GetSubnet(r.DB, params.ID)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("cannot get subnet with id %d from db", params.ID)
+		rsp := dhcp.NewDeleteSubnetDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if dbSubnet == nil {
+		msg := fmt.Sprintf("cannot find subnet with id %d", params.ID)
+		rsp := dhcp.NewDeleteSubnetDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	appIDs := []int64{}
+	for _, ls := range dbSubnet.LocalSubnets {
+		appIDs = append(appIDs, ls.AppID)
+	}
+	apps, code, msg := r.getSubnetChangeApps(appIDs)
+	if apps == nil {
+		rsp := dhcp.NewDeleteSubnetDefault(code).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	change, err := kea.NewSubnetChange(dbSubnet, nil, apps)
+	if err != nil {
+		msg := fmt.Sprintf("cannot delete subnet with id %d: %s", params.ID, err)
+		rsp := dhcp.NewDeleteSubnetDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	applied, results, err := kea.DeleteSubnetFromDaemons(ctx, r.DB, r.Agents, change)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("problem with deleting the subnet with id %d from the database", params.ID)
+		rsp := dhcp.NewDeleteSubnetDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	rsp := dhcp.NewDeleteSubnetOK().WithPayload(r.getSubnetChangeResult(params.ID, applied, results, apps))
+	return rsp
+}
+
+// Returns the outcome of the shared network change and the current state
+// of the network with its subnets in the database. The network is nil if
+// it doesn't exist.
+func (r *RestAPI) getSharedNetworkChangeResult(networkID int64, applied bool, results []kea.DaemonCmdsResult, apps []*dbmodel.App) *models.SharedNetworkChangeResult {
+	payload := &models.SharedNetworkChangeResult{
+		Applied: applied,
+		Results: daemonCmdsResultsToRestAPI(results),
+	}
+	if networkID == 0 {
+		return payload
+	}
+	dbNetwork, err := dbmodel.GetSharedNetworkWithSubnets(r.DB, networkID)
+	if err != nil {
+		log.Error(err)
+		return payload
+	}
+	if dbNetwork == nil {
+		return payload
+	}
+	for _, sn := range dbNetwork.Subnets {
+		if !r.setLocalSubnetApps(sn.LocalSubnets, apps) {
+			return payload
+		}
+	}
+	payload.SharedNetwork = sharedNetworkToRestAPI(dbNetwork)
+	return payload
+}
+
+// Add new shared network without subnets to the selected Kea servers with
+// the network4-add or network6-add command. The network is stored in the
+// database if all servers accept it. Otherwise, it is deleted from the
+// servers which accepted it.
+func (r *RestAPI) CreateSharedNetwork(ctx context.Context, params dhcp.CreateSharedNetworkParams) middleware.Responder {
+	if !r.canModifyDHCP(ctx) {
+		msg := "user is forbidden to add shared networks"
+		rsp := dhcp.NewCreateSharedNetworkDefault(http.StatusForbidden).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if params.SharedNetwork == nil {
+		msg := "missing shared network"
+		rsp := dhcp.NewCreateSharedNetworkDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	dbNetwork := &dbmodel.SharedNetwork{
+		Name:   params.SharedNetwork.Name,
+		Family: int(params.SharedNetwork.DhcpVersion),
+	}
+	existingNetworks, err := dbmodel.GetAllSharedNetworks(r.DB, dbNetwork.Family)
+	if err != nil {
+		log.Error(err)
+		msg := "cannot get shared networks from db"
+		rsp := dhcp.NewCreateSharedNetworkDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	apps, code, msg := r.getSubnetChangeApps(params.SharedNetwork.AppIds)
+	if apps == nil {
+		rsp := dhcp.NewCreateSharedNetworkDefault(code).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	change, err := kea.NewSharedNetworkChange(dbNetwork, existingNetworks, apps)
+	if err != nil {
+		msg := fmt.Sprintf("invalid shared network: %s", err)
+		rsp := dhcp.NewCreateSharedNetworkDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	applied, results, err := kea.AddSharedNetworkToDaemons(ctx, r.DB, r.Agents, change)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("problem with storing the added shared network %s in the database", dbNetwork.Name)
+		rsp := dhcp.NewCreateSharedNetworkDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	networkID := int64(0)
+	if applied {
+		networkID = dbNetwork.ID
+	}
+	rsp := dhcp.NewCreateSharedNetworkOK().WithPayload(r.getSharedNetworkChangeResult(networkID, applied, results, apps))
+	return rsp
+}
+
+// Delete the shared network from all Kea servers having it with the
+// network4-del or network6-del command. The subnets of the network are
+// kept as the top level subnets. The network is deleted from the database
+// if all servers delete it. Otherwise, it is restored in the servers which
+// deleted it.
+func (r *RestAPI) DeleteSharedNetwork(ctx context.Context, params dhcp.DeleteSharedNetworkParams) middleware.Responder {
+	if !r.canModifyDHCP(ctx) {
+		msg := "user is forbidden to delete shared networks"
+		rsp := dhcp.NewDeleteSharedNetworkDefault(http.StatusForbidden).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	dbNetwork, err := dbmodel.GetSharedNetwork(r.DB, params.ID)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("cannot get shared network with id %d from db", params.ID)
+		rsp := dhcp.NewDeleteSharedNetworkDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if dbNetwork == nil {
+		msg := fmt.Sprintf("cannot find shared network with id %d", params.ID)
+		rsp := dhcp.NewDeleteSharedNetworkDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	// The network may have been added to the servers which don't serve any
+	// of its subnets, so it is deleted from all Kea servers having it.
+	dbApps, err := dbmodel.GetAppsByType(r.DB, dbmodel.AppTypeKea)
+	if err != nil {
+		log.Error(err)
+		msg := "cannot get Kea apps from db"
+		rsp := dhcp.NewDeleteSharedNetworkDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	apps := []*dbmodel.App{}
+	for i := range dbApps {
+		apps = append(apps, &dbApps[i])
+	}
+	change, err := kea.NewSharedNetworkChange(dbNetwork, nil, apps)
+	if err != nil {
+		msg := fmt.Sprintf("cannot delete shared network with id %d: %s", params.ID, err)
+		rsp := dhcp.NewDeleteSharedNetworkDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	applied, results, err := kea.DeleteSharedNetworkFromDaemons(ctx, r.DB, r.Agents, change)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("problem with deleting the shared network with id %d from the database", params.ID)
+		rsp := dhcp.NewDeleteSharedNetworkDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	rsp := dhcp.NewDeleteSharedNetworkOK().WithPayload(r.getSharedNetworkChangeResult(params.ID, applied, results, apps))
+	return rsp
+}
+
+// Fetches the shared network and the subnet moved in or out of it. It
+// returns the HTTP status code and the error message if any of them
+// cannot be fetched.
+func (r *RestAPI) getSharedNetworkAndSubnet(networkID, subnetID int64) (*dbmodel.SharedNetwork, *dbmodel.Subnet, int, string) {
+	dbNetwork, err := dbmodel.GetSharedNetwork(r.DB, networkID)
+	if err != nil {
+		log.Error(err)
+		return nil, nil, http.StatusInternalServerError, fmt.Sprintf("cannot get shared network with id %d from db", networkID)
+	}
+	if dbNetwork == nil {
+		return nil, nil, http.StatusNotFound, fmt.Sprintf("cannot find shared network with id %d", networkID)
+	}
+	dbSubnet, err := dbmodel.GetSubnet(r.DB, subnetID)
+	if err != nil {
+		log.Error(err)
+		return nil, nil, http.StatusInternalServerError, fmt.Sprintf("cannot get subnet with id %d from db", subnetID)
+	}
+	if dbSubnet == nil {
+		return nil, nil, http.StatusNotFound, fmt.Sprintf("cannot find subnet with id %d", subnetID)
+	}
+	return dbNetwork, dbSubnet, 0, ""
+}
+
+// Creates the change moving the subnet in or out of the shared network in
+// the servers serving the subnet. It returns the HTTP status code and the
+// error message if the change cannot be created.
+func (r *RestAPI) newSharedNetworkSubnetChange(dbNetwork *dbmodel.SharedNetwork, dbSubnet *dbmodel.Subnet) (*kea.SharedNetworkChange, []*dbmodel.App, int, string) {
+	appIDs := []int64{}
+	for _, ls := range dbSubnet.LocalSubnets {
+		appIDs = append(appIDs, ls.AppID)
+	}
+	apps, code, msg := r.getSubnetChangeApps(appIDs)
+	if apps == nil {
+		return nil, nil, code, msg
+	}
+	change, err := kea.NewSharedNetworkSubnetChange(dbNetwork, dbSubnet, apps)
+	if err != nil {
+		return nil, nil, http.StatusBadRequest, fmt.Sprintf("cannot move subnet with id %d: %s", dbSubnet.ID, err)
+	}
+	return change, apps, 0, ""
+}
+
+// Move the subnet to the shared network in all Kea servers serving the
+// subnet with the network4-subnet-add or network6-subnet-add command.
+// The subnet must not belong to any shared network. The subnet is moved
+// in the database if all servers accept the command. Otherwise, it is
+// moved back out of the network in the servers which accepted it.
+func (r *RestAPI) AddSubnetToSharedNetwork(ctx context.Context, params dhcp.AddSubnetToSharedNetworkParams) middleware.Responder {
+	if !r.canModifyDHCP(ctx) {
+		msg := "user is forbidden to modify shared networks"
+		rsp := dhcp.NewAddSubnetToSharedNetworkDefault(http.StatusForbidden).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	dbNetwork, dbSubnet, code, msg := r.getSharedNetworkAndSubnet(params.ID, params.SubnetID)
+	if dbSubnet == nil {
+		rsp := dhcp.NewAddSubnetToSharedNetworkDefault(code).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if dbSubnet.SharedNetworkID != 0 {
+		msg := fmt.Sprintf("subnet %s already belongs to a shared network", dbSubnet.Prefix)
+		if dbSubnet.SharedNetwork != nil {
+			msg = fmt.Sprintf("subnet %s already belongs to the shared network %s", dbSubnet.Prefix, dbSubnet.SharedNetwork.Name)
+		}
+		rsp := dhcp.NewAddSubnetToSharedNetworkDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	change, apps, code, msg := r.newSharedNetworkSubnetChange(dbNetwork, dbSubnet)
+	if change == nil {
+		rsp := dhcp.NewAddSubnetToSharedNetworkDefault(code).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	applied, results, err := kea.AddSubnetToSharedNetworkInDaemons(ctx, r.DB, r.Agents, change)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("problem with moving the subnet with id %d to the shared network with id %d in the database",
+			params.SubnetID, params.ID)
+		rsp := dhcp.NewAddSubnetToSharedNetworkDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	rsp := dhcp.NewAddSubnetToSharedNetworkOK().WithPayload(r.getSharedNetworkChangeResult(params.ID, applied, results, apps))
+	return rsp
+}
+
+// Move the subnet out of the shared network in all Kea servers serving
+// the subnet with the network4-subnet-del or network6-subnet-del command.
+// The subnet becomes the top level subnet. It is moved in the database
+// if all servers accept the command. Otherwise, it is moved back to the
+// network in the servers which accepted it.
+func (r *RestAPI) DeleteSubnetFromSharedNetwork(ctx context.Context, params dhcp.DeleteSubnetFromSharedNetworkParams) middleware.Responder {
+	if !r.canModifyDHCP(ctx) {
+		msg := "user is forbidden to modify shared networks"
+		rsp := dhcp.NewDeleteSubnetFromSharedNetworkDefault(http.StatusForbidden).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	dbNetwork, dbSubnet, code, msg := r.getSharedNetworkAndSubnet(params.ID, params.SubnetID)
+	if dbSubnet == nil {
+		rsp := dhcp.NewDeleteSubnetFromSharedNetworkDefault(code).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if dbSubnet.SharedNetworkID != dbNetwork.ID {
+		msg := fmt.Sprintf("subnet %s does not belong to the shared network %s", dbSubnet.Prefix, dbNetwork.Name)
+		rsp := dhcp.NewDeleteSubnetFromSharedNetworkDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	change, apps, code, msg := r.newSharedNetworkSubnetChange(dbNetwork, dbSubnet)
+	if change == nil {
+		rsp := dhcp.NewDeleteSubnetFromSharedNetworkDefault(code).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	applied, results, err := kea.DeleteSubnetFromSharedNetworkInDaemons(ctx, r.DB, r.Agents, change)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("problem with moving the subnet with id %d out of the shared network with id %d in the database",
+			params.SubnetID, params.ID)
+		rsp := dhcp.NewDeleteSubnetFromSharedNetworkDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	rsp := dhcp.NewDeleteSubnetFromSharedNetworkOK().WithPayload(r.getSharedNetworkChangeResult(params.ID, applied, results, apps))
+	return rsp
+}
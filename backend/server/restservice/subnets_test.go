@@ -2,12 +2,15 @@ package restservice
 
 import (
 	"context"
+	"fmt"
+	"net/http"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 	agentcommtest "isc.org/stork/server/agentcomm/test"
 	dbmodel "isc.org/stork/server/database/model"
 	dbtest "isc.org/stork/server/database/test"
+	"isc.org/stork/server/gen/models"
 	dhcp "isc.org/stork/server/gen/restapi/operations/d_h_c_p"
 	storktest "isc.org/stork/server/test"
 )
@@ -517,3 +520,260 @@ func TestGetSharedNetworks(t *testing.T) {
 	require.Equal(t, a4.Name, okRsp.Payload.Items[1].Subnets[0].LocalSubnets[0].AppName)
 	require.ElementsMatch(t, []string{"mouse", "frog"}, []string{okRsp.Payload.Items[0].Name, okRsp.Payload.Items[1].Name})
 }
+
+// Test adding, updating and deleting the subnet in two Kea servers via
+// rest api functions.
+func TestCreateUpdateDeleteSubnet(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	settings := RestAPISettings{}
+	fa := agentcommtest.NewFakeAgents(mockKeaCmdResponses(
+		// subnet4-add
+		`[{"result": 0, "text": "IPv4 subnet added"}]`,
+		`[{"result": 0, "text": "IPv4 subnet added"}]`,
+		// subnet4-get, subnet4-update and subnet4-update restoring the
+		// original subnet
+		`[{"result": 0, "arguments": {"subnet4": [{"id": 8, "subnet": "192.0.3.0/24"}]}}]`,
+		`[{"result": 0, "arguments": {"subnet4": [{"id": 8, "subnet": "192.0.3.0/24"}]}}]`,
+		`[{"result": 0, "text": "IPv4 subnet updated"}]`,
+		`[{"result": 1, "text": "invalid pool"}]`,
+		`[{"result": 0, "text": "IPv4 subnet updated"}]`,
+		// subnet4-get and subnet4-del
+		`[{"result": 0, "arguments": {"subnet4": [{"id": 8, "subnet": "192.0.3.0/24"}]}}]`,
+		`[{"result": 0, "arguments": {"subnet4": [{"id": 8, "subnet": "192.0.3.0/24"}]}}]`,
+		`[{"result": 0, "text": "IPv4 subnet deleted"}]`,
+		`[{"result": 0, "text": "IPv4 subnet deleted"}]`,
+	), nil)
+	fec := &storktest.FakeEventCenter{}
+	rapi, err := NewRestAPI(&settings, dbSettings, db, fa, fec, nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	admin, err := dbmodel.GetUserByID(rapi.DB, 1)
+	require.NoError(t, err)
+	ctx, err = rapi.SessionManager.Load(ctx, "")
+	require.NoError(t, err)
+	err = rapi.SessionManager.LoginHandler(ctx, admin)
+	require.NoError(t, err)
+
+	existing := &dbmodel.Subnet{
+		Prefix: "192.0.2.0/24",
+	}
+	err = dbmodel.AddSubnet(db, existing)
+	require.NoError(t, err)
+
+	var appIDs []int64
+	for i := 0; i < 2; i++ {
+		m := &dbmodel.Machine{
+			Address:   "localhost",
+			AgentPort: int64(8080 + i),
+		}
+		err = dbmodel.AddMachine(db, m)
+		require.NoError(t, err)
+		accessPoints := []*dbmodel.AccessPoint{}
+		accessPoints = dbmodel.AppendAccessPoint(accessPoints, dbmodel.AccessPointControl, "localhost", "", int64(8000+i))
+		daemon := dbmodel.NewKeaDaemon(dbmodel.DaemonNameDHCPv4, true)
+		err = daemon.SetConfigFromJSON(`{"Dhcp4": {"subnet4": [{"id": 7, "subnet": "192.0.2.0/24"}]}}`)
+		require.NoError(t, err)
+		app := &dbmodel.App{
+			MachineID:    m.ID,
+			Type:         dbmodel.AppTypeKea,
+			Name:         fmt.Sprintf("dhcp-server%d", i),
+			AccessPoints: accessPoints,
+			Daemons:      []*dbmodel.Daemon{daemon},
+		}
+		_, err = dbmodel.AddApp(db, app)
+		require.NoError(t, err)
+		appIDs = append(appIDs, app.ID)
+	}
+
+	params := dhcp.CreateSubnetParams{
+		Subnet: &models.SubnetReq{
+			Subnet: &models.Subnet{
+				Subnet: "192.0.2.128/25",
+				Pools:  []string{"192.0.2.150-192.0.2.200"},
+			},
+			AppIds: appIDs,
+		},
+	}
+
+	// The subnet overlaps with the existing subnet.
+	rsp := rapi.CreateSubnet(ctx, params)
+	require.IsType(t, &dhcp.CreateSubnetDefault{}, rsp)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*rsp.(*dhcp.CreateSubnetDefault)))
+	require.Empty(t, fa.RecordedCommands)
+
+	// The subnet is added to both servers.
+	params.Subnet.Subnet.Subnet = "192.0.3.0/24"
+	params.Subnet.Subnet.Pools = []string{"192.0.3.10-192.0.3.100"}
+	rsp = rapi.CreateSubnet(ctx, params)
+	require.IsType(t, &dhcp.CreateSubnetOK{}, rsp)
+	result := rsp.(*dhcp.CreateSubnetOK).Payload
+	require.True(t, result.Applied)
+	require.Len(t, result.Results, 2)
+	require.NotNil(t, result.Subnet)
+	require.Equal(t, "192.0.3.0/24", result.Subnet.Subnet)
+	require.Equal(t, []string{"192.0.3.10-192.0.3.100"}, result.Subnet.Pools)
+	require.Len(t, result.Subnet.LocalSubnets, 2)
+	require.EqualValues(t, 8, result.Subnet.LocalSubnets[0].ID)
+	require.Equal(t, "localhost", result.Subnet.LocalSubnets[0].MachineAddress)
+
+	// The update is rejected by the second server.
+	subnetID := result.Subnet.ID
+	updateParams := dhcp.UpdateSubnetParams{
+		ID:     subnetID,
+		Subnet: params.Subnet,
+	}
+	updateParams.Subnet.Subnet.Pools = []string{"192.0.3.10-192.0.3.200"}
+	rsp = rapi.UpdateSubnet(ctx, updateParams)
+	require.IsType(t, &dhcp.UpdateSubnetOK{}, rsp)
+	result = rsp.(*dhcp.UpdateSubnetOK).Payload
+	require.False(t, result.Applied)
+	require.Len(t, result.Results, 5)
+	require.Equal(t, "subnet4-update", result.Results[4].Command)
+	require.Equal(t, []string{"192.0.3.10-192.0.3.100"}, result.Subnet.Pools)
+
+	// The subnet is deleted from both servers.
+	rsp = rapi.DeleteSubnet(ctx, dhcp.DeleteSubnetParams{ID: subnetID})
+	require.IsType(t, &dhcp.DeleteSubnetOK{}, rsp)
+	result = rsp.(*dhcp.DeleteSubnetOK).Payload
+	require.True(t, result.Applied)
+	require.Nil(t, result.Subnet)
+
+	subnet, err := dbmodel.GetSubnet(db, subnetID)
+	require.NoError(t, err)
+	require.Nil(t, subnet)
+
+	// Non-existing subnet.
+	rsp = rapi.DeleteSubnet(ctx, dhcp.DeleteSubnetParams{ID: subnetID})
+	require.IsType(t, &dhcp.DeleteSubnetDefault{}, rsp)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*rsp.(*dhcp.DeleteSubnetDefault)))
+}
+
+// Test adding the shared network, moving the subnet in and out of it
+// and deleting the network.
+func TestCreateDeleteSharedNetwork(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	settings := RestAPISettings{}
+	fa := agentcommtest.NewFakeAgents(mockKeaCmdResponses(
+		// network4-add
+		`[{"result": 0, "text": "A new IPv4 shared network 'foo' added"}]`,
+		// network4-subnet-add
+		`[{"result": 0, "text": "IPv4 subnet 7 added to shared network 'foo'"}]`,
+		// network4-subnet-del
+		`[{"result": 0, "text": "IPv4 subnet 7 deleted from shared network 'foo'"}]`,
+		// network4-get and network4-del
+		`[{"result": 0, "arguments": {"shared-networks": [{"name": "foo", "subnet4": []}]}}]`,
+		`[{"result": 0, "text": "IPv4 shared network 'foo' deleted"}]`,
+	), nil)
+	fec := &storktest.FakeEventCenter{}
+	rapi, err := NewRestAPI(&settings, dbSettings, db, fa, fec, nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	admin, err := dbmodel.GetUserByID(rapi.DB, 1)
+	require.NoError(t, err)
+	ctx, err = rapi.SessionManager.Load(ctx, "")
+	require.NoError(t, err)
+	err = rapi.SessionManager.LoginHandler(ctx, admin)
+	require.NoError(t, err)
+
+	m := &dbmodel.Machine{
+		Address:   "localhost",
+		AgentPort: 8080,
+	}
+	err = dbmodel.AddMachine(db, m)
+	require.NoError(t, err)
+	accessPoints := []*dbmodel.AccessPoint{}
+	accessPoints = dbmodel.AppendAccessPoint(accessPoints, dbmodel.AccessPointControl, "localhost", "", 8000)
+	daemon := dbmodel.NewKeaDaemon(dbmodel.DaemonNameDHCPv4, true)
+	err = daemon.SetConfigFromJSON(`{"Dhcp4": {"subnet4": [{"id": 7, "subnet": "192.0.2.0/24"}]}}`)
+	require.NoError(t, err)
+	app := &dbmodel.App{
+		MachineID:    m.ID,
+		Type:         dbmodel.AppTypeKea,
+		Name:         "dhcp-server",
+		AccessPoints: accessPoints,
+		Daemons:      []*dbmodel.Daemon{daemon},
+	}
+	_, err = dbmodel.AddApp(db, app)
+	require.NoError(t, err)
+
+	subnet := &dbmodel.Subnet{
+		Prefix: "192.0.2.0/24",
+	}
+	err = dbmodel.AddSubnet(db, subnet)
+	require.NoError(t, err)
+	err = dbmodel.AddAppToSubnet(db, subnet, app)
+	require.NoError(t, err)
+
+	// The family is missing.
+	params := dhcp.CreateSharedNetworkParams{
+		SharedNetwork: &models.SharedNetworkReq{
+			Name:   "foo",
+			AppIds: []int64{app.ID},
+		},
+	}
+	rsp := rapi.CreateSharedNetwork(ctx, params)
+	require.IsType(t, &dhcp.CreateSharedNetworkDefault{}, rsp)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*rsp.(*dhcp.CreateSharedNetworkDefault)))
+	require.Empty(t, fa.RecordedCommands)
+
+	// The network is added to the server.
+	params.SharedNetwork.DhcpVersion = 4
+	rsp = rapi.CreateSharedNetwork(ctx, params)
+	require.IsType(t, &dhcp.CreateSharedNetworkOK{}, rsp)
+	result := rsp.(*dhcp.CreateSharedNetworkOK).Payload
+	require.True(t, result.Applied)
+	require.Len(t, result.Results, 1)
+	require.NotNil(t, result.SharedNetwork)
+	require.Equal(t, "foo", result.SharedNetwork.Name)
+	require.Empty(t, result.SharedNetwork.Subnets)
+	networkID := result.SharedNetwork.ID
+
+	// The network with the same name already exists.
+	rsp = rapi.CreateSharedNetwork(ctx, params)
+	require.IsType(t, &dhcp.CreateSharedNetworkDefault{}, rsp)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*rsp.(*dhcp.CreateSharedNetworkDefault)))
+
+	// The subnet is moved to the network.
+	rsp = rapi.AddSubnetToSharedNetwork(ctx, dhcp.AddSubnetToSharedNetworkParams{ID: networkID, SubnetID: subnet.ID})
+	require.IsType(t, &dhcp.AddSubnetToSharedNetworkOK{}, rsp)
+	result = rsp.(*dhcp.AddSubnetToSharedNetworkOK).Payload
+	require.True(t, result.Applied)
+	require.Len(t, result.SharedNetwork.Subnets, 1)
+	require.Equal(t, "localhost", result.SharedNetwork.Subnets[0].LocalSubnets[0].MachineAddress)
+	require.EqualValues(t, 7, (*fa.RecordedCommands[1].Arguments)["id"])
+
+	// The subnet already belongs to the network.
+	rsp = rapi.AddSubnetToSharedNetwork(ctx, dhcp.AddSubnetToSharedNetworkParams{ID: networkID, SubnetID: subnet.ID})
+	require.IsType(t, &dhcp.AddSubnetToSharedNetworkDefault{}, rsp)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*rsp.(*dhcp.AddSubnetToSharedNetworkDefault)))
+
+	// The subnet is moved out of the network.
+	rsp = rapi.DeleteSubnetFromSharedNetwork(ctx, dhcp.DeleteSubnetFromSharedNetworkParams{ID: networkID, SubnetID: subnet.ID})
+	require.IsType(t, &dhcp.DeleteSubnetFromSharedNetworkOK{}, rsp)
+	result = rsp.(*dhcp.DeleteSubnetFromSharedNetworkOK).Payload
+	require.True(t, result.Applied)
+	require.Empty(t, result.SharedNetwork.Subnets)
+
+	// The network is deleted.
+	rsp = rapi.DeleteSharedNetwork(ctx, dhcp.DeleteSharedNetworkParams{ID: networkID})
+	require.IsType(t, &dhcp.DeleteSharedNetworkOK{}, rsp)
+	result = rsp.(*dhcp.DeleteSharedNetworkOK).Payload
+	require.True(t, result.Applied)
+	require.Nil(t, result.SharedNetwork)
+	require.Len(t, result.Results, 2)
+
+	network, err := dbmodel.GetSharedNetwork(db, networkID)
+	require.NoError(t, err)
+	require.Nil(t, network)
+
+	// Non-existing network.
+	rsp = rapi.DeleteSharedNetwork(ctx, dhcp.DeleteSharedNetworkParams{ID: networkID})
+	require.IsType(t, &dhcp.DeleteSharedNetworkDefault{}, rsp)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*rsp.(*dhcp.DeleteSharedNetworkDefault)))
+}
@@ -0,0 +1,93 @@
+package restservice
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-openapi/runtime/middleware"
+	"github.com/go-openapi/strfmt"
+	log "github.com/sirupsen/logrus"
+
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/gen/models"
+	dhcp "isc.org/stork/server/gen/restapi/operations/d_h_c_p"
+	storkutil "isc.org/stork/util"
+)
+
+// Get the utilization series of the subnet, shared network or daemon.
+func (r *RestAPI) GetUtilization(ctx context.Context, params dhcp.GetUtilizationParams) middleware.Responder {
+	var subnetID, sharedNetworkID, daemonID int64
+	objects := 0
+	if params.SubnetID != nil {
+		subnetID = *params.SubnetID
+		objects++
+	}
+	if params.SharedNetworkID != nil {
+		sharedNetworkID = *params.SharedNetworkID
+		objects++
+	}
+	if params.DaemonID != nil {
+		daemonID = *params.DaemonID
+		objects++
+	}
+	if objects != 1 {
+		msg := "exactly one of subnet, shared network or daemon must be specified"
+		log.Warn(msg)
+		rsp := dhcp.NewGetUtilizationDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	// By default return the raw samples from the last 48 hours.
+	now := storkutil.UTCNow()
+	to := now
+	if params.To != nil {
+		to = time.Time(*params.To)
+	}
+	from := to.Add(-dbmodel.UtilizationRawRetention)
+	if params.From != nil {
+		from = time.Time(*params.From)
+	}
+	if from.After(to) {
+		msg := "beginning of the time range must not be after its end"
+		log.Warn(msg)
+		rsp := dhcp.NewGetUtilizationDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	resolution := dbmodel.GetUtilizationResolution(from, now)
+	if params.Resolution != nil {
+		resolution = *params.Resolution
+	}
+
+	dbSamples, err := dbmodel.GetUtilizationSamples(r.DB, subnetID, sharedNetworkID, daemonID, resolution, from, to)
+	if err != nil {
+		msg := "cannot get utilization samples from db"
+		log.Error(err)
+		rsp := dhcp.NewGetUtilizationDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	series := &models.UtilizationSeries{
+		Resolution: resolution,
+		Items:      []*models.UtilizationSample{},
+	}
+	for _, s := range dbSamples {
+		series.Items = append(series.Items, &models.UtilizationSample{
+			SampledAt:         strfmt.DateTime(s.SampledAt),
+			AssignedAddresses: s.AssignedAddresses,
+			TotalAddresses:    s.TotalAddresses,
+			DeclinedAddresses: s.DeclinedAddresses,
+			AssignedPds:       s.AssignedPds,
+			TotalPds:          s.TotalPds,
+		})
+	}
+
+	rsp := dhcp.NewGetUtilizationOK().WithPayload(series)
+	return rsp
+}
@@ -0,0 +1,106 @@
+package restservice
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	"github.com/stretchr/testify/require"
+	agentcommtest "isc.org/stork/server/agentcomm/test"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	dhcp "isc.org/stork/server/gen/restapi/operations/d_h_c_p"
+	storktest "isc.org/stork/server/test"
+	storkutil "isc.org/stork/util"
+)
+
+// Check getting the utilization series via rest api functions.
+func TestGetUtilization(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	settings := RestAPISettings{}
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	fec := &storktest.FakeEventCenter{}
+	rapi, err := NewRestAPI(&settings, dbSettings, db, fa, fec, nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	subnet := &dbmodel.Subnet{
+		Prefix: "192.0.2.0/24",
+	}
+	err = dbmodel.AddSubnet(db, subnet)
+	require.NoError(t, err)
+
+	now := storkutil.UTCNow()
+	samples := []*dbmodel.UtilizationSample{
+		{
+			Resolution:        dbmodel.UtilizationResolutionRaw,
+			SampledAt:         now.Add(-time.Hour),
+			SubnetID:          subnet.ID,
+			AssignedAddresses: 10,
+			TotalAddresses:    100,
+			DeclinedAddresses: 1,
+		},
+		{
+			Resolution:        dbmodel.UtilizationResolutionHour,
+			SampledAt:         now.Add(-72 * time.Hour).Truncate(time.Hour),
+			SubnetID:          subnet.ID,
+			AssignedAddresses: 20,
+			TotalAddresses:    100,
+		},
+	}
+	err = dbmodel.AddUtilizationSamples(db, samples)
+	require.NoError(t, err)
+
+	// By default the raw samples from the last 48 hours are returned.
+	params := dhcp.GetUtilizationParams{
+		SubnetID: &subnet.ID,
+	}
+	rsp := rapi.GetUtilization(ctx, params)
+	require.IsType(t, &dhcp.GetUtilizationOK{}, rsp)
+	okRsp := rsp.(*dhcp.GetUtilizationOK)
+	require.Equal(t, dbmodel.UtilizationResolutionRaw, okRsp.Payload.Resolution)
+	require.Len(t, okRsp.Payload.Items, 1)
+	require.EqualValues(t, 10, okRsp.Payload.Items[0].AssignedAddresses)
+	require.EqualValues(t, 100, okRsp.Payload.Items[0].TotalAddresses)
+	require.EqualValues(t, 1, okRsp.Payload.Items[0].DeclinedAddresses)
+
+	// Longer time range selects the hourly samples.
+	from := strfmt.DateTime(now.Add(-96 * time.Hour))
+	params.From = &from
+	rsp = rapi.GetUtilization(ctx, params)
+	require.IsType(t, &dhcp.GetUtilizationOK{}, rsp)
+	okRsp = rsp.(*dhcp.GetUtilizationOK)
+	require.Equal(t, dbmodel.UtilizationResolutionHour, okRsp.Payload.Resolution)
+	require.Len(t, okRsp.Payload.Items, 1)
+	require.EqualValues(t, 20, okRsp.Payload.Items[0].AssignedAddresses)
+
+	// Explicit resolution.
+	resolution := dbmodel.UtilizationResolutionRaw
+	params.Resolution = &resolution
+	rsp = rapi.GetUtilization(ctx, params)
+	require.IsType(t, &dhcp.GetUtilizationOK{}, rsp)
+	okRsp = rsp.(*dhcp.GetUtilizationOK)
+	require.Equal(t, dbmodel.UtilizationResolutionRaw, okRsp.Payload.Resolution)
+	require.Len(t, okRsp.Payload.Items, 1)
+	require.EqualValues(t, 10, okRsp.Payload.Items[0].AssignedAddresses)
+
+	// Exactly one object must be specified.
+	networkID := int64(1)
+	params = dhcp.GetUtilizationParams{
+		SubnetID:        &subnet.ID,
+		SharedNetworkID: &networkID,
+	}
+	rsp = rapi.GetUtilization(ctx, params)
+	require.IsType(t, &dhcp.GetUtilizationDefault{}, rsp)
+	defaultRsp := rsp.(*dhcp.GetUtilizationDefault)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*defaultRsp))
+
+	rsp = rapi.GetUtilization(ctx, dhcp.GetUtilizationParams{})
+	require.IsType(t, &dhcp.GetUtilizationDefault{}, rsp)
+	defaultRsp = rsp.(*dhcp.GetUtilizationDefault)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*defaultRsp))
+}
@@ -10,6 +10,7 @@ import (
 
 	"isc.org/stork"
 	"isc.org/stork/server/agentcomm"
+	"isc.org/stork/server/alerts"
 	"isc.org/stork/server/apps"
 	"isc.org/stork/server/apps/bind9"
 	"isc.org/stork/server/apps/kea"
@@ -33,7 +34,11 @@ type StorkServer struct {
 
 	Pullers *apps.Pullers
 
+	EventRetentionSettings apps.EventRetentionSettings
+
 	EventCenter eventcenter.EventCenter
+
+	AlertsEngine *alerts.Engine
 }
 
 // Global server settings (called application settings in go-flags nomenclature).
@@ -66,6 +71,12 @@ func (ss *StorkServer) ParseArgs() {
 		log.Fatalf("FATAL error: %+v", err)
 	}
 
+	// Process event retention specific args.
+	_, err = parser.AddGroup("Event Retention Flags", "", &ss.EventRetentionSettings)
+	if err != nil {
+		log.Fatalf("FATAL error: %+v", err)
+	}
+
 	// Do args parsing.
 	if _, err := parser.Parse(); err != nil {
 		code := 1
@@ -160,9 +171,42 @@ func NewStorkServer() (ss *StorkServer, err error) {
 		return nil, err
 	}
 
+	// Setup utilization samples retention puller.
+	ss.Pullers.UtilizationRetentionPuller, err = kea.NewUtilizationRetentionPuller(ss.DB, ss.Agents)
+	if err != nil {
+		return nil, err
+	}
+
+	// Setup exhaustion forecast puller.
+	ss.Pullers.ExhaustionForecastPuller, err = kea.NewExhaustionForecastPuller(ss.DB, ss.Agents, ss.EventCenter)
+	if err != nil {
+		return nil, err
+	}
+
+	// Setup Kea log errors puller.
+	ss.Pullers.LogErrorsPuller, err = kea.NewLogErrorsPuller(ss.DB, ss.Agents, ss.EventCenter)
+	if err != nil {
+		return nil, err
+	}
+
+	// Setup events retention puller.
+	ss.Pullers.EventRetentionPuller, err = apps.NewEventRetentionPuller(ss.DB, ss.Agents, &ss.EventRetentionSettings)
+	if err != nil {
+		return nil, err
+	}
+
+	// Evaluate the alert rules whenever the pulled stats and HA state change.
+	ss.AlertsEngine = alerts.NewEngine(ss.DB, ss.EventCenter)
+	ss.Pullers.KeaStatsPuller.AddAfterPullHook(ss.AlertsEngine.Evaluate)
+	ss.Pullers.HAStatusPuller.AddAfterPullHook(ss.AlertsEngine.Evaluate)
+
 	// setup ReST API service
 	r, err := restservice.NewRestAPI(&ss.RestAPISettings, &ss.DBSettings, ss.DB, ss.Agents, ss.EventCenter, ss.Pullers)
 	if err != nil {
+		ss.Pullers.EventRetentionPuller.Shutdown()
+		ss.Pullers.LogErrorsPuller.Shutdown()
+		ss.Pullers.ExhaustionForecastPuller.Shutdown()
+		ss.Pullers.UtilizationRetentionPuller.Shutdown()
 		ss.Pullers.HAStatusPuller.Shutdown()
 		ss.Pullers.KeaHostsPuller.Shutdown()
 		ss.Pullers.KeaStatsPuller.Shutdown()
@@ -171,9 +215,11 @@ func NewStorkServer() (ss *StorkServer, err error) {
 		ss.DB.Close()
 		return nil, err
 	}
+	r.AlertsEngine = ss.AlertsEngine
 	ss.RestAPI = r
 
-	ss.EventCenter.AddInfoEvent("started Stork server", "version: "+stork.Version+"\nbuild date: "+stork.BuildDate)
+	ss.EventCenter.AddInfoEvent("started Stork server", "version: "+stork.Version+"\nbuild date: "+stork.BuildDate,
+		dbmodel.EventCodeServerStarted, dbmodel.EventPayload{"version": stork.Version, "buildDate": stork.BuildDate})
 
 	return ss, nil
 }
@@ -189,9 +235,13 @@ func (ss *StorkServer) Serve() {
 
 // Shutdown for Stork Server state.
 func (ss *StorkServer) Shutdown() {
-	ss.EventCenter.AddInfoEvent("shutting down Stork server")
+	ss.EventCenter.AddInfoEvent("shutting down Stork server", dbmodel.EventCodeServerStopping)
 	log.Println("Shutting down Stork Server")
 	ss.RestAPI.Shutdown()
+	ss.Pullers.EventRetentionPuller.Shutdown()
+	ss.Pullers.LogErrorsPuller.Shutdown()
+	ss.Pullers.ExhaustionForecastPuller.Shutdown()
+	ss.Pullers.UtilizationRetentionPuller.Shutdown()
 	ss.Pullers.HAStatusPuller.Shutdown()
 	ss.Pullers.KeaHostsPuller.Shutdown()
 	ss.Pullers.KeaStatsPuller.Shutdown()
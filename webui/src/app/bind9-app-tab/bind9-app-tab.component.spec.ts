@@ -5,6 +5,7 @@ import { RouterLink, Router, RouterModule, ActivatedRoute } from '@angular/route
 import { RouterTestingModule } from '@angular/router/testing'
 import { TooltipModule } from 'primeng/tooltip'
 import { TabViewModule } from 'primeng/tabview'
+import { TableModule } from 'primeng/table'
 import { MessageService } from 'primeng/api'
 import { LocaltimePipe } from '../localtime.pipe'
 import { MockLocationStrategy } from '@angular/common/testing'
@@ -46,7 +47,14 @@ describe('Bind9AppTabComponent', () => {
     beforeEach(async(() => {
         TestBed.configureTestingModule({
             providers: [UsersService, ServicesService, MessageService, MockLocationStrategy],
-            imports: [HttpClientTestingModule, RouterModule, RouterTestingModule, TooltipModule, TabViewModule],
+            imports: [
+                HttpClientTestingModule,
+                RouterModule,
+                RouterTestingModule,
+                TooltipModule,
+                TabViewModule,
+                TableModule,
+            ],
             declarations: [Bind9AppTabComponent, LocaltimePipe],
         }).compileComponents()
     }))
@@ -130,4 +138,10 @@ describe('Bind9AppTabComponent', () => {
         // A request to rename the app should not be sent.
         expect(servicesApi.renameApp).not.toHaveBeenCalled()
     })
+
+    it('should check if the log target can be viewed', () => {
+        expect(component.logTargetViewable('/var/log/named.log')).toBeTrue()
+        expect(component.logTargetViewable('stderr')).toBeFalse()
+        expect(component.logTargetViewable('syslog')).toBeFalse()
+    })
 })
@@ -227,6 +227,19 @@ export class Bind9AppTabComponent implements OnInit {
         return daemonStatusIconTooltip(daemon)
     }
 
+    /**
+     * Checks if the specified log target can be viewed
+     *
+     * Only the logs that are stored in the file can be viewed in Stork. The
+     * logs output to stderr or syslog can't be viewed in Stork.
+     *
+     * @param target log target output location
+     * @returns true if the log target can be viewed, false otherwise.
+     */
+    logTargetViewable(target): boolean {
+        return target !== 'stdout' && target !== 'stderr' && !target.startsWith('syslog')
+    }
+
     /**
      * Reacts to submitting a new app name from the dialog.
      *
@@ -17,6 +17,7 @@ import { AuthService } from '../auth.service'
 export class EventsPanelComponent implements OnInit, OnChanges {
     events: any = { items: [], total: 0 }
     errorCnt = 0
+    lastEventId = ''
     start = 0
     limit = 10
 
@@ -103,6 +104,7 @@ export class EventsPanelComponent implements OnInit, OnChanges {
      */
     private applyFilter(): void {
         this.refreshEvents(null)
+        this.lastEventId = ''
         this.registerServerSentEvents()
 
         if (this.filter.appType) {
@@ -276,6 +278,11 @@ export class EventsPanelComponent implements OnInit, OnChanges {
         if (this.filter.level) {
             searchParams.append('level', String(this.filter.level))
         }
+        // When reconnecting, ask the server to replay the events missed
+        // since the last received event.
+        if (this.lastEventId) {
+            searchParams.append('lastEventId', this.lastEventId)
+        }
         this.eventSource = new EventSource('/sse?' + searchParams.toString())
 
         this.eventSource.addEventListener(
@@ -305,6 +312,9 @@ export class EventsPanelComponent implements OnInit, OnChanges {
             (ev) => {
                 const data = JSON.parse(ev.data)
                 console.info('sse data', data)
+                if (ev.lastEventId) {
+                    this.lastEventId = ev.lastEventId
+                }
                 this.eventHandler(data)
                 // when events are coming then reset error counter
                 this.errorCnt = 0
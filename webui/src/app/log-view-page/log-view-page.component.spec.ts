@@ -1,4 +1,5 @@
 import { HttpClientTestingModule } from '@angular/common/http/testing'
+import { FormsModule } from '@angular/forms'
 import { async, ComponentFixture, TestBed } from '@angular/core/testing'
 import { ActivatedRoute, convertToParamMap } from '@angular/router'
 import { By } from '@angular/platform-browser'
@@ -9,6 +10,7 @@ import { of } from 'rxjs'
 describe('LogViewPageComponent', () => {
     let component: LogViewPageComponent
     let fixture: ComponentFixture<LogViewPageComponent>
+    let servicesApi: ServicesService
 
     beforeEach(async(() => {
         TestBed.configureTestingModule({
@@ -21,7 +23,7 @@ describe('LogViewPageComponent', () => {
                     },
                 },
             ],
-            imports: [HttpClientTestingModule],
+            imports: [HttpClientTestingModule, FormsModule],
             declarations: [LogViewPageComponent],
         }).compileComponents()
     }))
@@ -29,6 +31,7 @@ describe('LogViewPageComponent', () => {
     beforeEach(() => {
         fixture = TestBed.createComponent(LogViewPageComponent)
         component = fixture.componentInstance
+        servicesApi = fixture.debugElement.injector.get(ServicesService)
         fixture.detectChanges()
     })
 
@@ -46,4 +49,37 @@ describe('LogViewPageComponent', () => {
         expect(appLink.properties.attrs.hasOwnProperty('name')).toBeTrue()
         expect(appLink.properties.attrs.name).toEqual('fantastic-app')
     })
+
+    it('should page backward through the log', () => {
+        const data: any = {
+            appId: 1,
+            appName: 'fantastic-app',
+            appType: 'bind9',
+            contents: ['line 1', 'line 2'],
+            nextAnchorFileId: '2049:12',
+            nextAnchorOffset: 14,
+            beginning: false,
+        }
+        spyOn(servicesApi, 'getLogTail').and.returnValue(of(data))
+        component.loaded = true
+        component.data = data
+
+        // Fetch older logs.
+        component.fetchOlderLog()
+        expect(component.anchorFileId).toBe('2049:12')
+        expect(component.anchorOffset).toBe(14)
+        expect(servicesApi.getLogTail).toHaveBeenCalledWith(undefined, 4000, '2049:12', 14, undefined)
+
+        // Go back to the newest logs with a filter.
+        component.filter = ' line '
+        component.applyFilter()
+        expect(component.anchorFileId).toBeUndefined()
+        expect(component.appliedFilter).toBe('line')
+        expect(servicesApi.getLogTail).toHaveBeenCalledWith(undefined, 4000, undefined, 0, 'line')
+
+        // No more logs to fetch.
+        component.data.beginning = true
+        component.fetchOlderLog()
+        expect(component.anchorFileId).toBeUndefined()
+    })
 })
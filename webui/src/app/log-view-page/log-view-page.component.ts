@@ -13,6 +13,11 @@ import { ServicesService } from '../backend/api/api'
  * Currently, the log viewer is not following the changes in the file.
  * Though, the refresh button is provided which sends a request to
  * get the updated log tail.
+ *
+ * The rotated log files are read by the agent as if they were concatenated
+ * with the current log file, so it is possible to page backward through
+ * the older logs. The lines can be filtered by the agent using a regular
+ * expression.
  */
 @Component({
     selector: 'app-log-view-page',
@@ -31,6 +36,27 @@ export class LogViewPageComponent implements OnInit {
     contents: string[]
     data: any
 
+    /**
+     * Identity of the log file in which the presented data end. It is
+     * undefined when the newest logs are presented.
+     */
+    anchorFileId: string
+
+    /**
+     * Offset in the anchor file at which the presented data end.
+     */
+    anchorOffset = 0
+
+    /**
+     * Regular expression typed by the user to filter the log lines.
+     */
+    filter = ''
+
+    /**
+     * Filter used in the last request sent to the server.
+     */
+    appliedFilter = ''
+
     /**
      * Indicates if the new request for data has been sent and the
      * response is under way. When set to false, the spinner is
@@ -71,41 +97,44 @@ export class LogViewPageComponent implements OnInit {
      */
     private fetchLogTail() {
         this.loaded = false
-        this.servicesApi.getLogTail(this._logId, this.maxLength).subscribe(
-            (data) => {
-                // store received data
-                this.data = data
-
-                // Set other data.
-                this.appId = data.appId
-                this.appName = data.appName
-                this.appType = data.appType
-                if (this.appType.length > 1) {
-                    this.appTypeCapitalized = this.appType.charAt(0).toUpperCase() + this.appType.slice(1)
-                }
-                // Fill the text box with the log contents.
-                this.contents = data.contents
+        const filter = this.appliedFilter.length > 0 ? this.appliedFilter : undefined
+        this.servicesApi
+            .getLogTail(this._logId, this.maxLength, this.anchorFileId, this.anchorOffset, filter)
+            .subscribe(
+                (data) => {
+                    // store received data
+                    this.data = data
 
-                // Disable the spinner.
-                this.loaded = true
+                    // Set other data.
+                    this.appId = data.appId
+                    this.appName = data.appName
+                    this.appType = data.appType
+                    if (this.appType.length > 1) {
+                        this.appTypeCapitalized = this.appType.charAt(0).toUpperCase() + this.appType.slice(1)
+                    }
+                    // Fill the text box with the log contents.
+                    this.contents = data.contents
 
-                // handle error case
-                if (data.error) {
-                    this.loadingError = data.error
-                } else {
-                    this.loadingError = null
-                }
-            },
-            (err) => {
-                this.loaded = true
+                    // Disable the spinner.
+                    this.loaded = true
+
+                    // handle error case
+                    if (data.error) {
+                        this.loadingError = data.error
+                    } else {
+                        this.loadingError = null
+                    }
+                },
+                (err) => {
+                    this.loaded = true
 
-                let msg = err.StatusText
-                if (err.error && err.error.message) {
-                    msg = err.error.message
+                    let msg = err.StatusText
+                    if (err.error && err.error.message) {
+                        msg = err.error.message
+                    }
+                    this.loadingError = msg
                 }
-                this.loadingError = msg
-            }
-        )
+            )
     }
 
     /**
@@ -149,6 +178,53 @@ export class LogViewPageComponent implements OnInit {
         }
     }
 
+    /**
+     * Fetches the part of the log preceding the presented data.
+     *
+     * This action is triggered when the older logs button is clicked. The
+     * action is no-op if the beginning of the oldest rotated log file has
+     * been already reached.
+     */
+    fetchOlderLog() {
+        if (!this.loaded || !this.data || this.data.beginning) {
+            return
+        }
+        this.anchorFileId = this.data.nextAnchorFileId
+        this.anchorOffset = this.data.nextAnchorOffset
+        this.fetchLogTail()
+    }
+
+    /**
+     * Fetches the newest part of the log.
+     *
+     * This action is triggered when the newest logs button is clicked.
+     */
+    fetchNewestLog() {
+        if (!this.loaded) {
+            return
+        }
+        this.anchorFileId = undefined
+        this.anchorOffset = 0
+        this.fetchLogTail()
+    }
+
+    /**
+     * Applies the filter typed by the user and fetches the newest
+     * matching lines.
+     *
+     * This action is triggered when the filter button is clicked or
+     * enter is pressed in the filter box.
+     */
+    applyFilter() {
+        if (!this.loaded) {
+            return
+        }
+        this.appliedFilter = this.filter.trim()
+        this.anchorFileId = undefined
+        this.anchorOffset = 0
+        this.fetchLogTail()
+    }
+
     /**
      * Parses a single line of the log
      *
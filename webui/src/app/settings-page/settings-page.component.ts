@@ -23,6 +23,24 @@ export class SettingsPageComponent implements OnInit {
             kea_stats_puller_interval: ['', [Validators.required, Validators.min(0)]],
             kea_status_puller_interval: ['', [Validators.required, Validators.min(0)]],
             prometheus_url: [''],
+            utilization_retention_puller_interval: ['', [Validators.required, Validators.min(0)]],
+            exhaustion_forecast_puller_interval: ['', [Validators.required, Validators.min(0)]],
+            exhaustion_forecast_window: ['', [Validators.required, Validators.min(1)]],
+            exhaustion_forecast_threshold: ['', [Validators.required, Validators.min(0)]],
+            disk_usage_warning_threshold: ['', [Validators.required, Validators.min(0), Validators.max(100)]],
+            disk_usage_error_threshold: ['', [Validators.required, Validators.min(0), Validators.max(100)]],
+            clock_skew_warning_threshold: ['', [Validators.required, Validators.min(0)]],
+            clock_skew_error_threshold: ['', [Validators.required, Validators.min(0)]],
+            kea_log_errors_puller_interval: ['', [Validators.required, Validators.min(0)]],
+            kea_log_errors_threshold: ['', [Validators.required, Validators.min(0)]],
+            event_dedup_window: ['', [Validators.required, Validators.min(0)]],
+            event_flap_threshold: ['', [Validators.required, Validators.min(0)]],
+            event_flap_window: ['', [Validators.required, Validators.min(1)]],
+            event_retention_puller_interval: ['', [Validators.required, Validators.min(0)]],
+            event_retention_info: ['', [Validators.required, Validators.min(0)]],
+            event_retention_warning: ['', [Validators.required, Validators.min(0)]],
+            event_retention_error: ['', [Validators.required, Validators.min(0)]],
+            config_push_grace_period: ['', [Validators.required, Validators.min(0)]],
         })
     }
 
@@ -34,6 +52,24 @@ export class SettingsPageComponent implements OnInit {
                     'kea_hosts_puller_interval',
                     'kea_stats_puller_interval',
                     'kea_status_puller_interval',
+                    'utilization_retention_puller_interval',
+                    'exhaustion_forecast_puller_interval',
+                    'exhaustion_forecast_window',
+                    'exhaustion_forecast_threshold',
+                    'disk_usage_warning_threshold',
+                    'disk_usage_error_threshold',
+                    'clock_skew_warning_threshold',
+                    'clock_skew_error_threshold',
+                    'kea_log_errors_puller_interval',
+                    'kea_log_errors_threshold',
+                    'event_dedup_window',
+                    'event_flap_threshold',
+                    'event_flap_window',
+                    'event_retention_puller_interval',
+                    'event_retention_info',
+                    'event_retention_warning',
+                    'event_retention_error',
+                    'config_push_grace_period',
                 ]
                 const stringSettings = ['grafana_url', 'prometheus_url']
 